@@ -0,0 +1,124 @@
+// 指示: miu200521358
+package io_common
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// ArchiveFS はzipアーカイブを読み取り専用のfs.FSとして扱う。
+// UTF-8フラグの無いエントリ名はShift-JISとして解釈し、大文字小文字の違いは同名として扱う。
+type ArchiveFS struct {
+	reader *zip.Reader
+	closer io.Closer
+	// foldedNames は小文字化したエントリ名から実際のエントリ名への対応。
+	foldedNames map[string]string
+}
+
+// OpenArchive はzipファイルを開いてArchiveFSを生成する。利用後は Close を呼ぶ。
+func OpenArchive(archivePath string) (*ArchiveFS, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, NewIoFileNotFound(archivePath, err)
+		}
+		return nil, NewIoParseFailed("アーカイブのオープンに失敗しました", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, NewIoParseFailed("アーカイブ情報の取得に失敗しました", err)
+	}
+	archive, err := NewArchiveFS(file, info.Size())
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	archive.closer = file
+	return archive, nil
+}
+
+// NewArchiveFS はメモリ上などのzipデータからArchiveFSを生成する。
+func NewArchiveFS(r io.ReaderAt, size int64) (*ArchiveFS, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return nil, NewIoParseFailed("アーカイブの解析に失敗しました", err)
+	}
+	// zip.Reader はOpen時に初めてエントリ名を索引化するため、その前に名前を置き換える。
+	foldedNames := make(map[string]string, len(reader.File))
+	for _, file := range reader.File {
+		file.Name = decodeArchiveName(file)
+		name := NormalizeFsPath(file.Name)
+		if _, ok := foldedNames[strings.ToLower(name)]; !ok {
+			foldedNames[strings.ToLower(name)] = name
+		}
+	}
+	return &ArchiveFS{reader: reader, foldedNames: foldedNames}, nil
+}
+
+// Open はアーカイブ内のファイルを開く。
+func (a *ArchiveFS) Open(name string) (fs.File, error) {
+	name = NormalizeFsPath(name)
+	file, err := a.reader.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return file, err
+	}
+	if folded, ok := a.foldedNames[strings.ToLower(name)]; ok && folded != name {
+		return a.reader.Open(folded)
+	}
+	return nil, err
+}
+
+// Names はアーカイブ内のファイル名を、指定拡張子(大文字小文字を区別しない)に絞って昇順で返す。
+func (a *ArchiveFS) Names(exts ...string) []string {
+	names := make([]string, 0, len(a.reader.File))
+	for _, file := range a.reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		name := NormalizeFsPath(file.Name)
+		if len(exts) > 0 && !hasArchiveExt(name, exts) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close はOpenArchiveで開いたファイルを閉じる。
+func (a *ArchiveFS) Close() error {
+	if a == nil || a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// decodeArchiveName はUTF-8フラグの無いエントリ名をShift-JISとしてデコードする。
+func decodeArchiveName(file *zip.File) string {
+	if !file.NonUTF8 {
+		return file.Name
+	}
+	decoded, err := japanese.ShiftJIS.NewDecoder().String(file.Name)
+	if err != nil || containsRuneError(decoded) {
+		return file.Name
+	}
+	return decoded
+}
+
+// hasArchiveExt は拡張子が候補に含まれるか判定する。
+func hasArchiveExt(name string, exts []string) bool {
+	for _, ext := range exts {
+		if strings.HasSuffix(strings.ToLower(name), strings.ToLower(ext)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+// 指示: miu200521358
+package io_common
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// newTestArchive はShift-JISのエントリ名を含むzipデータを生成する。
+func newTestArchive(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range entries {
+		raw, err := japanese.ShiftJIS.NewEncoder().String(name)
+		if err != nil {
+			t.Fatalf("shift-jis encode failed: %v", err)
+		}
+		// UTF-8フラグを立てない、日本語環境のアーカイバと同じ形式で書き込む。
+		entry, err := writer.CreateHeader(&zip.FileHeader{Name: raw, NonUTF8: true, Method: zip.Deflate})
+		if err != nil {
+			t.Fatalf("create entry failed: %v", err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatalf("write entry failed: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close archive failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveFSShiftJISNames(t *testing.T) {
+	data := newTestArchive(t, map[string]string{
+		"初音ミク/初音ミク.pmx":   "model",
+		"初音ミク/tex/表情.PNG": "texture",
+		"初音ミク/readme.txt": "readme",
+	})
+	archive, err := NewArchiveFS(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewArchiveFS returned error: %v", err)
+	}
+	defer archive.Close()
+
+	if got := archive.Names(".pmx", ".pmd"); !reflect.DeepEqual(got, []string{"初音ミク/初音ミク.pmx"}) {
+		t.Fatalf("Names mismatch: %v", got)
+	}
+
+	// テクスチャはモデルからの相対パスで、区切りと大文字小文字が異なっても開ける。
+	texturePath := ResolveFsPath("初音ミク/初音ミク.pmx", "tex\\表情.png")
+	if texturePath != "初音ミク/tex/表情.png" {
+		t.Fatalf("ResolveFsPath mismatch: %s", texturePath)
+	}
+	file, err := archive.Open(texturePath)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	content, err := io.ReadAll(file)
+	_ = file.Close()
+	if err != nil || string(content) != "texture" {
+		t.Fatalf("content mismatch: %q %v", content, err)
+	}
+
+	entries, err := fs.ReadDir(archive, "初音ミク")
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("ReadDir mismatch: %v %v", entries, err)
+	}
+	if _, err := archive.Open("初音ミク/無し.png"); err == nil {
+		t.Fatalf("expected not found error")
+	}
+}
+
+func TestOpenFileNotFound(t *testing.T) {
+	data := newTestArchive(t, map[string]string{"a.pmx": "model"})
+	archive, err := NewArchiveFS(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewArchiveFS returned error: %v", err)
+	}
+	if _, err := OpenFile(archive, "b.pmx", "open failed"); merr.ExtractErrorID(err) != ioFileNotFoundErrorID {
+		t.Fatalf("expected file not found error, got %v", err)
+	}
+	if _, err := NewArchiveFS(bytes.NewReader([]byte("not zip")), 7); merr.ExtractErrorID(err) != ioParseFailedErrorID {
+		t.Fatalf("expected parse failed error, got %v", err)
+	}
+}
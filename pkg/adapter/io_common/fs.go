@@ -0,0 +1,45 @@
+// 指示: miu200521358
+package io_common
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// OpenFile はファイルを開く。fsys が nil の場合はOSのファイルシステムから開く。
+func OpenFile(fsys fs.FS, filePath string, openFailedMessage string) (fs.File, error) {
+	var (
+		file fs.File
+		err  error
+	)
+	if fsys == nil {
+		file, err = os.Open(filePath)
+	} else {
+		file, err = fsys.Open(NormalizeFsPath(filePath))
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, NewIoFileNotFound(filePath, err)
+		}
+		return nil, NewIoParseFailed(openFailedMessage, err)
+	}
+	return file, nil
+}
+
+// NormalizeFsPath はWindows形式の区切りを含むパスをfs.FSのパス形式へ変換する。
+func NormalizeFsPath(filePath string) string {
+	normalized := path.Clean(strings.ReplaceAll(filePath, "\\", "/"))
+	normalized = strings.TrimPrefix(normalized, "/")
+	if normalized == "" {
+		return "."
+	}
+	return normalized
+}
+
+// ResolveFsPath はfs.FS上のモデルパスを基準に、モデル内の相対パス(テクスチャ等)を解決する。
+func ResolveFsPath(modelPath, relativePath string) string {
+	return NormalizeFsPath(path.Join(path.Dir(NormalizeFsPath(modelPath)), NormalizeFsPath(relativePath)))
+}
@@ -6,9 +6,15 @@ import "github.com/miu200521358/mlib_go/pkg/usecase/port/io"
 // IFileReader は入出力共通の読み込み契約を表す。
 type IFileReader = io.IFileReader
 
+// IFsFileReader はfs.FS上のファイルからの読み込み契約を表す。
+type IFsFileReader = io.IFsFileReader
+
 // IFileWriter は入出力共通の書き込み契約を表す。
 type IFileWriter = io.IFileWriter
 
+// IStreamFileWriter はio.Writerへの書き込み契約を表す。
+type IStreamFileWriter = io.IStreamFileWriter
+
 // IFileRepository は読み書きの共通契約を表す。
 type IFileRepository = io.IFileRepository
 
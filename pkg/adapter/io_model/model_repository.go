@@ -2,29 +2,33 @@
 package io_model
 
 import (
+	"io/fs"
 	"path/filepath"
 	"strings"
 
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_model/pmd"
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_model/pmx"
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_model/pmxjson"
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_model/x"
 	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
 )
 
 // ModelRepository はモデル入出力のルーティングを表す。
 type ModelRepository struct {
-	pmxRepository *pmx.PmxRepository
-	pmdRepository *pmd.PmdRepository
-	xRepository   io_common.IFileReader
+	pmxRepository     *pmx.PmxRepository
+	pmxJsonRepository *pmxjson.PmxJsonRepository
+	pmdRepository     *pmd.PmdRepository
+	xRepository       io_common.IFileReader
 }
 
 // NewModelRepository はModelRepositoryを生成する。
 func NewModelRepository() *ModelRepository {
 	return &ModelRepository{
-		pmxRepository: pmx.NewPmxRepository(),
-		pmdRepository: pmd.NewPmdRepository(),
-		xRepository:   x.NewXRepository(),
+		pmxRepository:     pmx.NewPmxRepository(),
+		pmxJsonRepository: pmxjson.NewPmxJsonRepository(),
+		pmdRepository:     pmd.NewPmdRepository(),
+		xRepository:       x.NewXRepository(),
 	}
 }
 
@@ -38,6 +42,9 @@ func (r *ModelRepository) SetXRepository(repository io_common.IFileReader) {
 
 // CanLoad は拡張子に応じて読み込み可否を判定する。
 func (r *ModelRepository) CanLoad(path string) bool {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return true
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".pmx", ".pmd", ".x":
@@ -49,6 +56,9 @@ func (r *ModelRepository) CanLoad(path string) bool {
 
 // Load は拡張子に応じて読み込みを行う。
 func (r *ModelRepository) Load(path string) (hashable.IHashable, error) {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return r.pmxJsonRepository.Load(path)
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".pmx":
@@ -65,8 +75,32 @@ func (r *ModelRepository) Load(path string) (hashable.IHashable, error) {
 	}
 }
 
+// LoadFS は拡張子に応じてfs.FS上のファイルを読み込む。
+func (r *ModelRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return r.pmxJsonRepository.LoadFS(fsys, path)
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".pmx":
+		return r.pmxRepository.LoadFS(fsys, path)
+	case ".pmd":
+		return r.pmdRepository.LoadFS(fsys, path)
+	case ".x":
+		if repository, ok := r.xRepository.(io_common.IFsFileReader); ok {
+			return repository.LoadFS(fsys, path)
+		}
+		return nil, io_common.NewIoFormatNotSupported("X形式のfs.FSからの読み込みは未実装です", nil)
+	default:
+		return nil, io_common.NewIoExtInvalid(path, nil)
+	}
+}
+
 // InferName はパスから表示名を推定する。
 func (r *ModelRepository) InferName(path string) string {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return r.pmxJsonRepository.InferName(path)
+	}
 	base := filepath.Base(path)
 	ext := filepath.Ext(base)
 	if ext == "" {
@@ -77,6 +111,9 @@ func (r *ModelRepository) InferName(path string) string {
 
 // Save は拡張子に応じて保存を行う。
 func (r *ModelRepository) Save(path string, data hashable.IHashable, opts io_common.SaveOptions) error {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return r.pmxJsonRepository.Save(path, data, opts)
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".pmx":
@@ -19,6 +19,15 @@ func TestModelRepositoryCanLoadSupportedExt(t *testing.T) {
 	if !repository.CanLoad("sample.x") {
 		t.Fatalf("expected sample.x to be loadable")
 	}
+	if !repository.CanLoad("sample.PMX.json") {
+		t.Fatalf("expected sample.PMX.json to be loadable")
+	}
+	if repository.CanLoad("sample.json") {
+		t.Fatalf("expected sample.json to be not loadable")
+	}
+	if name := repository.InferName("dir/sample.pmx.json"); name != "sample" {
+		t.Fatalf("expected inferred name sample, got %s", name)
+	}
 }
 
 func TestModelRepositoryCanLoadRejectsVrm(t *testing.T) {
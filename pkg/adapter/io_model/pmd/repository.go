@@ -2,6 +2,8 @@
 package pmd
 
 import (
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,6 +11,7 @@ import (
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
 	"github.com/miu200521358/mlib_go/pkg/domain/model"
 	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+	"github.com/miu200521358/mlib_go/pkg/usecase/mmodel"
 )
 
 // PmdRepository はPMDバイナリ入出力を表す。
@@ -36,15 +39,17 @@ func (r *PmdRepository) InferName(path string) string {
 
 // Load はPMDバイナリを読み込む。
 func (r *PmdRepository) Load(path string) (hashable.IHashable, error) {
+	return r.LoadFS(nil, path)
+}
+
+// LoadFS はfs.FS上のPMDバイナリを読み込む。fsys が nil の場合はOSのファイルを読み込む。
+func (r *PmdRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
 	if !r.CanLoad(path) {
 		return nil, io_common.NewIoExtInvalid(path, nil)
 	}
-	file, err := os.Open(path)
+	file, err := io_common.OpenFile(fsys, path, "PMDファイルのオープンに失敗しました")
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, io_common.NewIoFileNotFound(path, err)
-		}
-		return nil, io_common.NewIoParseFailed("PMDファイルのオープンに失敗しました", err)
+		return nil, err
 	}
 	defer file.Close()
 
@@ -83,11 +88,25 @@ func (r *PmdRepository) Save(path string, data hashable.IHashable, opts io_commo
 	}
 	defer file.Close()
 
-	writer := newPmdWriter(file)
-	if err := writer.Write(modelData, opts); err != nil {
+	if err := r.SaveTo(file, modelData, opts); err != nil {
 		return err
 	}
 	modelData.SetPath(savePath)
 	modelData.UpdateHash()
 	return nil
 }
+
+// SaveTo はPMDバイナリをio.Writerへ書き込む。
+func (r *PmdRepository) SaveTo(w io.Writer, data hashable.IHashable, opts io_common.SaveOptions) error {
+	modelData, ok := data.(*model.PmxModel)
+	if !ok {
+		return io_common.NewIoEncodeFailed("PMD保存対象が不正です", nil)
+	}
+	// PMDで表現できない要素は複製したモデル上で近似してから書き込む。失われる情報は DowngradeToPmd で事前に確認できる。
+	downgraded, err := mmodel.DowngradeToPmd(modelData)
+	if err != nil {
+		return err
+	}
+	writer := newPmdWriter(w)
+	return writer.Write(downgraded.Model, opts)
+}
@@ -0,0 +1,65 @@
+// 指示: miu200521358
+package pmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// TestPmdRepositorySaveDowngradesModel は保存時にPMDで表せない要素を近似し、元のモデルは変更しないことを確認する。
+func TestPmdRepositorySaveDowngradesModel(t *testing.T) {
+	m := model.NewPmxModel()
+	m.SetName("テスト")
+	for i, name := range []string{"センター", "左腕"} {
+		bone := &model.Bone{ParentIndex: i - 1, TailIndex: -1, EffectIndex: -1}
+		bone.SetName(name)
+		bone.BoneFlag = model.BONE_FLAG_CAN_ROTATE | model.BONE_FLAG_IS_VISIBLE
+		m.Bones.Append(bone)
+	}
+	for i := 0; i < 3; i++ {
+		m.Vertices.Append(&model.Vertex{
+			Position:   mmath.Vec3{Vec: r3.Vec{X: float64(i), Y: float64(i % 2)}},
+			Deform:     model.NewSdef(0, 1, 0.5),
+			DeformType: model.SDEF,
+			EdgeFactor: 1,
+		})
+	}
+	m.Faces.Append(&model.Face{VertexIndexes: [3]int{0, 1, 2}})
+	material := model.NewMaterial()
+	material.SetName("材質")
+	material.VerticesCount = 3
+	m.Materials.Append(material)
+
+	smile := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX, Panel: model.MORPH_PANEL_EYE_UPPER_LEFT}
+	smile.SetName("笑い")
+	smile.Offsets = []model.IMorphOffset{&model.VertexMorphOffset{VertexIndex: 1, Position: mmath.Vec3{Vec: r3.Vec{Y: 1}}}}
+	m.Morphs.Append(smile)
+	group := &model.Morph{MorphType: model.MORPH_TYPE_GROUP, Panel: model.MORPH_PANEL_EYE_UPPER_LEFT}
+	group.SetName("まとめ")
+	group.Offsets = []model.IMorphOffset{&model.GroupMorphOffset{MorphIndex: 0, MorphFactor: 0.5}}
+	m.Morphs.Append(group)
+
+	path := filepath.Join(t.TempDir(), "test.pmd")
+	repository := NewPmdRepository()
+	if err := repository.Save(path, m, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	if vertex, _ := m.Vertices.Get(0); vertex.DeformType != model.SDEF {
+		t.Fatalf("source model should not be changed: %v", vertex.DeformType)
+	}
+
+	loaded, err := repository.Load(path)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	reloaded := loaded.(*model.PmxModel)
+	// グループモーフは頂点モーフへ変換されて保存される。
+	if morph, err := reloaded.Morphs.GetByName("まとめ"); err != nil || morph.MorphType != model.MORPH_TYPE_VERTEX {
+		t.Fatalf("group morph should be saved as vertex morph: %v %v", morph, err)
+	}
+}
@@ -2,6 +2,8 @@
 package pmx
 
 import (
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -36,15 +38,17 @@ func (r *PmxRepository) InferName(path string) string {
 
 // Load はPMXバイナリを読み込む。
 func (r *PmxRepository) Load(path string) (hashable.IHashable, error) {
+	return r.LoadFS(nil, path)
+}
+
+// LoadFS はfs.FS上のPMXバイナリを読み込む。fsys が nil の場合はOSのファイルを読み込む。
+func (r *PmxRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
 	if !r.CanLoad(path) {
 		return nil, io_common.NewIoExtInvalid(path, nil)
 	}
-	file, err := os.Open(path)
+	file, err := io_common.OpenFile(fsys, path, "PMXファイルのオープンに失敗しました")
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, io_common.NewIoFileNotFound(path, err)
-		}
-		return nil, io_common.NewIoParseFailed("PMXファイルのオープンに失敗しました", err)
+		return nil, err
 	}
 	defer file.Close()
 
@@ -83,11 +87,20 @@ func (r *PmxRepository) Save(path string, data hashable.IHashable, opts io_commo
 	}
 	defer file.Close()
 
-	writer := newPmxWriter(file)
-	if err := writer.Write(modelData, opts); err != nil {
+	if err := r.SaveTo(file, modelData, opts); err != nil {
 		return err
 	}
 	modelData.SetPath(savePath)
 	modelData.UpdateHash()
 	return nil
 }
+
+// SaveTo はPMXバイナリをio.Writerへ書き込む。
+func (r *PmxRepository) SaveTo(w io.Writer, data hashable.IHashable, opts io_common.SaveOptions) error {
+	modelData, ok := data.(*model.PmxModel)
+	if !ok {
+		return io_common.NewIoEncodeFailed("PMX保存対象が不正です", nil)
+	}
+	writer := newPmxWriter(w)
+	return writer.Write(modelData, opts)
+}
@@ -2,31 +2,38 @@
 package io_model
 
 import (
+	"io/fs"
 	"path/filepath"
 	"strings"
 
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_model/pmd"
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_model/pmx"
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_model/pmxjson"
 	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
 )
 
-// PmxPmdRepository はPMX/PMD入出力のルーティングを表す。
+// PmxPmdRepository はPMX/PMD(PMX JSONを含む)入出力のルーティングを表す。
 type PmxPmdRepository struct {
-	pmxRepository *pmx.PmxRepository
-	pmdRepository *pmd.PmdRepository
+	pmxRepository     *pmx.PmxRepository
+	pmxJsonRepository *pmxjson.PmxJsonRepository
+	pmdRepository     *pmd.PmdRepository
 }
 
 // NewPmxPmdRepository はPmxPmdRepositoryを生成する。
 func NewPmxPmdRepository() *PmxPmdRepository {
 	return &PmxPmdRepository{
-		pmxRepository: pmx.NewPmxRepository(),
-		pmdRepository: pmd.NewPmdRepository(),
+		pmxRepository:     pmx.NewPmxRepository(),
+		pmxJsonRepository: pmxjson.NewPmxJsonRepository(),
+		pmdRepository:     pmd.NewPmdRepository(),
 	}
 }
 
 // CanLoad は拡張子に応じて読み込み可否を判定する。
 func (r *PmxPmdRepository) CanLoad(path string) bool {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return true
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".pmx", ".pmd":
@@ -38,6 +45,9 @@ func (r *PmxPmdRepository) CanLoad(path string) bool {
 
 // Load は拡張子に応じて読み込みを行う。
 func (r *PmxPmdRepository) Load(path string) (hashable.IHashable, error) {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return r.pmxJsonRepository.Load(path)
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".pmx":
@@ -49,8 +59,27 @@ func (r *PmxPmdRepository) Load(path string) (hashable.IHashable, error) {
 	}
 }
 
+// LoadFS は拡張子に応じてfs.FS上のファイルを読み込む。
+func (r *PmxPmdRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return r.pmxJsonRepository.LoadFS(fsys, path)
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".pmx":
+		return r.pmxRepository.LoadFS(fsys, path)
+	case ".pmd":
+		return r.pmdRepository.LoadFS(fsys, path)
+	default:
+		return nil, io_common.NewIoExtInvalid(path, nil)
+	}
+}
+
 // InferName はパスから表示名を推定する。
 func (r *PmxPmdRepository) InferName(path string) string {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return r.pmxJsonRepository.InferName(path)
+	}
 	base := filepath.Base(path)
 	ext := filepath.Ext(base)
 	if ext == "" {
@@ -61,6 +90,9 @@ func (r *PmxPmdRepository) InferName(path string) string {
 
 // Save は拡張子に応じて保存を行う。
 func (r *PmxPmdRepository) Save(path string, data hashable.IHashable, opts io_common.SaveOptions) error {
+	if r.pmxJsonRepository.CanLoad(path) {
+		return r.pmxJsonRepository.Save(path, data, opts)
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".pmx":
@@ -0,0 +1,37 @@
+// 指示: miu200521358
+package io_model
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+)
+
+func TestPmxPmdRepositoryRoutesPmxJson(t *testing.T) {
+	repository := NewPmxPmdRepository()
+	if !repository.CanLoad("sample.PMX.json") {
+		t.Fatalf("expected sample.PMX.json to be loadable")
+	}
+	if repository.CanLoad("sample.json") {
+		t.Fatalf("expected sample.json to be not loadable")
+	}
+	if name := repository.InferName("dir/sample.pmx.json"); name != "sample" {
+		t.Fatalf("expected inferred name sample, got %s", name)
+	}
+
+	source := model.NewPmxModel()
+	source.SetName("JSON経由")
+	path := filepath.Join(t.TempDir(), "sample.pmx.json")
+	if err := repository.Save(path, source, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("expected save to succeed, got %v", err)
+	}
+	loaded, err := repository.Load(path)
+	if err != nil {
+		t.Fatalf("expected load to succeed, got %v", err)
+	}
+	if name := loaded.(*model.PmxModel).Name(); name != "JSON経由" {
+		t.Fatalf("expected loaded name JSON経由, got %s", name)
+	}
+}
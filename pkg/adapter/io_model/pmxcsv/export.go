@@ -0,0 +1,554 @@
+// 指示: miu200521358
+package pmxcsv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_csv"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+)
+
+// BuildPmxCsvRecords はモデル全体をPMXEditor互換のCSVレコードへ変換する。
+func BuildPmxCsvRecords(modelData *model.PmxModel) ([][]string, error) {
+	if modelData == nil {
+		return nil, io_common.NewIoEncodeFailed("PMX CSV出力対象のモデルがnilです", nil)
+	}
+	sections := []any{
+		[]modelInfoRow{{
+			Kind:           sectionModelInfo,
+			Name:           modelData.Name(),
+			EnglishName:    modelData.EnglishName,
+			Comment:        modelData.Comment,
+			EnglishComment: modelData.EnglishComment,
+		}},
+		vertexRows(modelData),
+		faceRows(modelData),
+		materialRows(modelData),
+		boneRows(modelData),
+		ikLinkRows(modelData),
+		morphRows(modelData),
+	}
+	vertexMorphs, uvMorphs, boneMorphs, materialMorphs, groupMorphs := morphOffsetRows(modelData)
+	sections = append(sections,
+		vertexMorphs,
+		uvMorphs,
+		boneMorphs,
+		materialMorphs,
+		groupMorphs,
+		nodeRows(modelData),
+		nodeItemRows(modelData),
+		bodyRows(modelData),
+		jointRows(modelData),
+	)
+
+	records := make([][]string, 0)
+	for _, rows := range sections {
+		csvModel, err := io_csv.Marshal(rows)
+		if err != nil {
+			return nil, err
+		}
+		sectionRecords := csvModel.Records()
+		if len(sectionRecords) <= 1 {
+			// 要素のない区分はヘッダも出力しない。
+			continue
+		}
+		records = append(records, sectionRecords...)
+	}
+	return records, nil
+}
+
+// vertexRows は頂点行を生成する。
+func vertexRows(modelData *model.PmxModel) []vertexRow {
+	rows := make([]vertexRow, 0, modelData.Vertices.Len())
+	for _, vertex := range modelData.Vertices.Values() {
+		row := vertexRow{
+			Kind:       sectionVertex,
+			Index:      vertex.Index(),
+			PositionX:  vertex.Position.X,
+			PositionY:  vertex.Position.Y,
+			PositionZ:  vertex.Position.Z,
+			NormalX:    vertex.Normal.X,
+			NormalY:    vertex.Normal.Y,
+			NormalZ:    vertex.Normal.Z,
+			EdgeFactor: vertex.EdgeFactor,
+			U:          vertex.Uv.X,
+			V:          vertex.Uv.Y,
+		}
+		values := [4][4]float64{}
+		for i, uv := range vertex.ExtendedUvs {
+			if i >= len(values) {
+				break
+			}
+			values[i] = [4]float64{uv.X, uv.Y, uv.Z, uv.W}
+		}
+		row.Ex1X, row.Ex1Y, row.Ex1Z, row.Ex1W = values[0][0], values[0][1], values[0][2], values[0][3]
+		row.Ex2X, row.Ex2Y, row.Ex2Z, row.Ex2W = values[1][0], values[1][1], values[1][2], values[1][3]
+		row.Ex3X, row.Ex3Y, row.Ex3Z, row.Ex3W = values[2][0], values[2][1], values[2][2], values[2][3]
+		row.Ex4X, row.Ex4Y, row.Ex4Z, row.Ex4W = values[3][0], values[3][1], values[3][2], values[3][3]
+
+		if vertex.Deform != nil {
+			row.DeformType = int(vertex.Deform.DeformType())
+			names := [4]*string{&row.Bone1, &row.Bone2, &row.Bone3, &row.Bone4}
+			weights := [4]*float64{&row.Weight1, &row.Weight2, &row.Weight3, &row.Weight4}
+			indexes := vertex.Deform.Indexes()
+			deformWeights := vertex.Deform.Weights()
+			for i := 0; i < len(indexes) && i < len(names); i++ {
+				*names[i] = boneName(modelData, indexes[i])
+				if i < len(deformWeights) {
+					*weights[i] = deformWeights[i]
+				}
+			}
+			if sdef, ok := vertex.Deform.(*model.Sdef); ok {
+				row.SdefCX, row.SdefCY, row.SdefCZ = sdef.SdefC.X, sdef.SdefC.Y, sdef.SdefC.Z
+				row.SdefR0X, row.SdefR0Y, row.SdefR0Z = sdef.SdefR0.X, sdef.SdefR0.Y, sdef.SdefR0.Z
+				row.SdefR1X, row.SdefR1Y, row.SdefR1Z = sdef.SdefR1.X, sdef.SdefR1.Y, sdef.SdefR1.Z
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// faceRows は材質ごとの面行を生成する。
+func faceRows(modelData *model.PmxModel) []faceRow {
+	rows := make([]faceRow, 0, modelData.Faces.Len())
+	faces := modelData.Faces.Values()
+	start := 0
+	for _, material := range modelData.Materials.Values() {
+		count := material.VerticesCount / 3
+		for i := 0; i < count && start+i < len(faces); i++ {
+			face := faces[start+i]
+			rows = append(rows, faceRow{
+				Kind:         sectionFace,
+				MaterialName: material.Name(),
+				Index:        i,
+				Vertex1:      face.VertexIndexes[0],
+				Vertex2:      face.VertexIndexes[1],
+				Vertex3:      face.VertexIndexes[2],
+			})
+		}
+		start += count
+	}
+	return rows
+}
+
+// materialRows は材質行を生成する。
+func materialRows(modelData *model.PmxModel) []materialRow {
+	rows := make([]materialRow, 0, modelData.Materials.Len())
+	for _, material := range modelData.Materials.Values() {
+		toonPath := textureName(modelData, material.ToonTextureIndex)
+		if material.ToonSharingFlag == model.TOON_SHARING_SHARING {
+			toonPath = sharedToonName(material.ToonTextureIndex)
+		}
+		rows = append(rows, materialRow{
+			Kind:          sectionMaterial,
+			Name:          material.Name(),
+			EnglishName:   material.EnglishName,
+			DiffuseR:      material.Diffuse.X,
+			DiffuseG:      material.Diffuse.Y,
+			DiffuseB:      material.Diffuse.Z,
+			DiffuseA:      material.Diffuse.W,
+			SpecularR:     material.Specular.X,
+			SpecularG:     material.Specular.Y,
+			SpecularB:     material.Specular.Z,
+			SpecularPower: material.Specular.W,
+			AmbientR:      material.Ambient.X,
+			AmbientG:      material.Ambient.Y,
+			AmbientB:      material.Ambient.Z,
+			DoubleSided:   flagValue(material.DrawFlag&model.DRAW_FLAG_DOUBLE_SIDED_DRAWING != 0),
+			GroundShadow:  flagValue(material.DrawFlag&model.DRAW_FLAG_GROUND_SHADOW != 0),
+			SelfShadowMap: flagValue(material.DrawFlag&model.DRAW_FLAG_DRAWING_ON_SELF_SHADOW_MAPS != 0),
+			SelfShadow:    flagValue(material.DrawFlag&model.DRAW_FLAG_DRAWING_SELF_SHADOWS != 0),
+			Edge:          flagValue(material.DrawFlag&model.DRAW_FLAG_DRAWING_EDGE != 0),
+			EdgeSize:      material.EdgeSize,
+			EdgeR:         material.Edge.X,
+			EdgeG:         material.Edge.Y,
+			EdgeB:         material.Edge.Z,
+			EdgeA:         material.Edge.W,
+			TexturePath:   textureName(modelData, material.TextureIndex),
+			SpherePath:    textureName(modelData, material.SphereTextureIndex),
+			SphereMode:    int(material.SphereMode),
+			ToonPath:      toonPath,
+			Memo:          material.Memo,
+		})
+	}
+	return rows
+}
+
+// boneRows はボーン行を生成する。
+func boneRows(modelData *model.PmxModel) []boneRow {
+	rows := make([]boneRow, 0, modelData.Bones.Len())
+	for _, bone := range modelData.Bones.Values() {
+		row := boneRow{
+			Kind:                sectionBone,
+			Name:                bone.Name(),
+			EnglishName:         bone.EnglishName,
+			Layer:               bone.Layer,
+			AfterPhysics:        flagValue(bone.BoneFlag&model.BONE_FLAG_IS_AFTER_PHYSICS_DEFORM != 0),
+			PositionX:           bone.Position.X,
+			PositionY:           bone.Position.Y,
+			PositionZ:           bone.Position.Z,
+			CanRotate:           flagValue(bone.BoneFlag&model.BONE_FLAG_CAN_ROTATE != 0),
+			CanTranslate:        flagValue(bone.BoneFlag&model.BONE_FLAG_CAN_TRANSLATE != 0),
+			IsIk:                flagValue(bone.BoneFlag&model.BONE_FLAG_IS_IK != 0),
+			Visible:             flagValue(bone.BoneFlag&model.BONE_FLAG_IS_VISIBLE != 0),
+			CanManipulate:       flagValue(bone.BoneFlag&model.BONE_FLAG_CAN_MANIPULATE != 0),
+			ParentName:          boneName(modelData, bone.ParentIndex),
+			TailIsBone:          flagValue(bone.BoneFlag&model.BONE_FLAG_TAIL_IS_BONE != 0),
+			TailName:            boneName(modelData, bone.TailIndex),
+			TailX:               bone.TailPosition.X,
+			TailY:               bone.TailPosition.Y,
+			TailZ:               bone.TailPosition.Z,
+			ExternalLocal:       flagValue(bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_LOCAL != 0),
+			ExternalRotation:    flagValue(bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_ROTATION != 0),
+			ExternalTranslation: flagValue(bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_TRANSLATION != 0),
+			EffectFactor:        bone.EffectFactor,
+			EffectName:          boneName(modelData, bone.EffectIndex),
+			FixedAxis:           flagValue(bone.BoneFlag&model.BONE_FLAG_HAS_FIXED_AXIS != 0),
+			FixedAxisX:          bone.FixedAxis.X,
+			FixedAxisY:          bone.FixedAxis.Y,
+			FixedAxisZ:          bone.FixedAxis.Z,
+			LocalAxis:           flagValue(bone.BoneFlag&model.BONE_FLAG_HAS_LOCAL_AXIS != 0),
+			LocalAxisXX:         bone.LocalAxisX.X,
+			LocalAxisXY:         bone.LocalAxisX.Y,
+			LocalAxisXZ:         bone.LocalAxisX.Z,
+			LocalAxisZX:         bone.LocalAxisZ.X,
+			LocalAxisZY:         bone.LocalAxisZ.Y,
+			LocalAxisZZ:         bone.LocalAxisZ.Z,
+			ExternalParent:      flagValue(bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_PARENT_DEFORM != 0),
+			EffectorKey:         bone.EffectorKey,
+		}
+		if bone.Ik != nil {
+			row.IkTargetName = boneName(modelData, bone.Ik.BoneIndex)
+			row.IkLoopCount = bone.Ik.LoopCount
+			row.IkUnitAngle = mmath.RadToDeg(bone.Ik.UnitRotation.X)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// ikLinkRows はIKリンク行を生成する。
+func ikLinkRows(modelData *model.PmxModel) []ikLinkRow {
+	rows := make([]ikLinkRow, 0)
+	for _, bone := range modelData.Bones.Values() {
+		if bone.Ik == nil {
+			continue
+		}
+		for _, link := range bone.Ik.Links {
+			minDeg := link.MinAngleLimit.RadToDeg()
+			maxDeg := link.MaxAngleLimit.RadToDeg()
+			rows = append(rows, ikLinkRow{
+				Kind:       sectionIkLink,
+				BoneName:   bone.Name(),
+				LinkName:   boneName(modelData, link.BoneIndex),
+				AngleLimit: flagValue(link.AngleLimit),
+				MinX:       minDeg.X,
+				MaxX:       maxDeg.X,
+				MinY:       minDeg.Y,
+				MaxY:       maxDeg.Y,
+				MinZ:       minDeg.Z,
+				MaxZ:       maxDeg.Z,
+			})
+		}
+	}
+	return rows
+}
+
+// morphRows はモーフ行を生成する。
+func morphRows(modelData *model.PmxModel) []morphRow {
+	rows := make([]morphRow, 0, modelData.Morphs.Len())
+	for _, morph := range modelData.Morphs.Values() {
+		rows = append(rows, morphRow{
+			Kind:        sectionMorph,
+			Name:        morph.Name(),
+			EnglishName: morph.EnglishName,
+			Panel:       int(morph.Panel),
+			MorphType:   int(morph.MorphType),
+		})
+	}
+	return rows
+}
+
+// morphOffsetRows はモーフオフセット行を種別ごとに生成する。
+func morphOffsetRows(modelData *model.PmxModel) ([]vertexMorphRow, []uvMorphRow, []boneMorphRow, []materialMorphRow, []groupMorphRow) {
+	vertexMorphs := make([]vertexMorphRow, 0)
+	uvMorphs := make([]uvMorphRow, 0)
+	boneMorphs := make([]boneMorphRow, 0)
+	materialMorphs := make([]materialMorphRow, 0)
+	groupMorphs := make([]groupMorphRow, 0)
+	for _, morph := range modelData.Morphs.Values() {
+		for _, offset := range morph.Offsets {
+			switch o := offset.(type) {
+			case *model.VertexMorphOffset:
+				vertexMorphs = append(vertexMorphs, vertexMorphRow{
+					Kind:        sectionVertexMorph,
+					MorphName:   morph.Name(),
+					VertexIndex: o.VertexIndex,
+					X:           o.Position.X,
+					Y:           o.Position.Y,
+					Z:           o.Position.Z,
+				})
+			case *model.UvMorphOffset:
+				uvMorphs = append(uvMorphs, uvMorphRow{
+					Kind:        sectionUvMorph,
+					MorphName:   morph.Name(),
+					VertexIndex: o.VertexIndex,
+					X:           o.Uv.X,
+					Y:           o.Uv.Y,
+					Z:           o.Uv.Z,
+					W:           o.Uv.W,
+				})
+			case *model.BoneMorphOffset:
+				degrees := o.Rotation.ToDegrees()
+				boneMorphs = append(boneMorphs, boneMorphRow{
+					Kind:      sectionBoneMorph,
+					MorphName: morph.Name(),
+					BoneName:  boneName(modelData, o.BoneIndex),
+					X:         o.Position.X,
+					Y:         o.Position.Y,
+					Z:         o.Position.Z,
+					RotationX: degrees.X,
+					RotationY: degrees.Y,
+					RotationZ: degrees.Z,
+				})
+			case *model.MaterialMorphOffset:
+				materialMorphs = append(materialMorphs, materialMorphRow{
+					Kind:          sectionMaterialMorph,
+					MorphName:     morph.Name(),
+					MaterialName:  materialName(modelData, o.MaterialIndex),
+					CalcMode:      int(o.CalcMode),
+					DiffuseR:      o.Diffuse.X,
+					DiffuseG:      o.Diffuse.Y,
+					DiffuseB:      o.Diffuse.Z,
+					DiffuseA:      o.Diffuse.W,
+					SpecularR:     o.Specular.X,
+					SpecularG:     o.Specular.Y,
+					SpecularB:     o.Specular.Z,
+					SpecularPower: o.Specular.W,
+					AmbientR:      o.Ambient.X,
+					AmbientG:      o.Ambient.Y,
+					AmbientB:      o.Ambient.Z,
+					EdgeR:         o.Edge.X,
+					EdgeG:         o.Edge.Y,
+					EdgeB:         o.Edge.Z,
+					EdgeA:         o.Edge.W,
+					EdgeSize:      o.EdgeSize,
+					TextureR:      o.TextureFactor.X,
+					TextureG:      o.TextureFactor.Y,
+					TextureB:      o.TextureFactor.Z,
+					TextureA:      o.TextureFactor.W,
+					SphereR:       o.SphereTextureFactor.X,
+					SphereG:       o.SphereTextureFactor.Y,
+					SphereB:       o.SphereTextureFactor.Z,
+					SphereA:       o.SphereTextureFactor.W,
+					ToonR:         o.ToonTextureFactor.X,
+					ToonG:         o.ToonTextureFactor.Y,
+					ToonB:         o.ToonTextureFactor.Z,
+					ToonA:         o.ToonTextureFactor.W,
+				})
+			case *model.GroupMorphOffset:
+				groupMorphs = append(groupMorphs, groupMorphRow{
+					Kind:      sectionGroupMorph,
+					MorphName: morph.Name(),
+					Target:    morphName(modelData, o.MorphIndex),
+					Factor:    o.MorphFactor,
+				})
+			}
+		}
+	}
+	return vertexMorphs, uvMorphs, boneMorphs, materialMorphs, groupMorphs
+}
+
+// nodeRows は表示枠行を生成する。
+func nodeRows(modelData *model.PmxModel) []nodeRow {
+	rows := make([]nodeRow, 0, modelData.DisplaySlots.Len())
+	for _, slot := range modelData.DisplaySlots.Values() {
+		rows = append(rows, nodeRow{Kind: sectionNode, Name: slot.Name(), EnglishName: slot.EnglishName})
+	}
+	return rows
+}
+
+// nodeItemRows は表示枠要素行を生成する。
+func nodeItemRows(modelData *model.PmxModel) []nodeItemRow {
+	rows := make([]nodeItemRow, 0)
+	for _, slot := range modelData.DisplaySlots.Values() {
+		for _, reference := range slot.References {
+			name := boneName(modelData, reference.DisplayIndex)
+			if reference.DisplayType == model.DISPLAY_TYPE_MORPH {
+				name = morphName(modelData, reference.DisplayIndex)
+			}
+			rows = append(rows, nodeItemRow{
+				Kind:     sectionNodeItem,
+				NodeName: slot.Name(),
+				ItemType: int(reference.DisplayType),
+				ItemName: name,
+			})
+		}
+	}
+	return rows
+}
+
+// bodyRows は剛体行を生成する。
+func bodyRows(modelData *model.PmxModel) []bodyRow {
+	rows := make([]bodyRow, 0, modelData.RigidBodies.Len())
+	for _, rigidBody := range modelData.RigidBodies.Values() {
+		degrees := rigidBody.Rotation.RadToDeg()
+		rows = append(rows, bodyRow{
+			Kind:           sectionBody,
+			Name:           rigidBody.Name(),
+			EnglishName:    rigidBody.EnglishName,
+			BoneName:       boneName(modelData, rigidBody.BoneIndex),
+			PhysicsType:    int(rigidBody.PhysicsType),
+			Group:          int(rigidBody.CollisionGroup.Group) + 1,
+			NoCollision:    noCollisionGroups(rigidBody.CollisionGroup.Mask),
+			Shape:          int(rigidBody.Shape),
+			SizeX:          rigidBody.Size.X,
+			SizeY:          rigidBody.Size.Y,
+			SizeZ:          rigidBody.Size.Z,
+			PositionX:      rigidBody.Position.X,
+			PositionY:      rigidBody.Position.Y,
+			PositionZ:      rigidBody.Position.Z,
+			RotationX:      degrees.X,
+			RotationY:      degrees.Y,
+			RotationZ:      degrees.Z,
+			Mass:           rigidBody.Param.Mass,
+			LinearDamping:  rigidBody.Param.LinearDamping,
+			AngularDamping: rigidBody.Param.AngularDamping,
+			Restitution:    rigidBody.Param.Restitution,
+			Friction:       rigidBody.Param.Friction,
+		})
+	}
+	return rows
+}
+
+// jointRows はジョイント行を生成する。
+func jointRows(modelData *model.PmxModel) []jointRow {
+	rows := make([]jointRow, 0, modelData.Joints.Len())
+	for _, joint := range modelData.Joints.Values() {
+		param := joint.Param
+		rotation := param.Rotation.RadToDeg()
+		rotationMin := param.RotationLimitMin.RadToDeg()
+		rotationMax := param.RotationLimitMax.RadToDeg()
+		rows = append(rows, jointRow{
+			Kind:               sectionJoint,
+			Name:               joint.Name(),
+			EnglishName:        joint.EnglishName,
+			RigidBodyNameA:     rigidBodyName(modelData, joint.RigidBodyIndexA),
+			RigidBodyNameB:     rigidBodyName(modelData, joint.RigidBodyIndexB),
+			PositionX:          param.Position.X,
+			PositionY:          param.Position.Y,
+			PositionZ:          param.Position.Z,
+			RotationX:          rotation.X,
+			RotationY:          rotation.Y,
+			RotationZ:          rotation.Z,
+			TranslationMinX:    param.TranslationLimitMin.X,
+			TranslationMinY:    param.TranslationLimitMin.Y,
+			TranslationMinZ:    param.TranslationLimitMin.Z,
+			TranslationMaxX:    param.TranslationLimitMax.X,
+			TranslationMaxY:    param.TranslationLimitMax.Y,
+			TranslationMaxZ:    param.TranslationLimitMax.Z,
+			RotationMinX:       rotationMin.X,
+			RotationMinY:       rotationMin.Y,
+			RotationMinZ:       rotationMin.Z,
+			RotationMaxX:       rotationMax.X,
+			RotationMaxY:       rotationMax.Y,
+			RotationMaxZ:       rotationMax.Z,
+			SpringTranslationX: param.SpringConstantTranslation.X,
+			SpringTranslationY: param.SpringConstantTranslation.Y,
+			SpringTranslationZ: param.SpringConstantTranslation.Z,
+			SpringRotationX:    param.SpringConstantRotation.X,
+			SpringRotationY:    param.SpringConstantRotation.Y,
+			SpringRotationZ:    param.SpringConstantRotation.Z,
+		})
+	}
+	return rows
+}
+
+// noCollisionGroups は非衝突グループを1始まりの空白区切り文字列へ変換する。
+func noCollisionGroups(mask uint16) string {
+	groups := make([]string, 0, 16)
+	for i := 0; i < 16; i++ {
+		if mask&(1<<uint(i)) == 0 {
+			groups = append(groups, strconv.Itoa(i+1))
+		}
+	}
+	return strings.Join(groups, " ")
+}
+
+// sharedToonName は共有トゥーンのファイル名を返す。
+func sharedToonName(index int) string {
+	return fmt.Sprintf("toon%02d.bmp", index+1)
+}
+
+// flagValue は真偽値を0/1へ変換する。
+func flagValue(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// boneName はボーン名を返す。存在しない場合は空文字。
+func boneName(modelData *model.PmxModel, index int) string {
+	if index < 0 {
+		return ""
+	}
+	bone, err := modelData.Bones.Get(index)
+	if err != nil || bone == nil {
+		return ""
+	}
+	return bone.Name()
+}
+
+// textureName はテクスチャパスを返す。存在しない場合は空文字。
+func textureName(modelData *model.PmxModel, index int) string {
+	if index < 0 {
+		return ""
+	}
+	texture, err := modelData.Textures.Get(index)
+	if err != nil || texture == nil {
+		return ""
+	}
+	return texture.Name()
+}
+
+// materialName は材質名を返す。存在しない場合は空文字。
+func materialName(modelData *model.PmxModel, index int) string {
+	if index < 0 {
+		return ""
+	}
+	material, err := modelData.Materials.Get(index)
+	if err != nil || material == nil {
+		return ""
+	}
+	return material.Name()
+}
+
+// morphName はモーフ名を返す。存在しない場合は空文字。
+func morphName(modelData *model.PmxModel, index int) string {
+	if index < 0 {
+		return ""
+	}
+	morph, err := modelData.Morphs.Get(index)
+	if err != nil || morph == nil {
+		return ""
+	}
+	return morph.Name()
+}
+
+// rigidBodyName は剛体名を返す。存在しない場合は空文字。
+func rigidBodyName(modelData *model.PmxModel, index int) string {
+	if index < 0 {
+		return ""
+	}
+	rigidBody, err := modelData.RigidBodies.Get(index)
+	if err != nil || rigidBody == nil {
+		return ""
+	}
+	return rigidBody.Name()
+}
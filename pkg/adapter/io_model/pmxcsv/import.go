@@ -0,0 +1,963 @@
+// 指示: miu200521358
+package pmxcsv
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_csv"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/collection"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// sharedToonPattern は共有トゥーンのファイル名パターン。
+var sharedToonPattern = regexp.MustCompile(`(?i)^toon(\d{2})\.bmp$`)
+
+// PmxCsvApplyResult はCSV取り込みの区分別件数を表す。
+// 面・IKリンク・モーフオフセット・表示枠要素は置き換えた親要素の数を Updated に数える。
+type PmxCsvApplyResult struct {
+	Updated map[string]int
+	Added   map[string]int
+}
+
+// csvLine は行番号付きのCSV行を表す。
+type csvLine struct {
+	number int
+	cells  []string
+}
+
+// numberedRow は行番号付きの解析済み行を表す。
+type numberedRow[T any] struct {
+	number int
+	row    T
+}
+
+// pmxCsvSections は区分別の解析済み行を表す。
+type pmxCsvSections struct {
+	modelInfos     []numberedRow[modelInfoRow]
+	vertices       []numberedRow[vertexRow]
+	faces          []numberedRow[faceRow]
+	materials      []numberedRow[materialRow]
+	bones          []numberedRow[boneRow]
+	ikLinks        []numberedRow[ikLinkRow]
+	morphs         []numberedRow[morphRow]
+	vertexMorphs   []numberedRow[vertexMorphRow]
+	uvMorphs       []numberedRow[uvMorphRow]
+	boneMorphs     []numberedRow[boneMorphRow]
+	materialMorphs []numberedRow[materialMorphRow]
+	groupMorphs    []numberedRow[groupMorphRow]
+	nodes          []numberedRow[nodeRow]
+	nodeItems      []numberedRow[nodeItemRow]
+	bodies         []numberedRow[bodyRow]
+	joints         []numberedRow[jointRow]
+}
+
+// pmxCsvApplier はモデルへのCSV反映状態を表す。
+type pmxCsvApplier struct {
+	model  *model.PmxModel
+	result PmxCsvApplyResult
+}
+
+// ApplyPmxCsvRecords はCSVレコードに記載された要素だけをモデルへ反映する。
+// 名前付き要素は名前で更新し、存在しなければ追加する。頂点はIndexで更新し、末尾Indexなら追加する。
+// 反映に失敗した場合、モデルは変更しない。
+func ApplyPmxCsvRecords(modelData *model.PmxModel, records [][]string) (PmxCsvApplyResult, error) {
+	if modelData == nil {
+		return PmxCsvApplyResult{}, io_common.NewIoParseFailed("PMX CSV反映先のモデルがnilです", nil)
+	}
+	sections, err := parsePmxCsvSections(records)
+	if err != nil {
+		return PmxCsvApplyResult{}, err
+	}
+	work, err := modelData.Copy()
+	if err != nil {
+		return PmxCsvApplyResult{}, io_common.NewIoParseFailed("PMX CSV反映用のモデル複製に失敗しました", err)
+	}
+	result, err := applyPmxCsvSections(&work, sections)
+	if err != nil {
+		return PmxCsvApplyResult{}, err
+	}
+	*modelData = work
+	return result, nil
+}
+
+// applyPmxCsvSections は解析済み区分を依存順にモデルへ反映する。
+func applyPmxCsvSections(modelData *model.PmxModel, sections *pmxCsvSections) (PmxCsvApplyResult, error) {
+	a := &pmxCsvApplier{
+		model: modelData,
+		result: PmxCsvApplyResult{
+			Updated: map[string]int{},
+			Added:   map[string]int{},
+		},
+	}
+	a.applyModelInfos(sections.modelInfos)
+	a.applyMaterials(sections.materials)
+	steps := []func() error{
+		func() error { return a.applyBones(sections.bones) },
+		func() error { return a.applyMorphs(sections.morphs) },
+		func() error { return a.applyNodes(sections.nodes) },
+		func() error { return a.applyBodies(sections.bodies) },
+		func() error { return a.applyJoints(sections.joints) },
+		func() error { return a.applyVertices(sections.vertices) },
+		func() error { return a.applyFaces(sections.faces) },
+		func() error { return a.applyIkLinks(sections.ikLinks) },
+		func() error { return a.applyVertexMorphs(sections.vertexMorphs) },
+		func() error { return a.applyUvMorphs(sections.uvMorphs) },
+		func() error { return a.applyBoneMorphs(sections.boneMorphs) },
+		func() error { return a.applyMaterialMorphs(sections.materialMorphs) },
+		func() error { return a.applyGroupMorphs(sections.groupMorphs) },
+		func() error { return a.applyNodeItems(sections.nodeItems) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return PmxCsvApplyResult{}, err
+		}
+	}
+	return a.result, nil
+}
+
+// parsePmxCsvSections はCSVレコードを行種別ごとに解析する。未対応の行種別は読み飛ばす。
+func parsePmxCsvSections(records [][]string) (*pmxCsvSections, error) {
+	lines := map[string][]csvLine{}
+	for i, record := range records {
+		if len(record) == 0 {
+			continue
+		}
+		kind := strings.TrimSpace(record[0])
+		if kind == "" || strings.HasPrefix(kind, ";") {
+			continue
+		}
+		lines[kind] = append(lines[kind], csvLine{number: i + 1, cells: record})
+	}
+
+	sections := &pmxCsvSections{}
+	var err error
+	if sections.modelInfos, err = decodeRows[modelInfoRow](lines[sectionModelInfo]); err != nil {
+		return nil, err
+	}
+	if sections.vertices, err = decodeRows[vertexRow](lines[sectionVertex]); err != nil {
+		return nil, err
+	}
+	if sections.faces, err = decodeRows[faceRow](lines[sectionFace]); err != nil {
+		return nil, err
+	}
+	if sections.materials, err = decodeRows[materialRow](lines[sectionMaterial]); err != nil {
+		return nil, err
+	}
+	if sections.bones, err = decodeRows[boneRow](lines[sectionBone]); err != nil {
+		return nil, err
+	}
+	if sections.ikLinks, err = decodeRows[ikLinkRow](lines[sectionIkLink]); err != nil {
+		return nil, err
+	}
+	if sections.morphs, err = decodeRows[morphRow](lines[sectionMorph]); err != nil {
+		return nil, err
+	}
+	if sections.vertexMorphs, err = decodeRows[vertexMorphRow](lines[sectionVertexMorph]); err != nil {
+		return nil, err
+	}
+	if sections.uvMorphs, err = decodeRows[uvMorphRow](lines[sectionUvMorph]); err != nil {
+		return nil, err
+	}
+	if sections.boneMorphs, err = decodeRows[boneMorphRow](lines[sectionBoneMorph]); err != nil {
+		return nil, err
+	}
+	if sections.materialMorphs, err = decodeRows[materialMorphRow](lines[sectionMaterialMorph]); err != nil {
+		return nil, err
+	}
+	if sections.groupMorphs, err = decodeRows[groupMorphRow](lines[sectionGroupMorph]); err != nil {
+		return nil, err
+	}
+	if sections.nodes, err = decodeRows[nodeRow](lines[sectionNode]); err != nil {
+		return nil, err
+	}
+	if sections.nodeItems, err = decodeRows[nodeItemRow](lines[sectionNodeItem]); err != nil {
+		return nil, err
+	}
+	if sections.bodies, err = decodeRows[bodyRow](lines[sectionBody]); err != nil {
+		return nil, err
+	}
+	if sections.joints, err = decodeRows[jointRow](lines[sectionJoint]); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// decodeRows はCSV行をcsvタグ順で構造体へ変換する。
+func decodeRows[T any](lines []csvLine) ([]numberedRow[T], error) {
+	rows := make([]numberedRow[T], 0, len(lines))
+	options := io_csv.CsvUnmarshalOptions{ColumnMapping: io_csv.CsvColumnMappingOrder}
+	for _, line := range lines {
+		header := make([]string, len(line.cells))
+		var decoded []T
+		if err := io_csv.UnmarshalWithOptions(io_csv.NewCsvModel([][]string{header, line.cells}), &decoded, options); err != nil {
+			return nil, io_common.NewIoParseFailed("PMX CSVの行解析に失敗しました(行:%d)", err, line.number)
+		}
+		for _, row := range decoded {
+			rows = append(rows, numberedRow[T]{number: line.number, row: row})
+		}
+	}
+	return rows, nil
+}
+
+// applyModelInfos はモデル情報を反映する。
+func (a *pmxCsvApplier) applyModelInfos(rows []numberedRow[modelInfoRow]) {
+	for _, r := range rows {
+		a.model.SetName(r.row.Name)
+		a.model.EnglishName = r.row.EnglishName
+		a.model.Comment = r.row.Comment
+		a.model.EnglishComment = r.row.EnglishComment
+		a.result.Updated[sectionModelInfo]++
+	}
+}
+
+// applyMaterials は材質を名前で更新または追加する。
+func (a *pmxCsvApplier) applyMaterials(rows []numberedRow[materialRow]) {
+	for _, r := range rows {
+		row := r.row
+		material, err := a.model.Materials.GetByName(row.Name)
+		if err != nil || material == nil {
+			material = model.NewMaterial()
+			material.SetName(row.Name)
+			a.model.Materials.AppendRaw(material)
+			a.result.Added[sectionMaterial]++
+		} else {
+			a.result.Updated[sectionMaterial]++
+		}
+		material.EnglishName = row.EnglishName
+		material.Memo = row.Memo
+		material.Diffuse = mmath.Vec4{X: row.DiffuseR, Y: row.DiffuseG, Z: row.DiffuseB, W: row.DiffuseA}
+		material.Specular = mmath.Vec4{X: row.SpecularR, Y: row.SpecularG, Z: row.SpecularB, W: row.SpecularPower}
+		material.Ambient = vec3(row.AmbientR, row.AmbientG, row.AmbientB)
+		material.Edge = mmath.Vec4{X: row.EdgeR, Y: row.EdgeG, Z: row.EdgeB, W: row.EdgeA}
+		material.EdgeSize = row.EdgeSize
+
+		drawFlag := material.DrawFlag &^ (model.DRAW_FLAG_DOUBLE_SIDED_DRAWING | model.DRAW_FLAG_GROUND_SHADOW |
+			model.DRAW_FLAG_DRAWING_ON_SELF_SHADOW_MAPS | model.DRAW_FLAG_DRAWING_SELF_SHADOWS | model.DRAW_FLAG_DRAWING_EDGE)
+		drawFlag |= drawFlagIf(row.DoubleSided, model.DRAW_FLAG_DOUBLE_SIDED_DRAWING)
+		drawFlag |= drawFlagIf(row.GroundShadow, model.DRAW_FLAG_GROUND_SHADOW)
+		drawFlag |= drawFlagIf(row.SelfShadowMap, model.DRAW_FLAG_DRAWING_ON_SELF_SHADOW_MAPS)
+		drawFlag |= drawFlagIf(row.SelfShadow, model.DRAW_FLAG_DRAWING_SELF_SHADOWS)
+		drawFlag |= drawFlagIf(row.Edge, model.DRAW_FLAG_DRAWING_EDGE)
+		material.DrawFlag = drawFlag
+
+		material.TextureIndex = a.textureIndex(row.TexturePath)
+		material.SphereTextureIndex = a.textureIndex(row.SpherePath)
+		material.SphereMode = model.SphereMode(row.SphereMode)
+		if match := sharedToonPattern.FindStringSubmatch(strings.TrimSpace(row.ToonPath)); match != nil {
+			number, _ := strconv.Atoi(match[1])
+			material.ToonSharingFlag = model.TOON_SHARING_SHARING
+			material.ToonTextureIndex = number - 1
+		} else {
+			material.ToonSharingFlag = model.TOON_SHARING_INDIVIDUAL
+			material.ToonTextureIndex = a.textureIndex(row.ToonPath)
+		}
+	}
+}
+
+// applyBones はボーンを名前で更新または追加する。参照先は全ボーン登録後に解決する。
+func (a *pmxCsvApplier) applyBones(rows []numberedRow[boneRow]) error {
+	bones := make([]*model.Bone, len(rows))
+	for i, r := range rows {
+		if r.row.Name == "" {
+			return io_common.NewIoParseFailed("PMX CSVのボーン名が空です(行:%d)", nil, r.number)
+		}
+		bone, err := a.model.Bones.GetByName(r.row.Name)
+		if err != nil || bone == nil {
+			bone = model.NewBoneByName(r.row.Name)
+			bone.ParentIndex = -1
+			bone.TailIndex = -1
+			bone.EffectIndex = -1
+			a.model.Bones.AppendRaw(bone)
+			a.result.Added[sectionBone]++
+		} else {
+			a.result.Updated[sectionBone]++
+		}
+		bones[i] = bone
+	}
+
+	for i, r := range rows {
+		row := r.row
+		bone := bones[i]
+		parentIndex, err := a.boneIndex(row.ParentName, r.number)
+		if err != nil {
+			return err
+		}
+		tailIndex, err := a.boneIndex(row.TailName, r.number)
+		if err != nil {
+			return err
+		}
+		effectIndex, err := a.boneIndex(row.EffectName, r.number)
+		if err != nil {
+			return err
+		}
+		bone.EnglishName = row.EnglishName
+		bone.Layer = row.Layer
+		bone.Position = vec3(row.PositionX, row.PositionY, row.PositionZ)
+		bone.ParentIndex = parentIndex
+		bone.TailIndex = tailIndex
+		bone.TailPosition = vec3(row.TailX, row.TailY, row.TailZ)
+		bone.EffectIndex = effectIndex
+		bone.EffectFactor = row.EffectFactor
+		bone.FixedAxis = vec3(row.FixedAxisX, row.FixedAxisY, row.FixedAxisZ)
+		bone.LocalAxisX = vec3(row.LocalAxisXX, row.LocalAxisXY, row.LocalAxisXZ)
+		bone.LocalAxisZ = vec3(row.LocalAxisZX, row.LocalAxisZY, row.LocalAxisZZ)
+		bone.EffectorKey = row.EffectorKey
+
+		var flag model.BoneFlag
+		flag |= boneFlagIf(row.TailIsBone, model.BONE_FLAG_TAIL_IS_BONE)
+		flag |= boneFlagIf(row.CanRotate, model.BONE_FLAG_CAN_ROTATE)
+		flag |= boneFlagIf(row.CanTranslate, model.BONE_FLAG_CAN_TRANSLATE)
+		flag |= boneFlagIf(row.Visible, model.BONE_FLAG_IS_VISIBLE)
+		flag |= boneFlagIf(row.CanManipulate, model.BONE_FLAG_CAN_MANIPULATE)
+		flag |= boneFlagIf(row.IsIk, model.BONE_FLAG_IS_IK)
+		flag |= boneFlagIf(row.ExternalLocal, model.BONE_FLAG_IS_EXTERNAL_LOCAL)
+		flag |= boneFlagIf(row.ExternalRotation, model.BONE_FLAG_IS_EXTERNAL_ROTATION)
+		flag |= boneFlagIf(row.ExternalTranslation, model.BONE_FLAG_IS_EXTERNAL_TRANSLATION)
+		flag |= boneFlagIf(row.FixedAxis, model.BONE_FLAG_HAS_FIXED_AXIS)
+		flag |= boneFlagIf(row.LocalAxis, model.BONE_FLAG_HAS_LOCAL_AXIS)
+		flag |= boneFlagIf(row.AfterPhysics, model.BONE_FLAG_IS_AFTER_PHYSICS_DEFORM)
+		flag |= boneFlagIf(row.ExternalParent, model.BONE_FLAG_IS_EXTERNAL_PARENT_DEFORM)
+		bone.BoneFlag = flag
+
+		if row.IsIk == 0 {
+			bone.Ik = nil
+			continue
+		}
+		targetIndex, err := a.boneIndex(row.IkTargetName, r.number)
+		if err != nil {
+			return err
+		}
+		if bone.Ik == nil {
+			bone.Ik = &model.Ik{Links: []model.IkLink{}}
+		}
+		bone.Ik.BoneIndex = targetIndex
+		bone.Ik.LoopCount = row.IkLoopCount
+		bone.Ik.UnitRotation = vec3(mmath.DegToRad(row.IkUnitAngle), 0, 0)
+	}
+	return nil
+}
+
+// applyMorphs はモーフを名前で更新または追加する。種類が変わった場合はオフセットを破棄する。
+func (a *pmxCsvApplier) applyMorphs(rows []numberedRow[morphRow]) error {
+	for _, r := range rows {
+		row := r.row
+		if row.Name == "" {
+			return io_common.NewIoParseFailed("PMX CSVのモーフ名が空です(行:%d)", nil, r.number)
+		}
+		morphType := model.MorphType(row.MorphType)
+		morph, err := a.model.Morphs.GetByName(row.Name)
+		if err != nil || morph == nil {
+			morph = &model.Morph{MorphType: morphType, Offsets: []model.IMorphOffset{}}
+			morph.SetName(row.Name)
+			a.model.Morphs.AppendRaw(morph)
+			a.result.Added[sectionMorph]++
+		} else {
+			a.result.Updated[sectionMorph]++
+		}
+		if morph.MorphType != morphType {
+			morph.Offsets = []model.IMorphOffset{}
+		}
+		morph.EnglishName = row.EnglishName
+		morph.Panel = model.MorphPanel(row.Panel)
+		morph.MorphType = morphType
+	}
+	return nil
+}
+
+// applyNodes は表示枠を名前で更新または追加する。
+func (a *pmxCsvApplier) applyNodes(rows []numberedRow[nodeRow]) error {
+	for _, r := range rows {
+		if r.row.Name == "" {
+			return io_common.NewIoParseFailed("PMX CSVの表示枠名が空です(行:%d)", nil, r.number)
+		}
+		slot, err := a.model.DisplaySlots.GetByName(r.row.Name)
+		if err != nil || slot == nil {
+			slot = &model.DisplaySlot{References: []model.Reference{}}
+			slot.SetName(r.row.Name)
+			index := a.model.DisplaySlots.AppendRaw(slot)
+			if index < 2 {
+				// 先頭2枠(Root/表情)は特殊枠として扱う。
+				slot.SpecialFlag = model.SPECIAL_FLAG_ON
+			}
+			a.result.Added[sectionNode]++
+		} else {
+			a.result.Updated[sectionNode]++
+		}
+		slot.EnglishName = r.row.EnglishName
+	}
+	return nil
+}
+
+// applyBodies は剛体を名前で更新または追加する。
+func (a *pmxCsvApplier) applyBodies(rows []numberedRow[bodyRow]) error {
+	for _, r := range rows {
+		row := r.row
+		if row.Name == "" {
+			return io_common.NewIoParseFailed("PMX CSVの剛体名が空です(行:%d)", nil, r.number)
+		}
+		boneIndex, err := a.boneIndex(row.BoneName, r.number)
+		if err != nil {
+			return err
+		}
+		if row.Group < 1 || row.Group > 16 {
+			return io_common.NewIoParseFailed("PMX CSVの剛体グループが範囲外です(行:%d 値:%d)", nil, r.number, row.Group)
+		}
+		mask, err := collisionMask(row.NoCollision)
+		if err != nil {
+			return io_common.NewIoParseFailed("PMX CSVの非衝突グループが不正です(行:%d)", err, r.number)
+		}
+		rigidBody, err := a.model.RigidBodies.GetByName(row.Name)
+		if err != nil || rigidBody == nil {
+			rigidBody = &model.RigidBody{}
+			rigidBody.SetName(row.Name)
+			a.model.RigidBodies.AppendRaw(rigidBody)
+			a.result.Added[sectionBody]++
+		} else {
+			a.result.Updated[sectionBody]++
+		}
+		rigidBody.EnglishName = row.EnglishName
+		rigidBody.BoneIndex = boneIndex
+		rigidBody.PhysicsType = model.PhysicsType(row.PhysicsType)
+		rigidBody.CollisionGroup = model.CollisionGroup{Group: byte(row.Group - 1), Mask: mask}
+		rigidBody.Shape = model.Shape(row.Shape)
+		rigidBody.Size = vec3(row.SizeX, row.SizeY, row.SizeZ)
+		rigidBody.Position = vec3(row.PositionX, row.PositionY, row.PositionZ)
+		rigidBody.Rotation = vec3(row.RotationX, row.RotationY, row.RotationZ).DegToRad()
+		rigidBody.Param = model.RigidBodyParam{
+			Mass:           row.Mass,
+			LinearDamping:  row.LinearDamping,
+			AngularDamping: row.AngularDamping,
+			Restitution:    row.Restitution,
+			Friction:       row.Friction,
+		}
+	}
+	return nil
+}
+
+// applyJoints はジョイントを名前で更新または追加する。
+func (a *pmxCsvApplier) applyJoints(rows []numberedRow[jointRow]) error {
+	for _, r := range rows {
+		row := r.row
+		if row.Name == "" {
+			return io_common.NewIoParseFailed("PMX CSVのジョイント名が空です(行:%d)", nil, r.number)
+		}
+		indexA, err := a.rigidBodyIndex(row.RigidBodyNameA, r.number)
+		if err != nil {
+			return err
+		}
+		indexB, err := a.rigidBodyIndex(row.RigidBodyNameB, r.number)
+		if err != nil {
+			return err
+		}
+		joint, err := a.model.Joints.GetByName(row.Name)
+		if err != nil || joint == nil {
+			joint = &model.Joint{}
+			joint.SetName(row.Name)
+			a.model.Joints.AppendRaw(joint)
+			a.result.Added[sectionJoint]++
+		} else {
+			a.result.Updated[sectionJoint]++
+		}
+		joint.EnglishName = row.EnglishName
+		joint.RigidBodyIndexA = indexA
+		joint.RigidBodyIndexB = indexB
+		joint.Param = model.JointParam{
+			Position:                  vec3(row.PositionX, row.PositionY, row.PositionZ),
+			Rotation:                  vec3(row.RotationX, row.RotationY, row.RotationZ).DegToRad(),
+			TranslationLimitMin:       vec3(row.TranslationMinX, row.TranslationMinY, row.TranslationMinZ),
+			TranslationLimitMax:       vec3(row.TranslationMaxX, row.TranslationMaxY, row.TranslationMaxZ),
+			RotationLimitMin:          vec3(row.RotationMinX, row.RotationMinY, row.RotationMinZ).DegToRad(),
+			RotationLimitMax:          vec3(row.RotationMaxX, row.RotationMaxY, row.RotationMaxZ).DegToRad(),
+			SpringConstantTranslation: vec3(row.SpringTranslationX, row.SpringTranslationY, row.SpringTranslationZ),
+			SpringConstantRotation:    vec3(row.SpringRotationX, row.SpringRotationY, row.SpringRotationZ),
+		}
+	}
+	return nil
+}
+
+// applyVertices は頂点をIndexで更新し、末尾Indexの場合は追加する。
+func (a *pmxCsvApplier) applyVertices(rows []numberedRow[vertexRow]) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	extendedUvCount := a.extendedUvCount(rows)
+	for _, r := range rows {
+		row := r.row
+		count := a.model.Vertices.Len()
+		if row.Index < 0 || row.Index > count {
+			return io_common.NewIoParseFailed("PMX CSVの頂点Indexが範囲外です(行:%d 値:%d)", nil, r.number, row.Index)
+		}
+		deform, err := a.deform(row, r.number)
+		if err != nil {
+			return err
+		}
+		var vertex *model.Vertex
+		if row.Index == count {
+			vertex = &model.Vertex{}
+			a.model.Vertices.AppendRaw(vertex)
+			a.result.Added[sectionVertex]++
+		} else {
+			vertex, _ = a.model.Vertices.Get(row.Index)
+			a.result.Updated[sectionVertex]++
+		}
+		vertex.Position = vec3(row.PositionX, row.PositionY, row.PositionZ)
+		vertex.Normal = vec3(row.NormalX, row.NormalY, row.NormalZ)
+		vertex.Uv = mmath.Vec2{X: row.U, Y: row.V}
+		extendedUvs := []mmath.Vec4{
+			{X: row.Ex1X, Y: row.Ex1Y, Z: row.Ex1Z, W: row.Ex1W},
+			{X: row.Ex2X, Y: row.Ex2Y, Z: row.Ex2Z, W: row.Ex2W},
+			{X: row.Ex3X, Y: row.Ex3Y, Z: row.Ex3Z, W: row.Ex3W},
+			{X: row.Ex4X, Y: row.Ex4Y, Z: row.Ex4Z, W: row.Ex4W},
+		}
+		vertex.ExtendedUvs = extendedUvs[:extendedUvCount]
+		vertex.EdgeFactor = row.EdgeFactor
+		vertex.DeformType = deform.DeformType()
+		vertex.Deform = deform
+	}
+	return nil
+}
+
+// extendedUvCount は追加UV数を既存頂点から決定し、空モデルの場合はCSVの値から推定する。
+func (a *pmxCsvApplier) extendedUvCount(rows []numberedRow[vertexRow]) int {
+	if a.model.Vertices.Len() > 0 {
+		vertex, err := a.model.Vertices.Get(0)
+		if err == nil && vertex != nil {
+			return min(len(vertex.ExtendedUvs), 4)
+		}
+	}
+	count := 0
+	for _, r := range rows {
+		row := r.row
+		values := [4]mmath.Vec4{
+			{X: row.Ex1X, Y: row.Ex1Y, Z: row.Ex1Z, W: row.Ex1W},
+			{X: row.Ex2X, Y: row.Ex2Y, Z: row.Ex2Z, W: row.Ex2W},
+			{X: row.Ex3X, Y: row.Ex3Y, Z: row.Ex3Z, W: row.Ex3W},
+			{X: row.Ex4X, Y: row.Ex4Y, Z: row.Ex4Z, W: row.Ex4W},
+		}
+		for i := len(values) - 1; i >= count; i-- {
+			if values[i] != (mmath.Vec4{}) {
+				count = i + 1
+				break
+			}
+		}
+	}
+	return count
+}
+
+// deform は頂点行のウェイト情報からデフォームを生成する。
+func (a *pmxCsvApplier) deform(row vertexRow, number int) (model.IDeform, error) {
+	names := [4]string{row.Bone1, row.Bone2, row.Bone3, row.Bone4}
+	indexes := [4]int{}
+	for i, name := range names {
+		index, err := a.boneIndex(name, number)
+		if err != nil {
+			return nil, err
+		}
+		indexes[i] = index
+	}
+	switch model.DeformType(row.DeformType) {
+	case model.BDEF1:
+		return model.NewBdef1(indexes[0]), nil
+	case model.BDEF2:
+		return model.NewBdef2(indexes[0], indexes[1], row.Weight1), nil
+	case model.BDEF4:
+		return model.NewBdef4(indexes, [4]float64{row.Weight1, row.Weight2, row.Weight3, row.Weight4}), nil
+	case model.SDEF:
+		sdef := model.NewSdef(indexes[0], indexes[1], row.Weight1)
+		sdef.SdefC = vec3(row.SdefCX, row.SdefCY, row.SdefCZ)
+		sdef.SdefR0 = vec3(row.SdefR0X, row.SdefR0Y, row.SdefR0Z)
+		sdef.SdefR1 = vec3(row.SdefR1X, row.SdefR1Y, row.SdefR1Z)
+		return sdef, nil
+	default:
+		return nil, io_common.NewIoParseFailed("PMX CSVのウェイト変形タイプが不正です(行:%d 値:%d)", nil, number, row.DeformType)
+	}
+}
+
+// applyFaces は記載された材質の面を置き換え、材質順に面を再構築する。
+func (a *pmxCsvApplier) applyFaces(rows []numberedRow[faceRow]) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	replaced := map[int][]numberedRow[faceRow]{}
+	for _, r := range rows {
+		material, err := a.model.Materials.GetByName(r.row.MaterialName)
+		if err != nil || material == nil {
+			return newNameNotFound("材質", r.row.MaterialName, r.number)
+		}
+		for _, vertexIndex := range []int{r.row.Vertex1, r.row.Vertex2, r.row.Vertex3} {
+			if vertexIndex < 0 || vertexIndex >= a.model.Vertices.Len() {
+				return io_common.NewIoParseFailed("PMX CSVの面の頂点Indexが範囲外です(行:%d 値:%d)", nil, r.number, vertexIndex)
+			}
+		}
+		replaced[material.Index()] = append(replaced[material.Index()], r)
+	}
+
+	faces := a.model.Faces.Values()
+	rebuilt := collection.NewIndexedCollection[*model.Face](len(faces))
+	start := 0
+	for _, material := range a.model.Materials.Values() {
+		count := material.VerticesCount / 3
+		end := min(start+count, len(faces))
+		if materialRows, ok := replaced[material.Index()]; ok {
+			sort.SliceStable(materialRows, func(i, j int) bool { return materialRows[i].row.Index < materialRows[j].row.Index })
+			for _, r := range materialRows {
+				rebuilt.AppendRaw(&model.Face{VertexIndexes: [3]int{r.row.Vertex1, r.row.Vertex2, r.row.Vertex3}})
+			}
+			material.VerticesCount = len(materialRows) * 3
+			a.result.Updated[sectionFace]++
+		} else {
+			for _, face := range faces[min(start, len(faces)):end] {
+				rebuilt.AppendRaw(face)
+			}
+		}
+		start += count
+	}
+	// 材質に割り当てられていない末尾の面は維持する。
+	for _, face := range faces[min(start, len(faces)):] {
+		rebuilt.AppendRaw(face)
+	}
+	a.model.Faces = rebuilt
+	return nil
+}
+
+// applyIkLinks は記載されたIKボーンのリンクを置き換える。ローカル軸制限は同じリンクボーンの既存値を維持する。
+func (a *pmxCsvApplier) applyIkLinks(rows []numberedRow[ikLinkRow]) error {
+	order, grouped := groupRows(rows, func(row ikLinkRow) string { return row.BoneName })
+	for _, name := range order {
+		group := grouped[name]
+		bone, err := a.model.Bones.GetByName(name)
+		if err != nil || bone == nil {
+			return newNameNotFound("ボーン", name, group[0].number)
+		}
+		if bone.Ik == nil {
+			return io_common.NewIoParseFailed("PMX CSVのIKリンク親がIKボーンではありません(行:%d 名前:%s)", nil, group[0].number, name)
+		}
+		links := make([]model.IkLink, 0, len(group))
+		for _, r := range group {
+			linkIndex, err := a.boneIndex(r.row.LinkName, r.number)
+			if err != nil {
+				return err
+			}
+			link := model.IkLink{
+				BoneIndex:     linkIndex,
+				AngleLimit:    r.row.AngleLimit != 0,
+				MinAngleLimit: vec3(r.row.MinX, r.row.MinY, r.row.MinZ).DegToRad(),
+				MaxAngleLimit: vec3(r.row.MaxX, r.row.MaxY, r.row.MaxZ).DegToRad(),
+			}
+			for _, current := range bone.Ik.Links {
+				if current.BoneIndex == linkIndex {
+					link.LocalAngleLimit = current.LocalAngleLimit
+					link.LocalMinAngleLimit = current.LocalMinAngleLimit
+					link.LocalMaxAngleLimit = current.LocalMaxAngleLimit
+					break
+				}
+			}
+			links = append(links, link)
+		}
+		bone.Ik.Links = links
+		a.result.Updated[sectionIkLink]++
+	}
+	return nil
+}
+
+// applyVertexMorphs は頂点モーフのオフセットを置き換える。
+func (a *pmxCsvApplier) applyVertexMorphs(rows []numberedRow[vertexMorphRow]) error {
+	order, grouped := groupRows(rows, func(row vertexMorphRow) string { return row.MorphName })
+	for _, name := range order {
+		group := grouped[name]
+		morph, err := a.offsetMorph(name, group[0].number, model.MORPH_TYPE_VERTEX, model.MORPH_TYPE_AFTER_VERTEX)
+		if err != nil {
+			return err
+		}
+		offsets := make([]model.IMorphOffset, 0, len(group))
+		for _, r := range group {
+			if err := a.checkVertexIndex(r.row.VertexIndex, r.number); err != nil {
+				return err
+			}
+			offsets = append(offsets, &model.VertexMorphOffset{
+				VertexIndex: r.row.VertexIndex,
+				Position:    vec3(r.row.X, r.row.Y, r.row.Z),
+			})
+		}
+		morph.Offsets = offsets
+		a.result.Updated[sectionVertexMorph]++
+	}
+	return nil
+}
+
+// applyUvMorphs はUVモーフのオフセットを置き換える。
+func (a *pmxCsvApplier) applyUvMorphs(rows []numberedRow[uvMorphRow]) error {
+	order, grouped := groupRows(rows, func(row uvMorphRow) string { return row.MorphName })
+	for _, name := range order {
+		group := grouped[name]
+		morph, err := a.offsetMorph(name, group[0].number, model.MORPH_TYPE_UV, model.MORPH_TYPE_EXTENDED_UV1,
+			model.MORPH_TYPE_EXTENDED_UV2, model.MORPH_TYPE_EXTENDED_UV3, model.MORPH_TYPE_EXTENDED_UV4)
+		if err != nil {
+			return err
+		}
+		offsets := make([]model.IMorphOffset, 0, len(group))
+		for _, r := range group {
+			if err := a.checkVertexIndex(r.row.VertexIndex, r.number); err != nil {
+				return err
+			}
+			offsets = append(offsets, &model.UvMorphOffset{
+				VertexIndex: r.row.VertexIndex,
+				Uv:          mmath.Vec4{X: r.row.X, Y: r.row.Y, Z: r.row.Z, W: r.row.W},
+				UvType:      morph.MorphType,
+			})
+		}
+		morph.Offsets = offsets
+		a.result.Updated[sectionUvMorph]++
+	}
+	return nil
+}
+
+// applyBoneMorphs はボーンモーフのオフセットを置き換える。
+func (a *pmxCsvApplier) applyBoneMorphs(rows []numberedRow[boneMorphRow]) error {
+	order, grouped := groupRows(rows, func(row boneMorphRow) string { return row.MorphName })
+	for _, name := range order {
+		group := grouped[name]
+		morph, err := a.offsetMorph(name, group[0].number, model.MORPH_TYPE_BONE)
+		if err != nil {
+			return err
+		}
+		offsets := make([]model.IMorphOffset, 0, len(group))
+		for _, r := range group {
+			boneIndex, err := a.requiredBoneIndex(r.row.BoneName, r.number)
+			if err != nil {
+				return err
+			}
+			offsets = append(offsets, &model.BoneMorphOffset{
+				BoneIndex: boneIndex,
+				Position:  vec3(r.row.X, r.row.Y, r.row.Z),
+				Rotation:  mmath.NewQuaternionFromDegrees(r.row.RotationX, r.row.RotationY, r.row.RotationZ),
+			})
+		}
+		morph.Offsets = offsets
+		a.result.Updated[sectionBoneMorph]++
+	}
+	return nil
+}
+
+// applyMaterialMorphs は材質モーフのオフセットを置き換える。材質名が空の場合は全材質対象とする。
+func (a *pmxCsvApplier) applyMaterialMorphs(rows []numberedRow[materialMorphRow]) error {
+	order, grouped := groupRows(rows, func(row materialMorphRow) string { return row.MorphName })
+	for _, name := range order {
+		group := grouped[name]
+		morph, err := a.offsetMorph(name, group[0].number, model.MORPH_TYPE_MATERIAL)
+		if err != nil {
+			return err
+		}
+		offsets := make([]model.IMorphOffset, 0, len(group))
+		for _, r := range group {
+			row := r.row
+			materialIndex := -1
+			if row.MaterialName != "" {
+				material, err := a.model.Materials.GetByName(row.MaterialName)
+				if err != nil || material == nil {
+					return newNameNotFound("材質", row.MaterialName, r.number)
+				}
+				materialIndex = material.Index()
+			}
+			offsets = append(offsets, &model.MaterialMorphOffset{
+				MaterialIndex:       materialIndex,
+				CalcMode:            model.MaterialMorphCalcMode(row.CalcMode),
+				Diffuse:             mmath.Vec4{X: row.DiffuseR, Y: row.DiffuseG, Z: row.DiffuseB, W: row.DiffuseA},
+				Specular:            mmath.Vec4{X: row.SpecularR, Y: row.SpecularG, Z: row.SpecularB, W: row.SpecularPower},
+				Ambient:             vec3(row.AmbientR, row.AmbientG, row.AmbientB),
+				Edge:                mmath.Vec4{X: row.EdgeR, Y: row.EdgeG, Z: row.EdgeB, W: row.EdgeA},
+				EdgeSize:            row.EdgeSize,
+				TextureFactor:       mmath.Vec4{X: row.TextureR, Y: row.TextureG, Z: row.TextureB, W: row.TextureA},
+				SphereTextureFactor: mmath.Vec4{X: row.SphereR, Y: row.SphereG, Z: row.SphereB, W: row.SphereA},
+				ToonTextureFactor:   mmath.Vec4{X: row.ToonR, Y: row.ToonG, Z: row.ToonB, W: row.ToonA},
+			})
+		}
+		morph.Offsets = offsets
+		a.result.Updated[sectionMaterialMorph]++
+	}
+	return nil
+}
+
+// applyGroupMorphs はグループモーフのオフセットを置き換える。
+func (a *pmxCsvApplier) applyGroupMorphs(rows []numberedRow[groupMorphRow]) error {
+	order, grouped := groupRows(rows, func(row groupMorphRow) string { return row.MorphName })
+	for _, name := range order {
+		group := grouped[name]
+		morph, err := a.offsetMorph(name, group[0].number, model.MORPH_TYPE_GROUP)
+		if err != nil {
+			return err
+		}
+		offsets := make([]model.IMorphOffset, 0, len(group))
+		for _, r := range group {
+			target, err := a.model.Morphs.GetByName(r.row.Target)
+			if err != nil || target == nil {
+				return newNameNotFound("モーフ", r.row.Target, r.number)
+			}
+			offsets = append(offsets, &model.GroupMorphOffset{MorphIndex: target.Index(), MorphFactor: r.row.Factor})
+		}
+		morph.Offsets = offsets
+		a.result.Updated[sectionGroupMorph]++
+	}
+	return nil
+}
+
+// applyNodeItems は記載された表示枠の要素を置き換える。
+func (a *pmxCsvApplier) applyNodeItems(rows []numberedRow[nodeItemRow]) error {
+	order, grouped := groupRows(rows, func(row nodeItemRow) string { return row.NodeName })
+	for _, name := range order {
+		group := grouped[name]
+		slot, err := a.model.DisplaySlots.GetByName(name)
+		if err != nil || slot == nil {
+			return newNameNotFound("表示枠", name, group[0].number)
+		}
+		references := make([]model.Reference, 0, len(group))
+		for _, r := range group {
+			switch model.DisplayType(r.row.ItemType) {
+			case model.DISPLAY_TYPE_BONE:
+				boneIndex, err := a.requiredBoneIndex(r.row.ItemName, r.number)
+				if err != nil {
+					return err
+				}
+				references = append(references, model.Reference{DisplayType: model.DISPLAY_TYPE_BONE, DisplayIndex: boneIndex})
+			case model.DISPLAY_TYPE_MORPH:
+				morph, err := a.model.Morphs.GetByName(r.row.ItemName)
+				if err != nil || morph == nil {
+					return newNameNotFound("モーフ", r.row.ItemName, r.number)
+				}
+				references = append(references, model.Reference{DisplayType: model.DISPLAY_TYPE_MORPH, DisplayIndex: morph.Index()})
+			default:
+				return io_common.NewIoParseFailed("PMX CSVの表示枠要素タイプが不正です(行:%d 値:%d)", nil, r.number, r.row.ItemType)
+			}
+		}
+		slot.References = references
+		a.result.Updated[sectionNodeItem]++
+	}
+	return nil
+}
+
+// offsetMorph はオフセット反映先のモーフを取得し、種類を検証する。
+func (a *pmxCsvApplier) offsetMorph(name string, number int, allowed ...model.MorphType) (*model.Morph, error) {
+	morph, err := a.model.Morphs.GetByName(name)
+	if err != nil || morph == nil {
+		return nil, newNameNotFound("モーフ", name, number)
+	}
+	for _, morphType := range allowed {
+		if morph.MorphType == morphType {
+			return morph, nil
+		}
+	}
+	return nil, io_common.NewIoParseFailed("PMX CSVのオフセットとモーフ種類が一致しません(行:%d 名前:%s)", nil, number, name)
+}
+
+// checkVertexIndex は頂点Indexが範囲内か検証する。
+func (a *pmxCsvApplier) checkVertexIndex(index int, number int) error {
+	if index < 0 || index >= a.model.Vertices.Len() {
+		return io_common.NewIoParseFailed("PMX CSVの頂点Indexが範囲外です(行:%d 値:%d)", nil, number, index)
+	}
+	return nil
+}
+
+// boneIndex はボーン名からindexを解決する。空文字は -1 とする。
+func (a *pmxCsvApplier) boneIndex(name string, number int) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+	return a.requiredBoneIndex(name, number)
+}
+
+// requiredBoneIndex はボーン名からindexを解決する。
+func (a *pmxCsvApplier) requiredBoneIndex(name string, number int) (int, error) {
+	bone, err := a.model.Bones.GetByName(name)
+	if err != nil || bone == nil {
+		return -1, newNameNotFound("ボーン", name, number)
+	}
+	return bone.Index(), nil
+}
+
+// rigidBodyIndex は剛体名からindexを解決する。空文字は -1 とする。
+func (a *pmxCsvApplier) rigidBodyIndex(name string, number int) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+	rigidBody, err := a.model.RigidBodies.GetByName(name)
+	if err != nil || rigidBody == nil {
+		return -1, newNameNotFound("剛体", name, number)
+	}
+	return rigidBody.Index(), nil
+}
+
+// textureIndex はテクスチャパスからindexを解決する。未登録の場合は追加する。
+func (a *pmxCsvApplier) textureIndex(path string) int {
+	if path == "" {
+		return -1
+	}
+	if texture, err := a.model.Textures.GetByName(path); err == nil && texture != nil {
+		return texture.Index()
+	}
+	texture := model.NewTexture()
+	texture.SetName(path)
+	texture.SetValid(true)
+	return a.model.Textures.AppendRaw(texture)
+}
+
+// groupRows は行をキーごとに出現順でまとめる。
+func groupRows[T any](rows []numberedRow[T], keyOf func(T) string) ([]string, map[string][]numberedRow[T]) {
+	order := make([]string, 0)
+	grouped := map[string][]numberedRow[T]{}
+	for _, r := range rows {
+		key := keyOf(r.row)
+		if _, ok := grouped[key]; !ok {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], r)
+	}
+	return order, grouped
+}
+
+// collisionMask は非衝突グループ文字列から衝突マスクを生成する。
+func collisionMask(noCollision string) (uint16, error) {
+	mask := uint16(0xffff)
+	for _, field := range strings.Fields(noCollision) {
+		group, err := strconv.Atoi(field)
+		if err != nil {
+			return 0, err
+		}
+		if group < 1 || group > 16 {
+			return 0, strconv.ErrRange
+		}
+		mask &^= 1 << uint(group-1)
+	}
+	return mask, nil
+}
+
+// newNameNotFound は名前解決失敗エラーを生成する。
+func newNameNotFound(kind string, name string, number int) error {
+	return io_common.NewIoParseFailed("PMX CSVの%sが見つかりません(行:%d 名前:%s)", nil, kind, number, name)
+}
+
+// drawFlagIf は0/1値に応じて描画フラグを返す。
+func drawFlagIf(value int, flag model.DrawFlag) model.DrawFlag {
+	if value != 0 {
+		return flag
+	}
+	return 0
+}
+
+// boneFlagIf は0/1値に応じてボーンフラグを返す。
+func boneFlagIf(value int, flag model.BoneFlag) model.BoneFlag {
+	if value != 0 {
+		return flag
+	}
+	return 0
+}
+
+// vec3 は成分からVec3を生成する。
+func vec3(x, y, z float64) mmath.Vec3 {
+	return mmath.Vec3{Vec: r3.Vec{X: x, Y: y, Z: z}}
+}
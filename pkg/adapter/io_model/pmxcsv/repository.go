@@ -0,0 +1,176 @@
+// 指示: miu200521358
+package pmxcsv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// PMX_CSV_EXT はPMX CSVの拡張子。
+const PMX_CSV_EXT = ".csv"
+
+// utf8Bom はUTF-8のBOM。
+var utf8Bom = []byte{0xEF, 0xBB, 0xBF}
+
+// PmxCsvRepository はPMXEditor互換のモデルCSV入出力を表す。
+type PmxCsvRepository struct{}
+
+// NewPmxCsvRepository はPmxCsvRepositoryを生成する。
+func NewPmxCsvRepository() *PmxCsvRepository {
+	return &PmxCsvRepository{}
+}
+
+// CanLoad は拡張子に応じて読み込み可否を判定する。
+func (r *PmxCsvRepository) CanLoad(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), PMX_CSV_EXT)
+}
+
+// InferName はパスから表示名を推定する。
+func (r *PmxCsvRepository) InferName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Load はPMX CSVから新しいモデルを読み込む。
+func (r *PmxCsvRepository) Load(path string) (hashable.IHashable, error) {
+	return r.LoadFS(nil, path)
+}
+
+// LoadFS はfs.FS上のPMX CSVから新しいモデルを読み込む。fsys が nil の場合はOSのファイルを読み込む。
+func (r *PmxCsvRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
+	records, modTime, err := r.readRecords(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := parsePmxCsvSections(records)
+	if err != nil {
+		return nil, err
+	}
+	modelData := model.NewPmxModel()
+	if _, err := applyPmxCsvSections(modelData, sections); err != nil {
+		return nil, err
+	}
+	if len(sections.modelInfos) == 0 {
+		modelData.SetName(r.InferName(path))
+	}
+	modelData.SetPath(path)
+	modelData.SetFileModTime(modTime)
+	modelData.UpdateHash()
+	return modelData, nil
+}
+
+// Apply はPMX CSVに記載された要素だけを既存モデルへ反映する。
+func (r *PmxCsvRepository) Apply(path string, modelData *model.PmxModel) (PmxCsvApplyResult, error) {
+	records, _, err := r.readRecords(nil, path)
+	if err != nil {
+		return PmxCsvApplyResult{}, err
+	}
+	result, err := ApplyPmxCsvRecords(modelData, records)
+	if err != nil {
+		return PmxCsvApplyResult{}, err
+	}
+	modelData.UpdateHash()
+	return result, nil
+}
+
+// Save はモデル全体をPMX CSVとして保存する。
+func (r *PmxCsvRepository) Save(path string, data hashable.IHashable, opts io_common.SaveOptions) error {
+	if path == "" {
+		return io_common.NewIoSaveFailed("保存先パスが空です", nil)
+	}
+	if !r.CanLoad(path) {
+		return io_common.NewIoEncodeFailed("CSV拡張子ではないため保存できません: %s", nil, filepath.Base(path))
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return io_common.NewIoSaveFailed("PMX CSVファイルの作成に失敗しました", err)
+	}
+	defer file.Close()
+
+	return r.SaveTo(file, data, opts)
+}
+
+// SaveTo はモデル全体をBOM付きUTF-8のPMX CSVとしてio.Writerへ書き込む。
+func (r *PmxCsvRepository) SaveTo(w io.Writer, data hashable.IHashable, opts io_common.SaveOptions) error {
+	_ = opts
+	modelData, ok := data.(*model.PmxModel)
+	if !ok {
+		return io_common.NewIoEncodeFailed("PMX CSV保存対象が不正です", nil)
+	}
+	records, err := BuildPmxCsvRecords(modelData)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(utf8Bom); err != nil {
+		return io_common.NewIoEncodeFailed("PMX CSVの書き込みに失敗しました", err)
+	}
+	writer := csv.NewWriter(w)
+	for rowIndex, row := range records {
+		if err := writer.Write(row); err != nil {
+			return io_common.NewIoEncodeFailed("PMX CSVの書き込みに失敗しました(行:%d)", err, rowIndex+1)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return io_common.NewIoEncodeFailed("PMX CSVの書き込み確定に失敗しました", err)
+	}
+	return nil
+}
+
+// readRecords はUTF-8(BOM有無)またはShift-JISのPMX CSVを読み込む。
+func (r *PmxCsvRepository) readRecords(fsys fs.FS, path string) ([][]string, int64, error) {
+	if !r.CanLoad(path) {
+		return nil, 0, io_common.NewIoExtInvalid(path, nil)
+	}
+	file, err := io_common.OpenFile(fsys, path, "PMX CSVファイルのオープンに失敗しました")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	raw, err := io.ReadAll(file)
+	if err != nil {
+		return nil, 0, io_common.NewIoParseFailed("PMX CSVの読み込みに失敗しました", err)
+	}
+	text, err := decodeCsvText(raw)
+	if err != nil {
+		return nil, 0, io_common.NewIoParseFailed("PMX CSVの文字コード変換に失敗しました", err)
+	}
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, 0, io_common.NewIoParseFailed("PMX CSVの解析に失敗しました: %s", err, filepath.Base(path))
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, io_common.NewIoParseFailed("PMX CSVファイル情報の取得に失敗しました", err)
+	}
+	return records, info.ModTime().UnixNano(), nil
+}
+
+// decodeCsvText はBOMを除去し、UTF-8でなければShift-JISとして復号する。
+func decodeCsvText(raw []byte) (string, error) {
+	raw = bytes.TrimPrefix(raw, utf8Bom)
+	if utf8.Valid(raw) {
+		return string(raw), nil
+	}
+	decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(raw)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,346 @@
+// 指示: miu200521358
+package pmxcsv
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// newCsvModel は全区分を含むモデルを生成する。
+func newCsvModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	m.SetName("CSV確認")
+	m.EnglishName = "CsvCheck"
+	m.Comment = "コメント,カンマ\n2行目"
+
+	center := model.NewBoneByName("センター")
+	center.ParentIndex = -1
+	center.TailIndex = -1
+	center.EffectIndex = -1
+	center.BoneFlag = model.BONE_FLAG_CAN_ROTATE | model.BONE_FLAG_CAN_TRANSLATE | model.BONE_FLAG_IS_VISIBLE | model.BONE_FLAG_CAN_MANIPULATE
+	m.Bones.AppendRaw(center)
+	leg := model.NewBoneByName("右足")
+	leg.Position = vec3(-1, 8, 0)
+	leg.ParentIndex = 0
+	leg.TailIndex = -1
+	leg.EffectIndex = 0
+	leg.EffectFactor = 0.5
+	leg.BoneFlag = model.BONE_FLAG_CAN_ROTATE | model.BONE_FLAG_IS_EXTERNAL_ROTATION
+	m.Bones.AppendRaw(leg)
+	ik := model.NewBoneByName("右足ＩＫ")
+	ik.Position = vec3(-1, 1, 0)
+	ik.ParentIndex = 0
+	ik.TailIndex = 1
+	ik.EffectIndex = -1
+	ik.BoneFlag = model.BONE_FLAG_IS_IK | model.BONE_FLAG_CAN_ROTATE | model.BONE_FLAG_TAIL_IS_BONE
+	ik.Ik = &model.Ik{
+		BoneIndex:    1,
+		LoopCount:    40,
+		UnitRotation: vec3(mmath.DegToRad(57.29578), 0, 0),
+		Links: []model.IkLink{{
+			BoneIndex:          1,
+			AngleLimit:         true,
+			MinAngleLimit:      vec3(mmath.DegToRad(-180), 0, 0),
+			MaxAngleLimit:      vec3(mmath.DegToRad(-0.5), 0, 0),
+			LocalAngleLimit:    true,
+			LocalMaxAngleLimit: vec3(1, 0, 0),
+		}},
+	}
+	m.Bones.AppendRaw(ik)
+
+	deforms := []model.IDeform{
+		model.NewBdef1(0),
+		model.NewBdef2(0, 1, 0.25),
+		model.NewBdef4([4]int{0, 1, 2, 0}, [4]float64{0.4, 0.3, 0.2, 0.1}),
+		model.NewSdef(0, 1, 0.75),
+	}
+	for i, deform := range deforms {
+		if sdef, ok := deform.(*model.Sdef); ok {
+			sdef.SdefC = vec3(0.5, 0, 0)
+			sdef.SdefR0 = vec3(0, 1, 0)
+			sdef.SdefR1 = vec3(0, 0, -1)
+		}
+		m.Vertices.AppendRaw(&model.Vertex{
+			Position:    vec3(float64(i)*0.125, 1.5, -0.25),
+			Normal:      vec3(0, 1, 0),
+			Uv:          mmath.Vec2{X: 0.5, Y: float64(i) * 0.25},
+			ExtendedUvs: []mmath.Vec4{{X: 1, Y: 2, Z: 3, W: 4}},
+			DeformType:  deform.DeformType(),
+			Deform:      deform,
+			EdgeFactor:  1,
+		})
+	}
+
+	for _, name := range []string{"tex\\body.png", "sphere.spa", "toon_custom.bmp"} {
+		texture := model.NewTexture()
+		texture.SetName(name)
+		texture.SetValid(true)
+		m.Textures.AppendRaw(texture)
+	}
+	body := model.NewMaterial()
+	body.SetName("体")
+	body.Memo = "メモ"
+	body.Diffuse = mmath.Vec4{X: 1, Y: 0.5, Z: 0.25, W: 1}
+	body.Specular = mmath.Vec4{X: 0.1, Y: 0.2, Z: 0.3, W: 5}
+	body.DrawFlag = model.DRAW_FLAG_DOUBLE_SIDED_DRAWING | model.DRAW_FLAG_DRAWING_EDGE
+	body.TextureIndex = 0
+	body.SphereTextureIndex = 1
+	body.SphereMode = model.SPHERE_MODE_ADDITION
+	body.ToonSharingFlag = model.TOON_SHARING_SHARING
+	body.ToonTextureIndex = 2
+	body.VerticesCount = 3
+	m.Materials.AppendRaw(body)
+	face := model.NewMaterial()
+	face.SetName("顔")
+	face.ToonTextureIndex = 2
+	face.VerticesCount = 3
+	m.Materials.AppendRaw(face)
+	m.Faces.AppendRaw(&model.Face{VertexIndexes: [3]int{0, 1, 2}})
+	m.Faces.AppendRaw(&model.Face{VertexIndexes: [3]int{1, 2, 3}})
+
+	offsets := [][]model.IMorphOffset{
+		{&model.VertexMorphOffset{VertexIndex: 1, Position: vec3(0, 0.5, 0)}},
+		{&model.UvMorphOffset{VertexIndex: 2, Uv: mmath.Vec4{X: 0.1}, UvType: model.MORPH_TYPE_EXTENDED_UV1}},
+		{&model.BoneMorphOffset{BoneIndex: 1, Position: vec3(0, 0, 1), Rotation: mmath.NewQuaternionFromDegrees(10, 20, 30)}},
+		{&model.MaterialMorphOffset{MaterialIndex: -1, CalcMode: model.CALC_MODE_ADDITION, Diffuse: mmath.Vec4{W: -1}}},
+		{&model.GroupMorphOffset{MorphIndex: 0, MorphFactor: 0.5}},
+	}
+	for i, morphOffsets := range offsets {
+		morph := &model.Morph{
+			Panel:     model.MORPH_PANEL_OTHER_LOWER_RIGHT,
+			MorphType: morphOffsets[0].MorphType(),
+			Offsets:   morphOffsets,
+		}
+		morph.SetName(string(rune('あ' + i)))
+		m.Morphs.AppendRaw(morph)
+	}
+
+	m.CreateDefaultDisplaySlots()
+	root, _ := m.DisplaySlots.Get(0)
+	root.References = append(root.References, model.Reference{DisplayType: model.DISPLAY_TYPE_BONE, DisplayIndex: 0})
+	faceSlot, _ := m.DisplaySlots.Get(1)
+	faceSlot.References = append(faceSlot.References, model.Reference{DisplayType: model.DISPLAY_TYPE_MORPH, DisplayIndex: 0})
+
+	for i, name := range []string{"剛体A", "剛体B"} {
+		rigidBody := &model.RigidBody{
+			BoneIndex:      i,
+			CollisionGroup: model.CollisionGroup{Group: byte(i + 1), Mask: 0xfffa},
+			Shape:          model.SHAPE_CAPSULE,
+			Size:           vec3(0.5, 2, 0),
+			Position:       vec3(0, float64(i), 0),
+			Rotation:       vec3(mmath.DegToRad(90), 0, 0),
+			Param:          model.RigidBodyParam{Mass: 1, LinearDamping: 0.5, AngularDamping: 0.5, Friction: 0.5},
+			PhysicsType:    model.PHYSICS_TYPE_DYNAMIC,
+		}
+		rigidBody.SetName(name)
+		m.RigidBodies.AppendRaw(rigidBody)
+	}
+	joint := &model.Joint{RigidBodyIndexA: 0, RigidBodyIndexB: 1}
+	joint.SetName("ジョイント")
+	joint.Param.RotationLimitMin = vec3(mmath.DegToRad(-30), 0, 0)
+	joint.Param.RotationLimitMax = vec3(mmath.DegToRad(30), 0, 0)
+	joint.Param.SpringConstantRotation = vec3(100, 0, 0)
+	m.Joints.AppendRaw(joint)
+	return m
+}
+
+func TestPmxCsvRepository_RoundTrip(t *testing.T) {
+	original := newCsvModel()
+	path := filepath.Join(t.TempDir(), "model.csv")
+	r := NewPmxCsvRepository()
+	if err := r.Save(path, original, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	if !bytes.HasPrefix(raw, utf8Bom) || !bytes.Contains(raw, []byte(";PmxVertex,")) {
+		t.Fatalf("Expected BOM and section header, got %q", raw[:min(len(raw), 64)])
+	}
+
+	loaded, err := r.Load(path)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	loadedModel := loaded.(*model.PmxModel)
+	if loadedModel.Name() != "CSV確認" || loadedModel.Comment != original.Comment || loadedModel.Path() != path {
+		t.Errorf("Expected model info to be restored, got %q %q %q", loadedModel.Name(), loadedModel.Comment, loadedModel.Path())
+	}
+	material, _ := loadedModel.Materials.GetByName("体")
+	if material.ToonSharingFlag != model.TOON_SHARING_SHARING || material.ToonTextureIndex != 2 {
+		t.Errorf("Expected shared toon to be restored, got %v %d", material.ToonSharingFlag, material.ToonTextureIndex)
+	}
+	faceMaterial, _ := loadedModel.Materials.GetByName("顔")
+	if toon, _ := loadedModel.Textures.Get(faceMaterial.ToonTextureIndex); toon == nil || toon.Name() != "toon_custom.bmp" {
+		t.Errorf("Expected individual toon to be restored, got %v", toon)
+	}
+	ikBone, _ := loadedModel.Bones.GetByName("右足ＩＫ")
+	if ikBone.Ik == nil || len(ikBone.Ik.Links) != 1 || ikBone.Ik.Links[0].LocalAngleLimit {
+		t.Errorf("Expected ik link without local angle limit, got %+v", ikBone.Ik)
+	}
+	rigidBody, _ := loadedModel.RigidBodies.GetByName("剛体B")
+	if rigidBody.CollisionGroup.Group != 2 || rigidBody.CollisionGroup.Mask != 0xfffa {
+		t.Errorf("Expected collision group to be restored, got %+v", rigidBody.CollisionGroup)
+	}
+	if slot, _ := loadedModel.DisplaySlots.Get(1); slot.SpecialFlag != model.SPECIAL_FLAG_ON || len(slot.References) != 1 {
+		t.Errorf("Expected morph display slot to be restored, got %+v", slot)
+	}
+
+	expected, _ := BuildPmxCsvRecords(original)
+	actual, err := BuildPmxCsvRecords(loadedModel)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	assertRecordsEqual(t, expected, actual)
+}
+
+func TestApplyPmxCsvRecords_Partial(t *testing.T) {
+	m := newCsvModel()
+	records := [][]string{
+		{";PmxBone", "ボーン名"},
+		boneRecord("右足", "センター", 2),
+		boneRecord("左足", "センター", 0),
+		{";PmxMaterial"},
+		{"PmxMaterial", "髪", "Hair", "1", "1", "1", "1", "0", "0", "0", "5", "0.5", "0.5", "0.5",
+			"1", "0", "0", "0", "0", "0", "0", "1", "0", "0", "0", "1", "hair.png", "", "0", "toon03.bmp", ""},
+		{"PmxFace", "顔", "0", "3", "2", "1"},
+		{"PmxFace", "髪", "0", "0", "1", "3"},
+		{"PmxIKLink", "右足ＩＫ", "左足", "0"},
+		{"PmxIKLink", "右足ＩＫ", "右足", "1", "-90", "0"},
+		{"PmxVertex", "1", "9", "9", "9"},
+	}
+	result, err := ApplyPmxCsvRecords(m, records)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	if result.Updated[sectionBone] != 1 || result.Added[sectionBone] != 1 || result.Added[sectionMaterial] != 1 || result.Updated[sectionFace] != 2 {
+		t.Errorf("Expected counts to be reported, got %+v", result)
+	}
+	leg, _ := m.Bones.GetByName("右足")
+	if leg.Layer != 2 || leg.EffectIndex != -1 || leg.Position.Y != 0 {
+		t.Errorf("Expected listed bone to be updated, got %+v", leg)
+	}
+	if center, _ := m.Bones.GetByName("センター"); center.BoneFlag&model.BONE_FLAG_CAN_TRANSLATE == 0 {
+		t.Errorf("Expected unlisted bone to be kept")
+	}
+	ik, _ := m.Bones.GetByName("右足ＩＫ")
+	if len(ik.Ik.Links) != 2 || ik.Ik.Links[0].BoneIndex != 3 || !ik.Ik.Links[1].LocalAngleLimit {
+		t.Errorf("Expected ik links to be replaced with local limit kept, got %+v", ik.Ik.Links)
+	}
+	if math.Abs(mmath.RadToDeg(ik.Ik.Links[1].MinAngleLimit.X)+90) > 1e-9 {
+		t.Errorf("Expected ik limit in degrees, got %v", ik.Ik.Links[1].MinAngleLimit)
+	}
+	hair, _ := m.Materials.GetByName("髪")
+	if hair.ToonSharingFlag != model.TOON_SHARING_SHARING || hair.ToonTextureIndex != 2 || hair.VerticesCount != 3 {
+		t.Errorf("Expected new material with shared toon and faces, got %+v", hair)
+	}
+	if texture, _ := m.Textures.Get(hair.TextureIndex); texture == nil || texture.Name() != "hair.png" || !texture.IsValid() {
+		t.Errorf("Expected texture to be registered, got %v", texture)
+	}
+	if m.Faces.Len() != 3 {
+		t.Fatalf("Expected faces to be rebuilt, got %d", m.Faces.Len())
+	}
+	if f, _ := m.Faces.Get(1); f.VertexIndexes != [3]int{3, 2, 1} {
+		t.Errorf("Expected face of listed material to be replaced, got %v", f.VertexIndexes)
+	}
+	if f, _ := m.Faces.Get(0); f.VertexIndexes != [3]int{0, 1, 2} {
+		t.Errorf("Expected face of unlisted material to be kept, got %v", f.VertexIndexes)
+	}
+	vertex, _ := m.Vertices.Get(1)
+	if vertex.Position.X != 9 || len(vertex.ExtendedUvs) != 1 || vertex.Deform.DeformType() != model.BDEF1 {
+		t.Errorf("Expected vertex to be updated by index, got %+v", vertex)
+	}
+}
+
+func TestApplyPmxCsvRecords_ErrorKeepsModel(t *testing.T) {
+	m := newCsvModel()
+	records := [][]string{
+		boneRecord("右足", "存在しない親", 5),
+	}
+	_, err := ApplyPmxCsvRecords(m, records)
+	if merr.ExtractErrorID(err) != "14105" || !strings.Contains(err.Error(), "行:1") {
+		t.Fatalf("Expected error id 14105 with row number, got %v", err)
+	}
+	if leg, _ := m.Bones.GetByName("右足"); leg.Layer != 0 {
+		t.Errorf("Expected model to be unchanged, got layer %d", leg.Layer)
+	}
+	if _, err := ApplyPmxCsvRecords(m, [][]string{{"PmxVertex", "99"}}); merr.ExtractErrorID(err) != "14105" {
+		t.Errorf("Expected out of range vertex to fail, got %v", err)
+	}
+}
+
+func TestPmxCsvRepository_LoadShiftJis(t *testing.T) {
+	text := ";PmxModelInfo,モデル名\nPmxModelInfo,ＳＪＩＳモデル\nPmxBone,センター,center\n"
+	encoded, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	path := filepath.Join(t.TempDir(), "sjis.CSV")
+	if err := os.WriteFile(path, encoded, 0o644); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	r := NewPmxCsvRepository()
+	loaded, err := r.Load(path)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	loadedModel := loaded.(*model.PmxModel)
+	if loadedModel.Name() != "ＳＪＩＳモデル" || !loadedModel.Bones.ContainsByName("センター") {
+		t.Errorf("Expected Shift-JIS CSV to be decoded, got %q", loadedModel.Name())
+	}
+	if r.InferName(path) != "sjis" || r.CanLoad("model.pmx") {
+		t.Errorf("Expected .csv handling, got %q", r.InferName(path))
+	}
+}
+
+// boneRecord は名前・親・変形階層のみを指定したボーン行を生成する。
+func boneRecord(name string, parent string, layer int) []string {
+	record := make([]string, 40)
+	for i := range record {
+		record[i] = "0"
+	}
+	record[0] = sectionBone
+	record[1] = name
+	record[2] = ""
+	record[3] = strconv.Itoa(layer)
+	record[13] = parent
+	record[15] = ""
+	record[23] = ""
+	record[37] = ""
+	return record
+}
+
+// assertRecordsEqual はレコードを比較する。数値セルは誤差を許容する。
+func assertRecordsEqual(t *testing.T, expected [][]string, actual [][]string) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Fatalf("Expected %d records, got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if len(expected[i]) != len(actual[i]) {
+			t.Fatalf("Expected %d cells at row %d, got %d", len(expected[i]), i+1, len(actual[i]))
+		}
+		for j := range expected[i] {
+			if expected[i][j] == actual[i][j] {
+				continue
+			}
+			e, errE := strconv.ParseFloat(expected[i][j], 64)
+			a, errA := strconv.ParseFloat(actual[i][j], 64)
+			if errE != nil || errA != nil || math.Abs(e-a) > 1e-6 {
+				t.Errorf("Expected %q at row %d col %d, got %q", expected[i][j], i+1, j+1, actual[i][j])
+			}
+		}
+	}
+}
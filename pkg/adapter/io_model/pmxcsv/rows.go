@@ -0,0 +1,345 @@
+// 指示: miu200521358
+package pmxcsv
+
+// PMXEditor CSV の行種別。ヘッダ行は先頭に ";" を付ける。
+const (
+	sectionModelInfo     = "PmxModelInfo"
+	sectionVertex        = "PmxVertex"
+	sectionFace          = "PmxFace"
+	sectionMaterial      = "PmxMaterial"
+	sectionBone          = "PmxBone"
+	sectionIkLink        = "PmxIKLink"
+	sectionMorph         = "PmxMorph"
+	sectionVertexMorph   = "PmxVertexMorph"
+	sectionUvMorph       = "PmxUVMorph"
+	sectionBoneMorph     = "PmxBoneMorph"
+	sectionMaterialMorph = "PmxMaterialMorph"
+	sectionGroupMorph    = "PmxGroupMorph"
+	sectionNode          = "PmxNode"
+	sectionNodeItem      = "PmxNodeItem"
+	sectionBody          = "PmxBody"
+	sectionJoint         = "PmxJoint"
+)
+
+// modelInfoRow はモデル情報行を表す。
+type modelInfoRow struct {
+	Kind           string `csv:";PmxModelInfo"`
+	Name           string `csv:"モデル名"`
+	EnglishName    string `csv:"モデル名(英)"`
+	Comment        string `csv:"コメント"`
+	EnglishComment string `csv:"コメント(英)"`
+}
+
+// vertexRow は頂点行を表す。
+type vertexRow struct {
+	Kind       string  `csv:";PmxVertex"`
+	Index      int     `csv:"頂点Index"`
+	PositionX  float64 `csv:"位置_x"`
+	PositionY  float64 `csv:"位置_y"`
+	PositionZ  float64 `csv:"位置_z"`
+	NormalX    float64 `csv:"法線_x"`
+	NormalY    float64 `csv:"法線_y"`
+	NormalZ    float64 `csv:"法線_z"`
+	EdgeFactor float64 `csv:"エッジ倍率"`
+	U          float64 `csv:"UV_u"`
+	V          float64 `csv:"UV_v"`
+	Ex1X       float64 `csv:"追加UV1_x"`
+	Ex1Y       float64 `csv:"追加UV1_y"`
+	Ex1Z       float64 `csv:"追加UV1_z"`
+	Ex1W       float64 `csv:"追加UV1_w"`
+	Ex2X       float64 `csv:"追加UV2_x"`
+	Ex2Y       float64 `csv:"追加UV2_y"`
+	Ex2Z       float64 `csv:"追加UV2_z"`
+	Ex2W       float64 `csv:"追加UV2_w"`
+	Ex3X       float64 `csv:"追加UV3_x"`
+	Ex3Y       float64 `csv:"追加UV3_y"`
+	Ex3Z       float64 `csv:"追加UV3_z"`
+	Ex3W       float64 `csv:"追加UV3_w"`
+	Ex4X       float64 `csv:"追加UV4_x"`
+	Ex4Y       float64 `csv:"追加UV4_y"`
+	Ex4Z       float64 `csv:"追加UV4_z"`
+	Ex4W       float64 `csv:"追加UV4_w"`
+	DeformType int     `csv:"ウェイト変形タイプ(0:BDEF1 1:BDEF2 2:BDEF4 3:SDEF)"`
+	Bone1      string  `csv:"ウェイト1_ボーン名"`
+	Weight1    float64 `csv:"ウェイト1_ウェイト値"`
+	Bone2      string  `csv:"ウェイト2_ボーン名"`
+	Weight2    float64 `csv:"ウェイト2_ウェイト値"`
+	Bone3      string  `csv:"ウェイト3_ボーン名"`
+	Weight3    float64 `csv:"ウェイト3_ウェイト値"`
+	Bone4      string  `csv:"ウェイト4_ボーン名"`
+	Weight4    float64 `csv:"ウェイト4_ウェイト値"`
+	SdefCX     float64 `csv:"C_x"`
+	SdefCY     float64 `csv:"C_y"`
+	SdefCZ     float64 `csv:"C_z"`
+	SdefR0X    float64 `csv:"R0_x"`
+	SdefR0Y    float64 `csv:"R0_y"`
+	SdefR0Z    float64 `csv:"R0_z"`
+	SdefR1X    float64 `csv:"R1_x"`
+	SdefR1Y    float64 `csv:"R1_y"`
+	SdefR1Z    float64 `csv:"R1_z"`
+}
+
+// faceRow は面行を表す。面Indexは材質内の通し番号。
+type faceRow struct {
+	Kind         string `csv:";PmxFace"`
+	MaterialName string `csv:"親材質名"`
+	Index        int    `csv:"面Index"`
+	Vertex1      int    `csv:"頂点Index1"`
+	Vertex2      int    `csv:"頂点Index2"`
+	Vertex3      int    `csv:"頂点Index3"`
+}
+
+// materialRow は材質行を表す。
+type materialRow struct {
+	Kind          string  `csv:";PmxMaterial"`
+	Name          string  `csv:"材質名"`
+	EnglishName   string  `csv:"材質名(英)"`
+	DiffuseR      float64 `csv:"拡散色_R"`
+	DiffuseG      float64 `csv:"拡散色_G"`
+	DiffuseB      float64 `csv:"拡散色_B"`
+	DiffuseA      float64 `csv:"拡散色_A(非透過度)"`
+	SpecularR     float64 `csv:"反射色_R"`
+	SpecularG     float64 `csv:"反射色_G"`
+	SpecularB     float64 `csv:"反射色_B"`
+	SpecularPower float64 `csv:"反射強度"`
+	AmbientR      float64 `csv:"環境色_R"`
+	AmbientG      float64 `csv:"環境色_G"`
+	AmbientB      float64 `csv:"環境色_B"`
+	DoubleSided   int     `csv:"両面描画(0/1)"`
+	GroundShadow  int     `csv:"地面影(0/1)"`
+	SelfShadowMap int     `csv:"セルフ影マップ(0/1)"`
+	SelfShadow    int     `csv:"セルフ影(0/1)"`
+	VertexColor   int     `csv:"頂点色(0/1)"`
+	DrawMode      int     `csv:"描画(0:Tri/1:Point/2:Line)"`
+	Edge          int     `csv:"エッジ(0/1)"`
+	EdgeSize      float64 `csv:"エッジサイズ"`
+	EdgeR         float64 `csv:"エッジ色_R"`
+	EdgeG         float64 `csv:"エッジ色_G"`
+	EdgeB         float64 `csv:"エッジ色_B"`
+	EdgeA         float64 `csv:"エッジ色_A"`
+	TexturePath   string  `csv:"テクスチャパス"`
+	SpherePath    string  `csv:"スフィアテクスチャパス"`
+	SphereMode    int     `csv:"スフィアモード(0:無効/1:乗算/2:加算/3:サブテクスチャ)"`
+	ToonPath      string  `csv:"Toonテクスチャパス"`
+	Memo          string  `csv:"メモ"`
+}
+
+// boneRow はボーン行を表す。
+type boneRow struct {
+	Kind                string  `csv:";PmxBone"`
+	Name                string  `csv:"ボーン名"`
+	EnglishName         string  `csv:"ボーン名(英)"`
+	Layer               int     `csv:"変形階層"`
+	AfterPhysics        int     `csv:"物理後(0/1)"`
+	PositionX           float64 `csv:"位置_x"`
+	PositionY           float64 `csv:"位置_y"`
+	PositionZ           float64 `csv:"位置_z"`
+	CanRotate           int     `csv:"回転(0/1)"`
+	CanTranslate        int     `csv:"移動(0/1)"`
+	IsIk                int     `csv:"IK(0/1)"`
+	Visible             int     `csv:"表示(0/1)"`
+	CanManipulate       int     `csv:"操作(0/1)"`
+	ParentName          string  `csv:"親ボーン名"`
+	TailIsBone          int     `csv:"表示先(0:オフセット/1:ボーン)"`
+	TailName            string  `csv:"表示先ボーン名"`
+	TailX               float64 `csv:"オフセット_x"`
+	TailY               float64 `csv:"オフセット_y"`
+	TailZ               float64 `csv:"オフセット_z"`
+	ExternalLocal       int     `csv:"ローカル付与(0/1)"`
+	ExternalRotation    int     `csv:"回転付与(0/1)"`
+	ExternalTranslation int     `csv:"移動付与(0/1)"`
+	EffectFactor        float64 `csv:"付与率"`
+	EffectName          string  `csv:"付与親名"`
+	FixedAxis           int     `csv:"軸制限(0/1)"`
+	FixedAxisX          float64 `csv:"制限軸_x"`
+	FixedAxisY          float64 `csv:"制限軸_y"`
+	FixedAxisZ          float64 `csv:"制限軸_z"`
+	LocalAxis           int     `csv:"ローカル軸(0/1)"`
+	LocalAxisXX         float64 `csv:"ローカルX軸_x"`
+	LocalAxisXY         float64 `csv:"ローカルX軸_y"`
+	LocalAxisXZ         float64 `csv:"ローカルX軸_z"`
+	LocalAxisZX         float64 `csv:"ローカルZ軸_x"`
+	LocalAxisZY         float64 `csv:"ローカルZ軸_y"`
+	LocalAxisZZ         float64 `csv:"ローカルZ軸_z"`
+	ExternalParent      int     `csv:"外部親(0/1)"`
+	EffectorKey         int     `csv:"外部親Key"`
+	IkTargetName        string  `csv:"IKTarget名"`
+	IkLoopCount         int     `csv:"IKLoop"`
+	IkUnitAngle         float64 `csv:"IK単位角[deg]"`
+}
+
+// ikLinkRow はIKリンク行を表す。
+type ikLinkRow struct {
+	Kind       string  `csv:";PmxIKLink"`
+	BoneName   string  `csv:"親ボーン名"`
+	LinkName   string  `csv:"Linkボーン名"`
+	AngleLimit int     `csv:"角度制限(0/1)"`
+	MinX       float64 `csv:"XL[deg]"`
+	MaxX       float64 `csv:"XH[deg]"`
+	MinY       float64 `csv:"YL[deg]"`
+	MaxY       float64 `csv:"YH[deg]"`
+	MinZ       float64 `csv:"ZL[deg]"`
+	MaxZ       float64 `csv:"ZH[deg]"`
+}
+
+// morphRow はモーフ行を表す。
+type morphRow struct {
+	Kind        string `csv:";PmxMorph"`
+	Name        string `csv:"モーフ名"`
+	EnglishName string `csv:"モーフ名(英)"`
+	Panel       int    `csv:"パネル(0:無効/1:眉(左下)/2:目(左上)/3:口(右上)/4:その他(右下))"`
+	MorphType   int    `csv:"モーフ種類(0:グループ/1:頂点/2:ボーン/3:UV/4:追加UV1/5:追加UV2/6:追加UV3/7:追加UV4/8:材質)"`
+}
+
+// vertexMorphRow は頂点モーフオフセット行を表す。
+type vertexMorphRow struct {
+	Kind        string  `csv:";PmxVertexMorph"`
+	MorphName   string  `csv:"親モーフ名"`
+	VertexIndex int     `csv:"頂点Index"`
+	X           float64 `csv:"位置オフセット_x"`
+	Y           float64 `csv:"位置オフセット_y"`
+	Z           float64 `csv:"位置オフセット_z"`
+}
+
+// uvMorphRow はUVモーフオフセット行を表す。
+type uvMorphRow struct {
+	Kind        string  `csv:";PmxUVMorph"`
+	MorphName   string  `csv:"親モーフ名"`
+	VertexIndex int     `csv:"頂点Index"`
+	X           float64 `csv:"UVオフセット_x"`
+	Y           float64 `csv:"UVオフセット_y"`
+	Z           float64 `csv:"UVオフセット_z"`
+	W           float64 `csv:"UVオフセット_w"`
+}
+
+// boneMorphRow はボーンモーフオフセット行を表す。
+type boneMorphRow struct {
+	Kind      string  `csv:";PmxBoneMorph"`
+	MorphName string  `csv:"親モーフ名"`
+	BoneName  string  `csv:"ボーン名"`
+	X         float64 `csv:"移動量_x"`
+	Y         float64 `csv:"移動量_y"`
+	Z         float64 `csv:"移動量_z"`
+	RotationX float64 `csv:"回転量_x[deg]"`
+	RotationY float64 `csv:"回転量_y[deg]"`
+	RotationZ float64 `csv:"回転量_z[deg]"`
+}
+
+// materialMorphRow は材質モーフオフセット行を表す。材質名が空の場合は全材質対象。
+type materialMorphRow struct {
+	Kind          string  `csv:";PmxMaterialMorph"`
+	MorphName     string  `csv:"親モーフ名"`
+	MaterialName  string  `csv:"材質名"`
+	CalcMode      int     `csv:"演算形式(0:乗算/1:加算)"`
+	DiffuseR      float64 `csv:"拡散色_R"`
+	DiffuseG      float64 `csv:"拡散色_G"`
+	DiffuseB      float64 `csv:"拡散色_B"`
+	DiffuseA      float64 `csv:"拡散色_A"`
+	SpecularR     float64 `csv:"反射色_R"`
+	SpecularG     float64 `csv:"反射色_G"`
+	SpecularB     float64 `csv:"反射色_B"`
+	SpecularPower float64 `csv:"反射強度"`
+	AmbientR      float64 `csv:"環境色_R"`
+	AmbientG      float64 `csv:"環境色_G"`
+	AmbientB      float64 `csv:"環境色_B"`
+	EdgeR         float64 `csv:"エッジ色_R"`
+	EdgeG         float64 `csv:"エッジ色_G"`
+	EdgeB         float64 `csv:"エッジ色_B"`
+	EdgeA         float64 `csv:"エッジ色_A"`
+	EdgeSize      float64 `csv:"エッジサイズ"`
+	TextureR      float64 `csv:"テクスチャ係数_R"`
+	TextureG      float64 `csv:"テクスチャ係数_G"`
+	TextureB      float64 `csv:"テクスチャ係数_B"`
+	TextureA      float64 `csv:"テクスチャ係数_A"`
+	SphereR       float64 `csv:"スフィア係数_R"`
+	SphereG       float64 `csv:"スフィア係数_G"`
+	SphereB       float64 `csv:"スフィア係数_B"`
+	SphereA       float64 `csv:"スフィア係数_A"`
+	ToonR         float64 `csv:"Toon係数_R"`
+	ToonG         float64 `csv:"Toon係数_G"`
+	ToonB         float64 `csv:"Toon係数_B"`
+	ToonA         float64 `csv:"Toon係数_A"`
+}
+
+// groupMorphRow はグループモーフオフセット行を表す。
+type groupMorphRow struct {
+	Kind      string  `csv:";PmxGroupMorph"`
+	MorphName string  `csv:"親モーフ名"`
+	Target    string  `csv:"モーフ名"`
+	Factor    float64 `csv:"影響度"`
+}
+
+// nodeRow は表示枠行を表す。
+type nodeRow struct {
+	Kind        string `csv:";PmxNode"`
+	Name        string `csv:"表示枠名"`
+	EnglishName string `csv:"表示枠名(英)"`
+}
+
+// nodeItemRow は表示枠要素行を表す。
+type nodeItemRow struct {
+	Kind     string `csv:";PmxNodeItem"`
+	NodeName string `csv:"親表示枠名"`
+	ItemType int    `csv:"要素タイプ(0:ボーン/1:モーフ)"`
+	ItemName string `csv:"要素名"`
+}
+
+// bodyRow は剛体行を表す。
+type bodyRow struct {
+	Kind           string  `csv:";PmxBody"`
+	Name           string  `csv:"剛体名"`
+	EnglishName    string  `csv:"剛体名(英)"`
+	BoneName       string  `csv:"関連ボーン名"`
+	PhysicsType    int     `csv:"剛体タイプ(0:Bone/1:物理演算/2:物理演算+Bone位置合わせ)"`
+	Group          int     `csv:"グループ(1~16)"`
+	NoCollision    string  `csv:"非衝突グループ文字列(ex:1 2 3 4)"`
+	Shape          int     `csv:"形状(0:球/1:箱/2:カプセル)"`
+	SizeX          float64 `csv:"サイズ_x"`
+	SizeY          float64 `csv:"サイズ_y"`
+	SizeZ          float64 `csv:"サイズ_z"`
+	PositionX      float64 `csv:"位置_x"`
+	PositionY      float64 `csv:"位置_y"`
+	PositionZ      float64 `csv:"位置_z"`
+	RotationX      float64 `csv:"回転_x[deg]"`
+	RotationY      float64 `csv:"回転_y[deg]"`
+	RotationZ      float64 `csv:"回転_z[deg]"`
+	Mass           float64 `csv:"質量"`
+	LinearDamping  float64 `csv:"移動減衰"`
+	AngularDamping float64 `csv:"回転減衰"`
+	Restitution    float64 `csv:"反発力"`
+	Friction       float64 `csv:"摩擦力"`
+}
+
+// jointRow はジョイント行を表す。
+type jointRow struct {
+	Kind               string  `csv:";PmxJoint"`
+	Name               string  `csv:"Joint名"`
+	EnglishName        string  `csv:"Joint名(英)"`
+	RigidBodyNameA     string  `csv:"剛体名A"`
+	RigidBodyNameB     string  `csv:"剛体名B"`
+	JointType          int     `csv:"Jointタイプ(0:バネ付6DOF)"`
+	PositionX          float64 `csv:"位置_x"`
+	PositionY          float64 `csv:"位置_y"`
+	PositionZ          float64 `csv:"位置_z"`
+	RotationX          float64 `csv:"回転_x[deg]"`
+	RotationY          float64 `csv:"回転_y[deg]"`
+	RotationZ          float64 `csv:"回転_z[deg]"`
+	TranslationMinX    float64 `csv:"移動下限_x"`
+	TranslationMinY    float64 `csv:"移動下限_y"`
+	TranslationMinZ    float64 `csv:"移動下限_z"`
+	TranslationMaxX    float64 `csv:"移動上限_x"`
+	TranslationMaxY    float64 `csv:"移動上限_y"`
+	TranslationMaxZ    float64 `csv:"移動上限_z"`
+	RotationMinX       float64 `csv:"回転下限_x[deg]"`
+	RotationMinY       float64 `csv:"回転下限_y[deg]"`
+	RotationMinZ       float64 `csv:"回転下限_z[deg]"`
+	RotationMaxX       float64 `csv:"回転上限_x[deg]"`
+	RotationMaxY       float64 `csv:"回転上限_y[deg]"`
+	RotationMaxZ       float64 `csv:"回転上限_z[deg]"`
+	SpringTranslationX float64 `csv:"バネ定数-移動_x"`
+	SpringTranslationY float64 `csv:"バネ定数-移動_y"`
+	SpringTranslationZ float64 `csv:"バネ定数-移動_z"`
+	SpringRotationX    float64 `csv:"バネ定数-回転_x"`
+	SpringRotationY    float64 `csv:"バネ定数-回転_y"`
+	SpringRotationZ    float64 `csv:"バネ定数-回転_z"`
+}
@@ -0,0 +1,839 @@
+// 指示: miu200521358
+
+// Package pmxjson はPmxModelを可逆なJSONテキストとして入出力する。
+//
+// ルートオブジェクトは format("mlib.pmx.json") と version を持ち、
+// 以降は PmxModel の各コレクションを index 順の配列で保持する。
+// 要素間の参照(ボーン・材質・テクスチャ等)はバイナリと同じく index の数値で表す。
+// 各要素はドメイン型から独立したJSON用の構造体へ変換し、キーは lowerCamelCase で固定する。
+// ベクトルとクォータニオンは x/y/z/w、列挙値はバイナリと同じ数値で表す。
+// 頂点の deform は bones/weights と SDEF パラメータを持つオブジェクト、
+// モーフの offsets は vertex/uv/bone/group/material のいずれか1つを持つオブジェクトで表す。
+package pmxjson
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/vrm"
+)
+
+const (
+	// PMX_JSON_FORMAT はPMX JSONの形式識別子。
+	PMX_JSON_FORMAT = "mlib.pmx.json"
+	// PMX_JSON_VERSION はPMX JSONの形式バージョン。
+	PMX_JSON_VERSION = 1
+)
+
+// pmxJsonDocument はPMX JSONのルートを表す。
+type pmxJsonDocument struct {
+	Format         string            `json:"format"`
+	Version        int               `json:"version"`
+	Name           string            `json:"name"`
+	EnglishName    string            `json:"englishName"`
+	Comment        string            `json:"comment"`
+	EnglishComment string            `json:"englishComment"`
+	Vertices       []vertexJson      `json:"vertices"`
+	Faces          [][3]int          `json:"faces"`
+	Textures       []textureJson     `json:"textures"`
+	Materials      []materialJson    `json:"materials"`
+	Bones          []boneJson        `json:"bones"`
+	Morphs         []morphJson       `json:"morphs"`
+	DisplaySlots   []displaySlotJson `json:"displaySlots"`
+	RigidBodies    []rigidBodyJson   `json:"rigidBodies"`
+	Joints         []jointJson       `json:"joints"`
+	VrmData        *vrmDataJson      `json:"vrmData,omitempty"`
+}
+
+// quaternionJson はクォータニオンを表す。
+type quaternionJson struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+	W float64 `json:"w"`
+}
+
+// vertexJson は頂点を表す。
+type vertexJson struct {
+	Position        mmath.Vec3       `json:"position"`
+	Normal          mmath.Vec3       `json:"normal"`
+	Uv              mmath.Vec2       `json:"uv"`
+	ExtendedUvs     []mmath.Vec4     `json:"extendedUvs"`
+	DeformType      model.DeformType `json:"deformType"`
+	Deform          deformJson       `json:"deform"`
+	EdgeFactor      float64          `json:"edgeFactor"`
+	MaterialIndexes []int            `json:"materialIndexes"`
+}
+
+// deformJson はデフォームを表す。
+type deformJson struct {
+	Bones   []int       `json:"bones"`
+	Weights []float64   `json:"weights"`
+	SdefC   *mmath.Vec3 `json:"sdefC,omitempty"`
+	SdefR0  *mmath.Vec3 `json:"sdefR0,omitempty"`
+	SdefR1  *mmath.Vec3 `json:"sdefR1,omitempty"`
+}
+
+// textureJson はテクスチャを表す。
+type textureJson struct {
+	Name        string            `json:"name"`
+	EnglishName string            `json:"englishName"`
+	TextureType model.TextureType `json:"textureType"`
+	Valid       bool              `json:"valid"`
+}
+
+// materialJson は材質を表す。
+type materialJson struct {
+	Name                string                `json:"name"`
+	EnglishName         string                `json:"englishName"`
+	Memo                string                `json:"memo"`
+	Diffuse             mmath.Vec4            `json:"diffuse"`
+	Specular            mmath.Vec4            `json:"specular"`
+	Ambient             mmath.Vec3            `json:"ambient"`
+	DrawFlag            model.DrawFlag        `json:"drawFlag"`
+	Edge                mmath.Vec4            `json:"edge"`
+	EdgeSize            float64               `json:"edgeSize"`
+	TextureFactor       mmath.Vec4            `json:"textureFactor"`
+	SphereTextureFactor mmath.Vec4            `json:"sphereTextureFactor"`
+	ToonTextureFactor   mmath.Vec4            `json:"toonTextureFactor"`
+	TextureIndex        int                   `json:"textureIndex"`
+	SphereTextureIndex  int                   `json:"sphereTextureIndex"`
+	SphereMode          model.SphereMode      `json:"sphereMode"`
+	ToonSharingFlag     model.ToonSharingFlag `json:"toonSharingFlag"`
+	ToonTextureIndex    int                   `json:"toonTextureIndex"`
+	VerticesCount       int                   `json:"verticesCount"`
+}
+
+// boneJson はボーンを表す。
+type boneJson struct {
+	Name             string         `json:"name"`
+	EnglishName      string         `json:"englishName"`
+	Position         mmath.Vec3     `json:"position"`
+	ParentIndex      int            `json:"parentIndex"`
+	Layer            int            `json:"layer"`
+	BoneFlag         model.BoneFlag `json:"boneFlag"`
+	TailPosition     mmath.Vec3     `json:"tailPosition"`
+	TailIndex        int            `json:"tailIndex"`
+	EffectIndex      int            `json:"effectIndex"`
+	EffectFactor     float64        `json:"effectFactor"`
+	FixedAxis        mmath.Vec3     `json:"fixedAxis"`
+	LocalAxisX       mmath.Vec3     `json:"localAxisX"`
+	LocalAxisZ       mmath.Vec3     `json:"localAxisZ"`
+	EffectorKey      int            `json:"effectorKey"`
+	Ik               *ikJson        `json:"ik,omitempty"`
+	DisplaySlotIndex int            `json:"displaySlotIndex"`
+	IsSystem         bool           `json:"isSystem"`
+}
+
+// ikJson はIK設定を表す。
+type ikJson struct {
+	BoneIndex    int          `json:"boneIndex"`
+	LoopCount    int          `json:"loopCount"`
+	UnitRotation mmath.Vec3   `json:"unitRotation"`
+	Links        []ikLinkJson `json:"links"`
+}
+
+// ikLinkJson はIKリンクを表す。
+type ikLinkJson struct {
+	BoneIndex          int        `json:"boneIndex"`
+	AngleLimit         bool       `json:"angleLimit"`
+	MinAngleLimit      mmath.Vec3 `json:"minAngleLimit"`
+	MaxAngleLimit      mmath.Vec3 `json:"maxAngleLimit"`
+	LocalAngleLimit    bool       `json:"localAngleLimit"`
+	LocalMinAngleLimit mmath.Vec3 `json:"localMinAngleLimit"`
+	LocalMaxAngleLimit mmath.Vec3 `json:"localMaxAngleLimit"`
+}
+
+// morphJson はモーフを表す。
+type morphJson struct {
+	Name        string            `json:"name"`
+	EnglishName string            `json:"englishName"`
+	Panel       model.MorphPanel  `json:"panel"`
+	MorphType   model.MorphType   `json:"morphType"`
+	Offsets     []morphOffsetJson `json:"offsets"`
+	DisplaySlot int               `json:"displaySlot"`
+	IsSystem    bool              `json:"isSystem"`
+}
+
+// morphOffsetJson はモーフオフセットを表す。いずれか1つだけを持つ。
+type morphOffsetJson struct {
+	Vertex   *vertexMorphOffsetJson   `json:"vertex,omitempty"`
+	Uv       *uvMorphOffsetJson       `json:"uv,omitempty"`
+	Bone     *boneMorphOffsetJson     `json:"bone,omitempty"`
+	Group    *groupMorphOffsetJson    `json:"group,omitempty"`
+	Material *materialMorphOffsetJson `json:"material,omitempty"`
+}
+
+// vertexMorphOffsetJson は頂点モーフオフセットを表す。
+type vertexMorphOffsetJson struct {
+	VertexIndex int        `json:"vertexIndex"`
+	Position    mmath.Vec3 `json:"position"`
+}
+
+// uvMorphOffsetJson はUVモーフオフセットを表す。
+type uvMorphOffsetJson struct {
+	VertexIndex int             `json:"vertexIndex"`
+	Uv          mmath.Vec4      `json:"uv"`
+	UvType      model.MorphType `json:"uvType"`
+}
+
+// boneMorphOffsetJson はボーンモーフオフセットを表す。
+type boneMorphOffsetJson struct {
+	BoneIndex int            `json:"boneIndex"`
+	Position  mmath.Vec3     `json:"position"`
+	Rotation  quaternionJson `json:"rotation"`
+}
+
+// groupMorphOffsetJson はグループモーフオフセットを表す。
+type groupMorphOffsetJson struct {
+	MorphIndex  int     `json:"morphIndex"`
+	MorphFactor float64 `json:"morphFactor"`
+}
+
+// materialMorphOffsetJson は材質モーフオフセットを表す。
+type materialMorphOffsetJson struct {
+	MaterialIndex       int                         `json:"materialIndex"`
+	CalcMode            model.MaterialMorphCalcMode `json:"calcMode"`
+	Diffuse             mmath.Vec4                  `json:"diffuse"`
+	Specular            mmath.Vec4                  `json:"specular"`
+	Ambient             mmath.Vec3                  `json:"ambient"`
+	Edge                mmath.Vec4                  `json:"edge"`
+	EdgeSize            float64                     `json:"edgeSize"`
+	TextureFactor       mmath.Vec4                  `json:"textureFactor"`
+	SphereTextureFactor mmath.Vec4                  `json:"sphereTextureFactor"`
+	ToonTextureFactor   mmath.Vec4                  `json:"toonTextureFactor"`
+}
+
+// displaySlotJson は表示枠を表す。
+type displaySlotJson struct {
+	Name        string            `json:"name"`
+	EnglishName string            `json:"englishName"`
+	SpecialFlag model.SpecialFlag `json:"specialFlag"`
+	References  []displayRefJson  `json:"references"`
+}
+
+// displayRefJson は表示枠の要素を表す。
+type displayRefJson struct {
+	DisplayType  model.DisplayType `json:"displayType"`
+	DisplayIndex int               `json:"displayIndex"`
+}
+
+// rigidBodyJson は剛体を表す。
+type rigidBodyJson struct {
+	Name           string            `json:"name"`
+	EnglishName    string            `json:"englishName"`
+	BoneIndex      int               `json:"boneIndex"`
+	CollisionGroup byte              `json:"collisionGroup"`
+	CollisionMask  uint16            `json:"collisionMask"`
+	Shape          model.Shape       `json:"shape"`
+	Size           mmath.Vec3        `json:"size"`
+	Position       mmath.Vec3        `json:"position"`
+	Rotation       mmath.Vec3        `json:"rotation"`
+	Mass           float64           `json:"mass"`
+	LinearDamping  float64           `json:"linearDamping"`
+	AngularDamping float64           `json:"angularDamping"`
+	Restitution    float64           `json:"restitution"`
+	Friction       float64           `json:"friction"`
+	PhysicsType    model.PhysicsType `json:"physicsType"`
+	IsSystem       bool              `json:"isSystem"`
+}
+
+// jointJson はジョイントを表す。
+type jointJson struct {
+	Name                      string     `json:"name"`
+	EnglishName               string     `json:"englishName"`
+	RigidBodyIndexA           int        `json:"rigidBodyIndexA"`
+	RigidBodyIndexB           int        `json:"rigidBodyIndexB"`
+	Position                  mmath.Vec3 `json:"position"`
+	Rotation                  mmath.Vec3 `json:"rotation"`
+	TranslationLimitMin       mmath.Vec3 `json:"translationLimitMin"`
+	TranslationLimitMax       mmath.Vec3 `json:"translationLimitMax"`
+	RotationLimitMin          mmath.Vec3 `json:"rotationLimitMin"`
+	RotationLimitMax          mmath.Vec3 `json:"rotationLimitMax"`
+	SpringConstantTranslation mmath.Vec3 `json:"springConstantTranslation"`
+	SpringConstantRotation    mmath.Vec3 `json:"springConstantRotation"`
+}
+
+// newPmxJsonDocument はモデルからJSON文書を生成する。
+func newPmxJsonDocument(modelData *model.PmxModel) (*pmxJsonDocument, error) {
+	doc := &pmxJsonDocument{
+		Format:         PMX_JSON_FORMAT,
+		Version:        PMX_JSON_VERSION,
+		Name:           modelData.Name(),
+		EnglishName:    modelData.EnglishName,
+		Comment:        modelData.Comment,
+		EnglishComment: modelData.EnglishComment,
+		Vertices:       make([]vertexJson, 0, modelData.Vertices.Len()),
+		Faces:          make([][3]int, 0, modelData.Faces.Len()),
+		Textures:       make([]textureJson, 0, modelData.Textures.Len()),
+		Materials:      make([]materialJson, 0, modelData.Materials.Len()),
+		Bones:          make([]boneJson, 0, modelData.Bones.Len()),
+		Morphs:         make([]morphJson, 0, modelData.Morphs.Len()),
+		DisplaySlots:   make([]displaySlotJson, 0, modelData.DisplaySlots.Len()),
+		RigidBodies:    make([]rigidBodyJson, 0, modelData.RigidBodies.Len()),
+		Joints:         make([]jointJson, 0, modelData.Joints.Len()),
+		VrmData:        newVrmDataJson(modelData.VrmData),
+	}
+	for _, vertex := range modelData.Vertices.Values() {
+		if vertex.Deform == nil {
+			return nil, io_common.NewIoEncodeFailed("頂点のデフォームが未設定です: %d", nil, vertex.Index())
+		}
+		doc.Vertices = append(doc.Vertices, newVertexJson(vertex))
+	}
+	for _, face := range modelData.Faces.Values() {
+		doc.Faces = append(doc.Faces, face.VertexIndexes)
+	}
+	for _, texture := range modelData.Textures.Values() {
+		doc.Textures = append(doc.Textures, textureJson{
+			Name:        texture.Name(),
+			EnglishName: texture.EnglishName,
+			TextureType: texture.TextureType,
+			Valid:       texture.IsValid(),
+		})
+	}
+	for _, material := range modelData.Materials.Values() {
+		doc.Materials = append(doc.Materials, newMaterialJson(material))
+	}
+	for _, bone := range modelData.Bones.Values() {
+		doc.Bones = append(doc.Bones, newBoneJson(bone))
+	}
+	for _, morph := range modelData.Morphs.Values() {
+		encoded, err := newMorphJson(morph)
+		if err != nil {
+			return nil, err
+		}
+		doc.Morphs = append(doc.Morphs, encoded)
+	}
+	for _, slot := range modelData.DisplaySlots.Values() {
+		doc.DisplaySlots = append(doc.DisplaySlots, newDisplaySlotJson(slot))
+	}
+	for _, rigidBody := range modelData.RigidBodies.Values() {
+		doc.RigidBodies = append(doc.RigidBodies, newRigidBodyJson(rigidBody))
+	}
+	for _, joint := range modelData.Joints.Values() {
+		doc.Joints = append(doc.Joints, newJointJson(joint))
+	}
+	return doc, nil
+}
+
+// toModel はJSON文書からモデルを復元する。
+func (doc *pmxJsonDocument) toModel() (*model.PmxModel, error) {
+	if doc.Format != PMX_JSON_FORMAT || doc.Version < 1 || doc.Version > PMX_JSON_VERSION {
+		return nil, io_common.NewIoFormatNotSupported("PMX JSONの形式が未対応です: %s %d", nil, doc.Format, doc.Version)
+	}
+	modelData := model.NewPmxModel()
+	modelData.SetName(doc.Name)
+	modelData.EnglishName = doc.EnglishName
+	modelData.Comment = doc.Comment
+	modelData.EnglishComment = doc.EnglishComment
+	modelData.VrmData = doc.VrmData.toVrmData()
+	if err := compactVrmExtensions(modelData.VrmData); err != nil {
+		return nil, io_common.NewIoParseFailed("VRM拡張情報が不正です", err)
+	}
+
+	for i, encoded := range doc.Vertices {
+		vertex, err := encoded.toVertex()
+		if err != nil {
+			return nil, io_common.NewIoParseFailed("頂点のデフォームが不正です: %d", err, i)
+		}
+		modelData.Vertices.AppendRaw(vertex)
+	}
+	for _, indexes := range doc.Faces {
+		modelData.Faces.AppendRaw(&model.Face{VertexIndexes: indexes})
+	}
+	for _, encoded := range doc.Textures {
+		texture := &model.Texture{EnglishName: encoded.EnglishName, TextureType: encoded.TextureType}
+		texture.SetName(encoded.Name)
+		texture.SetValid(encoded.Valid)
+		modelData.Textures.AppendRaw(texture)
+	}
+	for _, encoded := range doc.Materials {
+		modelData.Materials.AppendRaw(encoded.toMaterial())
+	}
+	for _, encoded := range doc.Bones {
+		modelData.Bones.AppendRaw(encoded.toBone())
+	}
+	for i, encoded := range doc.Morphs {
+		morph, ok := encoded.toMorph()
+		if !ok {
+			return nil, io_common.NewIoParseFailed("モーフオフセットが不正です: %d", nil, i)
+		}
+		modelData.Morphs.AppendRaw(morph)
+	}
+	for _, encoded := range doc.DisplaySlots {
+		modelData.DisplaySlots.AppendRaw(encoded.toDisplaySlot())
+	}
+	for _, encoded := range doc.RigidBodies {
+		modelData.RigidBodies.AppendRaw(encoded.toRigidBody())
+	}
+	for _, encoded := range doc.Joints {
+		modelData.Joints.AppendRaw(encoded.toJoint())
+	}
+	return modelData, nil
+}
+
+// newQuaternionJson はクォータニオンを変換する。
+func newQuaternionJson(q mmath.Quaternion) quaternionJson {
+	return quaternionJson{X: q.X(), Y: q.Y(), Z: q.Z(), W: q.W()}
+}
+
+// toQuaternion はクォータニオンを復元する。
+func (q quaternionJson) toQuaternion() mmath.Quaternion {
+	return mmath.NewQuaternionByValues(q.X, q.Y, q.Z, q.W)
+}
+
+// newVertexJson は頂点を変換する。
+func newVertexJson(vertex *model.Vertex) vertexJson {
+	deform := deformJson{Bones: vertex.Deform.Indexes(), Weights: vertex.Deform.Weights()}
+	if sdef, ok := vertex.Deform.(*model.Sdef); ok {
+		sdefC, sdefR0, sdefR1 := sdef.SdefC, sdef.SdefR0, sdef.SdefR1
+		deform.SdefC = &sdefC
+		deform.SdefR0 = &sdefR0
+		deform.SdefR1 = &sdefR1
+	}
+	return vertexJson{
+		Position:        vertex.Position,
+		Normal:          vertex.Normal,
+		Uv:              vertex.Uv,
+		ExtendedUvs:     cloneSlice(vertex.ExtendedUvs),
+		DeformType:      vertex.DeformType,
+		Deform:          deform,
+		EdgeFactor:      vertex.EdgeFactor,
+		MaterialIndexes: cloneSlice(vertex.MaterialIndexes),
+	}
+}
+
+// toVertex は頂点を復元する。
+func (v vertexJson) toVertex() (*model.Vertex, error) {
+	deform, err := v.Deform.toDeform(v.DeformType)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Vertex{
+		Position:        v.Position,
+		Normal:          v.Normal,
+		Uv:              v.Uv,
+		ExtendedUvs:     cloneSlice(v.ExtendedUvs),
+		DeformType:      v.DeformType,
+		Deform:          deform,
+		EdgeFactor:      v.EdgeFactor,
+		MaterialIndexes: cloneSlice(v.MaterialIndexes),
+	}, nil
+}
+
+// newMaterialJson は材質を変換する。
+func newMaterialJson(material *model.Material) materialJson {
+	return materialJson{
+		Name:                material.Name(),
+		EnglishName:         material.EnglishName,
+		Memo:                material.Memo,
+		Diffuse:             material.Diffuse,
+		Specular:            material.Specular,
+		Ambient:             material.Ambient,
+		DrawFlag:            material.DrawFlag,
+		Edge:                material.Edge,
+		EdgeSize:            material.EdgeSize,
+		TextureFactor:       material.TextureFactor,
+		SphereTextureFactor: material.SphereTextureFactor,
+		ToonTextureFactor:   material.ToonTextureFactor,
+		TextureIndex:        material.TextureIndex,
+		SphereTextureIndex:  material.SphereTextureIndex,
+		SphereMode:          material.SphereMode,
+		ToonSharingFlag:     material.ToonSharingFlag,
+		ToonTextureIndex:    material.ToonTextureIndex,
+		VerticesCount:       material.VerticesCount,
+	}
+}
+
+// toMaterial は材質を復元する。
+func (m materialJson) toMaterial() *model.Material {
+	material := model.NewMaterial()
+	material.SetName(m.Name)
+	material.EnglishName = m.EnglishName
+	material.Memo = m.Memo
+	material.Diffuse = m.Diffuse
+	material.Specular = m.Specular
+	material.Ambient = m.Ambient
+	material.DrawFlag = m.DrawFlag
+	material.Edge = m.Edge
+	material.EdgeSize = m.EdgeSize
+	material.TextureFactor = m.TextureFactor
+	material.SphereTextureFactor = m.SphereTextureFactor
+	material.ToonTextureFactor = m.ToonTextureFactor
+	material.TextureIndex = m.TextureIndex
+	material.SphereTextureIndex = m.SphereTextureIndex
+	material.SphereMode = m.SphereMode
+	material.ToonSharingFlag = m.ToonSharingFlag
+	material.ToonTextureIndex = m.ToonTextureIndex
+	material.VerticesCount = m.VerticesCount
+	return material
+}
+
+// newBoneJson はボーンを変換する。
+func newBoneJson(bone *model.Bone) boneJson {
+	encoded := boneJson{
+		Name:             bone.Name(),
+		EnglishName:      bone.EnglishName,
+		Position:         bone.Position,
+		ParentIndex:      bone.ParentIndex,
+		Layer:            bone.Layer,
+		BoneFlag:         bone.BoneFlag,
+		TailPosition:     bone.TailPosition,
+		TailIndex:        bone.TailIndex,
+		EffectIndex:      bone.EffectIndex,
+		EffectFactor:     bone.EffectFactor,
+		FixedAxis:        bone.FixedAxis,
+		LocalAxisX:       bone.LocalAxisX,
+		LocalAxisZ:       bone.LocalAxisZ,
+		EffectorKey:      bone.EffectorKey,
+		DisplaySlotIndex: bone.DisplaySlotIndex,
+		IsSystem:         bone.IsSystem,
+	}
+	if bone.Ik != nil {
+		encoded.Ik = &ikJson{
+			BoneIndex:    bone.Ik.BoneIndex,
+			LoopCount:    bone.Ik.LoopCount,
+			UnitRotation: bone.Ik.UnitRotation,
+			Links:        make([]ikLinkJson, 0, len(bone.Ik.Links)),
+		}
+		for _, link := range bone.Ik.Links {
+			encoded.Ik.Links = append(encoded.Ik.Links, ikLinkJson{
+				BoneIndex:          link.BoneIndex,
+				AngleLimit:         link.AngleLimit,
+				MinAngleLimit:      link.MinAngleLimit,
+				MaxAngleLimit:      link.MaxAngleLimit,
+				LocalAngleLimit:    link.LocalAngleLimit,
+				LocalMinAngleLimit: link.LocalMinAngleLimit,
+				LocalMaxAngleLimit: link.LocalMaxAngleLimit,
+			})
+		}
+	}
+	return encoded
+}
+
+// toBone はボーンを復元する。
+func (b boneJson) toBone() *model.Bone {
+	bone := &model.Bone{
+		EnglishName:      b.EnglishName,
+		Position:         b.Position,
+		ParentIndex:      b.ParentIndex,
+		Layer:            b.Layer,
+		BoneFlag:         b.BoneFlag,
+		TailPosition:     b.TailPosition,
+		TailIndex:        b.TailIndex,
+		EffectIndex:      b.EffectIndex,
+		EffectFactor:     b.EffectFactor,
+		FixedAxis:        b.FixedAxis,
+		LocalAxisX:       b.LocalAxisX,
+		LocalAxisZ:       b.LocalAxisZ,
+		EffectorKey:      b.EffectorKey,
+		DisplaySlotIndex: b.DisplaySlotIndex,
+		IsSystem:         b.IsSystem,
+	}
+	bone.SetName(b.Name)
+	if b.Ik != nil {
+		bone.Ik = &model.Ik{
+			BoneIndex:    b.Ik.BoneIndex,
+			LoopCount:    b.Ik.LoopCount,
+			UnitRotation: b.Ik.UnitRotation,
+			Links:        make([]model.IkLink, 0, len(b.Ik.Links)),
+		}
+		for _, link := range b.Ik.Links {
+			bone.Ik.Links = append(bone.Ik.Links, model.IkLink{
+				BoneIndex:          link.BoneIndex,
+				AngleLimit:         link.AngleLimit,
+				MinAngleLimit:      link.MinAngleLimit,
+				MaxAngleLimit:      link.MaxAngleLimit,
+				LocalAngleLimit:    link.LocalAngleLimit,
+				LocalMinAngleLimit: link.LocalMinAngleLimit,
+				LocalMaxAngleLimit: link.LocalMaxAngleLimit,
+			})
+		}
+	}
+	return bone
+}
+
+// newMorphJson はモーフを変換する。
+func newMorphJson(morph *model.Morph) (morphJson, error) {
+	encoded := morphJson{
+		Name:        morph.Name(),
+		EnglishName: morph.EnglishName,
+		Panel:       morph.Panel,
+		MorphType:   morph.MorphType,
+		Offsets:     make([]morphOffsetJson, 0, len(morph.Offsets)),
+		DisplaySlot: morph.DisplaySlot,
+		IsSystem:    morph.IsSystem,
+	}
+	for _, offset := range morph.Offsets {
+		var encodedOffset morphOffsetJson
+		switch o := offset.(type) {
+		case *model.VertexMorphOffset:
+			encodedOffset.Vertex = &vertexMorphOffsetJson{VertexIndex: o.VertexIndex, Position: o.Position}
+		case *model.UvMorphOffset:
+			encodedOffset.Uv = &uvMorphOffsetJson{VertexIndex: o.VertexIndex, Uv: o.Uv, UvType: o.UvType}
+		case *model.BoneMorphOffset:
+			encodedOffset.Bone = &boneMorphOffsetJson{
+				BoneIndex: o.BoneIndex,
+				Position:  o.Position,
+				Rotation:  newQuaternionJson(o.Rotation),
+			}
+		case *model.GroupMorphOffset:
+			encodedOffset.Group = &groupMorphOffsetJson{MorphIndex: o.MorphIndex, MorphFactor: o.MorphFactor}
+		case *model.MaterialMorphOffset:
+			encodedOffset.Material = &materialMorphOffsetJson{
+				MaterialIndex:       o.MaterialIndex,
+				CalcMode:            o.CalcMode,
+				Diffuse:             o.Diffuse,
+				Specular:            o.Specular,
+				Ambient:             o.Ambient,
+				Edge:                o.Edge,
+				EdgeSize:            o.EdgeSize,
+				TextureFactor:       o.TextureFactor,
+				SphereTextureFactor: o.SphereTextureFactor,
+				ToonTextureFactor:   o.ToonTextureFactor,
+			}
+		default:
+			return morphJson{}, io_common.NewIoEncodeFailed("モーフオフセットの種別が未対応です: %s", nil, morph.Name())
+		}
+		encoded.Offsets = append(encoded.Offsets, encodedOffset)
+	}
+	return encoded, nil
+}
+
+// toMorph はモーフを復元する。オフセットが不正な場合は false を返す。
+func (m morphJson) toMorph() (*model.Morph, bool) {
+	morph := &model.Morph{
+		EnglishName: m.EnglishName,
+		Panel:       m.Panel,
+		MorphType:   m.MorphType,
+		Offsets:     make([]model.IMorphOffset, 0, len(m.Offsets)),
+		DisplaySlot: m.DisplaySlot,
+		IsSystem:    m.IsSystem,
+	}
+	morph.SetName(m.Name)
+	for _, offset := range m.Offsets {
+		decoded := offset.toOffset()
+		if decoded == nil {
+			return nil, false
+		}
+		morph.Offsets = append(morph.Offsets, decoded)
+	}
+	return morph, true
+}
+
+// toOffset は設定されているオフセットを返す。
+func (o morphOffsetJson) toOffset() model.IMorphOffset {
+	switch {
+	case o.Vertex != nil:
+		return &model.VertexMorphOffset{VertexIndex: o.Vertex.VertexIndex, Position: o.Vertex.Position}
+	case o.Uv != nil:
+		return &model.UvMorphOffset{VertexIndex: o.Uv.VertexIndex, Uv: o.Uv.Uv, UvType: o.Uv.UvType}
+	case o.Bone != nil:
+		return &model.BoneMorphOffset{
+			BoneIndex: o.Bone.BoneIndex,
+			Position:  o.Bone.Position,
+			Rotation:  o.Bone.Rotation.toQuaternion(),
+		}
+	case o.Group != nil:
+		return &model.GroupMorphOffset{MorphIndex: o.Group.MorphIndex, MorphFactor: o.Group.MorphFactor}
+	case o.Material != nil:
+		return &model.MaterialMorphOffset{
+			MaterialIndex:       o.Material.MaterialIndex,
+			CalcMode:            o.Material.CalcMode,
+			Diffuse:             o.Material.Diffuse,
+			Specular:            o.Material.Specular,
+			Ambient:             o.Material.Ambient,
+			Edge:                o.Material.Edge,
+			EdgeSize:            o.Material.EdgeSize,
+			TextureFactor:       o.Material.TextureFactor,
+			SphereTextureFactor: o.Material.SphereTextureFactor,
+			ToonTextureFactor:   o.Material.ToonTextureFactor,
+		}
+	default:
+		return nil
+	}
+}
+
+// newDisplaySlotJson は表示枠を変換する。
+func newDisplaySlotJson(slot *model.DisplaySlot) displaySlotJson {
+	encoded := displaySlotJson{
+		Name:        slot.Name(),
+		EnglishName: slot.EnglishName,
+		SpecialFlag: slot.SpecialFlag,
+		References:  make([]displayRefJson, 0, len(slot.References)),
+	}
+	for _, reference := range slot.References {
+		encoded.References = append(encoded.References, displayRefJson{
+			DisplayType:  reference.DisplayType,
+			DisplayIndex: reference.DisplayIndex,
+		})
+	}
+	return encoded
+}
+
+// toDisplaySlot は表示枠を復元する。
+func (d displaySlotJson) toDisplaySlot() *model.DisplaySlot {
+	slot := &model.DisplaySlot{
+		EnglishName: d.EnglishName,
+		SpecialFlag: d.SpecialFlag,
+		References:  make([]model.Reference, 0, len(d.References)),
+	}
+	slot.SetName(d.Name)
+	for _, reference := range d.References {
+		slot.References = append(slot.References, model.Reference{
+			DisplayType:  reference.DisplayType,
+			DisplayIndex: reference.DisplayIndex,
+		})
+	}
+	return slot
+}
+
+// newRigidBodyJson は剛体を変換する。
+func newRigidBodyJson(rigidBody *model.RigidBody) rigidBodyJson {
+	return rigidBodyJson{
+		Name:           rigidBody.Name(),
+		EnglishName:    rigidBody.EnglishName,
+		BoneIndex:      rigidBody.BoneIndex,
+		CollisionGroup: rigidBody.CollisionGroup.Group,
+		CollisionMask:  rigidBody.CollisionGroup.Mask,
+		Shape:          rigidBody.Shape,
+		Size:           rigidBody.Size,
+		Position:       rigidBody.Position,
+		Rotation:       rigidBody.Rotation,
+		Mass:           rigidBody.Param.Mass,
+		LinearDamping:  rigidBody.Param.LinearDamping,
+		AngularDamping: rigidBody.Param.AngularDamping,
+		Restitution:    rigidBody.Param.Restitution,
+		Friction:       rigidBody.Param.Friction,
+		PhysicsType:    rigidBody.PhysicsType,
+		IsSystem:       rigidBody.IsSystem,
+	}
+}
+
+// toRigidBody は剛体を復元する。
+func (r rigidBodyJson) toRigidBody() *model.RigidBody {
+	rigidBody := &model.RigidBody{
+		EnglishName:    r.EnglishName,
+		BoneIndex:      r.BoneIndex,
+		CollisionGroup: model.CollisionGroup{Group: r.CollisionGroup, Mask: r.CollisionMask},
+		Shape:          r.Shape,
+		Size:           r.Size,
+		Position:       r.Position,
+		Rotation:       r.Rotation,
+		Param: model.RigidBodyParam{
+			Mass:           r.Mass,
+			LinearDamping:  r.LinearDamping,
+			AngularDamping: r.AngularDamping,
+			Restitution:    r.Restitution,
+			Friction:       r.Friction,
+		},
+		PhysicsType: r.PhysicsType,
+		IsSystem:    r.IsSystem,
+	}
+	rigidBody.SetName(r.Name)
+	return rigidBody
+}
+
+// newJointJson はジョイントを変換する。
+func newJointJson(joint *model.Joint) jointJson {
+	return jointJson{
+		Name:                      joint.Name(),
+		EnglishName:               joint.EnglishName,
+		RigidBodyIndexA:           joint.RigidBodyIndexA,
+		RigidBodyIndexB:           joint.RigidBodyIndexB,
+		Position:                  joint.Param.Position,
+		Rotation:                  joint.Param.Rotation,
+		TranslationLimitMin:       joint.Param.TranslationLimitMin,
+		TranslationLimitMax:       joint.Param.TranslationLimitMax,
+		RotationLimitMin:          joint.Param.RotationLimitMin,
+		RotationLimitMax:          joint.Param.RotationLimitMax,
+		SpringConstantTranslation: joint.Param.SpringConstantTranslation,
+		SpringConstantRotation:    joint.Param.SpringConstantRotation,
+	}
+}
+
+// toJoint はジョイントを復元する。
+func (j jointJson) toJoint() *model.Joint {
+	joint := &model.Joint{
+		EnglishName:     j.EnglishName,
+		RigidBodyIndexA: j.RigidBodyIndexA,
+		RigidBodyIndexB: j.RigidBodyIndexB,
+		Param: model.JointParam{
+			Position:                  j.Position,
+			Rotation:                  j.Rotation,
+			TranslationLimitMin:       j.TranslationLimitMin,
+			TranslationLimitMax:       j.TranslationLimitMax,
+			RotationLimitMin:          j.RotationLimitMin,
+			RotationLimitMax:          j.RotationLimitMax,
+			SpringConstantTranslation: j.SpringConstantTranslation,
+			SpringConstantRotation:    j.SpringConstantRotation,
+		},
+	}
+	joint.SetName(j.Name)
+	return joint
+}
+
+// cloneSlice は nil を保ったままスライスを複製する。
+func cloneSlice[T any](values []T) []T {
+	if values == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(values)), values...)
+}
+
+// toDeform はデフォーム種別に応じてデフォームを復元する。
+func (d deformJson) toDeform(deformType model.DeformType) (model.IDeform, error) {
+	switch deformType {
+	case model.BDEF1:
+		if len(d.Bones) < 1 {
+			return nil, io_common.NewIoParseFailed("BDEF1のボーン数が不足しています", nil)
+		}
+		return model.NewBdef1(d.Bones[0]), nil
+	case model.BDEF2, model.SDEF:
+		if len(d.Bones) < 2 || len(d.Weights) < 1 {
+			return nil, io_common.NewIoParseFailed("BDEF2/SDEFのボーン数が不足しています", nil)
+		}
+		if deformType == model.BDEF2 {
+			return model.NewBdef2(d.Bones[0], d.Bones[1], d.Weights[0]), nil
+		}
+		sdef := model.NewSdef(d.Bones[0], d.Bones[1], d.Weights[0])
+		if d.SdefC != nil {
+			sdef.SdefC = *d.SdefC
+		}
+		if d.SdefR0 != nil {
+			sdef.SdefR0 = *d.SdefR0
+		}
+		if d.SdefR1 != nil {
+			sdef.SdefR1 = *d.SdefR1
+		}
+		return sdef, nil
+	case model.BDEF4:
+		if len(d.Bones) < 4 || len(d.Weights) < 4 {
+			return nil, io_common.NewIoParseFailed("BDEF4のボーン数が不足しています", nil)
+		}
+		return model.NewBdef4(
+			[4]int{d.Bones[0], d.Bones[1], d.Bones[2], d.Bones[3]},
+			[4]float64{d.Weights[0], d.Weights[1], d.Weights[2], d.Weights[3]},
+		), nil
+	default:
+		return nil, io_common.NewIoParseFailed("デフォーム種別が不正です: %d", nil, int(deformType))
+	}
+}
+
+// compactVrmExtensions は保存時の字下げで整形された未解釈のVRM拡張を、元の詰めた表現へ戻す。
+func compactVrmExtensions(vrmData *vrm.VrmData) error {
+	if vrmData == nil {
+		return nil
+	}
+	for key, raw := range vrmData.RawExtensions {
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, raw); err != nil {
+			return err
+		}
+		vrmData.RawExtensions[key] = json.RawMessage(compacted.Bytes())
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// 指示: miu200521358
+package pmxjson
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+)
+
+// PMX_JSON_EXT はPMX JSONの拡張子。
+const PMX_JSON_EXT = ".pmx.json"
+
+// PmxJsonRepository はPMX JSON入出力を表す。
+type PmxJsonRepository struct{}
+
+// NewPmxJsonRepository はPmxJsonRepositoryを生成する。
+func NewPmxJsonRepository() *PmxJsonRepository {
+	return &PmxJsonRepository{}
+}
+
+// CanLoad は拡張子に応じて読み込み可否を判定する。
+func (r *PmxJsonRepository) CanLoad(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), PMX_JSON_EXT)
+}
+
+// InferName はパスから表示名を推定する。
+func (r *PmxJsonRepository) InferName(path string) string {
+	base := filepath.Base(path)
+	if r.CanLoad(base) {
+		return base[:len(base)-len(PMX_JSON_EXT)]
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Load はPMX JSONを読み込む。
+func (r *PmxJsonRepository) Load(path string) (hashable.IHashable, error) {
+	return r.LoadFS(nil, path)
+}
+
+// LoadFS はfs.FS上のPMX JSONを読み込む。fsys が nil の場合はOSのファイルを読み込む。
+func (r *PmxJsonRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
+	if !r.CanLoad(path) {
+		return nil, io_common.NewIoExtInvalid(path, nil)
+	}
+	file, err := io_common.OpenFile(fsys, path, "PMX JSONファイルのオープンに失敗しました")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var doc pmxJsonDocument
+	if err := json.NewDecoder(file).Decode(&doc); err != nil {
+		return nil, io_common.NewIoParseFailed("PMX JSONの解析に失敗しました", err)
+	}
+	modelData, err := doc.toModel()
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, io_common.NewIoParseFailed("PMX JSONファイル情報の取得に失敗しました", err)
+	}
+	modelData.SetPath(path)
+	modelData.SetFileModTime(info.ModTime().UnixNano())
+	modelData.UpdateHash()
+	return modelData, nil
+}
+
+// Save はPMX JSONを保存する。
+func (r *PmxJsonRepository) Save(path string, data hashable.IHashable, opts io_common.SaveOptions) error {
+	modelData, ok := data.(*model.PmxModel)
+	if !ok {
+		return io_common.NewIoEncodeFailed("PMX JSON保存対象が不正です", nil)
+	}
+	savePath := path
+	if savePath == "" {
+		savePath = modelData.Path()
+	}
+	if savePath == "" {
+		return io_common.NewIoSaveFailed("保存先パスが空です", nil)
+	}
+
+	file, err := os.Create(savePath)
+	if err != nil {
+		return io_common.NewIoSaveFailed("PMX JSONファイルの作成に失敗しました", err)
+	}
+	defer file.Close()
+
+	if err := r.SaveTo(file, modelData, opts); err != nil {
+		return err
+	}
+	modelData.SetPath(savePath)
+	modelData.UpdateHash()
+	return nil
+}
+
+// SaveTo はPMX JSONをio.Writerへ書き込む。
+func (r *PmxJsonRepository) SaveTo(w io.Writer, data hashable.IHashable, opts io_common.SaveOptions) error {
+	modelData, ok := data.(*model.PmxModel)
+	if !ok {
+		return io_common.NewIoEncodeFailed("PMX JSON保存対象が不正です", nil)
+	}
+	doc, err := newPmxJsonDocument(modelData)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return io_common.NewIoEncodeFailed("PMX JSONの書き込みに失敗しました", err)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+// 指示: miu200521358
+package pmxjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_model/pmx"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/vrm"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// newRoundTripModel は全要素種別を含むモデルを生成する。
+func newRoundTripModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	m.SetName("往復確認")
+	m.EnglishName = "RoundTrip"
+	m.Comment = "コメント\n2行目"
+
+	deforms := []model.IDeform{
+		model.NewBdef1(0),
+		model.NewBdef2(0, 1, 0.25),
+		model.NewBdef4([4]int{0, 1, 0, 1}, [4]float64{0.4, 0.3, 0.2, 0.1}),
+		model.NewSdef(0, 1, 0.75),
+	}
+	for i, deform := range deforms {
+		if sdef, ok := deform.(*model.Sdef); ok {
+			sdef.SdefC = vec3(0.5, 0, 0)
+			sdef.SdefR0 = vec3(0, 1, 0)
+			sdef.SdefR1 = vec3(0, 0, -1)
+		}
+		m.Vertices.AppendRaw(&model.Vertex{
+			Position:    vec3(float64(i)*0.125, 1.5, -0.25),
+			Normal:      vec3(0, 1, 0),
+			Uv:          mmath.Vec2{X: 0.5, Y: float64(i) * 0.25},
+			ExtendedUvs: []mmath.Vec4{{X: 1, Y: 2, Z: 3, W: 4}},
+			DeformType:  deform.DeformType(),
+			Deform:      deform,
+			EdgeFactor:  1,
+		})
+	}
+	m.Faces.AppendRaw(&model.Face{VertexIndexes: [3]int{0, 1, 2}})
+	m.Faces.AppendRaw(&model.Face{VertexIndexes: [3]int{1, 2, 3}})
+
+	texture := &model.Texture{}
+	texture.SetName("tex\\body.png")
+	texture.SetValid(true)
+	m.Textures.AppendRaw(texture)
+
+	material := model.NewMaterial()
+	material.SetName("体")
+	material.Memo = "メモ"
+	material.Diffuse = mmath.Vec4{X: 1, Y: 0.5, Z: 0.25, W: 1}
+	material.TextureIndex = 0
+	material.SphereTextureIndex = -1
+	material.ToonTextureIndex = 1
+	material.ToonSharingFlag = model.TOON_SHARING_SHARING
+	material.VerticesCount = 6
+	m.Materials.AppendRaw(material)
+
+	center := model.NewBoneByName("センター")
+	center.ParentIndex = -1
+	center.TailIndex = -1
+	center.EffectIndex = -1
+	center.BoneFlag = model.BONE_FLAG_CAN_ROTATE | model.BONE_FLAG_CAN_TRANSLATE | model.BONE_FLAG_IS_VISIBLE | model.BONE_FLAG_CAN_MANIPULATE
+	m.Bones.AppendRaw(center)
+	ik := model.NewBoneByName("右足ＩＫ")
+	ik.Position = vec3(-1, 1, 0)
+	ik.ParentIndex = 0
+	ik.TailIndex = -1
+	ik.EffectIndex = -1
+	ik.BoneFlag = model.BONE_FLAG_IS_IK | model.BONE_FLAG_CAN_ROTATE | model.BONE_FLAG_IS_VISIBLE
+	ik.Ik = &model.Ik{
+		BoneIndex:    0,
+		LoopCount:    40,
+		UnitRotation: vec3(0.5, 0, 0),
+		Links: []model.IkLink{{
+			BoneIndex:     0,
+			AngleLimit:    true,
+			MinAngleLimit: vec3(-1, 0, 0),
+			MaxAngleLimit: vec3(1, 0, 0),
+		}},
+	}
+	m.Bones.AppendRaw(ik)
+
+	offsets := [][]model.IMorphOffset{
+		{&model.VertexMorphOffset{VertexIndex: 1, Position: vec3(0, 0.5, 0)}},
+		{&model.UvMorphOffset{VertexIndex: 2, Uv: mmath.Vec4{X: 0.1}, UvType: model.MORPH_TYPE_UV}},
+		{&model.BoneMorphOffset{BoneIndex: 1, Position: vec3(0, 0, 1), Rotation: mmath.NewQuaternionFromDegrees(10, 20, 30)}},
+		{&model.MaterialMorphOffset{MaterialIndex: 0, CalcMode: model.CALC_MODE_ADDITION, Diffuse: mmath.Vec4{W: -1}}},
+		{&model.GroupMorphOffset{MorphIndex: 0, MorphFactor: 0.5}},
+	}
+	for i, morphOffsets := range offsets {
+		morph := &model.Morph{
+			Panel:     model.MORPH_PANEL_OTHER_LOWER_RIGHT,
+			MorphType: morphOffsets[0].MorphType(),
+			Offsets:   morphOffsets,
+		}
+		morph.SetName(string(rune('あ' + i)))
+		m.Morphs.AppendRaw(morph)
+	}
+
+	m.CreateDefaultDisplaySlots()
+	root, _ := m.DisplaySlots.Get(0)
+	root.References = append(root.References, model.Reference{DisplayType: model.DISPLAY_TYPE_BONE, DisplayIndex: 0})
+	faceSlot, _ := m.DisplaySlots.Get(1)
+	faceSlot.References = append(faceSlot.References, model.Reference{DisplayType: model.DISPLAY_TYPE_MORPH, DisplayIndex: 0})
+
+	for i, name := range []string{"剛体A", "剛体B"} {
+		rigidBody := &model.RigidBody{
+			BoneIndex:      0,
+			CollisionGroup: model.CollisionGroup{Group: byte(i), Mask: 0xfffe},
+			Shape:          model.SHAPE_CAPSULE,
+			Size:           vec3(0.5, 2, 0),
+			Position:       vec3(0, float64(i), 0),
+			Param:          model.RigidBodyParam{Mass: 1, LinearDamping: 0.5, AngularDamping: 0.5, Friction: 0.5},
+			PhysicsType:    model.PHYSICS_TYPE_DYNAMIC,
+		}
+		rigidBody.SetName(name)
+		m.RigidBodies.AppendRaw(rigidBody)
+	}
+	joint := &model.Joint{RigidBodyIndexA: 0, RigidBodyIndexB: 1}
+	joint.SetName("ジョイント")
+	joint.Param.RotationLimitMin = vec3(-0.5, 0, 0)
+	joint.Param.RotationLimitMax = vec3(0.5, 0, 0)
+	m.Joints.AppendRaw(joint)
+
+	center0 := 0
+	m.VrmData = &vrm.VrmData{
+		Version:        vrm.VRM_VERSION_1,
+		Profile:        vrm.VRM_PROFILE_VROID,
+		AssetGenerator: "UniGLTF",
+		Nodes: []vrm.Node{
+			{Index: 0, Name: "Hips", ParentIndex: -1, Children: []int{1}, Translation: vec3(0, 1, 0)},
+			{Index: 1, Name: "Spine", ParentIndex: 0, Children: []int{}, Translation: vec3(0, 0.125, 0)},
+		},
+		Vrm0: &vrm.Vrm0Data{
+			ExporterVersion: "UniVRM-0.99",
+			Meta:            &vrm.Vrm0Meta{Title: "往復確認", Version: "1.0", Author: "作者"},
+			Humanoid: &vrm.Vrm0Humanoid{HumanBones: []vrm.Vrm0HumanBone{
+				{Bone: vrm.VRM0_HUMAN_BONE_HIPS, Node: 0},
+				{Bone: vrm.VRM0_HUMAN_BONE_SPINE, Node: 1},
+			}},
+		},
+		Vrm1: &vrm.Vrm1Data{
+			SpecVersion: "1.0",
+			Meta:        &vrm.Vrm1Meta{Name: "往復確認", Version: "1.0", Authors: []string{"作者"}},
+			Humanoid: &vrm.Vrm1Humanoid{HumanBones: map[string]vrm.Vrm1HumanBone{
+				vrm.VRM1_HUMAN_BONE_HIPS:  {Node: 0},
+				vrm.VRM1_HUMAN_BONE_SPINE: {Node: 1},
+			}},
+			SpringBone: &vrm.Vrm1SpringBone{
+				SpecVersion: "1.0",
+				Colliders: []vrm.Vrm1SpringCollider{{
+					Node:  0,
+					Shape: vrm.Vrm1SpringColliderShape{Sphere: &vrm.Vrm1SpringColliderSphere{Offset: vec3(0, 0.5, 0), Radius: 0.25}},
+					Extended: &vrm.Vrm1SpringExtendedCollider{
+						SpecVersion: "1.0",
+						Shape: vrm.Vrm1SpringExtendedColliderShape{
+							Plane: &vrm.Vrm1SpringExtendedPlaneCollider{Offset: vec3(0, 0, 0), Normal: vec3(0, 1, 0)},
+						},
+					},
+				}},
+				ColliderGroups: []vrm.Vrm1SpringColliderGroup{{Name: "体", Colliders: []int{0}}},
+				Springs: []vrm.Vrm1Spring{{
+					Name:           "髪",
+					Joints:         []vrm.Vrm1SpringJoint{{Node: 1, HitRadius: 0.125, Stiffness: 1, GravityDir: vec3(0, -1, 0), DragForce: 0.5}},
+					ColliderGroups: []int{0},
+					Center:         &center0,
+				}},
+			},
+		},
+		RawExtensions: map[string]json.RawMessage{"VRMC_materials_mtoon": json.RawMessage(`{"specVersion":"1.0"}`)},
+	}
+	return m
+}
+
+func TestPmxJsonRepository_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	binaryRepository := pmx.NewPmxRepository()
+	jsonRepository := NewPmxJsonRepository()
+
+	binaryPath := filepath.Join(dir, "model.pmx")
+	if err := binaryRepository.Save(binaryPath, newRoundTripModel(), io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	original, err := os.ReadFile(binaryPath)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	loaded, err := binaryRepository.Load(binaryPath)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	jsonPath := filepath.Join(dir, "model.pmx.json")
+	if err := jsonRepository.Save(jsonPath, loaded, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	reloaded, err := jsonRepository.Load(jsonPath)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	reloadedModel := reloaded.(*model.PmxModel)
+	if reloadedModel.Name() != "往復確認" || reloadedModel.Path() != jsonPath {
+		t.Errorf("Expected name and path to be restored, got %q %q", reloadedModel.Name(), reloadedModel.Path())
+	}
+
+	var roundTripped bytes.Buffer
+	if err := binaryRepository.SaveTo(&roundTripped, reloadedModel, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	if !bytes.Equal(original, roundTripped.Bytes()) {
+		t.Fatalf("Expected binary to be equal after JSON round trip: %d bytes -> %d bytes", len(original), roundTripped.Len())
+	}
+
+	// JSONからJSONへの再保存も同一内容になる。
+	firstJson, _ := os.ReadFile(jsonPath)
+	var secondJson bytes.Buffer
+	if err := jsonRepository.SaveTo(&secondJson, reloadedModel, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	if !bytes.Equal(firstJson, secondJson.Bytes()) {
+		t.Fatalf("Expected JSON output to be stable")
+	}
+
+	// PMXバイナリには保存されないVRM情報も、JSONからモデルへ戻した際にそのまま復元される。
+	source := newRoundTripModel()
+	vrmPath := filepath.Join(dir, "vrm.pmx.json")
+	if err := jsonRepository.Save(vrmPath, source, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	vrmReloaded, err := jsonRepository.Load(vrmPath)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	if got := vrmReloaded.(*model.PmxModel).VrmData; !reflect.DeepEqual(source.VrmData, got) {
+		t.Fatalf("Expected VrmData to be restored: %+v -> %+v", source.VrmData, got)
+	}
+}
+
+func TestPmxJsonRepository_SaveTo_Keys(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewPmxJsonRepository().SaveTo(&buf, newRoundTripModel(), io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	for _, key := range []string{"format", "version", "vertices", "faces", "textures", "materials", "bones", "morphs", "displaySlots", "rigidBodies", "joints", "vrmData"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("Expected key %q", key)
+		}
+	}
+
+	// ボーンモーフの回転はクォータニオンの内部表現ではなく x/y/z/w で出力される。
+	var morphs []struct {
+		Offsets []map[string]map[string]json.RawMessage `json:"offsets"`
+	}
+	if err := json.Unmarshal(doc["morphs"], &morphs); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	var rotation map[string]float64
+	if err := json.Unmarshal(morphs[2].Offsets[0]["bone"]["rotation"], &rotation); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	if _, ok := rotation["w"]; !ok || len(rotation) != 4 {
+		t.Errorf("Expected rotation to have x/y/z/w, got %v", rotation)
+	}
+}
+
+func TestPmxJsonRepository_Load_InvalidFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "other.pmx.json")
+	if err := os.WriteFile(path, []byte(`{"format":"other","version":1}`), 0o644); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	r := NewPmxJsonRepository()
+	if _, err := r.Load(path); merr.ExtractErrorID(err) != "14103" {
+		t.Fatalf("Expected error id 14103, got %v", err)
+	}
+	if r.InferName(path) != "other" || r.CanLoad("model.json") {
+		t.Errorf("Expected .pmx.json handling, got %q", r.InferName(path))
+	}
+}
+
+func vec3(x, y, z float64) mmath.Vec3 {
+	return mmath.Vec3{Vec: r3.Vec{X: x, Y: y, Z: z}}
+}
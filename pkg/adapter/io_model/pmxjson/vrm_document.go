@@ -0,0 +1,426 @@
+// 指示: miu200521358
+package pmxjson
+
+import (
+	"encoding/json"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/vrm"
+)
+
+// vrmDataJson はVRM固有情報を表す。
+type vrmDataJson struct {
+	Version        vrm.VrmVersion             `json:"version"`
+	Profile        vrm.VrmProfile             `json:"profile"`
+	AssetGenerator string                     `json:"assetGenerator"`
+	Nodes          []vrmNodeJson              `json:"nodes"`
+	Vrm0           *vrm0DataJson              `json:"vrm0,omitempty"`
+	Vrm1           *vrm1DataJson              `json:"vrm1,omitempty"`
+	RawExtensions  map[string]json.RawMessage `json:"rawExtensions"`
+}
+
+// vrmNodeJson はglTFノードを表す。
+type vrmNodeJson struct {
+	Index       int        `json:"index"`
+	Name        string     `json:"name"`
+	ParentIndex int        `json:"parentIndex"`
+	Children    []int      `json:"children"`
+	Translation mmath.Vec3 `json:"translation"`
+}
+
+// vrm0DataJson はVRM0拡張を表す。
+type vrm0DataJson struct {
+	ExporterVersion string            `json:"exporterVersion"`
+	Meta            *vrm0MetaJson     `json:"meta,omitempty"`
+	Humanoid        *vrm0HumanoidJson `json:"humanoid,omitempty"`
+}
+
+// vrm0MetaJson はVRM0のメタ情報を表す。
+type vrm0MetaJson struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+	Author  string `json:"author"`
+}
+
+// vrm0HumanoidJson はVRM0のヒューマノイド定義を表す。
+type vrm0HumanoidJson struct {
+	HumanBones []vrm0HumanBoneJson `json:"humanBones"`
+}
+
+// vrm0HumanBoneJson はVRM0のヒューマノイドボーンを表す。
+type vrm0HumanBoneJson struct {
+	Bone string `json:"bone"`
+	Node int    `json:"node"`
+}
+
+// vrm1DataJson はVRM1拡張を表す。
+type vrm1DataJson struct {
+	SpecVersion string              `json:"specVersion"`
+	Meta        *vrm1MetaJson       `json:"meta,omitempty"`
+	Humanoid    *vrm1HumanoidJson   `json:"humanoid,omitempty"`
+	SpringBone  *vrm1SpringBoneJson `json:"springBone,omitempty"`
+}
+
+// vrm1MetaJson はVRM1のメタ情報を表す。
+type vrm1MetaJson struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Authors []string `json:"authors"`
+}
+
+// vrm1HumanoidJson はVRM1のヒューマノイド定義を表す。キーはヒューマノイドボーン名。
+type vrm1HumanoidJson struct {
+	HumanBones map[string]int `json:"humanBones"`
+}
+
+// vrm1SpringBoneJson はVRM1のスプリングボーン拡張を表す。
+type vrm1SpringBoneJson struct {
+	SpecVersion    string                        `json:"specVersion"`
+	Colliders      []vrm1SpringColliderJson      `json:"colliders"`
+	ColliderGroups []vrm1SpringColliderGroupJson `json:"colliderGroups"`
+	Springs        []vrm1SpringJson              `json:"springs"`
+}
+
+// vrm1SpringColliderJson はスプリングボーンのコライダーを表す。
+type vrm1SpringColliderJson struct {
+	Node     int                             `json:"node"`
+	Shape    vrm1SpringShapeJson             `json:"shape"`
+	Extended *vrm1SpringExtendedColliderJson `json:"extended,omitempty"`
+}
+
+// vrm1SpringShapeJson はコライダー形状を表す。Plane は拡張コライダーのみが持つ。
+type vrm1SpringShapeJson struct {
+	Sphere  *vrm1SpringSphereJson  `json:"sphere,omitempty"`
+	Capsule *vrm1SpringCapsuleJson `json:"capsule,omitempty"`
+	Plane   *vrm1SpringPlaneJson   `json:"plane,omitempty"`
+}
+
+// vrm1SpringSphereJson は球コライダーを表す。Inside は拡張コライダーのみが持つ。
+type vrm1SpringSphereJson struct {
+	Offset mmath.Vec3 `json:"offset"`
+	Radius float64    `json:"radius"`
+	Inside bool       `json:"inside,omitempty"`
+}
+
+// vrm1SpringCapsuleJson はカプセルコライダーを表す。Inside は拡張コライダーのみが持つ。
+type vrm1SpringCapsuleJson struct {
+	Offset mmath.Vec3 `json:"offset"`
+	Radius float64    `json:"radius"`
+	Tail   mmath.Vec3 `json:"tail"`
+	Inside bool       `json:"inside,omitempty"`
+}
+
+// vrm1SpringPlaneJson は平面コライダーを表す。
+type vrm1SpringPlaneJson struct {
+	Offset mmath.Vec3 `json:"offset"`
+	Normal mmath.Vec3 `json:"normal"`
+}
+
+// vrm1SpringExtendedColliderJson は拡張コライダーを表す。
+type vrm1SpringExtendedColliderJson struct {
+	SpecVersion string              `json:"specVersion"`
+	Shape       vrm1SpringShapeJson `json:"shape"`
+}
+
+// vrm1SpringColliderGroupJson はコライダーグループを表す。
+type vrm1SpringColliderGroupJson struct {
+	Name      string `json:"name"`
+	Colliders []int  `json:"colliders"`
+}
+
+// vrm1SpringJson はスプリングを表す。
+type vrm1SpringJson struct {
+	Name           string                `json:"name"`
+	Joints         []vrm1SpringJointJson `json:"joints"`
+	ColliderGroups []int                 `json:"colliderGroups"`
+	Center         *int                  `json:"center,omitempty"`
+}
+
+// vrm1SpringJointJson はスプリングのジョイントを表す。
+type vrm1SpringJointJson struct {
+	Node         int        `json:"node"`
+	HitRadius    float64    `json:"hitRadius"`
+	Stiffness    float64    `json:"stiffness"`
+	GravityPower float64    `json:"gravityPower"`
+	GravityDir   mmath.Vec3 `json:"gravityDir"`
+	DragForce    float64    `json:"dragForce"`
+}
+
+// newVrmDataJson はVRM固有情報を変換する。
+func newVrmDataJson(vrmData *vrm.VrmData) *vrmDataJson {
+	if vrmData == nil {
+		return nil
+	}
+	encoded := &vrmDataJson{
+		Version:        vrmData.Version,
+		Profile:        vrmData.Profile,
+		AssetGenerator: vrmData.AssetGenerator,
+		Nodes: mapSlice(vrmData.Nodes, func(node vrm.Node) vrmNodeJson {
+			return vrmNodeJson{
+				Index:       node.Index,
+				Name:        node.Name,
+				ParentIndex: node.ParentIndex,
+				Children:    cloneSlice(node.Children),
+				Translation: node.Translation,
+			}
+		}),
+		RawExtensions: cloneRawExtensions(vrmData.RawExtensions),
+	}
+	if vrm0 := vrmData.Vrm0; vrm0 != nil {
+		encoded.Vrm0 = &vrm0DataJson{ExporterVersion: vrm0.ExporterVersion}
+		if vrm0.Meta != nil {
+			encoded.Vrm0.Meta = &vrm0MetaJson{Title: vrm0.Meta.Title, Version: vrm0.Meta.Version, Author: vrm0.Meta.Author}
+		}
+		if vrm0.Humanoid != nil {
+			encoded.Vrm0.Humanoid = &vrm0HumanoidJson{
+				HumanBones: mapSlice(vrm0.Humanoid.HumanBones, func(bone vrm.Vrm0HumanBone) vrm0HumanBoneJson {
+					return vrm0HumanBoneJson{Bone: bone.Bone, Node: bone.Node}
+				}),
+			}
+		}
+	}
+	if vrm1 := vrmData.Vrm1; vrm1 != nil {
+		encoded.Vrm1 = &vrm1DataJson{SpecVersion: vrm1.SpecVersion}
+		if vrm1.Meta != nil {
+			encoded.Vrm1.Meta = &vrm1MetaJson{
+				Name:    vrm1.Meta.Name,
+				Version: vrm1.Meta.Version,
+				Authors: cloneSlice(vrm1.Meta.Authors),
+			}
+		}
+		if vrm1.Humanoid != nil {
+			encoded.Vrm1.Humanoid = &vrm1HumanoidJson{}
+			if vrm1.Humanoid.HumanBones != nil {
+				encoded.Vrm1.Humanoid.HumanBones = make(map[string]int, len(vrm1.Humanoid.HumanBones))
+				for name, bone := range vrm1.Humanoid.HumanBones {
+					encoded.Vrm1.Humanoid.HumanBones[name] = bone.Node
+				}
+			}
+		}
+		encoded.Vrm1.SpringBone = newVrm1SpringBoneJson(vrm1.SpringBone)
+	}
+	return encoded
+}
+
+// toVrmData はVRM固有情報を復元する。
+func (d *vrmDataJson) toVrmData() *vrm.VrmData {
+	if d == nil {
+		return nil
+	}
+	vrmData := &vrm.VrmData{
+		Version:        d.Version,
+		Profile:        d.Profile,
+		AssetGenerator: d.AssetGenerator,
+		Nodes: mapSlice(d.Nodes, func(node vrmNodeJson) vrm.Node {
+			return vrm.Node{
+				Index:       node.Index,
+				Name:        node.Name,
+				ParentIndex: node.ParentIndex,
+				Children:    cloneSlice(node.Children),
+				Translation: node.Translation,
+			}
+		}),
+		RawExtensions: cloneRawExtensions(d.RawExtensions),
+	}
+	if vrm0 := d.Vrm0; vrm0 != nil {
+		vrmData.Vrm0 = &vrm.Vrm0Data{ExporterVersion: vrm0.ExporterVersion}
+		if vrm0.Meta != nil {
+			vrmData.Vrm0.Meta = &vrm.Vrm0Meta{Title: vrm0.Meta.Title, Version: vrm0.Meta.Version, Author: vrm0.Meta.Author}
+		}
+		if vrm0.Humanoid != nil {
+			vrmData.Vrm0.Humanoid = &vrm.Vrm0Humanoid{
+				HumanBones: mapSlice(vrm0.Humanoid.HumanBones, func(bone vrm0HumanBoneJson) vrm.Vrm0HumanBone {
+					return vrm.Vrm0HumanBone{Bone: bone.Bone, Node: bone.Node}
+				}),
+			}
+		}
+	}
+	if vrm1 := d.Vrm1; vrm1 != nil {
+		vrmData.Vrm1 = &vrm.Vrm1Data{SpecVersion: vrm1.SpecVersion}
+		if vrm1.Meta != nil {
+			vrmData.Vrm1.Meta = &vrm.Vrm1Meta{
+				Name:    vrm1.Meta.Name,
+				Version: vrm1.Meta.Version,
+				Authors: cloneSlice(vrm1.Meta.Authors),
+			}
+		}
+		if vrm1.Humanoid != nil {
+			vrmData.Vrm1.Humanoid = &vrm.Vrm1Humanoid{}
+			if vrm1.Humanoid.HumanBones != nil {
+				vrmData.Vrm1.Humanoid.HumanBones = make(map[string]vrm.Vrm1HumanBone, len(vrm1.Humanoid.HumanBones))
+				for name, node := range vrm1.Humanoid.HumanBones {
+					vrmData.Vrm1.Humanoid.HumanBones[name] = vrm.Vrm1HumanBone{Node: node}
+				}
+			}
+		}
+		vrmData.Vrm1.SpringBone = vrm1.SpringBone.toSpringBone()
+	}
+	return vrmData
+}
+
+// newVrm1SpringBoneJson はスプリングボーン拡張を変換する。
+func newVrm1SpringBoneJson(springBone *vrm.Vrm1SpringBone) *vrm1SpringBoneJson {
+	if springBone == nil {
+		return nil
+	}
+	return &vrm1SpringBoneJson{
+		SpecVersion: springBone.SpecVersion,
+		Colliders: mapSlice(springBone.Colliders, func(collider vrm.Vrm1SpringCollider) vrm1SpringColliderJson {
+			encoded := vrm1SpringColliderJson{
+				Node: collider.Node,
+				Shape: vrm1SpringShapeJson{
+					Sphere:  newVrm1SpringSphereJson(collider.Shape.Sphere),
+					Capsule: newVrm1SpringCapsuleJson(collider.Shape.Capsule),
+				},
+			}
+			if extended := collider.Extended; extended != nil {
+				encoded.Extended = &vrm1SpringExtendedColliderJson{SpecVersion: extended.SpecVersion}
+				if sphere := extended.Shape.Sphere; sphere != nil {
+					encoded.Extended.Shape.Sphere = &vrm1SpringSphereJson{Offset: sphere.Offset, Radius: sphere.Radius, Inside: sphere.Inside}
+				}
+				if capsule := extended.Shape.Capsule; capsule != nil {
+					encoded.Extended.Shape.Capsule = &vrm1SpringCapsuleJson{
+						Offset: capsule.Offset,
+						Radius: capsule.Radius,
+						Tail:   capsule.Tail,
+						Inside: capsule.Inside,
+					}
+				}
+				if plane := extended.Shape.Plane; plane != nil {
+					encoded.Extended.Shape.Plane = &vrm1SpringPlaneJson{Offset: plane.Offset, Normal: plane.Normal}
+				}
+			}
+			return encoded
+		}),
+		ColliderGroups: mapSlice(springBone.ColliderGroups, func(group vrm.Vrm1SpringColliderGroup) vrm1SpringColliderGroupJson {
+			return vrm1SpringColliderGroupJson{Name: group.Name, Colliders: cloneSlice(group.Colliders)}
+		}),
+		Springs: mapSlice(springBone.Springs, func(spring vrm.Vrm1Spring) vrm1SpringJson {
+			return vrm1SpringJson{
+				Name: spring.Name,
+				Joints: mapSlice(spring.Joints, func(joint vrm.Vrm1SpringJoint) vrm1SpringJointJson {
+					return vrm1SpringJointJson{
+						Node:         joint.Node,
+						HitRadius:    joint.HitRadius,
+						Stiffness:    joint.Stiffness,
+						GravityPower: joint.GravityPower,
+						GravityDir:   joint.GravityDir,
+						DragForce:    joint.DragForce,
+					}
+				}),
+				ColliderGroups: cloneSlice(spring.ColliderGroups),
+				Center:         cloneIntPointer(spring.Center),
+			}
+		}),
+	}
+}
+
+// toSpringBone はスプリングボーン拡張を復元する。
+func (s *vrm1SpringBoneJson) toSpringBone() *vrm.Vrm1SpringBone {
+	if s == nil {
+		return nil
+	}
+	return &vrm.Vrm1SpringBone{
+		SpecVersion: s.SpecVersion,
+		Colliders: mapSlice(s.Colliders, func(collider vrm1SpringColliderJson) vrm.Vrm1SpringCollider {
+			decoded := vrm.Vrm1SpringCollider{Node: collider.Node}
+			if sphere := collider.Shape.Sphere; sphere != nil {
+				decoded.Shape.Sphere = &vrm.Vrm1SpringColliderSphere{Offset: sphere.Offset, Radius: sphere.Radius}
+			}
+			if capsule := collider.Shape.Capsule; capsule != nil {
+				decoded.Shape.Capsule = &vrm.Vrm1SpringColliderCapsule{Offset: capsule.Offset, Radius: capsule.Radius, Tail: capsule.Tail}
+			}
+			if extended := collider.Extended; extended != nil {
+				decoded.Extended = &vrm.Vrm1SpringExtendedCollider{SpecVersion: extended.SpecVersion}
+				if sphere := extended.Shape.Sphere; sphere != nil {
+					decoded.Extended.Shape.Sphere = &vrm.Vrm1SpringExtendedSphereCollider{
+						Offset: sphere.Offset,
+						Radius: sphere.Radius,
+						Inside: sphere.Inside,
+					}
+				}
+				if capsule := extended.Shape.Capsule; capsule != nil {
+					decoded.Extended.Shape.Capsule = &vrm.Vrm1SpringExtendedCapsuleCollider{
+						Offset: capsule.Offset,
+						Radius: capsule.Radius,
+						Tail:   capsule.Tail,
+						Inside: capsule.Inside,
+					}
+				}
+				if plane := extended.Shape.Plane; plane != nil {
+					decoded.Extended.Shape.Plane = &vrm.Vrm1SpringExtendedPlaneCollider{Offset: plane.Offset, Normal: plane.Normal}
+				}
+			}
+			return decoded
+		}),
+		ColliderGroups: mapSlice(s.ColliderGroups, func(group vrm1SpringColliderGroupJson) vrm.Vrm1SpringColliderGroup {
+			return vrm.Vrm1SpringColliderGroup{Name: group.Name, Colliders: cloneSlice(group.Colliders)}
+		}),
+		Springs: mapSlice(s.Springs, func(spring vrm1SpringJson) vrm.Vrm1Spring {
+			return vrm.Vrm1Spring{
+				Name: spring.Name,
+				Joints: mapSlice(spring.Joints, func(joint vrm1SpringJointJson) vrm.Vrm1SpringJoint {
+					return vrm.Vrm1SpringJoint{
+						Node:         joint.Node,
+						HitRadius:    joint.HitRadius,
+						Stiffness:    joint.Stiffness,
+						GravityPower: joint.GravityPower,
+						GravityDir:   joint.GravityDir,
+						DragForce:    joint.DragForce,
+					}
+				}),
+				ColliderGroups: cloneSlice(spring.ColliderGroups),
+				Center:         cloneIntPointer(spring.Center),
+			}
+		}),
+	}
+}
+
+// newVrm1SpringSphereJson は球コライダーを変換する。
+func newVrm1SpringSphereJson(sphere *vrm.Vrm1SpringColliderSphere) *vrm1SpringSphereJson {
+	if sphere == nil {
+		return nil
+	}
+	return &vrm1SpringSphereJson{Offset: sphere.Offset, Radius: sphere.Radius}
+}
+
+// newVrm1SpringCapsuleJson はカプセルコライダーを変換する。
+func newVrm1SpringCapsuleJson(capsule *vrm.Vrm1SpringColliderCapsule) *vrm1SpringCapsuleJson {
+	if capsule == nil {
+		return nil
+	}
+	return &vrm1SpringCapsuleJson{Offset: capsule.Offset, Radius: capsule.Radius, Tail: capsule.Tail}
+}
+
+// mapSlice は nil を保ったままスライスの各要素を変換する。
+func mapSlice[S, D any](values []S, fn func(S) D) []D {
+	if values == nil {
+		return nil
+	}
+	mapped := make([]D, 0, len(values))
+	for _, value := range values {
+		mapped = append(mapped, fn(value))
+	}
+	return mapped
+}
+
+// cloneRawExtensions は nil を保ったまま未解釈のVRM拡張を複製する。
+func cloneRawExtensions(extensions map[string]json.RawMessage) map[string]json.RawMessage {
+	if extensions == nil {
+		return nil
+	}
+	cloned := make(map[string]json.RawMessage, len(extensions))
+	for key, raw := range extensions {
+		cloned[key] = append(json.RawMessage(nil), raw...)
+	}
+	return cloned
+}
+
+// cloneIntPointer は整数ポインタを複製する。
+func cloneIntPointer(value *int) *int {
+	if value == nil {
+		return nil
+	}
+	cloned := *value
+	return &cloned
+}
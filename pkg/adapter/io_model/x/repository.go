@@ -4,7 +4,7 @@ package x
 import (
 	"bytes"
 	"io"
-	"os"
+	"io/fs"
 	"path/filepath"
 	"strings"
 
@@ -57,16 +57,18 @@ func (r *XRepository) InferName(path string) string {
 
 // Load はX形式の読み込みを行う。
 func (r *XRepository) Load(path string) (hashable.IHashable, error) {
+	return r.LoadFS(nil, path)
+}
+
+// LoadFS はfs.FS上のX形式の読み込みを行う。fsys が nil の場合はOSのファイルを読み込む。
+func (r *XRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
 	if !r.CanLoad(path) {
 		return nil, io_common.NewIoExtInvalid(path, nil)
 	}
 
-	file, err := os.Open(path)
+	file, err := io_common.OpenFile(fsys, path, "Xファイルのオープンに失敗しました")
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, io_common.NewIoFileNotFound(path, err)
-		}
-		return nil, io_common.NewIoParseFailed("Xファイルのオープンに失敗しました", err)
+		return nil, err
 	}
 	defer file.Close()
 
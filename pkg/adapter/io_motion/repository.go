@@ -2,26 +2,30 @@
 package io_motion
 
 import (
+	"io/fs"
 	"path/filepath"
 	"strings"
 
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_motion/vmd"
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_motion/vmdjson"
 	"github.com/miu200521358/mlib_go/pkg/adapter/io_motion/vpd"
 	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
 )
 
 // VmdVpdRepository はVMD/VPDの切り替えを表す。
 type VmdVpdRepository struct {
-	vmdRepository *vmd.VmdRepository
-	vpdRepository io_common.IFileReader
+	vmdRepository     *vmd.VmdRepository
+	vmdJsonRepository *vmdjson.VmdJsonRepository
+	vpdRepository     io_common.IFileReader
 }
 
 // NewVmdVpdRepository はVmdVpdRepositoryを生成する。
 func NewVmdVpdRepository() *VmdVpdRepository {
 	return &VmdVpdRepository{
-		vmdRepository: vmd.NewVmdRepository(),
-		vpdRepository: vpd.NewVpdRepository(),
+		vmdRepository:     vmd.NewVmdRepository(),
+		vmdJsonRepository: vmdjson.NewVmdJsonRepository(),
+		vpdRepository:     vpd.NewVpdRepository(),
 	}
 }
 
@@ -35,6 +39,9 @@ func (r *VmdVpdRepository) SetVpdRepository(repository io_common.IFileReader) {
 
 // CanLoad は拡張子に応じて読み込み可否を判定する。
 func (r *VmdVpdRepository) CanLoad(path string) bool {
+	if r.vmdJsonRepository.CanLoad(path) {
+		return true
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	switch ext {
 	case ".vmd", ".vpd":
@@ -46,6 +53,9 @@ func (r *VmdVpdRepository) CanLoad(path string) bool {
 
 // InferName はパスから表示名を推定する。
 func (r *VmdVpdRepository) InferName(path string) string {
+	if r.vmdJsonRepository.CanLoad(path) {
+		return r.vmdJsonRepository.InferName(path)
+	}
 	base := filepath.Base(path)
 	ext := filepath.Ext(base)
 	if ext == "" {
@@ -56,6 +66,9 @@ func (r *VmdVpdRepository) InferName(path string) string {
 
 // Load は拡張子に応じて読み込みを行う。
 func (r *VmdVpdRepository) Load(path string) (hashable.IHashable, error) {
+	if r.vmdJsonRepository.CanLoad(path) {
+		return r.vmdJsonRepository.Load(path)
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	if ext == ".vpd" {
 		if r.vpdRepository != nil {
@@ -66,8 +79,26 @@ func (r *VmdVpdRepository) Load(path string) (hashable.IHashable, error) {
 	return r.vmdRepository.Load(path)
 }
 
-// Save はVMDのみを保存する。
+// LoadFS は拡張子に応じてfs.FS上のファイルを読み込む。
+func (r *VmdVpdRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
+	if r.vmdJsonRepository.CanLoad(path) {
+		return r.vmdJsonRepository.LoadFS(fsys, path)
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".vpd" {
+		if repository, ok := r.vpdRepository.(io_common.IFsFileReader); ok {
+			return repository.LoadFS(fsys, path)
+		}
+		return nil, io_common.NewIoFormatNotSupported("VPD形式のfs.FSからの読み込みは未実装です", nil)
+	}
+	return r.vmdRepository.LoadFS(fsys, path)
+}
+
+// Save はVMDまたはVMD JSONを保存する。
 func (r *VmdVpdRepository) Save(path string, data hashable.IHashable, opts io_common.SaveOptions) error {
+	if r.vmdJsonRepository.CanLoad(path) {
+		return r.vmdJsonRepository.Save(path, data, opts)
+	}
 	ext := strings.ToLower(filepath.Ext(path))
 	if ext == ".vpd" {
 		return io_common.NewIoEncodeFailed("VPD形式の保存は未実装です", nil)
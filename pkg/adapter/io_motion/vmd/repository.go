@@ -2,6 +2,7 @@
 package vmd
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -36,15 +37,17 @@ func (r *VmdRepository) InferName(path string) string {
 
 // Load はVMDバイナリを読み込む。
 func (r *VmdRepository) Load(path string) (hashable.IHashable, error) {
+	return r.LoadFS(nil, path)
+}
+
+// LoadFS はfs.FS上のVMDバイナリを読み込む。fsys が nil の場合はOSのファイルを読み込む。
+func (r *VmdRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
 	if !r.CanLoad(path) {
 		return nil, io_common.NewIoExtInvalid(path, nil)
 	}
-	file, err := os.Open(path)
+	file, err := io_common.OpenFile(fsys, path, "VMDファイルのオープンに失敗しました")
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, io_common.NewIoFileNotFound(path, err)
-		}
-		return nil, io_common.NewIoParseFailed("VMDファイルのオープンに失敗しました", err)
+		return nil, err
 	}
 	defer file.Close()
 
@@ -0,0 +1,312 @@
+// 指示: miu200521358
+
+// Package vmdjson はVmdMotionを可逆なJSONテキストとして入出力する。
+//
+// ルートオブジェクトは Format("mlib.vmd.json") と Version を持ち、
+// VmdMotion の全トラックをフィールド名と同じキーで保持する。
+// 名前付きトラック(ボーン・モーフ・剛体・ジョイント)は {Name, Frames} の配列、
+// それ以外のトラックはフレーム配列で表し、登録順・フレーム番号順を保つ。
+// 各フレームは {Frame, Value} で、Value はドメイン型のフィールド名をそのままキーに用いる。
+// ベクトルは x/y/z、クォータニオンは Real(w)/Imag(x)/Jmag(y)/Kmag(z)、
+// 補間曲線は Curves に展開値と VMD の生バイト列(Values、base64)を持つ。
+package vmdjson
+
+import (
+	"encoding/json"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+)
+
+const (
+	// VMD_JSON_FORMAT はVMD JSONの形式識別子。
+	VMD_JSON_FORMAT = "mlib.vmd.json"
+	// VMD_JSON_VERSION はVMD JSONの形式バージョン。
+	VMD_JSON_VERSION = 1
+)
+
+// vmdJsonDocument はVMD JSONのルートを表す。
+type vmdJsonDocument struct {
+	Format                     string
+	Version                    int
+	Name                       string
+	Signature                  string
+	BoneFrames                 []namedFramesJson
+	MorphFrames                []namedFramesJson
+	CameraFrames               []frameJson
+	LightFrames                []frameJson
+	ShadowFrames               []frameJson
+	IkFrames                   []frameJson
+	MaxSubStepsFrames          []frameJson
+	FixedTimeStepFrames        []frameJson
+	GravityFrames              []frameJson
+	PhysicsResetFrames         []frameJson
+	RigidBodyFrames            []namedFramesJson
+	JointFrames                []namedFramesJson
+	WindEnabledFrames          []frameJson
+	WindDirectionFrames        []frameJson
+	WindLiftCoeffFrames        []frameJson
+	WindDragCoeffFrames        []frameJson
+	WindRandomnessFrames       []frameJson
+	WindSpeedFrames            []frameJson
+	WindTurbulenceFreqHzFrames []frameJson
+}
+
+// namedFramesJson は名前付きトラックを表す。
+type namedFramesJson struct {
+	Name   string
+	Frames []frameJson
+}
+
+// frameJson はフレーム番号と値の組を表す。
+type frameJson struct {
+	Frame motion.Frame
+	Value json.RawMessage
+}
+
+// frameTrack はJSON変換で扱うトラックの契約。
+type frameTrack[T any] interface {
+	ForEach(fn func(frame motion.Frame, value T) bool)
+	Append(value T)
+}
+
+// encodeFrames はトラックをフレーム番号順に変換する。
+func encodeFrames[T any](track frameTrack[T]) ([]frameJson, error) {
+	frames := make([]frameJson, 0)
+	var encodeErr error
+	track.ForEach(func(frame motion.Frame, value T) bool {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			encodeErr = io_common.NewIoEncodeFailed("VMD JSONのフレーム変換に失敗しました: %v", err, frame)
+			return false
+		}
+		frames = append(frames, frameJson{Frame: frame, Value: raw})
+		return true
+	})
+	return frames, encodeErr
+}
+
+// decodeFrames はフレーム配列をトラックへ追加する。
+func decodeFrames[T any](track frameTrack[T], frames []frameJson, newFrame func(index motion.Frame) T) error {
+	for _, encoded := range frames {
+		value := newFrame(encoded.Frame)
+		if len(encoded.Value) > 0 {
+			if err := json.Unmarshal(encoded.Value, value); err != nil {
+				return io_common.NewIoParseFailed("VMD JSONのフレーム解析に失敗しました: %v", err, encoded.Frame)
+			}
+		}
+		track.Append(value)
+	}
+	return nil
+}
+
+// namedTrack は名前付きトラックの契約。
+type namedTrack[F frameTrack[T], T any] interface {
+	Names() []string
+	Get(name string) F
+}
+
+// encodeNamedFrames は名前付きトラックを登録順に変換する。
+func encodeNamedFrames[F frameTrack[T], T any](tracks namedTrack[F, T]) ([]namedFramesJson, error) {
+	named := make([]namedFramesJson, 0)
+	for _, name := range tracks.Names() {
+		frames, err := encodeFrames[T](tracks.Get(name))
+		if err != nil {
+			return nil, err
+		}
+		named = append(named, namedFramesJson{Name: name, Frames: frames})
+	}
+	return named, nil
+}
+
+// decodeNamedFrames は名前付きトラックを復元する。trackOf は名前に対応するトラックを返す。
+func decodeNamedFrames[F frameTrack[T], T any](
+	named []namedFramesJson,
+	trackOf func(name string) F,
+	newFrame func(index motion.Frame) T,
+) error {
+	for _, encoded := range named {
+		if err := decodeFrames[T](trackOf(encoded.Name), encoded.Frames, newFrame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newVmdJsonDocument はモーションからJSON文書を生成する。
+func newVmdJsonDocument(motionData *motion.VmdMotion) (*vmdJsonDocument, error) {
+	doc := &vmdJsonDocument{
+		Format:    VMD_JSON_FORMAT,
+		Version:   VMD_JSON_VERSION,
+		Name:      motionData.Name(),
+		Signature: motionData.Signature,
+	}
+	var err error
+	if doc.BoneFrames, err = encodeNamedFrames[*motion.BoneNameFrames, *motion.BoneFrame](motionData.BoneFrames); err != nil {
+		return nil, err
+	}
+	if doc.MorphFrames, err = encodeNamedFrames[*motion.MorphNameFrames, *motion.MorphFrame](motionData.MorphFrames); err != nil {
+		return nil, err
+	}
+	if doc.CameraFrames, err = encodeFrames[*motion.CameraFrame](motionData.CameraFrames); err != nil {
+		return nil, err
+	}
+	if doc.LightFrames, err = encodeFrames[*motion.LightFrame](motionData.LightFrames); err != nil {
+		return nil, err
+	}
+	if doc.ShadowFrames, err = encodeFrames[*motion.ShadowFrame](motionData.ShadowFrames); err != nil {
+		return nil, err
+	}
+	if doc.IkFrames, err = encodeFrames[*motion.IkFrame](motionData.IkFrames); err != nil {
+		return nil, err
+	}
+	if doc.MaxSubStepsFrames, err = encodeFrames[*motion.MaxSubStepsFrame](motionData.MaxSubStepsFrames); err != nil {
+		return nil, err
+	}
+	if doc.FixedTimeStepFrames, err = encodeFrames[*motion.FixedTimeStepFrame](motionData.FixedTimeStepFrames); err != nil {
+		return nil, err
+	}
+	if doc.GravityFrames, err = encodeFrames[*motion.GravityFrame](motionData.GravityFrames); err != nil {
+		return nil, err
+	}
+	if doc.PhysicsResetFrames, err = encodeFrames[*motion.PhysicsResetFrame](motionData.PhysicsResetFrames); err != nil {
+		return nil, err
+	}
+	if doc.RigidBodyFrames, err = encodeNamedFrames[*motion.RigidBodyNameFrames, *motion.RigidBodyFrame](motionData.RigidBodyFrames); err != nil {
+		return nil, err
+	}
+	if doc.JointFrames, err = encodeNamedFrames[*motion.JointNameFrames, *motion.JointFrame](motionData.JointFrames); err != nil {
+		return nil, err
+	}
+	if doc.WindEnabledFrames, err = encodeFrames[*motion.WindEnabledFrame](motionData.WindEnabledFrames); err != nil {
+		return nil, err
+	}
+	if doc.WindDirectionFrames, err = encodeFrames[*motion.WindDirectionFrame](motionData.WindDirectionFrames); err != nil {
+		return nil, err
+	}
+	if doc.WindLiftCoeffFrames, err = encodeFrames[*motion.WindLiftCoeffFrame](motionData.WindLiftCoeffFrames); err != nil {
+		return nil, err
+	}
+	if doc.WindDragCoeffFrames, err = encodeFrames[*motion.WindDragCoeffFrame](motionData.WindDragCoeffFrames); err != nil {
+		return nil, err
+	}
+	if doc.WindRandomnessFrames, err = encodeFrames[*motion.WindRandomnessFrame](motionData.WindRandomnessFrames); err != nil {
+		return nil, err
+	}
+	if doc.WindSpeedFrames, err = encodeFrames[*motion.WindSpeedFrame](motionData.WindSpeedFrames); err != nil {
+		return nil, err
+	}
+	if doc.WindTurbulenceFreqHzFrames, err = encodeFrames[*motion.WindTurbulenceFreqHzFrame](motionData.WindTurbulenceFreqHzFrames); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// toMotion はJSON文書からモーションを復元する。
+func (doc *vmdJsonDocument) toMotion(path string) (*motion.VmdMotion, error) {
+	if doc.Format != VMD_JSON_FORMAT || doc.Version < 1 || doc.Version > VMD_JSON_VERSION {
+		return nil, io_common.NewIoFormatNotSupported("VMD JSONの形式が未対応です: %s %d", nil, doc.Format, doc.Version)
+	}
+	motionData := motion.NewVmdMotion(path)
+	motionData.SetName(doc.Name)
+	motionData.Signature = doc.Signature
+
+	decoders := []func() error{
+		func() error {
+			return decodeNamedFrames(doc.BoneFrames, motionData.BoneFrames.Get, motion.NewBoneFrame)
+		},
+		func() error {
+			return decodeNamedFrames(doc.MorphFrames, motionData.MorphFrames.Get, motion.NewMorphFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.CameraFrame](motionData.CameraFrames, doc.CameraFrames, motion.NewCameraFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.LightFrame](motionData.LightFrames, doc.LightFrames, motion.NewLightFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.ShadowFrame](motionData.ShadowFrames, doc.ShadowFrames, motion.NewShadowFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.IkFrame](motionData.IkFrames, doc.IkFrames, motion.NewIkFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.MaxSubStepsFrame](motionData.MaxSubStepsFrames, doc.MaxSubStepsFrames, motion.NewMaxSubStepsFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.FixedTimeStepFrame](motionData.FixedTimeStepFrames, doc.FixedTimeStepFrames, motion.NewFixedTimeStepFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.GravityFrame](motionData.GravityFrames, doc.GravityFrames, motion.NewGravityFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.PhysicsResetFrame](motionData.PhysicsResetFrames, doc.PhysicsResetFrames, motion.NewPhysicsResetFrame)
+		},
+		func() error {
+			return decodeNamedFrames(doc.RigidBodyFrames, func(name string) *motion.RigidBodyNameFrames {
+				if frames := motionData.RigidBodyFrames.Get(name); frames != nil {
+					return frames
+				}
+				frames := motion.NewRigidBodyNameFrames(name)
+				motionData.RigidBodyFrames.Update(frames)
+				return frames
+			}, motion.NewRigidBodyFrame)
+		},
+		func() error {
+			return decodeNamedFrames(doc.JointFrames, func(name string) *motion.JointNameFrames {
+				if frames := motionData.JointFrames.Get(name); frames != nil {
+					return frames
+				}
+				frames := motion.NewJointNameFrames(name)
+				motionData.JointFrames.Update(frames)
+				return frames
+			}, motion.NewJointFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.WindEnabledFrame](motionData.WindEnabledFrames, doc.WindEnabledFrames, motion.NewWindEnabledFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.WindDirectionFrame](motionData.WindDirectionFrames, doc.WindDirectionFrames, motion.NewWindDirectionFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.WindLiftCoeffFrame](motionData.WindLiftCoeffFrames, doc.WindLiftCoeffFrames, motion.NewWindLiftCoeffFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.WindDragCoeffFrame](motionData.WindDragCoeffFrames, doc.WindDragCoeffFrames, motion.NewWindDragCoeffFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.WindRandomnessFrame](motionData.WindRandomnessFrames, doc.WindRandomnessFrames, motion.NewWindRandomnessFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.WindSpeedFrame](motionData.WindSpeedFrames, doc.WindSpeedFrames, motion.NewWindSpeedFrame)
+		},
+		func() error {
+			return decodeFrames[*motion.WindTurbulenceFreqHzFrame](motionData.WindTurbulenceFreqHzFrames, doc.WindTurbulenceFreqHzFrames, motion.NewWindTurbulenceFreqHzFrame)
+		},
+	}
+	for _, decode := range decoders {
+		if err := decode(); err != nil {
+			return nil, err
+		}
+	}
+	restoreIkEnabledFrames(motionData.IkFrames)
+	return motionData, nil
+}
+
+// restoreIkEnabledFrames はIK有効フレームのフレーム番号を親フレームに揃える。
+func restoreIkEnabledFrames(ikFrames *motion.IkFrames) {
+	ikFrames.ForEach(func(frame motion.Frame, value *motion.IkFrame) bool {
+		for i, enabled := range value.IkList {
+			if enabled == nil {
+				continue
+			}
+			restored := motion.NewIkEnabledFrame(frame, enabled.BoneName)
+			restored.Enabled = enabled.Enabled
+			if enabled.BaseFrame != nil {
+				restored.Read = enabled.Read
+			}
+			value.IkList[i] = restored
+		}
+		return true
+	})
+}
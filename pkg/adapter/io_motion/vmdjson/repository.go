@@ -0,0 +1,120 @@
+// 指示: miu200521358
+package vmdjson
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+)
+
+// VMD_JSON_EXT はVMD JSONの拡張子。
+const VMD_JSON_EXT = ".vmd.json"
+
+// VmdJsonRepository はVMD JSON入出力を表す。
+type VmdJsonRepository struct{}
+
+// NewVmdJsonRepository はVmdJsonRepositoryを生成する。
+func NewVmdJsonRepository() *VmdJsonRepository {
+	return &VmdJsonRepository{}
+}
+
+// CanLoad は拡張子に応じて読み込み可否を判定する。
+func (r *VmdJsonRepository) CanLoad(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), VMD_JSON_EXT)
+}
+
+// InferName はパスから表示名を推定する。
+func (r *VmdJsonRepository) InferName(path string) string {
+	base := filepath.Base(path)
+	if r.CanLoad(base) {
+		return base[:len(base)-len(VMD_JSON_EXT)]
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Load はVMD JSONを読み込む。
+func (r *VmdJsonRepository) Load(path string) (hashable.IHashable, error) {
+	return r.LoadFS(nil, path)
+}
+
+// LoadFS はfs.FS上のVMD JSONを読み込む。fsys が nil の場合はOSのファイルを読み込む。
+func (r *VmdJsonRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
+	if !r.CanLoad(path) {
+		return nil, io_common.NewIoExtInvalid(path, nil)
+	}
+	file, err := io_common.OpenFile(fsys, path, "VMD JSONファイルのオープンに失敗しました")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var doc vmdJsonDocument
+	if err := json.NewDecoder(file).Decode(&doc); err != nil {
+		return nil, io_common.NewIoParseFailed("VMD JSONの解析に失敗しました", err)
+	}
+	motionData, err := doc.toMotion(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, io_common.NewIoParseFailed("VMD JSONファイル情報の取得に失敗しました", err)
+	}
+	motionData.SetFileModTime(info.ModTime().UnixNano())
+	motionData.UpdateHash()
+	return motionData, nil
+}
+
+// Save はVMD JSONを保存する。
+func (r *VmdJsonRepository) Save(path string, data hashable.IHashable, opts io_common.SaveOptions) error {
+	motionData, ok := data.(*motion.VmdMotion)
+	if !ok {
+		return io_common.NewIoEncodeFailed("VMD JSON保存対象が不正です", nil)
+	}
+	savePath := path
+	if savePath == "" {
+		savePath = motionData.Path()
+	}
+	if savePath == "" {
+		return io_common.NewIoSaveFailed("保存先パスが空です", nil)
+	}
+
+	file, err := os.Create(savePath)
+	if err != nil {
+		return io_common.NewIoSaveFailed("VMD JSONファイルの作成に失敗しました", err)
+	}
+	defer file.Close()
+
+	if err := r.SaveTo(file, motionData, opts); err != nil {
+		return err
+	}
+	motionData.SetPath(savePath)
+	motionData.UpdateHash()
+	return nil
+}
+
+// SaveTo はVMD JSONをio.Writerへ書き込む。
+func (r *VmdJsonRepository) SaveTo(w io.Writer, data hashable.IHashable, opts io_common.SaveOptions) error {
+	motionData, ok := data.(*motion.VmdMotion)
+	if !ok {
+		return io_common.NewIoEncodeFailed("VMD JSON保存対象が不正です", nil)
+	}
+	doc, err := newVmdJsonDocument(motionData)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return io_common.NewIoEncodeFailed("VMD JSONの書き込みに失敗しました", err)
+	}
+	return nil
+}
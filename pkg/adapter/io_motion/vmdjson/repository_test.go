@@ -0,0 +1,165 @@
+// 指示: miu200521358
+package vmdjson
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_common"
+	"github.com/miu200521358/mlib_go/pkg/adapter/io_motion/vmd"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// newRoundTripMotion はVMDに保存される全トラックを含むモーションを生成する。
+func newRoundTripMotion(path string) *motion.VmdMotion {
+	motionData := motion.NewVmdMotion(path)
+	motionData.SetName("往復確認")
+
+	for i, name := range []string{"センター", "右腕"} {
+		bf := motion.NewBoneFrame(motion.Frame(i * 10))
+		pos := vec3(1.5, float64(i), -0.25)
+		bf.Position = &pos
+		rot := mmath.NewQuaternionFromDegrees(10, 20, float64(i)*30)
+		bf.Rotation = &rot
+		curves := motion.NewBoneCurves()
+		curves.Values[0] = 64
+		bf.Curves = motion.NewBoneCurvesByValues(curves.Values)
+		motionData.AppendBoneFrame(name, bf)
+	}
+	for i := 0; i < 3; i++ {
+		mf := motion.NewMorphFrame(motion.Frame(i * 5))
+		mf.Ratio = float64(i) * 0.5
+		motionData.AppendMorphFrame("まばたき", mf)
+	}
+
+	cf := motion.NewCameraFrame(motion.Frame(3))
+	camPos := vec3(0, 10, 0)
+	camDeg := vec3(15, -35, 7)
+	cf.Position = &camPos
+	cf.Degrees = &camDeg
+	cf.Distance = -45
+	cf.ViewOfAngle = 30
+	cf.IsPerspectiveOff = true
+	cf.Curves = motion.NewCameraCurves()
+	motionData.AppendCameraFrame(cf)
+
+	lf := motion.NewLightFrame(motion.Frame(4))
+	lf.Position = vec3(-0.5, -1, 0.5)
+	lf.Color = vec3(0.6, 0.6, 0.6)
+	motionData.AppendLightFrame(lf)
+
+	sf := motion.NewShadowFrame(motion.Frame(5))
+	sf.ShadowMode = 1
+	sf.Distance = 0.0875
+	motionData.AppendShadowFrame(sf)
+
+	ikf := motion.NewIkFrame(motion.Frame(6))
+	ikf.Visible = false
+	disabled := motion.NewIkEnabledFrame(motion.Frame(6), "右足ＩＫ")
+	disabled.Enabled = false
+	ikf.IkList = append(ikf.IkList, disabled, motion.NewIkEnabledFrame(motion.Frame(6), "左足ＩＫ"))
+	motionData.IkFrames.Append(ikf)
+	return motionData
+}
+
+func TestVmdJsonRepository_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	binaryRepository := vmd.NewVmdRepository()
+	jsonRepository := NewVmdJsonRepository()
+
+	binaryPath := filepath.Join(dir, "motion.vmd")
+	if err := binaryRepository.Save(binaryPath, newRoundTripMotion(binaryPath), io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	original, err := os.ReadFile(binaryPath)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	loaded, err := binaryRepository.Load(binaryPath)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+
+	jsonPath := filepath.Join(dir, "motion.vmd.json")
+	if err := jsonRepository.Save(jsonPath, loaded, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	reloaded, err := jsonRepository.Load(jsonPath)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	reloadedMotion := reloaded.(*motion.VmdMotion)
+	if reloadedMotion.Name() != "往復確認" || reloadedMotion.Path() != jsonPath {
+		t.Errorf("Expected name and path to be restored, got %q %q", reloadedMotion.Name(), reloadedMotion.Path())
+	}
+	ikf := reloadedMotion.IkFrames.Get(motion.Frame(6))
+	if len(ikf.IkList) != 2 || ikf.IkList[0].Index() != motion.Frame(6) || ikf.IkList[0].Enabled {
+		t.Errorf("Expected ik list to be restored, got %+v", ikf.IkList)
+	}
+
+	roundTripPath := filepath.Join(dir, "round_trip.vmd")
+	if err := binaryRepository.Save(roundTripPath, reloadedMotion, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	roundTripped, err := os.ReadFile(roundTripPath)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	if !bytes.Equal(original, roundTripped) {
+		t.Fatalf("Expected binary to be equal after JSON round trip: %d bytes -> %d bytes", len(original), len(roundTripped))
+	}
+}
+
+func TestVmdJsonRepository_PhysicsTracks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "physics.vmd.json")
+	motionData := motion.NewVmdMotion(path)
+	gravity := motion.NewGravityFrame(motion.Frame(12))
+	g := vec3(0, -5, 0)
+	gravity.Gravity = &g
+	motionData.GravityFrames.Append(gravity)
+	mass := 2.5
+	rf := motion.NewRigidBodyFrame(motion.Frame(1))
+	rf.Mass = &mass
+	rigidBodyFrames := motion.NewRigidBodyNameFrames("髪")
+	rigidBodyFrames.Append(rf)
+	motionData.RigidBodyFrames.Update(rigidBodyFrames)
+	wind := motion.NewWindSpeedFrame(motion.Frame(0.5))
+	wind.Speed = 3
+	motionData.WindSpeedFrames.Append(wind)
+
+	r := NewVmdJsonRepository()
+	if err := r.Save("", motionData, io_common.SaveOptions{}); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	reloaded, err := r.Load(path)
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	reloadedMotion := reloaded.(*motion.VmdMotion)
+	if reloadedGravity := reloadedMotion.GravityFrames.Get(motion.Frame(12)); reloadedGravity.Gravity == nil || reloadedGravity.Gravity.Y != -5 {
+		t.Errorf("Expected gravity to be restored, got %v", reloadedGravity.Gravity)
+	}
+	if reloadedRf := reloadedMotion.RigidBodyFrames.Get("髪").Get(motion.Frame(1)); reloadedRf.Mass == nil || *reloadedRf.Mass != mass {
+		t.Errorf("Expected rigid body mass to be restored, got %v", reloadedRf.Mass)
+	}
+	if !reloadedMotion.WindSpeedFrames.Has(motion.Frame(0.5)) {
+		t.Errorf("Expected fractional wind frame to be restored")
+	}
+
+	invalidPath := filepath.Join(t.TempDir(), "invalid.vmd.json")
+	if err := os.WriteFile(invalidPath, []byte(`{"Format":"mlib.pmx.json","Version":1}`), 0o644); err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	if _, err := r.Load(invalidPath); merr.ExtractErrorID(err) != "14103" {
+		t.Fatalf("Expected error id 14103, got %v", err)
+	}
+}
+
+func vec3(x, y, z float64) mmath.Vec3 {
+	return mmath.Vec3{Vec: r3.Vec{X: x, Y: y, Z: z}}
+}
@@ -2,7 +2,7 @@
 package vpd
 
 import (
-	"os"
+	"io/fs"
 	"path/filepath"
 	"strings"
 
@@ -36,15 +36,17 @@ func (r *VpdRepository) InferName(path string) string {
 
 // Load はVPDテキストを読み込む。
 func (r *VpdRepository) Load(path string) (hashable.IHashable, error) {
+	return r.LoadFS(nil, path)
+}
+
+// LoadFS はfs.FS上のVPDテキストを読み込む。fsys が nil の場合はOSのファイルを読み込む。
+func (r *VpdRepository) LoadFS(fsys fs.FS, path string) (hashable.IHashable, error) {
 	if !r.CanLoad(path) {
 		return nil, io_common.NewIoExtInvalid(path, nil)
 	}
-	file, err := os.Open(path)
+	file, err := io_common.OpenFile(fsys, path, "VPDファイルのオープンに失敗しました")
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, io_common.NewIoFileNotFound(path, err)
-		}
-		return nil, io_common.NewIoParseFailed("VPDファイルのオープンに失敗しました", err)
+		return nil, err
 	}
 	defer file.Close()
 
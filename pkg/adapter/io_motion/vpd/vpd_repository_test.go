@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
 	"github.com/miu200521358/mlib_go/pkg/domain/model"
@@ -72,6 +73,37 @@ func TestVpdRepository_Load_InvalidSignature(t *testing.T) {
 	}
 }
 
+func TestVpdRepository_LoadFS(t *testing.T) {
+	encoded, err := japanese.ShiftJIS.NewEncoder().Bytes([]byte(strings.Join([]string{
+		"Vocaloid Pose Data file",
+		"1",
+		"Sample.osm; // 親ファイル名",
+		"{" + model.CENTER.String(),
+		"0.5,1.25,2.75; // trans",
+		"0,0,0,1; // Quaternion",
+	}, "\n")))
+	if err != nil {
+		t.Fatalf("Expected Shift-JIS encode to succeed, got %q", err)
+	}
+	fsys := fstest.MapFS{"pose/sample.vpd": &fstest.MapFile{Data: encoded}}
+
+	r := NewVpdRepository()
+	data, err := r.LoadFS(fsys, "pose\\sample.vpd")
+	if err != nil {
+		t.Fatalf("Expected error to be nil, got %q", err)
+	}
+	motionData, ok := data.(*motion.VmdMotion)
+	if !ok {
+		t.Fatalf("Expected motion type to be *VmdMotion, got %T", data)
+	}
+	if !motionData.BoneFrames.Get(model.CENTER.String()).Has(motion.Frame(0)) {
+		t.Errorf("Expected %s to contain frame 0", model.CENTER.String())
+	}
+	if _, err := r.LoadFS(fsys, "pose/nothing.vpd"); err == nil {
+		t.Fatalf("Expected error to be not nil")
+	}
+}
+
 func writeVpdFile(t *testing.T, content string) string {
 	t.Helper()
 	dir := t.TempDir()
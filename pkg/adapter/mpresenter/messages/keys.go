@@ -155,6 +155,12 @@ const (
 	ControlWindowKey105           = "言語変更メッセージ"
 	ControlWindowKey106           = "スクリーンショット要求に失敗しました"
 	ControlWindowKey107           = "スクリーンショット保存先が未指定です"
+	ControlWindowKey108           = "元に戻す(&U)"
+	ControlWindowKey109           = "やり直し(&R)"
+	ControlWindowKey110           = "元に戻す操作に失敗しました: %s"
+	ControlWindowKey111           = "やり直し操作に失敗しました: %s"
+	ControlWindowKey112           = "&編集"
+	ControlWindowKey113           = "未保存の変更があります。破棄して終了しますか？"
 	ViewerWindowKey001            = "カメラ手動操作(テンキー視点切替)"
 	ViewerWindowKey002            = "カメラ手動操作(右ドラッグ回転)"
 	ViewerWindowKey003            = "カメラ手動操作(中クリックドラッグ移動)"
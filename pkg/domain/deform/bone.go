@@ -26,6 +26,21 @@ func ComputeBoneDeltas(
 	afterPhysics bool,
 	removeTwist bool,
 	ikDebugFactory IIkDebugFactory,
+) (*delta.BoneDeltas, []int) {
+	return ComputeBoneDeltasWithIkSolvers(modelData, motionData, frame, boneNames, includeIk, afterPhysics, removeTwist, ikDebugFactory, nil)
+}
+
+// ComputeBoneDeltasWithIkSolvers はIKボーンごとのソルバー設定を指定してボーン差分を算出する。
+func ComputeBoneDeltasWithIkSolvers(
+	modelData *model.PmxModel,
+	motionData *motion.VmdMotion,
+	frame motion.Frame,
+	boneNames []string,
+	includeIk bool,
+	afterPhysics bool,
+	removeTwist bool,
+	ikDebugFactory IIkDebugFactory,
+	ikSolvers IkSolverSettings,
 ) (*delta.BoneDeltas, []int) {
 	if modelData == nil {
 		return delta.NewBoneDeltas(nil), nil
@@ -103,7 +118,7 @@ func ComputeBoneDeltas(
 
 	if includeIk {
 		applyBoneMatricesWithIndexes(modelData, boneDeltas, deformBoneIndexes)
-		applyIkDeltas(modelData, motionData, boneDeltas, frame, deformBoneIndexes, removeTwist, ikDebugFactory)
+		applyIkDeltas(modelData, motionData, boneDeltas, frame, deformBoneIndexes, removeTwist, ikDebugFactory, ikSolvers)
 		applyBoneMatricesWithIndexes(modelData, boneDeltas, deformBoneIndexes)
 	}
 
@@ -1355,6 +1370,7 @@ func applyIkDeltas(
 	deformBoneIndexes []int,
 	removeTwist bool,
 	ikDebugFactory IIkDebugFactory,
+	ikSolvers IkSolverSettings,
 ) {
 	if modelData == nil || boneDeltas == nil {
 		return
@@ -1390,10 +1406,53 @@ func applyIkDeltas(
 			orderIndex = ikDebugOrder[bone.Index()]
 		}
 		debugCtx := newIkDebugContext(ikDebugFactory, modelData, motionData, frame, bone, orderIndex)
+		if applyIkSolverForBone(modelData, boneDeltas, bone, ikSolvers.Resolve(bone.Name()), effectorChildren, children, scratch, debugCtx) {
+			continue
+		}
 		applyIkForBone(modelData, motionData, boneDeltas, bone, frame, deformBoneIndexes, effectorChildren, children, scratch, removeTwist, debugCtx)
 	}
 }
 
+// applyIkSolverForBone はCCD以外のソルバーが指定されていれば適用し、適用有無を返す。
+// 適用できない構成の場合はfalseを返し、呼び出し元のCCDへ委ねる。
+func applyIkSolverForBone(
+	modelData *model.PmxModel,
+	boneDeltas *delta.BoneDeltas,
+	ikBone *model.Bone,
+	setting IkSolverSetting,
+	effectorChildren [][]int,
+	children [][]int,
+	scratch *ikScratch,
+	debugCtx *IkDebugContext,
+) bool {
+	if setting.SolverType == IK_SOLVER_TYPE_CCD {
+		return false
+	}
+	ctx := &ikSolverContext{
+		modelData:        modelData,
+		boneDeltas:       boneDeltas,
+		ikBone:           ikBone,
+		setting:          setting,
+		effectorChildren: effectorChildren,
+		children:         children,
+		scratch:          scratch,
+		debug:            debugCtx,
+	}
+	applied := false
+	switch setting.SolverType {
+	case IK_SOLVER_TYPE_TWO_BONE:
+		applied = applyTwoBoneIkForBone(ctx)
+	case IK_SOLVER_TYPE_FABRIK:
+		applied = applyFabrikIkForBone(ctx)
+	}
+	if applied {
+		closeIkDebugContext(debugCtx)
+	} else {
+		logIkDebugf(debugCtx, "ソルバー(%d)を適用できないためCCDで計算", setting.SolverType)
+	}
+	return applied
+}
+
 // getIkFrame はIKフレームを返す。
 func getIkFrame(motionData *motion.VmdMotion, frame motion.Frame) *motion.IkFrame {
 	if motionData == nil || motionData.IkFrames == nil {
@@ -1539,14 +1598,6 @@ func applyIkForBone(
 	if scratch == nil {
 		scratch = newIkScratch(modelData, deformBoneIndexes)
 	}
-	unitUpdatedFlags := scratch.unitUpdatedFlags
-	unitUpdatedList := scratch.unitUpdatedList[:0]
-	unitQueue := scratch.unitQueue[:0]
-	globalUpdatedFlags := scratch.globalUpdatedFlags
-	globalUpdatedList := scratch.globalUpdatedList[:0]
-	globalQueue := scratch.globalQueue[:0]
-	globalRecalc := scratch.globalRecalc[:0]
-	orderByRank := scratch.orderByRank
 	for loop := 0; loop < loopCount; loop++ {
 		for linkIndex, link := range ikBone.Ik.Links {
 			linkBone, err := modelData.Bones.Get(link.BoneIndex)
@@ -1672,33 +1723,10 @@ func applyIkForBone(
 				appendIkRotation(debugCtx, linkBone.Name(), fixedRot)
 				logIkDebugf(debugCtx, "固定軸補正後=%s deg=%s", fixedRot.String(), fixedRot.ToMMDDegrees().String())
 			}
-			rot := resultQuat
-			linkDelta.FrameRotation = &rot
-			linkDelta.InvalidateTotals()
-			updateBoneDelta(modelData, boneDeltas, linkDelta)
-			collectEffectorRelatedFlags(effectorChildren, linkBone.Index(), unitUpdatedFlags, &unitUpdatedList, &unitQueue)
-			for _, idx := range unitUpdatedList {
-				if idx == linkBone.Index() {
-					continue
-				}
-				d := boneDeltas.Get(idx)
-				if d == nil || d.Bone == nil {
-					continue
-				}
-				updateBoneDelta(modelData, boneDeltas, d)
-			}
-			for _, idx := range unitUpdatedList {
-				collectDescendantFlags(children, idx, globalUpdatedFlags, &globalUpdatedList, &globalQueue)
-			}
-			globalRecalc = buildRecalcIndexesByOrderFlags(orderByRank, globalUpdatedFlags, globalRecalc)
-			applyGlobalMatricesWithIndexes(modelData, boneDeltas, globalRecalc)
+			applyIkLinkFrameRotation(modelData, boneDeltas, linkDelta, resultQuat, effectorChildren, children, scratch)
 			finalRot := linkDelta.FilledTotalRotation()
 			appendIkRotation(debugCtx, linkBone.Name(), finalRot)
 			logIkDebugf(debugCtx, "結果回転=%s deg=%s", finalRot.String(), finalRot.ToMMDDegrees().String())
-			resetFlagsByList(unitUpdatedFlags, unitUpdatedList)
-			unitUpdatedList = unitUpdatedList[:0]
-			resetFlagsByList(globalUpdatedFlags, globalUpdatedList)
-			globalUpdatedList = globalUpdatedList[:0]
 		}
 
 		threshold := ikTargetDistance(boneDeltas, ikBone.Index(), ikTargetIndex)
@@ -1708,11 +1736,48 @@ func applyIkForBone(
 			break
 		}
 	}
-	scratch.unitUpdatedList = unitUpdatedList
-	scratch.unitQueue = unitQueue
-	scratch.globalUpdatedList = globalUpdatedList
-	scratch.globalQueue = globalQueue
-	scratch.globalRecalc = globalRecalc
+}
+
+// applyIkLinkFrameRotation はリンクのフレーム回転を更新し、付与先と子孫の行列を再計算する。
+func applyIkLinkFrameRotation(
+	modelData *model.PmxModel,
+	boneDeltas *delta.BoneDeltas,
+	linkDelta *delta.BoneDelta,
+	frameRotation mmath.Quaternion,
+	effectorChildren [][]int,
+	children [][]int,
+	scratch *ikScratch,
+) {
+	if modelData == nil || boneDeltas == nil || linkDelta == nil || linkDelta.Bone == nil || scratch == nil {
+		return
+	}
+	rot := frameRotation
+	linkDelta.FrameRotation = &rot
+	linkDelta.InvalidateTotals()
+	updateBoneDelta(modelData, boneDeltas, linkDelta)
+	linkIndex := linkDelta.Bone.Index()
+	scratch.unitUpdatedList = scratch.unitUpdatedList[:0]
+	scratch.globalUpdatedList = scratch.globalUpdatedList[:0]
+	collectEffectorRelatedFlags(effectorChildren, linkIndex, scratch.unitUpdatedFlags, &scratch.unitUpdatedList, &scratch.unitQueue)
+	for _, idx := range scratch.unitUpdatedList {
+		if idx == linkIndex {
+			continue
+		}
+		d := boneDeltas.Get(idx)
+		if d == nil || d.Bone == nil {
+			continue
+		}
+		updateBoneDelta(modelData, boneDeltas, d)
+	}
+	for _, idx := range scratch.unitUpdatedList {
+		collectDescendantFlags(children, idx, scratch.globalUpdatedFlags, &scratch.globalUpdatedList, &scratch.globalQueue)
+	}
+	scratch.globalRecalc = buildRecalcIndexesByOrderFlags(scratch.orderByRank, scratch.globalUpdatedFlags, scratch.globalRecalc)
+	applyGlobalMatricesWithIndexes(modelData, boneDeltas, scratch.globalRecalc)
+	resetFlagsByList(scratch.unitUpdatedFlags, scratch.unitUpdatedList)
+	scratch.unitUpdatedList = scratch.unitUpdatedList[:0]
+	resetFlagsByList(scratch.globalUpdatedFlags, scratch.globalUpdatedList)
+	scratch.globalUpdatedList = scratch.globalUpdatedList[:0]
 }
 
 // ikTargetDistance はIKターゲット距離を返す。
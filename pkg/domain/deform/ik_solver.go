@@ -0,0 +1,409 @@
+// 指示: miu200521358
+package deform
+
+import (
+	"math"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/delta"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+)
+
+// IkSolverType はIKソルバー種別を表す。
+type IkSolverType int
+
+const (
+	// IK_SOLVER_TYPE_CCD はMMD互換のCCDソルバー。
+	IK_SOLVER_TYPE_CCD IkSolverType = iota
+	// IK_SOLVER_TYPE_TWO_BONE は2リンク解析解ソルバー。
+	IK_SOLVER_TYPE_TWO_BONE
+	// IK_SOLVER_TYPE_FABRIK は多リンク用のFABRIKソルバー。
+	IK_SOLVER_TYPE_FABRIK
+)
+
+const (
+	// ikSolverEpsilon はIKソルバーの幾何判定に使う閾値。
+	ikSolverEpsilon = 1e-8
+	// ikSolverDefaultTolerance は収束判定の既定距離。
+	ikSolverDefaultTolerance = 1e-5
+)
+
+// IkSolverSetting はIKボーン単位のソルバー設定を表す。
+type IkSolverSetting struct {
+	SolverType    IkSolverType
+	PoleBoneName  string
+	PolePosition  *mmath.Vec3
+	PoleAngle     float64
+	MaxIterations int
+	Tolerance     float64
+}
+
+// IkSolverSettings はIKボーン名ごとのソルバー設定を表す。
+type IkSolverSettings map[string]IkSolverSetting
+
+// Resolve はIKボーン名に対応する設定を返す。未指定の場合はCCD設定を返す。
+func (s IkSolverSettings) Resolve(ikBoneName string) IkSolverSetting {
+	if s == nil {
+		return IkSolverSetting{SolverType: IK_SOLVER_TYPE_CCD}
+	}
+	setting, ok := s[ikBoneName]
+	if !ok {
+		return IkSolverSetting{SolverType: IK_SOLVER_TYPE_CCD}
+	}
+	return setting
+}
+
+// HasPole はポール指定があるか判定する。
+func (s IkSolverSetting) HasPole() bool {
+	return s.PolePosition != nil || s.PoleBoneName != ""
+}
+
+// tolerance は収束判定距離を返す。
+func (s IkSolverSetting) tolerance() float64 {
+	if s.Tolerance > 0 {
+		return s.Tolerance
+	}
+	return ikSolverDefaultTolerance
+}
+
+// iterations は反復回数を返す。
+func (s IkSolverSetting) iterations(ik *model.Ik) int {
+	if s.MaxIterations > 0 {
+		return s.MaxIterations
+	}
+	if ik != nil && ik.LoopCount > 0 {
+		return ik.LoopCount
+	}
+	return 1
+}
+
+// ikSolverContext はCCD以外のソルバーが共有する計算状態を保持する。
+type ikSolverContext struct {
+	modelData        *model.PmxModel
+	boneDeltas       *delta.BoneDeltas
+	ikBone           *model.Bone
+	setting          IkSolverSetting
+	effectorChildren [][]int
+	children         [][]int
+	scratch          *ikScratch
+	debug            *IkDebugContext
+}
+
+// applyTwoBoneIkForBone は2リンクの解析解でIKを解く。適用できない場合はfalseを返す。
+func applyTwoBoneIkForBone(ctx *ikSolverContext) bool {
+	if ctx == nil || ctx.ikBone == nil || ctx.ikBone.Ik == nil || len(ctx.ikBone.Ik.Links) != 2 {
+		return false
+	}
+	ik := ctx.ikBone.Ik
+	lowerLink := ik.Links[0]
+	upperLink := ik.Links[1]
+	ikDelta := ctx.boneDeltas.Get(ctx.ikBone.Index())
+	upperDelta := ctx.boneDeltas.Get(upperLink.BoneIndex)
+	lowerDelta := ctx.boneDeltas.Get(lowerLink.BoneIndex)
+	targetDelta := ctx.boneDeltas.Get(ik.BoneIndex)
+	if ikDelta == nil || upperDelta == nil || lowerDelta == nil || targetDelta == nil {
+		return false
+	}
+	goal := ikDelta.FilledGlobalPosition()
+	rootPos := upperDelta.FilledGlobalPosition()
+	midPos := lowerDelta.FilledGlobalPosition()
+	endPos := targetDelta.FilledGlobalPosition()
+	upperLength := rootPos.Distance(midPos)
+	lowerLength := midPos.Distance(endPos)
+	if upperLength < ikSolverEpsilon || lowerLength < ikSolverEpsilon {
+		return false
+	}
+
+	toGoal := goal.Subed(rootPos)
+	goalDistance := toGoal.Length()
+	direction := toGoal.Normalized()
+	if goalDistance < ikSolverEpsilon {
+		direction = endPos.Subed(rootPos).Normalized()
+	}
+	if direction.IsZero() {
+		return false
+	}
+	reachMin := math.Abs(upperLength-lowerLength) + 1e-6
+	reachMax := upperLength + lowerLength - 1e-6
+	reach := mmath.Clamped(goalDistance, reachMin, reachMax)
+
+	bendDirection := resolveTwoBoneBendDirection(ctx, lowerLink, rootPos, midPos, endPos, direction)
+	cosRoot := mmath.Clamped(
+		(upperLength*upperLength+reach*reach-lowerLength*lowerLength)/(2*upperLength*reach),
+		-1,
+		1,
+	)
+	sinRoot := math.Sqrt(math.Max(0, 1-cosRoot*cosRoot))
+	midGoal := rootPos.Added(direction.MuledScalar(upperLength * cosRoot)).Added(bendDirection.MuledScalar(upperLength * sinRoot))
+	endGoal := rootPos.Added(direction.MuledScalar(reach))
+	logIkDebugf(ctx.debug, "2リンクIK: reach=%.8f upper=%.8f lower=%.8f bend=%s midGoal=%s endGoal=%s",
+		reach, upperLength, lowerLength, bendDirection.String(), midGoal.String(), endGoal.String(),
+	)
+
+	rotateIkLinkToward(ctx, upperLink, midPos, midGoal)
+	if updated := ctx.boneDeltas.Get(ik.BoneIndex); updated != nil {
+		endPos = updated.FilledGlobalPosition()
+	}
+	rotateIkLinkToward(ctx, lowerLink, endPos, endGoal)
+	return true
+}
+
+// resolveTwoBoneBendDirection は2リンクIKの曲げ方向を返す。
+// ポール指定、角度制限から推定した曲げ方向、現在の曲げ方向の順に採用する。
+func resolveTwoBoneBendDirection(
+	ctx *ikSolverContext,
+	lowerLink model.IkLink,
+	rootPos, midPos, endPos, direction mmath.Vec3,
+) mmath.Vec3 {
+	bend := mmath.NewVec3()
+	if polePos, ok := resolveIkPolePosition(ctx); ok {
+		bend = orthogonalDirection(polePos.Subed(rootPos), direction)
+	}
+	if bend.IsZero() {
+		bend = bendDirectionByLimit(ctx, lowerLink, midPos, endPos, direction)
+	}
+	if bend.IsZero() {
+		bend = orthogonalDirection(midPos.Subed(rootPos), direction)
+	}
+	if bend.IsZero() {
+		bend = orthogonalDirection(mmath.UNIT_Z_NEG_VEC3, direction)
+	}
+	if bend.IsZero() {
+		bend = orthogonalDirection(mmath.UNIT_Y_VEC3, direction)
+	}
+	if ctx.setting.PoleAngle != 0 && !bend.IsZero() {
+		bend = mmath.NewQuaternionFromAxisAngles(direction, ctx.setting.PoleAngle).MulVec3(bend).Normalized()
+	}
+	return bend
+}
+
+// bendDirectionByLimit は中間リンクを角度制限の中央まで曲げた時の関節の逃げ方向を返す。
+func bendDirectionByLimit(
+	ctx *ikSolverContext,
+	link model.IkLink,
+	midPos, endPos, direction mmath.Vec3,
+) mmath.Vec3 {
+	if !link.AngleLimit && !link.LocalAngleLimit {
+		return mmath.NewVec3()
+	}
+	linkDelta := ctx.boneDeltas.Get(link.BoneIndex)
+	if linkDelta == nil {
+		return mmath.NewVec3()
+	}
+	var limitRot mmath.Quaternion
+	if link.AngleLimit {
+		mid := link.MinAngleLimit.Added(link.MaxAngleLimit).MuledScalar(0.5)
+		limitRot = mid.RadToQuaternion()
+	} else {
+		linkBone, err := ctx.modelData.Bones.Get(link.BoneIndex)
+		if err != nil || linkBone == nil {
+			return mmath.NewVec3()
+		}
+		mid := link.LocalMinAngleLimit.Added(link.LocalMaxAngleLimit).MuledScalar(0.5)
+		axes := localAxes(ctx.modelData, linkBone)
+		limitRot = mmath.NewQuaternionFromAxisAngles(axes.Y, mid.Y).
+			Muled(mmath.NewQuaternionFromAxisAngles(axes.X, mid.X)).
+			Muled(mmath.NewQuaternionFromAxisAngles(axes.Z, mid.Z))
+	}
+	if limitRot.IsIdent() {
+		return mmath.NewVec3()
+	}
+	linkGlobal := linkDelta.FilledGlobalMatrix()
+	linkInv := linkGlobal.Inverted()
+	bentEnd := linkGlobal.MulVec3(limitRot.MulVec3(linkInv.MulVec3(endPos)))
+	// 先端が動いた向きの逆側へ関節が張り出す。
+	return orthogonalDirection(endPos.Subed(bentEnd), direction)
+}
+
+// applyFabrikIkForBone はFABRIKでIKを解く。適用できない場合はfalseを返す。
+func applyFabrikIkForBone(ctx *ikSolverContext) bool {
+	if ctx == nil || ctx.ikBone == nil || ctx.ikBone.Ik == nil || len(ctx.ikBone.Ik.Links) == 0 {
+		return false
+	}
+	ik := ctx.ikBone.Ik
+	// 根元から先端の順に並べる。
+	links := make([]model.IkLink, 0, len(ik.Links))
+	for i := len(ik.Links) - 1; i >= 0; i-- {
+		links = append(links, ik.Links[i])
+	}
+	jointIndexes := make([]int, 0, len(links)+1)
+	for _, link := range links {
+		jointIndexes = append(jointIndexes, link.BoneIndex)
+	}
+	jointIndexes = append(jointIndexes, ik.BoneIndex)
+	for _, idx := range jointIndexes {
+		if ctx.boneDeltas.Get(idx) == nil {
+			return false
+		}
+	}
+	ikDelta := ctx.boneDeltas.Get(ctx.ikBone.Index())
+	if ikDelta == nil {
+		return false
+	}
+	goal := ikDelta.FilledGlobalPosition()
+	polePos, hasPole := resolveIkPolePosition(ctx)
+	tolerance := ctx.setting.tolerance()
+	iterations := ctx.setting.iterations(ik)
+
+	positions := make([]mmath.Vec3, len(jointIndexes))
+	lengths := make([]float64, len(jointIndexes)-1)
+	for loop := 0; loop < iterations; loop++ {
+		for i, idx := range jointIndexes {
+			positions[i] = ctx.boneDeltas.Get(idx).FilledGlobalPosition()
+		}
+		if positions[len(positions)-1].Distance(goal) <= tolerance {
+			logIkDebugf(ctx.debug, "FABRIK収束終了: loop=%d", loop)
+			break
+		}
+		for i := range lengths {
+			lengths[i] = positions[i].Distance(positions[i+1])
+		}
+		solveFabrikPositions(positions, lengths, goal)
+		if hasPole {
+			applyFabrikPole(positions, polePos)
+		}
+		logIkDebugf(ctx.debug, "FABRIK: loop=%d end=%s goal=%s", loop, positions[len(positions)-1].String(), goal.String())
+		for i, link := range links {
+			current := ctx.boneDeltas.Get(jointIndexes[i+1]).FilledGlobalPosition()
+			rotateIkLinkToward(ctx, link, current, positions[i+1])
+		}
+	}
+	return true
+}
+
+// solveFabrikPositions は関節位置をFABRIKの前後パスで更新する。
+func solveFabrikPositions(positions []mmath.Vec3, lengths []float64, goal mmath.Vec3) {
+	if len(positions) < 2 {
+		return
+	}
+	root := positions[0]
+	total := 0.0
+	for _, length := range lengths {
+		total += length
+	}
+	last := len(positions) - 1
+	if root.Distance(goal) >= total {
+		for i := 0; i < last; i++ {
+			dir := goal.Subed(positions[i]).Normalized()
+			positions[i+1] = positions[i].Added(dir.MuledScalar(lengths[i]))
+		}
+		return
+	}
+	positions[last] = goal
+	for i := last - 1; i >= 0; i-- {
+		dir := positions[i].Subed(positions[i+1]).Normalized()
+		positions[i] = positions[i+1].Added(dir.MuledScalar(lengths[i]))
+	}
+	positions[0] = root
+	for i := 0; i < last; i++ {
+		dir := positions[i+1].Subed(positions[i]).Normalized()
+		positions[i+1] = positions[i].Added(dir.MuledScalar(lengths[i]))
+	}
+}
+
+// applyFabrikPole は中間関節を前後関節を結ぶ軸周りに回し、ポールへ向ける。
+func applyFabrikPole(positions []mmath.Vec3, polePos mmath.Vec3) {
+	for i := 1; i < len(positions)-1; i++ {
+		axis := positions[i+1].Subed(positions[i-1]).Normalized()
+		if axis.IsZero() {
+			continue
+		}
+		joint := positions[i].Subed(positions[i-1])
+		jointDir := orthogonalDirection(joint, axis)
+		poleDir := orthogonalDirection(polePos.Subed(positions[i-1]), axis)
+		if jointDir.IsZero() || poleDir.IsZero() {
+			continue
+		}
+		angle := math.Acos(mmath.Clamped(jointDir.Dot(poleDir), -1, 1))
+		rotAxis := jointDir.Cross(poleDir).Normalized()
+		if rotAxis.IsZero() || angle < ikSolverEpsilon {
+			continue
+		}
+		rot := mmath.NewQuaternionFromAxisAngles(rotAxis, angle)
+		positions[i] = positions[i-1].Added(rot.MulVec3(joint))
+	}
+}
+
+// rotateIkLinkToward はリンクを回し、fromGlobal の点を toGlobal の方向へ向ける。
+// 回転後はリンクの角度制限を適用して付与先と子孫の行列を再計算する。
+func rotateIkLinkToward(ctx *ikSolverContext, link model.IkLink, fromGlobal, toGlobal mmath.Vec3) {
+	linkBone, err := ctx.modelData.Bones.Get(link.BoneIndex)
+	if err != nil || linkBone == nil {
+		return
+	}
+	linkDelta := ctx.boneDeltas.Get(linkBone.Index())
+	if linkDelta == nil {
+		return
+	}
+	if ctx.debug != nil {
+		ctx.debug.LinkBoneName = linkBone.Name()
+	}
+	linkGlobal := linkDelta.FilledGlobalMatrix()
+	linkInv := linkGlobal.Inverted()
+	fromLocal := linkInv.MulVec3(fromGlobal).Normalized()
+	toLocal := linkInv.MulVec3(toGlobal).Normalized()
+	if fromLocal.IsZero() || toLocal.IsZero() {
+		return
+	}
+	angle := math.Acos(mmath.Clamped(fromLocal.Dot(toLocal), -1, 1))
+	axis := fromLocal.Cross(toLocal).Normalized()
+	if isInvalidFloat(angle) || angle < ikSolverEpsilon || axis.IsZero() || isInvalidVec3(axis) {
+		return
+	}
+
+	linkQuat := linkDelta.FilledTotalRotation()
+	totalIkQuat := linkQuat.Muled(mmath.NewQuaternionFromAxisAngles(axis, angle))
+	resultQuat := applyIkLimit(totalIkQuat, IkSolveStepInput{
+		MinAngleLimit:   link.MinAngleLimit,
+		MaxAngleLimit:   link.MaxAngleLimit,
+		LocalMinLimit:   link.LocalMinAngleLimit,
+		LocalMaxLimit:   link.LocalMaxAngleLimit,
+		AngleLimit:      link.AngleLimit,
+		LocalAngleLimit: link.LocalAngleLimit,
+		Loop:            0,
+		LoopCount:       1,
+		LocalAxes:       localAxes(ctx.modelData, linkBone),
+		Debug:           ctx.debug,
+	})
+	if boneHasFixedAxis(linkBone) {
+		resultQuat = resultQuat.ToFixedAxisRotation(linkBone.FixedAxis.Normalized())
+	}
+	if linkDelta.FrameMorphRotation != nil && !linkDelta.FrameMorphRotation.IsIdent() {
+		resultQuat = resultQuat.Muled(linkDelta.FrameMorphRotation.Inverted())
+	}
+	logIkDebugf(ctx.debug, "リンク回転: angle=%.8f axis=%s result=%s deg=%s",
+		angle, axis.String(), resultQuat.String(), resultQuat.ToMMDDegrees().String(),
+	)
+	applyIkLinkFrameRotation(ctx.modelData, ctx.boneDeltas, linkDelta, resultQuat, ctx.effectorChildren, ctx.children, ctx.scratch)
+	appendIkRotation(ctx.debug, linkBone.Name(), linkDelta.FilledTotalRotation())
+}
+
+// resolveIkPolePosition はポールのグローバル位置を返す。
+func resolveIkPolePosition(ctx *ikSolverContext) (mmath.Vec3, bool) {
+	if ctx == nil {
+		return mmath.NewVec3(), false
+	}
+	if ctx.setting.PolePosition != nil {
+		return *ctx.setting.PolePosition, true
+	}
+	if ctx.setting.PoleBoneName == "" || ctx.modelData == nil || ctx.modelData.Bones == nil {
+		return mmath.NewVec3(), false
+	}
+	poleBone, err := ctx.modelData.Bones.GetByName(ctx.setting.PoleBoneName)
+	if err != nil || poleBone == nil {
+		return mmath.NewVec3(), false
+	}
+	if poleDelta := ctx.boneDeltas.Get(poleBone.Index()); poleDelta != nil {
+		return poleDelta.FilledGlobalPosition(), true
+	}
+	return poleBone.Position, true
+}
+
+// orthogonalDirection は v から axis 成分を除いた正規化ベクトルを返す。
+func orthogonalDirection(v, axis mmath.Vec3) mmath.Vec3 {
+	ortho := v.Subed(axis.MuledScalar(v.Dot(axis)))
+	if ortho.Length() < 1e-6 {
+		return mmath.NewVec3()
+	}
+	return ortho.Normalized()
+}
@@ -0,0 +1,120 @@
+// 指示: miu200521358
+package deform
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+)
+
+// newIkSolverTestModel は足・ひざ・足首・足IKの最小モデルを生成する。
+func newIkSolverTestModel(withKneeLimit bool) *model.PmxModel {
+	m := model.NewPmxModel()
+	appendBone := func(name string, pos mmath.Vec3, parent int) *model.Bone {
+		bone := &model.Bone{Position: pos, ParentIndex: parent, TailIndex: -1, EffectIndex: -1}
+		bone.SetName(name)
+		bone.BoneFlag = model.BONE_FLAG_CAN_ROTATE | model.BONE_FLAG_IS_VISIBLE | model.BONE_FLAG_CAN_MANIPULATE
+		m.Bones.Append(bone)
+		return bone
+	}
+	appendBone("足", vec3(0, 10, 0), -1)
+	appendBone("ひざ", vec3(0, 5, 0), 0)
+	appendBone("足首", vec3(0, 0, 0), 1)
+	ikBone := appendBone("足IK", vec3(0, 0, 0), -1)
+	ikBone.BoneFlag |= model.BONE_FLAG_IS_IK | model.BONE_FLAG_CAN_TRANSLATE
+	kneeLink := model.IkLink{BoneIndex: 1}
+	if withKneeLimit {
+		kneeLink.AngleLimit = true
+		kneeLink.MinAngleLimit = vec3(-math.Pi, 0, 0)
+		kneeLink.MaxAngleLimit = vec3(-0.008, 0, 0)
+	}
+	ikBone.Ik = &model.Ik{
+		BoneIndex:    2,
+		LoopCount:    40,
+		UnitRotation: vec3(2, 0, 0),
+		Links:        []model.IkLink{kneeLink, {BoneIndex: 0}},
+	}
+	return m
+}
+
+// newIkSolverTestMotion は足IKを移動するモーションを生成する。
+func newIkSolverTestMotion(pos mmath.Vec3) *motion.VmdMotion {
+	motionData := motion.NewVmdMotion("")
+	bf := motion.NewBoneFrame(0)
+	bf.Position = &pos
+	motionData.BoneFrames.Get("足IK").Append(bf)
+	return motionData
+}
+
+// TestTwoBoneIkReachesGoal は2リンクIKがターゲットへ到達することを確認する。
+func TestTwoBoneIkReachesGoal(t *testing.T) {
+	m := newIkSolverTestModel(true)
+	motionData := newIkSolverTestMotion(vec3(0, 3, -1))
+	solvers := IkSolverSettings{"足IK": {SolverType: IK_SOLVER_TYPE_TWO_BONE}}
+	boneDeltas, _ := ComputeBoneDeltasWithIkSolvers(m, motionData, 0, nil, true, false, false, nil, solvers)
+
+	ankle := boneDeltas.GetByName("足首").FilledGlobalPosition()
+	goal := boneDeltas.GetByName("足IK").FilledGlobalPosition()
+	if ankle.Distance(goal) > 1e-4 {
+		t.Fatalf("ankle did not reach goal: ankle=%v goal=%v", ankle, goal)
+	}
+	knee := boneDeltas.GetByName("ひざ").FilledGlobalPosition()
+	if knee.Z >= 0 {
+		t.Fatalf("knee should bend forward (-Z): %v", knee)
+	}
+}
+
+// TestTwoBoneIkPole はポール位置で膝の向きが決まることを確認する。
+func TestTwoBoneIkPole(t *testing.T) {
+	m := newIkSolverTestModel(false)
+	motionData := newIkSolverTestMotion(vec3(0, 3, 0))
+	pole := vec3(10, 5, 0)
+	solvers := IkSolverSettings{"足IK": {SolverType: IK_SOLVER_TYPE_TWO_BONE, PolePosition: &pole}}
+	boneDeltas, _ := ComputeBoneDeltasWithIkSolvers(m, motionData, 0, nil, true, false, false, nil, solvers)
+
+	ankle := boneDeltas.GetByName("足首").FilledGlobalPosition()
+	if !ankle.NearEquals(vec3(0, 3, 0), 1e-4) {
+		t.Fatalf("ankle mismatch: %v", ankle)
+	}
+	knee := boneDeltas.GetByName("ひざ").FilledGlobalPosition()
+	if knee.X <= 0 || math.Abs(knee.Z) > 1e-4 {
+		t.Fatalf("knee should point to pole (+X): %v", knee)
+	}
+}
+
+// TestFabrikIkReachesGoal はFABRIKがターゲットへ到達することを確認する。
+func TestFabrikIkReachesGoal(t *testing.T) {
+	m := newIkSolverTestModel(false)
+	motionData := newIkSolverTestMotion(vec3(2, 4, -2))
+	pole := vec3(0, 5, -10)
+	solvers := IkSolverSettings{"足IK": {SolverType: IK_SOLVER_TYPE_FABRIK, PolePosition: &pole}}
+	boneDeltas, _ := ComputeBoneDeltasWithIkSolvers(m, motionData, 0, nil, true, false, false, nil, solvers)
+
+	ankle := boneDeltas.GetByName("足首").FilledGlobalPosition()
+	goal := boneDeltas.GetByName("足IK").FilledGlobalPosition()
+	if ankle.Distance(goal) > 1e-3 {
+		t.Fatalf("ankle did not reach goal: ankle=%v goal=%v", ankle, goal)
+	}
+	knee := boneDeltas.GetByName("ひざ").FilledGlobalPosition()
+	if knee.Z >= 0 {
+		t.Fatalf("knee should follow pole (-Z): %v", knee)
+	}
+}
+
+// TestIkSolverSettingsResolve は未指定ボーンがCCDになることを確認する。
+func TestIkSolverSettingsResolve(t *testing.T) {
+	var settings IkSolverSettings
+	if settings.Resolve("足IK").SolverType != IK_SOLVER_TYPE_CCD {
+		t.Fatalf("nil settings should resolve to CCD")
+	}
+	settings = IkSolverSettings{"右足IK": {SolverType: IK_SOLVER_TYPE_FABRIK}}
+	if settings.Resolve("左足IK").SolverType != IK_SOLVER_TYPE_CCD {
+		t.Fatalf("unknown bone should resolve to CCD")
+	}
+	if settings.Resolve("右足IK").SolverType != IK_SOLVER_TYPE_FABRIK {
+		t.Fatalf("registered bone should resolve to FABRIK")
+	}
+}
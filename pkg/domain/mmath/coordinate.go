@@ -0,0 +1,250 @@
+// 指示: miu200521358
+package mmath
+
+import (
+	"math"
+
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/shared/contracts/axis"
+	"github.com/miu200521358/mlib_go/pkg/shared/contracts/units"
+)
+
+const (
+	mathAxisPolicyInvalidErrorID = "12101"
+)
+
+// newMathAxisPolicyInvalid は座標系ポリシー不正エラーを生成する。
+func newMathAxisPolicyInvalid() error {
+	return merr.NewCommonError(mathAxisPolicyInvalidErrorID, merr.ErrorKindValidate, "座標系ポリシーの軸指定が不正です", nil)
+}
+
+// CoordinateSystem は座標軸と長さ単位の組を表す。
+type CoordinateSystem struct {
+	Axis   axis.AxisPolicy
+	Length units.LengthUnit
+}
+
+var (
+	// COORDINATE_SYSTEM_MMD はMMD(PMX/VMD)の座標系。
+	COORDINATE_SYSTEM_MMD = CoordinateSystem{Axis: axis.AXIS_POLICY_IO_MMD, Length: units.LENGTH_UNIT_MMD}
+	// COORDINATE_SYSTEM_RIGHT_Y_UP_METER は右手系Y-upメートルの座標系。
+	COORDINATE_SYSTEM_RIGHT_Y_UP_METER = CoordinateSystem{Axis: axis.AXIS_POLICY_RIGHT_Y_UP, Length: units.LENGTH_UNIT_METER}
+	// COORDINATE_SYSTEM_RIGHT_Z_UP_CENTIMETER は右手系Z-upセンチメートルの座標系。
+	COORDINATE_SYSTEM_RIGHT_Z_UP_CENTIMETER = CoordinateSystem{Axis: axis.AXIS_POLICY_RIGHT_Z_UP, Length: units.LENGTH_UNIT_CENTIMETER}
+	// COORDINATE_SYSTEM_UNITY はUnityの座標系。
+	COORDINATE_SYSTEM_UNITY = CoordinateSystem{Axis: axis.AXIS_POLICY_UNITY, Length: units.LENGTH_UNIT_METER}
+	// COORDINATE_SYSTEM_BLENDER はBlenderの座標系。
+	COORDINATE_SYSTEM_BLENDER = CoordinateSystem{Axis: axis.AXIS_POLICY_BLENDER, Length: units.LENGTH_UNIT_METER}
+	// COORDINATE_SYSTEM_GLTF はglTFの座標系。
+	COORDINATE_SYSTEM_GLTF = CoordinateSystem{Axis: axis.AXIS_POLICY_GLTF, Length: units.LENGTH_UNIT_METER}
+)
+
+// LengthUnitMeters は長さ単位1あたりのメートル数を返す。
+func LengthUnitMeters(unit units.LengthUnit) float64 {
+	switch unit {
+	case units.LENGTH_UNIT_METER:
+		return 1
+	case units.LENGTH_UNIT_CENTIMETER:
+		return 0.01
+	case units.LENGTH_UNIT_MILLIMETER:
+		return 0.001
+	default:
+		return units.MMD_UNIT_METERS
+	}
+}
+
+// CoordinateConverter は座標系間の変換を表す。
+// 軸の入れ替えと符号反転(鏡像を含む)および単位スケールのみを扱う。
+type CoordinateConverter struct {
+	// basis は変換先の各軸成分を変換元の軸成分から求める符号付き置換行列(行優先)。
+	basis [3][3]float64
+	det   float64
+	scale float64
+}
+
+// NewCoordinateConverter は from から to への変換器を生成する。
+func NewCoordinateConverter(from, to CoordinateSystem) (CoordinateConverter, error) {
+	fromAxes, err := axisPolicyVectors(from.Axis)
+	if err != nil {
+		return CoordinateConverter{}, err
+	}
+	toAxes, err := axisPolicyVectors(to.Axis)
+	if err != nil {
+		return CoordinateConverter{}, err
+	}
+
+	// 変換元の座標 p を (右,上,前) 成分へ分解し、変換先の軸で組み直す。
+	// M = Σ toAxes[k] ⊗ fromAxes[k]
+	var basis [3][3]float64
+	for k := 0; k < 3; k++ {
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				basis[i][j] += toAxes[k][i] * fromAxes[k][j]
+			}
+		}
+	}
+	return CoordinateConverter{
+		basis: basis,
+		det:   signDet3(basis),
+		scale: LengthUnitMeters(from.Length) / LengthUnitMeters(to.Length),
+	}, nil
+}
+
+// IsMirrored は変換が鏡像(手系の反転)を含むか判定する。
+func (c CoordinateConverter) IsMirrored() bool {
+	return c.det < 0
+}
+
+// Scale は長さのスケール係数を返す。
+func (c CoordinateConverter) Scale() float64 {
+	return c.scale
+}
+
+// Length は長さを変換する。
+func (c CoordinateConverter) Length(v float64) float64 {
+	return v * c.scale
+}
+
+// Direction は方向ベクトル(単位スケールなし)を変換する。
+func (c CoordinateConverter) Direction(v Vec3) Vec3 {
+	return c.apply(v)
+}
+
+// Position は位置ベクトルを単位スケール込みで変換する。
+func (c CoordinateConverter) Position(v Vec3) Vec3 {
+	return c.apply(v).MuledScalar(c.scale)
+}
+
+// Quaternion は回転を変換する。鏡像変換では回転向きを反転する。
+func (c CoordinateConverter) Quaternion(q Quaternion) Quaternion {
+	axisPart := c.apply(q.XYZ()).MuledScalar(c.det)
+	return NewQuaternionByValues(axisPart.X, axisPart.Y, axisPart.Z, q.W())
+}
+
+// AngleLimits は軸ごとの角度範囲(オイラー角)を変換し、min/max の順に返す。
+func (c CoordinateConverter) AngleLimits(minLimit, maxLimit Vec3) (Vec3, Vec3) {
+	return c.limits(minLimit, maxLimit, c.det)
+}
+
+// Ranges は軸ごとの移動範囲を単位スケール込みで変換し、min/max の順に返す。
+func (c CoordinateConverter) Ranges(minLimit, maxLimit Vec3) (Vec3, Vec3) {
+	outMin, outMax := c.limits(minLimit, maxLimit, 1)
+	return outMin.MuledScalar(c.scale), outMax.MuledScalar(c.scale)
+}
+
+// Mat4 は同次変換行列を変換する。
+func (c CoordinateConverter) Mat4(m Mat4) Mat4 {
+	s := c.basisMat4(c.scale)
+	sInv := c.basisMat4(1 / c.scale)
+	// 置換行列の逆は転置なので、スケールの逆数と合わせて転置する。
+	sInv.Transpose()
+	return s.Muled(m).Muled(sInv)
+}
+
+// Orientation は形状を伴う姿勢(剛体・ジョイント)を変換する。
+// 鏡像変換ではローカルZ軸を反転して右手/左手の回転行列に戻すため、
+// ローカル空間の値は OrientationLocal* で合わせて変換する。
+func (c CoordinateConverter) Orientation(q Quaternion) Quaternion {
+	localFlip := 1.0
+	if c.IsMirrored() {
+		localFlip = -1.0
+	}
+	xAxis := c.apply(q.MulVec3(UNIT_X_VEC3))
+	yAxis := c.apply(q.MulVec3(UNIT_Y_VEC3))
+	zAxis := c.apply(q.MulVec3(UNIT_Z_VEC3)).MuledScalar(localFlip)
+	return NewQuaternionFromAxes(xAxis, yAxis, zAxis).Normalized()
+}
+
+// OrientationLocalRanges は Orientation 変換後のローカル移動範囲を返す。
+func (c CoordinateConverter) OrientationLocalRanges(minLimit, maxLimit Vec3) (Vec3, Vec3) {
+	outMin, outMax := minLimit, maxLimit
+	if c.IsMirrored() {
+		outMin.Z, outMax.Z = -maxLimit.Z, -minLimit.Z
+	}
+	return outMin.MuledScalar(c.scale), outMax.MuledScalar(c.scale)
+}
+
+// OrientationLocalAngleLimits は Orientation 変換後のローカル角度範囲を返す。
+func (c CoordinateConverter) OrientationLocalAngleLimits(minLimit, maxLimit Vec3) (Vec3, Vec3) {
+	outMin, outMax := minLimit, maxLimit
+	if c.IsMirrored() {
+		// ローカルZ反転では軸性ベクトルのX/Y成分が反転する。
+		outMin.X, outMax.X = -maxLimit.X, -minLimit.X
+		outMin.Y, outMax.Y = -maxLimit.Y, -minLimit.Y
+	}
+	return outMin, outMax
+}
+
+// apply は基底行列を掛ける。
+func (c CoordinateConverter) apply(v Vec3) Vec3 {
+	out := NewVec3()
+	out.X = c.basis[0][0]*v.X + c.basis[0][1]*v.Y + c.basis[0][2]*v.Z
+	out.Y = c.basis[1][0]*v.X + c.basis[1][1]*v.Y + c.basis[1][2]*v.Z
+	out.Z = c.basis[2][0]*v.X + c.basis[2][1]*v.Y + c.basis[2][2]*v.Z
+	return out
+}
+
+// limits は軸ごとの範囲を置換し、符号反転した軸は min/max を入れ替える。
+func (c CoordinateConverter) limits(minLimit, maxLimit Vec3, sign float64) (Vec3, Vec3) {
+	src := [2][3]float64{{minLimit.X, minLimit.Y, minLimit.Z}, {maxLimit.X, maxLimit.Y, maxLimit.Z}}
+	var dst [2][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			factor := c.basis[i][j] * sign
+			if factor == 0 {
+				continue
+			}
+			lo, hi := src[0][j]*factor, src[1][j]*factor
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			dst[0][i], dst[1][i] = lo, hi
+		}
+	}
+	outMin, outMax := NewVec3(), NewVec3()
+	outMin.X, outMin.Y, outMin.Z = dst[0][0], dst[0][1], dst[0][2]
+	outMax.X, outMax.Y, outMax.Z = dst[1][0], dst[1][1], dst[1][2]
+	return outMin, outMax
+}
+
+// basisMat4 は基底行列にスケールを掛けた同次行列を返す。
+func (c CoordinateConverter) basisMat4(scale float64) Mat4 {
+	b := c.basis
+	return NewMat4ByValues(
+		b[0][0]*scale, b[1][0]*scale, b[2][0]*scale, 0,
+		b[0][1]*scale, b[1][1]*scale, b[2][1]*scale, 0,
+		b[0][2]*scale, b[1][2]*scale, b[2][2]*scale, 0,
+		0, 0, 0, 1,
+	)
+}
+
+// axisPolicyVectors は 右/上/前 の軸ベクトルを返す。
+func axisPolicyVectors(policy axis.AxisPolicy) ([3][3]float64, error) {
+	var out [3][3]float64
+	used := [3]bool{}
+	for k, dir := range []axis.AxisDir{policy.Right, policy.Up, policy.Forward} {
+		if dir.Axis < axis.AXIS_X || dir.Axis > axis.AXIS_Z || used[dir.Axis] {
+			return out, newMathAxisPolicyInvalid()
+		}
+		used[dir.Axis] = true
+		sign := 1.0
+		if dir.Sign == axis.AXIS_SIGN_NEG {
+			sign = -1.0
+		}
+		out[k][dir.Axis] = sign
+	}
+	// 右/上/前を並べた行列式が正なら右手系、負なら左手系。
+	d := signDet3(out)
+	if (d > 0) != (policy.Handedness == axis.HANDEDNESS_RIGHT) {
+		return out, newMathAxisPolicyInvalid()
+	}
+	return out, nil
+}
+
+// signDet3 は3x3行列の行列式の符号を返す。
+func signDet3(m [3][3]float64) float64 {
+	d := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	return math.Copysign(1, d)
+}
@@ -0,0 +1,90 @@
+// 指示: miu200521358
+package mmath
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/shared/contracts/axis"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// TestCoordinateConverterMmdToGltf はZ反転とメートル換算を確認する。
+func TestCoordinateConverterMmdToGltf(t *testing.T) {
+	c, err := NewCoordinateConverter(COORDINATE_SYSTEM_MMD, COORDINATE_SYSTEM_GLTF)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !c.IsMirrored() {
+		t.Fatalf("MMD->glTF should be mirrored")
+	}
+	got := c.Position(Vec3{r3.Vec{X: 1, Y: 2, Z: 3}})
+	if !got.NearEquals(Vec3{r3.Vec{X: 0.08, Y: 0.16, Z: -0.24}}, 1e-9) {
+		t.Fatalf("position mismatch: %v", got)
+	}
+
+	// 回転後の点を変換した結果と、変換した回転で変換した点を回した結果が一致すること。
+	q := NewQuaternionFromDegrees(30, 45, -20)
+	p := Vec3{r3.Vec{X: 0.3, Y: -1, Z: 2}}
+	expected := c.Direction(q.MulVec3(p))
+	actual := c.Quaternion(q).MulVec3(c.Direction(p))
+	if !expected.NearEquals(actual, 1e-9) {
+		t.Fatalf("quaternion mismatch: %v %v", expected, actual)
+	}
+
+	mat := NewMat4FromAxisAngle(UNIT_Y_VEC3, 0.7)
+	mat.Translate(Vec3{r3.Vec{X: 1, Y: 2, Z: 3}})
+	expected = c.Position(mat.MulVec3(p))
+	actual = c.Mat4(mat).MulVec3(c.Position(p))
+	if !expected.NearEquals(actual, 1e-9) {
+		t.Fatalf("mat4 mismatch: %v %v", expected, actual)
+	}
+}
+
+// TestCoordinateConverterOrientation は形状姿勢の変換が軸を保つことを確認する。
+func TestCoordinateConverterOrientation(t *testing.T) {
+	for _, to := range []CoordinateSystem{COORDINATE_SYSTEM_GLTF, COORDINATE_SYSTEM_BLENDER, COORDINATE_SYSTEM_UNITY} {
+		c, err := NewCoordinateConverter(COORDINATE_SYSTEM_MMD, to)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		q := NewQuaternionFromDegrees(10, 70, 35)
+		converted := c.Orientation(q)
+		if !converted.IsUnitQuat(1e-9) {
+			t.Fatalf("orientation should be a rotation: %v", converted)
+		}
+		// カプセル軸(ローカルY)の向きは点の変換と一致すること。
+		expected := c.Direction(q.MulVec3(UNIT_Y_VEC3))
+		actual := converted.MulVec3(UNIT_Y_VEC3)
+		if !expected.NearEquals(actual, 1e-9) {
+			t.Fatalf("orientation axis mismatch: %v %v", expected, actual)
+		}
+	}
+}
+
+// TestCoordinateConverterLimits は範囲の置換と反転を確認する。
+func TestCoordinateConverterLimits(t *testing.T) {
+	c, err := NewCoordinateConverter(COORDINATE_SYSTEM_MMD, COORDINATE_SYSTEM_BLENDER)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(c.Scale()-8) > 1e-9 && math.Abs(c.Scale()-0.08) > 1e-9 {
+		t.Fatalf("scale mismatch: %v", c.Scale())
+	}
+	// MMD(+Y上, -Z前) -> Blender(+Z上, -Y前): Y->Z, Z->Y。
+	minLimit, maxLimit := c.Ranges(Vec3{r3.Vec{X: -1, Y: -2, Z: -3}}, Vec3{r3.Vec{X: 1, Y: 2, Z: 4}})
+	if minLimit.Y > maxLimit.Y || math.Abs(maxLimit.Z-2*c.Scale()) > 1e-9 {
+		t.Fatalf("range mismatch: %v %v", minLimit, maxLimit)
+	}
+}
+
+// TestCoordinateConverterInvalidPolicy は不正な軸指定でエラーになることを確認する。
+func TestCoordinateConverterInvalidPolicy(t *testing.T) {
+	invalid := axis.AXIS_POLICY_GLTF
+	invalid.Handedness = axis.HANDEDNESS_LEFT
+	_, err := NewCoordinateConverter(COORDINATE_SYSTEM_MMD, CoordinateSystem{Axis: invalid})
+	if merr.ExtractErrorID(err) != mathAxisPolicyInvalidErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
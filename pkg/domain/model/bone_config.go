@@ -126,6 +126,7 @@ const (
 	UPPER_ROOT     StandardBoneName = "上半身根元"
 	UPPER          StandardBoneName = "上半身"
 	UPPER2         StandardBoneName = "上半身2"
+	UPPER3         StandardBoneName = "上半身3"
 	NECK_ROOT      StandardBoneName = "首根元"
 	NECK           StandardBoneName = "首"
 	HEAD           StandardBoneName = "頭"
@@ -175,6 +176,7 @@ const (
 	ANKLE          StandardBoneName = "{d}足首"
 	ANKLE_GROUND   StandardBoneName = "{d}足首地面"
 	HEEL           StandardBoneName = "{d}かかと"
+	TOE            StandardBoneName = "{d}つま先"
 	TOE_T          StandardBoneName = "{d}つま先先"
 	TOE_P          StandardBoneName = "{d}つま先親"
 	TOE_C          StandardBoneName = "{d}つま先子"
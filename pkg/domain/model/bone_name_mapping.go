@@ -0,0 +1,362 @@
+// 指示: miu200521358
+package model
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model/merrors"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/vrm"
+)
+
+// BoneNameConvention はボーン名の命名規則を表す。
+type BoneNameConvention string
+
+const (
+	// BONE_NAME_CONVENTION_MMD はMMD標準/準標準ボーン名。
+	BONE_NAME_CONVENTION_MMD BoneNameConvention = "mmd"
+	// BONE_NAME_CONVENTION_ENGLISH はPMXの英語ボーン名。
+	BONE_NAME_CONVENTION_ENGLISH BoneNameConvention = "english"
+	// BONE_NAME_CONVENTION_VRM0 はVRM0 humanoidのボーン名。
+	BONE_NAME_CONVENTION_VRM0 BoneNameConvention = "vrm0"
+	// BONE_NAME_CONVENTION_VRM1 はVRM1 humanoidのボーン名。
+	BONE_NAME_CONVENTION_VRM1 BoneNameConvention = "vrm1"
+	// BONE_NAME_CONVENTION_MIXAMO はMixamoのボーン名。
+	BONE_NAME_CONVENTION_MIXAMO BoneNameConvention = "mixamo"
+	// BONE_NAME_CONVENTION_UNITY はUnity Humanoidのボーン名。
+	BONE_NAME_CONVENTION_UNITY BoneNameConvention = "unity"
+)
+
+// BoneNameMapping は命名規則ごとのボーン名の対応を表す。
+// MMD名は必須で、名前に {d} を含む場合は左右それぞれに展開する。
+type BoneNameMapping map[BoneNameConvention]string
+
+// boneNameRow は組み込み対応表の1行を表す。
+// VRM 以外は {d} を含む名前を左右へ展開し、VRM は humanoid 定義の左右の名前をそのまま持つ。
+type boneNameRow struct {
+	mmd     string
+	english string
+	vrm0    vrmBoneName
+	vrm1    vrmBoneName
+	mixamo  string
+	unity   string
+}
+
+// vrmBoneName はVRM humanoidの左右それぞれのボーン名を表す。
+type vrmBoneName struct {
+	left  string
+	right string
+}
+
+// vrmCenter は左右のないVRM humanoidのボーン名を返す。
+func vrmCenter(name string) vrmBoneName {
+	return vrmBoneName{left: name, right: name}
+}
+
+// vrmSides は左右のあるVRM humanoidのボーン名を返す。
+func vrmSides(left, right string) vrmBoneName {
+	return vrmBoneName{left: left, right: right}
+}
+
+// byDirection は左右方向に応じた名前を返す。direction が空の場合は左右のない名前を返す。
+func (n vrmBoneName) byDirection(direction BoneDirection) string {
+	if direction == BONE_DIRECTION_RIGHT {
+		return n.right
+	}
+	return n.left
+}
+
+// builtinBoneNameRows は組み込みのボーン名対応表。VRM の名前は vrm パッケージの humanoid 定義を用いる。
+var builtinBoneNameRows = []boneNameRow{
+	{ROOT.String(), "master", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{CENTER.String(), "center", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{GROOVE.String(), "groove", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{WAIST.String(), "waist", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{LOWER.String(), "lower body", vrmCenter(vrm.VRM0_HUMAN_BONE_HIPS), vrmCenter(vrm.VRM1_HUMAN_BONE_HIPS), "mixamorig:Hips", "Hips"},
+	{UPPER.String(), "upper body", vrmCenter(vrm.VRM0_HUMAN_BONE_SPINE), vrmCenter(vrm.VRM1_HUMAN_BONE_SPINE), "mixamorig:Spine", "Spine"},
+	{UPPER2.String(), "upper body2", vrmCenter(vrm.VRM0_HUMAN_BONE_CHEST), vrmCenter(vrm.VRM1_HUMAN_BONE_CHEST), "mixamorig:Spine1", "Chest"},
+	{UPPER3.String(), "upper body3", vrmCenter(vrm.VRM0_HUMAN_BONE_UPPER_CHEST), vrmCenter(vrm.VRM1_HUMAN_BONE_UPPER_CHEST), "mixamorig:Spine2", "UpperChest"},
+	{NECK.String(), "neck", vrmCenter(vrm.VRM0_HUMAN_BONE_NECK), vrmCenter(vrm.VRM1_HUMAN_BONE_NECK), "mixamorig:Neck", "Neck"},
+	{HEAD.String(), "head", vrmCenter(vrm.VRM0_HUMAN_BONE_HEAD), vrmCenter(vrm.VRM1_HUMAN_BONE_HEAD), "mixamorig:Head", "Head"},
+	{EYES.String(), "eyes", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{EYE.String(), "eye_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_EYE, vrm.VRM0_HUMAN_BONE_RIGHT_EYE), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_EYE, vrm.VRM1_HUMAN_BONE_RIGHT_EYE), "mixamorig:{d}Eye", "{d}Eye"},
+	{SHOULDER_P.String(), "shoulderP_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{SHOULDER.String(), "shoulder_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_SHOULDER, vrm.VRM0_HUMAN_BONE_RIGHT_SHOULDER), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_SHOULDER, vrm.VRM1_HUMAN_BONE_RIGHT_SHOULDER), "mixamorig:{d}Shoulder", "{d}Shoulder"},
+	{SHOULDER_C.String(), "shoulderC_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{ARM.String(), "arm_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_UPPER_ARM, vrm.VRM0_HUMAN_BONE_RIGHT_UPPER_ARM), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_UPPER_ARM, vrm.VRM1_HUMAN_BONE_RIGHT_UPPER_ARM), "mixamorig:{d}Arm", "{d}UpperArm"},
+	{ARM_TWIST.String(), "arm twist_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{ARM_TWIST1.String(), "arm twist1_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{ARM_TWIST2.String(), "arm twist2_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{ARM_TWIST3.String(), "arm twist3_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{ELBOW.String(), "elbow_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_LOWER_ARM, vrm.VRM0_HUMAN_BONE_RIGHT_LOWER_ARM), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_LOWER_ARM, vrm.VRM1_HUMAN_BONE_RIGHT_LOWER_ARM), "mixamorig:{d}ForeArm", "{d}LowerArm"},
+	{WRIST_TWIST.String(), "wrist twist_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{WRIST_TWIST1.String(), "wrist twist1_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{WRIST_TWIST2.String(), "wrist twist2_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{WRIST_TWIST3.String(), "wrist twist3_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{WRIST.String(), "wrist_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_HAND, vrm.VRM0_HUMAN_BONE_RIGHT_HAND), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_HAND, vrm.VRM1_HUMAN_BONE_RIGHT_HAND), "mixamorig:{d}Hand", "{d}Hand"},
+	{THUMB0.String(), "thumb0_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_THUMB_PROXIMAL, vrm.VRM0_HUMAN_BONE_RIGHT_THUMB_PROXIMAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_THUMB_METACARPAL, vrm.VRM1_HUMAN_BONE_RIGHT_THUMB_METACARPAL), "mixamorig:{d}HandThumb1", "{d}ThumbProximal"},
+	{THUMB1.String(), "thumb1_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_THUMB_INTERMEDIATE, vrm.VRM0_HUMAN_BONE_RIGHT_THUMB_INTERMEDIATE), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_THUMB_PROXIMAL, vrm.VRM1_HUMAN_BONE_RIGHT_THUMB_PROXIMAL), "mixamorig:{d}HandThumb2", "{d}ThumbIntermediate"},
+	{THUMB2.String(), "thumb2_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_THUMB_DISTAL, vrm.VRM0_HUMAN_BONE_RIGHT_THUMB_DISTAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_THUMB_DISTAL, vrm.VRM1_HUMAN_BONE_RIGHT_THUMB_DISTAL), "mixamorig:{d}HandThumb3", "{d}ThumbDistal"},
+	{INDEX1.String(), "fore1_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_INDEX_PROXIMAL, vrm.VRM0_HUMAN_BONE_RIGHT_INDEX_PROXIMAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_INDEX_PROXIMAL, vrm.VRM1_HUMAN_BONE_RIGHT_INDEX_PROXIMAL), "mixamorig:{d}HandIndex1", "{d}IndexProximal"},
+	{INDEX2.String(), "fore2_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_INDEX_INTERMEDIATE, vrm.VRM0_HUMAN_BONE_RIGHT_INDEX_INTERMEDIATE), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_INDEX_INTERMEDIATE, vrm.VRM1_HUMAN_BONE_RIGHT_INDEX_INTERMEDIATE), "mixamorig:{d}HandIndex2", "{d}IndexIntermediate"},
+	{INDEX3.String(), "fore3_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_INDEX_DISTAL, vrm.VRM0_HUMAN_BONE_RIGHT_INDEX_DISTAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_INDEX_DISTAL, vrm.VRM1_HUMAN_BONE_RIGHT_INDEX_DISTAL), "mixamorig:{d}HandIndex3", "{d}IndexDistal"},
+	{MIDDLE1.String(), "middle1_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_MIDDLE_PROXIMAL, vrm.VRM0_HUMAN_BONE_RIGHT_MIDDLE_PROXIMAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_MIDDLE_PROXIMAL, vrm.VRM1_HUMAN_BONE_RIGHT_MIDDLE_PROXIMAL), "mixamorig:{d}HandMiddle1", "{d}MiddleProximal"},
+	{MIDDLE2.String(), "middle2_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_MIDDLE_INTERMEDIATE, vrm.VRM0_HUMAN_BONE_RIGHT_MIDDLE_INTERMEDIATE), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_MIDDLE_INTERMEDIATE, vrm.VRM1_HUMAN_BONE_RIGHT_MIDDLE_INTERMEDIATE), "mixamorig:{d}HandMiddle2", "{d}MiddleIntermediate"},
+	{MIDDLE3.String(), "middle3_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_MIDDLE_DISTAL, vrm.VRM0_HUMAN_BONE_RIGHT_MIDDLE_DISTAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_MIDDLE_DISTAL, vrm.VRM1_HUMAN_BONE_RIGHT_MIDDLE_DISTAL), "mixamorig:{d}HandMiddle3", "{d}MiddleDistal"},
+	{RING1.String(), "third1_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_RING_PROXIMAL, vrm.VRM0_HUMAN_BONE_RIGHT_RING_PROXIMAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_RING_PROXIMAL, vrm.VRM1_HUMAN_BONE_RIGHT_RING_PROXIMAL), "mixamorig:{d}HandRing1", "{d}RingProximal"},
+	{RING2.String(), "third2_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_RING_INTERMEDIATE, vrm.VRM0_HUMAN_BONE_RIGHT_RING_INTERMEDIATE), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_RING_INTERMEDIATE, vrm.VRM1_HUMAN_BONE_RIGHT_RING_INTERMEDIATE), "mixamorig:{d}HandRing2", "{d}RingIntermediate"},
+	{RING3.String(), "third3_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_RING_DISTAL, vrm.VRM0_HUMAN_BONE_RIGHT_RING_DISTAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_RING_DISTAL, vrm.VRM1_HUMAN_BONE_RIGHT_RING_DISTAL), "mixamorig:{d}HandRing3", "{d}RingDistal"},
+	{PINKY1.String(), "little1_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_LITTLE_PROXIMAL, vrm.VRM0_HUMAN_BONE_RIGHT_LITTLE_PROXIMAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_LITTLE_PROXIMAL, vrm.VRM1_HUMAN_BONE_RIGHT_LITTLE_PROXIMAL), "mixamorig:{d}HandPinky1", "{d}LittleProximal"},
+	{PINKY2.String(), "little2_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_LITTLE_INTERMEDIATE, vrm.VRM0_HUMAN_BONE_RIGHT_LITTLE_INTERMEDIATE), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_LITTLE_INTERMEDIATE, vrm.VRM1_HUMAN_BONE_RIGHT_LITTLE_INTERMEDIATE), "mixamorig:{d}HandPinky2", "{d}LittleIntermediate"},
+	{PINKY3.String(), "little3_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_LITTLE_DISTAL, vrm.VRM0_HUMAN_BONE_RIGHT_LITTLE_DISTAL), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_LITTLE_DISTAL, vrm.VRM1_HUMAN_BONE_RIGHT_LITTLE_DISTAL), "mixamorig:{d}HandPinky3", "{d}LittleDistal"},
+	{WAIST_CANCEL.String(), "waist cancel_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{LEG.String(), "leg_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_UPPER_LEG, vrm.VRM0_HUMAN_BONE_RIGHT_UPPER_LEG), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_UPPER_LEG, vrm.VRM1_HUMAN_BONE_RIGHT_UPPER_LEG), "mixamorig:{d}UpLeg", "{d}UpperLeg"},
+	{KNEE.String(), "knee_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_LOWER_LEG, vrm.VRM0_HUMAN_BONE_RIGHT_LOWER_LEG), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_LOWER_LEG, vrm.VRM1_HUMAN_BONE_RIGHT_LOWER_LEG), "mixamorig:{d}Leg", "{d}LowerLeg"},
+	{ANKLE.String(), "ankle_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_FOOT, vrm.VRM0_HUMAN_BONE_RIGHT_FOOT), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_FOOT, vrm.VRM1_HUMAN_BONE_RIGHT_FOOT), "mixamorig:{d}Foot", "{d}Foot"},
+	{TOE.String(), "toe_{d}", vrmSides(vrm.VRM0_HUMAN_BONE_LEFT_TOES, vrm.VRM0_HUMAN_BONE_RIGHT_TOES), vrmSides(vrm.VRM1_HUMAN_BONE_LEFT_TOES, vrm.VRM1_HUMAN_BONE_RIGHT_TOES), "mixamorig:{d}ToeBase", "{d}Toes"},
+	{LEG_D.String(), "leg D_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{KNEE_D.String(), "knee D_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{ANKLE_D.String(), "ankle D_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{TOE_EX.String(), "toe EX_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{LEG_IK.String(), "leg IK_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+	{TOE_IK.String(), "toe IK_{d}", vrmBoneName{}, vrmBoneName{}, "", ""},
+}
+
+// BoneNameRegistry は命名規則間のボーン名の双方向対応表を表す。
+// MMD名を中継して任意の命名規則間で変換する。
+type BoneNameRegistry struct {
+	mmdNames map[string]struct{}
+	toMmd    map[BoneNameConvention]map[string]string
+	fromMmd  map[BoneNameConvention]map[string]string
+	// localNames は名前空間を除いた名前ごとの、登録済みの名前を名前順で保持する。
+	localNames map[BoneNameConvention]map[string][]string
+}
+
+// NewBoneNameRegistry は組み込みの対応表を登録済みのレジストリを生成する。
+func NewBoneNameRegistry() *BoneNameRegistry {
+	r := &BoneNameRegistry{
+		mmdNames:   map[string]struct{}{},
+		toMmd:      map[BoneNameConvention]map[string]string{},
+		fromMmd:    map[BoneNameConvention]map[string]string{},
+		localNames: map[BoneNameConvention]map[string][]string{},
+	}
+	for _, row := range builtinBoneNameRows {
+		directions := []BoneDirection{""}
+		if strings.Contains(row.mmd, BONE_DIRECTION_PREFIX) {
+			directions = []BoneDirection{BONE_DIRECTION_LEFT, BONE_DIRECTION_RIGHT}
+		}
+		for _, direction := range directions {
+			mapping := BoneNameMapping{}
+			for convention, name := range map[BoneNameConvention]string{
+				BONE_NAME_CONVENTION_MMD:     row.mmd,
+				BONE_NAME_CONVENTION_ENGLISH: row.english,
+				BONE_NAME_CONVENTION_MIXAMO:  row.mixamo,
+				BONE_NAME_CONVENTION_UNITY:   row.unity,
+			} {
+				if name != "" {
+					mapping[convention] = expandBoneNameDirection(name, convention, direction)
+				}
+			}
+			if name := row.vrm0.byDirection(direction); name != "" {
+				mapping[BONE_NAME_CONVENTION_VRM0] = name
+			}
+			if name := row.vrm1.byDirection(direction); name != "" {
+				mapping[BONE_NAME_CONVENTION_VRM1] = name
+			}
+			_ = r.Register(mapping)
+		}
+	}
+	return r
+}
+
+// Register は対応を登録する。既存の対応は上書きする。
+func (r *BoneNameRegistry) Register(mapping BoneNameMapping) error {
+	mmdName := strings.TrimSpace(mapping[BONE_NAME_CONVENTION_MMD])
+	if mmdName == "" {
+		return merrors.NewBoneNameMappingInvalidError("mmd", nil)
+	}
+	if !strings.Contains(mmdName, BONE_DIRECTION_PREFIX) {
+		r.register(mapping, mmdName, "")
+		return nil
+	}
+	for _, direction := range []BoneDirection{BONE_DIRECTION_LEFT, BONE_DIRECTION_RIGHT} {
+		r.register(mapping, mmdName, direction)
+	}
+	return nil
+}
+
+// LoadJSON はJSON配列で記述された対応を登録する。
+// 例: [{"mmd": "{d}腕", "mixamo": "mixamorig:{d}Arm"}]
+func (r *BoneNameRegistry) LoadJSON(data []byte) error {
+	mappings := make([]BoneNameMapping, 0)
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return merrors.NewBoneNameMappingInvalidError("json", err)
+	}
+	for _, mapping := range mappings {
+		if strings.TrimSpace(mapping[BONE_NAME_CONVENTION_MMD]) == "" {
+			return merrors.NewBoneNameMappingInvalidError("mmd", nil)
+		}
+	}
+	for _, mapping := range mappings {
+		if err := r.Register(mapping); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToMmd は命名規則 convention の名前に対応するMMD名を返す。
+// 見つからない場合は名前空間(「mixamorig:」等)を除いて再検索する。
+func (r *BoneNameRegistry) ToMmd(convention BoneNameConvention, name string) (string, bool) {
+	if convention == BONE_NAME_CONVENTION_MMD {
+		_, ok := r.mmdNames[name]
+		return name, ok
+	}
+	names := r.toMmd[convention]
+	if mmdName, ok := names[name]; ok {
+		return mmdName, true
+	}
+	// 複数の候補が一致しても結果が変わらないよう、名前順で最初に一致したものを返す。
+	if keys := r.localNames[convention][localBoneName(name)]; len(keys) > 0 {
+		return names[keys[0]], true
+	}
+	return "", false
+}
+
+// localBoneName は名前空間(「mixamorig:」等)を除いた名前を返す。
+func localBoneName(name string) string {
+	if idx := strings.LastIndex(name, ":"); idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// FromMmd はMMD名に対応する命名規則 convention の名前を返す。
+func (r *BoneNameRegistry) FromMmd(convention BoneNameConvention, mmdName string) (string, bool) {
+	if convention == BONE_NAME_CONVENTION_MMD {
+		return r.ToMmd(convention, mmdName)
+	}
+	name, ok := r.fromMmd[convention][mmdName]
+	return name, ok
+}
+
+// Convert は命名規則 from の名前を命名規則 to の名前へ変換する。
+func (r *BoneNameRegistry) Convert(name string, from, to BoneNameConvention) (string, bool) {
+	mmdName, ok := r.ToMmd(from, name)
+	if !ok {
+		return "", false
+	}
+	return r.FromMmd(to, mmdName)
+}
+
+// FillEnglishNames はボーンの英語名を対応表から設定し、設定した件数を返す。
+// overwrite が false の場合は英語名が空のボーンのみ設定する。
+func (r *BoneNameRegistry) FillEnglishNames(bones *BoneCollection, overwrite bool) int {
+	if bones == nil {
+		return 0
+	}
+	count := 0
+	for _, bone := range bones.Values() {
+		if bone == nil || (!overwrite && bone.EnglishName != "") {
+			continue
+		}
+		englishName, ok := r.FromMmd(BONE_NAME_CONVENTION_ENGLISH, bone.Name())
+		if !ok || englishName == bone.EnglishName {
+			continue
+		}
+		bone.EnglishName = englishName
+		count++
+	}
+	return count
+}
+
+// register は左右を展開した対応を登録する。direction が空の場合は展開しない。
+func (r *BoneNameRegistry) register(mapping BoneNameMapping, mmdName string, direction BoneDirection) {
+	mmdName = expandBoneNameDirection(mmdName, BONE_NAME_CONVENTION_MMD, direction)
+	r.mmdNames[mmdName] = struct{}{}
+	for convention, name := range mapping {
+		if convention == BONE_NAME_CONVENTION_MMD || strings.TrimSpace(name) == "" {
+			continue
+		}
+		name = expandBoneNameDirection(strings.TrimSpace(name), convention, direction)
+		if _, ok := r.toMmd[convention]; !ok {
+			r.toMmd[convention] = map[string]string{}
+			r.fromMmd[convention] = map[string]string{}
+			r.localNames[convention] = map[string][]string{}
+		}
+		// 付け替えで古くなった双方向の対応を除く。
+		if previous, ok := r.fromMmd[convention][mmdName]; ok && previous != name {
+			r.removeName(convention, previous)
+		}
+		if previousMmd, ok := r.toMmd[convention][name]; ok && previousMmd != mmdName {
+			delete(r.fromMmd[convention], previousMmd)
+		}
+		r.addName(convention, name, mmdName)
+		r.fromMmd[convention][mmdName] = name
+	}
+}
+
+// addName は名前からMMD名への対応を登録する。
+func (r *BoneNameRegistry) addName(convention BoneNameConvention, name, mmdName string) {
+	if _, ok := r.toMmd[convention][name]; !ok {
+		local := localBoneName(name)
+		keys := r.localNames[convention][local]
+		i := sort.SearchStrings(keys, name)
+		keys = append(keys, "")
+		copy(keys[i+1:], keys[i:])
+		keys[i] = name
+		r.localNames[convention][local] = keys
+	}
+	r.toMmd[convention][name] = mmdName
+}
+
+// removeName は名前からMMD名への対応を削除する。
+func (r *BoneNameRegistry) removeName(convention BoneNameConvention, name string) {
+	if _, ok := r.toMmd[convention][name]; !ok {
+		return
+	}
+	delete(r.toMmd[convention], name)
+	local := localBoneName(name)
+	keys := r.localNames[convention][local]
+	if i := sort.SearchStrings(keys, name); i < len(keys) && keys[i] == name {
+		keys = append(keys[:i], keys[i+1:]...)
+	}
+	if len(keys) == 0 {
+		delete(r.localNames[convention], local)
+		return
+	}
+	r.localNames[convention][local] = keys
+}
+
+// expandBoneNameDirection は {d} を命名規則ごとの左右表記へ置き換える。
+func expandBoneNameDirection(name string, convention BoneNameConvention, direction BoneDirection) string {
+	if direction == "" {
+		return name
+	}
+	return strings.ReplaceAll(name, BONE_DIRECTION_PREFIX, boneNameDirectionWord(convention, direction))
+}
+
+// boneNameDirectionWord は命名規則ごとの左右表記を返す。
+func boneNameDirectionWord(convention BoneNameConvention, direction BoneDirection) string {
+	left := direction == BONE_DIRECTION_LEFT
+	switch convention {
+	case BONE_NAME_CONVENTION_MMD:
+		return string(direction)
+	case BONE_NAME_CONVENTION_ENGLISH:
+		if left {
+			return "L"
+		}
+		return "R"
+	case BONE_NAME_CONVENTION_VRM0, BONE_NAME_CONVENTION_VRM1:
+		if left {
+			return "left"
+		}
+		return "right"
+	default:
+		if left {
+			return "Left"
+		}
+		return "Right"
+	}
+}
@@ -0,0 +1,134 @@
+// 指示: miu200521358
+package model
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model/vrm"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// TestBoneNameRegistryConvert は命名規則間の変換を確認する。
+func TestBoneNameRegistryConvert(t *testing.T) {
+	r := NewBoneNameRegistry()
+	cases := []struct {
+		name     string
+		from     BoneNameConvention
+		to       BoneNameConvention
+		expected string
+	}{
+		{"左腕", BONE_NAME_CONVENTION_MMD, BONE_NAME_CONVENTION_VRM1, "leftUpperArm"},
+		{"rightLowerLeg", BONE_NAME_CONVENTION_VRM0, BONE_NAME_CONVENTION_MMD, "右ひざ"},
+		{"mixamorig:LeftForeArm", BONE_NAME_CONVENTION_MIXAMO, BONE_NAME_CONVENTION_UNITY, "LeftLowerArm"},
+		{"mixamorig1:Spine1", BONE_NAME_CONVENTION_MIXAMO, BONE_NAME_CONVENTION_MMD, "上半身2"},
+		{"Hips", BONE_NAME_CONVENTION_MIXAMO, BONE_NAME_CONVENTION_MMD, "下半身"},
+		{"leftThumbMetacarpal", BONE_NAME_CONVENTION_VRM1, BONE_NAME_CONVENTION_VRM0, "leftThumbProximal"},
+		{"右足ＩＫ", BONE_NAME_CONVENTION_MMD, BONE_NAME_CONVENTION_ENGLISH, "leg IK_R"},
+	}
+	for _, c := range cases {
+		got, ok := r.Convert(c.name, c.from, c.to)
+		if !ok || got != c.expected {
+			t.Fatalf("convert %s: got=%s ok=%v expected=%s", c.name, got, ok, c.expected)
+		}
+	}
+	if _, ok := r.Convert("センター", BONE_NAME_CONVENTION_MMD, BONE_NAME_CONVENTION_VRM1); ok {
+		t.Fatalf("center should not have a VRM humanoid slot")
+	}
+	if _, ok := r.ToMmd(BONE_NAME_CONVENTION_MMD, "存在しない"); ok {
+		t.Fatalf("unknown MMD name should not be found")
+	}
+}
+
+// TestBoneNameRegistryLoadJSON はJSONでの独自対応の登録を確認する。
+func TestBoneNameRegistryLoadJSON(t *testing.T) {
+	r := NewBoneNameRegistry()
+	data := []byte(`[{"mmd": "{d}腕", "mixamo": "Character1_{d}Arm"}, {"mmd": "しっぽ1", "english": "tail1", "custom": "Tail_01"}]`)
+	if err := r.LoadJSON(data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, ok := r.ToMmd(BONE_NAME_CONVENTION_MIXAMO, "Character1_RightArm"); !ok || got != "右腕" {
+		t.Fatalf("custom mapping not registered: %s", got)
+	}
+	if got, ok := r.FromMmd(BONE_NAME_CONVENTION_MIXAMO, "右腕"); !ok || got != "Character1_RightArm" {
+		t.Fatalf("custom mapping should override builtin: %s", got)
+	}
+	if _, ok := r.ToMmd(BONE_NAME_CONVENTION_MIXAMO, "mixamorig:RightArm"); ok {
+		t.Fatalf("overridden builtin name should be removed")
+	}
+	if got, ok := r.Convert("Tail_01", BoneNameConvention("custom"), BONE_NAME_CONVENTION_ENGLISH); !ok || got != "tail1" {
+		t.Fatalf("custom convention not registered: %s", got)
+	}
+
+	for _, invalid := range []string{`{`, `[{"vrm1": "hips"}]`} {
+		err := r.LoadJSON([]byte(invalid))
+		if err == nil {
+			t.Fatalf("expected error for %s", invalid)
+		}
+		if ce, ok := err.(*merr.CommonError); !ok || ce.ErrorID() != "12202" {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// TestBoneNameRegistryFillEnglishNames は英語名の自動設定を確認する。
+func TestBoneNameRegistryFillEnglishNames(t *testing.T) {
+	bones := NewBoneCollection(0)
+	for _, name := range []string{"センター", "左ひじ", "独自ボーン"} {
+		bones.Append(NewBoneByName(name))
+	}
+	center, _ := bones.GetByName("センター")
+	center.EnglishName = "Center"
+
+	r := NewBoneNameRegistry()
+	if count := r.FillEnglishNames(bones, false); count != 1 {
+		t.Fatalf("fill count mismatch: %d", count)
+	}
+	elbow, _ := bones.GetByName("左ひじ")
+	if elbow.EnglishName != "elbow_L" || center.EnglishName != "Center" {
+		t.Fatalf("english names mismatch: %s %s", elbow.EnglishName, center.EnglishName)
+	}
+	if count := r.FillEnglishNames(bones, true); count != 1 || center.EnglishName != "center" {
+		t.Fatalf("overwrite mismatch: %d %s", count, center.EnglishName)
+	}
+}
+
+// TestBoneNameRegistryToMmdFallbackDeterministic は名前空間を除いた再検索で候補が複数ある場合も結果が一定であることを確認する。
+func TestBoneNameRegistryToMmdFallbackDeterministic(t *testing.T) {
+	r := NewBoneNameRegistry()
+	for _, mapping := range []BoneNameMapping{
+		{BONE_NAME_CONVENTION_MMD: "尻尾2", BONE_NAME_CONVENTION_MIXAMO: "rigB:Tail"},
+		{BONE_NAME_CONVENTION_MMD: "尻尾1", BONE_NAME_CONVENTION_MIXAMO: "rigA:Tail"},
+	} {
+		if err := r.Register(mapping); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if name, ok := r.ToMmd(BONE_NAME_CONVENTION_MIXAMO, "Tail"); !ok || name != "尻尾1" {
+			t.Fatalf("fallback should be deterministic: %s %v", name, ok)
+		}
+	}
+}
+
+// TestBoneNameRegistryRegisterMovesName は別のMMD名へ付け替えた名前が元のMMD名から逆引きされないことを確認する。
+func TestBoneNameRegistryRegisterMovesName(t *testing.T) {
+	r := NewBoneNameRegistry()
+	if err := r.Register(BoneNameMapping{BONE_NAME_CONVENTION_MMD: "上半身3", BONE_NAME_CONVENTION_MIXAMO: "mixamorig:Spine1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, ok := r.ToMmd(BONE_NAME_CONVENTION_MIXAMO, "mixamorig:Spine1"); !ok || got != UPPER3.String() {
+		t.Fatalf("moved name should resolve to the new MMD name: %s %v", got, ok)
+	}
+	if got, ok := r.FromMmd(BONE_NAME_CONVENTION_MIXAMO, UPPER2.String()); ok {
+		t.Fatalf("stale reverse mapping should be removed: %s", got)
+	}
+	if _, ok := r.ToMmd(BONE_NAME_CONVENTION_MIXAMO, "mixamorig:Spine2"); ok {
+		t.Fatalf("replaced name should be removed")
+	}
+	if got, ok := r.ToMmd(BONE_NAME_CONVENTION_MIXAMO, "Spine1"); !ok || got != UPPER3.String() {
+		t.Fatalf("namespace fallback should follow the moved name: %s %v", got, ok)
+	}
+	if got, ok := r.FromMmd(BONE_NAME_CONVENTION_VRM1, "右足首"); !ok || got != vrm.VRM1_HUMAN_BONE_RIGHT_FOOT {
+		t.Fatalf("vrm humanoid name mismatch: %s", got)
+	}
+}
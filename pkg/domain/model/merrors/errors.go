@@ -9,13 +9,14 @@ import (
 )
 
 const (
-	invalidIndexErrorID     = "12201"
-	nameNotFoundErrorID     = "20001"
-	parentNotFoundErrorID   = "22201"
-	indexOutOfRangeErrorID  = "92202"
-	nameConflictErrorID     = "92203"
-	nameMismatchErrorID     = "92204"
-	modelCopyFailedErrorID  = "92201"
+	invalidIndexErrorID    = "12201"
+	boneNameMappingErrorID = "12202"
+	nameNotFoundErrorID    = "20001"
+	parentNotFoundErrorID  = "22201"
+	indexOutOfRangeErrorID = "92202"
+	nameConflictErrorID    = "92203"
+	nameMismatchErrorID    = "92204"
+	modelCopyFailedErrorID = "92201"
 )
 
 // IndexOutOfRangeError はインデックス範囲外エラーを表す。
@@ -180,6 +181,11 @@ func NewInvalidIndexError(index int) *merr.CommonError {
 	return merr.NewCommonError(invalidIndexErrorID, merr.ErrorKindValidate, "インデックスが無効です: %d", nil, index)
 }
 
+// NewBoneNameMappingInvalidError はボーン名対応表の不正エラーを生成する。
+func NewBoneNameMappingInvalidError(detail string, cause error) *merr.CommonError {
+	return merr.NewCommonError(boneNameMappingErrorID, merr.ErrorKindValidate, "ボーン名対応表が不正です: %s", cause, detail)
+}
+
 // ModelCopyFailed はモデルコピー失敗を表す。
 type ModelCopyFailed struct {
 	*merr.CommonError
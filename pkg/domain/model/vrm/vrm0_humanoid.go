@@ -11,3 +11,61 @@ type Vrm0HumanBone struct {
 type Vrm0Humanoid struct {
 	HumanBones []Vrm0HumanBone
 }
+
+// VRM0 humanoid のボーン名。
+const (
+	VRM0_HUMAN_BONE_HIPS                      = "hips"
+	VRM0_HUMAN_BONE_SPINE                     = "spine"
+	VRM0_HUMAN_BONE_CHEST                     = "chest"
+	VRM0_HUMAN_BONE_UPPER_CHEST               = "upperChest"
+	VRM0_HUMAN_BONE_NECK                      = "neck"
+	VRM0_HUMAN_BONE_HEAD                      = "head"
+	VRM0_HUMAN_BONE_LEFT_EYE                  = "leftEye"
+	VRM0_HUMAN_BONE_RIGHT_EYE                 = "rightEye"
+	VRM0_HUMAN_BONE_LEFT_SHOULDER             = "leftShoulder"
+	VRM0_HUMAN_BONE_RIGHT_SHOULDER            = "rightShoulder"
+	VRM0_HUMAN_BONE_LEFT_UPPER_ARM            = "leftUpperArm"
+	VRM0_HUMAN_BONE_RIGHT_UPPER_ARM           = "rightUpperArm"
+	VRM0_HUMAN_BONE_LEFT_LOWER_ARM            = "leftLowerArm"
+	VRM0_HUMAN_BONE_RIGHT_LOWER_ARM           = "rightLowerArm"
+	VRM0_HUMAN_BONE_LEFT_HAND                 = "leftHand"
+	VRM0_HUMAN_BONE_RIGHT_HAND                = "rightHand"
+	VRM0_HUMAN_BONE_LEFT_THUMB_PROXIMAL       = "leftThumbProximal"
+	VRM0_HUMAN_BONE_RIGHT_THUMB_PROXIMAL      = "rightThumbProximal"
+	VRM0_HUMAN_BONE_LEFT_THUMB_INTERMEDIATE   = "leftThumbIntermediate"
+	VRM0_HUMAN_BONE_RIGHT_THUMB_INTERMEDIATE  = "rightThumbIntermediate"
+	VRM0_HUMAN_BONE_LEFT_THUMB_DISTAL         = "leftThumbDistal"
+	VRM0_HUMAN_BONE_RIGHT_THUMB_DISTAL        = "rightThumbDistal"
+	VRM0_HUMAN_BONE_LEFT_INDEX_PROXIMAL       = "leftIndexProximal"
+	VRM0_HUMAN_BONE_RIGHT_INDEX_PROXIMAL      = "rightIndexProximal"
+	VRM0_HUMAN_BONE_LEFT_INDEX_INTERMEDIATE   = "leftIndexIntermediate"
+	VRM0_HUMAN_BONE_RIGHT_INDEX_INTERMEDIATE  = "rightIndexIntermediate"
+	VRM0_HUMAN_BONE_LEFT_INDEX_DISTAL         = "leftIndexDistal"
+	VRM0_HUMAN_BONE_RIGHT_INDEX_DISTAL        = "rightIndexDistal"
+	VRM0_HUMAN_BONE_LEFT_MIDDLE_PROXIMAL      = "leftMiddleProximal"
+	VRM0_HUMAN_BONE_RIGHT_MIDDLE_PROXIMAL     = "rightMiddleProximal"
+	VRM0_HUMAN_BONE_LEFT_MIDDLE_INTERMEDIATE  = "leftMiddleIntermediate"
+	VRM0_HUMAN_BONE_RIGHT_MIDDLE_INTERMEDIATE = "rightMiddleIntermediate"
+	VRM0_HUMAN_BONE_LEFT_MIDDLE_DISTAL        = "leftMiddleDistal"
+	VRM0_HUMAN_BONE_RIGHT_MIDDLE_DISTAL       = "rightMiddleDistal"
+	VRM0_HUMAN_BONE_LEFT_RING_PROXIMAL        = "leftRingProximal"
+	VRM0_HUMAN_BONE_RIGHT_RING_PROXIMAL       = "rightRingProximal"
+	VRM0_HUMAN_BONE_LEFT_RING_INTERMEDIATE    = "leftRingIntermediate"
+	VRM0_HUMAN_BONE_RIGHT_RING_INTERMEDIATE   = "rightRingIntermediate"
+	VRM0_HUMAN_BONE_LEFT_RING_DISTAL          = "leftRingDistal"
+	VRM0_HUMAN_BONE_RIGHT_RING_DISTAL         = "rightRingDistal"
+	VRM0_HUMAN_BONE_LEFT_LITTLE_PROXIMAL      = "leftLittleProximal"
+	VRM0_HUMAN_BONE_RIGHT_LITTLE_PROXIMAL     = "rightLittleProximal"
+	VRM0_HUMAN_BONE_LEFT_LITTLE_INTERMEDIATE  = "leftLittleIntermediate"
+	VRM0_HUMAN_BONE_RIGHT_LITTLE_INTERMEDIATE = "rightLittleIntermediate"
+	VRM0_HUMAN_BONE_LEFT_LITTLE_DISTAL        = "leftLittleDistal"
+	VRM0_HUMAN_BONE_RIGHT_LITTLE_DISTAL       = "rightLittleDistal"
+	VRM0_HUMAN_BONE_LEFT_UPPER_LEG            = "leftUpperLeg"
+	VRM0_HUMAN_BONE_RIGHT_UPPER_LEG           = "rightUpperLeg"
+	VRM0_HUMAN_BONE_LEFT_LOWER_LEG            = "leftLowerLeg"
+	VRM0_HUMAN_BONE_RIGHT_LOWER_LEG           = "rightLowerLeg"
+	VRM0_HUMAN_BONE_LEFT_FOOT                 = "leftFoot"
+	VRM0_HUMAN_BONE_RIGHT_FOOT                = "rightFoot"
+	VRM0_HUMAN_BONE_LEFT_TOES                 = "leftToes"
+	VRM0_HUMAN_BONE_RIGHT_TOES                = "rightToes"
+)
@@ -10,3 +10,62 @@ type Vrm1HumanBone struct {
 type Vrm1Humanoid struct {
 	HumanBones map[string]Vrm1HumanBone
 }
+
+// VRM1 humanoid のボーン名。
+// 親指は VRM0 から1段ずれ、付け根が Metacarpal となる。
+const (
+	VRM1_HUMAN_BONE_HIPS                      = "hips"
+	VRM1_HUMAN_BONE_SPINE                     = "spine"
+	VRM1_HUMAN_BONE_CHEST                     = "chest"
+	VRM1_HUMAN_BONE_UPPER_CHEST               = "upperChest"
+	VRM1_HUMAN_BONE_NECK                      = "neck"
+	VRM1_HUMAN_BONE_HEAD                      = "head"
+	VRM1_HUMAN_BONE_LEFT_EYE                  = "leftEye"
+	VRM1_HUMAN_BONE_RIGHT_EYE                 = "rightEye"
+	VRM1_HUMAN_BONE_LEFT_SHOULDER             = "leftShoulder"
+	VRM1_HUMAN_BONE_RIGHT_SHOULDER            = "rightShoulder"
+	VRM1_HUMAN_BONE_LEFT_UPPER_ARM            = "leftUpperArm"
+	VRM1_HUMAN_BONE_RIGHT_UPPER_ARM           = "rightUpperArm"
+	VRM1_HUMAN_BONE_LEFT_LOWER_ARM            = "leftLowerArm"
+	VRM1_HUMAN_BONE_RIGHT_LOWER_ARM           = "rightLowerArm"
+	VRM1_HUMAN_BONE_LEFT_HAND                 = "leftHand"
+	VRM1_HUMAN_BONE_RIGHT_HAND                = "rightHand"
+	VRM1_HUMAN_BONE_LEFT_THUMB_METACARPAL     = "leftThumbMetacarpal"
+	VRM1_HUMAN_BONE_RIGHT_THUMB_METACARPAL    = "rightThumbMetacarpal"
+	VRM1_HUMAN_BONE_LEFT_THUMB_PROXIMAL       = "leftThumbProximal"
+	VRM1_HUMAN_BONE_RIGHT_THUMB_PROXIMAL      = "rightThumbProximal"
+	VRM1_HUMAN_BONE_LEFT_THUMB_DISTAL         = "leftThumbDistal"
+	VRM1_HUMAN_BONE_RIGHT_THUMB_DISTAL        = "rightThumbDistal"
+	VRM1_HUMAN_BONE_LEFT_INDEX_PROXIMAL       = "leftIndexProximal"
+	VRM1_HUMAN_BONE_RIGHT_INDEX_PROXIMAL      = "rightIndexProximal"
+	VRM1_HUMAN_BONE_LEFT_INDEX_INTERMEDIATE   = "leftIndexIntermediate"
+	VRM1_HUMAN_BONE_RIGHT_INDEX_INTERMEDIATE  = "rightIndexIntermediate"
+	VRM1_HUMAN_BONE_LEFT_INDEX_DISTAL         = "leftIndexDistal"
+	VRM1_HUMAN_BONE_RIGHT_INDEX_DISTAL        = "rightIndexDistal"
+	VRM1_HUMAN_BONE_LEFT_MIDDLE_PROXIMAL      = "leftMiddleProximal"
+	VRM1_HUMAN_BONE_RIGHT_MIDDLE_PROXIMAL     = "rightMiddleProximal"
+	VRM1_HUMAN_BONE_LEFT_MIDDLE_INTERMEDIATE  = "leftMiddleIntermediate"
+	VRM1_HUMAN_BONE_RIGHT_MIDDLE_INTERMEDIATE = "rightMiddleIntermediate"
+	VRM1_HUMAN_BONE_LEFT_MIDDLE_DISTAL        = "leftMiddleDistal"
+	VRM1_HUMAN_BONE_RIGHT_MIDDLE_DISTAL       = "rightMiddleDistal"
+	VRM1_HUMAN_BONE_LEFT_RING_PROXIMAL        = "leftRingProximal"
+	VRM1_HUMAN_BONE_RIGHT_RING_PROXIMAL       = "rightRingProximal"
+	VRM1_HUMAN_BONE_LEFT_RING_INTERMEDIATE    = "leftRingIntermediate"
+	VRM1_HUMAN_BONE_RIGHT_RING_INTERMEDIATE   = "rightRingIntermediate"
+	VRM1_HUMAN_BONE_LEFT_RING_DISTAL          = "leftRingDistal"
+	VRM1_HUMAN_BONE_RIGHT_RING_DISTAL         = "rightRingDistal"
+	VRM1_HUMAN_BONE_LEFT_LITTLE_PROXIMAL      = "leftLittleProximal"
+	VRM1_HUMAN_BONE_RIGHT_LITTLE_PROXIMAL     = "rightLittleProximal"
+	VRM1_HUMAN_BONE_LEFT_LITTLE_INTERMEDIATE  = "leftLittleIntermediate"
+	VRM1_HUMAN_BONE_RIGHT_LITTLE_INTERMEDIATE = "rightLittleIntermediate"
+	VRM1_HUMAN_BONE_LEFT_LITTLE_DISTAL        = "leftLittleDistal"
+	VRM1_HUMAN_BONE_RIGHT_LITTLE_DISTAL       = "rightLittleDistal"
+	VRM1_HUMAN_BONE_LEFT_UPPER_LEG            = "leftUpperLeg"
+	VRM1_HUMAN_BONE_RIGHT_UPPER_LEG           = "rightUpperLeg"
+	VRM1_HUMAN_BONE_LEFT_LOWER_LEG            = "leftLowerLeg"
+	VRM1_HUMAN_BONE_RIGHT_LOWER_LEG           = "rightLowerLeg"
+	VRM1_HUMAN_BONE_LEFT_FOOT                 = "leftFoot"
+	VRM1_HUMAN_BONE_RIGHT_FOOT                = "rightFoot"
+	VRM1_HUMAN_BONE_LEFT_TOES                 = "leftToes"
+	VRM1_HUMAN_BONE_RIGHT_TOES                = "rightToes"
+)
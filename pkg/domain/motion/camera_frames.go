@@ -1,7 +1,11 @@
 // 指示: miu200521358
 package motion
 
-import "github.com/miu200521358/mlib_go/pkg/domain/mmath"
+import (
+	"math"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+)
 
 // CameraFrame はカメラフレームを表す。
 type CameraFrame struct {
@@ -209,6 +213,153 @@ func (c *CameraFrames) Indexes() []int {
 	return indexes
 }
 
+// Reduce は変曲点抽出と曲線当てはめで削減した新しいフレーム集合を返す。元の集合は変更しない。
+// 最小から最大フレームまでの各整数フレームを標本とし、曲線で表せない区間は二分してキーを残す。
+func (c *CameraFrames) Reduce() *CameraFrames {
+	if c == nil {
+		return NewCameraFrames()
+	}
+	minFrame := c.MinFrame()
+	maxFrame := c.MaxFrame()
+	count := int(maxFrame-minFrame) + 1
+	if c.Len() <= 2 || count <= 2 {
+		return c.copyFrames()
+	}
+
+	samples := make([]*CameraFrame, count)
+	frames := make([]Frame, count)
+	channels := make([][]float64, cameraReduceChannelCount)
+	for i := range channels {
+		channels[i] = make([]float64, count)
+	}
+	for i := 0; i < count; i++ {
+		frame := minFrame + Frame(i)
+		cf := c.Get(frame)
+		samples[i] = cf
+		frames[i] = frame
+		for ch, value := range cameraChannelValues(cf) {
+			channels[ch][i] = value
+		}
+	}
+
+	keyFrames := []Frame{minFrame, maxFrame}
+	for _, values := range channels {
+		if !mmath.IsAllSameValues(values) {
+			keyFrames = append(keyFrames, findInflectionFrames(frames, values, 1e-4)...)
+		}
+	}
+	for i := 1; i < count; i++ {
+		if samples[i].IsPerspectiveOff != samples[i-1].IsPerspectiveOff {
+			keyFrames = append(keyFrames, frames[i-1], frames[i])
+		}
+	}
+	keyFrames = mmath.Unique(keyFrames)
+	mmath.Sort(keyFrames)
+
+	reduced := NewCameraFrames()
+	first := samples[0].copyWithIndex(minFrame)
+	first.Curves = NewCameraCurves()
+	reduced.Append(first)
+	for i := 1; i < len(keyFrames); i++ {
+		startI := int(keyFrames[i-1] - minFrame)
+		endI := int(keyFrames[i] - minFrame)
+		reduceCameraRange(startI, endI, minFrame, samples, channels, reduced)
+	}
+	return reduced
+}
+
+// copyFrames は各フレームを複製した新しいフレーム集合を返す。
+func (c *CameraFrames) copyFrames() *CameraFrames {
+	copied := NewCameraFrames()
+	c.ForEach(func(frame Frame, cf *CameraFrame) bool {
+		copied.Append(cf.copyWithIndex(frame))
+		return true
+	})
+	return copied
+}
+
+// cameraReduceChannelCount は削減で比較する値の数。
+const cameraReduceChannelCount = 8
+
+// cameraChannelValues は削減で比較する値を位置XYZ/角度XYZ/距離/視野角の順で返す。
+func cameraChannelValues(cf *CameraFrame) [cameraReduceChannelCount]float64 {
+	pos := vec3OrZero(cf.Position)
+	deg := vec3OrZero(cf.Degrees)
+	return [cameraReduceChannelCount]float64{
+		pos.X, pos.Y, pos.Z, deg.X, deg.Y, deg.Z, cf.Distance, float64(cf.ViewOfAngle),
+	}
+}
+
+// reduceCameraRange は区間を1つの曲線で表せればキーを追加し、表せなければ二分して再試行する。
+func reduceCameraRange(startI, endI int, minFrame Frame, samples []*CameraFrame, channels [][]float64, reduced *CameraFrames) {
+	if endI-startI > 1 {
+		curves := fitCameraCurves(startI, endI, channels)
+		if curves != nil && checkCameraCurves(curves, startI, endI, channels) {
+			appendReducedCameraFrame(samples[endI], minFrame+Frame(endI), curves, reduced)
+			return
+		}
+		midI := (startI + endI) / 2
+		reduceCameraRange(startI, midI, minFrame, samples, channels, reduced)
+		reduceCameraRange(midI, endI, minFrame, samples, channels, reduced)
+		return
+	}
+	appendReducedCameraFrame(samples[endI], minFrame+Frame(endI), NewCameraCurves(), reduced)
+}
+
+// fitCameraCurves は区間の各値に曲線を当てはめる。角度は変化量が最大の成分で当てはめる。
+// 当てはめに失敗した場合は nil を返す。
+func fitCameraCurves(startI, endI int, channels [][]float64) *CameraCurves {
+	rotateCh := 3
+	for ch := 4; ch <= 5; ch++ {
+		if math.Abs(channels[ch][endI]-channels[ch][startI]) > math.Abs(channels[rotateCh][endI]-channels[rotateCh][startI]) {
+			rotateCh = ch
+		}
+	}
+	fitted := make([]*mmath.Curve, 0, 6)
+	for _, ch := range []int{0, 1, 2, rotateCh, 6, 7} {
+		curve, err := mmath.NewCurveFromValues(channels[ch][startI:endI+1], 1e-2)
+		if err != nil {
+			return nil
+		}
+		fitted = append(fitted, curve)
+	}
+	curves := NewCameraCurves()
+	curves.TranslateX = fitted[0]
+	curves.TranslateY = fitted[1]
+	curves.TranslateZ = fitted[2]
+	curves.Rotate = fitted[3]
+	curves.Distance = fitted[4]
+	curves.ViewOfAngle = fitted[5]
+	curves.Values = curves.Merge()
+	return curves
+}
+
+// checkCameraCurves は曲線による補間値が区間内の全標本と近似一致するか判定する。
+func checkCameraCurves(curves *CameraCurves, startI, endI int, channels [][]float64) bool {
+	for i := startI + 1; i < endI; i++ {
+		xy, yy, zy, ry, dy, vy := curves.Evaluate(Frame(startI), Frame(i), Frame(endI))
+		for ch, t := range [cameraReduceChannelCount]float64{xy, yy, zy, ry, ry, ry, dy, vy} {
+			tolerance := 1e-2
+			if ch >= 3 && ch <= 5 {
+				tolerance = 1e-1
+			} else if ch == 7 {
+				tolerance = 1
+			}
+			if !mmath.NearEquals(mmath.Lerp(channels[ch][startI], channels[ch][endI], t), channels[ch][i], tolerance) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// appendReducedCameraFrame は削減結果へキーを追加する。
+func appendReducedCameraFrame(src *CameraFrame, index Frame, curves *CameraCurves, reduced *CameraFrames) {
+	cf := src.copyWithIndex(index)
+	cf.Curves = curves
+	reduced.Append(cf)
+}
+
 // nilCameraFrame は既定の空フレームを返す。
 func nilCameraFrame() *CameraFrame {
 	return nil
@@ -78,3 +78,52 @@ func TestCameraFramesClean(t *testing.T) {
 		t.Fatalf("Clean should delete default")
 	}
 }
+
+// TestCameraFramesReduce は直線区間の削減とカットの保持を確認する。
+func TestCameraFramesReduce(t *testing.T) {
+	frames := NewCameraFrames()
+	for i := 0; i <= 40; i++ {
+		cf := NewCameraFrame(Frame(i))
+		x := float64(i)
+		if i >= 20 {
+			// 20フレーム目でカットする。
+			x = 100 - float64(i)
+		}
+		cf.Position = vec3Ptr(x, 10, 0)
+		cf.Degrees = vec3Ptr(0, float64(i)*2, 0)
+		cf.Distance = -45
+		cf.ViewOfAngle = 30
+		frames.Append(cf)
+	}
+
+	reduced := frames.Reduce()
+	if reduced.Len() >= frames.Len() || !reduced.Has(19) || !reduced.Has(20) {
+		t.Fatalf("reduced keys mismatch: %v", reduced.Indexes())
+	}
+	for i := 0; i <= 40; i++ {
+		want := frames.Get(Frame(i))
+		got := reduced.Get(Frame(i))
+		if !got.Position.NearEquals(*want.Position, 1e-1) || !got.Degrees.NearEquals(*want.Degrees, 1e-1) {
+			t.Fatalf("reduced value mismatch at %d: %v %v", i, got.Position, want.Position)
+		}
+	}
+}
+
+// TestCameraFramesReduceReturnsCopy は削減不要な場合も元の集合と独立した集合を返すことを確認する。
+func TestCameraFramesReduceReturnsCopy(t *testing.T) {
+	frames := NewCameraFrames()
+	for _, frame := range []Frame{0, 10} {
+		cf := NewCameraFrame(frame)
+		cf.Position = vec3Ptr(float64(frame), 0, 0)
+		frames.Append(cf)
+	}
+	reduced := frames.Reduce()
+	if reduced == frames || reduced.Len() != 2 {
+		t.Fatalf("reduce should return a new collection: %v", reduced.Indexes())
+	}
+	reduced.Get(10).Position.X = 99
+	reduced.Delete(0)
+	if frames.Len() != 2 || frames.Get(10).Position.X != 10 {
+		t.Fatalf("original frames should not be modified: %v %v", frames.Indexes(), frames.Get(10).Position)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
 	"github.com/miu200521358/mlib_go/pkg/shared/contracts/mtime"
 	"github.com/miu200521358/mlib_go/pkg/shared/state"
+	"github.com/miu200521358/mlib_go/pkg/usecase/mhistory"
 	"github.com/miu200521358/walk/pkg/declarative"
 	"github.com/miu200521358/walk/pkg/walk"
 )
@@ -111,6 +112,12 @@ type ControlWindow struct {
 	logLevelPhysicsVerboseAction       *walk.Action
 	logLevelViewerVerboseAction        *walk.Action
 	linkWindowAction                   *walk.Action
+	undoAction                         *walk.Action
+	redoAction                         *walk.Action
+
+	history *mhistory.History
+	// closing は終了確認ダイアログの表示中に再度閉じる要求を受けた場合の二重表示を防ぐ。
+	closing bool
 
 	verboseSinks map[logging.VerboseIndex]logging.IVerboseSink
 }
@@ -144,6 +151,7 @@ func NewControlWindow(shared *state.SharedState, baseServices base.IBaseServices
 		logger:       logger,
 		verboseSinks: map[logging.VerboseIndex]logging.IVerboseSink{},
 		viewerCount:  viewerCount,
+		history:      mhistory.NewHistory(mhistory.DEFAULT_HISTORY_LIMIT),
 	}
 
 	controllerItems := cw.buildControllerMenuItems()
@@ -153,6 +161,7 @@ func NewControlWindow(shared *state.SharedState, baseServices base.IBaseServices
 
 	menuItems := []declarative.MenuItem{
 		cw.buildViewerMenu(),
+		cw.buildEditMenu(),
 		declarative.Menu{Text: cw.t(messages.ControlWindowKey001), Items: controllerItems},
 	}
 	if len(toolMenuItems) > 0 {
@@ -225,6 +234,8 @@ func NewControlWindow(shared *state.SharedState, baseServices base.IBaseServices
 	cw.shared.SetFrame(0)
 	cw.shared.SetMaxFrame(1)
 	cw.SetPhysicsEnabled(true)
+	cw.history.SetOnChanged(cw.onHistoryChanged)
+	cw.onHistoryChanged()
 
 	cw.applyUserConfig()
 	return cw, nil
@@ -703,6 +714,23 @@ func (cw *ControlWindow) appTitle() string {
 
 // onClosing はウィンドウ終了時の処理を行う。
 func (cw *ControlWindow) onClosing(canceled *bool) {
+	if cw.closing {
+		// 確認ダイアログの応答待ちのため、後続の終了要求は取り消す。
+		*canceled = true
+		return
+	}
+	cw.closing = true
+	defer func() { cw.closing = false }()
+
+	if !cw.shared.IsClosed() && cw.IsDirty() {
+		if !cw.confirmDiscardChanges() {
+			*canceled = true
+			return
+		}
+		// 破棄の確認で終了を承認済みのため、終了確認は重ねて表示しない。
+		cw.shared.SetClosed(true)
+		return
+	}
 	if cw.appConfig == nil || !cw.appConfig.IsCloseConfirmEnabled() {
 		cw.shared.SetClosed(true)
 		return
@@ -0,0 +1,133 @@
+//go:build windows
+// +build windows
+
+// 指示: miu200521358
+package controller
+
+import (
+	"github.com/miu200521358/mlib_go/pkg/adapter/mpresenter/messages"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/infra/base/err"
+	"github.com/miu200521358/mlib_go/pkg/usecase/mhistory"
+	portio "github.com/miu200521358/mlib_go/pkg/usecase/port/io"
+	"github.com/miu200521358/walk/pkg/declarative"
+	"github.com/miu200521358/walk/pkg/walk"
+)
+
+// History は編集履歴を返す。
+func (cw *ControlWindow) History() *mhistory.History {
+	return cw.history
+}
+
+// ExecuteCommand は編集操作を実行して履歴に積む。
+func (cw *ControlWindow) ExecuteCommand(command mhistory.ICommand) error {
+	return cw.history.Execute(command)
+}
+
+// MarkSaved は現在の編集状態を保存済みとして記録する。
+func (cw *ControlWindow) MarkSaved() {
+	cw.history.MarkSaved()
+}
+
+// SaveModel はモデルを保存し、保存できた場合は編集状態を保存済みとして記録する。
+func (cw *ControlWindow) SaveModel(
+	writer portio.IFileWriter,
+	path string,
+	modelData *model.PmxModel,
+	opts portio.SaveOptions,
+) error {
+	if saveErr := writer.Save(path, modelData, opts); saveErr != nil {
+		return saveErr
+	}
+	cw.MarkSaved()
+	return nil
+}
+
+// SaveMotion はモーションを保存し、保存できた場合は編集状態を保存済みとして記録する。
+func (cw *ControlWindow) SaveMotion(
+	writer portio.IFileWriter,
+	path string,
+	motionData *motion.VmdMotion,
+	opts portio.SaveOptions,
+) error {
+	if saveErr := writer.Save(path, motionData, opts); saveErr != nil {
+		return saveErr
+	}
+	cw.MarkSaved()
+	return nil
+}
+
+// IsDirty は未保存の変更があるか判定する。
+func (cw *ControlWindow) IsDirty() bool {
+	return cw.history.IsDirty()
+}
+
+// TriggerUndo は直前の編集を取り消す。
+func (cw *ControlWindow) TriggerUndo() {
+	if _, undoErr := cw.history.Undo(); undoErr != nil {
+		cw.loggerOrDefault().Error(cw.t(messages.ControlWindowKey110), undoErr.Error())
+		walk.MsgBox(cw, cw.t(messages.ControlWindowKey108), err.BuildErrorText(undoErr), walk.MsgBoxIconError)
+	}
+}
+
+// TriggerRedo は取り消した編集を再実行する。
+func (cw *ControlWindow) TriggerRedo() {
+	if _, redoErr := cw.history.Redo(); redoErr != nil {
+		cw.loggerOrDefault().Error(cw.t(messages.ControlWindowKey111), redoErr.Error())
+		walk.MsgBox(cw, cw.t(messages.ControlWindowKey109), err.BuildErrorText(redoErr), walk.MsgBoxIconError)
+	}
+}
+
+// buildEditMenu は編集メニューを構築する。
+func (cw *ControlWindow) buildEditMenu() declarative.Menu {
+	return declarative.Menu{
+		Text: cw.t(messages.ControlWindowKey112),
+		Items: []declarative.MenuItem{
+			declarative.Action{
+				Text:        cw.t(messages.ControlWindowKey108),
+				OnTriggered: cw.TriggerUndo,
+				AssignTo:    &cw.undoAction,
+				Shortcut:    declarative.Shortcut{Modifiers: walk.ModControl, Key: walk.KeyZ},
+			},
+			declarative.Action{
+				Text:        cw.t(messages.ControlWindowKey109),
+				OnTriggered: cw.TriggerRedo,
+				AssignTo:    &cw.redoAction,
+				Shortcut:    declarative.Shortcut{Modifiers: walk.ModControl, Key: walk.KeyY},
+			},
+		},
+	}
+}
+
+// onHistoryChanged は履歴の変化をメニューとタイトルへ反映する。
+func (cw *ControlWindow) onHistoryChanged() {
+	if cw.undoAction != nil {
+		_ = cw.undoAction.SetEnabled(cw.history.CanUndo())
+	}
+	if cw.redoAction != nil {
+		_ = cw.redoAction.SetEnabled(cw.history.CanRedo())
+	}
+	if cw.MainWindow == nil {
+		return
+	}
+	title := cw.appTitle()
+	if cw.history.IsDirty() {
+		title += " *"
+	}
+	_ = cw.SetTitle(title)
+}
+
+// confirmDiscardChanges は未保存の変更を破棄してよいか確認する。変更が無い場合は true を返す。
+func (cw *ControlWindow) confirmDiscardChanges() bool {
+	if !cw.history.IsDirty() {
+		return true
+	}
+	result := walk.MsgBox(
+		cw,
+		cw.t(messages.ControlWindowKey005),
+		cw.t(messages.ControlWindowKey113),
+		walk.MsgBoxIconWarning|walk.MsgBoxOKCancel,
+	)
+	return result == walk.DlgCmdOK
+}
@@ -24,8 +24,10 @@ import (
 	"github.com/miu200521358/dds/pkg/dds"
 	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
 	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/infra/file/mfile"
 	"github.com/miu200521358/mlib_go/pkg/shared/base/logging"
 	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	portio "github.com/miu200521358/mlib_go/pkg/usecase/port/io"
 	"golang.org/x/image/bmp"
 	_ "golang.org/x/image/riff"
 	_ "golang.org/x/image/tiff"
@@ -103,6 +105,7 @@ func (texGl *textureGl) delete() {
 type TextureManager struct {
 	textures     []*textureGl
 	toonTextures []*textureGl
+	pathResolver portio.ITexturePathResolver
 }
 
 // NewTextureManager はTextureManagerを生成する。
@@ -113,6 +116,15 @@ func NewTextureManager() *TextureManager {
 	}
 }
 
+// SetPathResolver はテクスチャパスの解決処理を設定する。
+// 設定した解決処理のキャッシュはロードごとに破棄する。未設定の場合はロードごとに新しい解決処理を使う。
+func (tm *TextureManager) SetPathResolver(resolver portio.ITexturePathResolver) {
+	if tm == nil || resolver == nil {
+		return
+	}
+	tm.pathResolver = resolver
+}
+
 // LoadAllTextures はモデルに紐づくテクスチャをロードする。
 func (tm *TextureManager) LoadAllTextures(windowIndex int, textures *model.TextureCollection, modelPath string) error {
 	return tm.LoadAllTexturesWithTypes(windowIndex, textures, modelPath, nil)
@@ -126,6 +138,12 @@ func (tm *TextureManager) LoadAllTexturesWithTypes(windowIndex int, textures *mo
 	var loadErr error
 	// インデックスで参照できるようにスライスを確保する
 	tm.textures = make([]*textureGl, textures.Len())
+	resolver := tm.pathResolver
+	if resolver == nil {
+		resolver = mfile.NewTexturePathResolver()
+	} else {
+		resolver.ClearCache()
+	}
 
 	for _, texture := range textures.Values() {
 		if texture == nil || !texture.IsValid() {
@@ -137,7 +155,7 @@ func (tm *TextureManager) LoadAllTexturesWithTypes(windowIndex int, textures *mo
 				texType = resolved
 			}
 		}
-		texGl, err := tm.loadTextureGl(windowIndex, texture, modelPath, texType)
+		texGl, err := tm.loadTextureGl(windowIndex, texture, modelPath, texType, resolver)
 		if err != nil {
 			if loadErr == nil {
 				loadErr = err
@@ -259,7 +277,13 @@ func (tm *TextureManager) Delete() {
 }
 
 // loadTextureGl は単一テクスチャをOpenGL向けにロードする。
-func (tm *TextureManager) loadTextureGl(windowIndex int, texture *model.Texture, modelPath string, textureType model.TextureType) (*textureGl, error) {
+func (tm *TextureManager) loadTextureGl(
+	windowIndex int,
+	texture *model.Texture,
+	modelPath string,
+	textureType model.TextureType,
+	resolver portio.ITexturePathResolver,
+) (*textureGl, error) {
 	if texture == nil || texture.Name() == "" {
 		return nil, merr.NewImagePackageError("テクスチャ名が不正です", nil)
 	}
@@ -270,8 +294,11 @@ func (tm *TextureManager) loadTextureGl(windowIndex int, texture *model.Texture,
 		TextureType: textureType,
 	}
 
-	// モデルパス + テクスチャ相対パス
+	// モデルパス + テクスチャ相対パス(区切り・大文字小文字・拡張子の違いを解決する)
 	texPath := filepath.Join(filepath.Dir(modelPath), texture.Name())
+	if resolver != nil {
+		texPath, _ = resolver.ResolveTexturePath(modelPath, texture.Name())
+	}
 
 	img, err := loadImageFromFile(texPath)
 	if err != nil {
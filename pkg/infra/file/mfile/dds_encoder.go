@@ -0,0 +1,266 @@
+// 指示: miu200521358
+package mfile
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"io"
+)
+
+// DdsFormat はDDSの圧縮形式を表す。
+type DdsFormat int
+
+const (
+	// DDS_FORMAT_AUTO はアルファを使っていれば BC3、使っていなければ BC1 を選ぶ。
+	DDS_FORMAT_AUTO DdsFormat = iota
+	// DDS_FORMAT_BC1 はアルファなしの BC1(DXT1)。
+	DDS_FORMAT_BC1
+	// DDS_FORMAT_BC3 は8bitアルファ付きの BC3(DXT5)。
+	DDS_FORMAT_BC3
+)
+
+const (
+	ddsHeaderSize      = 124
+	ddsPixelFormatSize = 32
+	ddsFlagCaps        = 0x1
+	ddsFlagHeight      = 0x2
+	ddsFlagWidth       = 0x4
+	ddsFlagPixelFormat = 0x1000
+	ddsFlagMipmapCount = 0x20000
+	ddsFlagLinearSize  = 0x80000
+	ddsPixelFourCC     = 0x4
+	ddsCapsComplex     = 0x8
+	ddsCapsTexture     = 0x1000
+	ddsCapsMipmap      = 0x400000
+)
+
+// DdsOptions はDDS書き出しのオプションを表す。
+type DdsOptions struct {
+	Format DdsFormat
+	// Mipmaps は1x1までのミップマップを含めるか示す。
+	Mipmaps bool
+}
+
+// EncodeDDS は画像をBC1/BC3圧縮のDDSとして書き込む。
+func EncodeDDS(w io.Writer, img image.Image, opts DdsOptions) error {
+	level := toNRGBA(img)
+	format := opts.Format
+	if format == DDS_FORMAT_AUTO {
+		format = DDS_FORMAT_BC1
+		if !level.Opaque() {
+			format = DDS_FORMAT_BC3
+		}
+	}
+	width, height := level.Rect.Dx(), level.Rect.Dy()
+	mipmapCount := 1
+	if opts.Mipmaps {
+		for size := max(width, height); size > 1; size /= 2 {
+			mipmapCount++
+		}
+	}
+	if err := writeDdsHeader(w, width, height, mipmapCount, format); err != nil {
+		return err
+	}
+	for i := 0; i < mipmapCount; i++ {
+		if i > 0 {
+			level = halveNRGBA(level)
+		}
+		if _, err := w.Write(compressBlocks(level, format)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDdsHeader はDDSのマジックとヘッダを書き込む。
+func writeDdsHeader(w io.Writer, width, height, mipmapCount int, format DdsFormat) error {
+	blockSize, fourCC := 8, "DXT1"
+	if format == DDS_FORMAT_BC3 {
+		blockSize, fourCC = 16, "DXT5"
+	}
+	flags := uint32(ddsFlagCaps | ddsFlagHeight | ddsFlagWidth | ddsFlagPixelFormat | ddsFlagLinearSize)
+	caps := uint32(ddsCapsTexture)
+	if mipmapCount > 1 {
+		flags |= ddsFlagMipmapCount
+		caps |= ddsCapsComplex | ddsCapsMipmap
+	}
+	header := make([]byte, 4+ddsHeaderSize)
+	copy(header[0:4], "DDS ")
+	fields := header[4:]
+	binary.LittleEndian.PutUint32(fields[0:], ddsHeaderSize)
+	binary.LittleEndian.PutUint32(fields[4:], flags)
+	binary.LittleEndian.PutUint32(fields[8:], uint32(height))
+	binary.LittleEndian.PutUint32(fields[12:], uint32(width))
+	binary.LittleEndian.PutUint32(fields[16:], uint32(max(1, (width+3)/4)*max(1, (height+3)/4)*blockSize))
+	binary.LittleEndian.PutUint32(fields[24:], uint32(mipmapCount))
+	pixelFormat := fields[72:]
+	binary.LittleEndian.PutUint32(pixelFormat[0:], ddsPixelFormatSize)
+	binary.LittleEndian.PutUint32(pixelFormat[4:], ddsPixelFourCC)
+	copy(pixelFormat[8:12], fourCC)
+	binary.LittleEndian.PutUint32(fields[104:], caps)
+	_, err := w.Write(header)
+	return err
+}
+
+// compressBlocks は画像を4x4ブロック単位で圧縮する。端のブロックは境界の画素で埋める。
+func compressBlocks(img *image.NRGBA, format DdsFormat) []byte {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	blocksX, blocksY := max(1, (width+3)/4), max(1, (height+3)/4)
+	out := make([]byte, 0, blocksX*blocksY*16)
+	var block [16][4]uint8
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			for i := range block {
+				x := min(bx*4+i%4, width-1)
+				y := min(by*4+i/4, height-1)
+				offset := img.PixOffset(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+				copy(block[i][:], img.Pix[offset:offset+4])
+			}
+			if format == DDS_FORMAT_BC3 {
+				out = append(out, compressAlphaBlock(&block)...)
+			}
+			out = append(out, compressColorBlock(&block)...)
+		}
+	}
+	return out
+}
+
+// compressColorBlock はブロックの色を BC1 の4色モードで圧縮する。
+// 最も離れた2色を端点とし、各画素を最も近い補間色へ割り当てる。
+func compressColorBlock(block *[16][4]uint8) []byte {
+	c0, c1 := 0, 0
+	maxDistance := -1
+	for i := 0; i < 16; i++ {
+		for j := i + 1; j < 16; j++ {
+			if d := colorDistance(block[i], block[j]); d > maxDistance {
+				maxDistance, c0, c1 = d, i, j
+			}
+		}
+	}
+	color0, color1 := packRgb565(block[c0]), packRgb565(block[c1])
+	if color0 < color1 {
+		color0, color1 = color1, color0
+	}
+	out := make([]byte, 8)
+	binary.LittleEndian.PutUint16(out[0:], color0)
+	binary.LittleEndian.PutUint16(out[2:], color1)
+	if color0 == color1 {
+		return out
+	}
+	e0, e1 := unpackRgb565(color0), unpackRgb565(color1)
+	var palette [4][4]uint8
+	palette[0], palette[1] = e0, e1
+	for c := 0; c < 3; c++ {
+		palette[2][c] = uint8((2*int(e0[c]) + int(e1[c])) / 3)
+		palette[3][c] = uint8((int(e0[c]) + 2*int(e1[c])) / 3)
+	}
+	var indexes uint32
+	for i := 0; i < 16; i++ {
+		best, bestDistance := 0, -1
+		for p := range palette {
+			if d := colorDistance(block[i], palette[p]); bestDistance < 0 || d < bestDistance {
+				best, bestDistance = p, d
+			}
+		}
+		indexes |= uint32(best) << (2 * i)
+	}
+	binary.LittleEndian.PutUint32(out[4:], indexes)
+	return out
+}
+
+// compressAlphaBlock はブロックのアルファを BC3 の8段階補間で圧縮する。
+func compressAlphaBlock(block *[16][4]uint8) []byte {
+	alpha0, alpha1 := uint8(0), uint8(255)
+	for i := 0; i < 16; i++ {
+		alpha0 = max(alpha0, block[i][3])
+		alpha1 = min(alpha1, block[i][3])
+	}
+	out := make([]byte, 8)
+	out[0], out[1] = alpha0, alpha1
+	if alpha0 == alpha1 {
+		return out
+	}
+	var palette [8]int
+	palette[0], palette[1] = int(alpha0), int(alpha1)
+	for i := 1; i < 7; i++ {
+		palette[i+1] = ((7-i)*int(alpha0) + i*int(alpha1)) / 7
+	}
+	var indexes uint64
+	for i := 0; i < 16; i++ {
+		best, bestDistance := 0, 256
+		for p, value := range palette {
+			d := value - int(block[i][3])
+			if d < 0 {
+				d = -d
+			}
+			if d < bestDistance {
+				best, bestDistance = p, d
+			}
+		}
+		indexes |= uint64(best) << (3 * i)
+	}
+	for i := 0; i < 6; i++ {
+		out[2+i] = uint8(indexes >> (8 * i))
+	}
+	return out
+}
+
+// colorDistance はRGBの二乗距離を返す。
+func colorDistance(a, b [4]uint8) int {
+	dr := int(a[0]) - int(b[0])
+	dg := int(a[1]) - int(b[1])
+	db := int(a[2]) - int(b[2])
+	return dr*dr + dg*dg + db*db
+}
+
+// packRgb565 はRGBを RGB565 へ丸める。
+func packRgb565(c [4]uint8) uint16 {
+	r := (uint16(c[0])*31 + 127) / 255
+	g := (uint16(c[1])*63 + 127) / 255
+	b := (uint16(c[2])*31 + 127) / 255
+	return r<<11 | g<<5 | b
+}
+
+// unpackRgb565 は RGB565 を8bitのRGBへ戻す。
+func unpackRgb565(v uint16) [4]uint8 {
+	r := uint8((v >> 11) & 0x1f)
+	g := uint8((v >> 5) & 0x3f)
+	b := uint8(v & 0x1f)
+	return [4]uint8{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255}
+}
+
+// halveNRGBA は2x2画素の平均で画像を半分の大きさにする。
+func halveNRGBA(src *image.NRGBA) *image.NRGBA {
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, max(1, width/2), max(1, height/2)))
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			var sum [4]int
+			for dy := 0; dy < 2; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					offset := src.PixOffset(src.Rect.Min.X+min(2*x+dx, width-1), src.Rect.Min.Y+min(2*y+dy, height-1))
+					for c := 0; c < 4; c++ {
+						sum[c] += int(src.Pix[offset+c])
+					}
+				}
+			}
+			offset := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8((sum[c] + 2) / 4)
+			}
+		}
+	}
+	return dst
+}
+
+// toNRGBA は画像を *image.NRGBA へ変換する。
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Rect, img, bounds.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,61 @@
+// 指示: miu200521358
+package mfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// newDdsTestImage は単色の画像を生成する。
+func newDdsTestImage(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeDDSOpaque(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeDDS(&buf, newDdsTestImage(8, 4, color.NRGBA{R: 255, A: 255}), DdsOptions{Mipmaps: true}); err != nil {
+		t.Fatalf("EncodeDDS returned error: %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[0:4]) != "DDS " || string(data[84:88]) != "DXT1" {
+		t.Fatalf("header mismatch: %q %q", data[0:4], data[84:88])
+	}
+	// 8x4, 4x2, 2x1, 1x1 の4段。
+	if mipmaps := binary.LittleEndian.Uint32(data[28:]); mipmaps != 4 {
+		t.Fatalf("mipmap count mismatch: %d", mipmaps)
+	}
+	if len(data) != 128+16+8+8+8 {
+		t.Fatalf("data size mismatch: %d", len(data))
+	}
+	if color0 := binary.LittleEndian.Uint16(data[128:]); color0 != 0xf800 {
+		t.Fatalf("color endpoint mismatch: %#x", color0)
+	}
+}
+
+func TestEncodeDDSTranslucent(t *testing.T) {
+	img := newDdsTestImage(4, 4, color.NRGBA{G: 255, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{G: 255, A: 0})
+	var buf bytes.Buffer
+	if err := EncodeDDS(&buf, img, DdsOptions{}); err != nil {
+		t.Fatalf("EncodeDDS returned error: %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[84:88]) != "DXT5" || len(data) != 128+16 {
+		t.Fatalf("translucent image should be BC3: %q %d", data[84:88], len(data))
+	}
+	// アルファ端点は 255/0 で、画素1だけが端点1(透明)を指す。
+	alpha := data[128:136]
+	indexes := uint64(alpha[2]) | uint64(alpha[3])<<8 | uint64(alpha[4])<<16
+	if alpha[0] != 255 || alpha[1] != 0 || indexes&0x7 != 0 || (indexes>>3)&0x7 != 1 {
+		t.Fatalf("alpha block mismatch: %v", alpha)
+	}
+}
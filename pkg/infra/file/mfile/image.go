@@ -8,7 +8,9 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -33,6 +35,55 @@ func LoadImage(path string) (image.Image, error) {
 	return loadImage(path, baseName, open)
 }
 
+// LoadImageFS はfs.FS上の画像を読み込む。Windows形式の区切りを含むパスも受け付ける。
+func LoadImageFS(fsys fs.FS, name string) (image.Image, error) {
+	fsPath := strings.TrimPrefix(path.Clean(strings.ReplaceAll(name, "\\", "/")), "/")
+	baseName := path.Base(fsPath)
+	open := func() (io.ReadCloser, error) {
+		file, err := fsys.Open(fsPath)
+		if err != nil {
+			return nil, merr.NewOsPackageError("画像ファイルの読み込みに失敗しました: %s", err, baseName)
+		}
+		return file, nil
+	}
+	return loadImage(fsPath, baseName, open)
+}
+
+// SaveImage は拡張子に応じた形式で画像を保存する。png/jpg/bmp/dds に対応する。
+// dds はアルファの有無で BC1/BC3 を選び、ミップマップを含める。
+func SaveImage(path string, img image.Image) error {
+	baseName := filepath.Base(path)
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	var encode func(io.Writer, image.Image) error
+	switch ext {
+	case "png":
+		encode = png.Encode
+	case "jpg", "jpeg":
+		encode = func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, &jpeg.Options{Quality: 95}) }
+	case "bmp":
+		encode = bmp.Encode
+	case "dds":
+		encode = func(w io.Writer, m image.Image) error { return EncodeDDS(w, m, DdsOptions{Mipmaps: true}) }
+	default:
+		return merr.NewCommonError(imageFormatNotSupportedErrorID, merr.ErrorKindValidate, "画像形式が未対応です: %s", nil, baseName)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return merr.NewOsPackageError("画像の保存先ディレクトリの作成に失敗しました: %s", err, baseName)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return merr.NewOsPackageError("画像ファイルの作成に失敗しました: %s", err, baseName)
+	}
+	if err := encode(file, img); err != nil {
+		_ = file.Close()
+		return merr.NewImagePackageError("画像のエンコードに失敗しました: %s", err, baseName)
+	}
+	if err := file.Close(); err != nil {
+		return wrapImageCloseError(baseName, err)
+	}
+	return nil
+}
+
 // imageOpenFunc は画像データを取得する関数型。
 type imageOpenFunc func() (io.ReadCloser, error)
 
@@ -0,0 +1,106 @@
+// 指示: miu200521358
+package mfile
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// AssetReader はモデルが参照するファイルの読み込み処理を表す。
+type AssetReader struct{}
+
+// NewAssetReader はAssetReaderを生成する。
+func NewAssetReader() *AssetReader {
+	return &AssetReader{}
+}
+
+// ReadFile はファイルの内容を読み込む。
+func (r *AssetReader) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, newFileNotFound("ファイルが存在しません: %s", err, path)
+		}
+		return nil, newFileReadFailed("ファイル読込に失敗しました: %s", merr.NewOsPackageError("os.ReadFileに失敗しました", err), path)
+	}
+	return data, nil
+}
+
+// DirPackageWriter はモデル一式をフォルダへ書き出す処理を表す。
+type DirPackageWriter struct {
+	dir string
+}
+
+// NewDirPackageWriter はDirPackageWriterを生成する。
+func NewDirPackageWriter(dir string) *DirPackageWriter {
+	return &DirPackageWriter{dir: dir}
+}
+
+// WriteFile はフォルダ内の相対パスへデータを書き込む。
+func (w *DirPackageWriter) WriteFile(name string, data []byte) error {
+	path := filepath.Join(w.dir, filepath.FromSlash(name))
+	// 出力先フォルダが空(カレントディレクトリ)の場合も判定できるよう、相対パスで範囲外を判定する。
+	rel, err := filepath.Rel(filepath.Clean(w.dir), path)
+	if err != nil || rel == "." || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return merr.NewOsPackageError("出力先フォルダの外には書き込めません: %s", os.ErrPermission, name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return merr.NewOsPackageError("出力先ディレクトリの作成に失敗しました: %s", err, name)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return merr.NewOsPackageError("ファイルの書き込みに失敗しました: %s", err, name)
+	}
+	return nil
+}
+
+// Close はフォルダ出力では何もしない。
+func (w *DirPackageWriter) Close() error {
+	return nil
+}
+
+// ZipPackageWriter はモデル一式をzipへ書き出す処理を表す。
+type ZipPackageWriter struct {
+	file   *os.File
+	writer *zip.Writer
+}
+
+// NewZipPackageWriter はzipファイルを作成してZipPackageWriterを生成する。利用後は Close を呼ぶ。
+func NewZipPackageWriter(path string) (*ZipPackageWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, merr.NewOsPackageError("出力先ディレクトリの作成に失敗しました: %s", err, filepath.Base(path))
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, merr.NewOsPackageError("zipファイルの作成に失敗しました: %s", err, filepath.Base(path))
+	}
+	return &ZipPackageWriter{file: file, writer: zip.NewWriter(file)}, nil
+}
+
+// WriteFile はzip内の相対パスへデータを書き込む。
+func (w *ZipPackageWriter) WriteFile(name string, data []byte) error {
+	entry, err := w.writer.Create(filepath.ToSlash(name))
+	if err != nil {
+		return merr.NewOsPackageError("zipエントリの作成に失敗しました: %s", err, name)
+	}
+	if _, err := entry.Write(data); err != nil {
+		return merr.NewOsPackageError("zipエントリの書き込みに失敗しました: %s", err, name)
+	}
+	return nil
+}
+
+// Close はzipの書き込みを確定してファイルを閉じる。
+func (w *ZipPackageWriter) Close() error {
+	if err := w.writer.Close(); err != nil {
+		_ = w.file.Close()
+		return merr.NewOsPackageError("zipの書き込みに失敗しました: %s", err, filepath.Base(w.file.Name()))
+	}
+	if err := w.file.Close(); err != nil {
+		return merr.NewOsPackageError("zipファイルのクローズに失敗しました: %s", err, filepath.Base(w.file.Name()))
+	}
+	return nil
+}
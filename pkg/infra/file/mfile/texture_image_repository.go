@@ -0,0 +1,49 @@
+// 指示: miu200521358
+package mfile
+
+import (
+	"image"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// TextureImageRepository はテクスチャ画像の読み書き処理を表す。
+type TextureImageRepository struct{}
+
+// NewTextureImageRepository はTextureImageRepositoryを生成する。
+func NewTextureImageRepository() *TextureImageRepository {
+	return &TextureImageRepository{}
+}
+
+// LoadImage は画像を読み込む。
+func (r *TextureImageRepository) LoadImage(path string) (image.Image, error) {
+	return LoadImage(path)
+}
+
+// SaveImage は拡張子に応じた形式で画像を保存する。
+func (r *TextureImageRepository) SaveImage(path string, img image.Image) error {
+	return SaveImage(path, img)
+}
+
+// FsTextureImageRepository はfs.FS上のテクスチャ画像の読み込み処理を表す。
+// zipアーカイブ内のモデルのテクスチャを展開せずに読み込むために使う。
+type FsTextureImageRepository struct {
+	fsys fs.FS
+}
+
+// NewFsTextureImageRepository はFsTextureImageRepositoryを生成する。
+func NewFsTextureImageRepository(fsys fs.FS) *FsTextureImageRepository {
+	return &FsTextureImageRepository{fsys: fsys}
+}
+
+// LoadImage はfs.FS上の画像を読み込む。
+func (r *FsTextureImageRepository) LoadImage(path string) (image.Image, error) {
+	return LoadImageFS(r.fsys, path)
+}
+
+// SaveImage はfs.FSが読み取り専用のためエラーを返す。
+func (r *FsTextureImageRepository) SaveImage(path string, img image.Image) error {
+	return merr.NewOsPackageError("読み取り専用のため画像を保存できません: %s", fs.ErrPermission, filepath.Base(path))
+}
@@ -0,0 +1,158 @@
+// 指示: miu200521358
+package mfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// TexturePathResolver はWindows前提のテクスチャ名を、大文字小文字を区別するファイルシステム上でも解決する。
+// 区切り文字の正規化、大文字小文字を無視した照合、拡張子違いの探索、代替ディレクトリの探索を行う。
+type TexturePathResolver struct {
+	fallbackDirs []string
+	mu           sync.Mutex
+	// listings はディレクトリごとの、正規化したファイル名から実際のファイル名への対応。
+	listings map[string]map[string]string
+}
+
+// NewTexturePathResolver はTexturePathResolverを生成する。
+// fallbackDirs はモデルのディレクトリで見つからない場合に探すディレクトリ。
+func NewTexturePathResolver(fallbackDirs ...string) *TexturePathResolver {
+	return &TexturePathResolver{
+		fallbackDirs: fallbackDirs,
+		listings:     make(map[string]map[string]string),
+	}
+}
+
+// ResolveTexturePath はモデルパスを基準にテクスチャ名を解決する。
+// 見つからない場合は、モデルのディレクトリと結合しただけのパスと false を返す。
+func (r *TexturePathResolver) ResolveTexturePath(modelPath, textureName string) (string, bool) {
+	if textureName == "" {
+		return "", false
+	}
+	name := normalizeTextureSeparator(textureName)
+	joined := name
+	if !filepath.IsAbs(name) {
+		joined = filepath.Join(filepath.Dir(modelPath), name)
+	}
+	if resolved, ok := r.resolveFile(joined); ok {
+		return resolved, true
+	}
+	for _, dir := range r.fallbackDirs {
+		if !filepath.IsAbs(name) {
+			if resolved, ok := r.resolveFile(filepath.Join(dir, name)); ok {
+				return resolved, true
+			}
+		}
+		if resolved, ok := r.resolveFile(filepath.Join(dir, filepath.Base(name))); ok {
+			return resolved, true
+		}
+	}
+	return joined, false
+}
+
+// ClearCache はディレクトリ一覧のキャッシュを破棄する。ファイルを追加・改名した後に呼ぶ。
+func (r *TexturePathResolver) ClearCache() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listings = make(map[string]map[string]string)
+}
+
+// resolveFile はファイルパスを、大文字小文字と拡張子の違いを許容して解決する。
+func (r *TexturePathResolver) resolveFile(path string) (string, bool) {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return path, true
+	}
+	dir, ok := r.resolveDir(filepath.Dir(path))
+	if !ok {
+		return "", false
+	}
+	base := filepath.Base(path)
+	if actual, ok := r.lookup(dir, base); ok {
+		return filepath.Join(dir, actual), true
+	}
+	// 拡張子だけ異なるファイル(例: .png と書かれた .tga)も、読み込み時と同じ候補順で探す。
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	for _, ext := range imageExtensionCandidates(base) {
+		if actual, ok := r.lookup(dir, stem+"."+ext); ok {
+			return filepath.Join(dir, actual), true
+		}
+	}
+	return "", false
+}
+
+// resolveDir はディレクトリパスを、大文字小文字の違いを許容して上位から解決する。
+func (r *TexturePathResolver) resolveDir(dir string) (string, bool) {
+	if info, err := os.Stat(dir); err == nil {
+		return dir, info.IsDir()
+	}
+	parent := filepath.Dir(dir)
+	if parent == dir {
+		return "", false
+	}
+	resolvedParent, ok := r.resolveDir(parent)
+	if !ok {
+		return "", false
+	}
+	actual, ok := r.lookup(resolvedParent, filepath.Base(dir))
+	if !ok {
+		return "", false
+	}
+	resolved := filepath.Join(resolvedParent, actual)
+	info, err := os.Stat(resolved)
+	return resolved, err == nil && info.IsDir()
+}
+
+// lookup はディレクトリ一覧から、大文字小文字を区別せずにファイル名を探す。
+func (r *TexturePathResolver) lookup(dir, name string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	listing, ok := r.listings[dir]
+	if !ok {
+		listing = readTextureListing(dir)
+		r.listings[dir] = listing
+	}
+	actual, ok := listing[foldTextureName(name)]
+	return actual, ok
+}
+
+// readTextureListing はディレクトリ内のファイル名を正規化して一覧にする。
+// Shift-JISのまま展開されたファイル名は、デコードした名前でも引けるようにする。
+func readTextureListing(dir string) map[string]string {
+	listing := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return listing
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := listing[foldTextureName(name)]; !ok {
+			listing[foldTextureName(name)] = name
+		}
+		if utf8.ValidString(name) {
+			continue
+		}
+		if decoded, err := japanese.ShiftJIS.NewDecoder().String(name); err == nil {
+			if _, ok := listing[foldTextureName(decoded)]; !ok {
+				listing[foldTextureName(decoded)] = name
+			}
+		}
+	}
+	return listing
+}
+
+// normalizeTextureSeparator はテクスチャ名の区切り文字を実行環境の区切り文字へ揃える。
+func normalizeTextureSeparator(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	return filepath.Clean(filepath.FromSlash(name))
+}
+
+// foldTextureName は大文字小文字を無視して照合するためのファイル名を返す。
+func foldTextureName(name string) string {
+	return strings.ToLower(name)
+}
@@ -0,0 +1,80 @@
+// 指示: miu200521358
+package mfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// writeResolverTestFile はテスト用の空ファイルを作成する。
+func writeResolverTestFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte{}, 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+}
+
+func TestTexturePathResolver(t *testing.T) {
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "model", "miku.pmx")
+	writeResolverTestFile(t, filepath.Join(dir, "model", "Tex", "Body.PNG"))
+	writeResolverTestFile(t, filepath.Join(dir, "model", "sph", "face.tga"))
+	sjisName, err := japanese.ShiftJIS.NewEncoder().String("髪.png")
+	if err != nil {
+		t.Fatalf("shift-jis encode failed: %v", err)
+	}
+	writeResolverTestFile(t, filepath.Join(dir, "model", sjisName))
+	writeResolverTestFile(t, filepath.Join(dir, "shared", "toon01.bmp"))
+
+	resolver := NewTexturePathResolver(filepath.Join(dir, "shared"))
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"tex\\body.png", filepath.Join(dir, "model", "Tex", "Body.PNG")},
+		{"SPH/face.png", filepath.Join(dir, "model", "sph", "face.tga")},
+		{"髪.png", filepath.Join(dir, "model", sjisName)},
+		{"toon\\toon01.bmp", filepath.Join(dir, "shared", "toon01.bmp")},
+	}
+	for _, c := range cases {
+		resolved, ok := resolver.ResolveTexturePath(modelPath, c.name)
+		if !ok || resolved != c.expected {
+			t.Errorf("%s: expected %s, got %s (%v)", c.name, c.expected, resolved, ok)
+		}
+	}
+
+	resolved, ok := resolver.ResolveTexturePath(modelPath, "tex\\none.png")
+	if ok || resolved != filepath.Join(dir, "model", "tex", "none.png") {
+		t.Errorf("missing texture should not be resolved: %s %v", resolved, ok)
+	}
+	// キャッシュ済みのディレクトリに追加したファイルは、キャッシュ破棄後に解決できる。
+	writeResolverTestFile(t, filepath.Join(dir, "model", "tex", "None.png"))
+	resolver.ClearCache()
+	if _, ok := resolver.ResolveTexturePath(modelPath, "tex\\none.png"); !ok {
+		t.Errorf("added texture should be resolved after clearing cache")
+	}
+}
+
+func TestTextureValidatorResolvesTexturePath(t *testing.T) {
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "miku.pmx")
+	writeResolverTestFile(t, filepath.Join(dir, "Tex", "Body.PNG"))
+
+	validator := NewTextureValidator()
+	resolved, ok := validator.ResolveTexturePath(modelPath, "tex\\body.png")
+	if !ok || resolved != filepath.Join(dir, "Tex", "Body.PNG") {
+		t.Errorf("texture should be resolved by validator: %s %v", resolved, ok)
+	}
+	// キャッシュを破棄すれば、同じ検証処理でも後から追加したファイルを解決できる。
+	writeResolverTestFile(t, filepath.Join(dir, "Tex", "Face.png"))
+	validator.ClearCache()
+	if _, ok := validator.ResolveTexturePath(modelPath, "tex\\face.png"); !ok {
+		t.Errorf("validator should not reuse stale listings after clearing cache")
+	}
+}
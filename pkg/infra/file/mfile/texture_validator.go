@@ -2,11 +2,29 @@
 package mfile
 
 // TextureValidator はテクスチャ検証処理を表す。
-type TextureValidator struct{}
+type TextureValidator struct {
+	resolver *TexturePathResolver
+}
 
 // NewTextureValidator はTextureValidatorを生成する。
+// ディレクトリ一覧のキャッシュはモデルの検証ごとに ClearCache で破棄する。
 func NewTextureValidator() *TextureValidator {
-	return &TextureValidator{}
+	return &TextureValidator{resolver: NewTexturePathResolver()}
+}
+
+// ResolveTexturePath はモデルパスを基準にテクスチャ名を実在するファイルパスへ解決する。
+func (v *TextureValidator) ResolveTexturePath(modelPath, textureName string) (string, bool) {
+	if v.resolver == nil {
+		v.resolver = NewTexturePathResolver()
+	}
+	return v.resolver.ResolveTexturePath(modelPath, textureName)
+}
+
+// ClearCache はディレクトリ一覧のキャッシュを破棄する。
+func (v *TextureValidator) ClearCache() {
+	if v.resolver != nil {
+		v.resolver.ClearCache()
+	}
 }
 
 // ExistsFile はファイルの存在を判定する。
@@ -40,6 +40,7 @@ const (
 )
 
 // AxisPolicy は座標系の前提を表す。
+// Right/Up/Forward は正面からモデルを見たときの画面右・上・モデルの向きを表す。
 type AxisPolicy struct {
 	Handedness Handedness
 	Up         AxisDir
@@ -120,6 +121,36 @@ var AXIS_POLICY_IO_MMD = AxisPolicy{
 	Right:      AxisDir{Axis: AXIS_X, Sign: AXIS_SIGN_POS},
 }
 
+// AXIS_POLICY_RIGHT_Y_UP は右手系Y-up(OpenGL系)のポリシー。正面は+Z。
+var AXIS_POLICY_RIGHT_Y_UP = AxisPolicy{
+	Handedness: HANDEDNESS_RIGHT,
+	Up:         AxisDir{Axis: AXIS_Y, Sign: AXIS_SIGN_POS},
+	Forward:    AxisDir{Axis: AXIS_Z, Sign: AXIS_SIGN_POS},
+	Right:      AxisDir{Axis: AXIS_X, Sign: AXIS_SIGN_POS},
+}
+
+// AXIS_POLICY_RIGHT_Z_UP は右手系Z-up(DCCツール系)のポリシー。正面は-Y。
+var AXIS_POLICY_RIGHT_Z_UP = AxisPolicy{
+	Handedness: HANDEDNESS_RIGHT,
+	Up:         AxisDir{Axis: AXIS_Z, Sign: AXIS_SIGN_POS},
+	Forward:    AxisDir{Axis: AXIS_Y, Sign: AXIS_SIGN_NEG},
+	Right:      AxisDir{Axis: AXIS_X, Sign: AXIS_SIGN_POS},
+}
+
+// AXIS_POLICY_UNITY はUnity向けポリシー。左手系Y-upでモデルは+Zを向く。
+var AXIS_POLICY_UNITY = AxisPolicy{
+	Handedness: HANDEDNESS_LEFT,
+	Up:         AxisDir{Axis: AXIS_Y, Sign: AXIS_SIGN_POS},
+	Forward:    AxisDir{Axis: AXIS_Z, Sign: AXIS_SIGN_POS},
+	Right:      AxisDir{Axis: AXIS_X, Sign: AXIS_SIGN_NEG},
+}
+
+// AXIS_POLICY_BLENDER はBlender向けポリシー。
+var AXIS_POLICY_BLENDER = AXIS_POLICY_RIGHT_Z_UP
+
+// AXIS_POLICY_GLTF はglTF向けポリシー。
+var AXIS_POLICY_GLTF = AXIS_POLICY_RIGHT_Y_UP
+
 // MATRIX_POLICY_DEFAULT は行列計算の既定ポリシー。
 var MATRIX_POLICY_DEFAULT = MatrixPolicy{
 	Layout:              MATRIX_LAYOUT_COLUMN_MAJOR,
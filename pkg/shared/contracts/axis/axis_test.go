@@ -48,3 +48,16 @@ func TestCameraNdcZRange(t *testing.T) {
 		t.Errorf("CAMERA_NDC_Z_RANGE: got=%v", CAMERA_NDC_Z_RANGE)
 	}
 }
+
+// TestAxisPolicyPresets は外部ツール向けプリセットの手系と軸を確認する。
+func TestAxisPolicyPresets(t *testing.T) {
+	if AXIS_POLICY_GLTF != AXIS_POLICY_RIGHT_Y_UP || AXIS_POLICY_BLENDER != AXIS_POLICY_RIGHT_Z_UP {
+		t.Errorf("preset alias mismatch")
+	}
+	if AXIS_POLICY_RIGHT_Z_UP.Up != (AxisDir{Axis: AXIS_Z, Sign: AXIS_SIGN_POS}) {
+		t.Errorf("AXIS_POLICY_RIGHT_Z_UP.Up: got=%v", AXIS_POLICY_RIGHT_Z_UP.Up)
+	}
+	if AXIS_POLICY_UNITY.Handedness != HANDEDNESS_LEFT || AXIS_POLICY_RIGHT_Y_UP.Handedness != HANDEDNESS_RIGHT {
+		t.Errorf("handedness mismatch")
+	}
+}
@@ -7,14 +7,25 @@ type LengthUnit int
 const (
 	// LENGTH_UNIT_MMD はMMD単位を表す。
 	LENGTH_UNIT_MMD LengthUnit = iota
+	// LENGTH_UNIT_METER はメートルを表す。
+	LENGTH_UNIT_METER
+	// LENGTH_UNIT_CENTIMETER はセンチメートルを表す。
+	LENGTH_UNIT_CENTIMETER
+	// LENGTH_UNIT_MILLIMETER はミリメートルを表す。
+	LENGTH_UNIT_MILLIMETER
 )
 
+// MMD_UNIT_METERS は1MMD単位あたりのメートル数(1単位=8cm換算)。
+const MMD_UNIT_METERS = 0.08
+
 // AngleUnit は角度単位を表す。
 type AngleUnit int
 
 const (
 	// ANGLE_UNIT_RADIAN はラジアンを表す。
 	ANGLE_UNIT_RADIAN AngleUnit = iota
+	// ANGLE_UNIT_DEGREE は度を表す。
+	ANGLE_UNIT_DEGREE
 )
 
 // CameraFovUnit はカメラFOV単位を表す。
@@ -0,0 +1,152 @@
+// 指示: miu200521358
+package mdeform
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/delta"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/state"
+	"github.com/miu200521358/mlib_go/pkg/usecase/mphysics"
+	"github.com/miu200521358/mlib_go/pkg/usecase/port/physics"
+)
+
+// BatchPhysics は一括変形で物理を進める設定を表す。
+// モデルは呼び出し側で Core に追加済みであること。
+type BatchPhysics struct {
+	Core          physics.IPhysicsCore
+	ModelIndex    int
+	TimeStep      float32
+	MaxSubSteps   int
+	FixedTimeStep float32
+}
+
+// BatchOptions は複数フレーム一括変形の設定を表す。
+type BatchOptions struct {
+	Deform *DeformOptions
+	// Workers は並列数。0 以下の場合はCPU数。
+	Workers int
+	// Cache は物理なしの変形結果を再利用するキャッシュ。nil の場合は使用しない。
+	Cache *DeltaCache
+	// Physics は物理設定。nil の場合は物理なしで全フレームを並列に変形する。
+	Physics *BatchPhysics
+}
+
+// BuildFrameDeltas は指定フレーム群の変形差分を frames と同じ順で返す。
+// 物理前変形はフレーム間で独立なため並列に行い、物理は frames の順に直列で進める。
+func BuildFrameDeltas(
+	modelData *model.PmxModel,
+	motionData *motion.VmdMotion,
+	frames []motion.Frame,
+	opts BatchOptions,
+) []*delta.VmdDeltas {
+	results := make([]*delta.VmdDeltas, len(frames))
+	if modelData == nil || len(frames) == 0 {
+		return results
+	}
+	usePhysics := opts.Physics != nil && opts.Physics.Core != nil
+	// 物理結果は直前までのシミュレーションに依存するため、キャッシュしない。
+	cache := opts.Cache
+	if usePhysics {
+		cache = nil
+	}
+	modelHash := modelData.Hash()
+	motionHash := motionHash(motionData)
+	finalizeMotionFrames(motionData)
+
+	parallelFor(len(frames), opts.Workers, func(i int) {
+		if cached, ok := cache.Get(modelHash, motionHash, frames[i]); ok {
+			results[i] = cached
+			return
+		}
+		deltas := BuildBeforePhysics(modelData, motionData, nil, frames[i], opts.Deform)
+		if !usePhysics {
+			deltas = BuildAfterPhysics(nil, false, 0, modelData, motionData, deltas, frames[i])
+		}
+		cache.Put(deltas)
+		results[i] = deltas
+	})
+
+	if usePhysics {
+		p := opts.Physics
+		for i, frame := range frames {
+			physicsDeltas := mphysics.BuildPhysicsDeltas(modelData, motionData, frame)
+			results[i] = BuildForPhysics(p.Core, p.ModelIndex, modelData, results[i], physicsDeltas, true, state.PHYSICS_RESET_TYPE_NONE)
+			p.Core.StepSimulation(p.TimeStep, p.MaxSubSteps, p.FixedTimeStep)
+			results[i] = BuildAfterPhysics(p.Core, true, p.ModelIndex, modelData, motionData, results[i], frame)
+		}
+	}
+	return results
+}
+
+// BuildFrameRangeDeltas は start から end までの整数フレームの変形差分を返す。
+func BuildFrameRangeDeltas(
+	modelData *model.PmxModel,
+	motionData *motion.VmdMotion,
+	start, end motion.Frame,
+	opts BatchOptions,
+) []*delta.VmdDeltas {
+	if end < start {
+		return nil
+	}
+	frames := make([]motion.Frame, 0, int(end-start)+1)
+	for frame := start; frame <= end; frame++ {
+		frames = append(frames, frame)
+	}
+	return BuildFrameDeltas(modelData, motionData, frames, opts)
+}
+
+// finalizeMotionFrames は変形で参照するフレーム索引を確定する。
+// 索引は参照時に遅延ソートされるため、並列参照の前に確定しておく。
+func finalizeMotionFrames(motionData *motion.VmdMotion) {
+	if motionData == nil {
+		return
+	}
+	if motionData.BoneFrames != nil {
+		for _, name := range motionData.BoneFrames.Names() {
+			motionData.BoneFrames.Get(name).Finalize()
+		}
+	}
+	if motionData.MorphFrames != nil {
+		for _, name := range motionData.MorphFrames.Names() {
+			motionData.MorphFrames.Get(name).Finalize()
+		}
+	}
+	if motionData.IkFrames != nil {
+		motionData.IkFrames.Finalize()
+	}
+}
+
+// parallelFor は 0..count-1 を workers 並列で処理する。
+func parallelFor(count, workers int, fn func(i int)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > count {
+		workers = count
+	}
+	if workers <= 1 {
+		for i := 0; i < count; i++ {
+			fn(i)
+		}
+		return
+	}
+	indexes := make(chan int, workers)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,115 @@
+// 指示: miu200521358
+package mdeform
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/delta"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+)
+
+// newBatchTestData は boneCount 本の直列ボーンと、各ボーンを回すモーションを生成する。
+func newBatchTestData(boneCount int, frameCount int) (*model.PmxModel, *motion.VmdMotion) {
+	modelData := model.NewPmxModel()
+	for i := 0; i < boneCount; i++ {
+		bone := &model.Bone{ParentIndex: i - 1, TailIndex: -1, EffectIndex: -1}
+		bone.SetName(fmt.Sprintf("bone%d", i))
+		bone.Position = mmath.NewVec3()
+		bone.Position.Y = float64(i)
+		bone.BoneFlag = model.BONE_FLAG_CAN_ROTATE | model.BONE_FLAG_IS_VISIBLE
+		modelData.Bones.Append(bone)
+	}
+	modelData.UpdateHash()
+
+	motionData := motion.NewVmdMotion("")
+	for i := 0; i < boneCount; i++ {
+		frames := motionData.BoneFrames.Get(fmt.Sprintf("bone%d", i))
+		for _, frame := range []motion.Frame{0, motion.Frame(frameCount)} {
+			bf := motion.NewBoneFrame(frame)
+			rotation := mmath.NewQuaternionFromDegrees(0, 0, float64(frame)*0.5)
+			bf.Rotation = &rotation
+			frames.Append(bf)
+		}
+	}
+	motionData.UpdateHash()
+	return modelData, motionData
+}
+
+// lastBonePosition は末端ボーンのグローバル位置を返す。
+func lastBonePosition(deltas *delta.VmdDeltas) mmath.Vec3 {
+	return deltas.Bones.Get(deltas.Bones.Len() - 1).FilledGlobalPosition()
+}
+
+// TestBuildFrameDeltasMatchesSerial は並列変形が直列変形と一致することを確認する。
+func TestBuildFrameDeltasMatchesSerial(t *testing.T) {
+	modelData, motionData := newBatchTestData(8, 30)
+	parallel := BuildFrameRangeDeltas(modelData, motionData, 0, 30, BatchOptions{Workers: 4})
+	if len(parallel) != 31 {
+		t.Fatalf("frame count mismatch: %d", len(parallel))
+	}
+	for i, deltas := range parallel {
+		frame := motion.Frame(i)
+		if deltas.Frame() != frame || deltas.ModelHash() != modelData.Hash() {
+			t.Fatalf("delta key mismatch: frame=%v", deltas.Frame())
+		}
+		serial := BuildBeforePhysics(modelData, motionData, nil, frame, nil)
+		if !lastBonePosition(deltas).NearEquals(lastBonePosition(serial), 1e-9) {
+			t.Fatalf("frame %v mismatch: %v %v", frame, lastBonePosition(deltas), lastBonePosition(serial))
+		}
+	}
+}
+
+// TestBuildFrameDeltasCache はキャッシュの再利用とLRU破棄を確認する。
+func TestBuildFrameDeltasCache(t *testing.T) {
+	modelData, motionData := newBatchTestData(4, 10)
+	cache := NewDeltaCache(5)
+	first := BuildFrameDeltas(modelData, motionData, []motion.Frame{1, 2, 3}, BatchOptions{Cache: cache, Workers: 1})
+	second := BuildFrameDeltas(modelData, motionData, []motion.Frame{3, 2, 1}, BatchOptions{Cache: cache, Workers: 1})
+	if second[0] != first[2] || second[2] != first[0] {
+		t.Fatalf("cached deltas should be reused")
+	}
+	if hits, misses := cache.Stats(); hits != 3 || misses != 3 {
+		t.Fatalf("stats mismatch: hits=%d misses=%d", hits, misses)
+	}
+
+	BuildFrameDeltas(modelData, motionData, []motion.Frame{4, 5, 6}, BatchOptions{Cache: cache, Workers: 1})
+	if cache.Len() != 5 {
+		t.Fatalf("cache should be bounded: %d", cache.Len())
+	}
+	if _, ok := cache.Get(modelData.Hash(), motionData.Hash(), 3); ok {
+		t.Fatalf("least recently used entry should be evicted")
+	}
+}
+
+// benchmarkBuildFrameDeltas は並列数ごとの一括変形を計測する。
+func benchmarkBuildFrameDeltas(b *testing.B, workers int) {
+	modelData, motionData := newBatchTestData(200, 120)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildFrameRangeDeltas(modelData, motionData, 0, 120, BatchOptions{Workers: workers})
+	}
+}
+
+// BenchmarkBuildFrameDeltasSerial は直列の一括変形を計測する。
+func BenchmarkBuildFrameDeltasSerial(b *testing.B) {
+	benchmarkBuildFrameDeltas(b, 1)
+}
+
+// BenchmarkBuildFrameDeltasParallel はCPU数並列の一括変形を計測する。
+func BenchmarkBuildFrameDeltasParallel(b *testing.B) {
+	benchmarkBuildFrameDeltas(b, 0)
+}
+
+// BenchmarkBuildFrameDeltasCached はキャッシュ済みの一括変形を計測する。
+func BenchmarkBuildFrameDeltasCached(b *testing.B) {
+	modelData, motionData := newBatchTestData(200, 120)
+	cache := NewDeltaCache(121)
+	BuildFrameRangeDeltas(modelData, motionData, 0, 120, BatchOptions{Cache: cache})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildFrameRangeDeltas(modelData, motionData, 0, 120, BatchOptions{Cache: cache})
+	}
+}
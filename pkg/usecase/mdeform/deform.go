@@ -45,6 +45,8 @@ type DeformOptions struct {
 	EnableIK        bool
 	EnablePhysics   bool
 	IkDebugFactory  deform.IIkDebugFactory
+	// IkSolvers はIKボーン名ごとのソルバー設定。未指定のIKボーンはCCDで解く。
+	IkSolvers deform.IkSolverSettings
 }
 
 // rigidBodyBoneMatrixResult は剛体結果から得たボーン行列反映情報を保持する。
@@ -73,9 +75,10 @@ func BuildBeforePhysics(
 	deformNames := targetBoneNames(opts)
 	includeIk := enableIk(opts)
 	debugFactory := ikDebugFactory(opts)
+	solvers := ikSolverSettings(opts)
 
 	deltas.Morphs = deform.ComputeMorphDeltas(modelData, motionData, frame, nil)
-	boneDeltas, _ := deform.ComputeBoneDeltas(modelData, motionData, frame, deformNames, includeIk, false, false, debugFactory)
+	boneDeltas, _ := deform.ComputeBoneDeltasWithIkSolvers(modelData, motionData, frame, deformNames, includeIk, false, false, debugFactory, solvers)
 	deltas.Bones = boneDeltas
 	return deltas
 }
@@ -97,9 +100,10 @@ func RebuildBeforePhysics(
 	deformNames := targetBoneNames(opts)
 	includeIk := enableIk(opts)
 	debugFactory := ikDebugFactory(opts)
+	solvers := ikSolverSettings(opts)
 
 	deltas.Morphs = deform.ComputeMorphDeltas(modelData, motionData, frame, nil)
-	boneDeltas, _ := deform.ComputeBoneDeltas(modelData, motionData, frame, deformNames, includeIk, false, false, debugFactory)
+	boneDeltas, _ := deform.ComputeBoneDeltasWithIkSolvers(modelData, motionData, frame, deformNames, includeIk, false, false, debugFactory, solvers)
 	deltas.Bones = boneDeltas
 	return deltas
 }
@@ -406,6 +410,14 @@ func ikDebugFactory(opts *DeformOptions) deform.IIkDebugFactory {
 	return opts.IkDebugFactory
 }
 
+// ikSolverSettings はIKソルバー設定を返す。
+func ikSolverSettings(opts *DeformOptions) deform.IkSolverSettings {
+	if opts == nil {
+		return nil
+	}
+	return opts.IkSolvers
+}
+
 // updateRigidBodyShapeMass は剛体形状と質量を差分に応じて更新する。
 func updateRigidBodyShapeMass(core physics.IPhysicsCore, modelIndex int, modelData *model.PmxModel, physicsDeltas *delta.PhysicsDeltas) {
 	if core == nil || modelData == nil || physicsDeltas == nil || physicsDeltas.RigidBodies == nil {
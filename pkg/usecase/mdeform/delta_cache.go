@@ -0,0 +1,117 @@
+// 指示: miu200521358
+package mdeform
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/delta"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+)
+
+// deltaCacheKey はフレーム差分キャッシュのキーを表す。
+type deltaCacheKey struct {
+	modelHash  string
+	motionHash string
+	frame      motion.Frame
+}
+
+// deltaCacheEntry はLRUリストの要素を表す。
+type deltaCacheEntry struct {
+	key    deltaCacheKey
+	deltas *delta.VmdDeltas
+}
+
+// DeltaCache はモデル/モーションのハッシュとフレームで変形差分を保持するLRUキャッシュ。
+// 変形オプションはキーに含まれないため、オプションの組ごとに別のキャッシュを用意する。
+// 取得した差分は共有されるため、呼び出し側で変更してはならない。
+type DeltaCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[deltaCacheKey]*list.Element
+	hits     int
+	misses   int
+}
+
+// NewDeltaCache は最大 capacity 件を保持するキャッシュを生成する。
+func NewDeltaCache(capacity int) *DeltaCache {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &DeltaCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[deltaCacheKey]*list.Element, capacity),
+	}
+}
+
+// Get は指定キーの差分を返す。見つかった場合は最近使用として扱う。
+func (c *DeltaCache) Get(modelHash, motionHash string, frame motion.Frame) (*delta.VmdDeltas, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[deltaCacheKey{modelHash: modelHash, motionHash: motionHash, frame: frame}]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*deltaCacheEntry).deltas, true
+}
+
+// Put は差分を保持する。キーは差分が持つハッシュとフレームから決まる。
+func (c *DeltaCache) Put(deltas *delta.VmdDeltas) {
+	if c == nil || deltas == nil {
+		return
+	}
+	key := deltaCacheKey{modelHash: deltas.ModelHash(), motionHash: deltas.MotionHash(), frame: deltas.Frame()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*deltaCacheEntry).deltas = deltas
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&deltaCacheEntry{key: key, deltas: deltas})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*deltaCacheEntry).key)
+	}
+}
+
+// Len は保持件数を返す。
+func (c *DeltaCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats はヒット数とミス数を返す。
+func (c *DeltaCache) Stats() (hits, misses int) {
+	if c == nil {
+		return 0, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Clear は保持内容と統計を破棄する。
+func (c *DeltaCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[deltaCacheKey]*list.Element, c.capacity)
+	c.hits, c.misses = 0, 0
+}
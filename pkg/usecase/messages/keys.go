@@ -15,4 +15,26 @@ const (
 	SavePathServiceNotConfigured       = "保存先判定ができません"
 	TextureExistsValidationFailed      = "テクスチャの存在確認に失敗しました: %s"
 	TextureImageValidationFailed       = "テクスチャの読込に失敗しました: %s"
+	ModelNotSpecified                  = "対象のモデルが指定されていません"
+	MergeAttachBoneNotFound            = "接続先ボーンが見つかりません: %s"
+	PhysicsChainBoneNotFound           = "物理生成対象のボーンが見つかりません: %s"
+	PhysicsChainTooShort               = "物理生成対象のボーン列が短すぎます: %s"
+	WeightBoneNotFound                 = "ウェイト候補のボーンが見つかりません: %s"
+	WeightCandidateEmpty               = "ウェイト候補のボーンがありません"
+	WeightTransferNoSurface            = "ウェイト転写元に面がありません"
+	ExtendedUvIndexInvalid             = "追加UV番号が不正です: %d"
+	MotionNotSpecified                 = "対象のモーションが指定されていません"
+	CameraTargetBoneNotFound           = "カメラ追従対象のボーンが見つかりません: %s"
+	MorphNotFound                      = "モーフが見つかりません: %s"
+	MorphTypeUnsupported               = "未対応のモーフ種別です: %s"
+	MorphVertexCountMismatch           = "頂点数が一致しません: %d / %d"
+	MorphNameDuplicated                = "モーフ名が既に存在します: %s"
+	TextureRepositoryNotConfigured     = "テクスチャ画像リポジトリがありません"
+	TextureAtlasSaveFailed             = "アトラス画像の保存に失敗しました: %s"
+	PmdLimitExceeded                   = "PMDの上限を超えています: %s (%d/%d)"
+	PackageRepositoryNotConfigured     = "パッケージ出力のリポジトリがありません"
+	PackageAssetReadFailed             = "参照ファイルの読込に失敗しました: %s"
+	PackageWriteFailed                 = "パッケージへの書き込みに失敗しました: %s"
+	TextureProcessSaveFailed           = "加工したテクスチャの保存に失敗しました: %s"
+	DecimateRatioInvalid               = "簡略化の割合が不正です: %s (%v)"
 )
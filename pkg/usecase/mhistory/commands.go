@@ -0,0 +1,288 @@
+// 指示: miu200521358
+package mhistory
+
+import (
+	"github.com/miu200521358/mlib_go/pkg/domain/model/collection"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+)
+
+// IUpdatableCollection は要素を置き換え可能なコレクションを表す。
+type IUpdatableCollection[T collection.INameable] interface {
+	Get(index int) (T, error)
+	Update(index int, value T) (collection.ReindexResult, error)
+}
+
+// IRenamableCollection は要素名を変更可能なコレクションを表す。
+type IRenamableCollection[T collection.INameable] interface {
+	Get(index int) (T, error)
+	Rename(index int, newName string) (bool, error)
+}
+
+// FuncCommand は関数で実行/取り消しを定義する操作を表す。
+type FuncCommand struct {
+	label   string
+	targets []hashable.IHashable
+	execute func() error
+	undo    func() error
+}
+
+// NewFuncCommand は関数で定義する操作を生成する。
+func NewFuncCommand(label string, targets []hashable.IHashable, execute, undo func() error) *FuncCommand {
+	return &FuncCommand{label: label, targets: targets, execute: execute, undo: undo}
+}
+
+// Label は操作名を返す。
+func (c *FuncCommand) Label() string {
+	return c.label
+}
+
+// Targets は変更対象を返す。
+func (c *FuncCommand) Targets() []hashable.IHashable {
+	return c.targets
+}
+
+// Execute は操作を実行する。
+func (c *FuncCommand) Execute() error {
+	if c.execute == nil {
+		return nil
+	}
+	return c.execute()
+}
+
+// Undo は操作を取り消す。
+func (c *FuncCommand) Undo() error {
+	if c.undo == nil {
+		return nil
+	}
+	return c.undo()
+}
+
+// UpdateCommand はコレクション要素の置き換えを表す。
+// 新しい要素は既存要素と別のインスタンスで渡すこと。
+type UpdateCommand[T collection.INameable] struct {
+	label    string
+	target   hashable.IHashable
+	items    IUpdatableCollection[T]
+	index    int
+	oldValue T
+	newValue T
+	captured bool
+}
+
+// NewUpdateCommand は要素の置き換え操作を生成する。置き換え前の要素は実行時に記録する。
+func NewUpdateCommand[T collection.INameable](
+	label string,
+	target hashable.IHashable,
+	items IUpdatableCollection[T],
+	index int,
+	value T,
+) *UpdateCommand[T] {
+	return &UpdateCommand[T]{label: label, target: target, items: items, index: index, newValue: value}
+}
+
+// Label は操作名を返す。
+func (c *UpdateCommand[T]) Label() string {
+	return c.label
+}
+
+// Targets は変更対象を返す。
+func (c *UpdateCommand[T]) Targets() []hashable.IHashable {
+	return []hashable.IHashable{c.target}
+}
+
+// Execute は要素を置き換える。
+func (c *UpdateCommand[T]) Execute() error {
+	if !c.captured {
+		oldValue, err := c.items.Get(c.index)
+		if err != nil {
+			return err
+		}
+		c.oldValue = oldValue
+		c.captured = true
+	}
+	_, err := c.items.Update(c.index, c.newValue)
+	return err
+}
+
+// Undo は置き換え前の要素に戻す。
+func (c *UpdateCommand[T]) Undo() error {
+	_, err := c.items.Update(c.index, c.oldValue)
+	return err
+}
+
+// Merge は同じ要素への連続した置き換えを結合する。
+func (c *UpdateCommand[T]) Merge(next ICommand) bool {
+	other, ok := next.(*UpdateCommand[T])
+	if !ok || other.target != c.target || other.index != c.index {
+		return false
+	}
+	c.newValue = other.newValue
+	return true
+}
+
+// RenameCommand はコレクション要素の名前変更を表す。
+type RenameCommand[T collection.INameable] struct {
+	label   string
+	target  hashable.IHashable
+	items   IRenamableCollection[T]
+	index   int
+	oldName string
+	newName string
+}
+
+// NewRenameCommand は名前変更操作を生成する。
+func NewRenameCommand[T collection.INameable](
+	label string,
+	target hashable.IHashable,
+	items IRenamableCollection[T],
+	index int,
+	newName string,
+) *RenameCommand[T] {
+	return &RenameCommand[T]{label: label, target: target, items: items, index: index, newName: newName}
+}
+
+// Label は操作名を返す。
+func (c *RenameCommand[T]) Label() string {
+	return c.label
+}
+
+// Targets は変更対象を返す。
+func (c *RenameCommand[T]) Targets() []hashable.IHashable {
+	return []hashable.IHashable{c.target}
+}
+
+// Execute は名前を変更する。
+func (c *RenameCommand[T]) Execute() error {
+	value, err := c.items.Get(c.index)
+	if err != nil {
+		return err
+	}
+	c.oldName = value.Name()
+	_, err = c.items.Rename(c.index, c.newName)
+	return err
+}
+
+// Undo は変更前の名前に戻す。
+func (c *RenameCommand[T]) Undo() error {
+	_, err := c.items.Rename(c.index, c.oldName)
+	return err
+}
+
+// BoneFrameCommand はボーンキーフレームの登録/置き換えを表す。
+type BoneFrameCommand struct {
+	label    string
+	motion   *motion.VmdMotion
+	boneName string
+	oldFrame *motion.BoneFrame
+	newFrame *motion.BoneFrame
+	captured bool
+}
+
+// NewBoneFrameCommand はボーンキーフレームの登録操作を生成する。
+func NewBoneFrameCommand(label string, motionData *motion.VmdMotion, boneName string, frame *motion.BoneFrame) *BoneFrameCommand {
+	return &BoneFrameCommand{label: label, motion: motionData, boneName: boneName, newFrame: frame}
+}
+
+// Label は操作名を返す。
+func (c *BoneFrameCommand) Label() string {
+	return c.label
+}
+
+// Targets は変更対象を返す。
+func (c *BoneFrameCommand) Targets() []hashable.IHashable {
+	return []hashable.IHashable{c.motion}
+}
+
+// Execute はキーフレームを登録する。
+func (c *BoneFrameCommand) Execute() error {
+	frames := c.motion.BoneFrames.Get(c.boneName)
+	if !c.captured {
+		if frames.Has(c.newFrame.Index()) {
+			c.oldFrame = frames.Get(c.newFrame.Index())
+		}
+		c.captured = true
+	}
+	frames.Update(c.newFrame)
+	return nil
+}
+
+// Undo は登録前のキーフレームに戻す。
+func (c *BoneFrameCommand) Undo() error {
+	frames := c.motion.BoneFrames.Get(c.boneName)
+	if c.oldFrame != nil {
+		frames.Update(c.oldFrame)
+		return nil
+	}
+	frames.Delete(c.newFrame.Index())
+	return nil
+}
+
+// Merge は同じボーン・フレームへの連続した登録を結合する。
+func (c *BoneFrameCommand) Merge(next ICommand) bool {
+	other, ok := next.(*BoneFrameCommand)
+	if !ok || other.motion != c.motion || other.boneName != c.boneName || other.newFrame.Index() != c.newFrame.Index() {
+		return false
+	}
+	c.newFrame = other.newFrame
+	return true
+}
+
+// MorphFrameCommand はモーフキーフレームの登録/置き換えを表す。
+type MorphFrameCommand struct {
+	label     string
+	motion    *motion.VmdMotion
+	morphName string
+	oldFrame  *motion.MorphFrame
+	newFrame  *motion.MorphFrame
+	captured  bool
+}
+
+// NewMorphFrameCommand はモーフキーフレームの登録操作を生成する。
+func NewMorphFrameCommand(label string, motionData *motion.VmdMotion, morphName string, frame *motion.MorphFrame) *MorphFrameCommand {
+	return &MorphFrameCommand{label: label, motion: motionData, morphName: morphName, newFrame: frame}
+}
+
+// Label は操作名を返す。
+func (c *MorphFrameCommand) Label() string {
+	return c.label
+}
+
+// Targets は変更対象を返す。
+func (c *MorphFrameCommand) Targets() []hashable.IHashable {
+	return []hashable.IHashable{c.motion}
+}
+
+// Execute はキーフレームを登録する。
+func (c *MorphFrameCommand) Execute() error {
+	frames := c.motion.MorphFrames.Get(c.morphName)
+	if !c.captured {
+		if frames.Has(c.newFrame.Index()) {
+			c.oldFrame = frames.Get(c.newFrame.Index())
+		}
+		c.captured = true
+	}
+	frames.Update(c.newFrame)
+	return nil
+}
+
+// Undo は登録前のキーフレームに戻す。
+func (c *MorphFrameCommand) Undo() error {
+	frames := c.motion.MorphFrames.Get(c.morphName)
+	if c.oldFrame != nil {
+		frames.Update(c.oldFrame)
+		return nil
+	}
+	frames.Delete(c.newFrame.Index())
+	return nil
+}
+
+// Merge は同じモーフ・フレームへの連続した登録を結合する。
+func (c *MorphFrameCommand) Merge(next ICommand) bool {
+	other, ok := next.(*MorphFrameCommand)
+	if !ok || other.motion != c.motion || other.morphName != c.morphName || other.newFrame.Index() != c.newFrame.Index() {
+		return false
+	}
+	c.newFrame = other.newFrame
+	return true
+}
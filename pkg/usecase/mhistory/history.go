@@ -0,0 +1,278 @@
+// 指示: miu200521358
+package mhistory
+
+import (
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+)
+
+// DEFAULT_HISTORY_LIMIT は履歴の既定保持件数。
+const DEFAULT_HISTORY_LIMIT = 100
+
+// ICommand は取り消し可能な編集操作を表す。
+type ICommand interface {
+	// Label は表示用の操作名を返す。
+	Label() string
+	// Targets は操作で変更されるモデル/モーションを返す。
+	Targets() []hashable.IHashable
+	// Execute は操作を実行する。再実行時にも呼ばれる。
+	Execute() error
+	// Undo は操作を取り消す。
+	Undo() error
+}
+
+// IMergeableCommand は直前の操作と結合できる操作を表す。
+type IMergeableCommand interface {
+	ICommand
+	// Merge は next を自身へ取り込めた場合に true を返す。
+	// 取り込んだ場合、自身の Undo は結合前の状態へ戻す必要がある。
+	Merge(next ICommand) bool
+}
+
+// historyEntry は履歴の1件を表す。
+type historyEntry struct {
+	id      int
+	command ICommand
+	sealed  bool
+}
+
+// History は編集履歴を表す。
+type History struct {
+	limit     int
+	undoStack []*historyEntry
+	redoStack []*historyEntry
+	nextID    int
+	savedID   int
+	// savedLost は保存時点の履歴が破棄されたことを表す。
+	savedLost   bool
+	transaction *Transaction
+	onChanged   func()
+}
+
+// NewHistory は最大 limit 件を保持する履歴を生成する。limit が0以下の場合は既定値。
+func NewHistory(limit int) *History {
+	if limit <= 0 {
+		limit = DEFAULT_HISTORY_LIMIT
+	}
+	return &History{limit: limit, nextID: 1}
+}
+
+// SetOnChanged は履歴や保存状態の変化時に呼ばれる関数を設定する。
+func (h *History) SetOnChanged(fn func()) {
+	h.onChanged = fn
+}
+
+// Execute は操作を実行して履歴に積む。
+// トランザクション中はトランザクションへ追加し、確定時にまとめて積む。
+func (h *History) Execute(command ICommand) error {
+	if command == nil {
+		return nil
+	}
+	if err := command.Execute(); err != nil {
+		return err
+	}
+	updateHashes(command.Targets())
+	if h.transaction != nil {
+		h.transaction.add(command)
+		return nil
+	}
+	h.push(command)
+	h.notify()
+	return nil
+}
+
+// Begin はトランザクションを開始する。開始済みの場合は何もしない。
+func (h *History) Begin(label string) {
+	if h.transaction != nil {
+		return
+	}
+	h.transaction = NewTransaction(label)
+}
+
+// Commit はトランザクションを確定して1件の履歴として積む。
+func (h *History) Commit() {
+	transaction := h.transaction
+	h.transaction = nil
+	if transaction == nil || transaction.Len() == 0 {
+		return
+	}
+	h.push(transaction)
+	h.notify()
+}
+
+// Rollback はトランザクション中の操作を取り消して破棄する。
+func (h *History) Rollback() error {
+	transaction := h.transaction
+	h.transaction = nil
+	if transaction == nil {
+		return nil
+	}
+	if err := transaction.Undo(); err != nil {
+		return err
+	}
+	updateHashes(transaction.Targets())
+	return nil
+}
+
+// InTransaction はトランザクション中か判定する。
+func (h *History) InTransaction() bool {
+	return h.transaction != nil
+}
+
+// SealMerge は直前の操作を確定し、以降の操作と結合しないようにする。
+// スライダー操作の終了時などに呼ぶ。
+func (h *History) SealMerge() {
+	if len(h.undoStack) == 0 {
+		return
+	}
+	h.undoStack[len(h.undoStack)-1].sealed = true
+}
+
+// Undo は直前の操作を取り消す。取り消す操作が無い場合は false を返す。
+func (h *History) Undo() (bool, error) {
+	if len(h.undoStack) == 0 || h.transaction != nil {
+		return false, nil
+	}
+	entry := h.undoStack[len(h.undoStack)-1]
+	if err := entry.command.Undo(); err != nil {
+		return false, err
+	}
+	updateHashes(entry.command.Targets())
+	h.undoStack = h.undoStack[:len(h.undoStack)-1]
+	entry.sealed = true
+	h.redoStack = append(h.redoStack, entry)
+	h.notify()
+	return true, nil
+}
+
+// Redo は取り消した操作を再実行する。再実行する操作が無い場合は false を返す。
+func (h *History) Redo() (bool, error) {
+	if len(h.redoStack) == 0 || h.transaction != nil {
+		return false, nil
+	}
+	entry := h.redoStack[len(h.redoStack)-1]
+	if err := entry.command.Execute(); err != nil {
+		return false, err
+	}
+	updateHashes(entry.command.Targets())
+	h.redoStack = h.redoStack[:len(h.redoStack)-1]
+	h.undoStack = append(h.undoStack, entry)
+	h.notify()
+	return true, nil
+}
+
+// CanUndo は取り消し可能か判定する。
+func (h *History) CanUndo() bool {
+	return len(h.undoStack) > 0 && h.transaction == nil
+}
+
+// CanRedo は再実行可能か判定する。
+func (h *History) CanRedo() bool {
+	return len(h.redoStack) > 0 && h.transaction == nil
+}
+
+// UndoLabel は次に取り消す操作名を返す。
+func (h *History) UndoLabel() string {
+	if len(h.undoStack) == 0 {
+		return ""
+	}
+	return h.undoStack[len(h.undoStack)-1].command.Label()
+}
+
+// RedoLabel は次に再実行する操作名を返す。
+func (h *History) RedoLabel() string {
+	if len(h.redoStack) == 0 {
+		return ""
+	}
+	return h.redoStack[len(h.redoStack)-1].command.Label()
+}
+
+// Len は取り消し可能な履歴件数を返す。
+func (h *History) Len() int {
+	return len(h.undoStack)
+}
+
+// MarkSaved は現在の状態を保存済みとして記録する。
+func (h *History) MarkSaved() {
+	h.savedID = h.currentID()
+	h.savedLost = false
+	h.notify()
+}
+
+// IsDirty は保存後に変更があるか判定する。
+func (h *History) IsDirty() bool {
+	return h.savedLost || h.savedID != h.currentID()
+}
+
+// Clear は履歴を破棄し、現在の状態を保存済みとする。
+func (h *History) Clear() {
+	h.undoStack = nil
+	h.redoStack = nil
+	h.transaction = nil
+	h.savedID = 0
+	h.savedLost = false
+	h.notify()
+}
+
+// push は操作を履歴へ積み、再実行履歴を破棄する。
+func (h *History) push(command ICommand) {
+	for _, entry := range h.redoStack {
+		if entry.id == h.savedID {
+			h.savedLost = true
+		}
+	}
+	h.redoStack = nil
+
+	if len(h.undoStack) > 0 {
+		last := h.undoStack[len(h.undoStack)-1]
+		if mergeable, ok := last.command.(IMergeableCommand); ok && !last.sealed && mergeable.Merge(command) {
+			// 結合で内容が変わるため、保存済み判定用のIDを振り直す。
+			last.id = h.newID()
+			return
+		}
+	}
+
+	h.undoStack = append(h.undoStack, &historyEntry{id: h.newID(), command: command})
+	if over := len(h.undoStack) - h.limit; over > 0 {
+		// 保存時点より前へは戻れなくなるため、保存状態には戻れないものとして扱う。
+		if h.savedID == 0 {
+			h.savedLost = true
+		}
+		for _, entry := range h.undoStack[:over] {
+			if entry.id == h.savedID {
+				h.savedLost = true
+			}
+		}
+		h.undoStack = append([]*historyEntry(nil), h.undoStack[over:]...)
+	}
+}
+
+// currentID は現在の状態を表すIDを返す。
+func (h *History) currentID() int {
+	if len(h.undoStack) == 0 {
+		return 0
+	}
+	return h.undoStack[len(h.undoStack)-1].id
+}
+
+// newID は履歴IDを払い出す。
+func (h *History) newID() int {
+	id := h.nextID
+	h.nextID++
+	return id
+}
+
+// notify は変化を通知する。
+func (h *History) notify() {
+	if h.onChanged != nil {
+		h.onChanged()
+	}
+}
+
+// updateHashes は変更対象のハッシュを再計算する。
+func updateHashes(targets []hashable.IHashable) {
+	for _, target := range targets {
+		if target != nil {
+			target.UpdateHash()
+		}
+	}
+}
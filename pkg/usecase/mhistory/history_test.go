@@ -0,0 +1,172 @@
+// 指示: miu200521358
+package mhistory
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+)
+
+// newHistoryTestModel はボーン1本のモデルを生成する。
+func newHistoryTestModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	bone := &model.Bone{ParentIndex: -1, TailIndex: -1, EffectIndex: -1}
+	bone.SetName("センター")
+	m.Bones.Append(bone)
+	m.UpdateHash()
+	return m
+}
+
+// TestHistoryUndoRedoModel はモデル編集の取り消し/再実行と保存状態を確認する。
+func TestHistoryUndoRedoModel(t *testing.T) {
+	m := newHistoryTestModel()
+	history := NewHistory(0)
+
+	if err := history.Execute(NewRenameCommand[*model.Bone]("rename", m, m.Bones, 0, "全ての親")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !m.Bones.ContainsByName("全ての親") || !history.IsDirty() || !history.CanUndo() {
+		t.Fatalf("rename not applied")
+	}
+	updated := &model.Bone{ParentIndex: -1, TailIndex: -1, EffectIndex: -1, Layer: 2}
+	updated.SetName("全ての親")
+	if err := history.Execute(NewUpdateCommand[*model.Bone]("update", m, m.Bones, 0, updated)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ok, err := history.Undo(); !ok || err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	bone, _ := m.Bones.Get(0)
+	if bone.Layer != 0 || history.RedoLabel() != "update" {
+		t.Fatalf("update not undone: %d", bone.Layer)
+	}
+	if _, err := history.Undo(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if !m.Bones.ContainsByName("センター") {
+		t.Fatalf("rename not undone")
+	}
+	if history.IsDirty() {
+		t.Fatalf("history should be clean at the saved point")
+	}
+	if _, err := history.Redo(); err != nil {
+		t.Fatalf("redo failed: %v", err)
+	}
+	if !m.Bones.ContainsByName("全ての親") {
+		t.Fatalf("redo not applied")
+	}
+}
+
+// hashCounter はハッシュ更新回数を数える変更対象。
+type hashCounter struct {
+	*hashable.HashableBase
+	updates int
+}
+
+// UpdateHash は更新回数を数える。
+func (h *hashCounter) UpdateHash() {
+	h.updates++
+	h.HashableBase.UpdateHash()
+}
+
+// TestHistoryUpdatesHashes は実行/取り消し/再実行のたびにハッシュが更新されることを確認する。
+func TestHistoryUpdatesHashes(t *testing.T) {
+	target := &hashCounter{HashableBase: hashable.NewHashableBase("", "")}
+	value := 0
+	command := NewFuncCommand("set", []hashable.IHashable{target},
+		func() error { value = 1; return nil },
+		func() error { value = 0; return nil },
+	)
+	history := NewHistory(0)
+	if err := history.Execute(command); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := history.Undo(); err != nil || value != 0 {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if _, err := history.Redo(); err != nil || value != 1 {
+		t.Fatalf("redo failed: %v", err)
+	}
+	if target.updates != 3 {
+		t.Fatalf("hash update count mismatch: %d", target.updates)
+	}
+}
+
+// TestHistoryMergeAndTransaction はスライダー操作の結合とトランザクションを確認する。
+func TestHistoryMergeAndTransaction(t *testing.T) {
+	motionData := motion.NewVmdMotion("")
+	history := NewHistory(0)
+	for _, ratio := range []float64{0.1, 0.5, 0.9} {
+		mf := motion.NewMorphFrame(10)
+		mf.Ratio = ratio
+		if err := history.Execute(NewMorphFrameCommand("morph", motionData, "あ", mf)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if history.Len() != 1 || motionData.MorphFrames.Get("あ").Get(10).Ratio != 0.9 {
+		t.Fatalf("slider edits should be merged: %d", history.Len())
+	}
+	history.SealMerge()
+
+	history.Begin("pose")
+	for _, name := range []string{"センター", "上半身"} {
+		if err := history.Execute(NewBoneFrameCommand("bone", motionData, name, motion.NewBoneFrame(10))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if history.CanUndo() {
+		t.Fatalf("undo should be disabled during a transaction")
+	}
+	history.Commit()
+	if history.Len() != 2 || history.UndoLabel() != "pose" {
+		t.Fatalf("transaction should be one entry: %d %s", history.Len(), history.UndoLabel())
+	}
+
+	if _, err := history.Undo(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if motionData.BoneFrames.Get("センター").Has(10) || motionData.BoneFrames.Get("上半身").Has(10) {
+		t.Fatalf("transaction not undone")
+	}
+	if _, err := history.Undo(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if motionData.MorphFrames.Get("あ").Has(10) {
+		t.Fatalf("merged morph edit not undone")
+	}
+}
+
+// TestHistoryLimitAndDirty は保持件数の上限と保存状態の追跡を確認する。
+func TestHistoryLimitAndDirty(t *testing.T) {
+	motionData := motion.NewVmdMotion("")
+	history := NewHistory(2)
+	changed := 0
+	history.SetOnChanged(func() { changed++ })
+	for i := 0; i < 3; i++ {
+		if err := history.Execute(NewBoneFrameCommand("bone", motionData, "センター", motion.NewBoneFrame(motion.Frame(i)))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if history.Len() != 2 || changed != 3 {
+		t.Fatalf("history should be bounded: len=%d changed=%d", history.Len(), changed)
+	}
+	history.MarkSaved()
+	if history.IsDirty() {
+		t.Fatalf("history should be clean after save")
+	}
+	if _, err := history.Undo(); err != nil {
+		t.Fatalf("undo failed: %v", err)
+	}
+	if !history.IsDirty() {
+		t.Fatalf("history should be dirty after undo")
+	}
+	if err := history.Execute(NewBoneFrameCommand("bone", motionData, "センター", motion.NewBoneFrame(5))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if history.CanRedo() || !history.IsDirty() {
+		t.Fatalf("redo should be discarded and saved state lost")
+	}
+}
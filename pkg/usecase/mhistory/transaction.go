@@ -0,0 +1,76 @@
+// 指示: miu200521358
+package mhistory
+
+import (
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+)
+
+// Transaction は複数の操作を1件の履歴としてまとめる。
+type Transaction struct {
+	label    string
+	commands []ICommand
+}
+
+// NewTransaction はトランザクションを生成する。
+func NewTransaction(label string) *Transaction {
+	return &Transaction{label: label}
+}
+
+// Label は操作名を返す。
+func (t *Transaction) Label() string {
+	return t.label
+}
+
+// Len は含まれる操作数を返す。
+func (t *Transaction) Len() int {
+	return len(t.commands)
+}
+
+// Targets は含まれる操作の変更対象を重複なく返す。
+func (t *Transaction) Targets() []hashable.IHashable {
+	targets := make([]hashable.IHashable, 0)
+	seen := map[hashable.IHashable]struct{}{}
+	for _, command := range t.commands {
+		for _, target := range command.Targets() {
+			if _, ok := seen[target]; ok {
+				continue
+			}
+			seen[target] = struct{}{}
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// Execute は操作を順に実行する。途中で失敗した場合は実行済みの操作を取り消す。
+func (t *Transaction) Execute() error {
+	for i, command := range t.commands {
+		if err := command.Execute(); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				_ = t.commands[j].Undo()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Undo は操作を逆順に取り消す。
+func (t *Transaction) Undo() error {
+	for i := len(t.commands) - 1; i >= 0; i-- {
+		if err := t.commands[i].Undo(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add は実行済みの操作を追加する。直前の操作と結合できる場合は結合する。
+func (t *Transaction) add(command ICommand) {
+	if len(t.commands) > 0 {
+		if mergeable, ok := t.commands[len(t.commands)-1].(IMergeableCommand); ok && mergeable.Merge(command) {
+			return
+		}
+	}
+	t.commands = append(t.commands, command)
+}
@@ -0,0 +1,750 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/collection"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+	portio "github.com/miu200521358/mlib_go/pkg/usecase/port/io"
+)
+
+const (
+	textureImageRepositoryNotConfiguredErrorID = "93504"
+	textureAtlasSaveFailedErrorID              = "93505"
+	// defaultAtlasMaxSize はアトラス画像の最大辺の既定値。
+	defaultAtlasMaxSize = 4096
+	// defaultAtlasTextureName はアトラス画像のテクスチャ名の既定値。
+	defaultAtlasTextureName = "atlas.png"
+	// atlasUvTolerance はUVが0-1の範囲内とみなす許容差。
+	atlasUvTolerance = 1e-4
+)
+
+// AtlasOptions はテクスチャアトラス化の設定を表す。
+type AtlasOptions struct {
+	// TextureName はアトラス画像のテクスチャ名(モデルからの相対パス)。複数枚の場合は連番を付ける。空の場合は "atlas.png"。
+	TextureName string
+	// MaxSize はアトラス画像の最大辺(px)。0 の場合は4096。
+	MaxSize int
+	// Padding は各テクスチャの周囲へ複製する境界画素数。
+	Padding int
+}
+
+// AtlasGroup は1つの材質へ統合した材質群を表す。
+type AtlasGroup struct {
+	// MaterialName は統合後の材質名。
+	MaterialName string
+	// MergedMaterials は統合した材質名(統合先を含む)。
+	MergedMaterials []string
+	// TextureName は統合後のテクスチャ名。
+	TextureName string
+	// Width, Height はアトラス画像の大きさ。テクスチャが1種類の場合は0。
+	Width  int
+	Height int
+}
+
+// AtlasResult はテクスチャアトラス化の結果を表す。
+type AtlasResult struct {
+	Groups []AtlasGroup
+	// SkippedMaterials はUV範囲外・画像読込失敗・最大サイズ超過・描画順の変わる半透明材質のため統合しなかった材質名。
+	SkippedMaterials []string
+	// BroadenedMorphs は統合した材質の一部のみを対象にしていたため、効果範囲が広がった材質モーフ名。
+	BroadenedMorphs []string
+	// DrawCallsBefore, DrawCallsAfter は描画対象の材質数。
+	DrawCallsBefore int
+	DrawCallsAfter  int
+}
+
+// DrawCallsSaved は削減した描画回数を返す。
+func (r *AtlasResult) DrawCallsSaved() int {
+	return r.DrawCallsBefore - r.DrawCallsAfter
+}
+
+// atlasMaterialKey はテクスチャ以外の描画設定が同じ材質を判定するキー。
+type atlasMaterialKey struct {
+	Diffuse             mmath.Vec4
+	Specular            mmath.Vec4
+	Ambient             mmath.Vec3
+	DrawFlag            model.DrawFlag
+	Edge                mmath.Vec4
+	EdgeSize            float64
+	TextureFactor       mmath.Vec4
+	SphereTextureFactor mmath.Vec4
+	ToonTextureFactor   mmath.Vec4
+	SphereTextureIndex  int
+	SphereMode          model.SphereMode
+	ToonSharingFlag     model.ToonSharingFlag
+	ToonTextureIndex    int
+}
+
+// atlasRegion はアトラス画像内のテクスチャ配置を表す。
+type atlasRegion struct {
+	x, y, width, height int
+}
+
+// atlasPlan は1つの統合先材質への統合計画を表す。
+type atlasPlan struct {
+	materials []int
+	// textureIndex は統合後のテクスチャ index。アトラス画像を追加する前は -1。
+	textureIndex int
+	regions      map[int]atlasRegion
+	atlasWidth   int
+	atlasHeight  int
+	group        AtlasGroup
+}
+
+// BuildTextureAtlas はテクスチャ以外の描画設定が同じ材質のテクスチャを1枚のアトラス画像へまとめ、材質を統合する。
+// 頂点UV(UVモーフを含む)をアトラス上の位置へ変換し、統合した材質の面は統合先材質の位置へまとめる。
+// 他の材質と共有する頂点は複製してから変換する。
+// アトラス画像名は既存のテクスチャ名・画像ファイルと重ならないよう連番を付ける。
+func BuildTextureAtlas(
+	modelData *model.PmxModel,
+	images portio.ITextureImageRepository,
+	opts AtlasOptions,
+) (*AtlasResult, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if images == nil {
+		return nil, merr.NewCommonError(
+			textureImageRepositoryNotConfiguredErrorID,
+			merr.ErrorKindInternal,
+			messages.TextureRepositoryNotConfigured,
+			nil,
+		)
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultAtlasMaxSize
+	}
+	if opts.TextureName == "" {
+		opts.TextureName = defaultAtlasTextureName
+	}
+	if opts.Padding < 0 {
+		opts.Padding = 0
+	}
+
+	result := &AtlasResult{DrawCallsBefore: countDrawCalls(modelData)}
+	faceMaterials := buildFaceMaterials(modelData)
+	candidates := groupAtlasMaterials(modelData, faceMaterials, result)
+
+	// 画像の保存に失敗してもモデルを変更しないよう、先に全グループの画像を作成する。
+	usedNames := make(map[string]bool)
+	for _, texture := range modelData.Textures.Values() {
+		if texture != nil {
+			usedNames[strings.ToLower(texture.Name())] = true
+		}
+	}
+	plans := make([]*atlasPlan, 0, len(candidates))
+	for _, materials := range candidates {
+		plan, err := planAtlas(modelData, images, materials, opts, len(plans), len(candidates), usedNames, result)
+		if err != nil {
+			return nil, err
+		}
+		if plan != nil {
+			plans = append(plans, plan)
+		}
+	}
+	if len(plans) == 0 {
+		result.DrawCallsAfter = result.DrawCallsBefore
+		return result, nil
+	}
+
+	for _, plan := range plans {
+		if plan.textureIndex >= 0 {
+			continue
+		}
+		texture := model.NewTexture()
+		texture.SetName(plan.group.TextureName)
+		texture.SetValid(true)
+		plan.textureIndex, _ = modelData.Textures.Append(texture)
+	}
+	remapAtlasUvs(modelData, faceMaterials, plans)
+	mergeAtlasMaterials(modelData, plans, result)
+	for _, plan := range plans {
+		result.Groups = append(result.Groups, plan.group)
+	}
+	result.DrawCallsAfter = countDrawCalls(modelData)
+	modelData.UpdateHash()
+	return result, nil
+}
+
+// groupAtlasMaterials はアトラス化できる材質を描画設定ごとにまとめ、2つ以上の材質を持つ組を返す。
+func groupAtlasMaterials(modelData *model.PmxModel, faceMaterials []int, result *AtlasResult) [][]int {
+	outOfRange := make(map[int]bool)
+	vertices := modelData.Vertices.Values()
+	for faceIndex, face := range modelData.Faces.Values() {
+		materialIndex := faceMaterials[faceIndex]
+		if face == nil || materialIndex < 0 || outOfRange[materialIndex] {
+			continue
+		}
+		for _, vertexIndex := range face.VertexIndexes {
+			if vertexIndex < 0 || vertexIndex >= len(vertices) || vertices[vertexIndex] == nil {
+				continue
+			}
+			// 0-1 の範囲外を参照する(タイリングする)UVはアトラス上で隣のテクスチャを参照してしまう。
+			uv := vertices[vertexIndex].Uv
+			if uv.X < -atlasUvTolerance || uv.X > 1+atlasUvTolerance ||
+				uv.Y < -atlasUvTolerance || uv.Y > 1+atlasUvTolerance {
+				outOfRange[materialIndex] = true
+				break
+			}
+		}
+	}
+
+	keys := make([]atlasMaterialKey, 0)
+	groups := make(map[atlasMaterialKey][]int)
+	for _, material := range modelData.Materials.Values() {
+		if material == nil || material.TextureIndex < 0 || material.VerticesCount <= 0 {
+			continue
+		}
+		if outOfRange[material.Index()] {
+			result.SkippedMaterials = append(result.SkippedMaterials, material.Name())
+			continue
+		}
+		key := atlasMaterialKey{
+			Diffuse:             material.Diffuse,
+			Specular:            material.Specular,
+			Ambient:             material.Ambient,
+			DrawFlag:            material.DrawFlag,
+			Edge:                material.Edge,
+			EdgeSize:            material.EdgeSize,
+			TextureFactor:       material.TextureFactor,
+			SphereTextureFactor: material.SphereTextureFactor,
+			ToonTextureFactor:   material.ToonTextureFactor,
+			SphereTextureIndex:  material.SphereTextureIndex,
+			SphereMode:          material.SphereMode,
+			ToonSharingFlag:     material.ToonSharingFlag,
+			ToonTextureIndex:    material.ToonTextureIndex,
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], material.Index())
+	}
+
+	candidates := make([][]int, 0, len(keys))
+	for _, key := range keys {
+		if len(groups[key]) >= 2 {
+			candidates = append(candidates, groups[key])
+		}
+	}
+	return candidates
+}
+
+// planAtlas は材質群のテクスチャを読み込んでアトラス画像を作成・保存し、統合計画を返す。
+// 統合できない場合は nil を返す。
+func planAtlas(
+	modelData *model.PmxModel,
+	images portio.ITextureImageRepository,
+	materials []int,
+	opts AtlasOptions,
+	planIndex, planCount int,
+	usedNames map[string]bool,
+	result *AtlasResult,
+) (*atlasPlan, error) {
+	textureImages := make(map[int]image.Image)
+	usable := make([]int, 0, len(materials))
+	for _, materialIndex := range materials {
+		material, _ := modelData.Materials.Get(materialIndex)
+		textureIndex := material.TextureIndex
+		if _, ok := textureImages[textureIndex]; !ok {
+			texture, err := modelData.Textures.Get(textureIndex)
+			if err != nil || texture == nil {
+				result.SkippedMaterials = append(result.SkippedMaterials, material.Name())
+				continue
+			}
+			img, err := images.LoadImage(atlasTexturePath(modelData, texture.Name()))
+			if err != nil || img == nil {
+				result.SkippedMaterials = append(result.SkippedMaterials, material.Name())
+				continue
+			}
+			textureImages[textureIndex] = img
+		}
+		usable = append(usable, materialIndex)
+	}
+	usable = keepAtlasDrawOrder(modelData, usable, textureImages, result)
+	if len(usable) < 2 {
+		// 統合相手がいない材質は除外扱いにしない。
+		return nil, nil
+	}
+	textureIndexes := make([]int, 0, len(usable))
+	for _, materialIndex := range usable {
+		material, _ := modelData.Materials.Get(materialIndex)
+		if !containsInt(textureIndexes, material.TextureIndex) {
+			textureIndexes = append(textureIndexes, material.TextureIndex)
+		}
+	}
+
+	plan := &atlasPlan{materials: usable, regions: make(map[int]atlasRegion)}
+	leader, _ := modelData.Materials.Get(usable[0])
+	plan.group.MaterialName = leader.Name()
+	for _, materialIndex := range usable {
+		material, _ := modelData.Materials.Get(materialIndex)
+		plan.group.MergedMaterials = append(plan.group.MergedMaterials, material.Name())
+	}
+	if len(textureIndexes) == 1 {
+		// テクスチャが1種類ならアトラス画像は作らずに材質だけを統合する。
+		plan.textureIndex = textureIndexes[0]
+		texture, _ := modelData.Textures.Get(plan.textureIndex)
+		plan.group.TextureName = texture.Name()
+		return plan, nil
+	}
+
+	sizes := make([]image.Point, len(textureIndexes))
+	for i, textureIndex := range textureIndexes {
+		bounds := textureImages[textureIndex].Bounds()
+		sizes[i] = image.Pt(bounds.Dx()+opts.Padding*2, bounds.Dy()+opts.Padding*2)
+	}
+	positions, width, height, ok := packAtlas(sizes, opts.MaxSize)
+	if !ok {
+		for _, materialIndex := range usable {
+			material, _ := modelData.Materials.Get(materialIndex)
+			result.SkippedMaterials = append(result.SkippedMaterials, material.Name())
+		}
+		return nil, nil
+	}
+
+	atlas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i, textureIndex := range textureIndexes {
+		src := textureImages[textureIndex]
+		region := atlasRegion{
+			x:      positions[i].X + opts.Padding,
+			y:      positions[i].Y + opts.Padding,
+			width:  src.Bounds().Dx(),
+			height: src.Bounds().Dy(),
+		}
+		drawAtlasRegion(atlas, src, region, opts.Padding)
+		plan.regions[textureIndex] = region
+	}
+	plan.atlasWidth = width
+	plan.atlasHeight = height
+
+	textureName := uniqueAtlasTextureName(
+		modelData, images, atlasTextureName(opts.TextureName, planIndex, planCount), usedNames)
+	if err := images.SaveImage(atlasTexturePath(modelData, textureName), atlas); err != nil {
+		return nil, merr.NewCommonError(
+			textureAtlasSaveFailedErrorID,
+			merr.ErrorKindExternal,
+			messages.TextureAtlasSaveFailed,
+			err,
+			textureName,
+		)
+	}
+	plan.textureIndex = -1
+	plan.group.TextureName = textureName
+	plan.group.Width = width
+	plan.group.Height = height
+	return plan, nil
+}
+
+// keepAtlasDrawOrder は統合すると描画順が変わる半透明材質を統合対象から外す。
+// 統合した材質の面は統合先の位置へ移動するため、間に統合しない材質を挟む半透明材質はその材質より先に描画されてしまう。
+func keepAtlasDrawOrder(
+	modelData *model.PmxModel,
+	materials []int,
+	textureImages map[int]image.Image,
+	result *AtlasResult,
+) []int {
+	if len(materials) == 0 {
+		return materials
+	}
+	kept := []int{materials[0]}
+	for _, materialIndex := range materials[1:] {
+		material, _ := modelData.Materials.Get(materialIndex)
+		if !atlasMaterialHasAlpha(material, textureImages[material.TextureIndex]) {
+			kept = append(kept, materialIndex)
+			continue
+		}
+		adjacent := true
+		for between := kept[0] + 1; between < materialIndex; between++ {
+			other, err := modelData.Materials.Get(between)
+			if err == nil && other != nil && other.VerticesCount > 0 && !containsInt(kept, between) {
+				adjacent = false
+				break
+			}
+		}
+		if !adjacent {
+			result.SkippedMaterials = append(result.SkippedMaterials, material.Name())
+			continue
+		}
+		kept = append(kept, materialIndex)
+	}
+	return kept
+}
+
+// atlasMaterialHasAlpha は材質の拡散色またはテクスチャが半透明かを判定する。
+func atlasMaterialHasAlpha(material *model.Material, img image.Image) bool {
+	if material.Diffuse.W < 1 {
+		return true
+	}
+	if img == nil {
+		return false
+	}
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a < 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// packAtlas は矩形を高さ順の棚詰めで配置し、配置位置とアトラスの大きさ(2のべき乗)を返す。
+// 幅は正方形に収まる最小の2のべき乗を選ぶ。
+func packAtlas(sizes []image.Point, maxSize int) ([]image.Point, int, int, bool) {
+	order := make([]int, len(sizes))
+	maxWidth := 0
+	for i, size := range sizes {
+		order[i] = i
+		maxWidth = max(maxWidth, size.X)
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return sizes[b].Y - sizes[a].Y })
+
+	for width := nextPowerOfTwo(maxWidth); width <= maxSize; width *= 2 {
+		positions := make([]image.Point, len(sizes))
+		x, y, shelfHeight := 0, 0, 0
+		for _, i := range order {
+			if x+sizes[i].X > width {
+				x = 0
+				y += shelfHeight
+				shelfHeight = 0
+			}
+			positions[i] = image.Pt(x, y)
+			x += sizes[i].X
+			shelfHeight = max(shelfHeight, sizes[i].Y)
+		}
+		height := nextPowerOfTwo(y + shelfHeight)
+		if height <= width {
+			return positions, width, height, true
+		}
+	}
+	return nil, 0, 0, false
+}
+
+// nextPowerOfTwo は v 以上の最小の2のべき乗を返す。
+func nextPowerOfTwo(v int) int {
+	n := 1
+	for n < v {
+		n *= 2
+	}
+	return n
+}
+
+// drawAtlasRegion はテクスチャをアトラスへ描画し、周囲の余白へ境界画素を複製する。
+func drawAtlasRegion(atlas *image.NRGBA, src image.Image, region atlasRegion, padding int) {
+	dstRect := image.Rect(region.x, region.y, region.x+region.width, region.y+region.height)
+	draw.Draw(atlas, dstRect, src, src.Bounds().Min, draw.Src)
+	if padding <= 0 {
+		return
+	}
+	for y := region.y - padding; y < region.y+region.height+padding; y++ {
+		for x := region.x - padding; x < region.x+region.width+padding; x++ {
+			if image.Pt(x, y).In(dstRect) {
+				continue
+			}
+			sx := min(max(x, dstRect.Min.X), dstRect.Max.X-1)
+			sy := min(max(y, dstRect.Min.Y), dstRect.Max.Y-1)
+			atlas.Set(x, y, atlas.At(sx, sy))
+		}
+	}
+}
+
+// remapAtlasUvs は統合する材質の頂点UVとUVモーフをアトラス上の位置へ変換する。
+// 変換の異なる材質と共有する頂点は複製する。
+func remapAtlasUvs(modelData *model.PmxModel, faceMaterials []int, plans []*atlasPlan) {
+	type uvTransform struct {
+		offset mmath.Vec2
+		scale  mmath.Vec2
+	}
+	transforms := make(map[int]uvTransform)
+	for _, plan := range plans {
+		if len(plan.regions) == 0 {
+			continue
+		}
+		for _, materialIndex := range plan.materials {
+			material, _ := modelData.Materials.Get(materialIndex)
+			region := plan.regions[material.TextureIndex]
+			transforms[materialIndex] = uvTransform{
+				offset: mmath.Vec2{
+					X: float64(region.x) / float64(plan.atlasWidth),
+					Y: float64(region.y) / float64(plan.atlasHeight),
+				},
+				scale: mmath.Vec2{
+					X: float64(region.width) / float64(plan.atlasWidth),
+					Y: float64(region.height) / float64(plan.atlasHeight),
+				},
+			}
+		}
+	}
+	if len(transforms) == 0 {
+		return
+	}
+
+	vertexUsers := make([][]int, modelData.Vertices.Len())
+	for faceIndex, face := range modelData.Faces.Values() {
+		if face == nil {
+			continue
+		}
+		for _, vertexIndex := range face.VertexIndexes {
+			if vertexIndex >= 0 && vertexIndex < len(vertexUsers) &&
+				!containsInt(vertexUsers[vertexIndex], faceMaterials[faceIndex]) {
+				vertexUsers[vertexIndex] = append(vertexUsers[vertexIndex], faceMaterials[faceIndex])
+			}
+		}
+	}
+
+	// 材質ごとに変換済み頂点を記録し、同じ頂点を二重に変換しない。
+	converted := make(map[int]map[int]int)
+	claimed := make(map[int]bool)
+	scales := make(map[int]mmath.Vec2)
+	copies := make(map[int][]int)
+	for faceIndex, face := range modelData.Faces.Values() {
+		materialIndex := faceMaterials[faceIndex]
+		transform, ok := transforms[materialIndex]
+		if face == nil || !ok {
+			continue
+		}
+		if converted[materialIndex] == nil {
+			converted[materialIndex] = make(map[int]int)
+		}
+		for k, vertexIndex := range face.VertexIndexes {
+			if vertexIndex < 0 || vertexIndex >= len(vertexUsers) {
+				continue
+			}
+			if newIndex, ok := converted[materialIndex][vertexIndex]; ok {
+				face.VertexIndexes[k] = newIndex
+				continue
+			}
+			vertex, _ := modelData.Vertices.Get(vertexIndex)
+			newIndex := vertexIndex
+			inPlace := !claimed[vertexIndex]
+			for _, user := range vertexUsers[vertexIndex] {
+				if _, ok := transforms[user]; !ok {
+					inPlace = false
+				}
+			}
+			claimed[vertexIndex] = true
+			// 変換しない材質と共有する頂点や、他の材質が変換済みの頂点は複製してから変換する。
+			if !inPlace {
+				vertex = copyVertex(vertex)
+				vertex.MaterialIndexes = []int{materialIndex}
+				newIndex = modelData.Vertices.AppendRaw(vertex)
+				copies[vertexIndex] = append(copies[vertexIndex], newIndex)
+			}
+			vertex.Uv = mmath.Vec2{
+				X: transform.offset.X + vertex.Uv.X*transform.scale.X,
+				Y: transform.offset.Y + vertex.Uv.Y*transform.scale.Y,
+			}
+			converted[materialIndex][vertexIndex] = newIndex
+			scales[newIndex] = transform.scale
+			face.VertexIndexes[k] = newIndex
+		}
+	}
+	if len(copies) > 0 {
+		duplicateVertexMorphOffsets(modelData, copies)
+	}
+
+	for _, morph := range modelData.Morphs.Values() {
+		if morph == nil {
+			continue
+		}
+		for k, offset := range morph.Offsets {
+			o, ok := offset.(*model.UvMorphOffset)
+			if !ok || o.UvType != model.MORPH_TYPE_UV {
+				continue
+			}
+			scale, ok := scales[o.VertexIndex]
+			if !ok {
+				continue
+			}
+			// 複製元と共有しないよう、変換するオフセットは作り直す。
+			scaled := *o
+			scaled.Uv.X *= scale.X
+			scaled.Uv.Y *= scale.Y
+			morph.Offsets[k] = &scaled
+		}
+	}
+}
+
+// mergeAtlasMaterials は統合計画に従って面を並べ替え、材質と参照を詰め直す。
+func mergeAtlasMaterials(modelData *model.PmxModel, plans []*atlasPlan, result *AtlasResult) {
+	materials := modelData.Materials.Values()
+	leaderOf := make([]int, len(materials))
+	for i := range leaderOf {
+		leaderOf[i] = i
+	}
+	members := make(map[int][]int)
+	for _, plan := range plans {
+		leader := plan.materials[0]
+		members[leader] = plan.materials
+		for _, materialIndex := range plan.materials {
+			leaderOf[materialIndex] = leader
+		}
+	}
+
+	faces := modelData.Faces.Values()
+	faceStarts := make([]int, len(materials)+1)
+	for i, material := range materials {
+		faceStarts[i+1] = faceStarts[i]
+		if material != nil {
+			faceStarts[i+1] += material.VerticesCount / 3
+		}
+	}
+	rebuiltFaces := collection.NewIndexedCollection[*model.Face](len(faces))
+	rebuiltMaterials := collection.NewNamedCollection[*model.Material](len(materials))
+	oldToNew := make([]int, len(materials))
+	for i, material := range materials {
+		if leaderOf[i] != i {
+			continue
+		}
+		group, ok := members[i]
+		if !ok {
+			group = []int{i}
+		}
+		verticesCount := 0
+		for _, materialIndex := range group {
+			for f := faceStarts[materialIndex]; f < faceStarts[materialIndex+1] && f < len(faces); f++ {
+				rebuiltFaces.AppendRaw(faces[f])
+			}
+			verticesCount += materials[materialIndex].VerticesCount
+		}
+		if ok {
+			for _, plan := range plans {
+				if plan.materials[0] == i {
+					material.TextureIndex = plan.textureIndex
+				}
+			}
+			material.VerticesCount = verticesCount
+		}
+		oldToNew[i] = rebuiltMaterials.AppendRaw(material)
+	}
+	for i := range materials {
+		oldToNew[i] = oldToNew[leaderOf[i]]
+	}
+	modelData.Faces = rebuiltFaces
+	modelData.Materials = rebuiltMaterials
+
+	for _, vertex := range modelData.Vertices.Values() {
+		if vertex == nil {
+			continue
+		}
+		remapped := vertex.MaterialIndexes[:0]
+		for _, materialIndex := range vertex.MaterialIndexes {
+			mapped := mapIndex(oldToNew, materialIndex)
+			if mapped >= 0 && !containsInt(remapped, mapped) {
+				remapped = append(remapped, mapped)
+			}
+		}
+		vertex.MaterialIndexes = remapped
+	}
+
+	for _, morph := range modelData.Morphs.Values() {
+		if morph == nil || morph.MorphType != model.MORPH_TYPE_MATERIAL {
+			continue
+		}
+		targets := make(map[int]bool)
+		for _, offset := range morph.Offsets {
+			if o, ok := offset.(*model.MaterialMorphOffset); ok && o.MaterialIndex >= 0 {
+				targets[o.MaterialIndex] = true
+			}
+		}
+		broadened := false
+		for _, group := range members {
+			covered := 0
+			for _, materialIndex := range group {
+				if targets[materialIndex] {
+					covered++
+				}
+			}
+			if covered > 0 && covered < len(group) {
+				broadened = true
+			}
+		}
+		if broadened {
+			result.BroadenedMorphs = append(result.BroadenedMorphs, morph.Name())
+		}
+
+		seen := make(map[int]struct{}, len(morph.Offsets))
+		offsets := morph.Offsets[:0]
+		for _, offset := range morph.Offsets {
+			o, ok := offset.(*model.MaterialMorphOffset)
+			if ok && o.MaterialIndex >= 0 {
+				o.MaterialIndex = mapIndex(oldToNew, o.MaterialIndex)
+				if o.MaterialIndex < 0 {
+					continue
+				}
+				// 統合で同じ材質を指すオフセットは先頭のみ残す。
+				if _, exists := seen[o.MaterialIndex]; exists {
+					continue
+				}
+				seen[o.MaterialIndex] = struct{}{}
+			}
+			offsets = append(offsets, offset)
+		}
+		morph.Offsets = offsets
+	}
+}
+
+// countDrawCalls は面を持つ材質数を返す。
+func countDrawCalls(modelData *model.PmxModel) int {
+	count := 0
+	for _, material := range modelData.Materials.Values() {
+		if material != nil && material.VerticesCount > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// atlasTextureName は連番付きのアトラス画像名を返す。
+func atlasTextureName(name string, index, count int) string {
+	if count <= 1 {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s_%02d%s", strings.TrimSuffix(name, ext), index, ext)
+}
+
+// uniqueAtlasTextureName は既存のテクスチャ名・画像ファイルと重ならないアトラス画像名を返す。
+func uniqueAtlasTextureName(
+	modelData *model.PmxModel,
+	images portio.ITextureImageRepository,
+	name string,
+	usedNames map[string]bool,
+) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; usedNames[strings.ToLower(candidate)] || atlasImageExists(modelData, images, candidate); i++ {
+		candidate = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+	usedNames[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// atlasImageExists は保存先に読み込める画像が既にあるかを判定する。
+func atlasImageExists(modelData *model.PmxModel, images portio.ITextureImageRepository, name string) bool {
+	img, err := images.LoadImage(atlasTexturePath(modelData, name))
+	return err == nil && img != nil
+}
+
+// atlasTexturePath はテクスチャ名をモデルのディレクトリ基準のパスへ変換する。
+func atlasTexturePath(modelData *model.PmxModel, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(modelData.Path()), name)
+}
@@ -0,0 +1,273 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// memoryTextureImages はテスト用の画像リポジトリ。
+type memoryTextureImages struct {
+	images  map[string]image.Image
+	saveErr error
+}
+
+// LoadImage は登録済みの画像を返す。
+func (m *memoryTextureImages) LoadImage(path string) (image.Image, error) {
+	img, ok := m.images[filepath.Base(path)]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return img, nil
+}
+
+// SaveImage は画像を登録する。
+func (m *memoryTextureImages) SaveImage(path string, img image.Image) error {
+	if m.saveErr != nil {
+		return m.saveErr
+	}
+	m.images[filepath.Base(path)] = img
+	return nil
+}
+
+// filledImage は単色の画像を生成する。
+func filledImage(width, height int, c color.NRGBA) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// newAtlasTestModel は2つの同設定材質と、頂点を共有する別設定の材質を持つモデルを生成する。
+func newAtlasTestModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	m.SetPath(filepath.Join("models", "test.pmx"))
+	appendTestBone(m, "センター", -1)
+	for _, name := range []string{"red.png", "blue.png"} {
+		texture := model.NewTexture()
+		texture.SetName(name)
+		m.Textures.Append(texture)
+	}
+	// 材質ごとに1枚の四角形。頂点4は材質1と材質2で共有する。
+	quads := [][4]int{{0, 1, 2, 3}, {4, 5, 6, 7}, {4, 8, 9, 10}}
+	for i := 0; i < 11; i++ {
+		m.Vertices.Append(&model.Vertex{
+			Position: vec3(float64(i), 0, 0),
+			Uv:       mmath.Vec2{X: float64(i % 2), Y: float64((i / 2) % 2)},
+			Deform:   model.NewBdef1(0),
+		})
+	}
+	textures := []int{0, 1, 0}
+	for i, quad := range quads {
+		m.Faces.Append(&model.Face{VertexIndexes: [3]int{quad[0], quad[1], quad[2]}})
+		m.Faces.Append(&model.Face{VertexIndexes: [3]int{quad[2], quad[1], quad[3]}})
+		material := model.NewMaterial()
+		material.SetName([]string{"髪", "服", "肌"}[i])
+		material.Diffuse = mmath.Vec4{X: 1, Y: 1, Z: 1, W: 1}
+		material.TextureIndex = textures[i]
+		material.VerticesCount = 6
+		m.Materials.Append(material)
+	}
+	skin, _ := m.Materials.Get(2)
+	skin.Diffuse = mmath.Vec4{X: 1, Y: 0.8, Z: 0.8, W: 1}
+
+	uvMorph := &model.Morph{MorphType: model.MORPH_TYPE_UV}
+	uvMorph.SetName("服UV")
+	uvMorph.Offsets = []model.IMorphOffset{
+		&model.UvMorphOffset{VertexIndex: 5, Uv: mmath.Vec4{X: 0.5, Y: 0.5}, UvType: model.MORPH_TYPE_UV},
+	}
+	m.Morphs.Append(uvMorph)
+	materialMorph := &model.Morph{MorphType: model.MORPH_TYPE_MATERIAL}
+	materialMorph.SetName("服消し")
+	materialMorph.Offsets = []model.IMorphOffset{
+		&model.MaterialMorphOffset{MaterialIndex: 1},
+		&model.MaterialMorphOffset{MaterialIndex: 2},
+	}
+	m.Morphs.Append(materialMorph)
+	return m
+}
+
+// TestBuildTextureAtlas は同設定の材質がアトラスへ統合され、UVとモーフが張り替わることを確認する。
+func TestBuildTextureAtlas(t *testing.T) {
+	m := newAtlasTestModel()
+	images := &memoryTextureImages{images: map[string]image.Image{
+		"red.png":  filledImage(2, 2, color.NRGBA{R: 255, A: 255}),
+		"blue.png": filledImage(4, 2, color.NRGBA{B: 255, A: 255}),
+	}}
+
+	result, err := BuildTextureAtlas(m, images, AtlasOptions{Padding: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.DrawCallsBefore != 3 || result.DrawCallsAfter != 2 || result.DrawCallsSaved() != 1 {
+		t.Fatalf("draw calls mismatch: %+v", result)
+	}
+	if len(result.Groups) != 1 || result.Groups[0].Width != 8 || result.Groups[0].Height != 8 {
+		t.Fatalf("group mismatch: %+v", result.Groups)
+	}
+	if len(result.BroadenedMorphs) != 1 || result.BroadenedMorphs[0] != "服消し" {
+		t.Fatalf("broadened morphs mismatch: %v", result.BroadenedMorphs)
+	}
+
+	atlas, ok := images.images["atlas.png"]
+	if !ok {
+		t.Fatalf("atlas image should be saved")
+	}
+	// 余白には境界画素が複製される。
+	if r, _, _, _ := atlas.At(0, 0).RGBA(); r != 0xffff {
+		t.Fatalf("padding should bleed border color: %v", atlas.At(0, 0))
+	}
+	if _, _, b, _ := atlas.At(5, 6).RGBA(); b != 0xffff {
+		t.Fatalf("second texture should be placed below: %v", atlas.At(5, 6))
+	}
+
+	hair, _ := m.Materials.Get(0)
+	texture, _ := m.Textures.Get(hair.TextureIndex)
+	if m.Materials.Len() != 2 || hair.VerticesCount != 12 || texture.Name() != "atlas.png" {
+		t.Fatalf("merged material mismatch: %d %d %s", m.Materials.Len(), hair.VerticesCount, texture.Name())
+	}
+	v3, _ := m.Vertices.Get(3)
+	if !nearVec2(v3.Uv, mmath.Vec2{X: 3.0 / 8, Y: 3.0 / 8}, 1e-9) {
+		t.Fatalf("uv of first texture mismatch: %v", v3.Uv)
+	}
+	v5, _ := m.Vertices.Get(5)
+	if !nearVec2(v5.Uv, mmath.Vec2{X: 5.0 / 8, Y: 5.0 / 8}, 1e-9) {
+		t.Fatalf("uv of second texture mismatch: %v", v5.Uv)
+	}
+
+	// 統合しない材質と共有する頂点は複製され、元の頂点のUVは変わらない。
+	shared, _ := m.Vertices.Get(4)
+	if m.Vertices.Len() != 12 || !nearVec2(shared.Uv, mmath.Vec2{X: 0, Y: 0}, 1e-9) {
+		t.Fatalf("shared vertex should be kept: %d %v", m.Vertices.Len(), shared.Uv)
+	}
+	clothFace, _ := m.Faces.Get(2)
+	if clothFace.VertexIndexes[0] != 11 {
+		t.Fatalf("cloth face should use copied vertex: %v", clothFace.VertexIndexes)
+	}
+	skinFace, _ := m.Faces.Get(4)
+	if skinFace.VertexIndexes[0] != 4 {
+		t.Fatalf("skin faces should follow merged faces: %v", skinFace.VertexIndexes)
+	}
+
+	uvMorph, _ := m.Morphs.GetByName("服UV")
+	uvOffset := uvMorph.Offsets[0].(*model.UvMorphOffset)
+	if uvOffset.Uv != (mmath.Vec4{X: 0.25, Y: 0.125}) {
+		t.Fatalf("uv morph offset should be scaled: %v", uvOffset.Uv)
+	}
+	materialMorph, _ := m.Morphs.GetByName("服消し")
+	if len(materialMorph.Offsets) != 2 ||
+		materialMorph.Offsets[0].(*model.MaterialMorphOffset).MaterialIndex != 0 ||
+		materialMorph.Offsets[1].(*model.MaterialMorphOffset).MaterialIndex != 1 {
+		t.Fatalf("material morph should be remapped: %+v %+v", materialMorph.Offsets[0], materialMorph.Offsets[1])
+	}
+}
+
+// TestBuildTextureAtlasSaveFailed は画像保存に失敗した場合にモデルを変更しないことを確認する。
+func TestBuildTextureAtlasSaveFailed(t *testing.T) {
+	m := newAtlasTestModel()
+	images := &memoryTextureImages{
+		images: map[string]image.Image{
+			"red.png":  filledImage(2, 2, color.NRGBA{R: 255, A: 255}),
+			"blue.png": filledImage(4, 2, color.NRGBA{B: 255, A: 255}),
+		},
+		saveErr: errors.New("disk full"),
+	}
+	_, err := BuildTextureAtlas(m, images, AtlasOptions{})
+	if merr.ExtractErrorID(err) != textureAtlasSaveFailedErrorID {
+		t.Fatalf("expected save failed error, got %v", err)
+	}
+	if m.Materials.Len() != 3 || m.Vertices.Len() != 11 {
+		t.Fatalf("model should not be changed: %d %d", m.Materials.Len(), m.Vertices.Len())
+	}
+
+	// 読み込めないテクスチャの材質は統合しない。
+	images = &memoryTextureImages{images: map[string]image.Image{}}
+	result, err := BuildTextureAtlas(m, images, AtlasOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.DrawCallsSaved() != 0 || len(result.SkippedMaterials) != 2 {
+		t.Fatalf("unloadable textures should be skipped: %+v", result)
+	}
+	if _, err := BuildTextureAtlas(m, nil, AtlasOptions{}); merr.ExtractErrorID(err) != textureImageRepositoryNotConfiguredErrorID {
+		t.Fatalf("expected repository error, got %v", err)
+	}
+}
+
+// TestBuildTextureAtlasUniqueName は既存のテクスチャ名や画像ファイルと重ならない名前で保存することを確認する。
+func TestBuildTextureAtlasUniqueName(t *testing.T) {
+	m := newAtlasTestModel()
+	existing := model.NewTexture()
+	existing.SetName("atlas.png")
+	m.Textures.Append(existing)
+	images := &memoryTextureImages{images: map[string]image.Image{
+		"red.png":     filledImage(2, 2, color.NRGBA{R: 255, A: 255}),
+		"blue.png":    filledImage(4, 2, color.NRGBA{B: 255, A: 255}),
+		"atlas.png":   filledImage(1, 1, color.NRGBA{G: 255, A: 255}),
+		"atlas_2.png": filledImage(1, 1, color.NRGBA{G: 255, A: 255}),
+	}}
+
+	result, err := BuildTextureAtlas(m, images, AtlasOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Groups) != 1 || result.Groups[0].TextureName != "atlas_3.png" {
+		t.Fatalf("atlas name should be unique: %+v", result.Groups)
+	}
+	hair, _ := m.Materials.Get(0)
+	if hair.TextureIndex == existing.Index() || m.Textures.Len() != 4 {
+		t.Fatalf("existing texture should not be reused: %d %d", hair.TextureIndex, m.Textures.Len())
+	}
+	if _, _, b, _ := images.images["atlas_2.png"].At(0, 0).RGBA(); b != 0 {
+		t.Fatalf("existing image should not be overwritten")
+	}
+}
+
+// TestBuildTextureAtlasKeepsAlphaDrawOrder は間に別の材質を挟む半透明材質を統合しないことを確認する。
+func TestBuildTextureAtlasKeepsAlphaDrawOrder(t *testing.T) {
+	newModel := func(alpha float64) *model.PmxModel {
+		m := newAtlasTestModel()
+		// 髪と肌を同設定にし、間に別設定の服を挟む。
+		for i, diffuse := range []mmath.Vec4{{X: 1, Y: 1, Z: 1, W: alpha}, {X: 1, Y: 0.8, Z: 0.8, W: 1}, {X: 1, Y: 1, Z: 1, W: alpha}} {
+			material, _ := m.Materials.Get(i)
+			material.Diffuse = diffuse
+		}
+		return m
+	}
+	newImages := func() *memoryTextureImages {
+		return &memoryTextureImages{images: map[string]image.Image{
+			"red.png":  filledImage(2, 2, color.NRGBA{R: 255, A: 255}),
+			"blue.png": filledImage(4, 2, color.NRGBA{B: 255, A: 255}),
+		}}
+	}
+
+	m := newModel(0.5)
+	result, err := BuildTextureAtlas(m, newImages(), AtlasOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.DrawCallsSaved() != 0 || m.Materials.Len() != 3 ||
+		len(result.SkippedMaterials) != 1 || result.SkippedMaterials[0] != "肌" {
+		t.Fatalf("alpha material across another material should be skipped: %+v", result)
+	}
+
+	// 不透明な材質は描画順が変わっても見た目が変わらないため統合する。
+	m = newModel(1)
+	result, err = BuildTextureAtlas(m, newImages(), AtlasOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.DrawCallsSaved() != 1 || len(result.SkippedMaterials) != 0 {
+		t.Fatalf("opaque materials should be merged: %+v", result)
+	}
+}
@@ -0,0 +1,110 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+// ConvertModelCoordinate はモデル全体の座標系と長さ単位を from から to へ変換する。
+// 鏡像変換では面の頂点順を反転して表裏を保つ。
+func ConvertModelCoordinate(modelData *model.PmxModel, from, to mmath.CoordinateSystem) error {
+	if modelData == nil {
+		return merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	converter, err := mmath.NewCoordinateConverter(from, to)
+	if err != nil {
+		return err
+	}
+
+	for _, vertex := range modelData.Vertices.Values() {
+		if vertex == nil {
+			continue
+		}
+		vertex.Position = converter.Position(vertex.Position)
+		vertex.Normal = converter.Direction(vertex.Normal)
+		if sdef, ok := vertex.Deform.(*model.Sdef); ok {
+			sdef.SdefC = converter.Position(sdef.SdefC)
+			sdef.SdefR0 = converter.Position(sdef.SdefR0)
+			sdef.SdefR1 = converter.Position(sdef.SdefR1)
+		}
+	}
+	if converter.IsMirrored() {
+		for _, face := range modelData.Faces.Values() {
+			if face == nil {
+				continue
+			}
+			face.VertexIndexes[1], face.VertexIndexes[2] = face.VertexIndexes[2], face.VertexIndexes[1]
+		}
+	}
+	for _, bone := range modelData.Bones.Values() {
+		if bone == nil {
+			continue
+		}
+		convertBoneCoordinate(bone, converter)
+	}
+	for _, morph := range modelData.Morphs.Values() {
+		if morph == nil {
+			continue
+		}
+		convertMorphCoordinate(morph, converter)
+	}
+	for _, rigidBody := range modelData.RigidBodies.Values() {
+		if rigidBody == nil {
+			continue
+		}
+		rigidBody.Position = converter.Position(rigidBody.Position)
+		rotation := converter.Orientation(rigidBody.Rotation.RadToQuaternion())
+		rigidBody.Rotation = rotation.ToRadians()
+		rigidBody.Size = rigidBody.Size.MuledScalar(converter.Scale())
+	}
+	for _, joint := range modelData.Joints.Values() {
+		if joint == nil {
+			continue
+		}
+		convertJointCoordinate(joint, converter)
+	}
+	modelData.UpdateHash()
+	return nil
+}
+
+// convertBoneCoordinate はボーンの位置・軸・IK制限を変換する。
+func convertBoneCoordinate(bone *model.Bone, converter mmath.CoordinateConverter) {
+	bone.Position = converter.Position(bone.Position)
+	bone.TailPosition = converter.Position(bone.TailPosition)
+	bone.FixedAxis = converter.Direction(bone.FixedAxis)
+	bone.LocalAxisX = converter.Direction(bone.LocalAxisX)
+	bone.LocalAxisZ = converter.Direction(bone.LocalAxisZ)
+	if bone.Ik == nil {
+		return
+	}
+	for i := range bone.Ik.Links {
+		link := &bone.Ik.Links[i]
+		link.MinAngleLimit, link.MaxAngleLimit = converter.AngleLimits(link.MinAngleLimit, link.MaxAngleLimit)
+		link.LocalMinAngleLimit, link.LocalMaxAngleLimit = converter.AngleLimits(link.LocalMinAngleLimit, link.LocalMaxAngleLimit)
+	}
+}
+
+// convertMorphCoordinate は頂点・ボーンモーフのオフセットを変換する。
+func convertMorphCoordinate(morph *model.Morph, converter mmath.CoordinateConverter) {
+	for _, offset := range morph.Offsets {
+		switch o := offset.(type) {
+		case *model.VertexMorphOffset:
+			o.Position = converter.Position(o.Position)
+		case *model.BoneMorphOffset:
+			o.Position = converter.Position(o.Position)
+			o.Rotation = converter.Quaternion(o.Rotation)
+		}
+	}
+}
+
+// convertJointCoordinate はジョイントの姿勢と制限を変換する。
+func convertJointCoordinate(joint *model.Joint, converter mmath.CoordinateConverter) {
+	param := &joint.Param
+	param.Position = converter.Position(param.Position)
+	param.Rotation = converter.Orientation(param.Rotation.RadToQuaternion()).ToRadians()
+	param.TranslationLimitMin, param.TranslationLimitMax = converter.OrientationLocalRanges(param.TranslationLimitMin, param.TranslationLimitMax)
+	param.RotationLimitMin, param.RotationLimitMax = converter.OrientationLocalAngleLimits(param.RotationLimitMin, param.RotationLimitMax)
+}
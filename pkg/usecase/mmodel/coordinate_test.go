@@ -0,0 +1,76 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// TestConvertModelCoordinateGltf はglTF座標系への変換で位置・面・剛体が整合することを確認する。
+func TestConvertModelCoordinateGltf(t *testing.T) {
+	m := newFoldedMeshModel()
+	rigidBody := &model.RigidBody{Position: vec3(0, 10, 5), Rotation: vec3(0.3, 0.2, 0.1), Size: vec3(1, 2, 0)}
+	m.RigidBodies.Append(rigidBody)
+	joint := &model.Joint{Param: model.JointParam{
+		Position:         vec3(0, 10, 5),
+		RotationLimitMin: vec3(-0.1, -0.2, -0.3),
+		RotationLimitMax: vec3(0.4, 0.5, 0.6),
+	}}
+	m.Joints.Append(joint)
+	capsuleAxis := rigidBody.Rotation.RadToQuaternion().MulVec3(mmath.UNIT_Y_VEC3)
+
+	if err := ConvertModelCoordinate(m, mmath.COORDINATE_SYSTEM_MMD, mmath.COORDINATE_SYSTEM_GLTF); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	vertex, _ := m.Vertices.Get(7)
+	if !vertex.Position.NearEquals(vec3(0.08, 0.08, -0.08), 1e-9) {
+		t.Fatalf("vertex position mismatch: %v", vertex.Position)
+	}
+	face, _ := m.Faces.Get(0)
+	if face.VertexIndexes != [3]int{0, 2, 1} {
+		t.Fatalf("face winding should be flipped: %v", face.VertexIndexes)
+	}
+	morph, _ := m.Morphs.Get(0)
+	if offset := morph.Offsets[0].(*model.VertexMorphOffset); !offset.Position.NearEquals(vec3(0, 0.08, 0), 1e-9) {
+		t.Fatalf("morph offset mismatch: %v", offset.Position)
+	}
+	if !rigidBody.Position.NearEquals(vec3(0, 0.8, -0.4), 1e-9) || !rigidBody.Size.NearEquals(vec3(0.08, 0.16, 0), 1e-9) {
+		t.Fatalf("rigid body mismatch: %v %v", rigidBody.Position, rigidBody.Size)
+	}
+	expectedAxis := vec3(capsuleAxis.X, capsuleAxis.Y, -capsuleAxis.Z)
+	if axis := rigidBody.Rotation.RadToQuaternion().MulVec3(mmath.UNIT_Y_VEC3); !axis.NearEquals(expectedAxis, 1e-6) {
+		t.Fatalf("rigid body axis mismatch: %v %v", axis, expectedAxis)
+	}
+	if !joint.Param.RotationLimitMin.NearEquals(vec3(-0.4, -0.5, -0.3), 1e-9) {
+		t.Fatalf("joint limit mismatch: %v", joint.Param.RotationLimitMin)
+	}
+}
+
+// TestConvertModelCoordinateRoundTrip は往復変換で元の値に戻ることを確認する。
+func TestConvertModelCoordinateRoundTrip(t *testing.T) {
+	m := newFoldedMeshModel()
+	bone, _ := m.Bones.Get(1)
+	bone.Position = vec3(1, 2, 3)
+	bone.LocalAxisX = vec3(1, 0, 0)
+	if err := ConvertModelCoordinate(m, mmath.COORDINATE_SYSTEM_MMD, mmath.COORDINATE_SYSTEM_BLENDER); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ConvertModelCoordinate(m, mmath.COORDINATE_SYSTEM_BLENDER, mmath.COORDINATE_SYSTEM_MMD); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bone.Position.NearEquals(vec3(1, 2, 3), 1e-9) || !bone.LocalAxisX.NearEquals(vec3(1, 0, 0), 1e-9) {
+		t.Fatalf("round trip mismatch: %v %v", bone.Position, bone.LocalAxisX)
+	}
+	face, _ := m.Faces.Get(0)
+	if face.VertexIndexes != [3]int{0, 1, 2} {
+		t.Fatalf("face winding mismatch: %v", face.VertexIndexes)
+	}
+
+	err := ConvertModelCoordinate(nil, mmath.COORDINATE_SYSTEM_MMD, mmath.COORDINATE_SYSTEM_GLTF)
+	if merr.ExtractErrorID(err) != modelNotSpecifiedErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
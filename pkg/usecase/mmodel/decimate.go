@@ -0,0 +1,799 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/collection"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+const (
+	decimateRatioInvalidErrorID = "13520"
+	// defaultDecimateRatio は既定で残す面数の割合。
+	defaultDecimateRatio = 0.5
+	// defaultDecimateNormalAngle は既定の法線制限角度(60度)。
+	defaultDecimateNormalAngle = math.Pi / 3
+	// decimateBorderWeight は開いた辺を保つ拘束平面の重み。
+	decimateBorderWeight = 1000.0
+)
+
+// DecimateOptions はメッシュ簡略化の設定を表す。
+type DecimateOptions struct {
+	// TargetRatio は各材質で残す面数の割合(0より大きく1以下)。0 の場合は0.5。
+	TargetRatio float64
+	// MaterialRatios は材質 index ごとの面数割合。指定した材質は TargetRatio より優先する。
+	MaterialRatios map[int]float64
+	// NormalAngle は折り畳みで許容する面法線の変化と、結合する頂点法線の差の上限(ラジアン)。
+	// 0 の場合は60度、負の場合は面の反転のみ禁止する。
+	NormalAngle float64
+	// Tolerance はUV・法線の継ぎ目(同一位置の別頂点)とみなす距離。0 の場合は1e-5。
+	Tolerance float64
+}
+
+// MaterialDecimation は材質ごとの簡略化結果を表す。
+type MaterialDecimation struct {
+	MaterialIndex int
+	FacesBefore   int
+	FacesAfter    int
+}
+
+// DecimateResult はメッシュ簡略化の結果を表す。
+type DecimateResult struct {
+	// Model は簡略化した複製モデル。
+	Model          *model.PmxModel
+	VerticesBefore int
+	VerticesAfter  int
+	Materials      []MaterialDecimation
+}
+
+// DecimateMesh はモデルを複製し、二次誤差(QEM)による辺の折り畳みで材質ごとに面数を削減する。
+// 材質境界と継ぎ目(UV・法線で分割された同一位置の頂点)の頂点は動かさず、
+// 折り畳んだ頂点のデフォームと頂点/UVモーフのオフセットは折り畳み位置に応じて混合する。
+func DecimateMesh(modelData *model.PmxModel, opts DecimateOptions) (*DecimateResult, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	ratios, err := resolveDecimateRatios(modelData, opts)
+	if err != nil {
+		return nil, err
+	}
+	copied, err := modelData.Copy()
+	if err != nil {
+		return nil, err
+	}
+	d := newMeshDecimator(&copied, opts)
+	before := append([]int(nil), d.materialFaces...)
+	d.run(ratios)
+	d.apply()
+
+	result := &DecimateResult{
+		Model:          d.model,
+		VerticesBefore: modelData.Vertices.Len(),
+		VerticesAfter:  d.model.Vertices.Len(),
+		Materials:      make([]MaterialDecimation, 0, len(before)),
+	}
+	for i, count := range before {
+		result.Materials = append(result.Materials, MaterialDecimation{
+			MaterialIndex: i,
+			FacesBefore:   count,
+			FacesAfter:    d.materialFaces[i],
+		})
+	}
+	return result, nil
+}
+
+// GenerateLods は割合ごとに簡略化したモデルを生成する。MaterialRatios は全ての割合で共通に使う。
+func GenerateLods(modelData *model.PmxModel, ratios []float64, opts DecimateOptions) ([]*DecimateResult, error) {
+	results := make([]*DecimateResult, 0, len(ratios))
+	for _, ratio := range ratios {
+		lodOpts := opts
+		lodOpts.TargetRatio = ratio
+		result, err := DecimateMesh(modelData, lodOpts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// resolveDecimateRatios は材質ごとの面数割合を検証して返す。
+func resolveDecimateRatios(modelData *model.PmxModel, opts DecimateOptions) ([]float64, error) {
+	ratio := opts.TargetRatio
+	if ratio == 0 {
+		ratio = defaultDecimateRatio
+	}
+	if !validDecimateRatio(ratio) {
+		return nil, newDecimateRatioInvalid("全体", ratio)
+	}
+	ratios := make([]float64, modelData.Materials.Len())
+	for i := range ratios {
+		ratios[i] = ratio
+	}
+	materialIndexes := make([]int, 0, len(opts.MaterialRatios))
+	for materialIndex := range opts.MaterialRatios {
+		materialIndexes = append(materialIndexes, materialIndex)
+	}
+	sort.Ints(materialIndexes)
+	for _, materialIndex := range materialIndexes {
+		materialRatio := opts.MaterialRatios[materialIndex]
+		if materialIndex < 0 || materialIndex >= len(ratios) || !validDecimateRatio(materialRatio) {
+			return nil, newDecimateRatioInvalid(fmt.Sprintf("材質%d", materialIndex), materialRatio)
+		}
+		ratios[materialIndex] = materialRatio
+	}
+	return ratios, nil
+}
+
+// validDecimateRatio は割合が0より大きく1以下か判定する。
+func validDecimateRatio(ratio float64) bool {
+	return ratio > 0 && ratio <= 1
+}
+
+// newDecimateRatioInvalid は割合不正エラーを生成する。
+func newDecimateRatioInvalid(label string, ratio float64) error {
+	return merr.NewCommonError(
+		decimateRatioInvalidErrorID, merr.ErrorKindValidate, messages.DecimateRatioInvalid, nil, label, ratio)
+}
+
+// decimateQuadric は平面二次誤差の対称4x4行列(上三角10要素)を表す。
+type decimateQuadric [10]float64
+
+// addPlane は平面 ax+by+cz+d=0 を重み付きで加算する。
+func (q *decimateQuadric) addPlane(a, b, c, d, weight float64) {
+	q[0] += weight * a * a
+	q[1] += weight * a * b
+	q[2] += weight * a * c
+	q[3] += weight * a * d
+	q[4] += weight * b * b
+	q[5] += weight * b * c
+	q[6] += weight * b * d
+	q[7] += weight * c * c
+	q[8] += weight * c * d
+	q[9] += weight * d * d
+}
+
+// added は二次誤差の和を返す。
+func (q decimateQuadric) added(other decimateQuadric) decimateQuadric {
+	for i := range q {
+		q[i] += other[i]
+	}
+	return q
+}
+
+// eval は位置 p での誤差を返す。
+func (q decimateQuadric) eval(p mmath.Vec3) float64 {
+	x, y, z := p.X, p.Y, p.Z
+	return q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x +
+		q[4]*y*y + 2*q[5]*y*z + 2*q[6]*y +
+		q[7]*z*z + 2*q[8]*z + q[9]
+}
+
+// optimal は誤差を最小にする位置を返す。行列が特異な場合は false。
+func (q decimateQuadric) optimal() (mmath.Vec3, bool) {
+	a, b, c := q[0], q[1], q[2]
+	e, f, h := q[4], q[5], q[7]
+	det := a*(e*h-f*f) - b*(b*h-f*c) + c*(b*f-e*c)
+	if math.Abs(det) < 1e-12 {
+		return mmath.Vec3{}, false
+	}
+	rx, ry, rz := -q[3], -q[6], -q[8]
+	x := (rx*(e*h-f*f) - b*(ry*h-f*rz) + c*(ry*f-e*rz)) / det
+	y := (a*(ry*h-f*rz) - rx*(b*h-f*c) + c*(b*rz-ry*c)) / det
+	z := (a*(e*rz-ry*f) - b*(b*rz-ry*c) + rx*(b*f-e*c)) / det
+	return newDecimateVec3(x, y, z), true
+}
+
+// decimateCandidate は辺の折り畳み候補を表す。
+// remove を keep へ折り畳み、keep を position へ移動する。t は属性混合の remove 側の比率。
+type decimateCandidate struct {
+	cost          float64
+	keep          int
+	remove        int
+	position      mmath.Vec3
+	t             float64
+	keepVersion   int
+	removeVersion int
+}
+
+// decimateHeap は誤差の小さい順に候補を取り出すヒープを表す。
+type decimateHeap []decimateCandidate
+
+func (h decimateHeap) Len() int { return len(h) }
+
+func (h decimateHeap) Less(i, j int) bool {
+	if h[i].cost != h[j].cost {
+		return h[i].cost < h[j].cost
+	}
+	if h[i].keep != h[j].keep {
+		return h[i].keep < h[j].keep
+	}
+	return h[i].remove < h[j].remove
+}
+
+func (h decimateHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *decimateHeap) Push(x any) { *h = append(*h, x.(decimateCandidate)) }
+
+func (h *decimateHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// meshDecimator はメッシュ簡略化の状態を保持する。
+type meshDecimator struct {
+	model           *model.PmxModel
+	vertices        []*model.Vertex
+	faces           [][3]int
+	faceAlive       []bool
+	faceMaterial    []int
+	vertexFaces     [][]int
+	quadrics        []decimateQuadric
+	locked          []bool
+	removed         []bool
+	versions        []int
+	morphOffsets    []map[int]mmath.Vec4
+	vertexMorphs    [][]int
+	materialFaces   []int
+	materialTargets []int
+	cosNormal       float64
+	queue           decimateHeap
+}
+
+// newMeshDecimator はモデルから簡略化の状態を構築する。
+func newMeshDecimator(modelData *model.PmxModel, opts DecimateOptions) *meshDecimator {
+	d := &meshDecimator{
+		model:         modelData,
+		vertices:      modelData.Vertices.Values(),
+		faceMaterial:  buildFaceMaterials(modelData),
+		vertexFaces:   buildVertexFaces(modelData),
+		materialFaces: make([]int, modelData.Materials.Len()),
+	}
+	normalAngle := opts.NormalAngle
+	if normalAngle == 0 {
+		normalAngle = defaultDecimateNormalAngle
+	}
+	d.cosNormal = 0
+	if normalAngle > 0 {
+		d.cosNormal = math.Cos(normalAngle)
+	}
+
+	count := len(d.vertices)
+	d.quadrics = make([]decimateQuadric, count)
+	d.locked = make([]bool, count)
+	d.removed = make([]bool, count)
+	d.versions = make([]int, count)
+	d.vertexMorphs = make([][]int, count)
+
+	for i, face := range modelData.Faces.Values() {
+		var indexes [3]int
+		if face != nil {
+			indexes = face.VertexIndexes
+		}
+		d.faces = append(d.faces, indexes)
+		d.faceAlive = append(d.faceAlive, face != nil)
+		materialIndex := d.faceMaterial[i]
+		if face == nil {
+			continue
+		}
+		if materialIndex >= 0 {
+			d.materialFaces[materialIndex]++
+		}
+		// 材質に属さない面と縮退面の頂点は動かさない。
+		if materialIndex < 0 || indexes[0] == indexes[1] || indexes[1] == indexes[2] || indexes[0] == indexes[2] {
+			for _, vertexIndex := range indexes {
+				if vertexIndex >= 0 && vertexIndex < count {
+					d.locked[vertexIndex] = true
+				}
+			}
+		}
+	}
+	d.lockBoundaryVertices(opts.Tolerance)
+	d.buildQuadrics()
+	d.buildMorphOffsets()
+	return d
+}
+
+// lockBoundaryVertices は材質境界と継ぎ目の頂点を固定する。
+func (d *meshDecimator) lockBoundaryVertices(tolerance float64) {
+	for vertexIndex, faces := range d.vertexFaces {
+		for _, faceIndex := range faces {
+			if d.faceMaterial[faceIndex] != d.faceMaterial[faces[0]] {
+				d.locked[vertexIndex] = true
+				break
+			}
+		}
+	}
+	groups := groupVerticesByPosition(d.model, tolerance)
+	for vertexIndex, group := range groups {
+		if len(d.vertexFaces[vertexIndex]) == 0 {
+			continue
+		}
+		for _, other := range group {
+			if other != vertexIndex && len(d.vertexFaces[other]) > 0 {
+				d.locked[vertexIndex] = true
+				break
+			}
+		}
+	}
+}
+
+// buildQuadrics は面の平面と開いた辺の拘束平面から頂点ごとの二次誤差を構築する。
+func (d *meshDecimator) buildQuadrics() {
+	type edgeKey struct{ a, b int }
+	edgeFaces := make(map[edgeKey][]int)
+	for faceIndex, face := range d.faces {
+		if !d.faceAlive[faceIndex] || d.locked[face[0]] && d.locked[face[1]] && d.locked[face[2]] && d.faceMaterial[faceIndex] < 0 {
+			continue
+		}
+		normal, ok := d.faceNormal(face, -1, mmath.Vec3{})
+		if !ok {
+			continue
+		}
+		area := normal.Length() / 2
+		unit := normal.Normalized()
+		p0 := d.vertices[face[0]].Position
+		for _, vertexIndex := range face {
+			d.quadrics[vertexIndex].addPlane(unit.X, unit.Y, unit.Z, -unit.Dot(p0), area)
+		}
+		for k := 0; k < 3; k++ {
+			a, b := face[k], face[(k+1)%3]
+			if a > b {
+				a, b = b, a
+			}
+			edgeFaces[edgeKey{a, b}] = append(edgeFaces[edgeKey{a, b}], faceIndex)
+		}
+	}
+	for key, faces := range edgeFaces {
+		if len(faces) != 1 {
+			continue
+		}
+		normal, _ := d.faceNormal(d.faces[faces[0]], -1, mmath.Vec3{})
+		pa := d.vertices[key.a].Position
+		edge := d.vertices[key.b].Position.Subed(pa)
+		borderNormal := edge.Cross(normal.Normalized())
+		if borderNormal.IsZero() {
+			continue
+		}
+		borderNormal = borderNormal.Normalized()
+		weight := decimateBorderWeight * edge.LengthSqr()
+		for _, vertexIndex := range []int{key.a, key.b} {
+			d.quadrics[vertexIndex].addPlane(borderNormal.X, borderNormal.Y, borderNormal.Z, -borderNormal.Dot(pa), weight)
+		}
+	}
+}
+
+// buildMorphOffsets は頂点/UVモーフのオフセットを頂点ごとに引ける形へ展開する。
+func (d *meshDecimator) buildMorphOffsets() {
+	morphs := d.model.Morphs.Values()
+	d.morphOffsets = make([]map[int]mmath.Vec4, len(morphs))
+	for morphIndex, morph := range morphs {
+		if morph == nil || !isVertexOffsetMorph(morph.MorphType) {
+			continue
+		}
+		offsets := make(map[int]mmath.Vec4, len(morph.Offsets))
+		for _, offset := range morph.Offsets {
+			vertexIndex := -1
+			var value mmath.Vec4
+			switch o := offset.(type) {
+			case *model.VertexMorphOffset:
+				vertexIndex = o.VertexIndex
+				value = mmath.Vec4{X: o.Position.X, Y: o.Position.Y, Z: o.Position.Z}
+			case *model.UvMorphOffset:
+				vertexIndex = o.VertexIndex
+				value = o.Uv
+			}
+			if vertexIndex < 0 || vertexIndex >= len(d.vertices) {
+				continue
+			}
+			if _, ok := offsets[vertexIndex]; !ok {
+				d.vertexMorphs[vertexIndex] = append(d.vertexMorphs[vertexIndex], morphIndex)
+			}
+			offsets[vertexIndex] = value
+		}
+		d.morphOffsets[morphIndex] = offsets
+	}
+}
+
+// run は目標面数に達するまで誤差の小さい辺から折り畳む。
+func (d *meshDecimator) run(ratios []float64) {
+	d.materialTargets = make([]int, len(d.materialFaces))
+	for i, count := range d.materialFaces {
+		d.materialTargets[i] = int(math.Ceil(float64(count) * ratios[i]))
+	}
+	seen := make(map[[2]int]struct{})
+	for faceIndex, face := range d.faces {
+		if !d.faceAlive[faceIndex] {
+			continue
+		}
+		for k := 0; k < 3; k++ {
+			a, b := face[k], face[(k+1)%3]
+			if a > b {
+				a, b = b, a
+			}
+			if _, ok := seen[[2]int{a, b}]; ok {
+				continue
+			}
+			seen[[2]int{a, b}] = struct{}{}
+			d.pushCandidate(a, b)
+		}
+	}
+
+	for d.queue.Len() > 0 {
+		candidate := heap.Pop(&d.queue).(decimateCandidate)
+		if d.removed[candidate.keep] || d.removed[candidate.remove] ||
+			d.versions[candidate.keep] != candidate.keepVersion || d.versions[candidate.remove] != candidate.removeVersion {
+			continue
+		}
+		materialIndex := d.vertexMaterial(candidate.remove)
+		if materialIndex < 0 || d.materialFaces[materialIndex] <= d.materialTargets[materialIndex] {
+			continue
+		}
+		if !d.canCollapse(candidate) {
+			continue
+		}
+		d.collapse(candidate)
+	}
+}
+
+// pushCandidate は辺の折り畳み候補を評価してヒープへ追加する。
+func (d *meshDecimator) pushCandidate(a, b int) {
+	if candidate, ok := d.evaluate(a, b); ok {
+		heap.Push(&d.queue, candidate)
+	}
+}
+
+// evaluate は辺の折り畳み先と誤差を求める。固定頂点は移動させない。
+func (d *meshDecimator) evaluate(a, b int) (decimateCandidate, bool) {
+	if a == b || d.removed[a] || d.removed[b] || (d.locked[a] && d.locked[b]) {
+		return decimateCandidate{}, false
+	}
+	keep, remove := a, b
+	if d.locked[b] {
+		keep, remove = b, a
+	}
+	keepVertex, removeVertex := d.vertices[keep], d.vertices[remove]
+	if d.cosNormal > 0 && !keepVertex.Normal.IsZero() && !removeVertex.Normal.IsZero() &&
+		keepVertex.Normal.Normalized().Dot(removeVertex.Normal.Normalized()) < d.cosNormal {
+		return decimateCandidate{}, false
+	}
+
+	quadric := d.quadrics[keep].added(d.quadrics[remove])
+	p0, p1 := keepVertex.Position, removeVertex.Position
+	best := decimateCandidate{cost: quadric.eval(p0), position: p0, t: 0}
+	if !d.locked[keep] {
+		edge := p1.Subed(p0)
+		candidates := []float64{1, 0.5}
+		if optimal, ok := quadric.optimal(); ok && optimal.Distance(p0.Lerp(p1, 0.5)) <= edge.Length() {
+			if edge.LengthSqr() > 0 {
+				t := math.Max(0, math.Min(1, optimal.Subed(p0).Dot(edge)/edge.LengthSqr()))
+				if cost := quadric.eval(optimal); cost < best.cost {
+					best = decimateCandidate{cost: cost, position: optimal, t: t}
+				}
+			}
+		}
+		for _, t := range candidates {
+			position := p0.Lerp(p1, t)
+			if cost := quadric.eval(position); cost < best.cost {
+				best = decimateCandidate{cost: cost, position: position, t: t}
+			}
+		}
+	}
+	best.cost = math.Max(best.cost, 0)
+	best.keep = keep
+	best.remove = remove
+	best.keepVersion = d.versions[keep]
+	best.removeVersion = d.versions[remove]
+	return best, true
+}
+
+// vertexMaterial は頂点が属する材質 index を返す。
+func (d *meshDecimator) vertexMaterial(vertexIndex int) int {
+	for _, faceIndex := range d.vertexFaces[vertexIndex] {
+		if d.faceAlive[faceIndex] {
+			return d.faceMaterial[faceIndex]
+		}
+	}
+	return -1
+}
+
+// canCollapse は折り畳みで非多様体化や面の反転・大きな折れが起きないか判定する。
+func (d *meshDecimator) canCollapse(c decimateCandidate) bool {
+	// リンク条件: 両端の共通隣接頂点は辺を共有する面の対頂点だけでなければならない。
+	shared := 0
+	keepNeighbors := make(map[int]struct{})
+	for _, faceIndex := range d.vertexFaces[c.keep] {
+		if !d.faceAlive[faceIndex] {
+			continue
+		}
+		face := d.faces[faceIndex]
+		if containsVertex(face, c.remove) {
+			shared++
+		}
+		for _, vertexIndex := range face {
+			keepNeighbors[vertexIndex] = struct{}{}
+		}
+	}
+	if shared == 0 {
+		return false
+	}
+	common := make(map[int]struct{})
+	for _, faceIndex := range d.vertexFaces[c.remove] {
+		if !d.faceAlive[faceIndex] {
+			continue
+		}
+		for _, vertexIndex := range d.faces[faceIndex] {
+			if vertexIndex == c.keep || vertexIndex == c.remove {
+				continue
+			}
+			if _, ok := keepNeighbors[vertexIndex]; ok {
+				common[vertexIndex] = struct{}{}
+			}
+		}
+	}
+	if len(common) != shared {
+		return false
+	}
+
+	for _, vertexIndex := range []int{c.keep, c.remove} {
+		for _, faceIndex := range d.vertexFaces[vertexIndex] {
+			face := d.faces[faceIndex]
+			if !d.faceAlive[faceIndex] || (containsVertex(face, c.keep) && containsVertex(face, c.remove)) {
+				continue
+			}
+			before, ok := d.faceNormal(face, -1, mmath.Vec3{})
+			if !ok {
+				continue
+			}
+			after, ok := d.faceNormal(face, vertexIndex, c.position)
+			if !ok {
+				return false
+			}
+			if before.Normalized().Dot(after.Normalized()) < d.cosNormal {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// collapse は辺を折り畳み、頂点属性とモーフオフセットを混合する。
+func (d *meshDecimator) collapse(c decimateCandidate) {
+	faces := make([]int, 0, len(d.vertexFaces[c.keep])+len(d.vertexFaces[c.remove]))
+	for _, faceIndex := range d.vertexFaces[c.keep] {
+		if d.faceAlive[faceIndex] && !containsVertex(d.faces[faceIndex], c.remove) {
+			faces = append(faces, faceIndex)
+		}
+	}
+	for _, faceIndex := range d.vertexFaces[c.remove] {
+		if !d.faceAlive[faceIndex] {
+			continue
+		}
+		face := &d.faces[faceIndex]
+		if containsVertex(*face, c.keep) {
+			d.faceAlive[faceIndex] = false
+			d.materialFaces[d.faceMaterial[faceIndex]]--
+			continue
+		}
+		for k := range face {
+			if face[k] == c.remove {
+				face[k] = c.keep
+			}
+		}
+		faces = append(faces, faceIndex)
+	}
+	d.vertexFaces[c.keep] = faces
+	d.vertexFaces[c.remove] = nil
+	d.quadrics[c.keep] = d.quadrics[c.keep].added(d.quadrics[c.remove])
+	blendDecimatedVertex(d.vertices[c.keep], d.vertices[c.remove], c.position, c.t)
+	d.blendMorphOffsets(c.keep, c.remove, c.t)
+	d.removed[c.remove] = true
+	d.versions[c.keep]++
+	d.versions[c.remove]++
+
+	neighbors := make(map[int]struct{})
+	for _, faceIndex := range faces {
+		for _, vertexIndex := range d.faces[faceIndex] {
+			if vertexIndex != c.keep {
+				neighbors[vertexIndex] = struct{}{}
+			}
+		}
+	}
+	sortedNeighbors := make([]int, 0, len(neighbors))
+	for vertexIndex := range neighbors {
+		sortedNeighbors = append(sortedNeighbors, vertexIndex)
+	}
+	sort.Ints(sortedNeighbors)
+	for _, vertexIndex := range sortedNeighbors {
+		// 隣接頂点側の候補も古くなるため版を進めて再評価する。
+		d.versions[vertexIndex]++
+		d.pushCandidate(c.keep, vertexIndex)
+	}
+}
+
+// blendMorphOffsets は折り畳んだ頂点の頂点/UVモーフオフセットを keep へ混合する。
+func (d *meshDecimator) blendMorphOffsets(keep, remove int, t float64) {
+	morphIndexes := append([]int(nil), d.vertexMorphs[keep]...)
+	for _, morphIndex := range d.vertexMorphs[remove] {
+		if !containsInt(morphIndexes, morphIndex) {
+			morphIndexes = append(morphIndexes, morphIndex)
+		}
+	}
+	for _, morphIndex := range morphIndexes {
+		offsets := d.morphOffsets[morphIndex]
+		keepValue, hasKeep := offsets[keep]
+		removeValue := offsets[remove]
+		delete(offsets, remove)
+		value := keepValue.MuledScalar(1 - t).Added(removeValue.MuledScalar(t))
+		if !hasKeep && value == (mmath.Vec4{}) {
+			continue
+		}
+		offsets[keep] = value
+	}
+	d.vertexMorphs[keep] = morphIndexes
+	d.vertexMorphs[remove] = nil
+}
+
+// apply は簡略化結果をモデルの面・材質・モーフへ書き戻し、折り畳んだ頂点を削除する。
+func (d *meshDecimator) apply() {
+	rebuilt := collection.NewIndexedCollection[*model.Face](len(d.faces))
+	for _, material := range d.model.Materials.Values() {
+		if material == nil {
+			continue
+		}
+		count := 0
+		for faceIndex, face := range d.faces {
+			if d.faceAlive[faceIndex] && d.faceMaterial[faceIndex] == material.Index() {
+				rebuilt.AppendRaw(&model.Face{VertexIndexes: face})
+				count++
+			}
+		}
+		material.VerticesCount = count * 3
+	}
+	for faceIndex, face := range d.faces {
+		if d.faceAlive[faceIndex] && d.faceMaterial[faceIndex] < 0 {
+			rebuilt.AppendRaw(&model.Face{VertexIndexes: face})
+		}
+	}
+	d.model.Faces = rebuilt
+
+	for morphIndex, morph := range d.model.Morphs.Values() {
+		offsets := d.morphOffsets[morphIndex]
+		if morph == nil || offsets == nil {
+			continue
+		}
+		vertexIndexes := make([]int, 0, len(offsets))
+		for vertexIndex := range offsets {
+			vertexIndexes = append(vertexIndexes, vertexIndex)
+		}
+		sort.Ints(vertexIndexes)
+		morph.Offsets = make([]model.IMorphOffset, 0, len(vertexIndexes))
+		for _, vertexIndex := range vertexIndexes {
+			value := offsets[vertexIndex]
+			if morph.MorphType == model.MORPH_TYPE_VERTEX || morph.MorphType == model.MORPH_TYPE_AFTER_VERTEX {
+				morph.Offsets = append(morph.Offsets, &model.VertexMorphOffset{VertexIndex: vertexIndex, Position: value.XYZ()})
+			} else {
+				morph.Offsets = append(morph.Offsets, &model.UvMorphOffset{VertexIndex: vertexIndex, Uv: value, UvType: morph.MorphType})
+			}
+		}
+	}
+	d.removeCollapsedVertices()
+	d.model.UpdateHash()
+}
+
+// removeCollapsedVertices は折り畳みで消えた頂点のみを削除する。元から面に使われていない頂点は残す。
+func (d *meshDecimator) removeCollapsedVertices() {
+	oldToNew := make([]int, len(d.removed))
+	survivor := make([]int, len(d.removed))
+	next := 0
+	for i, removed := range d.removed {
+		if removed {
+			oldToNew[i] = -1
+			survivor[i] = -1
+			continue
+		}
+		oldToNew[i] = next
+		survivor[i] = i
+		next++
+	}
+	if next < len(d.removed) {
+		rebuildVertices(d.model, oldToNew, survivor)
+	}
+}
+
+// faceNormal は面の法線(面積の2倍の長さ)を返す。replaced の頂点は position に置き換えて計算する。
+func (d *meshDecimator) faceNormal(face [3]int, replaced int, position mmath.Vec3) (mmath.Vec3, bool) {
+	var points [3]mmath.Vec3
+	for k, vertexIndex := range face {
+		if vertexIndex < 0 || vertexIndex >= len(d.vertices) || d.vertices[vertexIndex] == nil {
+			return mmath.Vec3{}, false
+		}
+		points[k] = d.vertices[vertexIndex].Position
+		if vertexIndex == replaced {
+			points[k] = position
+		}
+	}
+	normal := points[1].Subed(points[0]).Cross(points[2].Subed(points[0]))
+	if normal.LengthSqr() < 1e-24 {
+		return mmath.Vec3{}, false
+	}
+	return normal, true
+}
+
+// blendDecimatedVertex は折り畳んだ頂点の属性を keep へ混合する。t は other 側の比率。
+func blendDecimatedVertex(keep, other *model.Vertex, position mmath.Vec3, t float64) {
+	keep.Position = position
+	if t <= 0 {
+		return
+	}
+	normal := keep.Normal.Lerp(other.Normal, t)
+	if !normal.IsZero() {
+		keep.Normal = normal.Normalized()
+	}
+	keep.Uv = keep.Uv.Lerp(other.Uv, t)
+	for i := range keep.ExtendedUvs {
+		if i < len(other.ExtendedUvs) {
+			keep.ExtendedUvs[i] = keep.ExtendedUvs[i].MuledScalar(1 - t).Added(other.ExtendedUvs[i].MuledScalar(t))
+		}
+	}
+	keep.EdgeFactor = keep.EdgeFactor*(1-t) + other.EdgeFactor*t
+	if sameVertexDeform(keep.Deform, other.Deform) {
+		return
+	}
+	if t >= 1 || keep.Deform == nil {
+		keep.Deform = remapDeform(other.Deform, nil)
+		if keep.Deform != nil {
+			keep.DeformType = keep.Deform.DeformType()
+		}
+		return
+	}
+	weights := make(map[int]float64)
+	addDeformWeights(weights, keep.Deform, 1-t)
+	addDeformWeights(weights, other.Deform, t)
+	applyBoneWeights(keep, weights, 4, 0)
+}
+
+// addDeformWeights はデフォームのウェイトを比率付きで加算する。
+func addDeformWeights(weights map[int]float64, deform model.IDeform, factor float64) {
+	if deform == nil {
+		return
+	}
+	values := deform.Weights()
+	for i, boneIndex := range deform.Indexes() {
+		if i < len(values) && boneIndex >= 0 {
+			weights[boneIndex] += values[i] * factor
+		}
+	}
+}
+
+// isVertexOffsetMorph は頂点単位のオフセットを持つモーフ種別か判定する。
+func isVertexOffsetMorph(morphType model.MorphType) bool {
+	switch morphType {
+	case model.MORPH_TYPE_VERTEX, model.MORPH_TYPE_AFTER_VERTEX, model.MORPH_TYPE_UV,
+		model.MORPH_TYPE_EXTENDED_UV1, model.MORPH_TYPE_EXTENDED_UV2,
+		model.MORPH_TYPE_EXTENDED_UV3, model.MORPH_TYPE_EXTENDED_UV4:
+		return true
+	}
+	return false
+}
+
+// containsVertex は面が頂点を含むか判定する。
+func containsVertex(face [3]int, vertexIndex int) bool {
+	return face[0] == vertexIndex || face[1] == vertexIndex || face[2] == vertexIndex
+}
+
+// newDecimateVec3 は成分から Vec3 を生成する。
+func newDecimateVec3(x, y, z float64) mmath.Vec3 {
+	v := mmath.NewVec3()
+	v.X, v.Y, v.Z = x, y, z
+	return v
+}
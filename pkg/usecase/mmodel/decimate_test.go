@@ -0,0 +1,353 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// newDecimateGridModel は XZ 平面上の分割グリッドを生成する。
+// splitMaterial が true の場合は x の左右で材質を分ける。
+func newDecimateGridModel(size int, splitMaterial bool) *model.PmxModel {
+	m := model.NewPmxModel()
+	appendTestBone(m, "左", -1)
+	appendTestBone(m, "右", -1)
+	for z := 0; z <= size; z++ {
+		for x := 0; x <= size; x++ {
+			var deform model.IDeform = model.NewBdef1(0)
+			if x*2 > size {
+				deform = model.NewBdef1(1)
+			}
+			m.Vertices.Append(&model.Vertex{
+				Position:   vec3(float64(x), 0, float64(z)),
+				Normal:     vec3(0, 1, 0),
+				Uv:         mmath.Vec2{X: float64(x) / float64(size), Y: float64(z) / float64(size)},
+				DeformType: model.BDEF1,
+				Deform:     deform,
+				EdgeFactor: 1,
+			})
+		}
+	}
+	materialCount := 1
+	if splitMaterial {
+		materialCount = 2
+	}
+	for materialIndex := 0; materialIndex < materialCount; materialIndex++ {
+		material := model.NewMaterial()
+		material.SetName([]string{"左材質", "右材質"}[materialIndex])
+		faces := 0
+		for z := 0; z < size; z++ {
+			for x := 0; x < size; x++ {
+				if splitMaterial && (x*2 < size) != (materialIndex == 0) {
+					continue
+				}
+				v0 := z*(size+1) + x
+				v1 := v0 + 1
+				v2 := v0 + size + 1
+				v3 := v2 + 1
+				m.Faces.Append(&model.Face{VertexIndexes: [3]int{v0, v2, v1}})
+				m.Faces.Append(&model.Face{VertexIndexes: [3]int{v1, v2, v3}})
+				faces += 2
+			}
+		}
+		material.VerticesCount = faces * 3
+		m.Materials.Append(material)
+	}
+	morph := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX}
+	morph.SetName("あ")
+	for vertexIndex := 0; vertexIndex < m.Vertices.Len(); vertexIndex++ {
+		morph.Offsets = append(morph.Offsets, &model.VertexMorphOffset{VertexIndex: vertexIndex, Position: vec3(0, 1, 0)})
+	}
+	m.Morphs.Append(morph)
+	return m
+}
+
+// TestDecimateMeshReducesFaces は面数が目標近くまで減り、デフォームとモーフが保たれることを確認する。
+func TestDecimateMeshReducesFaces(t *testing.T) {
+	m := newDecimateGridModel(8, false)
+	result, err := DecimateMesh(m, DecimateOptions{TargetRatio: 0.5})
+	if err != nil {
+		t.Fatalf("DecimateMesh failed: %v", err)
+	}
+	if m.Faces.Len() != 128 || m.Vertices.Len() != 81 {
+		t.Fatalf("original model should not be modified")
+	}
+	out := result.Model
+	if result.Materials[0].FacesBefore != 128 || result.Materials[0].FacesAfter > 80 || result.Materials[0].FacesAfter < 62 {
+		t.Fatalf("unexpected face count: %+v", result.Materials[0])
+	}
+	if out.Faces.Len() != result.Materials[0].FacesAfter || out.Materials.Values()[0].VerticesCount != out.Faces.Len()*3 {
+		t.Fatalf("faces and material count mismatch: %d %d", out.Faces.Len(), out.Materials.Values()[0].VerticesCount)
+	}
+	if result.VerticesAfter >= result.VerticesBefore || out.Vertices.Len() != result.VerticesAfter {
+		t.Fatalf("vertices should be reduced: %d -> %d", result.VerticesBefore, result.VerticesAfter)
+	}
+
+	for _, face := range out.Faces.Values() {
+		for _, vertexIndex := range face.VertexIndexes {
+			if vertexIndex < 0 || vertexIndex >= out.Vertices.Len() {
+				t.Fatalf("face refers to invalid vertex: %v", face.VertexIndexes)
+			}
+		}
+		normal := out.Vertices.Values()[face.VertexIndexes[1]].Position.Subed(out.Vertices.Values()[face.VertexIndexes[0]].Position).
+			Cross(out.Vertices.Values()[face.VertexIndexes[2]].Position.Subed(out.Vertices.Values()[face.VertexIndexes[0]].Position))
+		if normal.Y <= 0 {
+			t.Fatalf("face should not be flipped: %v", face.VertexIndexes)
+		}
+	}
+	for _, vertex := range out.Vertices.Values() {
+		if math.Abs(vertex.Position.Y) > 1e-9 {
+			t.Fatalf("vertex should stay on plane: %v", vertex.Position)
+		}
+		sum := 0.0
+		for _, weight := range vertex.Deform.Weights() {
+			sum += weight
+		}
+		if math.Abs(sum-1) > 1e-6 {
+			t.Fatalf("weights should be normalized: %v", vertex.Deform.Weights())
+		}
+	}
+
+	morph, err := out.Morphs.GetByName("あ")
+	if err != nil {
+		t.Fatalf("morph not found: %v", err)
+	}
+	if len(morph.Offsets) != out.Vertices.Len() {
+		t.Fatalf("morph offsets should follow vertices: %d %d", len(morph.Offsets), out.Vertices.Len())
+	}
+	for _, offset := range morph.Offsets {
+		vertexOffset := offset.(*model.VertexMorphOffset)
+		if math.Abs(vertexOffset.Position.Y-1) > 1e-9 {
+			t.Fatalf("morph offset should be preserved: %v", vertexOffset.Position)
+		}
+	}
+}
+
+// TestDecimateMeshKeepsMaterialBoundary は材質境界の頂点と材質別割合が守られることを確認する。
+func TestDecimateMeshKeepsMaterialBoundary(t *testing.T) {
+	m := newDecimateGridModel(8, true)
+	result, err := DecimateMesh(m, DecimateOptions{TargetRatio: 0.5, MaterialRatios: map[int]float64{1: 1}})
+	if err != nil {
+		t.Fatalf("DecimateMesh failed: %v", err)
+	}
+	if result.Materials[0].FacesAfter >= result.Materials[0].FacesBefore {
+		t.Fatalf("left material should be reduced: %+v", result.Materials[0])
+	}
+	if result.Materials[1].FacesAfter != result.Materials[1].FacesBefore {
+		t.Fatalf("right material should be kept: %+v", result.Materials[1])
+	}
+	boundary := 0
+	for _, vertex := range result.Model.Vertices.Values() {
+		if math.Abs(vertex.Position.X-4) < 1e-9 {
+			boundary++
+		}
+	}
+	if boundary != 9 {
+		t.Fatalf("boundary vertices should be kept: %d", boundary)
+	}
+}
+
+// TestDecimateMeshInvalidRatio は割合不正時にエラーになることを確認する。
+func TestDecimateMeshInvalidRatio(t *testing.T) {
+	m := newDecimateGridModel(2, false)
+	if _, err := DecimateMesh(m, DecimateOptions{TargetRatio: 1.5}); merr.ExtractErrorID(err) != decimateRatioInvalidErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := DecimateMesh(m, DecimateOptions{MaterialRatios: map[int]float64{3: 0.5}}); merr.ExtractErrorID(err) != decimateRatioInvalidErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := DecimateMesh(nil, DecimateOptions{}); merr.ExtractErrorID(err) != modelNotSpecifiedErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestGenerateLods は割合ごとに面数の異なるモデルを生成することを確認する。
+func TestGenerateLods(t *testing.T) {
+	m := newDecimateGridModel(8, false)
+	results, err := GenerateLods(m, []float64{1, 0.5, 0.25}, DecimateOptions{})
+	if err != nil {
+		t.Fatalf("GenerateLods failed: %v", err)
+	}
+	if len(results) != 3 || results[0].Model.Faces.Len() != 128 {
+		t.Fatalf("unexpected lods: %d", len(results))
+	}
+	if !(results[2].Model.Faces.Len() < results[1].Model.Faces.Len() && results[1].Model.Faces.Len() < 128) {
+		t.Fatalf("lods should be lighter: %d %d", results[1].Model.Faces.Len(), results[2].Model.Faces.Len())
+	}
+}
+
+// newDecimateBlendModel は頂点位置に比例するUV・モーフオフセットと、混合したBDEF2ウェイトを持つグリッドを生成する。
+func newDecimateBlendModel(size int) *model.PmxModel {
+	m := newDecimateGridModel(size, false)
+	morph, _ := m.Morphs.GetByName("あ")
+	uvMorph := &model.Morph{MorphType: model.MORPH_TYPE_UV}
+	uvMorph.SetName("UV")
+	for i, vertex := range m.Vertices.Values() {
+		u := vertex.Uv.X
+		vertex.DeformType = model.BDEF2
+		vertex.Deform = model.NewBdef2(0, 1, 1-u)
+		morph.Offsets[i] = &model.VertexMorphOffset{VertexIndex: i, Position: vec3(0, u*float64(size), 0)}
+		uvMorph.Offsets = append(uvMorph.Offsets, &model.UvMorphOffset{
+			VertexIndex: i, Uv: mmath.Vec4{X: u * 0.5, Y: vertex.Uv.Y * 0.25}, UvType: model.MORPH_TYPE_UV})
+	}
+	m.Morphs.Append(uvMorph)
+	return m
+}
+
+// TestDecimateCollapseBlendsAttributes は1辺の折り畳みでUV・ウェイト・モーフオフセットが比率通りに混合されることを確認する。
+func TestDecimateCollapseBlendsAttributes(t *testing.T) {
+	m := newDecimateGridModel(2, false)
+	keep, _ := m.Vertices.Get(4)
+	remove, _ := m.Vertices.Get(5)
+	keep.DeformType, keep.Deform = model.BDEF2, model.NewBdef2(0, 1, 0.8)
+	remove.DeformType, remove.Deform = model.BDEF2, model.NewBdef2(0, 1, 0.2)
+	morph, _ := m.Morphs.GetByName("あ")
+	morph.Offsets = []model.IMorphOffset{
+		&model.VertexMorphOffset{VertexIndex: 4, Position: vec3(0, 1, 0)},
+		&model.VertexMorphOffset{VertexIndex: 5, Position: vec3(0, 3, 0)},
+		&model.VertexMorphOffset{VertexIndex: 7, Position: vec3(1, 0, 0)},
+	}
+	// 折り畳まれる側だけが持つUVオフセットは比率分だけ残す。
+	uvMorph := &model.Morph{MorphType: model.MORPH_TYPE_UV}
+	uvMorph.SetName("UV")
+	uvMorph.Offsets = []model.IMorphOffset{
+		&model.UvMorphOffset{VertexIndex: 5, Uv: mmath.Vec4{X: 0.2, Y: 0.4}, UvType: model.MORPH_TYPE_UV},
+	}
+	m.Morphs.Append(uvMorph)
+
+	d := newMeshDecimator(m, DecimateOptions{})
+	d.collapse(decimateCandidate{keep: 4, remove: 5, position: vec3(1.5, 0, 1), t: 0.5})
+	d.apply()
+
+	if m.Vertices.Len() != 8 || m.Faces.Len() != 6 {
+		t.Fatalf("collapse result mismatch: vertices=%d faces=%d", m.Vertices.Len(), m.Faces.Len())
+	}
+	blended, _ := m.Vertices.Get(4)
+	if blended.Position != vec3(1.5, 0, 1) || math.Abs(blended.Uv.X-0.75) > 1e-9 || math.Abs(blended.Uv.Y-0.5) > 1e-9 {
+		t.Fatalf("vertex attributes mismatch: %v %v", blended.Position, blended.Uv)
+	}
+	if math.Abs(weightOf(blended, 0)-0.5) > 1e-9 || math.Abs(weightOf(blended, 1)-0.5) > 1e-9 {
+		t.Fatalf("weights should be blended: %v %v", blended.Deform.Indexes(), blended.Deform.Weights())
+	}
+	if len(morph.Offsets) != 2 {
+		t.Fatalf("vertex morph offsets mismatch: %d", len(morph.Offsets))
+	}
+	if o := morph.Offsets[0].(*model.VertexMorphOffset); o.VertexIndex != 4 || math.Abs(o.Position.Y-2) > 1e-9 {
+		t.Fatalf("vertex morph offset should be blended: %d %v", o.VertexIndex, o.Position)
+	}
+	// 後ろの頂点のオフセットは詰めた index を指す。
+	if o := morph.Offsets[1].(*model.VertexMorphOffset); o.VertexIndex != 6 || o.Position.X != 1 {
+		t.Fatalf("vertex morph offset should be remapped: %d %v", o.VertexIndex, o.Position)
+	}
+	o := uvMorph.Offsets[0].(*model.UvMorphOffset)
+	if len(uvMorph.Offsets) != 1 || o.VertexIndex != 4 || math.Abs(o.Uv.X-0.1) > 1e-9 || math.Abs(o.Uv.Y-0.2) > 1e-9 {
+		t.Fatalf("uv morph offset should be blended: %d %v", o.VertexIndex, o.Uv)
+	}
+}
+
+// TestDecimateMeshBlendsLinearAttributes は簡略化後もUV・ウェイト・モーフオフセットが同じ比率で混合されたままであることを確認する。
+func TestDecimateMeshBlendsLinearAttributes(t *testing.T) {
+	size := 8
+	result, err := DecimateMesh(newDecimateBlendModel(size), DecimateOptions{TargetRatio: 0.5})
+	if err != nil {
+		t.Fatalf("DecimateMesh failed: %v", err)
+	}
+	out := result.Model
+	if result.VerticesAfter >= result.VerticesBefore {
+		t.Fatalf("vertices should be reduced: %d -> %d", result.VerticesBefore, result.VerticesAfter)
+	}
+	morph, _ := out.Morphs.GetByName("あ")
+	uvMorph, _ := out.Morphs.GetByName("UV")
+	if len(morph.Offsets) != out.Vertices.Len() || len(uvMorph.Offsets) != out.Vertices.Len() {
+		t.Fatalf("morph offsets should follow vertices: %d %d %d", len(morph.Offsets), len(uvMorph.Offsets), out.Vertices.Len())
+	}
+	// 元の属性はすべてUVに比例するため、同じ比率で混合されていれば比例関係が保たれる。
+	for i, vertex := range out.Vertices.Values() {
+		u := vertex.Uv.X
+		if math.Abs(weightOf(vertex, 0)-(1-u)) > 1e-6 || math.Abs(weightOf(vertex, 1)-u) > 1e-6 {
+			t.Fatalf("vertex %d weights mismatch: u=%f %v", i, u, vertex.Deform.Weights())
+		}
+		vertexOffset := morph.Offsets[i].(*model.VertexMorphOffset)
+		if vertexOffset.VertexIndex != i || math.Abs(vertexOffset.Position.Y-u*float64(size)) > 1e-6 {
+			t.Fatalf("vertex %d morph offset mismatch: u=%f %v", i, u, vertexOffset.Position)
+		}
+		uvOffset := uvMorph.Offsets[i].(*model.UvMorphOffset)
+		if uvOffset.VertexIndex != i || math.Abs(uvOffset.Uv.X-u*0.5) > 1e-6 || math.Abs(uvOffset.Uv.Y-vertex.Uv.Y*0.25) > 1e-6 {
+			t.Fatalf("vertex %d uv morph offset mismatch: uv=%v %v", i, vertex.Uv, uvOffset.Uv)
+		}
+	}
+}
+
+// TestDecimateMeshKeepsUvSeam はUVで分割された同一位置の頂点を動かさないことを確認する。
+func TestDecimateMeshKeepsUvSeam(t *testing.T) {
+	size := 8
+	m := model.NewPmxModel()
+	appendTestBone(m, "センター", -1)
+	material := model.NewMaterial()
+	material.SetName("材質")
+	// x=4 の列を左右の島で別頂点として持ち、UVだけを変える。
+	for island := 0; island < 2; island++ {
+		start := m.Vertices.Len()
+		for z := 0; z <= size; z++ {
+			for x := 0; x <= size/2; x++ {
+				px := x + island*size/2
+				m.Vertices.Append(&model.Vertex{
+					Position:   vec3(float64(px), 0, float64(z)),
+					Normal:     vec3(0, 1, 0),
+					Uv:         mmath.Vec2{X: float64(px)/float64(size)*0.5 + float64(island)*0.5, Y: float64(z) / float64(size)},
+					DeformType: model.BDEF1,
+					Deform:     model.NewBdef1(0),
+					EdgeFactor: 1,
+				})
+			}
+		}
+		width := size/2 + 1
+		for z := 0; z < size; z++ {
+			for x := 0; x < size/2; x++ {
+				v0 := start + z*width + x
+				v2 := v0 + width
+				m.Faces.Append(&model.Face{VertexIndexes: [3]int{v0, v2, v0 + 1}})
+				m.Faces.Append(&model.Face{VertexIndexes: [3]int{v0 + 1, v2, v2 + 1}})
+				material.VerticesCount += 6
+			}
+		}
+	}
+	m.Materials.Append(material)
+
+	result, err := DecimateMesh(m, DecimateOptions{TargetRatio: 0.5})
+	if err != nil {
+		t.Fatalf("DecimateMesh failed: %v", err)
+	}
+	if result.Materials[0].FacesAfter >= result.Materials[0].FacesBefore {
+		t.Fatalf("faces should be reduced: %+v", result.Materials[0])
+	}
+	seams := map[float64]int{}
+	for _, vertex := range result.Model.Vertices.Values() {
+		if vertex.Position.X == float64(size/2) {
+			seams[vertex.Uv.X]++
+		}
+	}
+	if seams[0.25] != size+1 || seams[0.75] != size+1 {
+		t.Fatalf("seam vertices should be kept on both islands: %v", seams)
+	}
+}
+
+// TestDecimateMeshKeepsUnusedVertices は元から面に使われていない頂点を削除しないことを確認する。
+func TestDecimateMeshKeepsUnusedVertices(t *testing.T) {
+	m := newDecimateGridModel(4, false)
+	m.Vertices.Append(&model.Vertex{Position: vec3(100, 0, 0), DeformType: model.BDEF1, Deform: model.NewBdef1(0)})
+
+	result, err := DecimateMesh(m, DecimateOptions{TargetRatio: 0.5})
+	if err != nil {
+		t.Fatalf("DecimateMesh failed: %v", err)
+	}
+	out := result.Model
+	last, _ := out.Vertices.Get(out.Vertices.Len() - 1)
+	if result.VerticesAfter >= result.VerticesBefore || last.Position != vec3(100, 0, 0) {
+		t.Fatalf("unused vertex should be kept: %d -> %d %v", result.VerticesBefore, result.VerticesAfter, last.Position)
+	}
+}
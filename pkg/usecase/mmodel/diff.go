@@ -0,0 +1,812 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/collection"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+// defaultDiffTolerance は数値比較の許容差の既定値。
+const defaultDiffTolerance = 1e-5
+
+// ModelDiffKind は差分の種類を表す。
+type ModelDiffKind string
+
+const (
+	// MODEL_DIFF_ADDED は追加。
+	MODEL_DIFF_ADDED ModelDiffKind = "added"
+	// MODEL_DIFF_REMOVED は削除。
+	MODEL_DIFF_REMOVED ModelDiffKind = "removed"
+	// MODEL_DIFF_MODIFIED は変更。
+	MODEL_DIFF_MODIFIED ModelDiffKind = "modified"
+)
+
+// ModelDiffCategory は差分の対象要素を表す。
+type ModelDiffCategory string
+
+const (
+	// MODEL_DIFF_MODEL はモデル情報。
+	MODEL_DIFF_MODEL ModelDiffCategory = "Model"
+	// MODEL_DIFF_VERTEX は頂点。
+	MODEL_DIFF_VERTEX ModelDiffCategory = "Vertex"
+	// MODEL_DIFF_FACE は面。
+	MODEL_DIFF_FACE ModelDiffCategory = "Face"
+	// MODEL_DIFF_TEXTURE はテクスチャ。
+	MODEL_DIFF_TEXTURE ModelDiffCategory = "Texture"
+	// MODEL_DIFF_MATERIAL は材質。
+	MODEL_DIFF_MATERIAL ModelDiffCategory = "Material"
+	// MODEL_DIFF_BONE はボーン。
+	MODEL_DIFF_BONE ModelDiffCategory = "Bone"
+	// MODEL_DIFF_MORPH はモーフ。
+	MODEL_DIFF_MORPH ModelDiffCategory = "Morph"
+	// MODEL_DIFF_DISPLAY_SLOT は表示枠。
+	MODEL_DIFF_DISPLAY_SLOT ModelDiffCategory = "DisplaySlot"
+	// MODEL_DIFF_RIGID_BODY は剛体。
+	MODEL_DIFF_RIGID_BODY ModelDiffCategory = "RigidBody"
+	// MODEL_DIFF_JOINT はジョイント。
+	MODEL_DIFF_JOINT ModelDiffCategory = "Joint"
+)
+
+// ModelDiffOptions はモデル差分のオプションを表す。
+type ModelDiffOptions struct {
+	// Tolerance は数値を同じとみなす許容差。0以下は既定値を使う。
+	Tolerance float64
+	// MatchVerticesByPosition は頂点をindexではなく位置で対応付けるか示す。
+	// 頂点の追加・削除でindexがずれた場合に使う。
+	MatchVerticesByPosition bool
+}
+
+// ModelFieldChange は項目1つの変更を表す。
+type ModelFieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// ModelDiffEntry は要素1つの差分を表す。
+type ModelDiffEntry struct {
+	Category ModelDiffCategory
+	Kind     ModelDiffKind
+	// Name は名前で対応付けた要素は名前、indexで対応付けた要素は "#index"。
+	Name    string
+	Changes []ModelFieldChange
+}
+
+// ModelDiff はモデル差分の結果を表す。
+type ModelDiff struct {
+	Entries []ModelDiffEntry
+	// FieldCounts は「要素.項目」ごとの変更数。意図しない変更(ウェイト編集で頂点位置も動いた等)の確認に使う。
+	FieldCounts map[string]int
+}
+
+// DiffModels は2つのモデルを要素ごとに比較する。
+// 名前を持つ要素は名前で、頂点と面はindex(オプションで頂点は位置)で対応付ける。
+// index参照(親ボーン・テクスチャ等)は参照先の名前で比較するため、並び替えだけでは差分にならない。
+func DiffModels(before, after *model.PmxModel, opts ModelDiffOptions) (*ModelDiff, error) {
+	if before == nil || after == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = defaultDiffTolerance
+	}
+	d := &modelDiffer{before: before, after: after, opts: opts, result: &ModelDiff{FieldCounts: make(map[string]int)}}
+
+	fields := d.newFields()
+	fields.str("Name", before.Name(), after.Name())
+	fields.str("EnglishName", before.EnglishName, after.EnglishName)
+	fields.str("Comment", before.Comment, after.Comment)
+	fields.str("EnglishComment", before.EnglishComment, after.EnglishComment)
+	d.addModified(MODEL_DIFF_MODEL, before.Name(), fields)
+
+	d.diffVertices()
+	d.diffFaces()
+	diffNamed(d, MODEL_DIFF_TEXTURE, before.Textures.Values(), after.Textures.Values(), func(_, _ *model.Texture, _ *diffFields) {})
+	diffNamed(d, MODEL_DIFF_MATERIAL, before.Materials.Values(), after.Materials.Values(), d.diffMaterial)
+	diffNamed(d, MODEL_DIFF_BONE, before.Bones.Values(), after.Bones.Values(), d.diffBone)
+	diffNamed(d, MODEL_DIFF_MORPH, before.Morphs.Values(), after.Morphs.Values(), d.diffMorph)
+	diffNamed(d, MODEL_DIFF_DISPLAY_SLOT, before.DisplaySlots.Values(), after.DisplaySlots.Values(), d.diffDisplaySlot)
+	diffNamed(d, MODEL_DIFF_RIGID_BODY, before.RigidBodies.Values(), after.RigidBodies.Values(), d.diffRigidBody)
+	diffNamed(d, MODEL_DIFF_JOINT, before.Joints.Values(), after.Joints.Values(), d.diffJoint)
+	return d.result, nil
+}
+
+// HasChanges は差分があるか返す。
+func (r *ModelDiff) HasChanges() bool {
+	return len(r.Entries) > 0
+}
+
+// Count は指定した要素と種類の差分数を返す。
+func (r *ModelDiff) Count(category ModelDiffCategory, kind ModelDiffKind) int {
+	count := 0
+	for _, entry := range r.Entries {
+		if entry.Category == category && entry.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteText は差分を人が読むためのテキストで書き込む。
+func (r *ModelDiff) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, entry := range r.Entries {
+		fmt.Fprintf(&b, "[%s] %s %s\n", entry.Category, entry.Kind, entry.Name)
+		for _, change := range entry.Changes {
+			fmt.Fprintf(&b, "  %s: %s -> %s\n", change.Field, change.Before, change.After)
+		}
+	}
+	if len(r.FieldCounts) > 0 {
+		b.WriteString("Summary:\n")
+		keys := make([]string, 0, len(r.FieldCounts))
+		for key := range r.FieldCounts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "  %s: %d\n", key, r.FieldCounts[key])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON は差分をJSONで書き込む。
+func (r *ModelDiff) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// modelDiffer はモデル差分の作業状態を表す。
+type modelDiffer struct {
+	before *model.PmxModel
+	after  *model.PmxModel
+	opts   ModelDiffOptions
+	result *ModelDiff
+	// beforeVertexKeys/afterVertexKeys は頂点の対応付けに合わせた、面と頂点・UVモーフのオフセットが参照する頂点の名前。
+	beforeVertexKeys []string
+	afterVertexKeys  []string
+}
+
+// newFields は項目比較の集計を生成する。
+func (d *modelDiffer) newFields() *diffFields {
+	return &diffFields{tolerance: d.opts.Tolerance}
+}
+
+// add は差分を追加する。
+func (d *modelDiffer) add(category ModelDiffCategory, kind ModelDiffKind, name string, changes []ModelFieldChange) {
+	d.result.Entries = append(d.result.Entries, ModelDiffEntry{Category: category, Kind: kind, Name: name, Changes: changes})
+	for _, change := range changes {
+		d.result.FieldCounts[string(category)+"."+fieldCountKey(change.Field)]++
+	}
+}
+
+// addModified は変更があった場合のみ差分を追加する。
+func (d *modelDiffer) addModified(category ModelDiffCategory, name string, fields *diffFields) {
+	if len(fields.changes) > 0 {
+		d.add(category, MODEL_DIFF_MODIFIED, name, fields.changes)
+	}
+}
+
+// diffNamed は名前で対応付けて要素を比較する。同名が複数ある場合は出現順に対応付ける。
+func diffNamed[T interface {
+	comparable
+	Name() string
+}](d *modelDiffer, category ModelDiffCategory, before, after []T, compare func(b, a T, fields *diffFields)) {
+	var zero T
+	unmatched := make(map[string][]T)
+	for _, item := range before {
+		if item != zero {
+			unmatched[item.Name()] = append(unmatched[item.Name()], item)
+		}
+	}
+	for _, item := range after {
+		if item == zero {
+			continue
+		}
+		candidates := unmatched[item.Name()]
+		if len(candidates) == 0 {
+			d.add(category, MODEL_DIFF_ADDED, item.Name(), nil)
+			continue
+		}
+		unmatched[item.Name()] = candidates[1:]
+		fields := d.newFields()
+		compare(candidates[0], item, fields)
+		d.addModified(category, item.Name(), fields)
+	}
+	for _, item := range before {
+		if item == zero {
+			continue
+		}
+		if candidates := unmatched[item.Name()]; len(candidates) > 0 && candidates[0] == item {
+			unmatched[item.Name()] = candidates[1:]
+			d.add(category, MODEL_DIFF_REMOVED, item.Name(), nil)
+		}
+	}
+}
+
+// diffVertices は頂点を比較する。
+func (d *modelDiffer) diffVertices() {
+	before := d.before.Vertices.Values()
+	after := d.after.Vertices.Values()
+	pairs := make([]int, len(after))
+	if d.opts.MatchVerticesByPosition {
+		pairs = d.matchVerticesByPosition(before, after)
+	} else {
+		for i := range pairs {
+			pairs[i] = -1
+			if i < len(before) {
+				pairs[i] = i
+			}
+		}
+	}
+	matched := make([]bool, len(before))
+	d.beforeVertexKeys = make([]string, len(before))
+	d.afterVertexKeys = make([]string, len(after))
+	for i, vertex := range after {
+		if vertex == nil {
+			continue
+		}
+		name := "#" + strconv.Itoa(i)
+		if pairs[i] < 0 || before[pairs[i]] == nil {
+			d.afterVertexKeys[i] = name
+			d.add(MODEL_DIFF_VERTEX, MODEL_DIFF_ADDED, name, nil)
+			continue
+		}
+		matched[pairs[i]] = true
+		if pairs[i] != i {
+			name = fmt.Sprintf("#%d (#%d)", i, pairs[i])
+		}
+		d.afterVertexKeys[i] = name
+		d.beforeVertexKeys[pairs[i]] = name
+		original := before[pairs[i]]
+		fields := d.newFields()
+		fields.vec3("Position", original.Position, vertex.Position)
+		fields.vec3("Normal", original.Normal, vertex.Normal)
+		fields.vec2("Uv", original.Uv, vertex.Uv)
+		for u := 0; u < max(len(original.ExtendedUvs), len(vertex.ExtendedUvs)); u++ {
+			fields.vec4(fmt.Sprintf("ExtendedUv%d", u+1), vec4At(original.ExtendedUvs, u), vec4At(vertex.ExtendedUvs, u))
+		}
+		fields.float("EdgeFactor", original.EdgeFactor, vertex.EdgeFactor)
+		d.diffDeform(fields, original.Deform, vertex.Deform)
+		d.addModified(MODEL_DIFF_VERTEX, name, fields)
+	}
+	for i, vertex := range before {
+		if vertex != nil && !matched[i] {
+			// 追加された頂点と同じindexでも別の対象として比較されないよう、モーフでは括弧付きの名前にする。
+			d.beforeVertexKeys[i] = "(#" + strconv.Itoa(i) + ")"
+			d.add(MODEL_DIFF_VERTEX, MODEL_DIFF_REMOVED, "#"+strconv.Itoa(i), nil)
+		}
+	}
+}
+
+// vertexKey は面やモーフのオフセットが参照する頂点の名前を、頂点の対応付けに合わせて返す。
+func (d *modelDiffer) vertexKey(modelData *model.PmxModel, index int) string {
+	keys := d.afterVertexKeys
+	if modelData == d.before {
+		keys = d.beforeVertexKeys
+	}
+	if index >= 0 && index < len(keys) && keys[index] != "" {
+		return keys[index]
+	}
+	return "#" + strconv.Itoa(index)
+}
+
+// matchVerticesByPosition は許容差内の位置にある頂点を出現順に対応付ける。
+func (d *modelDiffer) matchVerticesByPosition(before, after []*model.Vertex) []int {
+	// 境界をまたいで許容差内にある頂点も対応付けるよう、隣接する格子も探索する。
+	grid := newVertexPositionGrid(before, d.opts.Tolerance)
+	matched := make([]bool, len(before))
+	pairs := make([]int, len(after))
+	for i, vertex := range after {
+		pairs[i] = -1
+		if vertex == nil {
+			continue
+		}
+		grid.each(vertex.Position, func(j int) {
+			if matched[j] || (pairs[i] >= 0 && pairs[i] < j) {
+				return
+			}
+			if vertex.Position.Distance(before[j].Position) <= d.opts.Tolerance {
+				pairs[i] = j
+			}
+		})
+		if pairs[i] >= 0 {
+			matched[pairs[i]] = true
+		}
+	}
+	return pairs
+}
+
+// diffDeform はデフォームを種別と参照ボーン名ごとのウェイトで比較する。
+func (d *modelDiffer) diffDeform(fields *diffFields, before, after model.IDeform) {
+	if before == nil || after == nil {
+		if (before == nil) != (after == nil) {
+			fields.add("Deform", d.formatDeform(d.before, before), d.formatDeform(d.after, after))
+		}
+		return
+	}
+	fields.str("DeformType", deformTypeName(before.DeformType()), deformTypeName(after.DeformType()))
+	beforeWeights := d.deformWeights(d.before, before)
+	afterWeights := d.deformWeights(d.after, after)
+	changed := len(beforeWeights) != len(afterWeights)
+	for name, weight := range beforeWeights {
+		other, ok := afterWeights[name]
+		if !ok || math.Abs(weight-other) > d.opts.Tolerance {
+			changed = true
+		}
+	}
+	if changed {
+		fields.add("Deform", d.formatDeform(d.before, before), d.formatDeform(d.after, after))
+	}
+	beforeSdef, beforeOk := before.(*model.Sdef)
+	afterSdef, afterOk := after.(*model.Sdef)
+	if beforeOk && afterOk {
+		fields.vec3("SdefC", beforeSdef.SdefC, afterSdef.SdefC)
+		fields.vec3("SdefR0", beforeSdef.SdefR0, afterSdef.SdefR0)
+		fields.vec3("SdefR1", beforeSdef.SdefR1, afterSdef.SdefR1)
+	}
+}
+
+// deformWeights はデフォームを参照ボーン名ごとのウェイトへ変換する。
+func (d *modelDiffer) deformWeights(modelData *model.PmxModel, deform model.IDeform) map[string]float64 {
+	weights := make(map[string]float64)
+	deformWeights := deform.Weights()
+	for i, boneIndex := range deform.Indexes() {
+		if i < len(deformWeights) && deformWeights[i] > 0 {
+			weights[boneNameAt(modelData, boneIndex)] += deformWeights[i]
+		}
+	}
+	return weights
+}
+
+// formatDeform はデフォームを「ボーン名:ウェイト」の並びで表す。
+func (d *modelDiffer) formatDeform(modelData *model.PmxModel, deform model.IDeform) string {
+	if deform == nil {
+		return ""
+	}
+	weights := d.deformWeights(modelData, deform)
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+":"+formatDiffFloat(weights[name]))
+	}
+	return deformTypeName(deform.DeformType()) + " " + strings.Join(parts, " ")
+}
+
+// diffFaces は面をindexで比較する。
+// 参照する頂点は頂点の対応付けに合わせた名前で比較し、位置で対応付けた場合の頂点indexのずれは差分にしない。
+func (d *modelDiffer) diffFaces() {
+	before := d.before.Faces.Values()
+	after := d.after.Faces.Values()
+	for i := 0; i < max(len(before), len(after)); i++ {
+		name := "#" + strconv.Itoa(i)
+		switch {
+		case i >= len(before):
+			d.add(MODEL_DIFF_FACE, MODEL_DIFF_ADDED, name, nil)
+		case i >= len(after):
+			d.add(MODEL_DIFF_FACE, MODEL_DIFF_REMOVED, name, nil)
+		case before[i] != nil && after[i] != nil:
+			fields := d.newFields()
+			fields.str("VertexIndexes", d.faceVertexKeys(d.before, before[i]), d.faceVertexKeys(d.after, after[i]))
+			d.addModified(MODEL_DIFF_FACE, name, fields)
+		}
+	}
+}
+
+// faceVertexKeys は面が参照する頂点を対応付けに合わせた名前の並びで表す。
+func (d *modelDiffer) faceVertexKeys(modelData *model.PmxModel, face *model.Face) string {
+	keys := make([]string, len(face.VertexIndexes))
+	for i, vertexIndex := range face.VertexIndexes {
+		keys[i] = d.vertexKey(modelData, vertexIndex)
+	}
+	return "[" + strings.Join(keys, " ") + "]"
+}
+
+// diffMaterial は材質を比較する。描画順に影響するため並び順の変更も報告する。
+func (d *modelDiffer) diffMaterial(before, after *model.Material, fields *diffFields) {
+	fields.integer("Order", before.Index(), after.Index())
+	fields.str("EnglishName", before.EnglishName, after.EnglishName)
+	fields.str("Memo", before.Memo, after.Memo)
+	fields.vec4("Diffuse", before.Diffuse, after.Diffuse)
+	fields.vec4("Specular", before.Specular, after.Specular)
+	fields.vec3("Ambient", before.Ambient, after.Ambient)
+	fields.integer("DrawFlag", int(before.DrawFlag), int(after.DrawFlag))
+	fields.vec4("Edge", before.Edge, after.Edge)
+	fields.float("EdgeSize", before.EdgeSize, after.EdgeSize)
+	fields.str("Texture", namedAt(d.before.Textures, before.TextureIndex), namedAt(d.after.Textures, after.TextureIndex))
+	fields.str("SphereTexture", namedAt(d.before.Textures, before.SphereTextureIndex), namedAt(d.after.Textures, after.SphereTextureIndex))
+	fields.integer("SphereMode", int(before.SphereMode), int(after.SphereMode))
+	fields.integer("ToonSharingFlag", int(before.ToonSharingFlag), int(after.ToonSharingFlag))
+	fields.str("ToonTexture", d.toonName(d.before, before), d.toonName(d.after, after))
+	fields.integer("VerticesCount", before.VerticesCount, after.VerticesCount)
+}
+
+// toonName は共有トゥーンは番号、個別トゥーンはテクスチャ名で返す。
+func (d *modelDiffer) toonName(modelData *model.PmxModel, material *model.Material) string {
+	if material.ToonSharingFlag == model.TOON_SHARING_SHARING {
+		return fmt.Sprintf("toon%02d", material.ToonTextureIndex+1)
+	}
+	return namedAt(modelData.Textures, material.ToonTextureIndex)
+}
+
+// diffBone はボーンを比較する。
+func (d *modelDiffer) diffBone(before, after *model.Bone, fields *diffFields) {
+	fields.str("EnglishName", before.EnglishName, after.EnglishName)
+	fields.vec3("Position", before.Position, after.Position)
+	fields.str("Parent", boneNameAt(d.before, before.ParentIndex), boneNameAt(d.after, after.ParentIndex))
+	fields.integer("Layer", before.Layer, after.Layer)
+	fields.integer("BoneFlag", int(before.BoneFlag), int(after.BoneFlag))
+	fields.vec3("TailPosition", before.TailPosition, after.TailPosition)
+	fields.str("Tail", boneNameAt(d.before, before.TailIndex), boneNameAt(d.after, after.TailIndex))
+	fields.str("Effect", boneNameAt(d.before, before.EffectIndex), boneNameAt(d.after, after.EffectIndex))
+	fields.float("EffectFactor", before.EffectFactor, after.EffectFactor)
+	fields.vec3("FixedAxis", before.FixedAxis, after.FixedAxis)
+	fields.vec3("LocalAxisX", before.LocalAxisX, after.LocalAxisX)
+	fields.vec3("LocalAxisZ", before.LocalAxisZ, after.LocalAxisZ)
+	fields.integer("EffectorKey", before.EffectorKey, after.EffectorKey)
+	if before.Ik == nil || after.Ik == nil {
+		fields.flag("Ik", before.Ik != nil, after.Ik != nil)
+		return
+	}
+	fields.str("Ik.Target", boneNameAt(d.before, before.Ik.BoneIndex), boneNameAt(d.after, after.Ik.BoneIndex))
+	fields.integer("Ik.LoopCount", before.Ik.LoopCount, after.Ik.LoopCount)
+	fields.vec3("Ik.UnitRotation", before.Ik.UnitRotation, after.Ik.UnitRotation)
+	fields.integer("Ik.Links", len(before.Ik.Links), len(after.Ik.Links))
+	for i := 0; i < min(len(before.Ik.Links), len(after.Ik.Links)); i++ {
+		prefix := fmt.Sprintf("Ik.Links[%d].", i)
+		b, a := before.Ik.Links[i], after.Ik.Links[i]
+		fields.str(prefix+"Bone", boneNameAt(d.before, b.BoneIndex), boneNameAt(d.after, a.BoneIndex))
+		fields.flag(prefix+"AngleLimit", b.AngleLimit, a.AngleLimit)
+		fields.vec3(prefix+"MinAngleLimit", b.MinAngleLimit, a.MinAngleLimit)
+		fields.vec3(prefix+"MaxAngleLimit", b.MaxAngleLimit, a.MaxAngleLimit)
+		fields.flag(prefix+"LocalAngleLimit", b.LocalAngleLimit, a.LocalAngleLimit)
+		fields.vec3(prefix+"LocalMinAngleLimit", b.LocalMinAngleLimit, a.LocalMinAngleLimit)
+		fields.vec3(prefix+"LocalMaxAngleLimit", b.LocalMaxAngleLimit, a.LocalMaxAngleLimit)
+	}
+}
+
+// diffMorph はモーフをオフセットの対象ごとに比較する。
+func (d *modelDiffer) diffMorph(before, after *model.Morph, fields *diffFields) {
+	fields.str("EnglishName", before.EnglishName, after.EnglishName)
+	fields.integer("Panel", int(before.Panel), int(after.Panel))
+	fields.str("MorphType", morphTypeName(before.MorphType), morphTypeName(after.MorphType))
+	beforeOffsets := d.morphOffsets(d.before, before)
+	afterOffsets := d.morphOffsets(d.after, after)
+	keys := make([]string, 0, len(beforeOffsets)+len(afterOffsets))
+	for key := range beforeOffsets {
+		keys = append(keys, key)
+	}
+	for key := range afterOffsets {
+		if _, ok := beforeOffsets[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b, beforeOk := beforeOffsets[key]
+		a, afterOk := afterOffsets[key]
+		field := "Offset[" + key + "]"
+		switch {
+		case !beforeOk:
+			fields.add(field, "", d.formatOffset(a))
+		case !afterOk:
+			fields.add(field, d.formatOffset(b), "")
+		default:
+			d.diffOffset(fields, field, b, a)
+		}
+	}
+}
+
+// morphOffsets はオフセットを対象の名前(頂点は頂点差分と同じ対応付けの名前)をキーにまとめる。
+// 同じ対象へのオフセットが複数ある場合は、2つ目以降に出現順の番号を付けて区別する。
+func (d *modelDiffer) morphOffsets(modelData *model.PmxModel, morph *model.Morph) map[string]model.IMorphOffset {
+	offsets := make(map[string]model.IMorphOffset, len(morph.Offsets))
+	counts := make(map[string]int, len(morph.Offsets))
+	for _, offset := range morph.Offsets {
+		var key string
+		switch o := offset.(type) {
+		case *model.VertexMorphOffset:
+			key = d.vertexKey(modelData, o.VertexIndex)
+		case *model.UvMorphOffset:
+			key = d.vertexKey(modelData, o.VertexIndex)
+		case *model.BoneMorphOffset:
+			key = boneNameAt(modelData, o.BoneIndex)
+		case *model.GroupMorphOffset:
+			key = namedAt(modelData.Morphs, o.MorphIndex)
+		case *model.MaterialMorphOffset:
+			key = "*"
+			if o.MaterialIndex >= 0 {
+				key = namedAt(modelData.Materials, o.MaterialIndex)
+			}
+		default:
+			continue
+		}
+		counts[key]++
+		if counts[key] > 1 {
+			key = fmt.Sprintf("%s:%d", key, counts[key])
+		}
+		offsets[key] = offset
+	}
+	return offsets
+}
+
+// diffOffset はモーフオフセットの値を比較する。
+func (d *modelDiffer) diffOffset(fields *diffFields, field string, before, after model.IMorphOffset) {
+	switch b := before.(type) {
+	case *model.VertexMorphOffset:
+		if a, ok := after.(*model.VertexMorphOffset); ok {
+			fields.vec3(field+".Position", b.Position, a.Position)
+			return
+		}
+	case *model.UvMorphOffset:
+		if a, ok := after.(*model.UvMorphOffset); ok {
+			fields.vec4(field+".Uv", b.Uv, a.Uv)
+			return
+		}
+	case *model.BoneMorphOffset:
+		if a, ok := after.(*model.BoneMorphOffset); ok {
+			fields.vec3(field+".Position", b.Position, a.Position)
+			fields.quat(field+".Rotation", b.Rotation, a.Rotation)
+			return
+		}
+	case *model.GroupMorphOffset:
+		if a, ok := after.(*model.GroupMorphOffset); ok {
+			fields.float(field+".Factor", b.MorphFactor, a.MorphFactor)
+			return
+		}
+	case *model.MaterialMorphOffset:
+		if a, ok := after.(*model.MaterialMorphOffset); ok {
+			fields.integer(field+".CalcMode", int(b.CalcMode), int(a.CalcMode))
+			fields.vec4(field+".Diffuse", b.Diffuse, a.Diffuse)
+			fields.vec4(field+".Specular", b.Specular, a.Specular)
+			fields.vec3(field+".Ambient", b.Ambient, a.Ambient)
+			fields.vec4(field+".Edge", b.Edge, a.Edge)
+			fields.float(field+".EdgeSize", b.EdgeSize, a.EdgeSize)
+			fields.vec4(field+".TextureFactor", b.TextureFactor, a.TextureFactor)
+			fields.vec4(field+".SphereTextureFactor", b.SphereTextureFactor, a.SphereTextureFactor)
+			fields.vec4(field+".ToonTextureFactor", b.ToonTextureFactor, a.ToonTextureFactor)
+			return
+		}
+	}
+	fields.add(field, d.formatOffset(before), d.formatOffset(after))
+}
+
+// formatOffset はモーフオフセットの主な値を文字列にする。
+func (d *modelDiffer) formatOffset(offset model.IMorphOffset) string {
+	switch o := offset.(type) {
+	case *model.VertexMorphOffset:
+		return formatDiffVec3(o.Position)
+	case *model.UvMorphOffset:
+		return formatDiffVec4(o.Uv)
+	case *model.BoneMorphOffset:
+		return formatDiffVec3(o.Position) + " " + formatDiffQuat(o.Rotation)
+	case *model.GroupMorphOffset:
+		return formatDiffFloat(o.MorphFactor)
+	case *model.MaterialMorphOffset:
+		return formatDiffVec4(o.Diffuse)
+	default:
+		return fmt.Sprint(offset)
+	}
+}
+
+// diffDisplaySlot は表示枠を比較する。
+func (d *modelDiffer) diffDisplaySlot(before, after *model.DisplaySlot, fields *diffFields) {
+	fields.str("EnglishName", before.EnglishName, after.EnglishName)
+	fields.integer("SpecialFlag", int(before.SpecialFlag), int(after.SpecialFlag))
+	fields.str("References", d.formatReferences(d.before, before), d.formatReferences(d.after, after))
+}
+
+// formatReferences は表示枠の参照先を名前の並びで表す。
+func (d *modelDiffer) formatReferences(modelData *model.PmxModel, slot *model.DisplaySlot) string {
+	names := make([]string, 0, len(slot.References))
+	for _, reference := range slot.References {
+		if reference.DisplayType == model.DISPLAY_TYPE_MORPH {
+			names = append(names, "morph:"+namedAt(modelData.Morphs, reference.DisplayIndex))
+			continue
+		}
+		names = append(names, boneNameAt(modelData, reference.DisplayIndex))
+	}
+	return strings.Join(names, ",")
+}
+
+// diffRigidBody は剛体を比較する。
+func (d *modelDiffer) diffRigidBody(before, after *model.RigidBody, fields *diffFields) {
+	fields.str("EnglishName", before.EnglishName, after.EnglishName)
+	fields.str("Bone", boneNameAt(d.before, before.BoneIndex), boneNameAt(d.after, after.BoneIndex))
+	fields.integer("CollisionGroup", int(before.CollisionGroup.Group), int(after.CollisionGroup.Group))
+	fields.integer("CollisionMask", int(before.CollisionGroup.Mask), int(after.CollisionGroup.Mask))
+	fields.integer("Shape", int(before.Shape), int(after.Shape))
+	fields.vec3("Size", before.Size, after.Size)
+	fields.vec3("Position", before.Position, after.Position)
+	fields.vec3("Rotation", before.Rotation, after.Rotation)
+	fields.float("Mass", before.Param.Mass, after.Param.Mass)
+	fields.float("LinearDamping", before.Param.LinearDamping, after.Param.LinearDamping)
+	fields.float("AngularDamping", before.Param.AngularDamping, after.Param.AngularDamping)
+	fields.float("Restitution", before.Param.Restitution, after.Param.Restitution)
+	fields.float("Friction", before.Param.Friction, after.Param.Friction)
+	fields.str("PhysicsType", physicsTypeName(before.PhysicsType), physicsTypeName(after.PhysicsType))
+}
+
+// diffJoint はジョイントを比較する。
+func (d *modelDiffer) diffJoint(before, after *model.Joint, fields *diffFields) {
+	fields.str("EnglishName", before.EnglishName, after.EnglishName)
+	fields.str("RigidBodyA", namedAt(d.before.RigidBodies, before.RigidBodyIndexA), namedAt(d.after.RigidBodies, after.RigidBodyIndexA))
+	fields.str("RigidBodyB", namedAt(d.before.RigidBodies, before.RigidBodyIndexB), namedAt(d.after.RigidBodies, after.RigidBodyIndexB))
+	b, a := before.Param, after.Param
+	fields.vec3("Position", b.Position, a.Position)
+	fields.vec3("Rotation", b.Rotation, a.Rotation)
+	fields.vec3("TranslationLimitMin", b.TranslationLimitMin, a.TranslationLimitMin)
+	fields.vec3("TranslationLimitMax", b.TranslationLimitMax, a.TranslationLimitMax)
+	fields.vec3("RotationLimitMin", b.RotationLimitMin, a.RotationLimitMin)
+	fields.vec3("RotationLimitMax", b.RotationLimitMax, a.RotationLimitMax)
+	fields.vec3("SpringConstantTranslation", b.SpringConstantTranslation, a.SpringConstantTranslation)
+	fields.vec3("SpringConstantRotation", b.SpringConstantRotation, a.SpringConstantRotation)
+}
+
+// diffFields は要素1つの項目比較の結果を集める。
+type diffFields struct {
+	tolerance float64
+	changes   []ModelFieldChange
+}
+
+// add は変更を追加する。
+func (f *diffFields) add(field, before, after string) {
+	f.changes = append(f.changes, ModelFieldChange{Field: field, Before: before, After: after})
+}
+
+// str は文字列を比較する。
+func (f *diffFields) str(field, before, after string) {
+	if before != after {
+		f.add(field, before, after)
+	}
+}
+
+// integer は整数を比較する。
+func (f *diffFields) integer(field string, before, after int) {
+	if before != after {
+		f.add(field, strconv.Itoa(before), strconv.Itoa(after))
+	}
+}
+
+// flag は真偽値を比較する。
+func (f *diffFields) flag(field string, before, after bool) {
+	if before != after {
+		f.add(field, strconv.FormatBool(before), strconv.FormatBool(after))
+	}
+}
+
+// float は数値を許容差つきで比較する。
+func (f *diffFields) float(field string, before, after float64) {
+	if math.Abs(before-after) > f.tolerance {
+		f.add(field, formatDiffFloat(before), formatDiffFloat(after))
+	}
+}
+
+// vec2 は2次元ベクトルを許容差つきで比較する。
+func (f *diffFields) vec2(field string, before, after mmath.Vec2) {
+	if math.Abs(before.X-after.X) > f.tolerance || math.Abs(before.Y-after.Y) > f.tolerance {
+		f.add(field, formatDiffFloats(before.X, before.Y), formatDiffFloats(after.X, after.Y))
+	}
+}
+
+// vec3 は3次元ベクトルを許容差つきで比較する。
+func (f *diffFields) vec3(field string, before, after mmath.Vec3) {
+	if math.Abs(before.X-after.X) > f.tolerance ||
+		math.Abs(before.Y-after.Y) > f.tolerance ||
+		math.Abs(before.Z-after.Z) > f.tolerance {
+		f.add(field, formatDiffVec3(before), formatDiffVec3(after))
+	}
+}
+
+// vec4 は4次元ベクトルを許容差つきで比較する。
+func (f *diffFields) vec4(field string, before, after mmath.Vec4) {
+	if math.Abs(before.X-after.X) > f.tolerance ||
+		math.Abs(before.Y-after.Y) > f.tolerance ||
+		math.Abs(before.Z-after.Z) > f.tolerance ||
+		math.Abs(before.W-after.W) > f.tolerance {
+		f.add(field, formatDiffVec4(before), formatDiffVec4(after))
+	}
+}
+
+// quat は回転を比較する。符号だけが異なるクォータニオンは同じ回転とみなす。
+func (f *diffFields) quat(field string, before, after mmath.Quaternion) {
+	if 1-math.Abs(before.Dot(after)) > f.tolerance {
+		f.add(field, formatDiffQuat(before), formatDiffQuat(after))
+	}
+}
+
+// fieldCountKey は集計用に配列の添字と対象名を除いた項目名を返す。
+func fieldCountKey(field string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range field {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// boneNameAt はボーンindexをボーン名へ変換する。存在しない場合は "#index" を返す。
+func boneNameAt(modelData *model.PmxModel, index int) string {
+	if index < 0 {
+		return ""
+	}
+	if bone, err := modelData.Bones.Get(index); err == nil && bone != nil {
+		return bone.Name()
+	}
+	return "#" + strconv.Itoa(index)
+}
+
+// namedAt は名前付きコレクションのindexを名前へ変換する。存在しない場合は "#index" を返す。
+func namedAt[T interface {
+	comparable
+	collection.INameable
+}](values *collection.NamedCollection[T], index int) string {
+	if index < 0 {
+		return ""
+	}
+	var zero T
+	if item, err := values.Get(index); err == nil && item != zero {
+		return item.Name()
+	}
+	return "#" + strconv.Itoa(index)
+}
+
+// vec4At は追加UVの値を返す。存在しない場合はゼロを返す。
+func vec4At(values []mmath.Vec4, index int) mmath.Vec4 {
+	if index < len(values) {
+		return values[index]
+	}
+	return mmath.Vec4{}
+}
+
+// formatDiffFloat は数値を差分表示用の文字列にする。
+func formatDiffFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', 7, 64)
+}
+
+// formatDiffFloats は数値の並びを差分表示用の文字列にする。
+func formatDiffFloats(values ...float64) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = formatDiffFloat(value)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// formatDiffVec3 は3次元ベクトルを差分表示用の文字列にする。
+func formatDiffVec3(v mmath.Vec3) string {
+	return formatDiffFloats(v.X, v.Y, v.Z)
+}
+
+// formatDiffVec4 は4次元ベクトルを差分表示用の文字列にする。
+func formatDiffVec4(v mmath.Vec4) string {
+	return formatDiffFloats(v.X, v.Y, v.Z, v.W)
+}
+
+// formatDiffQuat はクォータニオンを差分表示用の文字列にする。
+func formatDiffQuat(q mmath.Quaternion) string {
+	return formatDiffFloats(q.X(), q.Y(), q.Z(), q.W())
+}
@@ -0,0 +1,154 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+func TestDiffModels(t *testing.T) {
+	before := newAtlasTestModel()
+	appendTestBone(before, "右腕", 0)
+	morph := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX, Offsets: []model.IMorphOffset{
+		&model.VertexMorphOffset{VertexIndex: 0, Position: vec3(0, 1, 0)},
+	}}
+	morph.SetName("あ")
+	before.Morphs.Append(morph)
+	copied, err := before.Copy()
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	after := &copied
+
+	// ウェイト編集のつもりで、頂点1の位置も動いてしまった。
+	vertex, _ := after.Vertices.Get(1)
+	vertex.Deform = model.NewBdef2(0, 1, 0.5)
+	vertex.Position = vec3(1, 0.5, 0)
+	// 許容差未満の変更は差分にしない。
+	vertex, _ = after.Vertices.Get(2)
+	vertex.Position = vec3(2+1e-7, 0, 0)
+	material, _ := after.Materials.GetByName("服")
+	material.Diffuse = mmath.Vec4{X: 1, Y: 0, Z: 0, W: 1}
+	material.TextureIndex = 0
+	afterMorph, _ := after.Morphs.GetByName("あ")
+	afterMorph.Offsets = append(afterMorph.Offsets, &model.VertexMorphOffset{VertexIndex: 3, Position: vec3(0, 0, 1)})
+	added := model.NewMaterial()
+	added.SetName("追加")
+	after.Materials.Append(added)
+
+	diff, err := DiffModels(before, after, ModelDiffOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff.Count(MODEL_DIFF_VERTEX, MODEL_DIFF_MODIFIED) != 1 || diff.Count(MODEL_DIFF_MATERIAL, MODEL_DIFF_ADDED) != 1 {
+		t.Fatalf("entry count mismatch: %+v", diff.Entries)
+	}
+	if diff.FieldCounts["Vertex.Position"] != 1 || diff.FieldCounts["Vertex.Deform"] != 1 {
+		t.Fatalf("unintended position change should be counted: %v", diff.FieldCounts)
+	}
+	if diff.FieldCounts["Material.Texture"] != 1 || diff.FieldCounts["Morph.Offset"] != 1 {
+		t.Fatalf("field counts mismatch: %v", diff.FieldCounts)
+	}
+
+	var text bytes.Buffer
+	if err := diff.WriteText(&text); err != nil {
+		t.Fatalf("WriteText returned error: %v", err)
+	}
+	if !strings.Contains(text.String(), "  Texture: blue.png -> red.png\n") ||
+		!strings.Contains(text.String(), "  Deform: BDEF1 センター:1 -> BDEF2 センター:0.5 右腕:0.5\n") {
+		t.Fatalf("text mismatch:\n%s", text.String())
+	}
+	var decoded ModelDiff
+	var jsonOut bytes.Buffer
+	if err := diff.WriteJSON(&jsonOut); err != nil {
+		t.Fatalf("WriteJSON returned error: %v", err)
+	}
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil || len(decoded.Entries) != len(diff.Entries) {
+		t.Fatalf("json mismatch: %v", err)
+	}
+
+	// 頂点を1つ削除しても、位置で対応付ければ残りの頂点は変更なしとみなす。
+	removed, _ := before.Copy()
+	if _, err := removed.Vertices.Remove(0); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	diff, err = DiffModels(before, &removed, ModelDiffOptions{MatchVerticesByPosition: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff.Count(MODEL_DIFF_VERTEX, MODEL_DIFF_REMOVED) != 1 || diff.Count(MODEL_DIFF_VERTEX, MODEL_DIFF_MODIFIED) != 0 {
+		t.Fatalf("position matching mismatch: %+v", diff.Entries)
+	}
+
+	// 位置で対応付けた場合、モーフの頂点オフセットも同じ対応で比較し、同じ頂点への重複オフセットも区別する。
+	offsets := []model.IMorphOffset{
+		&model.VertexMorphOffset{VertexIndex: 3, Position: vec3(0, 1, 0)},
+		&model.VertexMorphOffset{VertexIndex: 3, Position: vec3(0, 0, 1)},
+		&model.UvMorphOffset{VertexIndex: 5, Uv: mmath.Vec4{X: 0.5}, UvType: model.MORPH_TYPE_UV},
+	}
+	shifted := []model.IMorphOffset{
+		&model.VertexMorphOffset{VertexIndex: 2, Position: vec3(0, 1, 0)},
+		&model.VertexMorphOffset{VertexIndex: 2, Position: vec3(0, 0, 2)},
+		&model.UvMorphOffset{VertexIndex: 4, Uv: mmath.Vec4{X: 0.5}, UvType: model.MORPH_TYPE_UV},
+	}
+	beforeMorph, _ := before.Morphs.GetByName("あ")
+	beforeMorph.Offsets = offsets
+	removedMorph, _ := removed.Morphs.GetByName("あ")
+	removedMorph.Offsets = shifted
+	diff, err = DiffModels(before, &removed, ModelDiffOptions{MatchVerticesByPosition: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var morphChanges []ModelFieldChange
+	for _, entry := range diff.Entries {
+		if entry.Category == MODEL_DIFF_MORPH && entry.Name == "あ" {
+			morphChanges = append(morphChanges, entry.Changes...)
+		}
+	}
+	if len(morphChanges) != 1 || morphChanges[0].Field != "Offset[#2 (#3):2].Position" {
+		t.Fatalf("morph offsets should follow vertex matching: %+v", morphChanges)
+	}
+
+	// 頂点の並べ替えに合わせて面の参照を張り替えただけなら、位置で対応付けた面は変更なしとみなす。
+	swapped, _ := before.Copy()
+	v0, _ := swapped.Vertices.Get(0)
+	v1, _ := swapped.Vertices.Get(1)
+	v0.Position, v1.Position = v1.Position, v0.Position
+	v0.Uv, v1.Uv = v1.Uv, v0.Uv
+	// 丸めの境界をまたぐ程度の誤差は許容差内として対応付ける。
+	v1.Position.X += defaultDiffTolerance * 0.6
+	for _, face := range swapped.Faces.Values() {
+		for k, vertexIndex := range face.VertexIndexes {
+			switch vertexIndex {
+			case 0:
+				face.VertexIndexes[k] = 1
+			case 1:
+				face.VertexIndexes[k] = 0
+			}
+		}
+	}
+	diff, err = DiffModels(before, &swapped, ModelDiffOptions{MatchVerticesByPosition: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff.Count(MODEL_DIFF_FACE, MODEL_DIFF_MODIFIED) != 0 || diff.Count(MODEL_DIFF_VERTEX, MODEL_DIFF_MODIFIED) != 0 {
+		t.Fatalf("faces should be compared through vertex matching: %+v", diff.Entries)
+	}
+	diff, err = DiffModels(before, &swapped, ModelDiffOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff.Count(MODEL_DIFF_FACE, MODEL_DIFF_MODIFIED) == 0 {
+		t.Fatalf("faces should be compared by index without matching: %+v", diff.Entries)
+	}
+
+	if _, err := DiffModels(nil, after, ModelDiffOptions{}); merr.ExtractErrorID(err) != modelNotSpecifiedErrorID {
+		t.Fatalf("expected model not specified error, got %v", err)
+	}
+}
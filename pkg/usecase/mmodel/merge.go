@@ -0,0 +1,598 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"fmt"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+const (
+	modelNotSpecifiedErrorID       = "13505"
+	mergeAttachBoneNotFoundErrorID = "13506"
+)
+
+// MergeBoneMode は付属モデルのボーン統合方法を表す。
+type MergeBoneMode int
+
+const (
+	// MERGE_BONE_MODE_ATTACH は付属モデルの全ボーンを追加し、ルートを接続先ボーンへぶら下げる。
+	MERGE_BONE_MODE_ATTACH MergeBoneMode = iota
+	// MERGE_BONE_MODE_MATCH_NAME は同名ボーンをベース側と共有し、重複ボーンを追加しない。
+	MERGE_BONE_MODE_MATCH_NAME
+)
+
+// MergeElementKind は名称変更した要素の種別を表す。
+type MergeElementKind int
+
+const (
+	// MERGE_ELEMENT_MATERIAL は材質。
+	MERGE_ELEMENT_MATERIAL MergeElementKind = iota
+	// MERGE_ELEMENT_BONE はボーン。
+	MERGE_ELEMENT_BONE
+	// MERGE_ELEMENT_MORPH はモーフ。
+	MERGE_ELEMENT_MORPH
+	// MERGE_ELEMENT_DISPLAY_SLOT は表示枠。
+	MERGE_ELEMENT_DISPLAY_SLOT
+	// MERGE_ELEMENT_RIGID_BODY は剛体。
+	MERGE_ELEMENT_RIGID_BODY
+	// MERGE_ELEMENT_JOINT はジョイント。
+	MERGE_ELEMENT_JOINT
+)
+
+// MergeOptions はモデル統合の設定を表す。
+type MergeOptions struct {
+	BoneMode MergeBoneMode
+	// AttachBoneName は付属モデルのルートボーンを接続するベース側ボーン名。空の場合はルートのまま追加する。
+	AttachBoneName string
+	// NameSuffix は名称衝突時に付与する接尾辞。空の場合は付属モデル名から生成する。
+	NameSuffix string
+	// MergeSameNameMorphs は同名同種のモーフ(グループモーフを含む)のオフセットを統合する。
+	MergeSameNameMorphs bool
+	// DisplaySlotName は付属モデルのルート枠ボーンを登録する表示枠名。空の場合は付属モデル名を使う。
+	DisplaySlotName string
+}
+
+// MergeRename は名称衝突により変更した要素を表す。
+type MergeRename struct {
+	Kind MergeElementKind
+	From string
+	To   string
+}
+
+// MergeResult はモデル統合結果を表す。各Map は付属モデル側 index から統合後 index への対応を持つ。
+type MergeResult struct {
+	VertexOffset   int
+	FaceOffset     int
+	TextureMap     []int
+	MaterialMap    []int
+	BoneMap        []int
+	MorphMap       []int
+	RigidBodyMap   []int
+	JointMap       []int
+	SkippedBones   []string
+	MergedMorphs   []string
+	Renames        []MergeRename
+	AttachBoneName string
+}
+
+// MergeModel は付属モデルの要素を base へ追加し、相互参照を張り替える。
+// base は直接更新され、accessory は変更しない。
+func MergeModel(base, accessory *model.PmxModel, opts MergeOptions) (*MergeResult, error) {
+	if base == nil || accessory == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	attachIndex := -1
+	if opts.AttachBoneName != "" {
+		attachBone, err := base.Bones.GetByName(opts.AttachBoneName)
+		if err != nil || attachBone == nil {
+			return nil, merr.NewCommonError(
+				mergeAttachBoneNotFoundErrorID,
+				merr.ErrorKindValidate,
+				messages.MergeAttachBoneNotFound,
+				err,
+				opts.AttachBoneName,
+			)
+		}
+		attachIndex = attachBone.Index()
+	}
+
+	m := &modelMerger{
+		base:        base,
+		accessory:   accessory,
+		opts:        opts,
+		suffix:      resolveMergeSuffix(accessory, opts.NameSuffix),
+		attachIndex: attachIndex,
+		result: &MergeResult{
+			VertexOffset:   base.Vertices.Len(),
+			FaceOffset:     base.Faces.Len(),
+			AttachBoneName: opts.AttachBoneName,
+		},
+	}
+	m.mergeTextures()
+	m.mergeMaterials()
+	m.mergeBones()
+	m.mergeVertices()
+	m.mergeFaces()
+	m.mergeMorphs()
+	m.mergeDisplaySlots()
+	m.mergeRigidBodies()
+	m.mergeJoints()
+	base.UpdateHash()
+	return m.result, nil
+}
+
+// modelMerger は統合処理中の状態を保持する。
+type modelMerger struct {
+	base        *model.PmxModel
+	accessory   *model.PmxModel
+	opts        MergeOptions
+	suffix      string
+	attachIndex int
+	result      *MergeResult
+	// addedBones は付属モデル側で新規追加したボーン index を保持する。
+	addedBones map[int]struct{}
+	// addedMorphs は付属モデル側で新規追加したモーフ index を保持する。
+	addedMorphs map[int]struct{}
+}
+
+// resolveMergeSuffix は名称衝突時の接尾辞を返す。
+func resolveMergeSuffix(accessory *model.PmxModel, suffix string) string {
+	if suffix != "" {
+		return suffix
+	}
+	if accessory != nil && accessory.Name() != "" {
+		return "_" + accessory.Name()
+	}
+	return "_merged"
+}
+
+// uniqueName は exists が偽になるまで接尾辞と連番を付けた名前を返す。
+func uniqueName(name, suffix string, exists func(string) bool) string {
+	if !exists(name) {
+		return name
+	}
+	candidate := name + suffix
+	for i := 2; exists(candidate); i++ {
+		candidate = fmt.Sprintf("%s%s%d", name, suffix, i)
+	}
+	return candidate
+}
+
+// rename は衝突回避後の名前を記録して返す。
+func (m *modelMerger) rename(kind MergeElementKind, name string, exists func(string) bool) string {
+	resolved := uniqueName(name, m.suffix, exists)
+	if resolved != name {
+		m.result.Renames = append(m.result.Renames, MergeRename{Kind: kind, From: name, To: resolved})
+	}
+	return resolved
+}
+
+// mapIndex は対応表で index を変換する。範囲外は -1 を返す。
+func mapIndex(mapping []int, index int) int {
+	if index < 0 || index >= len(mapping) {
+		return -1
+	}
+	return mapping[index]
+}
+
+// mergeTextures はテクスチャを追加する。同名テクスチャはベース側を再利用する。
+func (m *modelMerger) mergeTextures() {
+	src := m.accessory.Textures.Values()
+	m.result.TextureMap = make([]int, len(src))
+	// 検証前のテクスチャは名前索引に載らないため、名前で直接照合する。
+	existingByName := make(map[string]int, m.base.Textures.Len())
+	for _, texture := range m.base.Textures.Values() {
+		if texture == nil {
+			continue
+		}
+		if _, ok := existingByName[texture.Name()]; !ok {
+			existingByName[texture.Name()] = texture.Index()
+		}
+	}
+	for i, texture := range src {
+		m.result.TextureMap[i] = -1
+		if texture == nil {
+			continue
+		}
+		if idx, ok := existingByName[texture.Name()]; ok {
+			m.result.TextureMap[i] = idx
+			continue
+		}
+		copied := *texture
+		idx := m.base.Textures.AppendRaw(&copied)
+		existingByName[copied.Name()] = idx
+		m.result.TextureMap[i] = idx
+	}
+}
+
+// mergeMaterials は材質を追加し、テクスチャ参照を張り替える。
+func (m *modelMerger) mergeMaterials() {
+	src := m.accessory.Materials.Values()
+	m.result.MaterialMap = make([]int, len(src))
+	for i, material := range src {
+		m.result.MaterialMap[i] = -1
+		if material == nil {
+			continue
+		}
+		copied := *material
+		copied.SetName(m.rename(MERGE_ELEMENT_MATERIAL, material.Name(), func(name string) bool {
+			_, err := m.base.Materials.GetByName(name)
+			return err == nil
+		}))
+		copied.TextureIndex = mapIndex(m.result.TextureMap, material.TextureIndex)
+		copied.SphereTextureIndex = mapIndex(m.result.TextureMap, material.SphereTextureIndex)
+		if material.ToonSharingFlag == model.TOON_SHARING_INDIVIDUAL {
+			copied.ToonTextureIndex = mapIndex(m.result.TextureMap, material.ToonTextureIndex)
+		}
+		idx := m.base.Materials.AppendRaw(&copied)
+		m.result.MaterialMap[i] = idx
+	}
+}
+
+// mergeBones はボーンを追加し、親/表示先/付与/IK参照を張り替える。
+func (m *modelMerger) mergeBones() {
+	src := m.accessory.Bones.Values()
+	m.result.BoneMap = make([]int, m.accessory.Bones.Len())
+	for i := range m.result.BoneMap {
+		m.result.BoneMap[i] = -1
+	}
+	m.addedBones = make(map[int]struct{}, len(src))
+	added := make([]*model.Bone, 0, len(src))
+	sources := make([]*model.Bone, 0, len(src))
+	baseLayer := 0
+	if m.attachIndex >= 0 {
+		if attachBone, err := m.base.Bones.Get(m.attachIndex); err == nil && attachBone != nil {
+			baseLayer = attachBone.Layer
+		}
+	}
+
+	// 1パス目で対応表を確定し、2パス目で参照を張り替える。
+	for _, bone := range src {
+		if bone == nil {
+			continue
+		}
+		if m.opts.BoneMode == MERGE_BONE_MODE_MATCH_NAME {
+			if existing, err := m.base.Bones.GetByName(bone.Name()); err == nil && existing != nil {
+				m.result.BoneMap[bone.Index()] = existing.Index()
+				m.result.SkippedBones = append(m.result.SkippedBones, bone.Name())
+				continue
+			}
+		}
+		copied := *bone
+		copied.SetName(m.rename(MERGE_ELEMENT_BONE, bone.Name(), m.base.Bones.ContainsByName))
+		copied.Ik = copyIk(bone.Ik)
+		if copied.Layer < baseLayer {
+			copied.Layer = baseLayer
+		}
+		idx := m.base.Bones.AppendRaw(&copied)
+		m.result.BoneMap[bone.Index()] = idx
+		m.addedBones[bone.Index()] = struct{}{}
+		added = append(added, &copied)
+		sources = append(sources, bone)
+	}
+
+	for i, copied := range added {
+		bone := sources[i]
+		if bone.ParentIndex < 0 || mapIndex(m.result.BoneMap, bone.ParentIndex) < 0 {
+			copied.ParentIndex = m.attachIndex
+		} else {
+			copied.ParentIndex = mapIndex(m.result.BoneMap, bone.ParentIndex)
+		}
+		if bone.BoneFlag&model.BONE_FLAG_TAIL_IS_BONE != 0 {
+			copied.TailIndex = mapIndex(m.result.BoneMap, bone.TailIndex)
+		}
+		if bone.EffectIndex >= 0 {
+			copied.EffectIndex = mapIndex(m.result.BoneMap, bone.EffectIndex)
+		}
+		if copied.Ik != nil {
+			copied.Ik.BoneIndex = mapIndex(m.result.BoneMap, bone.Ik.BoneIndex)
+			for j := range copied.Ik.Links {
+				copied.Ik.Links[j].BoneIndex = mapIndex(m.result.BoneMap, bone.Ik.Links[j].BoneIndex)
+			}
+		}
+		copied.DisplaySlotIndex = -1
+	}
+}
+
+// copyIk はIK設定を複製する。
+func copyIk(ik *model.Ik) *model.Ik {
+	if ik == nil {
+		return nil
+	}
+	copied := *ik
+	copied.Links = append([]model.IkLink(nil), ik.Links...)
+	return &copied
+}
+
+// mergeVertices は頂点を追加し、デフォームと材質参照を張り替える。
+func (m *modelMerger) mergeVertices() {
+	for _, vertex := range m.accessory.Vertices.Values() {
+		if vertex == nil {
+			continue
+		}
+		copied := *vertex
+		copied.ExtendedUvs = append([]mmath.Vec4(nil), vertex.ExtendedUvs...)
+		copied.Deform = remapDeform(vertex.Deform, m.result.BoneMap)
+		copied.MaterialIndexes = make([]int, 0, len(vertex.MaterialIndexes))
+		for _, materialIndex := range vertex.MaterialIndexes {
+			if mapped := mapIndex(m.result.MaterialMap, materialIndex); mapped >= 0 {
+				copied.MaterialIndexes = append(copied.MaterialIndexes, mapped)
+			}
+		}
+		m.base.Vertices.AppendRaw(&copied)
+	}
+}
+
+// remapDeform はデフォームのボーン index を対応表で張り替えた複製を返す。
+// boneMap が nil の場合は index をそのまま複製する。
+func remapDeform(deform model.IDeform, boneMap []int) model.IDeform {
+	if deform == nil {
+		return nil
+	}
+	indexes := deform.Indexes()
+	weights := deform.Weights()
+	mapped := make([]int, len(indexes))
+	for i, idx := range indexes {
+		if boneMap == nil {
+			mapped[i] = idx
+			continue
+		}
+		mapped[i] = mapIndex(boneMap, idx)
+		if mapped[i] < 0 {
+			mapped[i] = 0
+		}
+	}
+	switch src := deform.(type) {
+	case *model.Bdef1:
+		return model.NewBdef1(mapped[0])
+	case *model.Bdef2:
+		return model.NewBdef2(mapped[0], mapped[1], weights[0])
+	case *model.Bdef4:
+		return model.NewBdef4(
+			[4]int{mapped[0], mapped[1], mapped[2], mapped[3]},
+			[4]float64{weights[0], weights[1], weights[2], weights[3]},
+		)
+	case *model.Sdef:
+		sdef := model.NewSdef(mapped[0], mapped[1], weights[0])
+		sdef.SdefC = src.SdefC
+		sdef.SdefR0 = src.SdefR0
+		sdef.SdefR1 = src.SdefR1
+		return sdef
+	}
+	return deform
+}
+
+// mergeFaces は面を追加し、頂点 index をずらす。
+func (m *modelMerger) mergeFaces() {
+	offset := m.result.VertexOffset
+	for _, face := range m.accessory.Faces.Values() {
+		if face == nil {
+			continue
+		}
+		copied := &model.Face{VertexIndexes: [3]int{
+			face.VertexIndexes[0] + offset,
+			face.VertexIndexes[1] + offset,
+			face.VertexIndexes[2] + offset,
+		}}
+		m.base.Faces.AppendRaw(copied)
+	}
+}
+
+// mergeMorphs はモーフを追加し、オフセット参照を張り替える。
+func (m *modelMerger) mergeMorphs() {
+	src := m.accessory.Morphs.Values()
+	m.result.MorphMap = make([]int, m.accessory.Morphs.Len())
+	for i := range m.result.MorphMap {
+		m.result.MorphMap[i] = -1
+	}
+	m.addedMorphs = make(map[int]struct{}, len(src))
+	targets := make([]*model.Morph, len(src))
+
+	// グループモーフの参照先を確定させるため、先に対応表を作る。
+	for i, morph := range src {
+		if morph == nil {
+			continue
+		}
+		if m.opts.MergeSameNameMorphs {
+			if existing, err := m.base.Morphs.GetByName(morph.Name()); err == nil && existing != nil && existing.MorphType == morph.MorphType {
+				m.result.MorphMap[morph.Index()] = existing.Index()
+				m.result.MergedMorphs = append(m.result.MergedMorphs, morph.Name())
+				targets[i] = existing
+				continue
+			}
+		}
+		copied := *morph
+		copied.SetName(m.rename(MERGE_ELEMENT_MORPH, morph.Name(), func(name string) bool {
+			_, err := m.base.Morphs.GetByName(name)
+			return err == nil
+		}))
+		copied.Offsets = nil
+		idx := m.base.Morphs.AppendRaw(&copied)
+		m.result.MorphMap[morph.Index()] = idx
+		m.addedMorphs[morph.Index()] = struct{}{}
+		targets[i] = &copied
+	}
+
+	for i, morph := range src {
+		if morph == nil || targets[i] == nil {
+			continue
+		}
+		targets[i].Offsets = append(targets[i].Offsets, m.remapMorphOffsets(morph.Offsets)...)
+	}
+}
+
+// remapMorphOffsets はモーフオフセットの参照を統合後の index へ張り替える。
+func (m *modelMerger) remapMorphOffsets(offsets []model.IMorphOffset) []model.IMorphOffset {
+	out := make([]model.IMorphOffset, 0, len(offsets))
+	for _, offset := range offsets {
+		switch o := offset.(type) {
+		case *model.VertexMorphOffset:
+			copied := *o
+			copied.VertexIndex += m.result.VertexOffset
+			out = append(out, &copied)
+		case *model.UvMorphOffset:
+			copied := *o
+			copied.VertexIndex += m.result.VertexOffset
+			out = append(out, &copied)
+		case *model.BoneMorphOffset:
+			copied := *o
+			copied.BoneIndex = mapIndex(m.result.BoneMap, o.BoneIndex)
+			if copied.BoneIndex >= 0 {
+				out = append(out, &copied)
+			}
+		case *model.GroupMorphOffset:
+			copied := *o
+			copied.MorphIndex = mapIndex(m.result.MorphMap, o.MorphIndex)
+			if copied.MorphIndex >= 0 {
+				out = append(out, &copied)
+			}
+		case *model.MaterialMorphOffset:
+			if o.MaterialIndex < 0 {
+				// 全材質対象は付属モデル側の材質だけに展開する。
+				for _, mapped := range m.result.MaterialMap {
+					if mapped < 0 {
+						continue
+					}
+					copied := *o
+					copied.MaterialIndex = mapped
+					out = append(out, &copied)
+				}
+				continue
+			}
+			copied := *o
+			copied.MaterialIndex = mapIndex(m.result.MaterialMap, o.MaterialIndex)
+			if copied.MaterialIndex >= 0 {
+				out = append(out, &copied)
+			}
+		}
+	}
+	return out
+}
+
+// mergeDisplaySlots は追加したボーン/モーフの表示枠を統合する。
+func (m *modelMerger) mergeDisplaySlots() {
+	if m.base.DisplaySlots.Len() == 0 {
+		m.base.CreateDefaultDisplaySlots()
+	}
+	slotName := m.opts.DisplaySlotName
+	if slotName == "" {
+		slotName = m.accessory.Name()
+	}
+	if slotName == "" {
+		slotName = "merged"
+	}
+	for _, slot := range m.accessory.DisplaySlots.Values() {
+		if slot == nil {
+			continue
+		}
+		refs := m.remapReferences(slot.References)
+		if len(refs) == 0 {
+			continue
+		}
+		targetName := slot.Name()
+		if slot.SpecialFlag == model.SPECIAL_FLAG_ON && hasBoneReference(refs) {
+			// ルート枠はベース側のルートと重複させず、付属モデル用の枠へまとめる。
+			targetName = slotName
+		}
+		target, err := m.base.DisplaySlots.GetByName(targetName)
+		if err != nil || target == nil {
+			target = &model.DisplaySlot{SpecialFlag: model.SPECIAL_FLAG_OFF}
+			target.SetName(targetName)
+			target.EnglishName = slot.EnglishName
+			m.base.DisplaySlots.AppendRaw(target)
+		}
+		target.References = append(target.References, refs...)
+		m.assignDisplaySlotIndex(target, refs)
+	}
+}
+
+// remapReferences は新規追加した要素の参照のみを張り替えて返す。
+func (m *modelMerger) remapReferences(refs []model.Reference) []model.Reference {
+	out := make([]model.Reference, 0, len(refs))
+	for _, ref := range refs {
+		switch ref.DisplayType {
+		case model.DISPLAY_TYPE_BONE:
+			if _, ok := m.addedBones[ref.DisplayIndex]; !ok {
+				continue
+			}
+			out = append(out, model.Reference{DisplayType: ref.DisplayType, DisplayIndex: mapIndex(m.result.BoneMap, ref.DisplayIndex)})
+		case model.DISPLAY_TYPE_MORPH:
+			if _, ok := m.addedMorphs[ref.DisplayIndex]; !ok {
+				continue
+			}
+			out = append(out, model.Reference{DisplayType: ref.DisplayType, DisplayIndex: mapIndex(m.result.MorphMap, ref.DisplayIndex)})
+		}
+	}
+	return out
+}
+
+// hasBoneReference はボーン参照を含むか判定する。
+func hasBoneReference(refs []model.Reference) bool {
+	for _, ref := range refs {
+		if ref.DisplayType == model.DISPLAY_TYPE_BONE {
+			return true
+		}
+	}
+	return false
+}
+
+// assignDisplaySlotIndex は参照先要素へ表示枠 index を設定する。
+func (m *modelMerger) assignDisplaySlotIndex(slot *model.DisplaySlot, refs []model.Reference) {
+	for _, ref := range refs {
+		switch ref.DisplayType {
+		case model.DISPLAY_TYPE_BONE:
+			if bone, err := m.base.Bones.Get(ref.DisplayIndex); err == nil && bone != nil {
+				bone.DisplaySlotIndex = slot.Index()
+			}
+		case model.DISPLAY_TYPE_MORPH:
+			if morph, err := m.base.Morphs.Get(ref.DisplayIndex); err == nil && morph != nil {
+				morph.DisplaySlot = slot.Index()
+			}
+		}
+	}
+}
+
+// mergeRigidBodies は剛体を追加し、ボーン参照を張り替える。
+func (m *modelMerger) mergeRigidBodies() {
+	src := m.accessory.RigidBodies.Values()
+	m.result.RigidBodyMap = make([]int, len(src))
+	for i, rigidBody := range src {
+		m.result.RigidBodyMap[i] = -1
+		if rigidBody == nil {
+			continue
+		}
+		copied := *rigidBody
+		copied.SetName(m.rename(MERGE_ELEMENT_RIGID_BODY, rigidBody.Name(), func(name string) bool {
+			_, err := m.base.RigidBodies.GetByName(name)
+			return err == nil
+		}))
+		copied.BoneIndex = mapIndex(m.result.BoneMap, rigidBody.BoneIndex)
+		idx := m.base.RigidBodies.AppendRaw(&copied)
+		m.result.RigidBodyMap[i] = idx
+	}
+}
+
+// mergeJoints はジョイントを追加し、剛体参照を張り替える。
+func (m *modelMerger) mergeJoints() {
+	src := m.accessory.Joints.Values()
+	m.result.JointMap = make([]int, len(src))
+	for i, joint := range src {
+		m.result.JointMap[i] = -1
+		if joint == nil {
+			continue
+		}
+		copied := *joint
+		copied.SetName(m.rename(MERGE_ELEMENT_JOINT, joint.Name(), func(name string) bool {
+			_, err := m.base.Joints.GetByName(name)
+			return err == nil
+		}))
+		copied.RigidBodyIndexA = mapIndex(m.result.RigidBodyMap, joint.RigidBodyIndexA)
+		copied.RigidBodyIndexB = mapIndex(m.result.RigidBodyMap, joint.RigidBodyIndexB)
+		idx := m.base.Joints.AppendRaw(&copied)
+		m.result.JointMap[i] = idx
+	}
+}
@@ -0,0 +1,190 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// vec3 はテスト用のVec3を生成する。
+func vec3(x, y, z float64) mmath.Vec3 {
+	return mmath.Vec3{Vec: r3.Vec{X: x, Y: y, Z: z}}
+}
+
+// appendTestBone はテスト用ボーンを追加する。
+func appendTestBone(m *model.PmxModel, name string, parent int) *model.Bone {
+	bone := &model.Bone{ParentIndex: parent, TailIndex: -1, EffectIndex: -1}
+	bone.SetName(name)
+	bone.BoneFlag = model.BONE_FLAG_CAN_ROTATE | model.BONE_FLAG_IS_VISIBLE
+	m.Bones.Append(bone)
+	return bone
+}
+
+// newMergeBaseModel は統合先モデルを生成する。
+func newMergeBaseModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	m.SetName("base")
+	appendTestBone(m, "センター", -1)
+	appendTestBone(m, "頭", 0)
+	texture := model.NewTexture()
+	texture.SetName("tex.png")
+	m.Textures.Append(texture)
+	material := model.NewMaterial()
+	material.SetName("材質")
+	material.TextureIndex = 0
+	m.Materials.Append(material)
+	for i := 0; i < 3; i++ {
+		m.Vertices.Append(&model.Vertex{Position: vec3(float64(i), 0, 0), Deform: model.NewBdef1(1)})
+	}
+	m.Faces.Append(&model.Face{VertexIndexes: [3]int{0, 1, 2}})
+	morph := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX}
+	morph.SetName("あ")
+	m.Morphs.Append(morph)
+	m.CreateDefaultDisplaySlots()
+	return m
+}
+
+// newMergeAccessoryModel は付属モデルを生成する。
+func newMergeAccessoryModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	m.SetName("hat")
+	appendTestBone(m, "センター", -1)
+	appendTestBone(m, "帽子", 0)
+	texture := model.NewTexture()
+	texture.SetName("tex.png")
+	m.Textures.Append(texture)
+	other := model.NewTexture()
+	other.SetName("hat.png")
+	m.Textures.Append(other)
+	material := model.NewMaterial()
+	material.SetName("材質")
+	material.TextureIndex = 1
+	material.SphereTextureIndex = 0
+	m.Materials.Append(material)
+	for i := 0; i < 3; i++ {
+		m.Vertices.Append(&model.Vertex{Position: vec3(0, float64(i), 0), Deform: model.NewBdef2(1, 0, 0.5)})
+	}
+	m.Faces.Append(&model.Face{VertexIndexes: [3]int{0, 1, 2}})
+	morph := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX}
+	morph.SetName("あ")
+	morph.Offsets = []model.IMorphOffset{&model.VertexMorphOffset{VertexIndex: 2, Position: vec3(0, 1, 0)}}
+	m.Morphs.Append(morph)
+	materialMorph := &model.Morph{MorphType: model.MORPH_TYPE_MATERIAL}
+	materialMorph.SetName("消す")
+	materialMorph.Offsets = []model.IMorphOffset{&model.MaterialMorphOffset{MaterialIndex: -1}}
+	m.Morphs.Append(materialMorph)
+	rigidBody := &model.RigidBody{BoneIndex: 1}
+	rigidBody.SetName("帽子")
+	m.RigidBodies.Append(rigidBody)
+	joint := &model.Joint{RigidBodyIndexA: 0, RigidBodyIndexB: 0}
+	joint.SetName("帽子J")
+	m.Joints.Append(joint)
+	m.CreateDefaultDisplaySlots()
+	root, _ := m.DisplaySlots.Get(0)
+	root.References = append(root.References, model.Reference{DisplayType: model.DISPLAY_TYPE_BONE, DisplayIndex: 0})
+	slot := &model.DisplaySlot{References: []model.Reference{{DisplayType: model.DISPLAY_TYPE_BONE, DisplayIndex: 1}}}
+	slot.SetName("帽子")
+	m.DisplaySlots.Append(slot)
+	return m
+}
+
+// TestMergeModelAttach は接続モードで参照が張り替えられることを確認する。
+func TestMergeModelAttach(t *testing.T) {
+	base := newMergeBaseModel()
+	accessory := newMergeAccessoryModel()
+	result, err := MergeModel(base, accessory, MergeOptions{AttachBoneName: "頭"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if base.Bones.Len() != 4 {
+		t.Fatalf("bone count mismatch: %d", base.Bones.Len())
+	}
+	center, _ := base.Bones.GetByName("センター_hat")
+	if center == nil || center.ParentIndex != 1 {
+		t.Fatalf("accessory root should be attached to 頭: %+v", center)
+	}
+	hat, _ := base.Bones.GetByName("帽子")
+	if hat.ParentIndex != center.Index() {
+		t.Fatalf("parent mismatch: %d", hat.ParentIndex)
+	}
+	if base.Textures.Len() != 2 || result.TextureMap[0] != 0 || result.TextureMap[1] != 1 {
+		t.Fatalf("texture dedupe mismatch: %v", result.TextureMap)
+	}
+	material, _ := base.Materials.Get(1)
+	if material.Name() != "材質_hat" || material.TextureIndex != 1 || material.SphereTextureIndex != 0 {
+		t.Fatalf("material remap mismatch: %s %d %d", material.Name(), material.TextureIndex, material.SphereTextureIndex)
+	}
+	vertex, _ := base.Vertices.Get(3)
+	if idx := vertex.Deform.Indexes(); idx[0] != hat.Index() || idx[1] != center.Index() {
+		t.Fatalf("deform remap mismatch: %v", idx)
+	}
+	face, _ := base.Faces.Get(1)
+	if face.VertexIndexes != [3]int{3, 4, 5} {
+		t.Fatalf("face offset mismatch: %v", face.VertexIndexes)
+	}
+	morph, _ := base.Morphs.GetByName("あ_hat")
+	if morph == nil || morph.Offsets[0].(*model.VertexMorphOffset).VertexIndex != 5 {
+		t.Fatalf("vertex morph remap mismatch")
+	}
+	materialMorph, _ := base.Morphs.GetByName("消す")
+	if len(materialMorph.Offsets) != 1 || materialMorph.Offsets[0].(*model.MaterialMorphOffset).MaterialIndex != 1 {
+		t.Fatalf("material morph should target only accessory materials")
+	}
+	rigidBody, _ := base.RigidBodies.Get(0)
+	joint, _ := base.Joints.Get(0)
+	if rigidBody.BoneIndex != hat.Index() || joint.RigidBodyIndexA != 0 {
+		t.Fatalf("physics remap mismatch")
+	}
+	slot, _ := base.DisplaySlots.GetByName("hat")
+	if slot == nil || len(slot.References) != 1 || slot.References[0].DisplayIndex != center.Index() {
+		t.Fatalf("root display slot should be redirected")
+	}
+	if hat.DisplaySlotIndex < 0 {
+		t.Fatalf("display slot index not assigned")
+	}
+	if accessory.Bones.Len() != 2 || accessory.Materials.Len() != 1 {
+		t.Fatalf("accessory should not be modified")
+	}
+}
+
+// TestMergeModelMatchName は同名ボーンと同名モーフが共有されることを確認する。
+func TestMergeModelMatchName(t *testing.T) {
+	base := newMergeBaseModel()
+	accessory := newMergeAccessoryModel()
+	result, err := MergeModel(base, accessory, MergeOptions{
+		BoneMode:            MERGE_BONE_MODE_MATCH_NAME,
+		AttachBoneName:      "頭",
+		MergeSameNameMorphs: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if base.Bones.Len() != 3 || result.BoneMap[0] != 0 {
+		t.Fatalf("same name bone should be shared: %v", result.BoneMap)
+	}
+	hat, _ := base.Bones.GetByName("帽子")
+	if hat.ParentIndex != 0 {
+		t.Fatalf("hat parent should be base center: %d", hat.ParentIndex)
+	}
+	morph, _ := base.Morphs.GetByName("あ")
+	if len(morph.Offsets) != 1 || base.Morphs.Len() != 2 {
+		t.Fatalf("same name morph should be merged")
+	}
+}
+
+// TestMergeModelAttachBoneNotFound は接続先ボーン不在時にエラーになることを確認する。
+func TestMergeModelAttachBoneNotFound(t *testing.T) {
+	base := newMergeBaseModel()
+	_, err := MergeModel(base, newMergeAccessoryModel(), MergeOptions{AttachBoneName: "存在しない"})
+	if merr.ExtractErrorID(err) != mergeAttachBoneNotFoundErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if base.Bones.Len() != 2 {
+		t.Fatalf("base should not be modified on error")
+	}
+}
@@ -0,0 +1,649 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"math"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/collection"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+const (
+	extendedUvIndexInvalidErrorID = "13512"
+	// maxExtendedUvCount はPMXで扱える追加UV数。
+	maxExtendedUvCount = 4
+)
+
+// NormalOptions は法線再計算の設定を表す。
+type NormalOptions struct {
+	// SmoothingAngle はスムージングする面法線の最大角度(ラジアン)。0 以下は同一頂点を共有する面のみで平均する。
+	SmoothingAngle float64
+	// Tolerance は同一位置とみなす距離。0 の場合は1e-5。
+	Tolerance float64
+}
+
+// WeldOptions は頂点結合の設定を表す。
+type WeldOptions struct {
+	// Tolerance は同一位置とみなす距離。0 の場合は1e-5。
+	Tolerance float64
+	// UvTolerance はUVの一致判定距離。負の場合はUVを無視して結合する。
+	UvTolerance float64
+	// NormalAngle は法線の一致判定角度(ラジアン)。0 以下は法線を無視する。
+	NormalAngle float64
+}
+
+// SplitOptions は頂点分割の設定を表す。
+type SplitOptions struct {
+	// MaterialSeams は異なる材質の面で共有されている頂点を材質ごとに分割する。
+	MaterialSeams bool
+	// HardEdgeAngle はこの角度(ラジアン)を超えて折れている面同士で頂点を分割する。0 以下は無効。
+	HardEdgeAngle float64
+	// FaceUvs は面ごとの角のUV(面の VertexIndexes と同順)。指定すると、同じ頂点を参照しながら
+	// 異なるUVを持つ面同士をUVの継ぎ目として分割し、各頂点へ面側のUVを設定する。
+	// 面数より短い場合、足りない面は頂点のUVを使う。
+	FaceUvs [][3]mmath.Vec2
+	// UvTolerance は FaceUvs の一致判定距離。0 の場合は1e-5。
+	UvTolerance float64
+}
+
+// TangentOptions は接線生成の設定を表す。
+type TangentOptions struct {
+	// MaterialIndexes は対象材質。空の場合は全材質。
+	MaterialIndexes []int
+	// ExtendedUvIndex は接線を書き込む追加UV番号(0-3)。負の場合は書き込まない。
+	ExtendedUvIndex int
+}
+
+// RecomputeNormals は面から頂点法線を再計算する。
+// 同一位置の頂点(UV分割済み頂点など)はスムージング角度内であれば同じ法線になる。
+func RecomputeNormals(modelData *model.PmxModel, opts NormalOptions) error {
+	if modelData == nil {
+		return merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	faceNormals := computeFaceNormals(modelData)
+	vertexFaces := buildVertexFaces(modelData)
+	groups := groupVerticesByPosition(modelData, opts.Tolerance)
+	cosThreshold := math.Cos(opts.SmoothingAngle)
+
+	for _, vertex := range modelData.Vertices.Values() {
+		if vertex == nil {
+			continue
+		}
+		own := mmath.NewVec3()
+		for _, faceIndex := range vertexFaces[vertex.Index()] {
+			own = own.Added(faceNormals[faceIndex])
+		}
+		if own.IsZero() {
+			continue
+		}
+		if opts.SmoothingAngle <= 0 {
+			vertex.Normal = own.Normalized()
+			continue
+		}
+		ownDir := own.Normalized()
+		accumulated := mmath.NewVec3()
+		visited := make(map[int]struct{})
+		for _, sibling := range groups[vertex.Index()] {
+			for _, faceIndex := range vertexFaces[sibling] {
+				if _, ok := visited[faceIndex]; ok {
+					continue
+				}
+				visited[faceIndex] = struct{}{}
+				faceNormal := faceNormals[faceIndex]
+				if faceNormal.IsZero() || faceNormal.Normalized().Dot(ownDir) < cosThreshold {
+					continue
+				}
+				accumulated = accumulated.Added(faceNormal)
+			}
+		}
+		if accumulated.IsZero() {
+			accumulated = own
+		}
+		vertex.Normal = accumulated.Normalized()
+	}
+	modelData.UpdateHash()
+	return nil
+}
+
+// WeldVertices は同一とみなせる頂点を結合し、結合で削除した頂点数を返す。
+func WeldVertices(modelData *model.PmxModel, opts WeldOptions) (int, error) {
+	if modelData == nil {
+		return 0, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	vertices := modelData.Vertices.Values()
+	groups := groupVerticesByPosition(modelData, opts.Tolerance)
+	cosNormal := math.Cos(opts.NormalAngle)
+
+	survivor := make([]int, len(vertices))
+	for i := range survivor {
+		survivor[i] = -1
+	}
+	merged := make(map[int][]int)
+	for i, vertex := range vertices {
+		if vertex == nil || survivor[i] >= 0 {
+			continue
+		}
+		survivor[i] = i
+		for _, j := range groups[i] {
+			if j <= i || survivor[j] >= 0 || vertices[j] == nil {
+				continue
+			}
+			if opts.UvTolerance >= 0 && !nearVec2(vertex.Uv, vertices[j].Uv, opts.UvTolerance) {
+				continue
+			}
+			if opts.NormalAngle > 0 && vertex.Normal.Normalized().Dot(vertices[j].Normal.Normalized()) < cosNormal {
+				continue
+			}
+			survivor[j] = i
+			merged[i] = append(merged[i], j)
+		}
+	}
+
+	for keep, others := range merged {
+		mergeVertexAttributes(vertices[keep], vertices, others)
+	}
+
+	oldToNew := make([]int, len(vertices))
+	next := 0
+	for i := range vertices {
+		if survivor[i] == i {
+			oldToNew[i] = next
+			next++
+		} else {
+			oldToNew[i] = -1
+		}
+	}
+	for i := range vertices {
+		if survivor[i] >= 0 && survivor[i] != i {
+			oldToNew[i] = oldToNew[survivor[i]]
+		}
+	}
+	removed := len(vertices) - next
+	if removed > 0 {
+		rebuildVertices(modelData, oldToNew, survivor)
+	}
+	return removed, nil
+}
+
+// SplitVertices は材質境界・UVの継ぎ目・折れ角の大きい辺で共有頂点を複製し、追加した頂点数を返す。
+func SplitVertices(modelData *model.PmxModel, opts SplitOptions) (int, error) {
+	if modelData == nil {
+		return 0, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	faceMaterials := buildFaceMaterials(modelData)
+	faceNormals := computeFaceNormals(modelData)
+	vertexFaces := buildVertexFaces(modelData)
+	cosHard := math.Cos(opts.HardEdgeAngle)
+	uvSeams := len(opts.FaceUvs) > 0
+	uvTolerance := opts.UvTolerance
+	if uvTolerance <= 0 {
+		uvTolerance = 1e-5
+	}
+	originalCount := modelData.Vertices.Len()
+	copies := make(map[int][]int)
+
+	for vertexIndex := 0; vertexIndex < originalCount; vertexIndex++ {
+		faces := vertexFaces[vertexIndex]
+		original, _ := modelData.Vertices.Get(vertexIndex)
+		if len(faces) == 0 {
+			continue
+		}
+		// 面を連結成分(同材質・同UVかつ折れ角内)へまとめ、先頭以外の成分へ頂点を複製する。
+		clusters := make([][]int, 0, 2)
+		for _, faceIndex := range faces {
+			placed := false
+			for c, cluster := range clusters {
+				ref := cluster[0]
+				if opts.MaterialSeams && faceMaterials[ref] != faceMaterials[faceIndex] {
+					continue
+				}
+				if uvSeams && !nearVec2(
+					faceCornerUv(modelData, opts.FaceUvs, ref, original),
+					faceCornerUv(modelData, opts.FaceUvs, faceIndex, original),
+					uvTolerance,
+				) {
+					continue
+				}
+				if opts.HardEdgeAngle > 0 && !faceNormals[ref].IsZero() && !faceNormals[faceIndex].IsZero() &&
+					faceNormals[ref].Normalized().Dot(faceNormals[faceIndex].Normalized()) < cosHard {
+					continue
+				}
+				clusters[c] = append(clusters[c], faceIndex)
+				placed = true
+				break
+			}
+			if !placed {
+				clusters = append(clusters, []int{faceIndex})
+			}
+		}
+		if uvSeams {
+			original.Uv = faceCornerUv(modelData, opts.FaceUvs, clusters[0][0], original)
+		}
+		for _, cluster := range clusters[1:] {
+			copied := copyVertex(original)
+			if uvSeams {
+				copied.Uv = faceCornerUv(modelData, opts.FaceUvs, cluster[0], original)
+			}
+			newIndex := modelData.Vertices.AppendRaw(copied)
+			copies[vertexIndex] = append(copies[vertexIndex], newIndex)
+			for _, faceIndex := range cluster {
+				face, _ := modelData.Faces.Get(faceIndex)
+				for k := range face.VertexIndexes {
+					if face.VertexIndexes[k] == vertexIndex {
+						face.VertexIndexes[k] = newIndex
+					}
+				}
+			}
+		}
+	}
+
+	if len(copies) > 0 {
+		duplicateVertexMorphOffsets(modelData, copies)
+	}
+	modelData.UpdateHash()
+	return modelData.Vertices.Len() - originalCount, nil
+}
+
+// faceCornerUv は面の角としての頂点UVを返す。FaceUvs に無い面は頂点のUVを返す。
+func faceCornerUv(modelData *model.PmxModel, faceUvs [][3]mmath.Vec2, faceIndex int, vertex *model.Vertex) mmath.Vec2 {
+	if faceIndex < len(faceUvs) {
+		if face, err := modelData.Faces.Get(faceIndex); err == nil && face != nil {
+			for k, vertexIndex := range face.VertexIndexes {
+				if vertexIndex == vertex.Index() {
+					return faceUvs[faceIndex][k]
+				}
+			}
+		}
+	}
+	return vertex.Uv
+}
+
+// RemoveUnusedVertices は面から参照されない頂点を削除し、削除数を返す。
+func RemoveUnusedVertices(modelData *model.PmxModel) (int, error) {
+	if modelData == nil {
+		return 0, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	used := make([]bool, modelData.Vertices.Len())
+	for _, face := range modelData.Faces.Values() {
+		if face == nil {
+			continue
+		}
+		for _, vertexIndex := range face.VertexIndexes {
+			if vertexIndex >= 0 && vertexIndex < len(used) {
+				used[vertexIndex] = true
+			}
+		}
+	}
+	oldToNew := make([]int, len(used))
+	survivor := make([]int, len(used))
+	next := 0
+	for i, ok := range used {
+		if ok {
+			oldToNew[i] = next
+			survivor[i] = i
+			next++
+		} else {
+			oldToNew[i] = -1
+			survivor[i] = -1
+		}
+	}
+	removed := len(used) - next
+	if removed > 0 {
+		rebuildVertices(modelData, oldToNew, survivor)
+	}
+	return removed, nil
+}
+
+// GenerateTangents は対象材質の頂点接線(xyz + 従法線の向きw)を生成して返す。
+// 対象外頂点の接線はゼロとなる。
+func GenerateTangents(modelData *model.PmxModel, opts TangentOptions) ([]mmath.Vec4, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if opts.ExtendedUvIndex >= maxExtendedUvCount {
+		return nil, merr.NewCommonError(
+			extendedUvIndexInvalidErrorID,
+			merr.ErrorKindValidate,
+			messages.ExtendedUvIndexInvalid,
+			nil,
+			opts.ExtendedUvIndex,
+		)
+	}
+	targetMaterials := make(map[int]struct{}, len(opts.MaterialIndexes))
+	for _, materialIndex := range opts.MaterialIndexes {
+		targetMaterials[materialIndex] = struct{}{}
+	}
+
+	vertexCount := modelData.Vertices.Len()
+	tangents := make([]mmath.Vec3, vertexCount)
+	bitangents := make([]mmath.Vec3, vertexCount)
+	touched := make([]bool, vertexCount)
+	faceMaterials := buildFaceMaterials(modelData)
+	for faceIndex, face := range modelData.Faces.Values() {
+		if face == nil {
+			continue
+		}
+		if len(targetMaterials) > 0 {
+			if _, ok := targetMaterials[faceMaterials[faceIndex]]; !ok {
+				continue
+			}
+		}
+		v0, err0 := modelData.Vertices.Get(face.VertexIndexes[0])
+		v1, err1 := modelData.Vertices.Get(face.VertexIndexes[1])
+		v2, err2 := modelData.Vertices.Get(face.VertexIndexes[2])
+		if err0 != nil || err1 != nil || err2 != nil {
+			continue
+		}
+		edge1 := v1.Position.Subed(v0.Position)
+		edge2 := v2.Position.Subed(v0.Position)
+		du1, dv1 := v1.Uv.X-v0.Uv.X, v1.Uv.Y-v0.Uv.Y
+		du2, dv2 := v2.Uv.X-v0.Uv.X, v2.Uv.Y-v0.Uv.Y
+		det := du1*dv2 - du2*dv1
+		if math.Abs(det) < 1e-12 {
+			continue
+		}
+		r := 1 / det
+		tangent := edge1.MuledScalar(dv2 * r).Subed(edge2.MuledScalar(dv1 * r))
+		bitangent := edge2.MuledScalar(du1 * r).Subed(edge1.MuledScalar(du2 * r))
+		for _, vertexIndex := range face.VertexIndexes {
+			tangents[vertexIndex] = tangents[vertexIndex].Added(tangent)
+			bitangents[vertexIndex] = bitangents[vertexIndex].Added(bitangent)
+			touched[vertexIndex] = true
+		}
+	}
+
+	out := make([]mmath.Vec4, vertexCount)
+	for i, vertex := range modelData.Vertices.Values() {
+		if vertex == nil || !touched[i] {
+			continue
+		}
+		normal := vertex.Normal.Normalized()
+		// Gram-Schmidt で法線と直交化する。
+		tangent := tangents[i].Subed(normal.MuledScalar(normal.Dot(tangents[i])))
+		if tangent.IsZero() {
+			tangent = orthogonalVec3(normal)
+		}
+		tangent = tangent.Normalized()
+		w := 1.0
+		if normal.Cross(tangent).Dot(bitangents[i]) < 0 {
+			w = -1.0
+		}
+		out[i] = mmath.Vec4{X: tangent.X, Y: tangent.Y, Z: tangent.Z, W: w}
+		if opts.ExtendedUvIndex >= 0 {
+			for len(vertex.ExtendedUvs) <= opts.ExtendedUvIndex {
+				vertex.ExtendedUvs = append(vertex.ExtendedUvs, mmath.Vec4{})
+			}
+			vertex.ExtendedUvs[opts.ExtendedUvIndex] = out[i]
+		}
+	}
+	if opts.ExtendedUvIndex >= 0 {
+		modelData.UpdateHash()
+	}
+	return out, nil
+}
+
+// computeFaceNormals は面積で重み付けされた面法線を返す。
+func computeFaceNormals(modelData *model.PmxModel) []mmath.Vec3 {
+	normals := make([]mmath.Vec3, modelData.Faces.Len())
+	for i, face := range modelData.Faces.Values() {
+		if face == nil {
+			continue
+		}
+		v0, err0 := modelData.Vertices.Get(face.VertexIndexes[0])
+		v1, err1 := modelData.Vertices.Get(face.VertexIndexes[1])
+		v2, err2 := modelData.Vertices.Get(face.VertexIndexes[2])
+		if err0 != nil || err1 != nil || err2 != nil {
+			continue
+		}
+		normals[i] = v1.Position.Subed(v0.Position).Cross(v2.Position.Subed(v0.Position))
+	}
+	return normals
+}
+
+// buildVertexFaces は頂点ごとの参照面 index を返す。
+func buildVertexFaces(modelData *model.PmxModel) [][]int {
+	vertexFaces := make([][]int, modelData.Vertices.Len())
+	for i, face := range modelData.Faces.Values() {
+		if face == nil {
+			continue
+		}
+		for k, vertexIndex := range face.VertexIndexes {
+			if vertexIndex < 0 || vertexIndex >= len(vertexFaces) {
+				continue
+			}
+			// 縮退面で同じ頂点が重複する場合は1回だけ登録する。
+			if (k > 0 && face.VertexIndexes[0] == vertexIndex) || (k > 1 && face.VertexIndexes[1] == vertexIndex) {
+				continue
+			}
+			vertexFaces[vertexIndex] = append(vertexFaces[vertexIndex], i)
+		}
+	}
+	return vertexFaces
+}
+
+// buildFaceMaterials は面ごとの材質 index を返す。
+func buildFaceMaterials(modelData *model.PmxModel) []int {
+	faceMaterials := make([]int, modelData.Faces.Len())
+	for i := range faceMaterials {
+		faceMaterials[i] = -1
+	}
+	offset := 0
+	for _, material := range modelData.Materials.Values() {
+		if material == nil {
+			continue
+		}
+		count := material.VerticesCount / 3
+		for i := offset; i < offset+count && i < len(faceMaterials); i++ {
+			faceMaterials[i] = material.Index()
+		}
+		offset += count
+	}
+	return faceMaterials
+}
+
+// groupVerticesByPosition は頂点ごとに同一位置(自身を含む)の頂点 index を返す。
+func groupVerticesByPosition(modelData *model.PmxModel, tolerance float64) [][]int {
+	if tolerance <= 0 {
+		tolerance = 1e-5
+	}
+	vertices := modelData.Vertices.Values()
+	grid := newVertexPositionGrid(vertices, tolerance)
+
+	groups := make([][]int, len(vertices))
+	for i, vertex := range vertices {
+		if vertex == nil {
+			continue
+		}
+		// 境界付近の頂点を取りこぼさないよう隣接セルも探索する。
+		grid.each(vertex.Position, func(j int) {
+			if vertex.Position.Distance(vertices[j].Position) <= tolerance {
+				groups[i] = append(groups[i], j)
+			}
+		})
+	}
+	return groups
+}
+
+// nearVec2 は2次元ベクトルが許容差内か判定する。
+func nearVec2(a, b mmath.Vec2, tolerance float64) bool {
+	return math.Abs(a.X-b.X) <= tolerance && math.Abs(a.Y-b.Y) <= tolerance
+}
+
+// mergeVertexAttributes は結合する頂点のデフォーム・法線・材質参照を keep へ集約する。
+func mergeVertexAttributes(keep *model.Vertex, vertices []*model.Vertex, others []int) {
+	weights := make(map[int]float64)
+	addWeights := func(vertex *model.Vertex) {
+		if vertex.Deform == nil {
+			return
+		}
+		values := vertex.Deform.Weights()
+		for i, boneIndex := range vertex.Deform.Indexes() {
+			if i < len(values) && boneIndex >= 0 {
+				weights[boneIndex] += values[i]
+			}
+		}
+	}
+	sameDeform := true
+	normal := keep.Normal
+	addWeights(keep)
+	for _, other := range others {
+		vertex := vertices[other]
+		if !sameVertexDeform(keep.Deform, vertex.Deform) {
+			sameDeform = false
+		}
+		addWeights(vertex)
+		normal = normal.Added(vertex.Normal)
+		for _, materialIndex := range vertex.MaterialIndexes {
+			if !containsInt(keep.MaterialIndexes, materialIndex) {
+				keep.MaterialIndexes = append(keep.MaterialIndexes, materialIndex)
+			}
+		}
+	}
+	if !normal.IsZero() {
+		keep.Normal = normal.Normalized()
+	}
+	// SDEF 等を保つため、デフォームが全て同じ場合は元のまま残す。
+	if !sameDeform && len(weights) > 0 {
+		applyBoneWeights(keep, weights, 4, 0)
+	}
+}
+
+// sameVertexDeform はデフォームのボーンとウェイトが一致するか判定する。
+func sameVertexDeform(a, b model.IDeform) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.DeformType() != b.DeformType() {
+		return false
+	}
+	ai, bi := a.Indexes(), b.Indexes()
+	aw, bw := a.Weights(), b.Weights()
+	if len(ai) != len(bi) || len(aw) != len(bw) {
+		return false
+	}
+	for i := range ai {
+		if ai[i] != bi[i] {
+			return false
+		}
+	}
+	for i := range aw {
+		if math.Abs(aw[i]-bw[i]) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+// containsInt は values に v が含まれるか判定する。
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// copyVertex は頂点を複製する。
+func copyVertex(vertex *model.Vertex) *model.Vertex {
+	copied := *vertex
+	copied.ExtendedUvs = append([]mmath.Vec4(nil), vertex.ExtendedUvs...)
+	copied.MaterialIndexes = append([]int(nil), vertex.MaterialIndexes...)
+	copied.Deform = remapDeform(vertex.Deform, nil)
+	return &copied
+}
+
+// rebuildVertices は oldToNew に従って頂点を詰め直し、面とモーフの参照を張り替える。
+// survivor[i] == i の頂点だけを残し、それ以外は削除または結合済みとして扱う。
+func rebuildVertices(modelData *model.PmxModel, oldToNew, survivor []int) {
+	vertices := modelData.Vertices.Values()
+	rebuilt := collection.NewIndexedCollection[*model.Vertex](len(vertices))
+	for i, vertex := range vertices {
+		if survivor[i] == i {
+			rebuilt.AppendRaw(vertex)
+		}
+	}
+	modelData.Vertices = rebuilt
+
+	for _, face := range modelData.Faces.Values() {
+		if face == nil {
+			continue
+		}
+		for k, vertexIndex := range face.VertexIndexes {
+			face.VertexIndexes[k] = mapIndex(oldToNew, vertexIndex)
+		}
+	}
+
+	for _, morph := range modelData.Morphs.Values() {
+		if morph == nil {
+			continue
+		}
+		seen := make(map[int]struct{}, len(morph.Offsets))
+		offsets := morph.Offsets[:0]
+		for _, offset := range morph.Offsets {
+			switch o := offset.(type) {
+			case *model.VertexMorphOffset:
+				o.VertexIndex = mapIndex(oldToNew, o.VertexIndex)
+				if o.VertexIndex < 0 {
+					continue
+				}
+				// 結合で同じ頂点を指すオフセットは先頭のみ残す。
+				if _, ok := seen[o.VertexIndex]; ok {
+					continue
+				}
+				seen[o.VertexIndex] = struct{}{}
+			case *model.UvMorphOffset:
+				o.VertexIndex = mapIndex(oldToNew, o.VertexIndex)
+				if o.VertexIndex < 0 {
+					continue
+				}
+				if _, ok := seen[o.VertexIndex]; ok {
+					continue
+				}
+				seen[o.VertexIndex] = struct{}{}
+			}
+			offsets = append(offsets, offset)
+		}
+		morph.Offsets = offsets
+	}
+	modelData.UpdateHash()
+}
+
+// duplicateVertexMorphOffsets は複製した頂点へ元頂点の頂点/UVモーフオフセットを複製する。
+func duplicateVertexMorphOffsets(modelData *model.PmxModel, copies map[int][]int) {
+	for _, morph := range modelData.Morphs.Values() {
+		if morph == nil {
+			continue
+		}
+		var added []model.IMorphOffset
+		for _, offset := range morph.Offsets {
+			switch o := offset.(type) {
+			case *model.VertexMorphOffset:
+				for _, newIndex := range copies[o.VertexIndex] {
+					copied := *o
+					copied.VertexIndex = newIndex
+					added = append(added, &copied)
+				}
+			case *model.UvMorphOffset:
+				for _, newIndex := range copies[o.VertexIndex] {
+					copied := *o
+					copied.VertexIndex = newIndex
+					added = append(added, &copied)
+				}
+			}
+		}
+		morph.Offsets = append(morph.Offsets, added...)
+	}
+}
+
+// orthogonalVec3 は v に直交する単位ベクトルを返す。
+func orthogonalVec3(v mmath.Vec3) mmath.Vec3 {
+	axis := mmath.UNIT_X_VEC3
+	if math.Abs(v.X) > 0.9 {
+		axis = mmath.UNIT_Y_VEC3
+	}
+	return v.Cross(axis).Normalized()
+}
@@ -0,0 +1,185 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// newFoldedMeshModel はX軸で90度折れた2枚の四角形(各2面)を、折れ目の頂点を分けて持つモデルを生成する。
+func newFoldedMeshModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	appendTestBone(m, "センター", -1)
+	appendTestBone(m, "下半身", 0)
+	positions := []mmath.Vec3{
+		// 床面(y=0, 法線+Y)
+		vec3(0, 0, 0), vec3(0, 0, 1), vec3(1, 0, 0), vec3(1, 0, 1),
+		// 壁面(x=1, 法線-X)。折れ目の2頂点は床面と同位置の別頂点。
+		vec3(1, 0, 0), vec3(1, 0, 1), vec3(1, 1, 0), vec3(1, 1, 1),
+	}
+	uvs := []mmath.Vec2{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 0}, {X: 1, Y: 1}}
+	for i, p := range positions {
+		m.Vertices.Append(&model.Vertex{Position: p, Uv: uvs[i], Deform: model.NewBdef1(i / 4)})
+	}
+	m.Faces.Append(&model.Face{VertexIndexes: [3]int{0, 1, 2}})
+	m.Faces.Append(&model.Face{VertexIndexes: [3]int{2, 1, 3}})
+	m.Faces.Append(&model.Face{VertexIndexes: [3]int{4, 5, 6}})
+	m.Faces.Append(&model.Face{VertexIndexes: [3]int{6, 5, 7}})
+	floor := model.NewMaterial()
+	floor.SetName("床")
+	floor.VerticesCount = 6
+	m.Materials.Append(floor)
+	wall := model.NewMaterial()
+	wall.SetName("壁")
+	wall.VerticesCount = 6
+	m.Materials.Append(wall)
+	morph := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX}
+	morph.SetName("折れ")
+	morph.Offsets = []model.IMorphOffset{
+		&model.VertexMorphOffset{VertexIndex: 2, Position: vec3(0, 1, 0)},
+		&model.VertexMorphOffset{VertexIndex: 4, Position: vec3(0, 1, 0)},
+		&model.VertexMorphOffset{VertexIndex: 7, Position: vec3(0, 1, 0)},
+	}
+	m.Morphs.Append(morph)
+	return m
+}
+
+// TestRecomputeNormalsSmoothingAngle はスムージング角度で折れ目の法線が変わることを確認する。
+func TestRecomputeNormalsSmoothingAngle(t *testing.T) {
+	m := newFoldedMeshModel()
+	if err := RecomputeNormals(m, NormalOptions{SmoothingAngle: mmath.DegToRad(30)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	seam, _ := m.Vertices.Get(2)
+	if !seam.Normal.NearEquals(vec3(0, 1, 0), 1e-6) {
+		t.Fatalf("hard edge normal mismatch: %v", seam.Normal)
+	}
+
+	if err := RecomputeNormals(m, NormalOptions{SmoothingAngle: mmath.DegToRad(100)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	seam, _ = m.Vertices.Get(2)
+	wallSeam, _ := m.Vertices.Get(4)
+	if seam.Normal.Y <= 0 || seam.Normal.X >= 0 || !seam.Normal.NearEquals(wallSeam.Normal, 1e-6) {
+		t.Fatalf("smooth normal mismatch: %v %v", seam.Normal, wallSeam.Normal)
+	}
+}
+
+// TestWeldAndSplitVertices は結合と分割で面・デフォーム・モーフが整合することを確認する。
+func TestWeldAndSplitVertices(t *testing.T) {
+	m := newFoldedMeshModel()
+	removed, err := WeldVertices(m, WeldOptions{UvTolerance: -1})
+	if err != nil || removed != 2 {
+		t.Fatalf("unexpected weld result: removed=%d err=%v", removed, err)
+	}
+	if m.Vertices.Len() != 6 {
+		t.Fatalf("vertex count mismatch: %d", m.Vertices.Len())
+	}
+	face, _ := m.Faces.Get(2)
+	if face.VertexIndexes != [3]int{2, 3, 4} {
+		t.Fatalf("face remap mismatch: %v", face.VertexIndexes)
+	}
+	seam, _ := m.Vertices.Get(2)
+	if seam.DeformType != model.BDEF2 || math.Abs(weightOf(seam, 0)-0.5) > 1e-6 {
+		t.Fatalf("deform merge mismatch: %v %v", seam.Deform.Indexes(), seam.Deform.Weights())
+	}
+	morph, _ := m.Morphs.Get(0)
+	if len(morph.Offsets) != 2 || morph.Offsets[1].(*model.VertexMorphOffset).VertexIndex != 5 {
+		t.Fatalf("morph remap mismatch: %+v", morph.Offsets)
+	}
+
+	added, err := SplitVertices(m, SplitOptions{MaterialSeams: true})
+	if err != nil || added != 2 {
+		t.Fatalf("unexpected split result: added=%d err=%v", added, err)
+	}
+	face, _ = m.Faces.Get(2)
+	if face.VertexIndexes[0] < 6 || face.VertexIndexes[1] < 6 {
+		t.Fatalf("wall face should use split vertices: %v", face.VertexIndexes)
+	}
+	morph, _ = m.Morphs.Get(0)
+	if len(morph.Offsets) != 3 {
+		t.Fatalf("split vertex should inherit morph offsets: %d", len(morph.Offsets))
+	}
+}
+
+// TestRemoveUnusedVertices は面から参照されない頂点が削除されることを確認する。
+func TestRemoveUnusedVertices(t *testing.T) {
+	m := newFoldedMeshModel()
+	m.Vertices.Append(&model.Vertex{Position: vec3(5, 5, 5), Deform: model.NewBdef1(0)})
+	removed, err := RemoveUnusedVertices(m)
+	if err != nil || removed != 1 || m.Vertices.Len() != 8 {
+		t.Fatalf("unexpected result: removed=%d err=%v len=%d", removed, err, m.Vertices.Len())
+	}
+	last, _ := m.Vertices.Get(7)
+	if last.Index() != 7 {
+		t.Fatalf("vertex index mismatch: %d", last.Index())
+	}
+}
+
+// TestGenerateTangents はUVのU方向に沿った接線が生成されることを確認する。
+func TestGenerateTangents(t *testing.T) {
+	m := newFoldedMeshModel()
+	if err := RecomputeNormals(m, NormalOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tangents, err := GenerateTangents(m, TangentOptions{MaterialIndexes: []int{0}, ExtendedUvIndex: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if math.Abs(tangents[0].X-1) > 1e-6 || math.Abs(math.Abs(tangents[0].W)-1) > 1e-6 {
+		t.Fatalf("tangent mismatch: %v", tangents[0])
+	}
+	if tangents[6] != (mmath.Vec4{}) {
+		t.Fatalf("non-target material should be zero: %v", tangents[6])
+	}
+	vertex, _ := m.Vertices.Get(0)
+	if len(vertex.ExtendedUvs) != 2 || vertex.ExtendedUvs[1] != tangents[0] {
+		t.Fatalf("extended uv not written: %v", vertex.ExtendedUvs)
+	}
+
+	_, err = GenerateTangents(m, TangentOptions{ExtendedUvIndex: 4})
+	if merr.ExtractErrorID(err) != extendedUvIndexInvalidErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestSplitVerticesUvSeams は面ごとのUVが異なる共有頂点がUVの継ぎ目として分割されることを確認する。
+func TestSplitVerticesUvSeams(t *testing.T) {
+	m := newFoldedMeshModel()
+	// 床面の2面は頂点1,2を共有するが、2面目は展開図上で別の島(U+1)に置かれている。
+	faceUvs := [][3]mmath.Vec2{
+		{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 0}},
+		{{X: 2, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 1}},
+	}
+	added, err := SplitVertices(m, SplitOptions{FaceUvs: faceUvs})
+	if err != nil || added != 2 {
+		t.Fatalf("unexpected split result: added=%d err=%v", added, err)
+	}
+	first, _ := m.Faces.Get(0)
+	if first.VertexIndexes != [3]int{0, 1, 2} {
+		t.Fatalf("first face should keep original vertices: %v", first.VertexIndexes)
+	}
+	second, _ := m.Faces.Get(1)
+	if second.VertexIndexes[0] < 8 || second.VertexIndexes[1] < 8 || second.VertexIndexes[2] != 3 {
+		t.Fatalf("second face should use split vertices: %v", second.VertexIndexes)
+	}
+	for k, vertexIndex := range second.VertexIndexes {
+		vertex, _ := m.Vertices.Get(vertexIndex)
+		if !nearVec2(vertex.Uv, faceUvs[1][k], 1e-9) {
+			t.Fatalf("split vertex uv mismatch at %d: %v", k, vertex.Uv)
+		}
+	}
+	split, _ := m.Vertices.Get(second.VertexIndexes[0])
+	original, _ := m.Vertices.Get(2)
+	if !split.Position.NearEquals(original.Position, 1e-9) || !nearVec2(original.Uv, mmath.Vec2{X: 1, Y: 0}, 1e-9) {
+		t.Fatalf("split vertex should keep position: %v %v", split.Position, original.Uv)
+	}
+	morph, _ := m.Morphs.Get(0)
+	if len(morph.Offsets) != 4 {
+		t.Fatalf("split vertex should inherit morph offsets: %d", len(morph.Offsets))
+	}
+}
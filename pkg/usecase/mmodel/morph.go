@@ -0,0 +1,455 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"math"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+const (
+	morphNotFoundErrorID            = "13515"
+	morphTypeUnsupportedErrorID     = "13516"
+	morphVertexCountMismatchErrorID = "13517"
+	morphNameDuplicatedErrorID      = "13518"
+	// defaultMorphThreshold はオフセットとして残す最小差分の既定値。
+	defaultMorphThreshold = 1e-5
+	// defaultMorphMatchDistance は近傍一致の最大距離の既定値。
+	defaultMorphMatchDistance = 0.1
+	// defaultMorphMirrorTolerance は左右反転の対応頂点を探す距離の既定値。
+	defaultMorphMirrorTolerance = 1e-3
+)
+
+// MorphMatchMode は基準モデルと編集後モデルの頂点対応付け方法を表す。
+type MorphMatchMode int
+
+const (
+	// MORPH_MATCH_INDEX は頂点INDEXで対応付ける。
+	MORPH_MATCH_INDEX MorphMatchMode = iota
+	// MORPH_MATCH_NEAREST は最も近い位置の頂点で対応付ける。
+	MORPH_MATCH_NEAREST
+)
+
+// MorphExtractOptions はモーフ抽出の設定を表す。
+type MorphExtractOptions struct {
+	// Name は作成するモーフ名。
+	Name string
+	// Panel は作成するモーフの表示パネル。
+	Panel model.MorphPanel
+	// Match は頂点の対応付け方法。
+	Match MorphMatchMode
+	// MatchDistance は近傍一致で対応付ける最大距離。0 の場合は0.1。
+	MatchDistance float64
+	// Threshold はオフセットとして残す最小の差分。0 の場合は1e-5。
+	Threshold float64
+	// UvType はUVモーフの種別（UV/追加UV1-4）。ExtractUvMorph でのみ使い、nil の場合はUV。
+	UvType *model.MorphType
+}
+
+// MorphMirrorOptions はモーフ左右反転の設定を表す。
+type MorphMirrorOptions struct {
+	// Name は作成するモーフ名。
+	Name string
+	// Tolerance は反転位置の頂点を同一とみなす距離。0 の場合は1e-3。
+	Tolerance float64
+}
+
+// MorphFactor はグループモーフに含めるモーフと係数を表す。
+type MorphFactor struct {
+	Name   string
+	Factor float64
+}
+
+// ExtractVertexMorph は基準モデルと編集後モデルの頂点位置の差分から頂点モーフを作成し、基準モデルへ追加する。
+// 近傍一致ではUVが一致する頂点のみを対応候補とする。
+func ExtractVertexMorph(base, sculpted *model.PmxModel, opts MorphExtractOptions) (*model.Morph, error) {
+	pairs, err := matchMorphVertices(base, sculpted, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	threshold := morphThreshold(opts)
+	baseVertices := base.Vertices.Values()
+	sculptedVertices := sculpted.Vertices.Values()
+	offsets := make([]model.IMorphOffset, 0)
+	for i, j := range pairs {
+		if j < 0 {
+			continue
+		}
+		diff := sculptedVertices[j].Position.Subed(baseVertices[i].Position)
+		if diff.Length() < threshold {
+			continue
+		}
+		offsets = append(offsets, &model.VertexMorphOffset{VertexIndex: i, Position: diff})
+	}
+	return appendMorph(base, opts.Name, opts.Panel, model.MORPH_TYPE_VERTEX, offsets)
+}
+
+// ExtractUvMorph は基準モデルと編集後モデルのUV差分からUVモーフを作成し、基準モデルへ追加する。
+// 近傍一致では頂点位置のみで対応付ける。
+func ExtractUvMorph(base, sculpted *model.PmxModel, opts MorphExtractOptions) (*model.Morph, error) {
+	uvType := model.MORPH_TYPE_UV
+	if opts.UvType != nil {
+		uvType = *opts.UvType
+	}
+	if !isUvMorphType(uvType) {
+		return nil, merr.NewCommonError(
+			morphTypeUnsupportedErrorID, merr.ErrorKindValidate, messages.MorphTypeUnsupported, nil, opts.Name)
+	}
+	pairs, err := matchMorphVertices(base, sculpted, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	threshold := morphThreshold(opts)
+	baseVertices := base.Vertices.Values()
+	sculptedVertices := sculpted.Vertices.Values()
+	offsets := make([]model.IMorphOffset, 0)
+	for i, j := range pairs {
+		if j < 0 {
+			continue
+		}
+		diff := vertexUv(sculptedVertices[j], uvType).Subed(vertexUv(baseVertices[i], uvType))
+		// Vec4.Length は同次座標として扱うため、4成分の長さで判定する。
+		if math.Sqrt(diff.X*diff.X+diff.Y*diff.Y+diff.Z*diff.Z+diff.W*diff.W) < threshold {
+			continue
+		}
+		offsets = append(offsets, &model.UvMorphOffset{VertexIndex: i, Uv: diff, UvType: uvType})
+	}
+	return appendMorph(base, opts.Name, opts.Panel, uvType, offsets)
+}
+
+// MirrorMorph は頂点・UV・ボーンモーフを左右反転したモーフを作成し、モデルへ追加する。
+// 頂点は X を反転した位置にある頂点へ、ボーンは名前の左右を入れ替えたボーンへ対応付ける。
+func MirrorMorph(modelData *model.PmxModel, morphName string, opts MorphMirrorOptions) (*model.Morph, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	morph, err := findMorph(modelData, morphName)
+	if err != nil {
+		return nil, err
+	}
+	tolerance := opts.Tolerance
+	if tolerance <= 0 {
+		tolerance = defaultMorphMirrorTolerance
+	}
+
+	offsets := make([]model.IMorphOffset, 0, len(morph.Offsets))
+	switch {
+	case morph.MorphType == model.MORPH_TYPE_VERTEX || morph.MorphType == model.MORPH_TYPE_AFTER_VERTEX ||
+		isUvMorphType(morph.MorphType):
+		mirrors := mirrorVertexIndexes(modelData, tolerance)
+		for _, offset := range morph.Offsets {
+			switch o := offset.(type) {
+			case *model.VertexMorphOffset:
+				if mirror := mirrorIndex(mirrors, o.VertexIndex); mirror >= 0 {
+					position := o.Position
+					position.X = -position.X
+					offsets = append(offsets, &model.VertexMorphOffset{VertexIndex: mirror, Position: position})
+				}
+			case *model.UvMorphOffset:
+				if mirror := mirrorIndex(mirrors, o.VertexIndex); mirror >= 0 {
+					offsets = append(offsets, &model.UvMorphOffset{VertexIndex: mirror, Uv: o.Uv, UvType: o.UvType})
+				}
+			}
+		}
+	case morph.MorphType == model.MORPH_TYPE_BONE:
+		for _, offset := range morph.Offsets {
+			o, ok := offset.(*model.BoneMorphOffset)
+			if !ok {
+				continue
+			}
+			bone, err := modelData.Bones.Get(o.BoneIndex)
+			if err != nil || bone == nil {
+				continue
+			}
+			mirrorBone, err := modelData.Bones.GetByName(mirrorBoneName(bone.Name()))
+			if err != nil || mirrorBone == nil {
+				continue
+			}
+			position := o.Position
+			position.X = -position.X
+			rotation := mmath.NewQuaternionByValues(o.Rotation.X(), -o.Rotation.Y(), -o.Rotation.Z(), o.Rotation.W())
+			offsets = append(offsets, &model.BoneMorphOffset{
+				BoneIndex: mirrorBone.Index(),
+				Position:  position,
+				Rotation:  rotation,
+			})
+		}
+	default:
+		return nil, merr.NewCommonError(
+			morphTypeUnsupportedErrorID, merr.ErrorKindValidate, messages.MorphTypeUnsupported, nil, morphName)
+	}
+	return appendMorph(modelData, opts.Name, morph.Panel, morph.MorphType, offsets)
+}
+
+// CombineMorphs は指定モーフを係数付きでまとめたグループモーフを作成し、モデルへ追加する。
+func CombineMorphs(
+	modelData *model.PmxModel,
+	name string,
+	panel model.MorphPanel,
+	factors []MorphFactor,
+) (*model.Morph, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	offsets := make([]model.IMorphOffset, 0, len(factors))
+	for _, factor := range factors {
+		morph, err := findMorph(modelData, factor.Name)
+		if err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, &model.GroupMorphOffset{MorphIndex: morph.Index(), MorphFactor: factor.Factor})
+	}
+	return appendMorph(modelData, name, panel, model.MORPH_TYPE_GROUP, offsets)
+}
+
+// BakeMorph はモーフを指定比率で基準メッシュへ適用する。
+// 頂点・UVモーフと、それらを含むグループモーフのみ対応する。モーフ自体は残す。
+func BakeMorph(modelData *model.PmxModel, morphName string, ratio float64) error {
+	if modelData == nil {
+		return merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	morph, err := findMorph(modelData, morphName)
+	if err != nil {
+		return err
+	}
+	// 途中で失敗してもメッシュを変更しないよう、先に適用内容を集める。
+	positions := make(map[int]mmath.Vec3)
+	uvs := make(map[model.MorphType]map[int]mmath.Vec4)
+	if err := collectBakeOffsets(modelData, morph, ratio, positions, uvs, map[int]bool{}); err != nil {
+		return err
+	}
+	vertices := modelData.Vertices.Values()
+	for index, position := range positions {
+		if index < 0 || index >= len(vertices) || vertices[index] == nil {
+			continue
+		}
+		vertices[index].Position = vertices[index].Position.Added(position)
+	}
+	for uvType, offsets := range uvs {
+		for index, uv := range offsets {
+			if index < 0 || index >= len(vertices) || vertices[index] == nil {
+				continue
+			}
+			setVertexUv(vertices[index], uvType, vertexUv(vertices[index], uvType).Added(uv))
+		}
+	}
+	modelData.UpdateHash()
+	return nil
+}
+
+// collectBakeOffsets はモーフの頂点・UVオフセットを比率を掛けて集計する。
+func collectBakeOffsets(
+	modelData *model.PmxModel,
+	morph *model.Morph,
+	ratio float64,
+	positions map[int]mmath.Vec3,
+	uvs map[model.MorphType]map[int]mmath.Vec4,
+	visiting map[int]bool,
+) error {
+	if visiting[morph.Index()] {
+		// 循環参照したグループモーフは二重に適用しない。
+		return nil
+	}
+	visiting[morph.Index()] = true
+	defer delete(visiting, morph.Index())
+
+	switch {
+	case morph.MorphType == model.MORPH_TYPE_VERTEX:
+		for _, offset := range morph.Offsets {
+			if o, ok := offset.(*model.VertexMorphOffset); ok {
+				positions[o.VertexIndex] = positions[o.VertexIndex].Added(o.Position.MuledScalar(ratio))
+			}
+		}
+	case isUvMorphType(morph.MorphType):
+		for _, offset := range morph.Offsets {
+			if o, ok := offset.(*model.UvMorphOffset); ok {
+				if uvs[o.UvType] == nil {
+					uvs[o.UvType] = make(map[int]mmath.Vec4)
+				}
+				uvs[o.UvType][o.VertexIndex] = uvs[o.UvType][o.VertexIndex].Added(o.Uv.MuledScalar(ratio))
+			}
+		}
+	case morph.MorphType == model.MORPH_TYPE_GROUP:
+		for _, offset := range morph.Offsets {
+			o, ok := offset.(*model.GroupMorphOffset)
+			if !ok {
+				continue
+			}
+			child, err := modelData.Morphs.Get(o.MorphIndex)
+			if err != nil || child == nil {
+				continue
+			}
+			if err := collectBakeOffsets(modelData, child, ratio*o.MorphFactor, positions, uvs, visiting); err != nil {
+				return err
+			}
+		}
+	default:
+		return merr.NewCommonError(
+			morphTypeUnsupportedErrorID, merr.ErrorKindValidate, messages.MorphTypeUnsupported, nil, morph.Name())
+	}
+	return nil
+}
+
+// matchMorphVertices は基準モデルの頂点ごとに対応する編集後モデルの頂点INDEXを返す。対応が無い場合は -1。
+func matchMorphVertices(base, sculpted *model.PmxModel, opts MorphExtractOptions, matchUv bool) ([]int, error) {
+	if base == nil || sculpted == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	baseVertices := base.Vertices.Values()
+	sculptedVertices := sculpted.Vertices.Values()
+	pairs := make([]int, len(baseVertices))
+	if opts.Match == MORPH_MATCH_INDEX {
+		if len(baseVertices) != len(sculptedVertices) {
+			return nil, merr.NewCommonError(
+				morphVertexCountMismatchErrorID,
+				merr.ErrorKindValidate,
+				messages.MorphVertexCountMismatch,
+				nil,
+				len(baseVertices),
+				len(sculptedVertices),
+			)
+		}
+		for i := range pairs {
+			pairs[i] = i
+			if baseVertices[i] == nil || sculptedVertices[i] == nil {
+				pairs[i] = -1
+			}
+		}
+		return pairs, nil
+	}
+
+	distance := opts.MatchDistance
+	if distance <= 0 {
+		distance = defaultMorphMatchDistance
+	}
+	grid := newVertexPositionGrid(sculptedVertices, distance)
+	for i, vertex := range baseVertices {
+		pairs[i] = -1
+		if vertex == nil {
+			continue
+		}
+		best := distance
+		grid.each(vertex.Position, func(j int) {
+			candidate := sculptedVertices[j]
+			if matchUv && !nearVec2(vertex.Uv, candidate.Uv, 1e-4) {
+				return
+			}
+			if d := vertex.Position.Distance(candidate.Position); d <= best {
+				best = d
+				pairs[i] = j
+			}
+		})
+	}
+	return pairs, nil
+}
+
+// mirrorVertexIndexes は頂点ごとに X を反転した位置で最も近い頂点INDEXを返す。対応が無い場合は -1。
+func mirrorVertexIndexes(modelData *model.PmxModel, tolerance float64) []int {
+	vertices := modelData.Vertices.Values()
+	grid := newVertexPositionGrid(vertices, tolerance)
+	mirrors := make([]int, len(vertices))
+	for i, vertex := range vertices {
+		mirrors[i] = -1
+		if vertex == nil {
+			continue
+		}
+		mirrored := vertex.Position
+		mirrored.X = -mirrored.X
+		best := tolerance
+		grid.each(mirrored, func(j int) {
+			if d := mirrored.Distance(vertices[j].Position); d <= best {
+				best = d
+				mirrors[i] = j
+			}
+		})
+	}
+	return mirrors
+}
+
+// mirrorIndex は反転先の頂点INDEXを返す。範囲外の場合は -1。
+func mirrorIndex(mirrors []int, index int) int {
+	if index < 0 || index >= len(mirrors) {
+		return -1
+	}
+	return mirrors[index]
+}
+
+// mirrorBoneName はボーン名の左右を入れ替える。
+func mirrorBoneName(name string) string {
+	return strings.NewReplacer("左", "右", "右", "左").Replace(name)
+}
+
+// isUvMorphType はUV系のモーフ種別か判定する。
+func isUvMorphType(morphType model.MorphType) bool {
+	return morphType >= model.MORPH_TYPE_UV && morphType <= model.MORPH_TYPE_EXTENDED_UV4
+}
+
+// vertexUv はモーフ種別に対応する頂点のUVを返す。
+func vertexUv(vertex *model.Vertex, uvType model.MorphType) mmath.Vec4 {
+	if uvType == model.MORPH_TYPE_UV {
+		return mmath.Vec4{X: vertex.Uv.X, Y: vertex.Uv.Y}
+	}
+	index := int(uvType - model.MORPH_TYPE_EXTENDED_UV1)
+	if index < len(vertex.ExtendedUvs) {
+		return vertex.ExtendedUvs[index]
+	}
+	return mmath.Vec4{}
+}
+
+// setVertexUv はモーフ種別に対応する頂点のUVを設定する。
+func setVertexUv(vertex *model.Vertex, uvType model.MorphType, uv mmath.Vec4) {
+	if uvType == model.MORPH_TYPE_UV {
+		vertex.Uv.X = uv.X
+		vertex.Uv.Y = uv.Y
+		return
+	}
+	index := int(uvType - model.MORPH_TYPE_EXTENDED_UV1)
+	for len(vertex.ExtendedUvs) <= index {
+		vertex.ExtendedUvs = append(vertex.ExtendedUvs, mmath.Vec4{})
+	}
+	vertex.ExtendedUvs[index] = uv
+}
+
+// morphThreshold は最小差分の設定値を返す。
+func morphThreshold(opts MorphExtractOptions) float64 {
+	if opts.Threshold <= 0 {
+		return defaultMorphThreshold
+	}
+	return opts.Threshold
+}
+
+// findMorph はモーフ名からモーフを取得する。
+func findMorph(modelData *model.PmxModel, name string) (*model.Morph, error) {
+	morph, err := modelData.Morphs.GetByName(name)
+	if err != nil || morph == nil {
+		return nil, merr.NewCommonError(morphNotFoundErrorID, merr.ErrorKindValidate, messages.MorphNotFound, err, name)
+	}
+	return morph, nil
+}
+
+// appendMorph はモーフを作成してモデルへ追加する。
+func appendMorph(
+	modelData *model.PmxModel,
+	name string,
+	panel model.MorphPanel,
+	morphType model.MorphType,
+	offsets []model.IMorphOffset,
+) (*model.Morph, error) {
+	if existing, err := modelData.Morphs.GetByName(name); err == nil && existing != nil {
+		return nil, merr.NewCommonError(
+			morphNameDuplicatedErrorID, merr.ErrorKindValidate, messages.MorphNameDuplicated, nil, name)
+	}
+	morph := &model.Morph{
+		Panel:     panel,
+		MorphType: morphType,
+		Offsets:   offsets,
+	}
+	morph.SetName(name)
+	modelData.Morphs.Append(morph)
+	modelData.UpdateHash()
+	return morph, nil
+}
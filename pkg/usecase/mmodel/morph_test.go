@@ -0,0 +1,195 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// newMirrorMeshModel は左右対称な4頂点と左右の腕ボーンを持つモデルを生成する。
+func newMirrorMeshModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	appendTestBone(m, "センター", -1)
+	appendTestBone(m, "左腕", 0)
+	appendTestBone(m, "右腕", 0)
+	positions := []mmath.Vec3{vec3(1, 1, 0), vec3(-1, 1, 0), vec3(2, 0, 0), vec3(-2, 0, 0)}
+	for i, p := range positions {
+		m.Vertices.Append(&model.Vertex{
+			Position: p,
+			Uv:       mmath.Vec2{X: float64(i) * 0.25, Y: 0.5},
+			Deform:   model.NewBdef1(0),
+		})
+	}
+	return m
+}
+
+// copyMeshPositions は頂点順を逆にし、位置を変形した編集後モデルを生成する。
+func copyMeshPositions(base *model.PmxModel, moved map[int]mmath.Vec3) *model.PmxModel {
+	m := model.NewPmxModel()
+	vertices := base.Vertices.Values()
+	for i := len(vertices) - 1; i >= 0; i-- {
+		vertex := *vertices[i]
+		vertex.Position = vertex.Position.Added(moved[i])
+		m.Vertices.Append(&vertex)
+	}
+	return m
+}
+
+// TestExtractVertexMorphNearest は頂点順の異なる編集後モデルから頂点モーフを抽出できることを確認する。
+func TestExtractVertexMorphNearest(t *testing.T) {
+	base := newMirrorMeshModel()
+	sculpted := copyMeshPositions(base, map[int]mmath.Vec3{0: vec3(0, 0.05, 0)})
+
+	morph, err := ExtractVertexMorph(base, sculpted, MorphExtractOptions{Name: "上げ", Match: MORPH_MATCH_NEAREST})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(morph.Offsets) != 1 {
+		t.Fatalf("offset count mismatch: %d", len(morph.Offsets))
+	}
+	offset := morph.Offsets[0].(*model.VertexMorphOffset)
+	if offset.VertexIndex != 0 || !offset.Position.NearEquals(vec3(0, 0.05, 0), 1e-9) {
+		t.Fatalf("offset mismatch: %d %v", offset.VertexIndex, offset.Position)
+	}
+	if found, err := base.Morphs.GetByName("上げ"); err != nil || found != morph {
+		t.Fatalf("morph should be appended to base")
+	}
+
+	// 頂点INDEXで対応付ける場合は頂点数が一致している必要がある。
+	sculpted.Vertices.Append(&model.Vertex{Deform: model.NewBdef1(0)})
+	_, err = ExtractVertexMorph(base, sculpted, MorphExtractOptions{Name: "上げ2"})
+	if merr.ExtractErrorID(err) != morphVertexCountMismatchErrorID {
+		t.Fatalf("expected vertex count mismatch, got %v", err)
+	}
+}
+
+// TestExtractUvMorph は追加UVの差分からUVモーフを抽出できることを確認する。
+func TestExtractUvMorph(t *testing.T) {
+	base := newMirrorMeshModel()
+	sculpted := newMirrorMeshModel()
+	v, _ := sculpted.Vertices.Get(3)
+	v.ExtendedUvs = []mmath.Vec4{{}, {X: 0.5, W: 1}}
+
+	uvType := model.MORPH_TYPE_EXTENDED_UV2
+	morph, err := ExtractUvMorph(base, sculpted, MorphExtractOptions{Name: "UV", UvType: &uvType})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if morph.MorphType != model.MORPH_TYPE_EXTENDED_UV2 || len(morph.Offsets) != 1 {
+		t.Fatalf("uv morph mismatch: %v %d", morph.MorphType, len(morph.Offsets))
+	}
+	offset := morph.Offsets[0].(*model.UvMorphOffset)
+	if offset.VertexIndex != 3 || offset.Uv != (mmath.Vec4{X: 0.5, W: 1}) {
+		t.Fatalf("uv offset mismatch: %d %v", offset.VertexIndex, offset.Uv)
+	}
+
+	// 0 はグループモーフの種別なので、明示された場合はUVとして扱わない。
+	groupType := model.MORPH_TYPE_GROUP
+	_, err = ExtractUvMorph(base, sculpted, MorphExtractOptions{Name: "UV2", UvType: &groupType})
+	if merr.ExtractErrorID(err) != morphTypeUnsupportedErrorID {
+		t.Fatalf("expected unsupported morph type, got %v", err)
+	}
+	defaultMorph, err := ExtractUvMorph(base, sculpted, MorphExtractOptions{Name: "UV3"})
+	if err != nil || defaultMorph.MorphType != model.MORPH_TYPE_UV || len(defaultMorph.Offsets) != 0 {
+		t.Fatalf("default uv morph mismatch: %v", err)
+	}
+}
+
+// TestMirrorMorph は頂点モーフとボーンモーフを左右反転できることを確認する。
+func TestMirrorMorph(t *testing.T) {
+	m := newMirrorMeshModel()
+	vertexMorph := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX, Panel: model.MORPH_PANEL_EYE_UPPER_LEFT}
+	vertexMorph.SetName("ウィンク")
+	vertexMorph.Offsets = []model.IMorphOffset{&model.VertexMorphOffset{VertexIndex: 0, Position: vec3(0.1, -0.2, 0.3)}}
+	m.Morphs.Append(vertexMorph)
+	boneMorph := &model.Morph{MorphType: model.MORPH_TYPE_BONE}
+	boneMorph.SetName("左腕上げ")
+	rotation := mmath.NewQuaternionFromAxisAngles(vec3(1, 2, 3).Normalized(), 0.5)
+	boneMorph.Offsets = []model.IMorphOffset{&model.BoneMorphOffset{BoneIndex: 1, Position: vec3(1, 2, 3), Rotation: rotation}}
+	m.Morphs.Append(boneMorph)
+
+	mirrored, err := MirrorMorph(m, "ウィンク", MorphMirrorOptions{Name: "ウィンク右"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	offset := mirrored.Offsets[0].(*model.VertexMorphOffset)
+	if mirrored.Panel != model.MORPH_PANEL_EYE_UPPER_LEFT || offset.VertexIndex != 1 ||
+		!offset.Position.NearEquals(vec3(-0.1, -0.2, 0.3), 1e-9) {
+		t.Fatalf("vertex mirror mismatch: %d %v", offset.VertexIndex, offset.Position)
+	}
+
+	mirrored, err = MirrorMorph(m, "左腕上げ", MorphMirrorOptions{Name: "右腕上げ"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	boneOffset := mirrored.Offsets[0].(*model.BoneMorphOffset)
+	// X 反転した回転は、反転した軸を逆回りにする回転と一致する。
+	expected := mmath.NewQuaternionFromAxisAngles(vec3(-1, 2, 3).Normalized(), -0.5)
+	if boneOffset.BoneIndex != 2 || !boneOffset.Position.NearEquals(vec3(-1, 2, 3), 1e-9) ||
+		!boneOffset.Rotation.NearEquals(expected, 1e-9) {
+		t.Fatalf("bone mirror mismatch: %d %v %v", boneOffset.BoneIndex, boneOffset.Position, boneOffset.Rotation)
+	}
+
+	if _, err := MirrorMorph(m, "ウィンク", MorphMirrorOptions{Name: "左腕上げ"}); merr.ExtractErrorID(err) != morphNameDuplicatedErrorID {
+		t.Fatalf("expected duplicated name error, got %v", err)
+	}
+	if _, err := MirrorMorph(m, "無し", MorphMirrorOptions{Name: "無し右"}); merr.ExtractErrorID(err) != morphNotFoundErrorID {
+		t.Fatalf("expected morph not found error, got %v", err)
+	}
+}
+
+// TestCombineAndBakeMorph はグループモーフの作成と、係数を掛けた焼き込みを確認する。
+func TestCombineAndBakeMorph(t *testing.T) {
+	m := newMirrorMeshModel()
+	vertexMorph := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX}
+	vertexMorph.SetName("上げ")
+	vertexMorph.Offsets = []model.IMorphOffset{&model.VertexMorphOffset{VertexIndex: 2, Position: vec3(0, 1, 0)}}
+	m.Morphs.Append(vertexMorph)
+	uvMorph := &model.Morph{MorphType: model.MORPH_TYPE_UV}
+	uvMorph.SetName("UV")
+	uvMorph.Offsets = []model.IMorphOffset{
+		&model.UvMorphOffset{VertexIndex: 2, Uv: mmath.Vec4{X: 0.1, Y: 0.2}, UvType: model.MORPH_TYPE_UV},
+	}
+	m.Morphs.Append(uvMorph)
+
+	group, err := CombineMorphs(m, "まとめ", model.MORPH_PANEL_OTHER_LOWER_RIGHT, []MorphFactor{
+		{Name: "上げ", Factor: 0.5},
+		{Name: "UV", Factor: 1},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if group.MorphType != model.MORPH_TYPE_GROUP || len(group.Offsets) != 2 {
+		t.Fatalf("group morph mismatch: %v %d", group.MorphType, len(group.Offsets))
+	}
+	if _, err := CombineMorphs(m, "不正", model.MORPH_PANEL_OTHER_LOWER_RIGHT, []MorphFactor{{Name: "無し"}}); merr.ExtractErrorID(err) != morphNotFoundErrorID {
+		t.Fatalf("expected morph not found error, got %v", err)
+	}
+
+	if err := BakeMorph(m, "まとめ", 0.5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v, _ := m.Vertices.Get(2)
+	if !v.Position.NearEquals(vec3(2, 0.25, 0), 1e-9) {
+		t.Fatalf("baked position mismatch: %v", v.Position)
+	}
+	if !nearVec2(v.Uv, mmath.Vec2{X: 0.55, Y: 0.6}, 1e-9) {
+		t.Fatalf("baked uv mismatch: %v", v.Uv)
+	}
+	if _, err := m.Morphs.GetByName("まとめ"); err != nil {
+		t.Fatalf("baked morph should be kept")
+	}
+
+	boneMorph := &model.Morph{MorphType: model.MORPH_TYPE_BONE}
+	boneMorph.SetName("ボーン")
+	m.Morphs.Append(boneMorph)
+	if err := BakeMorph(m, "ボーン", 1); merr.ExtractErrorID(err) != morphTypeUnsupportedErrorID {
+		t.Fatalf("expected unsupported error, got %v", err)
+	}
+	if err := BakeMorph(nil, "ボーン", 1); merr.ExtractErrorID(err) != modelNotSpecifiedErrorID {
+		t.Fatalf("expected model not specified error, got %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+const (
+	physicsChainBoneNotFoundErrorID = "13507"
+	physicsChainTooShortErrorID     = "13508"
+)
+
+// PhysicsPreset は物理生成のプリセット種別を表す。
+type PhysicsPreset int
+
+const (
+	// PHYSICS_PRESET_HAIR は髪の毛向け。縦方向のみ接続する。
+	PHYSICS_PRESET_HAIR PhysicsPreset = iota
+	// PHYSICS_PRESET_SKIRT はスカート向け。列間を横ジョイントで閉じた輪として接続する。
+	PHYSICS_PRESET_SKIRT
+	// PHYSICS_PRESET_CLOTH は布(リボン・マント等)向け。列間を横ジョイントで接続する。
+	PHYSICS_PRESET_CLOTH
+)
+
+// PhysicsPresetParam は物理生成の調整値を表す。角度はラジアン。
+type PhysicsPresetParam struct {
+	Mass           float64
+	MassDecay      float64
+	LinearDamping  float64
+	AngularDamping float64
+	Restitution    float64
+	Friction       float64
+	PhysicsType    model.PhysicsType
+	// RadiusScale は周辺頂点から求めた半径への倍率。
+	RadiusScale float64
+	// DefaultRadiusRatio はウェイト頂点が無い場合の長さに対する半径比率。
+	DefaultRadiusRatio float64
+	// WeightThreshold は半径算出に使う頂点ウェイトの下限。
+	WeightThreshold          float64
+	RotationLimit            mmath.Vec3
+	SpringRotation           mmath.Vec3
+	HorizontalJoint          bool
+	HorizontalClosed         bool
+	HorizontalRotationLimit  mmath.Vec3
+	HorizontalSpringRotation mmath.Vec3
+}
+
+// NewPhysicsPresetParam はプリセットの既定調整値を返す。
+func NewPhysicsPresetParam(preset PhysicsPreset) PhysicsPresetParam {
+	param := PhysicsPresetParam{
+		Mass:               1.0,
+		MassDecay:          0.8,
+		LinearDamping:      0.5,
+		AngularDamping:     0.5,
+		Restitution:        0.0,
+		Friction:           0.5,
+		PhysicsType:        model.PHYSICS_TYPE_DYNAMIC_BONE,
+		RadiusScale:        1.0,
+		DefaultRadiusRatio: 0.2,
+		WeightThreshold:    0.3,
+	}
+	switch preset {
+	case PHYSICS_PRESET_SKIRT:
+		param.Mass = 0.8
+		param.AngularDamping = 0.8
+		param.RotationLimit = degreesVec3(30, 5, 15)
+		param.SpringRotation = newVec3(100, 100, 100)
+		param.HorizontalJoint = true
+		param.HorizontalClosed = true
+		param.HorizontalRotationLimit = degreesVec3(10, 5, 10)
+		param.HorizontalSpringRotation = newVec3(50, 50, 50)
+	case PHYSICS_PRESET_CLOTH:
+		param.Mass = 0.6
+		param.AngularDamping = 0.7
+		param.PhysicsType = model.PHYSICS_TYPE_DYNAMIC
+		param.RotationLimit = degreesVec3(20, 10, 20)
+		param.SpringRotation = newVec3(60, 60, 60)
+		param.HorizontalJoint = true
+		param.HorizontalRotationLimit = degreesVec3(10, 10, 10)
+		param.HorizontalSpringRotation = newVec3(30, 30, 30)
+	default:
+		param.Mass = 0.5
+		param.LinearDamping = 0.8
+		param.AngularDamping = 0.9
+		param.RadiusScale = 0.8
+		param.RotationLimit = degreesVec3(20, 10, 20)
+		param.SpringRotation = newVec3(50, 50, 50)
+	}
+	return param
+}
+
+// PhysicsGenerateOptions は物理生成の設定を表す。
+type PhysicsGenerateOptions struct {
+	// Chains は根元から先端の順に並べたボーン名列。先頭ボーンの剛体はボーン追従となる。
+	Chains [][]string
+	Preset PhysicsPreset
+	// Param はプリセット値を上書きする調整値。nil の場合はプリセット既定値を使う。
+	Param *PhysicsPresetParam
+	// CollisionGroup は生成剛体の衝突グループ(0-15)。同グループ同士は衝突しない。
+	CollisionGroup byte
+	// NoCollisionGroups は追加で衝突しないグループ。
+	NoCollisionGroups []byte
+}
+
+// PhysicsGenerateResult は物理生成結果を表す。
+type PhysicsGenerateResult struct {
+	// RigidBodyIndexes はチェーンごとの生成剛体 index。
+	RigidBodyIndexes [][]int
+	JointIndexes     []int
+}
+
+// physicsSegment はボーン1本分の剛体生成情報を表す。
+type physicsSegment struct {
+	bone  *model.Bone
+	start mmath.Vec3
+	end   mmath.Vec3
+}
+
+// GenerateChainPhysics はボーンチェーンから剛体とジョイントを生成して追加する。
+func GenerateChainPhysics(modelData *model.PmxModel, opts PhysicsGenerateOptions) (*PhysicsGenerateResult, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	param := NewPhysicsPresetParam(opts.Preset)
+	if opts.Param != nil {
+		param = *opts.Param
+	}
+
+	// 追加前に全チェーンを検証し、途中失敗でモデルを半端に更新しない。
+	chains := make([][]physicsSegment, 0, len(opts.Chains))
+	for _, chain := range opts.Chains {
+		segments, err := resolvePhysicsSegments(modelData, chain)
+		if err != nil {
+			return nil, err
+		}
+		chains = append(chains, segments)
+	}
+
+	vertexGroups := collectWeightedVertices(modelData, param.WeightThreshold)
+	collision := model.CollisionGroup{
+		Group: opts.CollisionGroup,
+		Mask:  physicsCollisionMask(opts.CollisionGroup, opts.NoCollisionGroups),
+	}
+	result := &PhysicsGenerateResult{RigidBodyIndexes: make([][]int, len(chains))}
+	rigidBodies := make([][]*model.RigidBody, len(chains))
+
+	for c, segments := range chains {
+		mass := param.Mass
+		for i, segment := range segments {
+			rigidBody := newSegmentRigidBody(segment, vertexGroups[segment.bone.Index()], param)
+			rigidBody.SetName(uniqueName(segment.bone.Name(), "_", func(name string) bool {
+				_, err := modelData.RigidBodies.GetByName(name)
+				return err == nil
+			}))
+			rigidBody.CollisionGroup = collision
+			rigidBody.Param.Mass = mass
+			if i == 0 {
+				rigidBody.PhysicsType = model.PHYSICS_TYPE_STATIC
+			} else {
+				rigidBody.PhysicsType = param.PhysicsType
+				mass *= param.MassDecay
+			}
+			idx := modelData.RigidBodies.AppendRaw(rigidBody)
+			result.RigidBodyIndexes[c] = append(result.RigidBodyIndexes[c], idx)
+			rigidBodies[c] = append(rigidBodies[c], rigidBody)
+		}
+	}
+
+	appendJoint := func(name string, a, b *model.RigidBody, position mmath.Vec3, limit, spring mmath.Vec3) {
+		joint := &model.Joint{RigidBodyIndexA: a.Index(), RigidBodyIndexB: b.Index()}
+		joint.SetName(uniqueName(name, "_", func(name string) bool {
+			_, err := modelData.Joints.GetByName(name)
+			return err == nil
+		}))
+		joint.Param.Position = position
+		joint.Param.Rotation = b.Rotation
+		joint.Param.RotationLimitMin = limit.MuledScalar(-1)
+		joint.Param.RotationLimitMax = limit
+		joint.Param.SpringConstantRotation = spring
+		idx := modelData.Joints.AppendRaw(joint)
+		result.JointIndexes = append(result.JointIndexes, idx)
+	}
+
+	// 縦方向: 隣接セグメントを子セグメントの始点で接続する。
+	for c, segments := range chains {
+		for i := 1; i < len(segments); i++ {
+			appendJoint("J_"+rigidBodies[c][i].Name(), rigidBodies[c][i-1], rigidBodies[c][i], segments[i].start, param.RotationLimit, param.SpringRotation)
+		}
+	}
+
+	// 横方向: 隣接チェーンの同じ段の動的剛体を中点で接続する。
+	if param.HorizontalJoint && len(chains) > 1 {
+		pairCount := len(chains) - 1
+		if param.HorizontalClosed && len(chains) > 2 {
+			pairCount = len(chains)
+		}
+		for c := 0; c < pairCount; c++ {
+			next := (c + 1) % len(chains)
+			rows := min(len(chains[c]), len(chains[next]))
+			for i := 1; i < rows; i++ {
+				a := rigidBodies[c][i]
+				b := rigidBodies[next][i]
+				appendJoint("J_"+a.Name()+"-"+b.Name(), a, b, a.Position.Lerp(b.Position, 0.5), param.HorizontalRotationLimit, param.HorizontalSpringRotation)
+			}
+		}
+	}
+
+	modelData.UpdateHash()
+	return result, nil
+}
+
+// resolvePhysicsSegments はボーン名列を剛体化するセグメント列へ変換する。
+func resolvePhysicsSegments(modelData *model.PmxModel, chain []string) ([]physicsSegment, error) {
+	bones := make([]*model.Bone, 0, len(chain))
+	for _, name := range chain {
+		bone, err := modelData.Bones.GetByName(name)
+		if err != nil || bone == nil {
+			return nil, merr.NewCommonError(
+				physicsChainBoneNotFoundErrorID,
+				merr.ErrorKindValidate,
+				messages.PhysicsChainBoneNotFound,
+				err,
+				name,
+			)
+		}
+		bones = append(bones, bone)
+	}
+
+	segments := make([]physicsSegment, 0, len(bones))
+	for i, bone := range bones {
+		var end mmath.Vec3
+		if i+1 < len(bones) {
+			end = bones[i+1].Position
+		} else if tail, ok := boneTailPosition(modelData, bone); ok {
+			end = tail
+		} else {
+			continue
+		}
+		if end.Distance(bone.Position) < 1e-6 {
+			continue
+		}
+		segments = append(segments, physicsSegment{bone: bone, start: bone.Position, end: end})
+	}
+	if len(segments) < 2 {
+		name := ""
+		if len(chain) > 0 {
+			name = chain[0]
+		}
+		return nil, merr.NewCommonError(physicsChainTooShortErrorID, merr.ErrorKindValidate, messages.PhysicsChainTooShort, nil, name)
+	}
+	return segments, nil
+}
+
+// boneTailPosition はボーンの表示先位置を返す。
+func boneTailPosition(modelData *model.PmxModel, bone *model.Bone) (mmath.Vec3, bool) {
+	if bone.BoneFlag&model.BONE_FLAG_TAIL_IS_BONE != 0 {
+		tail, err := modelData.Bones.Get(bone.TailIndex)
+		if err != nil || tail == nil {
+			return mmath.Vec3{}, false
+		}
+		return tail.Position, true
+	}
+	if bone.TailPosition.IsZero() {
+		return mmath.Vec3{}, false
+	}
+	return bone.Position.Added(bone.TailPosition), true
+}
+
+// collectWeightedVertices はボーンごとに閾値以上のウェイトを持つ頂点位置を集める。
+func collectWeightedVertices(modelData *model.PmxModel, threshold float64) map[int][]mmath.Vec3 {
+	groups := make(map[int][]mmath.Vec3)
+	for _, vertex := range modelData.Vertices.Values() {
+		if vertex == nil || vertex.Deform == nil {
+			continue
+		}
+		weights := vertex.Deform.Weights()
+		for i, boneIndex := range vertex.Deform.Indexes() {
+			if boneIndex < 0 || i >= len(weights) || weights[i] < threshold {
+				continue
+			}
+			groups[boneIndex] = append(groups[boneIndex], vertex.Position)
+		}
+	}
+	return groups
+}
+
+// newSegmentRigidBody はセグメントを覆うカプセル剛体を生成する。
+func newSegmentRigidBody(segment physicsSegment, vertices []mmath.Vec3, param PhysicsPresetParam) *model.RigidBody {
+	axis := segment.end.Subed(segment.start)
+	length := axis.Length()
+	radius := segmentRadius(segment, vertices) * param.RadiusScale
+	if radius <= 0 {
+		radius = length * param.DefaultRadiusRatio
+	}
+	// 極端に太い/細いカプセルは連結時に暴れやすいため長さ基準で抑える。
+	radius = mmath.Clamped(radius, length*0.05, length)
+
+	rigidBody := &model.RigidBody{
+		BoneIndex: segment.bone.Index(),
+		Shape:     model.SHAPE_CAPSULE,
+		Size:      newVec3(radius, length, 0),
+		Position:  segment.start.Lerp(segment.end, 0.5),
+		Rotation:  mmath.NewQuaternionRotate(mmath.UNIT_Y_VEC3, axis.Normalized()).ToRadians(),
+		Param: model.RigidBodyParam{
+			LinearDamping:  param.LinearDamping,
+			AngularDamping: param.AngularDamping,
+			Restitution:    param.Restitution,
+			Friction:       param.Friction,
+		},
+	}
+	return rigidBody
+}
+
+// segmentRadius は頂点群からセグメント軸までの平均距離を返す。
+func segmentRadius(segment physicsSegment, vertices []mmath.Vec3) float64 {
+	if len(vertices) == 0 {
+		return 0
+	}
+	axis := segment.end.Subed(segment.start)
+	lengthSqr := axis.Dot(axis)
+	total := 0.0
+	for _, v := range vertices {
+		t := mmath.Clamped(v.Subed(segment.start).Dot(axis)/lengthSqr, 0, 1)
+		closest := segment.start.Added(axis.MuledScalar(t))
+		total += v.Distance(closest)
+	}
+	return total / float64(len(vertices))
+}
+
+// physicsCollisionMask は自グループと指定グループを除外した衝突マスクを返す。
+func physicsCollisionMask(group byte, noCollisionGroups []byte) uint16 {
+	mask := uint16(0xFFFF) &^ (1 << (group & 0x0F))
+	for _, g := range noCollisionGroups {
+		mask &^= 1 << (g & 0x0F)
+	}
+	return mask
+}
+
+// newVec3 は成分指定で Vec3 を生成する。
+func newVec3(x, y, z float64) mmath.Vec3 {
+	v := mmath.NewVec3()
+	v.X = x
+	v.Y = y
+	v.Z = z
+	return v
+}
+
+// degreesVec3 は度数指定からラジアンの Vec3 を生成する。
+func degreesVec3(x, y, z float64) mmath.Vec3 {
+	return newVec3(mmath.DegToRad(x), mmath.DegToRad(y), mmath.DegToRad(z))
+}
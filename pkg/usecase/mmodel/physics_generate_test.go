@@ -0,0 +1,111 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// newSkirtTestModel は下向きに3段並ぶボーン列を columns 本持つモデルを生成する。
+func newSkirtTestModel(columns int) (*model.PmxModel, [][]string) {
+	m := model.NewPmxModel()
+	lower := appendTestBone(m, "下半身", -1)
+	lower.Position = vec3(0, 10, 0)
+	chains := make([][]string, 0, columns)
+	for c := 0; c < columns; c++ {
+		angle := 2 * math.Pi * float64(c) / float64(columns)
+		parent := lower.Index()
+		chain := make([]string, 0, 3)
+		for r := 0; r < 3; r++ {
+			name := string(rune('A'+c)) + string(rune('0'+r))
+			bone := appendTestBone(m, name, parent)
+			bone.Position = vec3(math.Cos(angle), 10-float64(r)*2, math.Sin(angle))
+			parent = bone.Index()
+			chain = append(chain, name)
+			// 各ボーン周辺に半径0.5のウェイト頂点を置く。
+			for _, offset := range []mmath.Vec3{vec3(0.5, -1, 0), vec3(-0.5, -1, 0)} {
+				m.Vertices.Append(&model.Vertex{Position: bone.Position.Added(offset), Deform: model.NewBdef1(bone.Index())})
+			}
+		}
+		chains = append(chains, chain)
+	}
+	return m, chains
+}
+
+// TestGenerateChainPhysicsSkirt はスカートプリセットで剛体とジョイントが生成されることを確認する。
+func TestGenerateChainPhysicsSkirt(t *testing.T) {
+	m, chains := newSkirtTestModel(4)
+	result, err := GenerateChainPhysics(m, PhysicsGenerateOptions{Chains: chains, Preset: PHYSICS_PRESET_SKIRT, CollisionGroup: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 末端ボーンは表示先が無いため各列2剛体。
+	if m.RigidBodies.Len() != 8 {
+		t.Fatalf("rigid body count mismatch: %d", m.RigidBodies.Len())
+	}
+	// 縦1本 x 4列 + 横(閉ループ)4本 x 動的1段。
+	if m.Joints.Len() != 8 || len(result.JointIndexes) != 8 {
+		t.Fatalf("joint count mismatch: %d", m.Joints.Len())
+	}
+
+	root, _ := m.RigidBodies.Get(result.RigidBodyIndexes[0][0])
+	if root.PhysicsType != model.PHYSICS_TYPE_STATIC || root.Shape != model.SHAPE_CAPSULE {
+		t.Fatalf("root rigid body should be static capsule: %+v", root)
+	}
+	if math.Abs(root.Size.X-0.5) > 1e-6 || math.Abs(root.Size.Y-2) > 1e-6 {
+		t.Fatalf("capsule size mismatch: %v", root.Size)
+	}
+	// カプセルのY軸がボーン方向(下向き)に一致すること。
+	axis := root.Rotation.RadToQuaternion().MulVec3(mmath.UNIT_Y_VEC3)
+	if math.Abs(math.Abs(axis.Y)-1) > 1e-6 {
+		t.Fatalf("capsule axis mismatch: %v", axis)
+	}
+	if root.CollisionGroup.Group != 3 || root.CollisionGroup.Mask&(1<<3) != 0 {
+		t.Fatalf("collision group mismatch: %+v", root.CollisionGroup)
+	}
+	tip, _ := m.RigidBodies.Get(result.RigidBodyIndexes[0][1])
+	if tip.PhysicsType != model.PHYSICS_TYPE_DYNAMIC_BONE || tip.Param.Mass != NewPhysicsPresetParam(PHYSICS_PRESET_SKIRT).Mass {
+		t.Fatalf("dynamic rigid body mismatch: %+v", tip)
+	}
+	joint, _ := m.Joints.Get(result.JointIndexes[0])
+	if joint.RigidBodyIndexA != root.Index() || joint.RigidBodyIndexB != tip.Index() {
+		t.Fatalf("joint connection mismatch: %+v", joint)
+	}
+	if joint.Param.RotationLimitMax.X <= 0 || joint.Param.RotationLimitMin.X >= 0 {
+		t.Fatalf("joint limit mismatch: %+v", joint.Param)
+	}
+}
+
+// TestGenerateChainPhysicsHair は髪プリセットで横ジョイントが生成されないことを確認する。
+func TestGenerateChainPhysicsHair(t *testing.T) {
+	m, chains := newSkirtTestModel(3)
+	if _, err := GenerateChainPhysics(m, PhysicsGenerateOptions{Chains: chains, Preset: PHYSICS_PRESET_HAIR}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Joints.Len() != 3 {
+		t.Fatalf("hair should have vertical joints only: %d", m.Joints.Len())
+	}
+}
+
+// TestGenerateChainPhysicsInvalid は不正なチェーン指定でモデルが更新されないことを確認する。
+func TestGenerateChainPhysicsInvalid(t *testing.T) {
+	m, chains := newSkirtTestModel(2)
+	chains = append(chains, []string{"存在しない"})
+	_, err := GenerateChainPhysics(m, PhysicsGenerateOptions{Chains: chains})
+	if merr.ExtractErrorID(err) != physicsChainBoneNotFoundErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.RigidBodies.Len() != 0 {
+		t.Fatalf("model should not be modified on error")
+	}
+
+	_, err = GenerateChainPhysics(m, PhysicsGenerateOptions{Chains: [][]string{{"A0"}}})
+	if merr.ExtractErrorID(err) != physicsChainTooShortErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
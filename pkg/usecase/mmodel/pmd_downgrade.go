@@ -0,0 +1,536 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/collection"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+	"golang.org/x/text/encoding/japanese"
+)
+
+const (
+	pmdLimitExceededErrorID = "13519"
+	// maxPmdVertexCount はPMDの頂点数上限(頂点番号はuint16)。
+	maxPmdVertexCount = 65535
+	// maxPmdBoneCount はPMDのボーン数上限(0xFFFFは親なしを表す)。
+	maxPmdBoneCount = 65535
+	// maxPmdMorphCount はPMDの表情数上限(base表情を含む)。
+	maxPmdMorphCount = 65535
+	// maxPmdIkLinkCount はPMDのIKチェーン長上限。
+	maxPmdIkLinkCount = 255
+	// maxPmdToonIndex はPMDの共有トゥーン番号上限。
+	maxPmdToonIndex = 9
+	// pmdNameBytes はPMDの名称欄のバイト数。
+	pmdNameBytes = 20
+	// pmdCommentBytes はPMDのコメント欄のバイト数。
+	pmdCommentBytes = 256
+	// pmdDisplaySlotNameBytes はPMDのボーン枠名欄のバイト数。
+	pmdDisplaySlotNameBytes = 50
+)
+
+// PmdLossKind はPMD変換で失われる情報の種別を表す。
+type PmdLossKind int
+
+const (
+	// PMD_LOSS_VERTEX_WEIGHT は3ボーン以上・SDEFのウェイトを2ボーンへ近似した。
+	PMD_LOSS_VERTEX_WEIGHT PmdLossKind = iota
+	// PMD_LOSS_VERTEX_ATTRIBUTE は追加UVや中間のエッジ倍率を削除・丸めた。
+	PMD_LOSS_VERTEX_ATTRIBUTE
+	// PMD_LOSS_MORPH_CONVERTED はグループモーフ・ボーン変形後頂点モーフを頂点モーフへ変換した。
+	PMD_LOSS_MORPH_CONVERTED
+	// PMD_LOSS_MORPH_REMOVED は頂点モーフへ変換できないモーフを削除した。
+	PMD_LOSS_MORPH_REMOVED
+	// PMD_LOSS_BONE_FEATURE はPMDで表現できないボーン設定を削除した。
+	PMD_LOSS_BONE_FEATURE
+	// PMD_LOSS_IK_LIMIT はIKリンクの角度制限を削除した。
+	PMD_LOSS_IK_LIMIT
+	// PMD_LOSS_MATERIAL はPMDで表現できない材質設定を削除した。
+	PMD_LOSS_MATERIAL
+	// PMD_LOSS_NAME は名称がPMDの欄に収まらない、またはShift-JISで表せない。
+	PMD_LOSS_NAME
+)
+
+// PmdLoss はPMD変換で失われる情報を表す。
+type PmdLoss struct {
+	Kind PmdLossKind
+	// Name は対象要素名。頂点など件数で集計する場合は空。
+	Name string
+	// Count は影響を受けた要素数。
+	Count int
+	// Detail は失われる内容の説明。
+	Detail string
+}
+
+// PmdDowngradeResult はPMD変換結果を表す。
+type PmdDowngradeResult struct {
+	// Model はPMDで保存できる形へ変換した複製モデル。
+	Model  *model.PmxModel
+	Losses []PmdLoss
+}
+
+// DowngradeToPmd はモデルを複製し、PMDで保存できる形へ近似して失われる情報を報告する。
+// 頂点数・ボーン数・表情数・IKチェーン長がPMDの上限を超える場合はエラーを返す。
+// ジョイントはPMDと同じバネ付き6DOFのみを保持する(PMX 2.1 のその他の種別は読み込み時に未対応としている)ため、種別の変換は行わない。
+func DowngradeToPmd(modelData *model.PmxModel) (*PmdDowngradeResult, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if err := checkPmdLimit("頂点数", modelData.Vertices.Len(), maxPmdVertexCount); err != nil {
+		return nil, err
+	}
+	if err := checkPmdLimit("ボーン数", modelData.Bones.Len(), maxPmdBoneCount); err != nil {
+		return nil, err
+	}
+	for _, bone := range modelData.Bones.Values() {
+		if bone != nil && bone.Ik != nil {
+			if err := checkPmdLimit("IKチェーン長: "+bone.Name(), len(bone.Ik.Links), maxPmdIkLinkCount); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	copied, err := modelData.Copy()
+	if err != nil {
+		return nil, err
+	}
+	d := &pmdDowngrader{model: &copied}
+	d.downgradeVertices()
+	d.downgradeMorphs()
+	if err := checkPmdLimit("表情数", d.model.Morphs.Len()+1, maxPmdMorphCount); err != nil {
+		return nil, err
+	}
+	d.downgradeBones()
+	d.downgradeMaterials()
+	d.checkNames()
+	d.model.UpdateHash()
+	return &PmdDowngradeResult{Model: d.model, Losses: d.losses}, nil
+}
+
+// checkPmdLimit はPMDの上限を超えていないか確認する。
+func checkPmdLimit(label string, count, limit int) error {
+	if count <= limit {
+		return nil
+	}
+	return merr.NewCommonError(
+		pmdLimitExceededErrorID, merr.ErrorKindValidate, messages.PmdLimitExceeded, nil, label, count, limit)
+}
+
+// pmdDowngrader はPMD変換の状態を保持する。
+type pmdDowngrader struct {
+	model  *model.PmxModel
+	losses []PmdLoss
+}
+
+// addLoss は失われる情報を記録する。
+func (d *pmdDowngrader) addLoss(kind PmdLossKind, name string, count int, detail string) {
+	d.losses = append(d.losses, PmdLoss{Kind: kind, Name: name, Count: count, Detail: detail})
+}
+
+// downgradeVertices は頂点のウェイトを2ボーンへ近似し、PMDに無い頂点属性を削除する。
+func (d *pmdDowngrader) downgradeVertices() {
+	bones := d.model.Bones.Values()
+	approximated := 0
+	sdefCount := 0
+	maxMoved := 0.0
+	extendedUvCount := 0
+	edgeCount := 0
+	for _, vertex := range d.model.Vertices.Values() {
+		if vertex == nil {
+			continue
+		}
+		if len(vertex.ExtendedUvs) > 0 {
+			vertex.ExtendedUvs = nil
+			extendedUvCount++
+		}
+		// PMDのエッジはON/OFFのみのため、中間の倍率は描画する側へ丸める。
+		if vertex.EdgeFactor > 0 && vertex.EdgeFactor != 1 {
+			vertex.EdgeFactor = 1
+			edgeCount++
+		}
+		if vertex.Deform == nil {
+			vertex.Deform = model.NewBdef1(0)
+			vertex.DeformType = model.BDEF1
+			continue
+		}
+		switch vertex.Deform.DeformType() {
+		case model.BDEF1, model.BDEF2:
+			continue
+		case model.SDEF:
+			sdefCount++
+		}
+		deform, moved := approximatePmdDeform(vertex.Deform, bones)
+		if moved > 0 {
+			approximated++
+			maxMoved = max(maxMoved, moved)
+		}
+		vertex.Deform = deform
+		vertex.DeformType = deform.DeformType()
+	}
+	if approximated > 0 {
+		d.addLoss(PMD_LOSS_VERTEX_WEIGHT, "", approximated,
+			fmt.Sprintf("3ボーン以上のウェイトを2ボーンへ近似(最大移し替えウェイト %.3f)", maxMoved))
+	}
+	if sdefCount > 0 {
+		d.addLoss(PMD_LOSS_VERTEX_WEIGHT, "", sdefCount, "SDEFをBDEF2へ変換")
+	}
+	if extendedUvCount > 0 {
+		d.addLoss(PMD_LOSS_VERTEX_ATTRIBUTE, "", extendedUvCount, "追加UVを削除")
+	}
+	if edgeCount > 0 {
+		d.addLoss(PMD_LOSS_VERTEX_ATTRIBUTE, "", edgeCount, "エッジ倍率を1へ丸め")
+	}
+}
+
+// approximatePmdDeform は上位2ボーンを残し、残りのボーンのウェイトを位置が近い側へ移し替える。
+// 移し替えたウェイトの合計を返す。
+func approximatePmdDeform(deform model.IDeform, bones []*model.Bone) (model.IDeform, float64) {
+	merged := make(map[int]float64)
+	for i, boneIndex := range deform.Indexes() {
+		if i < len(deform.Weights()) && deform.Weights()[i] > 0 {
+			merged[boneIndex] += deform.Weights()[i]
+		}
+	}
+	sorted := make([]boneWeight, 0, len(merged))
+	for boneIndex, w := range merged {
+		sorted = append(sorted, boneWeight{boneIndex: boneIndex, weight: w})
+	}
+	if len(sorted) == 0 {
+		return model.NewBdef1(0), 0
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].weight == sorted[j].weight {
+			return sorted[i].boneIndex < sorted[j].boneIndex
+		}
+		return sorted[i].weight > sorted[j].weight
+	})
+	kept := sorted[:min(2, len(sorted))]
+	moved := 0.0
+	for _, dropped := range sorted[len(kept):] {
+		// 削除するボーンに最も近い位置のボーンが、最も近い動きをすると見なす。
+		target := 0
+		if len(kept) == 2 && bonePositionDistance(bones, dropped.boneIndex, kept[1].boneIndex) <
+			bonePositionDistance(bones, dropped.boneIndex, kept[0].boneIndex) {
+			target = 1
+		}
+		kept[target].weight += dropped.weight
+		moved += dropped.weight
+	}
+	total := 0.0
+	for _, bw := range kept {
+		total += bw.weight
+	}
+	for i := range kept {
+		kept[i].weight /= total
+	}
+	return newDeformFromWeights(kept), moved
+}
+
+// bonePositionDistance はボーン間の距離を返す。ボーンが無い場合は最大値。
+func bonePositionDistance(bones []*model.Bone, a, b int) float64 {
+	if a < 0 || b < 0 || a >= len(bones) || b >= len(bones) || bones[a] == nil || bones[b] == nil {
+		return math.MaxFloat64
+	}
+	return bones[a].Position.Distance(bones[b].Position)
+}
+
+// downgradeMorphs はグループモーフを頂点モーフへ展開し、頂点モーフにできないモーフを削除する。
+func (d *pmdDowngrader) downgradeMorphs() {
+	morphs := d.model.Morphs.Values()
+	flattened := make(map[int][]model.IMorphOffset)
+	for _, morph := range morphs {
+		if morph == nil || morph.MorphType != model.MORPH_TYPE_GROUP {
+			continue
+		}
+		positions := make(map[int]mmath.Vec3)
+		dropped := make(map[string]struct{})
+		flattenGroupMorph(d.model, morph, 1, positions, dropped, map[int]bool{})
+		vertexIndexes := make([]int, 0, len(positions))
+		for vertexIndex := range positions {
+			vertexIndexes = append(vertexIndexes, vertexIndex)
+		}
+		sort.Ints(vertexIndexes)
+		offsets := make([]model.IMorphOffset, 0, len(vertexIndexes))
+		for _, vertexIndex := range vertexIndexes {
+			offsets = append(offsets, &model.VertexMorphOffset{VertexIndex: vertexIndex, Position: positions[vertexIndex]})
+		}
+		flattened[morph.Index()] = offsets
+		if len(dropped) > 0 {
+			names := make([]string, 0, len(dropped))
+			for name := range dropped {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			d.addLoss(PMD_LOSS_MORPH_CONVERTED, morph.Name(), len(names),
+				"グループモーフ内の頂点モーフ以外を削除: "+strings.Join(names, ", "))
+		}
+	}
+
+	rebuilt := collection.NewNamedCollection[*model.Morph](len(morphs))
+	oldToNew := make([]int, len(morphs))
+	for i, morph := range morphs {
+		oldToNew[i] = -1
+		if morph == nil {
+			continue
+		}
+		switch morph.MorphType {
+		case model.MORPH_TYPE_VERTEX:
+		case model.MORPH_TYPE_AFTER_VERTEX:
+			morph.MorphType = model.MORPH_TYPE_VERTEX
+			d.addLoss(PMD_LOSS_MORPH_CONVERTED, morph.Name(), len(morph.Offsets), "ボーン変形後頂点モーフを頂点モーフへ変換")
+		case model.MORPH_TYPE_GROUP:
+			morph.Offsets = flattened[i]
+			if len(morph.Offsets) == 0 {
+				d.addLoss(PMD_LOSS_MORPH_REMOVED, morph.Name(), 1, "頂点モーフを含まないグループモーフを削除")
+				continue
+			}
+			morph.MorphType = model.MORPH_TYPE_VERTEX
+			d.addLoss(PMD_LOSS_MORPH_CONVERTED, morph.Name(), len(morph.Offsets), "グループモーフを頂点モーフへ展開")
+		default:
+			d.addLoss(PMD_LOSS_MORPH_REMOVED, morph.Name(), 1, pmdMorphTypeName(morph.MorphType)+"を削除")
+			continue
+		}
+		oldToNew[i] = rebuilt.AppendRaw(morph)
+	}
+	d.model.Morphs = rebuilt
+
+	for _, slot := range d.model.DisplaySlots.Values() {
+		if slot == nil {
+			continue
+		}
+		refs := slot.References[:0]
+		for _, ref := range slot.References {
+			if ref.DisplayType == model.DISPLAY_TYPE_MORPH {
+				ref.DisplayIndex = mapIndex(oldToNew, ref.DisplayIndex)
+				if ref.DisplayIndex < 0 {
+					continue
+				}
+			}
+			refs = append(refs, ref)
+		}
+		slot.References = refs
+	}
+}
+
+// flattenGroupMorph はグループモーフの頂点オフセットを係数を掛けて集計し、展開できないモーフ名を記録する。
+func flattenGroupMorph(
+	modelData *model.PmxModel,
+	morph *model.Morph,
+	factor float64,
+	positions map[int]mmath.Vec3,
+	dropped map[string]struct{},
+	visiting map[int]bool,
+) {
+	if visiting[morph.Index()] {
+		return
+	}
+	visiting[morph.Index()] = true
+	defer delete(visiting, morph.Index())
+
+	for _, offset := range morph.Offsets {
+		switch o := offset.(type) {
+		case *model.VertexMorphOffset:
+			positions[o.VertexIndex] = positions[o.VertexIndex].Added(o.Position.MuledScalar(factor))
+		case *model.GroupMorphOffset:
+			child, err := modelData.Morphs.Get(o.MorphIndex)
+			if err != nil || child == nil {
+				continue
+			}
+			switch child.MorphType {
+			case model.MORPH_TYPE_VERTEX, model.MORPH_TYPE_AFTER_VERTEX, model.MORPH_TYPE_GROUP:
+				flattenGroupMorph(modelData, child, factor*o.MorphFactor, positions, dropped, visiting)
+			default:
+				dropped[child.Name()] = struct{}{}
+			}
+		}
+	}
+}
+
+// pmdMorphTypeName はモーフ種別の表示名を返す。
+func pmdMorphTypeName(morphType model.MorphType) string {
+	switch morphType {
+	case model.MORPH_TYPE_BONE:
+		return "ボーンモーフ"
+	case model.MORPH_TYPE_MATERIAL:
+		return "材質モーフ"
+	default:
+		if isUvMorphType(morphType) {
+			return "UVモーフ"
+		}
+		return "モーフ"
+	}
+}
+
+// downgradeBones はPMDで表現できないボーン設定を削除する。
+func (d *pmdDowngrader) downgradeBones() {
+	for _, bone := range d.model.Bones.Values() {
+		if bone == nil {
+			continue
+		}
+		lost := make([]string, 0)
+		if bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_TRANSLATION != 0 {
+			bone.BoneFlag &^= model.BONE_FLAG_IS_EXTERNAL_TRANSLATION
+			lost = append(lost, "移動付与")
+		}
+		if bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_ROTATION != 0 && bone.EffectFactor != 1 {
+			lost = append(lost, fmt.Sprintf("付与率%.3fの回転付与(回転連動へ近似)", bone.EffectFactor))
+		}
+		if bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_ROTATION == 0 {
+			bone.EffectIndex = -1
+		}
+		if bone.BoneFlag&model.BONE_FLAG_HAS_FIXED_AXIS != 0 {
+			bone.BoneFlag &^= model.BONE_FLAG_HAS_FIXED_AXIS
+			lost = append(lost, "軸制限")
+		}
+		if bone.BoneFlag&model.BONE_FLAG_HAS_LOCAL_AXIS != 0 {
+			bone.BoneFlag &^= model.BONE_FLAG_HAS_LOCAL_AXIS
+			lost = append(lost, "ローカル軸")
+		}
+		if bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_PARENT_DEFORM != 0 {
+			bone.BoneFlag &^= model.BONE_FLAG_IS_EXTERNAL_PARENT_DEFORM
+			lost = append(lost, "外部親変形")
+		}
+		if bone.BoneFlag&model.BONE_FLAG_IS_AFTER_PHYSICS_DEFORM != 0 {
+			bone.BoneFlag &^= model.BONE_FLAG_IS_AFTER_PHYSICS_DEFORM
+			lost = append(lost, "物理後変形")
+		}
+		if bone.Layer != 0 {
+			bone.Layer = 0
+			lost = append(lost, "変形階層")
+		}
+		if len(lost) > 0 {
+			d.addLoss(PMD_LOSS_BONE_FEATURE, bone.Name(), len(lost), strings.Join(lost, ", "))
+		}
+
+		if bone.Ik == nil {
+			continue
+		}
+		limited := 0
+		for i := range bone.Ik.Links {
+			link := &bone.Ik.Links[i]
+			if link.AngleLimit || link.LocalAngleLimit {
+				link.AngleLimit = false
+				link.LocalAngleLimit = false
+				limited++
+			}
+		}
+		if limited > 0 {
+			// PMDでは「ひざ」を名前に含むリンクのみ、読み込み側で固定の角度制限が付く。
+			d.addLoss(PMD_LOSS_IK_LIMIT, bone.Name(), limited, "IKリンクの角度制限を削除")
+		}
+	}
+}
+
+// downgradeMaterials はPMDで表現できない材質設定を削除する。
+func (d *pmdDowngrader) downgradeMaterials() {
+	named := 0
+	for _, material := range d.model.Materials.Values() {
+		if material == nil {
+			continue
+		}
+		if material.Name() != "" || material.EnglishName != "" {
+			named++
+		}
+		lost := make([]string, 0)
+		if material.ToonTextureIndex >= 0 &&
+			(material.ToonSharingFlag == model.TOON_SHARING_INDIVIDUAL || material.ToonTextureIndex > maxPmdToonIndex) {
+			material.ToonSharingFlag = model.TOON_SHARING_SHARING
+			material.ToonTextureIndex = -1
+			lost = append(lost, "個別トゥーン")
+		}
+		if material.SphereMode == model.SPHERE_MODE_SUBTEXTURE {
+			material.SphereMode = model.SPHERE_MODE_INVALID
+			material.SphereTextureIndex = -1
+			lost = append(lost, "サブテクスチャ")
+		}
+		if material.DrawFlag&^model.DRAW_FLAG_DRAWING_EDGE != 0 {
+			lost = append(lost, "描画フラグ(両面描画/地面影/セルフシャドウ)")
+		}
+		if material.DrawFlag&model.DRAW_FLAG_DRAWING_EDGE != 0 &&
+			(material.EdgeSize != 1 || material.Edge != (mmath.Vec4{W: 1})) {
+			lost = append(lost, "エッジ色/サイズ")
+		}
+		if material.Memo != "" {
+			lost = append(lost, "メモ")
+		}
+		if len(lost) > 0 {
+			d.addLoss(PMD_LOSS_MATERIAL, material.Name(), len(lost), strings.Join(lost, ", "))
+		}
+		textureName := pmdTextureSpec(d.model, material)
+		if length, ok := shiftJisLength(textureName); !ok || length > pmdNameBytes {
+			d.addLoss(PMD_LOSS_NAME, material.Name(), 1, "テクスチャ名: "+textureName)
+		}
+	}
+	if named > 0 {
+		d.addLoss(PMD_LOSS_MATERIAL, "", named, "材質名")
+	}
+}
+
+// pmdTextureSpec はPMDの材質テクスチャ欄へ書き込む文字列を返す。
+func pmdTextureSpec(modelData *model.PmxModel, material *model.Material) string {
+	names := make([]string, 0, 2)
+	for _, index := range []int{material.TextureIndex, material.SphereTextureIndex} {
+		if index < 0 {
+			continue
+		}
+		if texture, err := modelData.Textures.Get(index); err == nil && texture != nil && texture.Name() != "" {
+			names = append(names, texture.Name())
+		}
+	}
+	return strings.Join(names, "*")
+}
+
+// checkNames はPMDの名称欄に収まらない名称を記録する。
+func (d *pmdDowngrader) checkNames() {
+	check := func(label, name string, size int) {
+		if length, ok := shiftJisLength(name); !ok || length > size {
+			d.addLoss(PMD_LOSS_NAME, name, 1, label)
+		}
+	}
+	check("モデル名", d.model.Name(), pmdNameBytes)
+	check("英語モデル名", d.model.EnglishName, pmdNameBytes)
+	check("コメント", d.model.Comment, pmdCommentBytes)
+	check("英語コメント", d.model.EnglishComment, pmdCommentBytes)
+	for _, bone := range d.model.Bones.Values() {
+		if bone != nil {
+			check("ボーン名", bone.Name(), pmdNameBytes)
+			check("英語ボーン名", bone.EnglishName, pmdNameBytes)
+		}
+	}
+	for _, morph := range d.model.Morphs.Values() {
+		if morph != nil {
+			check("表情名", morph.Name(), pmdNameBytes)
+			check("英語表情名", morph.EnglishName, pmdNameBytes)
+		}
+	}
+	for _, slot := range d.model.DisplaySlots.Values() {
+		if slot != nil {
+			check("表示枠名", slot.Name(), pmdDisplaySlotNameBytes)
+		}
+	}
+	for _, rigidBody := range d.model.RigidBodies.Values() {
+		if rigidBody != nil {
+			check("剛体名", rigidBody.Name(), pmdNameBytes)
+		}
+	}
+	for _, joint := range d.model.Joints.Values() {
+		if joint != nil {
+			check("ジョイント名", joint.Name(), pmdNameBytes)
+		}
+	}
+}
+
+// shiftJisLength はShift-JISでのバイト数を返す。表せない文字を含む場合は false。
+func shiftJisLength(text string) (int, bool) {
+	encoded, err := japanese.ShiftJIS.NewEncoder().String(text)
+	if err != nil {
+		return len(text), false
+	}
+	return len(encoded), true
+}
@@ -0,0 +1,142 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// hasPmdLoss は指定種別・名称の損失が報告されているか判定する。
+func hasPmdLoss(losses []PmdLoss, kind PmdLossKind, name string) bool {
+	for _, loss := range losses {
+		if loss.Kind == kind && loss.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TestDowngradeToPmd はPMX固有の設定が近似・削除され、損失が報告されることを確認する。
+func TestDowngradeToPmd(t *testing.T) {
+	m := model.NewPmxModel()
+	m.SetName("テスト")
+	appendTestBone(m, "センター", -1)
+	appendTestBone(m, "左腕", 0)
+	appendTestBone(m, "左ひじ", 1)
+	appendTestBone(m, "左手首", 2)
+	for i, position := range []mmath.Vec3{vec3(0, 10, 0), vec3(1, 15, 0), vec3(3, 15, 0), vec3(5, 15, 0)} {
+		bone, _ := m.Bones.Get(i)
+		bone.Position = position
+	}
+	wrist, _ := m.Bones.Get(3)
+	wrist.BoneFlag |= model.BONE_FLAG_IS_EXTERNAL_TRANSLATION
+	wrist.EffectIndex = 1
+	wrist.EffectFactor = 1
+
+	m.Vertices.Append(&model.Vertex{
+		Deform:     model.NewBdef4([4]int{0, 1, 2, 3}, [4]float64{0.4, 0.3, 0.2, 0.1}),
+		DeformType: model.BDEF4,
+		EdgeFactor: 1,
+	})
+	m.Vertices.Append(&model.Vertex{
+		Deform:      model.NewSdef(1, 2, 0.6),
+		DeformType:  model.SDEF,
+		EdgeFactor:  0.5,
+		ExtendedUvs: []mmath.Vec4{{X: 1}},
+	})
+
+	smile := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX}
+	smile.SetName("笑い")
+	smile.Offsets = []model.IMorphOffset{&model.VertexMorphOffset{VertexIndex: 1, Position: vec3(0, 1, 0)}}
+	m.Morphs.Append(smile)
+	armUp := &model.Morph{MorphType: model.MORPH_TYPE_BONE}
+	armUp.SetName("腕上げ")
+	armUp.Offsets = []model.IMorphOffset{&model.BoneMorphOffset{BoneIndex: 1, Position: vec3(0, 1, 0)}}
+	m.Morphs.Append(armUp)
+	group := &model.Morph{MorphType: model.MORPH_TYPE_GROUP}
+	group.SetName("まとめ")
+	group.Offsets = []model.IMorphOffset{
+		&model.GroupMorphOffset{MorphIndex: 0, MorphFactor: 0.5},
+		&model.GroupMorphOffset{MorphIndex: 1, MorphFactor: 1},
+	}
+	m.Morphs.Append(group)
+	slot := &model.DisplaySlot{References: []model.Reference{
+		{DisplayType: model.DISPLAY_TYPE_MORPH, DisplayIndex: 1},
+		{DisplayType: model.DISPLAY_TYPE_MORPH, DisplayIndex: 2},
+	}}
+	slot.SetName("表情")
+	m.DisplaySlots.Append(slot)
+
+	result, err := DowngradeToPmd(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, vertex := range result.Model.Vertices.Values() {
+		if vertex.DeformType != model.BDEF2 || len(vertex.ExtendedUvs) != 0 || vertex.EdgeFactor != 1 {
+			t.Fatalf("vertex should be downgraded: %v %v %v", vertex.DeformType, vertex.ExtendedUvs, vertex.EdgeFactor)
+		}
+	}
+	// 左ひじは左腕、左手首は左ひじに近いため、それぞれのウェイトが移し替えられる。
+	v0, _ := result.Model.Vertices.Get(0)
+	if v0.Deform.Indexes()[0] != 0 || v0.Deform.Indexes()[1] != 1 || math.Abs(v0.Deform.Weights()[0]-0.4) > 1e-9 {
+		t.Fatalf("bdef4 approximation mismatch: %v %v", v0.Deform.Indexes(), v0.Deform.Weights())
+	}
+
+	if result.Model.Morphs.Len() != 2 {
+		t.Fatalf("bone morph should be removed: %d", result.Model.Morphs.Len())
+	}
+	flattened, _ := result.Model.Morphs.GetByName("まとめ")
+	offset := flattened.Offsets[0].(*model.VertexMorphOffset)
+	if flattened.MorphType != model.MORPH_TYPE_VERTEX || flattened.Index() != 1 ||
+		offset.VertexIndex != 1 || !offset.Position.NearEquals(vec3(0, 0.5, 0), 1e-9) {
+		t.Fatalf("group morph should be flattened: %v %d %+v", flattened.MorphType, flattened.Index(), offset)
+	}
+	convertedSlot, _ := result.Model.DisplaySlots.Get(0)
+	if len(convertedSlot.References) != 1 || convertedSlot.References[0].DisplayIndex != 1 {
+		t.Fatalf("display slot should be remapped: %+v", convertedSlot.References)
+	}
+	convertedWrist, _ := result.Model.Bones.Get(3)
+	if convertedWrist.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_TRANSLATION != 0 || convertedWrist.EffectIndex != -1 {
+		t.Fatalf("translation effect should be removed: %v %d", convertedWrist.BoneFlag, convertedWrist.EffectIndex)
+	}
+
+	for _, expected := range []struct {
+		kind PmdLossKind
+		name string
+	}{
+		{PMD_LOSS_VERTEX_WEIGHT, ""},
+		{PMD_LOSS_VERTEX_ATTRIBUTE, ""},
+		{PMD_LOSS_MORPH_CONVERTED, "まとめ"},
+		{PMD_LOSS_MORPH_REMOVED, "腕上げ"},
+		{PMD_LOSS_BONE_FEATURE, "左手首"},
+	} {
+		if !hasPmdLoss(result.Losses, expected.kind, expected.name) {
+			t.Fatalf("loss should be reported: %v %s %+v", expected.kind, expected.name, result.Losses)
+		}
+	}
+
+	// 元のモデルは変更されない。
+	original, _ := m.Vertices.Get(0)
+	if original.DeformType != model.BDEF4 || m.Morphs.Len() != 3 {
+		t.Fatalf("source model should not be changed")
+	}
+}
+
+// TestDowngradeToPmdLimitExceeded はIKチェーン長がPMDの上限を超える場合にエラーとなることを確認する。
+func TestDowngradeToPmdLimitExceeded(t *testing.T) {
+	m := model.NewPmxModel()
+	appendTestBone(m, "センター", -1)
+	ik := appendTestBone(m, "左足IK", 0)
+	ik.Ik = &model.Ik{BoneIndex: 0, Links: make([]model.IkLink, maxPmdIkLinkCount+1)}
+
+	if _, err := DowngradeToPmd(m); merr.ExtractErrorID(err) != pmdLimitExceededErrorID {
+		t.Fatalf("expected limit exceeded error, got %v", err)
+	}
+	if _, err := DowngradeToPmd(nil); merr.ExtractErrorID(err) != modelNotSpecifiedErrorID {
+		t.Fatalf("expected model not specified error, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"math"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+)
+
+// positionGrid は位置を格子で分割した近傍探索用の索引。
+type positionGrid struct {
+	cellSize float64
+	cells    map[[3]int64][]int
+	// minKey, maxKey は登録済みの格子の範囲。
+	minKey [3]int64
+	maxKey [3]int64
+}
+
+// newPositionGrid は格子の大きさを指定して索引を生成する。
+func newPositionGrid(cellSize float64) *positionGrid {
+	return &positionGrid{cellSize: cellSize, cells: make(map[[3]int64][]int)}
+}
+
+// newVertexPositionGrid は頂点を位置の格子へ登録した索引を生成する。
+func newVertexPositionGrid(vertices []*model.Vertex, cellSize float64) *positionGrid {
+	grid := newPositionGrid(cellSize)
+	for i, vertex := range vertices {
+		if vertex != nil {
+			grid.add(vertex.Position, i)
+		}
+	}
+	return grid
+}
+
+// keyOf は位置が属する格子を返す。
+func (g *positionGrid) keyOf(p mmath.Vec3) [3]int64 {
+	return [3]int64{
+		int64(math.Floor(p.X / g.cellSize)),
+		int64(math.Floor(p.Y / g.cellSize)),
+		int64(math.Floor(p.Z / g.cellSize)),
+	}
+}
+
+// add は位置の格子へ index を登録する。
+func (g *positionGrid) add(p mmath.Vec3, index int) {
+	g.addBox(p, p, index)
+}
+
+// addBox は範囲と重なる全ての格子へ index を登録する。
+func (g *positionGrid) addBox(minPos, maxPos mmath.Vec3, index int) {
+	minKey := g.keyOf(minPos)
+	maxKey := g.keyOf(maxPos)
+	if len(g.cells) == 0 {
+		g.minKey, g.maxKey = minKey, maxKey
+	}
+	for axis := 0; axis < 3; axis++ {
+		g.minKey[axis] = min(g.minKey[axis], minKey[axis])
+		g.maxKey[axis] = max(g.maxKey[axis], maxKey[axis])
+	}
+	for x := minKey[0]; x <= maxKey[0]; x++ {
+		for y := minKey[1]; y <= maxKey[1]; y++ {
+			for z := minKey[2]; z <= maxKey[2]; z++ {
+				key := [3]int64{x, y, z}
+				g.cells[key] = append(g.cells[key], index)
+			}
+		}
+	}
+}
+
+// each は位置の格子と隣接格子に登録された index を列挙する。
+func (g *positionGrid) each(p mmath.Vec3, fn func(int)) {
+	key := g.keyOf(p)
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for dz := int64(-1); dz <= 1; dz++ {
+				for _, i := range g.cells[[3]int64{key[0] + dx, key[1] + dy, key[2] + dz}] {
+					fn(i)
+				}
+			}
+		}
+	}
+}
+
+// ringRange は中心格子から登録済みの格子へ届く最小・最大のチェビシェフ距離を返す。
+func (g *positionGrid) ringRange(center [3]int64) (int64, int64) {
+	nearest, farthest := int64(0), int64(0)
+	for axis := 0; axis < 3; axis++ {
+		nearest = max(nearest, g.minKey[axis]-center[axis], center[axis]-g.maxKey[axis])
+		farthest = max(farthest, center[axis]-g.minKey[axis], g.maxKey[axis]-center[axis])
+	}
+	return nearest, farthest
+}
+
+// eachRing は中心格子からのチェビシェフ距離が ring の格子に登録された index を列挙する。
+func (g *positionGrid) eachRing(center [3]int64, ring int64, fn func(int)) {
+	visit := func(key [3]int64) {
+		for _, i := range g.cells[key] {
+			fn(i)
+		}
+	}
+	for x := max(center[0]-ring, g.minKey[0]); x <= min(center[0]+ring, g.maxKey[0]); x++ {
+		for y := max(center[1]-ring, g.minKey[1]); y <= min(center[1]+ring, g.maxKey[1]); y++ {
+			if abs64(x-center[0]) == ring || abs64(y-center[1]) == ring {
+				for z := max(center[2]-ring, g.minKey[2]); z <= min(center[2]+ring, g.maxKey[2]); z++ {
+					visit([3]int64{x, y, z})
+				}
+				continue
+			}
+			// 内側の列は z 方向の両端だけが距離 ring になる。
+			if z := center[2] - ring; z >= g.minKey[2] {
+				visit([3]int64{x, y, z})
+			}
+			if z := center[2] + ring; z <= g.maxKey[2] {
+				visit([3]int64{x, y, z})
+			}
+		}
+	}
+}
+
+// abs64 は整数の絶対値を返す。
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
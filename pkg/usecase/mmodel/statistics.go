@@ -0,0 +1,486 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/shared/contracts/units"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+	portio "github.com/miu200521358/mlib_go/pkg/usecase/port/io"
+)
+
+// textureBytesPerPixel はテクスチャメモリ見積もりに使う1画素あたりのバイト数(RGBA8)。
+const textureBytesPerPixel = 4
+
+// ModelBudgetItem は予算チェックの項目を表す。
+type ModelBudgetItem string
+
+const (
+	// MODEL_BUDGET_VERTICES は頂点数。
+	MODEL_BUDGET_VERTICES ModelBudgetItem = "Vertices"
+	// MODEL_BUDGET_TRIANGLES は三角形数。
+	MODEL_BUDGET_TRIANGLES ModelBudgetItem = "Triangles"
+	// MODEL_BUDGET_MATERIALS は材質数。
+	MODEL_BUDGET_MATERIALS ModelBudgetItem = "Materials"
+	// MODEL_BUDGET_BONES はボーン数。
+	MODEL_BUDGET_BONES ModelBudgetItem = "Bones"
+	// MODEL_BUDGET_MORPHS はモーフ数。
+	MODEL_BUDGET_MORPHS ModelBudgetItem = "Morphs"
+	// MODEL_BUDGET_RIGID_BODIES は剛体数。
+	MODEL_BUDGET_RIGID_BODIES ModelBudgetItem = "RigidBodies"
+	// MODEL_BUDGET_JOINTS はジョイント数。
+	MODEL_BUDGET_JOINTS ModelBudgetItem = "Joints"
+	// MODEL_BUDGET_TEXTURE_MEMORY はテクスチャメモリ(バイト)。
+	MODEL_BUDGET_TEXTURE_MEMORY ModelBudgetItem = "TextureMemoryBytes"
+	// MODEL_BUDGET_BONE_INFLUENCES は1頂点あたりの最大ボーン影響数。
+	MODEL_BUDGET_BONE_INFLUENCES ModelBudgetItem = "BoneInfluences"
+	// MODEL_BUDGET_HEIGHT は身長(cm)。
+	MODEL_BUDGET_HEIGHT ModelBudgetItem = "HeightCm"
+)
+
+// ModelBudget はモデルの予算を表す。0以下の項目はチェックしない。
+type ModelBudget struct {
+	MaxVertices           int
+	MaxTriangles          int
+	MaxMaterials          int
+	MaxBones              int
+	MaxMorphs             int
+	MaxRigidBodies        int
+	MaxJoints             int
+	MaxTextureMemoryBytes int64
+	MaxBoneInfluences     int
+	MaxHeightCm           float64
+}
+
+// ModelBudgetViolation は予算超過1件を表す。
+type ModelBudgetViolation struct {
+	Item  ModelBudgetItem
+	Value float64
+	Limit float64
+}
+
+// ModelElementCounts はコレクションごとの要素数を表す。
+type ModelElementCounts struct {
+	Vertices     int
+	Faces        int
+	Textures     int
+	Materials    int
+	Bones        int
+	Morphs       int
+	DisplaySlots int
+	RigidBodies  int
+	Joints       int
+}
+
+// MaterialStatistics は材質ごとの統計を表す。
+type MaterialStatistics struct {
+	Name      string
+	Triangles int
+	// TextureMemoryBytes は通常・スフィア・個別トゥーンテクスチャの非圧縮メモリ見積もり。
+	TextureMemoryBytes int64
+	Textures           []string
+}
+
+// BoneWeightStatistics はボーンごとのウェイト統計を表す。
+type BoneWeightStatistics struct {
+	Name      string
+	Vertices  int
+	WeightSum float64
+}
+
+// IkChainStatistics はIKボーンごとのIK設定の概要を表す。
+type IkChainStatistics struct {
+	Name           string
+	Target         string
+	Links          int
+	LimitedLinks   int
+	LoopCount      int
+	UnitRotDegrees float64
+}
+
+// ModelProfile はモデルの統計と予算チェック結果を表す。
+type ModelProfile struct {
+	Name               string
+	Path               string
+	Counts             ModelElementCounts
+	Triangles          int
+	TextureMemoryBytes int64
+	Materials          []MaterialStatistics
+	BoundingMin        mmath.Vec3
+	BoundingMax        mmath.Vec3
+	// HeightCm は頂点の上端から下端までの高さを 1MMD単位=8cm で換算した値。
+	HeightCm          float64
+	MaxBoneInfluences int
+	// InfluenceCounts はウェイトを持つボーン数ごとの頂点数。
+	InfluenceCounts map[int]int
+	DeformCounts    map[string]int
+	BoneWeights     []BoneWeightStatistics
+	IkChains        []IkChainStatistics
+	RigidBodyCounts map[string]int
+	MorphCounts     map[string]int
+	Budget          ModelBudget
+	Violations      []ModelBudgetViolation
+}
+
+// ProfileModel はモデルの統計を集計し、予算と照合する。
+// images が nil の場合はテクスチャメモリを集計しない。
+func ProfileModel(
+	modelData *model.PmxModel,
+	images portio.ITextureImageRepository,
+	budget ModelBudget,
+) (*ModelProfile, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	profile := &ModelProfile{
+		Name: modelData.Name(),
+		Path: modelData.Path(),
+		Counts: ModelElementCounts{
+			Vertices:     modelData.Vertices.Len(),
+			Faces:        modelData.Faces.Len(),
+			Textures:     modelData.Textures.Len(),
+			Materials:    modelData.Materials.Len(),
+			Bones:        modelData.Bones.Len(),
+			Morphs:       modelData.Morphs.Len(),
+			DisplaySlots: modelData.DisplaySlots.Len(),
+			RigidBodies:  modelData.RigidBodies.Len(),
+			Joints:       modelData.Joints.Len(),
+		},
+		InfluenceCounts: make(map[int]int),
+		DeformCounts:    make(map[string]int),
+		RigidBodyCounts: make(map[string]int),
+		MorphCounts:     make(map[string]int),
+		Budget:          budget,
+	}
+	profileMaterials(modelData, images, profile)
+	profileVertices(modelData, profile)
+	profileBones(modelData, profile)
+	for _, rigidBody := range modelData.RigidBodies.Values() {
+		if rigidBody != nil {
+			profile.RigidBodyCounts[physicsTypeName(rigidBody.PhysicsType)]++
+		}
+	}
+	for _, morph := range modelData.Morphs.Values() {
+		if morph != nil {
+			profile.MorphCounts[morphTypeName(morph.MorphType)]++
+		}
+	}
+	profile.Violations = CheckModelBudget(profile, budget)
+	return profile, nil
+}
+
+// WithinBudget は予算超過がないか返す。
+func (p *ModelProfile) WithinBudget() bool {
+	return len(p.Violations) == 0
+}
+
+// profileMaterials は材質ごとの三角形数とテクスチャメモリを集計する。
+func profileMaterials(modelData *model.PmxModel, images portio.ITextureImageRepository, profile *ModelProfile) {
+	textureBytes := make(map[int]int64)
+	loadTextureBytes := func(index int) (string, int64, bool) {
+		texture, err := modelData.Textures.Get(index)
+		if err != nil || texture == nil || texture.Name() == "" {
+			return "", 0, false
+		}
+		if bytes, ok := textureBytes[index]; ok {
+			return texture.Name(), bytes, true
+		}
+		var bytes int64
+		if images != nil {
+			if img, err := images.LoadImage(atlasTexturePath(modelData, texture.Name())); err == nil && img != nil {
+				bytes = int64(img.Bounds().Dx()) * int64(img.Bounds().Dy()) * textureBytesPerPixel
+			}
+		}
+		textureBytes[index] = bytes
+		return texture.Name(), bytes, true
+	}
+
+	for _, material := range modelData.Materials.Values() {
+		if material == nil {
+			continue
+		}
+		stats := MaterialStatistics{Name: material.Name(), Triangles: material.VerticesCount / 3}
+		indexes := []int{material.TextureIndex, material.SphereTextureIndex}
+		if material.ToonSharingFlag == model.TOON_SHARING_INDIVIDUAL {
+			indexes = append(indexes, material.ToonTextureIndex)
+		}
+		for _, index := range indexes {
+			if name, bytes, ok := loadTextureBytes(index); ok {
+				stats.Textures = append(stats.Textures, name)
+				stats.TextureMemoryBytes += bytes
+			}
+		}
+		profile.Triangles += stats.Triangles
+		profile.Materials = append(profile.Materials, stats)
+	}
+	// 複数の材質で共有するテクスチャはモデル全体では1回だけ数える。
+	for _, bytes := range textureBytes {
+		profile.TextureMemoryBytes += bytes
+	}
+}
+
+// profileVertices はバウンディングボックス・身長・ウェイト分布を集計する。
+func profileVertices(modelData *model.PmxModel, profile *ModelProfile) {
+	boneWeights := make(map[int]*BoneWeightStatistics)
+	positions := make([]mmath.Vec3, 0, modelData.Vertices.Len())
+	for _, vertex := range modelData.Vertices.Values() {
+		if vertex == nil {
+			continue
+		}
+		positions = append(positions, vertex.Position)
+		if vertex.Deform == nil {
+			continue
+		}
+		profile.DeformCounts[deformTypeName(vertex.Deform.DeformType())]++
+		influences := 0
+		weights := vertex.Deform.Weights()
+		for i, boneIndex := range vertex.Deform.Indexes() {
+			if i >= len(weights) || boneIndex < 0 || weights[i] <= 0 {
+				continue
+			}
+			influences++
+			stats, ok := boneWeights[boneIndex]
+			if !ok {
+				name := ""
+				if bone, err := modelData.Bones.Get(boneIndex); err == nil && bone != nil {
+					name = bone.Name()
+				}
+				stats = &BoneWeightStatistics{Name: name}
+				boneWeights[boneIndex] = stats
+			}
+			stats.Vertices++
+			stats.WeightSum += weights[i]
+		}
+		profile.InfluenceCounts[influences]++
+		profile.MaxBoneInfluences = max(profile.MaxBoneInfluences, influences)
+	}
+	profile.BoundingMin = mmath.MinVec3(positions)
+	profile.BoundingMax = mmath.MaxVec3(positions)
+	profile.HeightCm = (profile.BoundingMax.Y - profile.BoundingMin.Y) * units.MMD_UNIT_METERS * 100
+
+	boneIndexes := make([]int, 0, len(boneWeights))
+	for boneIndex := range boneWeights {
+		boneIndexes = append(boneIndexes, boneIndex)
+	}
+	sort.Ints(boneIndexes)
+	for _, boneIndex := range boneIndexes {
+		profile.BoneWeights = append(profile.BoneWeights, *boneWeights[boneIndex])
+	}
+}
+
+// profileBones はIKボーンごとの設定を集計する。
+func profileBones(modelData *model.PmxModel, profile *ModelProfile) {
+	for _, bone := range modelData.Bones.Values() {
+		if bone == nil || bone.Ik == nil {
+			continue
+		}
+		stats := IkChainStatistics{
+			Name:           bone.Name(),
+			Links:          len(bone.Ik.Links),
+			LoopCount:      bone.Ik.LoopCount,
+			UnitRotDegrees: mmath.RadToDeg(bone.Ik.UnitRotation.X),
+		}
+		if target, err := modelData.Bones.Get(bone.Ik.BoneIndex); err == nil && target != nil {
+			stats.Target = target.Name()
+		}
+		for _, link := range bone.Ik.Links {
+			if link.AngleLimit || link.LocalAngleLimit {
+				stats.LimitedLinks++
+			}
+		}
+		profile.IkChains = append(profile.IkChains, stats)
+	}
+}
+
+// CheckModelBudget は統計を予算と照合し、超過した項目を返す。
+func CheckModelBudget(profile *ModelProfile, budget ModelBudget) []ModelBudgetViolation {
+	if profile == nil {
+		return nil
+	}
+	violations := make([]ModelBudgetViolation, 0)
+	check := func(item ModelBudgetItem, value, limit float64) {
+		if limit > 0 && value > limit {
+			violations = append(violations, ModelBudgetViolation{Item: item, Value: value, Limit: limit})
+		}
+	}
+	check(MODEL_BUDGET_VERTICES, float64(profile.Counts.Vertices), float64(budget.MaxVertices))
+	check(MODEL_BUDGET_TRIANGLES, float64(profile.Triangles), float64(budget.MaxTriangles))
+	check(MODEL_BUDGET_MATERIALS, float64(profile.Counts.Materials), float64(budget.MaxMaterials))
+	check(MODEL_BUDGET_BONES, float64(profile.Counts.Bones), float64(budget.MaxBones))
+	check(MODEL_BUDGET_MORPHS, float64(profile.Counts.Morphs), float64(budget.MaxMorphs))
+	check(MODEL_BUDGET_RIGID_BODIES, float64(profile.Counts.RigidBodies), float64(budget.MaxRigidBodies))
+	check(MODEL_BUDGET_JOINTS, float64(profile.Counts.Joints), float64(budget.MaxJoints))
+	check(MODEL_BUDGET_TEXTURE_MEMORY, float64(profile.TextureMemoryBytes), float64(budget.MaxTextureMemoryBytes))
+	check(MODEL_BUDGET_BONE_INFLUENCES, float64(profile.MaxBoneInfluences), float64(budget.MaxBoneInfluences))
+	check(MODEL_BUDGET_HEIGHT, profile.HeightCm, budget.MaxHeightCm)
+	return violations
+}
+
+// WriteJSON は統計をJSONで書き込む。
+func (p *ModelProfile) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// CsvRecords は統計を「区分,名前,項目,値」の行へ変換する。
+func (p *ModelProfile) CsvRecords() [][]string {
+	records := [][]string{{"Section", "Name", "Item", "Value"}}
+	add := func(section, name, item string, value any) {
+		records = append(records, []string{section, name, item, formatProfileValue(value)})
+	}
+	add("Model", p.Name, "Path", p.Path)
+	add("Count", "", "Vertices", p.Counts.Vertices)
+	add("Count", "", "Faces", p.Counts.Faces)
+	add("Count", "", "Textures", p.Counts.Textures)
+	add("Count", "", "Materials", p.Counts.Materials)
+	add("Count", "", "Bones", p.Counts.Bones)
+	add("Count", "", "Morphs", p.Counts.Morphs)
+	add("Count", "", "DisplaySlots", p.Counts.DisplaySlots)
+	add("Count", "", "RigidBodies", p.Counts.RigidBodies)
+	add("Count", "", "Joints", p.Counts.Joints)
+	add("Model", "", "Triangles", p.Triangles)
+	add("Model", "", "TextureMemoryBytes", p.TextureMemoryBytes)
+	add("Model", "", "HeightCm", p.HeightCm)
+	add("Model", "", "MaxBoneInfluences", p.MaxBoneInfluences)
+	add("Bounds", "", "Min", fmt.Sprintf("%g %g %g", p.BoundingMin.X, p.BoundingMin.Y, p.BoundingMin.Z))
+	add("Bounds", "", "Max", fmt.Sprintf("%g %g %g", p.BoundingMax.X, p.BoundingMax.Y, p.BoundingMax.Z))
+	for _, material := range p.Materials {
+		add("Material", material.Name, "Triangles", material.Triangles)
+		add("Material", material.Name, "TextureMemoryBytes", material.TextureMemoryBytes)
+	}
+	for _, influences := range sortedIntKeys(p.InfluenceCounts) {
+		add("Influence", strconv.Itoa(influences), "Vertices", p.InfluenceCounts[influences])
+	}
+	for _, deform := range sortedStringKeys(p.DeformCounts) {
+		add("Deform", deform, "Vertices", p.DeformCounts[deform])
+	}
+	for _, bone := range p.BoneWeights {
+		add("BoneWeight", bone.Name, "Vertices", bone.Vertices)
+		add("BoneWeight", bone.Name, "WeightSum", bone.WeightSum)
+	}
+	for _, ik := range p.IkChains {
+		add("Ik", ik.Name, "Target", ik.Target)
+		add("Ik", ik.Name, "Links", ik.Links)
+		add("Ik", ik.Name, "LimitedLinks", ik.LimitedLinks)
+		add("Ik", ik.Name, "LoopCount", ik.LoopCount)
+		add("Ik", ik.Name, "UnitRotDegrees", ik.UnitRotDegrees)
+	}
+	for _, physicsType := range sortedStringKeys(p.RigidBodyCounts) {
+		add("RigidBody", physicsType, "Count", p.RigidBodyCounts[physicsType])
+	}
+	for _, morphType := range sortedStringKeys(p.MorphCounts) {
+		add("Morph", morphType, "Count", p.MorphCounts[morphType])
+	}
+	for _, violation := range p.Violations {
+		add("Violation", string(violation.Item), "Value", violation.Value)
+		add("Violation", string(violation.Item), "Limit", violation.Limit)
+	}
+	return records
+}
+
+// WriteCSV は統計をCSVで書き込む。
+func (p *ModelProfile) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(p.CsvRecords()); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// formatProfileValue はCSVへ出力する値を文字列へ変換する。
+func formatProfileValue(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sortedIntKeys は整数キーを昇順で返す。
+func sortedIntKeys(values map[int]int) []int {
+	keys := make([]int, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// sortedStringKeys は文字列キーを昇順で返す。
+func sortedStringKeys(values map[string]int) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// deformTypeName はデフォーム種別の名前を返す。
+func deformTypeName(deformType model.DeformType) string {
+	switch deformType {
+	case model.BDEF1:
+		return "BDEF1"
+	case model.BDEF2:
+		return "BDEF2"
+	case model.BDEF4:
+		return "BDEF4"
+	case model.SDEF:
+		return "SDEF"
+	default:
+		return strconv.Itoa(int(deformType))
+	}
+}
+
+// physicsTypeName は剛体物理の種類の名前を返す。
+func physicsTypeName(physicsType model.PhysicsType) string {
+	switch physicsType {
+	case model.PHYSICS_TYPE_STATIC:
+		return "Static"
+	case model.PHYSICS_TYPE_DYNAMIC:
+		return "Dynamic"
+	case model.PHYSICS_TYPE_DYNAMIC_BONE:
+		return "DynamicBone"
+	default:
+		return strconv.Itoa(int(physicsType))
+	}
+}
+
+// morphTypeName はモーフ種別の名前を返す。
+func morphTypeName(morphType model.MorphType) string {
+	switch morphType {
+	case model.MORPH_TYPE_GROUP:
+		return "Group"
+	case model.MORPH_TYPE_VERTEX:
+		return "Vertex"
+	case model.MORPH_TYPE_BONE:
+		return "Bone"
+	case model.MORPH_TYPE_UV:
+		return "Uv"
+	case model.MORPH_TYPE_EXTENDED_UV1:
+		return "ExtendedUv1"
+	case model.MORPH_TYPE_EXTENDED_UV2:
+		return "ExtendedUv2"
+	case model.MORPH_TYPE_EXTENDED_UV3:
+		return "ExtendedUv3"
+	case model.MORPH_TYPE_EXTENDED_UV4:
+		return "ExtendedUv4"
+	case model.MORPH_TYPE_MATERIAL:
+		return "Material"
+	case model.MORPH_TYPE_AFTER_VERTEX:
+		return "AfterVertex"
+	default:
+		return strconv.Itoa(int(morphType))
+	}
+}
@@ -0,0 +1,85 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+func TestProfileModel(t *testing.T) {
+	m := newAtlasTestModel()
+	appendTestBone(m, "右足IK", 0).Ik = &model.Ik{
+		BoneIndex: 0,
+		LoopCount: 40,
+		Links:     []model.IkLink{{BoneIndex: 0, AngleLimit: true}},
+	}
+	vertex, _ := m.Vertices.Get(10)
+	vertex.Position = vec3(0, 20, 0)
+	vertex.Deform = model.NewBdef2(0, 1, 0.5)
+	m.RigidBodies.Append(&model.RigidBody{PhysicsType: model.PHYSICS_TYPE_DYNAMIC})
+	morph := &model.Morph{MorphType: model.MORPH_TYPE_VERTEX}
+	morph.SetName("あ")
+	m.Morphs.Append(morph)
+	images := &memoryTextureImages{images: map[string]image.Image{
+		"red.png":  filledImage(4, 4, color.NRGBA{A: 255}),
+		"blue.png": filledImage(2, 2, color.NRGBA{A: 255}),
+	}}
+
+	profile, err := ProfileModel(m, images, ModelBudget{MaxTriangles: 5, MaxBones: 2, MaxBoneInfluences: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.Triangles != 6 || profile.Counts.Vertices != 11 || profile.Counts.Bones != 2 {
+		t.Fatalf("count mismatch: %d %+v", profile.Triangles, profile.Counts)
+	}
+	// 共有テクスチャはモデル全体では1回だけ数える。
+	if profile.Materials[0].TextureMemoryBytes != 64 || profile.TextureMemoryBytes != 64+16 {
+		t.Fatalf("texture memory mismatch: %+v %d", profile.Materials, profile.TextureMemoryBytes)
+	}
+	if math.Abs(profile.HeightCm-160) > 1e-6 {
+		t.Fatalf("height mismatch: %f", profile.HeightCm)
+	}
+	if profile.MaxBoneInfluences != 2 || profile.InfluenceCounts[1] != 10 || profile.DeformCounts["BDEF2"] != 1 {
+		t.Fatalf("weight distribution mismatch: %d %v %v", profile.MaxBoneInfluences, profile.InfluenceCounts, profile.DeformCounts)
+	}
+	if len(profile.BoneWeights) != 2 || profile.BoneWeights[0].Vertices != 11 || profile.BoneWeights[1].WeightSum != 0.5 {
+		t.Fatalf("bone weights mismatch: %+v", profile.BoneWeights)
+	}
+	if len(profile.IkChains) != 1 || profile.IkChains[0].Target != "センター" || profile.IkChains[0].LimitedLinks != 1 {
+		t.Fatalf("ik summary mismatch: %+v", profile.IkChains)
+	}
+	if profile.RigidBodyCounts["Dynamic"] != 1 || profile.MorphCounts["Vertex"] != 1 {
+		t.Fatalf("type counts mismatch: %v %v", profile.RigidBodyCounts, profile.MorphCounts)
+	}
+	if profile.WithinBudget() || len(profile.Violations) != 1 || profile.Violations[0].Item != MODEL_BUDGET_TRIANGLES {
+		t.Fatalf("violations mismatch: %+v", profile.Violations)
+	}
+
+	var jsonOut bytes.Buffer
+	if err := profile.WriteJSON(&jsonOut); err != nil {
+		t.Fatalf("WriteJSON returned error: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil || decoded["Triangles"] != float64(6) {
+		t.Fatalf("json mismatch: %v %v", err, decoded["Triangles"])
+	}
+	var csvOut bytes.Buffer
+	if err := profile.WriteCSV(&csvOut); err != nil {
+		t.Fatalf("WriteCSV returned error: %v", err)
+	}
+	if !strings.Contains(csvOut.String(), "Violation,Triangles,Limit,5\n") {
+		t.Fatalf("csv should contain violation:\n%s", csvOut.String())
+	}
+
+	if _, err := ProfileModel(nil, nil, ModelBudget{}); merr.ExtractErrorID(err) != modelNotSpecifiedErrorID {
+		t.Fatalf("expected model not specified error, got %v", err)
+	}
+}
@@ -0,0 +1,443 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/model/collection"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+	portio "github.com/miu200521358/mlib_go/pkg/usecase/port/io"
+)
+
+const (
+	textureProcessSaveFailedErrorID = "93509"
+	// textureOpaqueAlphaMin は不透明とみなすアルファの下限。
+	textureOpaqueAlphaMin = 250
+	// textureClearAlphaMax は完全に透明とみなすアルファの上限。
+	textureClearAlphaMax = 5
+	// textureTranslucentRatio は中間アルファの画素がこの割合を超えると半透明とみなす。
+	// 抜きテクスチャの縁のアンチエイリアスを半透明と誤判定しないための閾値。
+	textureTranslucentRatio = 0.02
+	// textureProcessFallbackFormat は保存できない形式のテクスチャを書き出す形式。
+	textureProcessFallbackFormat = "png"
+)
+
+// textureProcessSaveFormats は画像リポジトリで保存できる形式。
+var textureProcessSaveFormats = map[string]bool{"png": true, "jpg": true, "jpeg": true, "bmp": true, "dds": true}
+
+// TextureAlphaUsage はテクスチャのアルファの使い方を表す。
+type TextureAlphaUsage int
+
+const (
+	// TEXTURE_ALPHA_OPAQUE は全画素が不透明。
+	TEXTURE_ALPHA_OPAQUE TextureAlphaUsage = iota
+	// TEXTURE_ALPHA_CUTOUT は透明と不透明の抜きのみ。
+	TEXTURE_ALPHA_CUTOUT
+	// TEXTURE_ALPHA_TRANSLUCENT は中間のアルファを持つ半透明。
+	TEXTURE_ALPHA_TRANSLUCENT
+)
+
+// String はアルファの使い方の名前を返す。
+func (u TextureAlphaUsage) String() string {
+	switch u {
+	case TEXTURE_ALPHA_CUTOUT:
+		return "cutout"
+	case TEXTURE_ALPHA_TRANSLUCENT:
+		return "translucent"
+	default:
+		return "opaque"
+	}
+}
+
+// TextureProcessOptions はテクスチャ加工のオプションを表す。
+type TextureProcessOptions struct {
+	// MaxSize は長辺の最大画素数。0以下は縮小しない。
+	MaxSize int
+	// Format は保存形式の拡張子(png/dds 等)。空の場合は元の形式のまま保存する。
+	// 元の形式が保存できない形式(tga/sph/spa/gif 等)の場合は png で保存し、テクスチャ名を書き換える。
+	Format string
+	// OutputDir は加工したテクスチャの保存先ディレクトリ。テクスチャ名はモデルのディレクトリからの相対パスへ書き換える。
+	// 空の場合はモデルのディレクトリへ別名で保存し、元のファイルは上書きしない。
+	OutputDir string
+	// StripAlpha はアルファを使っていないテクスチャのアルファを取り除くか示す。
+	StripAlpha bool
+	// UpdateDrawFlags は半透明テクスチャを使う材質のセルフシャドウマップ描画を外すか示す。
+	UpdateDrawFlags bool
+	// SortTranslucentLast は半透明の材質を描画順の最後へ移すか示す。
+	SortTranslucentLast bool
+}
+
+// TextureProcessEntry は加工したテクスチャ1枚の結果を表す。
+type TextureProcessEntry struct {
+	Name          string
+	NewName       string
+	OriginalSize  image.Point
+	Size          image.Point
+	Alpha         TextureAlphaUsage
+	AlphaStripped bool
+}
+
+// TextureProcessResult はテクスチャ加工の結果を表す。
+type TextureProcessResult struct {
+	Textures []TextureProcessEntry
+	// Skipped は読み込めなかったため加工しなかったテクスチャ名。
+	Skipped []string
+	// TranslucentMaterials は半透明と判定した材質名。
+	TranslucentMaterials []string
+	// Reordered は材質の描画順を変更したか示す。
+	Reordered bool
+}
+
+// textureProcessPlan は保存前のテクスチャ加工内容を表す。
+type textureProcessPlan struct {
+	texture *model.Texture
+	entry   TextureProcessEntry
+	img     image.Image
+	save    bool
+}
+
+// ProcessModelTextures はモデルのテクスチャを縮小・形式変換し、アルファの使い方に応じて材質を調整する。
+// 画像の保存に失敗した場合はモデルを変更しない。
+func ProcessModelTextures(
+	modelData *model.PmxModel,
+	images portio.ITextureImageRepository,
+	opts TextureProcessOptions,
+) (*TextureProcessResult, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if images == nil {
+		return nil, merr.NewCommonError(
+			textureImageRepositoryNotConfiguredErrorID,
+			merr.ErrorKindInternal,
+			messages.TextureRepositoryNotConfigured,
+			nil,
+		)
+	}
+	format := strings.TrimPrefix(strings.ToLower(opts.Format), ".")
+
+	result := &TextureProcessResult{}
+	alphaByTexture := make(map[int]TextureAlphaUsage)
+	usedNames := make(map[string]bool)
+	for _, texture := range modelData.Textures.Values() {
+		if texture != nil {
+			usedNames[strings.ToLower(texture.Name())] = true
+		}
+	}
+	plans := make([]*textureProcessPlan, 0, modelData.Textures.Len())
+	for _, texture := range modelData.Textures.Values() {
+		if texture == nil || texture.Name() == "" {
+			continue
+		}
+		img, err := images.LoadImage(atlasTexturePath(modelData, texture.Name()))
+		if err != nil || img == nil {
+			result.Skipped = append(result.Skipped, texture.Name())
+			continue
+		}
+		plan := planTextureProcess(texture, img, format, opts, usedNames)
+		alphaByTexture[texture.Index()] = plan.entry.Alpha
+		plans = append(plans, plan)
+	}
+
+	for _, plan := range plans {
+		if !plan.save {
+			continue
+		}
+		if err := images.SaveImage(processedTexturePath(modelData, plan.entry.NewName, opts.OutputDir), plan.img); err != nil {
+			return nil, merr.NewCommonError(
+				textureProcessSaveFailedErrorID,
+				merr.ErrorKindExternal,
+				messages.TextureProcessSaveFailed,
+				err,
+				plan.entry.NewName,
+			)
+		}
+	}
+	for _, plan := range plans {
+		if plan.save && opts.OutputDir != "" {
+			plan.entry.NewName = processedTextureName(modelData, plan.entry.NewName, opts.OutputDir)
+		}
+		plan.texture.SetName(plan.entry.NewName)
+		result.Textures = append(result.Textures, plan.entry)
+	}
+
+	translucent := make([]bool, modelData.Materials.Len())
+	for i, material := range modelData.Materials.Values() {
+		if material == nil {
+			continue
+		}
+		usage, ok := alphaByTexture[material.TextureIndex]
+		if !(ok && usage == TEXTURE_ALPHA_TRANSLUCENT) && material.Diffuse.W >= 1 {
+			continue
+		}
+		translucent[i] = true
+		result.TranslucentMaterials = append(result.TranslucentMaterials, material.Name())
+		if opts.UpdateDrawFlags {
+			// 半透明部分がセルフシャドウマップへ不透明な影として描かれないようにする。
+			material.DrawFlag &^= model.DRAW_FLAG_DRAWING_ON_SELF_SHADOW_MAPS
+		}
+	}
+	if opts.SortTranslucentLast {
+		result.Reordered = sortTranslucentMaterials(modelData, translucent)
+	}
+	modelData.UpdateHash()
+	return result, nil
+}
+
+// planTextureProcess はテクスチャ1枚の縮小・アルファ除去・保存名を決める。
+// 保存先ディレクトリの指定がない場合、保存するテクスチャは元のファイルと重ならない名前にする。
+func planTextureProcess(
+	texture *model.Texture,
+	img image.Image,
+	format string,
+	opts TextureProcessOptions,
+	usedNames map[string]bool,
+) *textureProcessPlan {
+	name := texture.Name()
+	plan := &textureProcessPlan{
+		texture: texture,
+		entry: TextureProcessEntry{
+			Name:         name,
+			NewName:      name,
+			OriginalSize: img.Bounds().Size(),
+		},
+	}
+	processed := ResizeTexture(img, opts.MaxSize)
+	plan.entry.Alpha = AnalyzeTextureAlpha(processed)
+	if opts.StripAlpha && plan.entry.Alpha == TEXTURE_ALPHA_OPAQUE && !isImageOpaque(processed) {
+		processed = StripTextureAlpha(processed)
+		plan.entry.AlphaStripped = true
+	}
+	plan.entry.Size = processed.Bounds().Size()
+	plan.img = processed
+
+	ext := filepath.Ext(name)
+	current := strings.ToLower(strings.TrimPrefix(ext, "."))
+	target := format
+	if target == "" {
+		target = current
+	}
+	plan.save = target != current || plan.entry.Size != plan.entry.OriginalSize || plan.entry.AlphaStripped
+	if !plan.save {
+		return plan
+	}
+	if !textureProcessSaveFormats[target] {
+		target = textureProcessFallbackFormat
+	}
+	if target != current || opts.OutputDir == "" {
+		base := strings.TrimSuffix(name, ext)
+		newName := base + "." + target
+		for i := 2; usedNames[strings.ToLower(newName)]; i++ {
+			newName = fmt.Sprintf("%s_%d.%s", base, i, target)
+		}
+		usedNames[strings.ToLower(newName)] = true
+		plan.entry.NewName = newName
+	}
+	return plan
+}
+
+// processedTexturePath は加工したテクスチャの保存先パスを返す。
+func processedTexturePath(modelData *model.PmxModel, name, outputDir string) string {
+	if outputDir == "" {
+		return atlasTexturePath(modelData, name)
+	}
+	if filepath.IsAbs(name) {
+		return filepath.Join(outputDir, filepath.Base(name))
+	}
+	return filepath.Join(outputDir, name)
+}
+
+// processedTextureName は保存先ディレクトリへ書き出したテクスチャを、モデルのディレクトリからの相対パスで返す。
+// 相対パスにできない場合は絶対パスを返す。
+func processedTextureName(modelData *model.PmxModel, name, outputDir string) string {
+	path := processedTexturePath(modelData, name, outputDir)
+	if rel, err := filepath.Rel(filepath.Dir(modelData.Path()), path); err == nil {
+		return rel
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// sortTranslucentMaterials は半透明の材質を元の順序を保ったまま最後へ移す。順序が変わった場合に true を返す。
+func sortTranslucentMaterials(modelData *model.PmxModel, translucent []bool) bool {
+	materials := modelData.Materials.Values()
+	order := make([]int, 0, len(materials))
+	for i := range materials {
+		if !translucent[i] {
+			order = append(order, i)
+		}
+	}
+	for i := range materials {
+		if translucent[i] {
+			order = append(order, i)
+		}
+	}
+	changed := false
+	for i, materialIndex := range order {
+		if i != materialIndex {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	faces := modelData.Faces.Values()
+	faceStarts := make([]int, len(materials)+1)
+	for i, material := range materials {
+		faceStarts[i+1] = faceStarts[i]
+		if material != nil {
+			faceStarts[i+1] += material.VerticesCount / 3
+		}
+	}
+	rebuiltFaces := collection.NewIndexedCollection[*model.Face](len(faces))
+	rebuiltMaterials := collection.NewNamedCollection[*model.Material](len(materials))
+	oldToNew := make([]int, len(materials))
+	for _, materialIndex := range order {
+		for f := faceStarts[materialIndex]; f < faceStarts[materialIndex+1] && f < len(faces); f++ {
+			rebuiltFaces.AppendRaw(faces[f])
+		}
+		oldToNew[materialIndex] = rebuiltMaterials.AppendRaw(materials[materialIndex])
+	}
+	modelData.Faces = rebuiltFaces
+	modelData.Materials = rebuiltMaterials
+
+	for _, vertex := range modelData.Vertices.Values() {
+		if vertex == nil {
+			continue
+		}
+		for i, materialIndex := range vertex.MaterialIndexes {
+			vertex.MaterialIndexes[i] = mapIndex(oldToNew, materialIndex)
+		}
+	}
+	for _, morph := range modelData.Morphs.Values() {
+		if morph == nil || morph.MorphType != model.MORPH_TYPE_MATERIAL {
+			continue
+		}
+		for _, offset := range morph.Offsets {
+			if o, ok := offset.(*model.MaterialMorphOffset); ok && o.MaterialIndex >= 0 {
+				o.MaterialIndex = mapIndex(oldToNew, o.MaterialIndex)
+			}
+		}
+	}
+	return true
+}
+
+// AnalyzeTextureAlpha は画像のアルファが不透明・抜き・半透明のどれにあたるか判定する。
+func AnalyzeTextureAlpha(img image.Image) TextureAlphaUsage {
+	if img == nil || isImageOpaque(img) {
+		return TEXTURE_ALPHA_OPAQUE
+	}
+	src := toTextureNRGBA(img)
+	total := src.Rect.Dx() * src.Rect.Dy()
+	if total == 0 {
+		return TEXTURE_ALPHA_OPAQUE
+	}
+	clear, partial := 0, 0
+	for y := 0; y < src.Rect.Dy(); y++ {
+		offset := src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y)
+		for x := 0; x < src.Rect.Dx(); x++ {
+			alpha := src.Pix[offset+x*4+3]
+			switch {
+			case alpha >= textureOpaqueAlphaMin:
+			case alpha <= textureClearAlphaMax:
+				clear++
+			default:
+				partial++
+			}
+		}
+	}
+	if float64(partial) > float64(total)*textureTranslucentRatio {
+		return TEXTURE_ALPHA_TRANSLUCENT
+	}
+	if clear > 0 || partial > 0 {
+		return TEXTURE_ALPHA_CUTOUT
+	}
+	return TEXTURE_ALPHA_OPAQUE
+}
+
+// ResizeTexture は長辺が maxSize 以下になるよう縦横比を保って面積平均で縮小する。
+// 縮小不要な場合は元の画像を返す。透明画素の色が縁へ滲まないよう、色はアルファで重み付けする。
+func ResizeTexture(img image.Image, maxSize int) image.Image {
+	if img == nil {
+		return nil
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if maxSize <= 0 || (width <= maxSize && height <= maxSize) {
+		return img
+	}
+	scale := float64(maxSize) / float64(max(width, height))
+	dstWidth := max(1, int(math.Round(float64(width)*scale)))
+	dstHeight := max(1, int(math.Round(float64(height)*scale)))
+	src := toTextureNRGBA(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for dy := 0; dy < dstHeight; dy++ {
+		y0 := dy * height / dstHeight
+		y1 := max(y0+1, (dy+1)*height/dstHeight)
+		for dx := 0; dx < dstWidth; dx++ {
+			x0 := dx * width / dstWidth
+			x1 := max(x0+1, (dx+1)*width/dstWidth)
+			var sum [3]int
+			sumAlpha, count := 0, 0
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					offset := src.PixOffset(src.Rect.Min.X+x, src.Rect.Min.Y+y)
+					alpha := int(src.Pix[offset+3])
+					for c := 0; c < 3; c++ {
+						sum[c] += int(src.Pix[offset+c]) * alpha
+					}
+					sumAlpha += alpha
+					count++
+				}
+			}
+			offset := dst.PixOffset(dx, dy)
+			if sumAlpha > 0 {
+				for c := 0; c < 3; c++ {
+					dst.Pix[offset+c] = uint8((sum[c] + sumAlpha/2) / sumAlpha)
+				}
+			}
+			dst.Pix[offset+3] = uint8((sumAlpha + count/2) / count)
+		}
+	}
+	return dst
+}
+
+// StripTextureAlpha はアルファを取り除いた不透明な画像を返す。
+func StripTextureAlpha(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Rect, img, bounds.Min, draw.Src)
+	for i := 3; i < len(dst.Pix); i += 4 {
+		dst.Pix[i] = 255
+	}
+	return dst
+}
+
+// isImageOpaque は画像が不透明であることを型から判定できるか返す。
+func isImageOpaque(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return opaque.Opaque()
+	}
+	return false
+}
+
+// toTextureNRGBA は画像を *image.NRGBA へ変換する。
+func toTextureNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Rect, img, bounds.Min, draw.Src)
+	return dst
+}
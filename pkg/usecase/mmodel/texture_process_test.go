@@ -0,0 +1,181 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+func TestAnalyzeTextureAlpha(t *testing.T) {
+	opaque := filledImage(8, 8, color.NRGBA{R: 255, A: 255})
+	cutout := filledImage(10, 10, color.NRGBA{R: 255, A: 255}).(*image.NRGBA)
+	for x := 0; x < 10; x++ {
+		cutout.SetNRGBA(x, 0, color.NRGBA{})
+	}
+	// 縁のアンチエイリアス1画素は抜きとみなす。
+	cutout.SetNRGBA(0, 1, color.NRGBA{A: 128})
+	translucent := filledImage(4, 4, color.NRGBA{R: 255, A: 128})
+
+	cases := []struct {
+		img      image.Image
+		expected TextureAlphaUsage
+	}{
+		{opaque, TEXTURE_ALPHA_OPAQUE},
+		{cutout, TEXTURE_ALPHA_CUTOUT},
+		{translucent, TEXTURE_ALPHA_TRANSLUCENT},
+	}
+	for i, c := range cases {
+		if usage := AnalyzeTextureAlpha(c.img); usage != c.expected {
+			t.Errorf("case %d: expected %s, got %s", i, c.expected, usage)
+		}
+	}
+}
+
+func TestResizeTexture(t *testing.T) {
+	src := filledImage(8, 4, color.NRGBA{R: 255, A: 255}).(*image.NRGBA)
+	// 透明画素の色は縮小後の色に混ざらない。
+	src.SetNRGBA(0, 0, color.NRGBA{B: 255})
+	resized := ResizeTexture(src, 4).(*image.NRGBA)
+	if resized.Rect.Dx() != 4 || resized.Rect.Dy() != 2 {
+		t.Fatalf("size mismatch: %v", resized.Rect)
+	}
+	if c := resized.NRGBAAt(0, 0); c.R != 255 || c.B != 0 || c.A != 191 {
+		t.Fatalf("averaged color mismatch: %v", c)
+	}
+	if ResizeTexture(src, 16) != image.Image(src) {
+		t.Fatalf("small texture should not be resized")
+	}
+}
+
+func TestProcessModelTextures(t *testing.T) {
+	m := newAtlasTestModel()
+	for _, material := range m.Materials.Values() {
+		material.DrawFlag = model.DRAW_FLAG_DRAWING_ON_SELF_SHADOW_MAPS
+	}
+	hair := filledImage(4, 4, color.NRGBA{R: 255, A: 255}).(*image.NRGBA)
+	hair.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 252})
+	images := &memoryTextureImages{images: map[string]image.Image{
+		"red.png":  hair,
+		"blue.png": filledImage(8, 8, color.NRGBA{B: 255, A: 100}),
+	}}
+
+	result, err := ProcessModelTextures(m, images, TextureProcessOptions{
+		MaxSize:             4,
+		Format:              "dds",
+		StripAlpha:          true,
+		UpdateDrawFlags:     true,
+		SortTranslucentLast: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Textures) != 2 || !result.Textures[0].AlphaStripped || result.Textures[1].Size != (image.Point{X: 4, Y: 4}) {
+		t.Fatalf("texture result mismatch: %+v", result.Textures)
+	}
+	if _, ok := images.images["red.dds"]; !ok {
+		t.Fatalf("converted texture should be saved: %v", images.images)
+	}
+	texture, _ := m.Textures.Get(1)
+	if texture.Name() != "blue.dds" {
+		t.Fatalf("texture name mismatch: %s", texture.Name())
+	}
+	// 半透明テクスチャを使う「服」が最後へ移り、セルフシャドウマップ描画が外れる。
+	if !result.Reordered || len(result.TranslucentMaterials) != 1 || result.TranslucentMaterials[0] != "服" {
+		t.Fatalf("translucent material mismatch: %+v", result)
+	}
+	last, _ := m.Materials.Get(2)
+	if last.Name() != "服" || last.DrawFlag&model.DRAW_FLAG_DRAWING_ON_SELF_SHADOW_MAPS != 0 {
+		t.Fatalf("translucent material should be last without shadow map: %s %d", last.Name(), last.DrawFlag)
+	}
+	face, _ := m.Faces.Get(2)
+	if face.VertexIndexes != [3]int{4, 8, 9} {
+		t.Fatalf("faces should follow material order: %v", face.VertexIndexes)
+	}
+}
+
+func TestProcessModelTexturesSaveFailed(t *testing.T) {
+	m := newAtlasTestModel()
+	images := &memoryTextureImages{
+		images:  map[string]image.Image{"red.png": filledImage(8, 8, color.NRGBA{R: 255, A: 255})},
+		saveErr: errors.New("disk full"),
+	}
+	_, err := ProcessModelTextures(m, images, TextureProcessOptions{MaxSize: 4})
+	if merr.ExtractErrorID(err) != textureProcessSaveFailedErrorID {
+		t.Fatalf("expected save failed error, got %v", err)
+	}
+	texture, _ := m.Textures.Get(0)
+	if texture.Name() != "red.png" {
+		t.Fatalf("model should not be changed: %s", texture.Name())
+	}
+	if _, err := ProcessModelTextures(nil, images, TextureProcessOptions{}); merr.ExtractErrorID(err) != modelNotSpecifiedErrorID {
+		t.Fatalf("expected model not specified error, got %v", err)
+	}
+}
+
+// recordingTextureImages は保存先のパスを記録する画像リポジトリ。
+type recordingTextureImages struct {
+	*memoryTextureImages
+	savedPaths []string
+}
+
+// SaveImage は保存先のパスを記録してから画像を登録する。
+func (r *recordingTextureImages) SaveImage(path string, img image.Image) error {
+	r.savedPaths = append(r.savedPaths, path)
+	return r.memoryTextureImages.SaveImage(path, img)
+}
+
+func TestProcessModelTexturesUnsupportedFormat(t *testing.T) {
+	m := newAtlasTestModel()
+	red, _ := m.Textures.Get(0)
+	red.SetName("red.tga")
+	source := filledImage(8, 8, color.NRGBA{R: 255, A: 255})
+	blue := filledImage(8, 8, color.NRGBA{B: 255, A: 255})
+	images := &recordingTextureImages{memoryTextureImages: &memoryTextureImages{images: map[string]image.Image{
+		"red.tga":  source,
+		"blue.png": blue,
+	}}}
+
+	result, err := ProcessModelTextures(m, images, TextureProcessOptions{MaxSize: 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 保存できない tga は png で書き出し、テクスチャ名も書き換える。
+	if result.Textures[0].NewName != "red.png" || red.Name() != "red.png" {
+		t.Fatalf("unsupported texture should fall back to png: %+v", result.Textures[0])
+	}
+	if saved, ok := images.images["red.png"]; !ok || saved.Bounds().Dx() != 4 {
+		t.Fatalf("fallback texture should be saved: %v", images.images)
+	}
+	// 同じ形式でも元のファイルは上書きしない。
+	texture, _ := m.Textures.Get(1)
+	if texture.Name() != "blue_2.png" || images.images["blue.png"] != blue || images.images["red.tga"] != source {
+		t.Fatalf("source textures should not be overwritten: %s %v", texture.Name(), images.images)
+	}
+}
+
+func TestProcessModelTexturesOutputDir(t *testing.T) {
+	m := newAtlasTestModel()
+	images := &recordingTextureImages{memoryTextureImages: &memoryTextureImages{images: map[string]image.Image{
+		"red.png":  filledImage(8, 8, color.NRGBA{R: 255, A: 255}),
+		"blue.png": filledImage(2, 2, color.NRGBA{B: 255, A: 255}),
+	}}}
+	outputDir := filepath.Join("models", "out")
+
+	if _, err := ProcessModelTextures(m, images, TextureProcessOptions{MaxSize: 4, OutputDir: outputDir}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(images.savedPaths) != 1 || images.savedPaths[0] != filepath.Join(outputDir, "red.png") {
+		t.Fatalf("texture should be saved to output dir: %v", images.savedPaths)
+	}
+	red, _ := m.Textures.Get(0)
+	blue, _ := m.Textures.Get(1)
+	if red.Name() != filepath.Join("out", "red.png") || blue.Name() != "blue.png" {
+		t.Fatalf("texture names mismatch: %s %s", red.Name(), blue.Name())
+	}
+}
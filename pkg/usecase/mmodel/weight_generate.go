@@ -0,0 +1,547 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"math"
+	"sort"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+const (
+	weightBoneNotFoundErrorID      = "13509"
+	weightCandidateEmptyErrorID    = "13510"
+	weightTransferNoSurfaceErrorID = "13511"
+)
+
+// WeightMethod はウェイト生成方式を表す。
+type WeightMethod int
+
+const (
+	// WEIGHT_METHOD_DISTANCE はボーン線分までの距離減衰でウェイトを求める。
+	WEIGHT_METHOD_DISTANCE WeightMethod = iota
+	// WEIGHT_METHOD_SMOOTHED_DISTANCE は距離ウェイトを面の接続に沿って平滑化する。
+	WEIGHT_METHOD_SMOOTHED_DISTANCE
+)
+
+// weightControlBoneNames は既定の候補から外す変形に使わない操作用ボーン名。
+var weightControlBoneNames = map[string]struct{}{
+	model.ROOT.String():   {},
+	model.CENTER.String(): {},
+	model.GROOVE.String(): {},
+}
+
+// WeightGenerateOptions はウェイト生成の設定を表す。
+type WeightGenerateOptions struct {
+	Method WeightMethod
+	// VertexIndexes は対象頂点。空の場合は全頂点。
+	VertexIndexes []int
+	// BoneNames は候補ボーン。空の場合はIKと全ての親・センター・グルーブ以外の全ボーン。
+	BoneNames []string
+	// MaxInfluences は1頂点あたりの最大ボーン数(1-4)。0 の場合は4。
+	MaxInfluences int
+	// Falloff は距離減衰の指数。0 の場合は2。
+	Falloff float64
+	// MinWeight はこれ未満のウェイトを切り捨てる閾値。
+	MinWeight float64
+	// SmoothIterations は平滑化の反復回数。0 の場合は10。
+	SmoothIterations int
+	// SmoothRate は1反復あたりに隣接頂点から取り込む割合(0-1)。0 の場合は0.5。
+	SmoothRate float64
+}
+
+// WeightTransferOptions はウェイト転写の設定を表す。
+type WeightTransferOptions struct {
+	// VertexIndexes は転写先の対象頂点。空の場合は全頂点。
+	VertexIndexes []int
+	// MaxInfluences は1頂点あたりの最大ボーン数(1-4)。0 の場合は4。
+	MaxInfluences int
+	// MinWeight はこれ未満のウェイトを切り捨てる閾値。
+	MinWeight float64
+}
+
+// boneSegment はウェイト計算に使うボーン線分を表す。
+type boneSegment struct {
+	boneIndex int
+	start     mmath.Vec3
+	end       mmath.Vec3
+}
+
+// boneWeight はボーン index とウェイトの組を表す。
+type boneWeight struct {
+	boneIndex int
+	weight    float64
+}
+
+// GenerateWeights は対象頂点のデフォームをボーン階層から生成し、更新頂点数を返す。
+func GenerateWeights(modelData *model.PmxModel, opts WeightGenerateOptions) (int, error) {
+	if modelData == nil {
+		return 0, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	segments, err := resolveBoneSegments(modelData, opts.BoneNames)
+	if err != nil {
+		return 0, err
+	}
+	falloff := opts.Falloff
+	if falloff <= 0 {
+		falloff = 2
+	}
+
+	targets := resolveTargetVertices(modelData, opts.VertexIndexes)
+	weights := make(map[int]map[int]float64, len(targets))
+	for _, vertexIndex := range targets {
+		vertex, _ := modelData.Vertices.Get(vertexIndex)
+		weights[vertexIndex] = distanceWeights(vertex.Position, segments, falloff)
+	}
+	if opts.Method == WEIGHT_METHOD_SMOOTHED_DISTANCE {
+		smoothWeights(modelData, weights, opts.SmoothIterations, opts.SmoothRate)
+	}
+
+	for _, vertexIndex := range targets {
+		vertex, _ := modelData.Vertices.Get(vertexIndex)
+		applyBoneWeights(vertex, weights[vertexIndex], opts.MaxInfluences, opts.MinWeight)
+	}
+	modelData.UpdateHash()
+	return len(targets), nil
+}
+
+// TransferWeights は source の最近傍面からウェイトを補間して target の頂点へ転写し、更新頂点数を返す。
+// ボーンは名前で対応付け、target に存在しないボーンのウェイトは捨てる。
+func TransferWeights(target, source *model.PmxModel, opts WeightTransferOptions) (int, error) {
+	if target == nil || source == nil {
+		return 0, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if source.Faces.Len() == 0 {
+		return 0, merr.NewCommonError(weightTransferNoSurfaceErrorID, merr.ErrorKindValidate, messages.WeightTransferNoSurface, nil)
+	}
+
+	boneMap := make([]int, source.Bones.Len())
+	for i := range boneMap {
+		boneMap[i] = -1
+	}
+	for _, bone := range source.Bones.Values() {
+		if bone == nil {
+			continue
+		}
+		if mapped, err := target.Bones.GetByName(bone.Name()); err == nil && mapped != nil {
+			boneMap[bone.Index()] = mapped.Index()
+		}
+	}
+
+	surfaces := newSurfaceIndex(source)
+	count := 0
+	for _, vertexIndex := range resolveTargetVertices(target, opts.VertexIndexes) {
+		vertex, _ := target.Vertices.Get(vertexIndex)
+		face, bary, ok := surfaces.nearest(vertex.Position)
+		if !ok {
+			continue
+		}
+		weights := make(map[int]float64)
+		for i, sourceIndex := range face.VertexIndexes {
+			sourceVertex, err := source.Vertices.Get(sourceIndex)
+			if err != nil || sourceVertex == nil || sourceVertex.Deform == nil {
+				continue
+			}
+			sourceWeights := sourceVertex.Deform.Weights()
+			for j, boneIndex := range sourceVertex.Deform.Indexes() {
+				mapped := mapIndex(boneMap, boneIndex)
+				if mapped < 0 || j >= len(sourceWeights) {
+					continue
+				}
+				weights[mapped] += sourceWeights[j] * bary[i]
+			}
+		}
+		if len(weights) == 0 {
+			continue
+		}
+		applyBoneWeights(vertex, weights, opts.MaxInfluences, opts.MinWeight)
+		count++
+	}
+	target.UpdateHash()
+	return count, nil
+}
+
+// resolveTargetVertices は対象頂点 index を返す。
+func resolveTargetVertices(modelData *model.PmxModel, indexes []int) []int {
+	if len(indexes) == 0 {
+		out := make([]int, 0, modelData.Vertices.Len())
+		for _, vertex := range modelData.Vertices.Values() {
+			if vertex != nil {
+				out = append(out, vertex.Index())
+			}
+		}
+		return out
+	}
+	out := make([]int, 0, len(indexes))
+	for _, idx := range indexes {
+		if vertex, err := modelData.Vertices.Get(idx); err == nil && vertex != nil {
+			out = append(out, idx)
+		}
+	}
+	return out
+}
+
+// resolveBoneSegments は候補ボーンの線分を返す。
+func resolveBoneSegments(modelData *model.PmxModel, boneNames []string) ([]boneSegment, error) {
+	candidates := make([]*model.Bone, 0, modelData.Bones.Len())
+	if len(boneNames) == 0 {
+		for _, bone := range modelData.Bones.Values() {
+			if bone == nil || bone.BoneFlag&model.BONE_FLAG_IS_IK != 0 {
+				continue
+			}
+			if _, ok := weightControlBoneNames[bone.Name()]; ok {
+				continue
+			}
+			candidates = append(candidates, bone)
+		}
+	} else {
+		for _, name := range boneNames {
+			bone, err := modelData.Bones.GetByName(name)
+			if err != nil || bone == nil {
+				return nil, merr.NewCommonError(weightBoneNotFoundErrorID, merr.ErrorKindValidate, messages.WeightBoneNotFound, err, name)
+			}
+			candidates = append(candidates, bone)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, merr.NewCommonError(weightCandidateEmptyErrorID, merr.ErrorKindValidate, messages.WeightCandidateEmpty, nil)
+	}
+
+	firstChild := make(map[int]*model.Bone)
+	for _, bone := range modelData.Bones.Values() {
+		if bone == nil || bone.ParentIndex < 0 || bone.BoneFlag&model.BONE_FLAG_IS_IK != 0 {
+			continue
+		}
+		if _, ok := firstChild[bone.ParentIndex]; !ok {
+			firstChild[bone.ParentIndex] = bone
+		}
+	}
+
+	segments := make([]boneSegment, 0, len(candidates))
+	for _, bone := range candidates {
+		end, ok := boneTailPosition(modelData, bone)
+		if !ok {
+			// 表示先が無い場合は子ボーン、子も無ければ点として扱う。
+			end = bone.Position
+			if child, ok := firstChild[bone.Index()]; ok {
+				end = child.Position
+			}
+		}
+		segments = append(segments, boneSegment{boneIndex: bone.Index(), start: bone.Position, end: end})
+	}
+	return segments, nil
+}
+
+// distanceWeights はボーン線分までの距離減衰で正規化済みウェイトを返す。
+func distanceWeights(position mmath.Vec3, segments []boneSegment, falloff float64) map[int]float64 {
+	weights := make(map[int]float64, len(segments))
+	total := 0.0
+	for _, segment := range segments {
+		distance := distanceToSegment(position, segment.start, segment.end)
+		if distance < 1e-6 {
+			// 線分上の頂点はそのボーンに全振りする。
+			return map[int]float64{segment.boneIndex: 1}
+		}
+		w := 1 / math.Pow(distance, falloff)
+		weights[segment.boneIndex] += w
+		total += w
+	}
+	for boneIndex := range weights {
+		weights[boneIndex] /= total
+	}
+	return weights
+}
+
+// distanceToSegment は点から線分までの距離を返す。
+func distanceToSegment(p, start, end mmath.Vec3) float64 {
+	axis := end.Subed(start)
+	lengthSqr := axis.Dot(axis)
+	if lengthSqr < 1e-12 {
+		return p.Distance(start)
+	}
+	t := mmath.Clamped(p.Subed(start).Dot(axis)/lengthSqr, 0, 1)
+	return p.Distance(start.Added(axis.MuledScalar(t)))
+}
+
+// smoothWeights は面で接続された対象頂点間でウェイトを平滑化する。
+func smoothWeights(modelData *model.PmxModel, weights map[int]map[int]float64, iterations int, rate float64) {
+	if iterations <= 0 {
+		iterations = 10
+	}
+	if rate <= 0 || rate > 1 {
+		rate = 0.5
+	}
+	neighbors := make(map[int]map[int]struct{}, len(weights))
+	for _, face := range modelData.Faces.Values() {
+		if face == nil {
+			continue
+		}
+		for i := 0; i < 3; i++ {
+			a := face.VertexIndexes[i]
+			b := face.VertexIndexes[(i+1)%3]
+			if _, ok := weights[a]; !ok {
+				continue
+			}
+			if _, ok := weights[b]; !ok {
+				continue
+			}
+			if neighbors[a] == nil {
+				neighbors[a] = make(map[int]struct{})
+			}
+			if neighbors[b] == nil {
+				neighbors[b] = make(map[int]struct{})
+			}
+			neighbors[a][b] = struct{}{}
+			neighbors[b][a] = struct{}{}
+		}
+	}
+
+	for iter := 0; iter < iterations; iter++ {
+		next := make(map[int]map[int]float64, len(weights))
+		for vertexIndex, current := range weights {
+			adjacent := neighbors[vertexIndex]
+			if len(adjacent) == 0 {
+				next[vertexIndex] = current
+				continue
+			}
+			blended := make(map[int]float64, len(current))
+			for boneIndex, w := range current {
+				blended[boneIndex] += w * (1 - rate)
+			}
+			share := rate / float64(len(adjacent))
+			for neighbor := range adjacent {
+				for boneIndex, w := range weights[neighbor] {
+					blended[boneIndex] += w * share
+				}
+			}
+			next[vertexIndex] = blended
+		}
+		for vertexIndex, w := range next {
+			weights[vertexIndex] = w
+		}
+	}
+}
+
+// applyBoneWeights はウェイトを上位 maxInfluences 本に絞って正規化し、頂点のデフォームへ設定する。
+func applyBoneWeights(vertex *model.Vertex, weights map[int]float64, maxInfluences int, minWeight float64) {
+	if maxInfluences <= 0 || maxInfluences > 4 {
+		maxInfluences = 4
+	}
+	sorted := make([]boneWeight, 0, len(weights))
+	for boneIndex, w := range weights {
+		if w > 0 {
+			sorted = append(sorted, boneWeight{boneIndex: boneIndex, weight: w})
+		}
+	}
+	if len(sorted) == 0 {
+		return
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].weight == sorted[j].weight {
+			return sorted[i].boneIndex < sorted[j].boneIndex
+		}
+		return sorted[i].weight > sorted[j].weight
+	})
+	if len(sorted) > maxInfluences {
+		sorted = sorted[:maxInfluences]
+	}
+	// 最大ウェイトは閾値に関わらず残す。
+	kept := sorted[:1]
+	for _, bw := range sorted[1:] {
+		if bw.weight >= minWeight {
+			kept = append(kept, bw)
+		}
+	}
+	total := 0.0
+	for _, bw := range kept {
+		total += bw.weight
+	}
+	for i := range kept {
+		kept[i].weight /= total
+	}
+	vertex.Deform = newDeformFromWeights(kept)
+	vertex.DeformType = vertex.Deform.DeformType()
+}
+
+// newDeformFromWeights はウェイト数に応じた BDEF デフォームを生成する。
+func newDeformFromWeights(weights []boneWeight) model.IDeform {
+	switch len(weights) {
+	case 1:
+		return model.NewBdef1(weights[0].boneIndex)
+	case 2:
+		return model.NewBdef2(weights[0].boneIndex, weights[1].boneIndex, weights[0].weight)
+	}
+	indexes := [4]int{}
+	values := [4]float64{}
+	for i, bw := range weights {
+		indexes[i] = bw.boneIndex
+		values[i] = bw.weight
+	}
+	return model.NewBdef4(indexes, values)
+}
+
+// surfaceIndex は面を格子へ登録した最近傍面の探索用索引。
+type surfaceIndex struct {
+	faces   []*model.Face
+	corners [][3]mmath.Vec3
+	grid    *positionGrid
+	// visited は探索済みの面を記録する。複数の格子に登録した面を二重に計算しない。
+	visited []int
+	query   int
+}
+
+// newSurfaceIndex は有効な面を、面の範囲と重なる格子へ登録する。
+func newSurfaceIndex(modelData *model.PmxModel) *surfaceIndex {
+	index := &surfaceIndex{}
+	boxMin := mmath.Vec3{}
+	boxMax := mmath.Vec3{}
+	extentTotal := 0.0
+	for _, face := range modelData.Faces.Values() {
+		if face == nil {
+			continue
+		}
+		var corners [3]mmath.Vec3
+		valid := true
+		for i, vertexIndex := range face.VertexIndexes {
+			vertex, err := modelData.Vertices.Get(vertexIndex)
+			if err != nil || vertex == nil {
+				valid = false
+				break
+			}
+			corners[i] = vertex.Position
+		}
+		if !valid {
+			continue
+		}
+		faceMin, faceMax := triangleBounds(corners)
+		if len(index.faces) == 0 {
+			boxMin, boxMax = faceMin, faceMax
+		}
+		boxMin = minVec3(boxMin, faceMin)
+		boxMax = maxVec3(boxMax, faceMax)
+		extent := faceMax.Subed(faceMin)
+		extentTotal += max(extent.X, extent.Y, extent.Z)
+		index.faces = append(index.faces, face)
+		index.corners = append(index.corners, corners)
+	}
+
+	// 面の大きさに合わせつつ、大きな面が多数の格子へ登録されないよう面数に応じた下限を設ける。
+	cellSize := 1.0
+	if len(index.faces) > 0 {
+		size := boxMax.Subed(boxMin)
+		cellSize = max(
+			extentTotal/float64(len(index.faces)),
+			max(size.X, size.Y, size.Z)/math.Cbrt(float64(len(index.faces))),
+		)
+		if cellSize <= 1e-9 {
+			cellSize = 1
+		}
+	}
+	index.grid = newPositionGrid(cellSize)
+	for i, corners := range index.corners {
+		faceMin, faceMax := triangleBounds(corners)
+		index.grid.addBox(faceMin, faceMax, i)
+	}
+	index.visited = make([]int, len(index.faces))
+	return index
+}
+
+// nearest はモデル表面上で最も近い面と重心座標を返す。
+// 近い格子から順に探索し、未探索の格子にそれより近い面が無いと分かった時点で打ち切る。
+func (s *surfaceIndex) nearest(p mmath.Vec3) (*model.Face, [3]float64, bool) {
+	if len(s.faces) == 0 {
+		return nil, [3]float64{}, false
+	}
+	s.query++
+	center := s.grid.keyOf(p)
+	firstRing, lastRing := s.grid.ringRange(center)
+	nearest := -1
+	var nearestBary [3]float64
+	nearestDistance := math.MaxFloat64
+	for ring := firstRing; ring <= lastRing; ring++ {
+		s.grid.eachRing(center, ring, func(i int) {
+			if s.visited[i] == s.query {
+				return
+			}
+			s.visited[i] = s.query
+			corners := s.corners[i]
+			closest, bary := closestPointOnTriangle(p, corners[0], corners[1], corners[2])
+			if distance := p.Distance(closest); distance < nearestDistance ||
+				(distance == nearestDistance && i < nearest) {
+				nearest = i
+				nearestBary = bary
+				nearestDistance = distance
+			}
+		})
+		// 距離 ring+1 以上の格子は、点から少なくとも ring 個分の格子だけ離れている。
+		if nearest >= 0 && nearestDistance <= float64(ring)*s.grid.cellSize {
+			break
+		}
+	}
+	if nearest < 0 {
+		return nil, [3]float64{}, false
+	}
+	return s.faces[nearest], nearestBary, true
+}
+
+// triangleBounds は三角形を囲む軸平行な範囲を返す。
+func triangleBounds(corners [3]mmath.Vec3) (mmath.Vec3, mmath.Vec3) {
+	return minVec3(minVec3(corners[0], corners[1]), corners[2]), maxVec3(maxVec3(corners[0], corners[1]), corners[2])
+}
+
+// minVec3 は成分ごとの最小値を返す。
+func minVec3(a, b mmath.Vec3) mmath.Vec3 {
+	return mmath.Vec3{Vec: r3.Vec{X: min(a.X, b.X), Y: min(a.Y, b.Y), Z: min(a.Z, b.Z)}}
+}
+
+// maxVec3 は成分ごとの最大値を返す。
+func maxVec3(a, b mmath.Vec3) mmath.Vec3 {
+	return mmath.Vec3{Vec: r3.Vec{X: max(a.X, b.X), Y: max(a.Y, b.Y), Z: max(a.Z, b.Z)}}
+}
+
+// closestPointOnTriangle は三角形上の最近傍点と重心座標を返す。
+func closestPointOnTriangle(p, a, b, c mmath.Vec3) (mmath.Vec3, [3]float64) {
+	ab := b.Subed(a)
+	ac := c.Subed(a)
+	ap := p.Subed(a)
+	d1 := ab.Dot(ap)
+	d2 := ac.Dot(ap)
+	if d1 <= 0 && d2 <= 0 {
+		return a, [3]float64{1, 0, 0}
+	}
+	bp := p.Subed(b)
+	d3 := ab.Dot(bp)
+	d4 := ac.Dot(bp)
+	if d3 >= 0 && d4 <= d3 {
+		return b, [3]float64{0, 1, 0}
+	}
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		v := d1 / (d1 - d3)
+		return a.Added(ab.MuledScalar(v)), [3]float64{1 - v, v, 0}
+	}
+	cp := p.Subed(c)
+	d5 := ab.Dot(cp)
+	d6 := ac.Dot(cp)
+	if d6 >= 0 && d5 <= d6 {
+		return c, [3]float64{0, 0, 1}
+	}
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		w := d2 / (d2 - d6)
+		return a.Added(ac.MuledScalar(w)), [3]float64{1 - w, 0, w}
+	}
+	va := d3*d6 - d5*d4
+	if va <= 0 && (d4-d3) >= 0 && (d5-d6) >= 0 {
+		w := (d4 - d3) / ((d4 - d3) + (d5 - d6))
+		return b.Added(c.Subed(b).MuledScalar(w)), [3]float64{0, 1 - w, w}
+	}
+	denom := 1 / (va + vb + vc)
+	v := vb * denom
+	w := vc * denom
+	return a.Added(ab.MuledScalar(v)).Added(ac.MuledScalar(w)), [3]float64{1 - v - w, v, w}
+}
@@ -0,0 +1,216 @@
+// 指示: miu200521358
+package mmodel
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// newWeightTestModel は上腕・ひじ・手首と、腕に沿った帯状メッシュを持つモデルを生成する。
+func newWeightTestModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	arm := appendTestBone(m, "腕", -1)
+	arm.Position = vec3(0, 0, 0)
+	elbow := appendTestBone(m, "ひじ", arm.Index())
+	elbow.Position = vec3(4, 0, 0)
+	wrist := appendTestBone(m, "手首", elbow.Index())
+	wrist.Position = vec3(8, 0, 0)
+	ik := appendTestBone(m, "IK", -1)
+	ik.Position = vec3(1, 0, 0)
+	ik.BoneFlag |= model.BONE_FLAG_IS_IK
+
+	// x=0..8 に上下2列の頂点を並べ、隣接列を面で繋ぐ。
+	for i := 0; i <= 8; i++ {
+		for _, y := range []float64{0.5, -0.5} {
+			m.Vertices.Append(&model.Vertex{Position: vec3(float64(i), y, 0), Deform: model.NewBdef1(0)})
+		}
+	}
+	for i := 0; i < 8; i++ {
+		a, b, c, d := i*2, i*2+1, i*2+2, i*2+3
+		m.Faces.Append(&model.Face{VertexIndexes: [3]int{a, b, c}})
+		m.Faces.Append(&model.Face{VertexIndexes: [3]int{b, d, c}})
+	}
+	return m
+}
+
+// weightOf は頂点デフォームの指定ボーンのウェイトを返す。
+func weightOf(vertex *model.Vertex, boneIndex int) float64 {
+	weights := vertex.Deform.Weights()
+	total := 0.0
+	for i, idx := range vertex.Deform.Indexes() {
+		if idx == boneIndex && i < len(weights) {
+			total += weights[i]
+		}
+	}
+	return total
+}
+
+// TestGenerateWeightsDistance は距離減衰で近いボーンほど重くなることを確認する。
+func TestGenerateWeightsDistance(t *testing.T) {
+	m := newWeightTestModel()
+	count, err := GenerateWeights(m, WeightGenerateOptions{MaxInfluences: 2, MinWeight: 0.05})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != m.Vertices.Len() {
+		t.Fatalf("count mismatch: %d", count)
+	}
+	near, _ := m.Vertices.Get(2)
+	if weightOf(near, 0) <= weightOf(near, 1) {
+		t.Fatalf("vertex near arm should favor arm: %v %v", near.Deform.Indexes(), near.Deform.Weights())
+	}
+	far, _ := m.Vertices.Get(12)
+	if weightOf(far, 1) <= weightOf(far, 0) {
+		t.Fatalf("vertex near elbow should favor elbow: %v %v", far.Deform.Indexes(), far.Deform.Weights())
+	}
+	for _, vertex := range m.Vertices.Values() {
+		if vertex.DeformType == model.BDEF4 {
+			t.Fatalf("influence limit exceeded: %v", vertex.Deform.Indexes())
+		}
+		if weightOf(vertex, 3) > 0 {
+			t.Fatalf("IK bone should not receive weights")
+		}
+		total := 0.0
+		for _, w := range vertex.Deform.Weights() {
+			total += w
+		}
+		if vertex.DeformType != model.BDEF1 && math.Abs(total-1) > 1e-6 {
+			t.Fatalf("weights not normalized: %v", vertex.Deform.Weights())
+		}
+	}
+}
+
+// TestGenerateWeightsSmoothedRestrict は候補ボーン制限と平滑化が適用されることを確認する。
+func TestGenerateWeightsSmoothedRestrict(t *testing.T) {
+	m := newWeightTestModel()
+	distance := newWeightTestModel()
+	opts := WeightGenerateOptions{BoneNames: []string{"腕", "ひじ"}, VertexIndexes: []int{6, 7, 8, 9}}
+	if _, err := GenerateWeights(distance, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts.Method = WEIGHT_METHOD_SMOOTHED_DISTANCE
+	if _, err := GenerateWeights(m, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	untouched, _ := m.Vertices.Get(0)
+	if untouched.Deform.Indexes()[0] != 0 || untouched.DeformType != model.BDEF1 {
+		t.Fatalf("non-target vertex should not change")
+	}
+	smoothed, _ := m.Vertices.Get(8)
+	plain, _ := distance.Vertices.Get(8)
+	if weightOf(smoothed, 2) > 0 {
+		t.Fatalf("restricted bone should not receive weights")
+	}
+	if math.Abs(weightOf(smoothed, 1)-weightOf(plain, 1)) < 1e-9 {
+		t.Fatalf("smoothing should change weights")
+	}
+
+	_, err := GenerateWeights(m, WeightGenerateOptions{BoneNames: []string{"存在しない"}})
+	if merr.ExtractErrorID(err) != weightBoneNotFoundErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestGenerateWeightsSkipsControlBones は既定の候補に操作用ボーンを含めないことを確認する。
+func TestGenerateWeightsSkipsControlBones(t *testing.T) {
+	m := newWeightTestModel()
+	for _, name := range []string{model.ROOT.String(), model.CENTER.String(), model.GROOVE.String()} {
+		bone := appendTestBone(m, name, -1)
+		bone.Position = vec3(4, 0.5, 0)
+	}
+	if _, err := GenerateWeights(m, WeightGenerateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, vertex := range m.Vertices.Values() {
+		for _, boneIndex := range vertex.Deform.Indexes() {
+			if boneIndex >= 4 {
+				t.Fatalf("control bone should not receive weights: %v", vertex.Deform.Indexes())
+			}
+		}
+	}
+}
+
+// TestTransferWeights は最近傍面のウェイトが補間されて転写されることを確認する。
+func TestTransferWeights(t *testing.T) {
+	source := model.NewPmxModel()
+	appendTestBone(source, "腕", -1)
+	appendTestBone(source, "ひじ", 0)
+	source.Vertices.Append(&model.Vertex{Position: vec3(0, 0, 0), Deform: model.NewBdef1(0)})
+	source.Vertices.Append(&model.Vertex{Position: vec3(2, 0, 0), Deform: model.NewBdef1(1)})
+	source.Vertices.Append(&model.Vertex{Position: vec3(0, 2, 0), Deform: model.NewBdef1(0)})
+	source.Faces.Append(&model.Face{VertexIndexes: [3]int{0, 1, 2}})
+
+	target := model.NewPmxModel()
+	appendTestBone(target, "ひじ", -1)
+	appendTestBone(target, "腕", -1)
+	target.Vertices.Append(&model.Vertex{Position: vec3(1, 0, 1), Deform: model.NewBdef1(0)})
+
+	count, err := TransferWeights(target, source, WeightTransferOptions{})
+	if err != nil || count != 1 {
+		t.Fatalf("unexpected result: count=%d err=%v", count, err)
+	}
+	vertex, _ := target.Vertices.Get(0)
+	if math.Abs(weightOf(vertex, 0)-0.5) > 1e-6 || math.Abs(weightOf(vertex, 1)-0.5) > 1e-6 {
+		t.Fatalf("transfer weights mismatch: %v %v", vertex.Deform.Indexes(), vertex.Deform.Weights())
+	}
+
+	_, err = TransferWeights(target, model.NewPmxModel(), WeightTransferOptions{})
+	if merr.ExtractErrorID(err) != weightTransferNoSurfaceErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestSurfaceIndexNearest は格子探索の最近傍面が全面探索と一致することを確認する。
+func TestSurfaceIndexNearest(t *testing.T) {
+	m := model.NewPmxModel()
+	// 大きさの異なる面が混在する凹凸のある格子状メッシュ。
+	for z := 0; z <= 10; z++ {
+		for x := 0; x <= 10; x++ {
+			y := math.Sin(float64(x)*0.7) * math.Cos(float64(z)*0.4) * 2
+			m.Vertices.Append(&model.Vertex{Position: vec3(float64(x)*float64(x)*0.2, y, float64(z)), Deform: model.NewBdef1(0)})
+		}
+	}
+	for z := 0; z < 10; z++ {
+		for x := 0; x < 10; x++ {
+			a, b, c, d := z*11+x, z*11+x+1, (z+1)*11+x, (z+1)*11+x+1
+			m.Faces.Append(&model.Face{VertexIndexes: [3]int{a, b, c}})
+			m.Faces.Append(&model.Face{VertexIndexes: [3]int{b, d, c}})
+		}
+	}
+
+	surfaces := newSurfaceIndex(m)
+	for i := 0; i < 200; i++ {
+		p := vec3(math.Mod(float64(i)*7.3, 30)-5, math.Mod(float64(i)*1.7, 12)-6, math.Mod(float64(i)*3.1, 16)-3)
+		face, bary, ok := surfaces.nearest(p)
+		if !ok {
+			t.Fatalf("nearest face should be found: %v", p)
+		}
+		got := surfacePoint(m, face, bary)
+
+		want := math.MaxFloat64
+		for _, candidate := range m.Faces.Values() {
+			a, _ := m.Vertices.Get(candidate.VertexIndexes[0])
+			b, _ := m.Vertices.Get(candidate.VertexIndexes[1])
+			c, _ := m.Vertices.Get(candidate.VertexIndexes[2])
+			closest, _ := closestPointOnTriangle(p, a.Position, b.Position, c.Position)
+			want = math.Min(want, p.Distance(closest))
+		}
+		if math.Abs(p.Distance(got)-want) > 1e-9 {
+			t.Fatalf("nearest distance mismatch at %v: got=%v want=%v", p, p.Distance(got), want)
+		}
+	}
+}
+
+// surfacePoint は面上の重心座標の位置を返す。
+func surfacePoint(m *model.PmxModel, face *model.Face, bary [3]float64) mmath.Vec3 {
+	point := mmath.Vec3{}
+	for i, vertexIndex := range face.VertexIndexes {
+		vertex, _ := m.Vertices.Get(vertexIndex)
+		point = point.Added(vertex.Position.MuledScalar(bary[i]))
+	}
+	return point
+}
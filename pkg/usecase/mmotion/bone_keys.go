@@ -0,0 +1,151 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"slices"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+)
+
+// collectBoneKeyFrames は対象トラックのキーフレーム番号を昇順で重複なく返す。
+func collectBoneKeyFrames(motionData *motion.VmdMotion, names []string) []motion.Frame {
+	seen := map[motion.Frame]struct{}{}
+	frames := make([]motion.Frame, 0)
+	for _, name := range names {
+		if !motionData.BoneFrames.Has(name) {
+			continue
+		}
+		motionData.BoneFrames.Get(name).ForEach(func(frame motion.Frame, _ *motion.BoneFrame) bool {
+			if _, ok := seen[frame]; !ok {
+				seen[frame] = struct{}{}
+				frames = append(frames, frame)
+			}
+			return true
+		})
+	}
+	slices.Sort(frames)
+	return frames
+}
+
+// readBoneRotations は各フレームの補間済み回転を返す。トラックが無い場合は単位回転。
+func readBoneRotations(boneFrames *motion.BoneFrames, name string, frames []motion.Frame) []mmath.Quaternion {
+	rotations := make([]mmath.Quaternion, len(frames))
+	for i := range rotations {
+		rotations[i] = mmath.NewQuaternion()
+	}
+	if !boneFrames.Has(name) {
+		return rotations
+	}
+	nameFrames := boneFrames.Get(name)
+	for i, frame := range frames {
+		if bf := nameFrames.Get(frame); bf != nil && bf.Rotation != nil {
+			rotations[i] = *bf.Rotation
+		}
+	}
+	return rotations
+}
+
+// readBonePositions は各フレームの補間済み移動量を返す。トラックが無い場合は0。
+func readBonePositions(boneFrames *motion.BoneFrames, name string, frames []motion.Frame) []mmath.Vec3 {
+	positions := make([]mmath.Vec3, len(frames))
+	if !boneFrames.Has(name) {
+		return positions
+	}
+	nameFrames := boneFrames.Get(name)
+	for i, frame := range frames {
+		if bf := nameFrames.Get(frame); bf != nil && bf.Position != nil {
+			positions[i] = *bf.Position
+		}
+	}
+	return positions
+}
+
+// writeBoneRotations は各フレームの回転を登録する。
+func writeBoneRotations(
+	motionData *motion.VmdMotion,
+	name string,
+	frames []motion.Frame,
+	rotations []mmath.Quaternion,
+) bool {
+	return writeBonePoses(motionData, name, frames, rotations, nil)
+}
+
+// writeBonePoses は各フレームの回転と移動量を登録する。positions が nil の場合は移動量を変更しない。
+// トラックが無く全て初期姿勢の場合は登録しない。
+// 既存キーフレームは値のみ差し替え、無いフレームは補間曲線を分割して挿入する。
+func writeBonePoses(
+	motionData *motion.VmdMotion,
+	name string,
+	frames []motion.Frame,
+	rotations []mmath.Quaternion,
+	positions []mmath.Vec3,
+) bool {
+	if !motionData.BoneFrames.Has(name) && isInitialPoses(rotations, positions) {
+		return false
+	}
+	boneFrames := motionData.BoneFrames.Get(name)
+	inserted := make([]*motion.BoneFrame, 0)
+	for i, frame := range frames {
+		rotation := rotations[i]
+		target := boneFrames.Get(frame)
+		if !boneFrames.Has(frame) {
+			// 補間値の読み出しに影響しないよう、挿入はまとめて後で行う。
+			bf := motion.NewBoneFrame(frame)
+			if target != nil {
+				if copied, err := target.Copy(); err == nil && copied.BaseFrame != nil {
+					bf = &copied
+				}
+			}
+			target = bf
+			inserted = append(inserted, bf)
+		}
+		target.Rotation = &rotation
+		if positions != nil {
+			position := positions[i]
+			target.Position = &position
+		}
+	}
+	for _, bf := range inserted {
+		boneFrames.Insert(bf)
+	}
+	return true
+}
+
+// isInitialPoses は全ての回転と移動量が初期姿勢か判定する。
+func isInitialPoses(rotations []mmath.Quaternion, positions []mmath.Vec3) bool {
+	identity := mmath.NewQuaternion()
+	for _, rotation := range rotations {
+		if !rotation.NearEquals(identity, 1e-8) {
+			return false
+		}
+	}
+	for _, position := range positions {
+		if !position.NearEquals(mmath.ZERO_VEC3, 1e-8) {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshotBoneFrames は変換前の参照用にボーンキーフレームを複製する。
+func snapshotBoneFrames(boneFrames *motion.BoneFrames) (*motion.BoneFrames, error) {
+	snapshot := motion.NewBoneFrames()
+	for _, name := range boneFrames.Names() {
+		nameFrames := snapshot.Get(name)
+		var copyErr error
+		boneFrames.Get(name).ForEach(func(_ motion.Frame, bf *motion.BoneFrame) bool {
+			copied, err := bf.Copy()
+			if err != nil {
+				copyErr = err
+				return false
+			}
+			nameFrames.Update(&copied)
+			return true
+		})
+		if copyErr != nil {
+			return nil, copyErr
+		}
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,265 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"cmp"
+	"math"
+	"math/rand"
+	"slices"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/deform"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/shared/contracts/mtime"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+const (
+	cameraTargetBoneNotFoundErrorID = "13514"
+	// defaultFollowCameraViewOfAngle は視野角未指定時の既定値。
+	defaultFollowCameraViewOfAngle = 30
+	// cameraShakeOctaves は揺れのノイズを重ねる周波数の数。
+	cameraShakeOctaves = 3
+)
+
+// CameraCut はカット点と、カット後の構図を表す。
+type CameraCut struct {
+	Frame    motion.Frame
+	Degrees  mmath.Vec3
+	Distance float64
+}
+
+// CameraShake は手持ち風の揺れを表す。
+type CameraShake struct {
+	// PositionAmplitude は注視点の揺れ幅。
+	PositionAmplitude mmath.Vec3
+	// DegreesAmplitude は角度の揺れ幅（度）。
+	DegreesAmplitude mmath.Vec3
+	// Frequency は1秒あたりの揺れの回数。
+	Frequency float64
+	// Seed は揺れの乱数シード。同じシードなら同じ揺れになる。
+	Seed int64
+}
+
+// CameraFollowOptions はボーン追従カメラの構図ルールを表す。
+type CameraFollowOptions struct {
+	// BoneName は追従するボーン名。
+	BoneName   string
+	StartFrame motion.Frame
+	// EndFrame は終了フレーム。0 の場合はモーションの最終フレーム。
+	EndFrame motion.Frame
+	// Offset はボーン位置から注視点へのずれ（グローバル座標）。
+	Offset mmath.Vec3
+	// Degrees はカメラ角度（度）。
+	Degrees mmath.Vec3
+	// Distance はVMDのカメラ距離。正面から撮る場合は負値。
+	Distance float64
+	// ViewOfAngle は視野角。0 の場合は既定値。
+	ViewOfAngle int
+	// FollowYaw はボーンの向きに合わせてカメラを水平に回り込ませるか。
+	FollowYaw bool
+	// DeadZone は注視点から離れてもカメラを動かさない距離。
+	DeadZone float64
+	// Smoothing は追従の遅れ（0:即時追従 - 1未満:大きいほど緩やか）。
+	Smoothing float64
+	// MaxAngularSpeed は1フレームあたりの角度変化の上限（度）。0 の場合は無制限。
+	MaxAngularSpeed float64
+	// Cuts はカット点。カット点では注視点と角度を即座に切り替える。
+	Cuts []CameraCut
+	// Shake は手持ち風の揺れ。nil の場合は揺らさない。
+	Shake *CameraShake
+}
+
+// GenerateFollowCameraFrames はボーンを追従するカメラフレームを生成し、削減して返す。
+// ボーン位置はIKを含めたモーション変形から求める。
+func GenerateFollowCameraFrames(
+	modelData *model.PmxModel,
+	motionData *motion.VmdMotion,
+	options CameraFollowOptions,
+) (*motion.CameraFrames, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if motionData == nil {
+		return nil, merr.NewCommonError(motionNotSpecifiedErrorID, merr.ErrorKindValidate, messages.MotionNotSpecified, nil)
+	}
+	bone, err := modelData.Bones.GetByName(options.BoneName)
+	if err != nil || bone == nil {
+		return nil, merr.NewCommonError(
+			cameraTargetBoneNotFoundErrorID,
+			merr.ErrorKindValidate,
+			messages.CameraTargetBoneNotFound,
+			err,
+			options.BoneName,
+		)
+	}
+
+	endFrame := options.EndFrame
+	if endFrame <= 0 {
+		endFrame = motionData.MaxFrame()
+	}
+	viewOfAngle := options.ViewOfAngle
+	if viewOfAngle <= 0 {
+		viewOfAngle = defaultFollowCameraViewOfAngle
+	}
+	cuts := slices.Clone(options.Cuts)
+	slices.SortFunc(cuts, func(a, b CameraCut) int { return cmp.Compare(a.Frame, b.Frame) })
+	shake := newCameraShakeNoise(options.Shake)
+
+	state := followCameraState{baseDegrees: options.Degrees, distance: options.Distance}
+	frames := motion.NewCameraFrames()
+	for frame := options.StartFrame; frame <= endFrame; frame++ {
+		reset := frame == options.StartFrame
+		for len(cuts) > 0 && cuts[0].Frame <= frame {
+			state.baseDegrees = cuts[0].Degrees
+			state.distance = cuts[0].Distance
+			reset = true
+			cuts = cuts[1:]
+		}
+		center, yaw := followCameraTarget(modelData, motionData, bone, frame)
+		state.update(center.Added(options.Offset), yaw, reset, options)
+
+		position := state.center
+		degrees := state.degrees
+		if shake != nil {
+			positionNoise, degreesNoise := shake.at(frame)
+			position = position.Added(positionNoise)
+			degrees = degrees.Added(degreesNoise)
+		}
+		cf := motion.NewCameraFrame(frame)
+		cf.Position = &position
+		cf.Degrees = &degrees
+		cf.Distance = state.distance
+		cf.ViewOfAngle = viewOfAngle
+		cf.IsPerspectiveOff = false
+		frames.Append(cf)
+	}
+	return frames.Reduce(), nil
+}
+
+// followCameraTarget は指定フレームのボーンのグローバル位置と水平方向の向き（度）を返す。
+func followCameraTarget(
+	modelData *model.PmxModel,
+	motionData *motion.VmdMotion,
+	bone *model.Bone,
+	frame motion.Frame,
+) (mmath.Vec3, float64) {
+	boneDeltas, indexes := deform.ComputeBoneDeltas(
+		modelData, motionData, frame, []string{bone.Name()}, true, false, false, nil)
+	deform.ApplyBoneMatricesWithIndexes(modelData, boneDeltas, indexes)
+	boneDelta := boneDeltas.Get(bone.Index())
+	if boneDelta == nil {
+		return bone.Position, 0
+	}
+	// モデルの正面は -Z 方向のため、回転後の正面から水平角を求める。
+	front := boneDelta.FilledGlobalMatrix().Quaternion().MulVec3(mmath.UNIT_Z_VEC3.Negated())
+	yaw := 0.0
+	if math.Abs(front.X)+math.Abs(front.Z) > 1e-8 {
+		yaw = mmath.RadToDeg(math.Atan2(-front.X, -front.Z))
+	}
+	return boneDelta.FilledGlobalPosition(), yaw
+}
+
+// followCameraState は追従中のカメラ状態を保持する。
+type followCameraState struct {
+	baseDegrees mmath.Vec3
+	distance    float64
+	center      mmath.Vec3
+	yaw         float64
+	degrees     mmath.Vec3
+}
+
+// update は追従対象に向けて状態を1フレーム進める。reset の場合は即座に合わせる。
+func (s *followCameraState) update(target mmath.Vec3, targetYaw float64, reset bool, options CameraFollowOptions) {
+	if !options.FollowYaw {
+		targetYaw = 0
+	}
+	if reset {
+		s.center = target
+		s.yaw = targetYaw
+		s.degrees = s.desiredDegrees()
+		return
+	}
+
+	follow := 1 - mmath.Clamped(options.Smoothing, 0, 1)
+	diff := target.Subed(s.center)
+	if length := diff.Length(); length > options.DeadZone {
+		// 不感帯の外へ出た分だけ追従する。
+		s.center = s.center.Added(diff.MuledScalar((length - options.DeadZone) / length * follow))
+	}
+	s.yaw += wrapDegrees(targetYaw-s.yaw) * follow
+
+	desired := s.desiredDegrees()
+	step := desired.Subed(s.degrees)
+	if options.MaxAngularSpeed > 0 {
+		step.X = mmath.Clamped(step.X, -options.MaxAngularSpeed, options.MaxAngularSpeed)
+		step.Y = mmath.Clamped(step.Y, -options.MaxAngularSpeed, options.MaxAngularSpeed)
+		step.Z = mmath.Clamped(step.Z, -options.MaxAngularSpeed, options.MaxAngularSpeed)
+	}
+	s.degrees = s.degrees.Added(step)
+}
+
+// desiredDegrees は現在の向きで目標とするカメラ角度を返す。
+func (s *followCameraState) desiredDegrees() mmath.Vec3 {
+	degrees := s.baseDegrees
+	// MMDカメラのY角はモデルの回転と逆回りになる。
+	degrees.Y -= s.yaw
+	return degrees
+}
+
+// wrapDegrees は角度を -180 から 180 の範囲へ丸める。
+func wrapDegrees(degrees float64) float64 {
+	wrapped := math.Mod(degrees+180, 360)
+	if wrapped < 0 {
+		wrapped += 360
+	}
+	return wrapped - 180
+}
+
+// cameraShakeNoise は位相の異なる正弦波を重ねた揺れを生成する。
+type cameraShakeNoise struct {
+	shake  CameraShake
+	phases [6][cameraShakeOctaves]float64
+}
+
+// newCameraShakeNoise は揺れの設定からノイズを生成する。揺らさない場合は nil を返す。
+func newCameraShakeNoise(shake *CameraShake) *cameraShakeNoise {
+	if shake == nil || shake.Frequency <= 0 {
+		return nil
+	}
+	noise := &cameraShakeNoise{shake: *shake}
+	random := rand.New(rand.NewSource(shake.Seed))
+	for ch := range noise.phases {
+		for octave := range noise.phases[ch] {
+			noise.phases[ch][octave] = random.Float64() * 2 * math.Pi
+		}
+	}
+	return noise
+}
+
+// at は指定フレームの注視点と角度の揺れを返す。
+func (n *cameraShakeNoise) at(frame motion.Frame) (mmath.Vec3, mmath.Vec3) {
+	seconds := float64(mtime.FramesToSeconds(frame, mtime.DefaultFps))
+	var values [6]float64
+	for ch := range values {
+		total := 0.0
+		weight := 0.0
+		for octave := 0; octave < cameraShakeOctaves; octave++ {
+			scale := math.Pow(2, float64(octave))
+			total += math.Sin(2*math.Pi*n.shake.Frequency*scale*seconds+n.phases[ch][octave]) / scale
+			weight += 1 / scale
+		}
+		values[ch] = total / weight
+	}
+	position := mmath.Vec3{}
+	position.X = values[0] * n.shake.PositionAmplitude.X
+	position.Y = values[1] * n.shake.PositionAmplitude.Y
+	position.Z = values[2] * n.shake.PositionAmplitude.Z
+	degrees := mmath.Vec3{}
+	degrees.X = values[3] * n.shake.DegreesAmplitude.X
+	degrees.Y = values[4] * n.shake.DegreesAmplitude.Y
+	degrees.Z = values[5] * n.shake.DegreesAmplitude.Z
+	return position, degrees
+}
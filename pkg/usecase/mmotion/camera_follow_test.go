@@ -0,0 +1,116 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+)
+
+// newFollowCameraTestData はセンターが X 方向へ移動しながら振り向くモデルとモーションを生成する。
+func newFollowCameraTestData() (*model.PmxModel, *motion.VmdMotion) {
+	m := newSemiStandardTestModel([]semiStandardTestBone{{name: "センター", position: vec3Of(0, 8, 0)}})
+	vmd := motion.NewVmdMotion("")
+	registerPose(vmd, "センター", 0, mmath.NewQuaternion(), vec3Of(0, 0, 0))
+	registerPose(vmd, "センター", 30, mmath.NewQuaternionFromAxisAngles(mmath.UNIT_Y_VEC3, math.Pi/2), vec3Of(30, 0, 0))
+	return m, vmd
+}
+
+// TestGenerateFollowCameraFrames は追従と削減を確認する。
+func TestGenerateFollowCameraFrames(t *testing.T) {
+	m, vmd := newFollowCameraTestData()
+	frames, err := GenerateFollowCameraFrames(m, vmd, CameraFollowOptions{
+		BoneName: "センター",
+		Offset:   vec3Of(0, 2, 0),
+		Degrees:  vec3Of(10, 0, 0),
+		Distance: -45,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if frames.Len() >= 31 {
+		t.Fatalf("frames should be reduced: %d", frames.Len())
+	}
+	for i := 0; i <= 30; i++ {
+		cf := frames.Get(motion.Frame(i))
+		if !cf.Position.NearEquals(vec3Of(float64(i), 10, 0), 1e-1) {
+			t.Fatalf("position mismatch at %d: %v", i, cf.Position)
+		}
+		if !cf.Degrees.NearEquals(vec3Of(10, 0, 0), 1e-1) || cf.Distance != -45 || cf.ViewOfAngle != 30 {
+			t.Fatalf("framing mismatch at %d: %v %v %v", i, cf.Degrees, cf.Distance, cf.ViewOfAngle)
+		}
+	}
+}
+
+// TestGenerateFollowCameraFramesRules は不感帯/角速度制限/カット/揺れを確認する。
+func TestGenerateFollowCameraFramesRules(t *testing.T) {
+	m, vmd := newFollowCameraTestData()
+
+	deadZone, err := GenerateFollowCameraFrames(m, vmd, CameraFollowOptions{BoneName: "センター", EndFrame: 10, DeadZone: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if x := deadZone.Get(4).Position.X; math.Abs(x) > 1e-6 {
+		t.Fatalf("camera should stay inside dead zone: %v", x)
+	}
+	if x := deadZone.Get(10).Position.X; math.Abs(x-5) > 1e-1 {
+		t.Fatalf("camera should trail by dead zone: %v", x)
+	}
+
+	limited, err := GenerateFollowCameraFrames(m, vmd, CameraFollowOptions{
+		BoneName:        "センター",
+		FollowYaw:       true,
+		MaxAngularSpeed: 1,
+		Smoothing:       0.5,
+		Cuts:            []CameraCut{{Frame: 20, Degrees: vec3Of(0, 180, 0), Distance: -30}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 1; i < 20; i++ {
+		step := limited.Get(motion.Frame(i)).Degrees.Y - limited.Get(motion.Frame(i-1)).Degrees.Y
+		if math.Abs(step) > 1+1e-1 {
+			t.Fatalf("angular speed exceeded at %d: %v", i, step)
+		}
+	}
+	cut := limited.Get(20)
+	if !cut.Position.NearEquals(vec3Of(20, 8, 0), 1e-1) || cut.Distance != -30 {
+		t.Fatalf("cut should snap to target: %v %v", cut.Position, cut.Distance)
+	}
+	if math.Abs(cut.Degrees.Y-(180-60)) > 1e-1 {
+		t.Fatalf("cut should follow bone yaw: %v", cut.Degrees)
+	}
+
+	shakeOptions := CameraFollowOptions{
+		BoneName: "センター",
+		Shake:    &CameraShake{PositionAmplitude: vec3Of(0.5, 0.5, 0), DegreesAmplitude: vec3Of(1, 1, 0), Frequency: 2, Seed: 7},
+	}
+	shaken, _ := GenerateFollowCameraFrames(m, vmd, shakeOptions)
+	again, _ := GenerateFollowCameraFrames(m, vmd, shakeOptions)
+	moved := false
+	for i := 0; i <= 30; i++ {
+		a := shaken.Get(motion.Frame(i))
+		if !a.Position.NearEquals(*again.Get(motion.Frame(i)).Position, 1e-8) {
+			t.Fatalf("shake should be deterministic at %d", i)
+		}
+		if !a.Position.NearEquals(vec3Of(float64(i), 8, 0), 1e-2) {
+			moved = true
+		}
+	}
+	if !moved {
+		t.Fatalf("shake should move the camera")
+	}
+}
+
+// TestGenerateFollowCameraFramesBoneNotFound は追従ボーンが無い場合のエラーを確認する。
+func TestGenerateFollowCameraFramesBoneNotFound(t *testing.T) {
+	m, vmd := newFollowCameraTestData()
+	_, err := GenerateFollowCameraFrames(m, vmd, CameraFollowOptions{BoneName: "頭"})
+	if ce, ok := err.(*merr.CommonError); !ok || ce.ErrorID() != cameraTargetBoneNotFoundErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
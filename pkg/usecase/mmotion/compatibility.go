@@ -0,0 +1,327 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+	"golang.org/x/text/width"
+)
+
+const (
+	modelNotSpecifiedErrorID = "13521"
+)
+
+// NameMatchKind は名前の近似一致の種類を表す。
+type NameMatchKind int
+
+const (
+	// NAME_MATCH_NORMALIZED は全角/半角・前後の空白・大文字/小文字の違いのみ。
+	NAME_MATCH_NORMALIZED NameMatchKind = iota + 1
+	// NAME_MATCH_SIDE は左右表記(左/右 と _L/.L 等)の違い。
+	NAME_MATCH_SIDE
+	// NAME_MATCH_ALIAS は既知の別名。
+	NAME_MATCH_ALIAS
+)
+
+// BONE_NAME_ALIASES はボーン名の既知の別名の組。{d} は左右を表す。
+var BONE_NAME_ALIASES = [][]string{
+	{model.KNEE.String(), "{d}膝"},
+	{model.ELBOW.String(), "{d}肘"},
+	{model.LEG_IK.String(), "{d}脚ＩＫ"},
+	{model.TOE_IK.String(), "{d}つまさきＩＫ"},
+	{model.ROOT.String(), "全ての親ボーン", "全親"},
+}
+
+// MORPH_NAME_ALIASES はモーフ名の既知の別名の組。
+var MORPH_NAME_ALIASES = [][]string{
+	{"まばたき", "瞬き"},
+	{"ウィンク", "ウインク"},
+	{"ウィンク右", "ウインク右"},
+	{"ウィンク２", "ウインク２"},
+	{"ウィンク２右", "ウインク２右"},
+	{"にやり", "ニヤリ"},
+}
+
+// NameSuggestion はモーションのトラック名に対するモデル側の候補名を表す。
+type NameSuggestion struct {
+	Source string
+	Target string
+	Kind   NameMatchKind
+}
+
+// MotionRenameMap はモーションのトラック名の置き換え表を表す。
+type MotionRenameMap struct {
+	Bones  map[string]string
+	Morphs map[string]string
+}
+
+// MotionCompatibilityReport はモーションとモデルの名前の対応状況を表す。
+type MotionCompatibilityReport struct {
+	// UnmatchedBoneNames はモデルに対象ボーンが無いボーントラック名。
+	UnmatchedBoneNames []string
+	// UnmatchedMorphNames はモデルに対象モーフが無いモーフトラック名。
+	UnmatchedMorphNames []string
+	// UndrivenBoneNames はモーションから動かされない操作可能ボーン名。
+	UndrivenBoneNames []string
+	// BoneSuggestions は対象の無いボーントラックに対する候補。
+	BoneSuggestions []NameSuggestion
+	// MorphSuggestions は対象の無いモーフトラックに対する候補。
+	MorphSuggestions []NameSuggestion
+}
+
+// IsCompatible は対象の無いトラックが無いか判定する。
+func (r *MotionCompatibilityReport) IsCompatible() bool {
+	return len(r.UnmatchedBoneNames) == 0 && len(r.UnmatchedMorphNames) == 0
+}
+
+// RenameMap は候補からトラック名の置き換え表を生成する。
+func (r *MotionCompatibilityReport) RenameMap() MotionRenameMap {
+	renames := MotionRenameMap{Bones: map[string]string{}, Morphs: map[string]string{}}
+	for _, suggestion := range r.BoneSuggestions {
+		renames.Bones[suggestion.Source] = suggestion.Target
+	}
+	for _, suggestion := range r.MorphSuggestions {
+		renames.Morphs[suggestion.Source] = suggestion.Target
+	}
+	return renames
+}
+
+// AnalyzeMotionCompatibility はモーションのトラック名がモデルに適用できるか調べる。
+func AnalyzeMotionCompatibility(modelData *model.PmxModel, motionData *motion.VmdMotion) (*MotionCompatibilityReport, error) {
+	if modelData == nil {
+		return nil, merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if motionData == nil {
+		return nil, merr.NewCommonError(motionNotSpecifiedErrorID, merr.ErrorKindValidate, messages.MotionNotSpecified, nil)
+	}
+
+	report := &MotionCompatibilityReport{}
+
+	boneNames := make([]string, 0)
+	undriven := make([]string, 0)
+	if modelData.Bones != nil {
+		for _, bone := range modelData.Bones.Values() {
+			if bone == nil {
+				continue
+			}
+			boneNames = append(boneNames, bone.Name())
+			if !bone.IsSystem && bone.BoneFlag&model.BONE_FLAG_CAN_MANIPULATE != 0 &&
+				!motionData.BoneFrames.Has(bone.Name()) {
+				undriven = append(undriven, bone.Name())
+			}
+		}
+	}
+	report.UndrivenBoneNames = undriven
+
+	morphNames := make([]string, 0)
+	if modelData.Morphs != nil {
+		for _, morph := range modelData.Morphs.Values() {
+			if morph != nil {
+				morphNames = append(morphNames, morph.Name())
+			}
+		}
+	}
+
+	var trackNames []string
+	if motionData.BoneFrames != nil {
+		trackNames = motionData.BoneFrames.Names()
+	}
+	report.UnmatchedBoneNames, report.BoneSuggestions =
+		matchTrackNames(trackNames, boneNames, newNameMatcher(boneNames, BONE_NAME_ALIASES))
+
+	trackNames = nil
+	if motionData.MorphFrames != nil {
+		trackNames = motionData.MorphFrames.Names()
+	}
+	report.UnmatchedMorphNames, report.MorphSuggestions =
+		matchTrackNames(trackNames, morphNames, newNameMatcher(morphNames, MORPH_NAME_ALIASES))
+
+	return report, nil
+}
+
+// ApplyMotionRenameMap はモーションのトラック名を置き換え、置き換えた件数を返す。
+// 置き換え先のトラックが既に存在する場合は上書きせずに残す。
+func ApplyMotionRenameMap(motionData *motion.VmdMotion, renames MotionRenameMap) (int, error) {
+	if motionData == nil {
+		return 0, merr.NewCommonError(motionNotSpecifiedErrorID, merr.ErrorKindValidate, messages.MotionNotSpecified, nil)
+	}
+	count := 0
+	if motionData.BoneFrames != nil {
+		for _, name := range motionData.BoneFrames.Names() {
+			target, ok := renames.Bones[name]
+			if !ok || target == name || motionData.BoneFrames.Has(target) {
+				continue
+			}
+			frames := motionData.BoneFrames.Get(name)
+			motionData.BoneFrames.Delete(name)
+			frames.Name = target
+			motionData.BoneFrames.Update(frames)
+			count++
+		}
+	}
+	if motionData.MorphFrames != nil {
+		for _, name := range motionData.MorphFrames.Names() {
+			target, ok := renames.Morphs[name]
+			if !ok || target == name || motionData.MorphFrames.Has(target) {
+				continue
+			}
+			frames := motionData.MorphFrames.Get(name)
+			motionData.MorphFrames.Delete(name)
+			frames.Name = target
+			motionData.MorphFrames.Update(frames)
+			count++
+		}
+	}
+	if count > 0 {
+		motionData.UpdateHash()
+	}
+	return count, nil
+}
+
+// matchTrackNames は対象の無いトラック名と候補を返す。
+// 既存トラックや他の候補と同じ名前へは置き換えない。
+func matchTrackNames(trackNames, targetNames []string, matcher *nameMatcher) ([]string, []NameSuggestion) {
+	targets := make(map[string]struct{}, len(targetNames))
+	for _, name := range targetNames {
+		targets[name] = struct{}{}
+	}
+	used := make(map[string]struct{}, len(trackNames))
+	for _, name := range trackNames {
+		used[name] = struct{}{}
+	}
+
+	unmatched := make([]string, 0)
+	suggestions := make([]NameSuggestion, 0)
+	for _, name := range trackNames {
+		if _, ok := targets[name]; ok {
+			continue
+		}
+		unmatched = append(unmatched, name)
+		target, kind, ok := matcher.match(name)
+		if !ok {
+			continue
+		}
+		if _, exists := used[target]; exists {
+			continue
+		}
+		used[target] = struct{}{}
+		suggestions = append(suggestions, NameSuggestion{Source: name, Target: target, Kind: kind})
+	}
+	return unmatched, suggestions
+}
+
+// nameMatcher は正規化段階ごとの名前索引を表す。値が空文字の場合は候補が複数ある。
+type nameMatcher struct {
+	normalized map[string]string
+	sided      map[string]string
+	aliased    map[string]string
+	aliases    map[string]string
+}
+
+// newNameMatcher は対象名から索引を生成する。
+func newNameMatcher(names []string, aliasGroups [][]string) *nameMatcher {
+	m := &nameMatcher{
+		normalized: map[string]string{},
+		sided:      map[string]string{},
+		aliased:    map[string]string{},
+		aliases:    buildAliasIndex(aliasGroups),
+	}
+	for _, name := range names {
+		key := normalizeName(name)
+		addNameIndex(m.normalized, key, name)
+		key = canonicalSide(key)
+		addNameIndex(m.sided, key, name)
+		addNameIndex(m.aliased, m.canonicalAlias(key), name)
+	}
+	return m
+}
+
+// match は近似一致する対象名を返す。
+func (m *nameMatcher) match(name string) (string, NameMatchKind, bool) {
+	key := normalizeName(name)
+	if target := m.normalized[key]; target != "" {
+		return target, NAME_MATCH_NORMALIZED, true
+	}
+	key = canonicalSide(key)
+	if target := m.sided[key]; target != "" {
+		return target, NAME_MATCH_SIDE, true
+	}
+	if target := m.aliased[m.canonicalAlias(key)]; target != "" {
+		return target, NAME_MATCH_ALIAS, true
+	}
+	return "", 0, false
+}
+
+// canonicalAlias は別名の組の代表名を返す。別名が無い場合はそのまま返す。
+func (m *nameMatcher) canonicalAlias(key string) string {
+	if canonical, ok := m.aliases[key]; ok {
+		return canonical
+	}
+	return key
+}
+
+// addNameIndex は索引へ名前を追加する。既に別の名前がある場合は曖昧として空文字にする。
+func addNameIndex(index map[string]string, key, name string) {
+	if current, ok := index[key]; ok && current != name {
+		index[key] = ""
+		return
+	}
+	index[key] = name
+}
+
+// buildAliasIndex は別名の組から正規化名→代表名の索引を生成する。
+func buildAliasIndex(groups [][]string) map[string]string {
+	index := map[string]string{}
+	directions := []model.BoneDirection{model.BONE_DIRECTION_RIGHT, model.BONE_DIRECTION_LEFT}
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		if !strings.Contains(group[0], model.BONE_DIRECTION_PREFIX) {
+			canonical := canonicalSide(normalizeName(group[0]))
+			for _, alias := range group {
+				index[canonicalSide(normalizeName(alias))] = canonical
+			}
+			continue
+		}
+		for _, direction := range directions {
+			canonical := canonicalSide(normalizeName(
+				strings.ReplaceAll(group[0], model.BONE_DIRECTION_PREFIX, string(direction))))
+			for _, alias := range group {
+				key := canonicalSide(normalizeName(
+					strings.ReplaceAll(alias, model.BONE_DIRECTION_PREFIX, string(direction))))
+				index[key] = canonical
+			}
+		}
+	}
+	return index
+}
+
+// normalizeName は全角/半角・前後の空白・大文字/小文字の違いを吸収した名前を返す。
+func normalizeName(name string) string {
+	return strings.ToUpper(strings.TrimSpace(width.Fold.String(name)))
+}
+
+// SIDE_SUFFIXES は正規化後の名前末尾の左右表記。
+var SIDE_SUFFIXES = map[string]model.BoneDirection{
+	"_L": model.BONE_DIRECTION_LEFT,
+	".L": model.BONE_DIRECTION_LEFT,
+	"-L": model.BONE_DIRECTION_LEFT,
+	"_R": model.BONE_DIRECTION_RIGHT,
+	".R": model.BONE_DIRECTION_RIGHT,
+	"-R": model.BONE_DIRECTION_RIGHT,
+}
+
+// canonicalSide は末尾の左右表記を先頭の 左/右 表記へ揃える。
+func canonicalSide(key string) string {
+	for suffix, direction := range SIDE_SUFFIXES {
+		if base, ok := strings.CutSuffix(key, suffix); ok && base != "" {
+			return string(direction) + strings.TrimSpace(base)
+		}
+	}
+	return key
+}
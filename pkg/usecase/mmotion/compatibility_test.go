@@ -0,0 +1,111 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+)
+
+// newCompatibilityTestModel は名前照合用のモデルを生成する。
+func newCompatibilityTestModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	for _, name := range []string{"センター", "左足ＩＫ", "右ひざ", "左腕", "首"} {
+		bone := &model.Bone{ParentIndex: -1, TailIndex: -1, EffectIndex: -1, BoneFlag: model.BONE_FLAG_CAN_MANIPULATE}
+		bone.SetName(name)
+		m.Bones.Append(bone)
+	}
+	for _, name := range []string{"まばたき", "あ"} {
+		morph := &model.Morph{}
+		morph.SetName(name)
+		m.Morphs.Append(morph)
+	}
+	return m
+}
+
+// TestAnalyzeMotionCompatibility は不一致トラック・未駆動ボーン・候補を確認する。
+func TestAnalyzeMotionCompatibility(t *testing.T) {
+	m := newCompatibilityTestModel()
+	vmd := motion.NewVmdMotion("")
+	for _, name := range []string{"センター", "左足IK ", "右膝", "腕_L", "存在しない"} {
+		vmd.BoneFrames.Get(name).Update(motion.NewBoneFrame(0))
+	}
+	for _, name := range []string{"瞬き", "あ"} {
+		vmd.MorphFrames.Get(name).Update(motion.NewMorphFrame(0))
+	}
+
+	report, err := AnalyzeMotionCompatibility(m, vmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.IsCompatible() {
+		t.Fatalf("report should not be compatible")
+	}
+	if len(report.UnmatchedBoneNames) != 4 || len(report.UnmatchedMorphNames) != 1 {
+		t.Fatalf("unmatched mismatch: %v %v", report.UnmatchedBoneNames, report.UnmatchedMorphNames)
+	}
+	if len(report.UndrivenBoneNames) != 4 {
+		t.Fatalf("undriven mismatch: %v", report.UndrivenBoneNames)
+	}
+
+	expected := map[string]NameSuggestion{
+		"左足IK ": {Source: "左足IK ", Target: "左足ＩＫ", Kind: NAME_MATCH_NORMALIZED},
+		"右膝":    {Source: "右膝", Target: "右ひざ", Kind: NAME_MATCH_ALIAS},
+		"腕_L":   {Source: "腕_L", Target: "左腕", Kind: NAME_MATCH_SIDE},
+	}
+	if len(report.BoneSuggestions) != len(expected) {
+		t.Fatalf("bone suggestions mismatch: %v", report.BoneSuggestions)
+	}
+	for _, suggestion := range report.BoneSuggestions {
+		if expected[suggestion.Source] != suggestion {
+			t.Fatalf("unexpected suggestion: %+v", suggestion)
+		}
+	}
+	if len(report.MorphSuggestions) != 1 || report.MorphSuggestions[0].Target != "まばたき" {
+		t.Fatalf("morph suggestions mismatch: %v", report.MorphSuggestions)
+	}
+
+	count, err := ApplyMotionRenameMap(vmd, report.RenameMap())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 4 {
+		t.Fatalf("renamed count mismatch: %d", count)
+	}
+	report, err = AnalyzeMotionCompatibility(m, vmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.UnmatchedBoneNames) != 1 || report.UnmatchedBoneNames[0] != "存在しない" || len(report.UnmatchedMorphNames) != 0 {
+		t.Fatalf("rename not applied: %v %v", report.UnmatchedBoneNames, report.UnmatchedMorphNames)
+	}
+	if len(report.UndrivenBoneNames) != 1 || report.UndrivenBoneNames[0] != "首" {
+		t.Fatalf("undriven mismatch after rename: %v", report.UndrivenBoneNames)
+	}
+}
+
+// TestApplyMotionRenameMapKeepsExistingTrack は置き換え先が既にある場合に上書きしないことを確認する。
+func TestApplyMotionRenameMapKeepsExistingTrack(t *testing.T) {
+	vmd := motion.NewVmdMotion("")
+	vmd.BoneFrames.Get("左足IK").Update(motion.NewBoneFrame(0))
+	vmd.BoneFrames.Get("左足ＩＫ").Update(motion.NewBoneFrame(5))
+
+	count, err := ApplyMotionRenameMap(vmd, MotionRenameMap{Bones: map[string]string{"左足IK": "左足ＩＫ"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 0 || !vmd.BoneFrames.Has("左足IK") || !vmd.BoneFrames.Get("左足ＩＫ").Has(5) {
+		t.Fatalf("existing track should be kept: %d", count)
+	}
+}
+
+// TestAnalyzeMotionCompatibilityNil は未指定時のエラーを確認する。
+func TestAnalyzeMotionCompatibilityNil(t *testing.T) {
+	if _, err := AnalyzeMotionCompatibility(nil, motion.NewVmdMotion("")); err == nil {
+		t.Fatalf("expected error for nil model")
+	}
+	if _, err := AnalyzeMotionCompatibility(model.NewPmxModel(), nil); err == nil {
+		t.Fatalf("expected error for nil motion")
+	}
+}
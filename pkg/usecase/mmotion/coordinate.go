@@ -0,0 +1,170 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"math"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+const (
+	motionNotSpecifiedErrorID = "13513"
+)
+
+// ConvertMotionCoordinate はモーション全体の座標系と長さ単位を from から to へ変換する。
+// カメラ角度は変換後の回転から再計算するため、多回転の情報は失われる。
+func ConvertMotionCoordinate(motionData *motion.VmdMotion, from, to mmath.CoordinateSystem) error {
+	if motionData == nil {
+		return merr.NewCommonError(motionNotSpecifiedErrorID, merr.ErrorKindValidate, messages.MotionNotSpecified, nil)
+	}
+	converter, err := mmath.NewCoordinateConverter(from, to)
+	if err != nil {
+		return err
+	}
+
+	if motionData.BoneFrames != nil {
+		for _, name := range motionData.BoneFrames.Names() {
+			motionData.BoneFrames.Get(name).ForEach(func(_ motion.Frame, bf *motion.BoneFrame) bool {
+				convertBoneFrame(bf, converter)
+				return true
+			})
+		}
+	}
+	if motionData.CameraFrames != nil {
+		motionData.CameraFrames.ForEach(func(_ motion.Frame, cf *motion.CameraFrame) bool {
+			convertCameraFrame(cf, converter)
+			return true
+		})
+	}
+	if motionData.LightFrames != nil {
+		motionData.LightFrames.ForEach(func(_ motion.Frame, lf *motion.LightFrame) bool {
+			lf.Position = converter.Direction(lf.Position)
+			return true
+		})
+	}
+	if motionData.GravityFrames != nil {
+		motionData.GravityFrames.ForEach(func(_ motion.Frame, gf *motion.GravityFrame) bool {
+			gf.Gravity = convertDirectionPtr(gf.Gravity, converter)
+			return true
+		})
+	}
+	if motionData.WindDirectionFrames != nil {
+		motionData.WindDirectionFrames.ForEach(func(_ motion.Frame, wf *motion.WindDirectionFrame) bool {
+			wf.Direction = convertDirectionPtr(wf.Direction, converter)
+			return true
+		})
+	}
+	if motionData.RigidBodyFrames != nil {
+		for _, name := range motionData.RigidBodyFrames.Names() {
+			motionData.RigidBodyFrames.Get(name).ForEach(func(_ motion.Frame, rf *motion.RigidBodyFrame) bool {
+				rf.Position = convertPositionPtr(rf.Position, converter)
+				if rf.Size != nil {
+					size := rf.Size.MuledScalar(converter.Scale())
+					rf.Size = &size
+				}
+				return true
+			})
+		}
+	}
+	if motionData.JointFrames != nil {
+		for _, name := range motionData.JointFrames.Names() {
+			motionData.JointFrames.Get(name).ForEach(func(_ motion.Frame, jf *motion.JointFrame) bool {
+				convertJointFrame(jf, converter)
+				return true
+			})
+		}
+	}
+	motionData.UpdateHash()
+	return nil
+}
+
+// convertBoneFrame はボーンフレームの移動・回転・スケールを変換する。
+func convertBoneFrame(bf *motion.BoneFrame, converter mmath.CoordinateConverter) {
+	if bf == nil {
+		return
+	}
+	bf.Position = convertPositionPtr(bf.Position, converter)
+	bf.CancelablePosition = convertPositionPtr(bf.CancelablePosition, converter)
+	bf.Rotation = convertQuaternionPtr(bf.Rotation, converter)
+	bf.UnitRotation = convertQuaternionPtr(bf.UnitRotation, converter)
+	bf.CancelableRotation = convertQuaternionPtr(bf.CancelableRotation, converter)
+	bf.Scale = convertScalePtr(bf.Scale, converter)
+	bf.CancelableScale = convertScalePtr(bf.CancelableScale, converter)
+}
+
+// convertCameraFrame はカメラフレームの位置・距離・回転を変換する。
+func convertCameraFrame(cf *motion.CameraFrame, converter mmath.CoordinateConverter) {
+	if cf == nil {
+		return
+	}
+	cf.Position = convertPositionPtr(cf.Position, converter)
+	cf.Distance = converter.Length(cf.Distance)
+	var rotation mmath.Quaternion
+	switch {
+	case cf.Degrees != nil:
+		rotation = mmath.NewQuaternionFromDegrees(cf.Degrees.X, cf.Degrees.Y, cf.Degrees.Z)
+	case cf.Quaternion != nil:
+		rotation = *cf.Quaternion
+	default:
+		return
+	}
+	rotation = converter.Quaternion(rotation)
+	degrees := rotation.ToDegrees()
+	cf.Quaternion = &rotation
+	cf.Degrees = &degrees
+}
+
+// convertJointFrame はジョイントフレームのローカル制限を変換する。
+func convertJointFrame(jf *motion.JointFrame, converter mmath.CoordinateConverter) {
+	if jf == nil {
+		return
+	}
+	if jf.TranslationLimitMin != nil && jf.TranslationLimitMax != nil {
+		minLimit, maxLimit := converter.OrientationLocalRanges(*jf.TranslationLimitMin, *jf.TranslationLimitMax)
+		jf.TranslationLimitMin, jf.TranslationLimitMax = &minLimit, &maxLimit
+	}
+	if jf.RotationLimitMin != nil && jf.RotationLimitMax != nil {
+		minLimit, maxLimit := converter.OrientationLocalAngleLimits(*jf.RotationLimitMin, *jf.RotationLimitMax)
+		jf.RotationLimitMin, jf.RotationLimitMax = &minLimit, &maxLimit
+	}
+}
+
+// convertPositionPtr は位置を単位スケール込みで変換する。
+func convertPositionPtr(v *mmath.Vec3, converter mmath.CoordinateConverter) *mmath.Vec3 {
+	if v == nil {
+		return nil
+	}
+	out := converter.Position(*v)
+	return &out
+}
+
+// convertDirectionPtr は方向を変換する。
+func convertDirectionPtr(v *mmath.Vec3, converter mmath.CoordinateConverter) *mmath.Vec3 {
+	if v == nil {
+		return nil
+	}
+	out := converter.Direction(*v)
+	return &out
+}
+
+// convertQuaternionPtr は回転を変換する。
+func convertQuaternionPtr(q *mmath.Quaternion, converter mmath.CoordinateConverter) *mmath.Quaternion {
+	if q == nil {
+		return nil
+	}
+	out := converter.Quaternion(*q)
+	return &out
+}
+
+// convertScalePtr は軸ごとの倍率を軸の入れ替えに合わせて並べ替える。
+func convertScalePtr(v *mmath.Vec3, converter mmath.CoordinateConverter) *mmath.Vec3 {
+	if v == nil {
+		return nil
+	}
+	out := converter.Direction(*v)
+	out.X, out.Y, out.Z = math.Abs(out.X), math.Abs(out.Y), math.Abs(out.Z)
+	return &out
+}
@@ -0,0 +1,53 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// TestConvertMotionCoordinateGltf はglTF座標系への変換でボーン・カメラ・物理フレームが整合することを確認する。
+func TestConvertMotionCoordinateGltf(t *testing.T) {
+	motionData := motion.NewVmdMotion("")
+	bf := motion.NewBoneFrame(0)
+	position := mmath.Vec3{Vec: r3.Vec{X: 1, Y: 2, Z: 3}}
+	rotation := mmath.NewQuaternionFromDegrees(10, 20, 30)
+	bf.Position = &position
+	bf.Rotation = &rotation
+	motionData.BoneFrames.Get("センター").Append(bf)
+
+	cf := motion.NewCameraFrame(0)
+	cf.Distance = -45
+	cf.Degrees = &mmath.Vec3{Vec: r3.Vec{X: 0, Y: 90, Z: 0}}
+	motionData.CameraFrames.Append(cf)
+
+	wf := motion.NewWindDirectionFrame(0)
+	wf.Direction = &mmath.Vec3{Vec: r3.Vec{X: 0, Y: 0, Z: 1}}
+	motionData.WindDirectionFrames.Append(wf)
+
+	if err := ConvertMotionCoordinate(motionData, mmath.COORDINATE_SYSTEM_MMD, mmath.COORDINATE_SYSTEM_GLTF); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bf.Position.NearEquals(mmath.Vec3{Vec: r3.Vec{X: 0.08, Y: 0.16, Z: -0.24}}, 1e-9) {
+		t.Fatalf("bone position mismatch: %v", bf.Position)
+	}
+	if math.Abs(bf.Rotation.X()+rotation.X()) > 1e-9 || math.Abs(bf.Rotation.Z()-rotation.Z()) > 1e-9 {
+		t.Fatalf("bone rotation mismatch: %v %v", bf.Rotation, rotation)
+	}
+	if math.Abs(cf.Distance+3.6) > 1e-9 || math.Abs(cf.Degrees.Y+90) > 1e-6 {
+		t.Fatalf("camera mismatch: %v %v", cf.Distance, cf.Degrees)
+	}
+	if !wf.Direction.NearEquals(mmath.Vec3{Vec: r3.Vec{X: 0, Y: 0, Z: -1}}, 1e-9) {
+		t.Fatalf("wind direction mismatch: %v", wf.Direction)
+	}
+
+	err := ConvertMotionCoordinate(nil, mmath.COORDINATE_SYSTEM_MMD, mmath.COORDINATE_SYSTEM_GLTF)
+	if merr.ExtractErrorID(err) != motionNotSpecifiedErrorID {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
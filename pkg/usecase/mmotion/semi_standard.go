@@ -0,0 +1,376 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"slices"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+// maxSemiStandardEffectRecursion は付与を辿る最大深さ。
+const maxSemiStandardEffectRecursion = 10
+
+// ConvertSemiStandardMotion は変換元モデル向けモーションを変換先モデルのボーン構成へ合わせる。
+// 全ての親/グルーブ/上半身2/肩P/肩C/親指０/腰キャンセル/足D/ひざD/足首D のうち、
+// 変換先に無いボーンのキーは子ボーンへ合成して削除し、変換先にのみ有るD系ボーンにはFKボーンと同じ姿勢、
+// キャンセルボーンには打ち消しのキーを登録する。
+// IK を除いたボーンのグローバル姿勢はキーフレーム上で変わらない。
+func ConvertSemiStandardMotion(sourceModel, targetModel *model.PmxModel, motionData *motion.VmdMotion) error {
+	if sourceModel == nil || targetModel == nil {
+		return merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if motionData == nil {
+		return merr.NewCommonError(motionNotSpecifiedErrorID, merr.ErrorKindValidate, messages.MotionNotSpecified, nil)
+	}
+	if sourceModel.Bones == nil || targetModel.Bones == nil || motionData.BoneFrames == nil {
+		return nil
+	}
+
+	snapshot, err := snapshotBoneFrames(motionData.BoneFrames)
+	if err != nil {
+		return err
+	}
+	converter := &semiStandardConverter{
+		sourceModel: sourceModel,
+		targetModel: targetModel,
+		motionData:  motionData,
+		snapshot:    snapshot,
+		parents:     map[string]string{},
+		removed:     map[string]struct{}{},
+	}
+	for _, bone := range sourceModel.Bones.Values() {
+		if parent, err := sourceModel.Bones.Get(bone.ParentIndex); err == nil && parent != nil {
+			converter.parents[bone.Name()] = parent.Name()
+		}
+	}
+	added := make([]string, 0)
+	for _, name := range semiStandardBoneNames() {
+		inSource := sourceModel.Bones.ContainsByName(name)
+		inTarget := targetModel.Bones.ContainsByName(name)
+		switch {
+		case inSource && !inTarget:
+			converter.removed[name] = struct{}{}
+		case !inSource && inTarget:
+			added = append(added, name)
+		}
+	}
+
+	converter.removeDeformBones()
+	converter.removeIntermediateBones()
+	converter.addDeformBones(added)
+	converter.addCancelBones(added)
+	if converter.changed {
+		motionData.UpdateHash()
+	}
+	return nil
+}
+
+// semiStandardBoneNames は準標準ボーン名を返す。
+func semiStandardBoneNames() []string {
+	names := []string{model.ROOT.String(), model.GROOVE.String(), model.UPPER2.String()}
+	for _, direction := range []model.BoneDirection{model.BONE_DIRECTION_LEFT, model.BONE_DIRECTION_RIGHT} {
+		for _, name := range []model.StandardBoneName{
+			model.SHOULDER_P, model.SHOULDER_C, model.THUMB0, model.WAIST_CANCEL,
+			model.LEG_D, model.KNEE_D, model.ANKLE_D,
+		} {
+			names = append(names, name.StringFromDirection(direction))
+		}
+	}
+	return names
+}
+
+// semiStandardConverter は準標準ボーン変換の作業状態を保持する。
+type semiStandardConverter struct {
+	sourceModel *model.PmxModel
+	targetModel *model.PmxModel
+	motionData  *motion.VmdMotion
+	snapshot    *motion.BoneFrames
+	parents     map[string]string
+	removed     map[string]struct{}
+	changed     bool
+}
+
+// removeDeformBones は変換先に無いD系ボーンの最終姿勢をFKボーンへ移し、D系ボーンのトラックを削除する。
+func (c *semiStandardConverter) removeDeformBones() {
+	for _, direction := range []model.BoneDirection{model.BONE_DIRECTION_LEFT, model.BONE_DIRECTION_RIGHT} {
+		for _, pair := range [][2]model.StandardBoneName{
+			{model.LEG_D, model.LEG}, {model.KNEE_D, model.KNEE}, {model.ANKLE_D, model.ANKLE},
+		} {
+			deformName := pair[0].StringFromDirection(direction)
+			fkName := pair[1].StringFromDirection(direction)
+			if _, ok := c.removed[deformName]; !ok {
+				continue
+			}
+			if !c.sourceModel.Bones.ContainsByName(fkName) || !c.targetModel.Bones.ContainsByName(fkName) {
+				continue
+			}
+			names := append([]string{deformName, fkName}, effectorNames(c.sourceModel, deformName)...)
+			frames := collectBoneKeyFrames(c.motionData, names)
+			rotations := make([]mmath.Quaternion, len(frames))
+			positions := make([]mmath.Vec3, len(frames))
+			for i, frame := range frames {
+				rotations[i], positions[i] = totalBonePose(c.sourceModel, c.snapshot, deformName, frame, 0)
+			}
+			c.changed = writeBonePoses(c.motionData, fkName, frames, rotations, positions) || c.changed
+			if parent, ok := c.parents[deformName]; ok {
+				c.parents[fkName] = parent
+			}
+			delete(c.removed, deformName)
+			if c.motionData.BoneFrames.Has(deformName) {
+				c.motionData.BoneFrames.Delete(deformName)
+				c.changed = true
+			}
+		}
+	}
+}
+
+// removeIntermediateBones は変換先に無い中間ボーンの変形を、変換先に残る子ボーンのキーへ合成する。
+func (c *semiStandardConverter) removeIntermediateBones() {
+	if len(c.removed) == 0 {
+		return
+	}
+	for _, bone := range c.sourceModel.Bones.Values() {
+		name := bone.Name()
+		if !c.targetModel.Bones.ContainsByName(name) {
+			continue
+		}
+		chain := c.removedAncestors(name)
+		if len(chain) == 0 {
+			continue
+		}
+		names := []string{name}
+		for _, ancestor := range chain {
+			names = append(names, ancestor)
+			names = append(names, effectorNames(c.sourceModel, ancestor)...)
+		}
+		frames := collectBoneKeyFrames(c.motionData, names)
+		rotations := readBoneRotations(c.motionData.BoneFrames, name, frames)
+		positions := readBonePositions(c.motionData.BoneFrames, name, frames)
+		for i, frame := range frames {
+			// 削除する祖先の変形を根元側から合成する。
+			parentPose := newRigidPose()
+			for j := len(chain) - 1; j >= 0; j-- {
+				ancestor, _ := c.sourceModel.Bones.GetByName(chain[j])
+				rotation, position := c.localBonePose(ancestor, frame)
+				parentPose = parentPose.muled(boneRigidPose(ancestor, rotation, position))
+			}
+			_, effectPosition := effectBonePose(c.sourceModel, c.snapshot, bone, frame, 0)
+			totalPosition := positions[i].Added(effectPosition)
+			// 付与は自身の回転の後ろに掛かるため、合成回転は自身の回転の前へ置く。
+			rotations[i] = parentPose.rotation.Muled(rotations[i])
+			moved := parentPose.apply(bone.Position.Added(totalPosition)).Subed(bone.Position)
+			positions[i] = positions[i].Added(moved.Subed(totalPosition))
+		}
+		c.changed = writeBonePoses(c.motionData, name, frames, rotations, positions) || c.changed
+	}
+	for name := range c.removed {
+		if c.motionData.BoneFrames.Has(name) {
+			c.motionData.BoneFrames.Delete(name)
+			c.changed = true
+		}
+	}
+}
+
+// addDeformBones は変換先にのみ有るD系ボーンへ、付与と合わせてFKボーンと同じ姿勢になるキーを登録する。
+func (c *semiStandardConverter) addDeformBones(added []string) {
+	for _, direction := range []model.BoneDirection{model.BONE_DIRECTION_LEFT, model.BONE_DIRECTION_RIGHT} {
+		for _, pair := range [][2]model.StandardBoneName{
+			{model.LEG_D, model.LEG}, {model.KNEE_D, model.KNEE}, {model.ANKLE_D, model.ANKLE},
+		} {
+			deformName := pair[0].StringFromDirection(direction)
+			fkName := pair[1].StringFromDirection(direction)
+			if !slices.Contains(added, deformName) || !c.sourceModel.Bones.ContainsByName(fkName) {
+				continue
+			}
+			deformBone, err := c.targetModel.Bones.GetByName(deformName)
+			if err != nil || deformBone == nil {
+				continue
+			}
+			names := append([]string{deformName, fkName}, effectorNames(c.targetModel, deformName)...)
+			frames := collectBoneKeyFrames(c.motionData, names)
+			rotations := make([]mmath.Quaternion, len(frames))
+			positions := make([]mmath.Vec3, len(frames))
+			for i, frame := range frames {
+				rotation, position := totalBonePose(c.sourceModel, c.snapshot, fkName, frame, 0)
+				effectRotation, effectPosition := effectBonePose(c.targetModel, c.motionData.BoneFrames, deformBone, frame, 0)
+				rotations[i] = rotation.Muled(effectRotation.Inverted())
+				positions[i] = position.Subed(effectPosition)
+			}
+			c.changed = writeBonePoses(c.motionData, deformName, frames, rotations, positions) || c.changed
+		}
+	}
+}
+
+// addCancelBones は変換先にのみ有る付与キャンセルボーンへ、付与元の回転を打ち消すキーを登録する。
+func (c *semiStandardConverter) addCancelBones(added []string) {
+	cancelNames := make([]string, 0)
+	for _, direction := range []model.BoneDirection{model.BONE_DIRECTION_LEFT, model.BONE_DIRECTION_RIGHT} {
+		cancelNames = append(cancelNames,
+			model.SHOULDER_C.StringFromDirection(direction), model.WAIST_CANCEL.StringFromDirection(direction))
+	}
+	for _, name := range added {
+		if !slices.Contains(cancelNames, name) {
+			continue
+		}
+		bone, err := c.targetModel.Bones.GetByName(name)
+		if err != nil || bone == nil || bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_ROTATION == 0 || bone.EffectIndex < 0 {
+			continue
+		}
+		effector, err := c.targetModel.Bones.Get(bone.EffectIndex)
+		if err != nil || effector == nil {
+			continue
+		}
+		names := append([]string{name}, effectorNames(c.targetModel, name)...)
+		frames := collectBoneKeyFrames(c.motionData, names)
+		rotations := make([]mmath.Quaternion, len(frames))
+		for i, frame := range frames {
+			effectorRotation, _ := totalBonePose(c.targetModel, c.motionData.BoneFrames, effector.Name(), frame, 0)
+			rotations[i] = effectorRotation.MuledScalar(bone.EffectFactor).Inverted()
+		}
+		c.changed = writeBoneRotations(c.motionData, name, frames, rotations) || c.changed
+	}
+}
+
+// removedAncestors は削除対象の祖先ボーン名を近い順に返す。直近の親が削除対象でない場合は空。
+func (c *semiStandardConverter) removedAncestors(name string) []string {
+	chain := make([]string, 0)
+	for current := name; len(chain) <= c.sourceModel.Bones.Len(); {
+		parent, ok := c.parents[current]
+		if !ok {
+			break
+		}
+		if _, removed := c.removed[parent]; !removed {
+			break
+		}
+		chain = append(chain, parent)
+		current = parent
+	}
+	return chain
+}
+
+// localBonePose は削除対象ボーンの自身のキーと付与を合成した回転と移動量を返す。
+func (c *semiStandardConverter) localBonePose(bone *model.Bone, frame motion.Frame) (mmath.Quaternion, mmath.Vec3) {
+	rotation, position := ownBonePose(c.motionData.BoneFrames, bone.Name(), frame)
+	effectRotation, effectPosition := effectBonePose(c.sourceModel, c.snapshot, bone, frame, 0)
+	return rotation.Muled(effectRotation), position.Added(effectPosition)
+}
+
+// ownBonePose は指定フレームの自身のキーの回転と移動量を返す。
+func ownBonePose(boneFrames *motion.BoneFrames, name string, frame motion.Frame) (mmath.Quaternion, mmath.Vec3) {
+	rotation := mmath.NewQuaternion()
+	position := mmath.Vec3{}
+	if !boneFrames.Has(name) {
+		return rotation, position
+	}
+	if bf := boneFrames.Get(name).Get(frame); bf != nil {
+		if bf.Rotation != nil {
+			rotation = *bf.Rotation
+		}
+		if bf.Position != nil {
+			position = *bf.Position
+		}
+	}
+	return rotation, position
+}
+
+// totalBonePose は付与を含めたボーンの回転と移動量を返す。
+func totalBonePose(
+	modelData *model.PmxModel,
+	boneFrames *motion.BoneFrames,
+	name string,
+	frame motion.Frame,
+	recursion int,
+) (mmath.Quaternion, mmath.Vec3) {
+	rotation, position := ownBonePose(boneFrames, name, frame)
+	bone, err := modelData.Bones.GetByName(name)
+	if err != nil || bone == nil {
+		return rotation, position
+	}
+	effectRotation, effectPosition := effectBonePose(modelData, boneFrames, bone, frame, recursion)
+	return rotation.Muled(effectRotation), position.Added(effectPosition)
+}
+
+// effectBonePose は付与元から受け取る回転と移動量を返す。
+func effectBonePose(
+	modelData *model.PmxModel,
+	boneFrames *motion.BoneFrames,
+	bone *model.Bone,
+	frame motion.Frame,
+	recursion int,
+) (mmath.Quaternion, mmath.Vec3) {
+	rotation := mmath.NewQuaternion()
+	position := mmath.Vec3{}
+	if recursion > maxSemiStandardEffectRecursion || bone.EffectIndex < 0 {
+		return rotation, position
+	}
+	isRotation := bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_ROTATION != 0
+	isTranslation := bone.BoneFlag&model.BONE_FLAG_IS_EXTERNAL_TRANSLATION != 0
+	if !isRotation && !isTranslation {
+		return rotation, position
+	}
+	effector, err := modelData.Bones.Get(bone.EffectIndex)
+	if err != nil || effector == nil {
+		return rotation, position
+	}
+	effectorRotation, effectorPosition := totalBonePose(modelData, boneFrames, effector.Name(), frame, recursion+1)
+	if isRotation {
+		rotation = effectorRotation.MuledScalar(bone.EffectFactor)
+	}
+	if isTranslation {
+		position = effectorPosition.MuledScalar(bone.EffectFactor)
+	}
+	return rotation, position
+}
+
+// effectorNames は付与元を辿ったボーン名を返す。
+func effectorNames(modelData *model.PmxModel, name string) []string {
+	names := make([]string, 0)
+	bone, err := modelData.Bones.GetByName(name)
+	for i := 0; err == nil && bone != nil && i <= maxSemiStandardEffectRecursion; i++ {
+		if bone.EffectIndex < 0 ||
+			bone.BoneFlag&(model.BONE_FLAG_IS_EXTERNAL_ROTATION|model.BONE_FLAG_IS_EXTERNAL_TRANSLATION) == 0 {
+			break
+		}
+		bone, err = modelData.Bones.Get(bone.EffectIndex)
+		if err == nil && bone != nil {
+			names = append(names, bone.Name())
+		}
+	}
+	return names
+}
+
+// rigidPose は x ↦ rotation*x + position の剛体変換を表す。
+type rigidPose struct {
+	rotation mmath.Quaternion
+	position mmath.Vec3
+}
+
+// newRigidPose は恒等変換を返す。
+func newRigidPose() rigidPose {
+	return rigidPose{rotation: mmath.NewQuaternion()}
+}
+
+// boneRigidPose はボーン位置を中心に回転し移動量を加える変換を返す。
+func boneRigidPose(bone *model.Bone, rotation mmath.Quaternion, position mmath.Vec3) rigidPose {
+	return rigidPose{
+		rotation: rotation,
+		position: bone.Position.Added(position).Subed(rotation.MulVec3(bone.Position)),
+	}
+}
+
+// muled は other を先に適用する合成変換を返す。
+func (p rigidPose) muled(other rigidPose) rigidPose {
+	return rigidPose{
+		rotation: p.rotation.Muled(other.rotation),
+		position: p.rotation.MulVec3(other.position).Added(p.position),
+	}
+}
+
+// apply は点を変換する。
+func (p rigidPose) apply(v mmath.Vec3) mmath.Vec3 {
+	return p.rotation.MulVec3(v).Added(p.position)
+}
@@ -0,0 +1,188 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/deform"
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+)
+
+// semiStandardTestBone はテストモデルのボーン定義。
+type semiStandardTestBone struct {
+	name     string
+	parent   string
+	position mmath.Vec3
+	effector string
+	factor   float64
+}
+
+// newSemiStandardTestModel は定義順にボーンを並べたモデルを生成する。
+func newSemiStandardTestModel(defs []semiStandardTestBone) *model.PmxModel {
+	m := model.NewPmxModel()
+	for _, def := range defs {
+		bone := &model.Bone{ParentIndex: -1, TailIndex: -1, EffectIndex: -1, Position: def.position}
+		bone.SetName(def.name)
+		if parent, err := m.Bones.GetByName(def.parent); err == nil {
+			bone.ParentIndex = parent.Index()
+		}
+		if effector, err := m.Bones.GetByName(def.effector); err == nil {
+			bone.BoneFlag |= model.BONE_FLAG_IS_EXTERNAL_ROTATION
+			bone.EffectIndex = effector.Index()
+			bone.EffectFactor = def.factor
+		}
+		m.Bones.Append(bone)
+	}
+	return m
+}
+
+// semiStandardSourceBones は準標準ボーンを持つ左半身の定義を返す。
+func semiStandardSourceBones() []semiStandardTestBone {
+	return []semiStandardTestBone{
+		{name: "全ての親"},
+		{name: "センター", parent: "全ての親", position: vec3Of(0, 8, 0)},
+		{name: "グルーブ", parent: "センター", position: vec3Of(0, 8.5, 0)},
+		{name: "腰", parent: "グルーブ", position: vec3Of(0, 10, 0)},
+		{name: "上半身", parent: "腰", position: vec3Of(0, 11, 0)},
+		{name: "上半身2", parent: "上半身", position: vec3Of(0, 13, 0)},
+		{name: "左肩P", parent: "上半身2", position: vec3Of(1, 15, 0)},
+		{name: "左肩", parent: "左肩P", position: vec3Of(1, 15, 0)},
+		{name: "左肩C", parent: "左肩", position: vec3Of(2, 15, 0), effector: "左肩P", factor: -1},
+		{name: "左腕", parent: "左肩C", position: vec3Of(2, 15, 0)},
+		{name: "左手首", parent: "左腕", position: vec3Of(5, 15, 0)},
+		{name: "左親指０", parent: "左手首", position: vec3Of(5.2, 14.8, 0.3)},
+		{name: "左親指１", parent: "左親指０", position: vec3Of(5.5, 14.6, 0.5)},
+		{name: "下半身", parent: "腰", position: vec3Of(0, 10, 0)},
+		{name: "腰キャンセル左", parent: "下半身", position: vec3Of(1, 9, 0), effector: "腰", factor: -1},
+		{name: "左足", parent: "腰キャンセル左", position: vec3Of(1, 9, 0)},
+		{name: "左ひざ", parent: "左足", position: vec3Of(1, 5, 0)},
+		{name: "左足首", parent: "左ひざ", position: vec3Of(1, 1, 0)},
+		{name: "左足D", parent: "腰キャンセル左", position: vec3Of(1, 9, 0), effector: "左足", factor: 1},
+		{name: "左ひざD", parent: "左足D", position: vec3Of(1, 5, 0), effector: "左ひざ", factor: 1},
+		{name: "左足首D", parent: "左ひざD", position: vec3Of(1, 1, 0), effector: "左足首", factor: 1},
+	}
+}
+
+// semiStandardTargetBones は準標準ボーンを持たない左半身の定義を返す。
+func semiStandardTargetBones() []semiStandardTestBone {
+	defs := make([]semiStandardTestBone, 0)
+	parents := map[string]string{
+		"センター": "", "腰": "センター", "上半身": "腰", "左肩": "上半身", "左腕": "左肩",
+		"左手首": "左腕", "左親指１": "左手首", "下半身": "腰", "左足": "下半身",
+	}
+	for _, def := range semiStandardSourceBones() {
+		parent, ok := parents[def.name]
+		if !ok && def.name != "左ひざ" && def.name != "左足首" {
+			continue
+		}
+		if ok {
+			def.parent = parent
+		}
+		def.effector = ""
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// vec3Of は成分からベクトルを生成する。
+func vec3Of(x, y, z float64) mmath.Vec3 {
+	v := mmath.NewVec3()
+	v.X = x
+	v.Y = y
+	v.Z = z
+	return v
+}
+
+// registerPose は回転と移動量のキーフレームを登録する。
+func registerPose(motionData *motion.VmdMotion, name string, frame motion.Frame, rotation mmath.Quaternion, position mmath.Vec3) {
+	bf := motion.NewBoneFrame(frame)
+	bf.Rotation = &rotation
+	bf.Position = &position
+	motionData.BoneFrames.Get(name).Update(bf)
+}
+
+// semiStandardGlobals は指定ボーンのグローバル行列を返す。
+func semiStandardGlobals(m *model.PmxModel, vmd *motion.VmdMotion, frame motion.Frame, names []string) []mmath.Mat4 {
+	boneDeltas, _ := deform.ComputeBoneDeltas(m, vmd, frame, nil, false, false, false, nil)
+	deform.ApplyBoneMatrices(m, boneDeltas)
+	globals := make([]mmath.Mat4, len(names))
+	for i, name := range names {
+		globals[i] = boneDeltas.GetByName(name).FilledGlobalMatrix()
+	}
+	return globals
+}
+
+// TestConvertSemiStandardMotion は準標準ボーンの削除と追加で姿勢が保たれることを確認する。
+func TestConvertSemiStandardMotion(t *testing.T) {
+	source := newSemiStandardTestModel(semiStandardSourceBones())
+	target := newSemiStandardTestModel(semiStandardTargetBones())
+
+	vmd := motion.NewVmdMotion("")
+	axis := vec3Of(1, 2, 3).Normalized()
+	for i, name := range []string{
+		"全ての親", "センター", "グルーブ", "腰", "上半身", "上半身2", "左肩P", "左肩", "左肩C", "左腕",
+		"左親指０", "左親指１", "下半身", "腰キャンセル左", "左足", "左足D", "左ひざD", "左足首",
+	} {
+		angle := 0.1 * float64(i+1)
+		registerPose(vmd, name, 0, mmath.NewQuaternionFromAxisAngles(axis, angle), vec3Of(0.1*float64(i), 0, 0))
+		registerRotation(vmd, name, 10, mmath.NewQuaternionFromAxisAngles(mmath.UNIT_Y_VEC3, -angle))
+	}
+
+	// 変換先のボーン名と、同じ姿勢になるべき変換元のボーン名。
+	targetNames := []string{"センター", "腰", "上半身", "左肩", "左腕", "左手首", "左親指１", "下半身", "左足", "左ひざ", "左足首"}
+	sourceNames := []string{"センター", "腰", "上半身", "左肩", "左腕", "左手首", "左親指１", "下半身", "左足D", "左ひざD", "左足首D"}
+	frames := []motion.Frame{0, 10}
+	before := make([][]mmath.Mat4, len(frames))
+	for i, frame := range frames {
+		before[i] = semiStandardGlobals(source, vmd, frame, sourceNames)
+	}
+
+	if err := ConvertSemiStandardMotion(source, target, vmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"全ての親", "グルーブ", "上半身2", "左肩P", "左肩C", "左親指０", "腰キャンセル左", "左足D", "左ひざD"} {
+		if vmd.BoneFrames.Has(name) {
+			t.Fatalf("track should be removed: %s", name)
+		}
+	}
+	for i, frame := range frames {
+		after := semiStandardGlobals(target, vmd, frame, targetNames)
+		for j, name := range targetNames {
+			if !after[j].NearEquals(before[i][j], 1e-5) {
+				t.Fatalf("pose changed at %v %s:\n%v\n%v", frame, name, after[j], before[i][j])
+			}
+		}
+	}
+
+	// 準標準ボーンを戻しても姿勢は変わらない。
+	if err := ConvertSemiStandardMotion(target, source, vmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !vmd.BoneFrames.Has("腰キャンセル左") {
+		t.Fatalf("waist cancel track should be added")
+	}
+	for i, frame := range frames {
+		after := semiStandardGlobals(source, vmd, frame, sourceNames)
+		for j, name := range sourceNames {
+			if !after[j].NearEquals(before[i][j], 1e-5) {
+				t.Fatalf("pose changed after restore at %v %s:\n%v\n%v", frame, name, after[j], before[i][j])
+			}
+		}
+	}
+}
+
+// TestConvertSemiStandardMotionInvalid は入力不足でエラーになることを確認する。
+func TestConvertSemiStandardMotionInvalid(t *testing.T) {
+	m := newSemiStandardTestModel(semiStandardTargetBones())
+	if err := ConvertSemiStandardMotion(nil, m, motion.NewVmdMotion("")); err == nil {
+		t.Fatalf("expected error for nil model")
+	}
+	if err := ConvertSemiStandardMotion(m, m, nil); err == nil {
+		t.Fatalf("expected error for nil motion")
+	}
+	if err := ConvertSemiStandardMotion(m, m, motion.NewVmdMotion("")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+)
+
+// SeparateTwistMotion は腕/ひじ/手首の回転から捩り成分を分離し、腕捩/手捩へ移す。
+// 捩り軸はモデルの捩りボーンの固定軸、無い場合は腕→ひじ/ひじ→手首の方向を用いる。
+// モデルに捩りボーンが無い側は変換しない。
+func SeparateTwistMotion(modelData *model.PmxModel, motionData *motion.VmdMotion) error {
+	if modelData == nil {
+		return merr.NewCommonError(modelNotSpecifiedErrorID, merr.ErrorKindValidate, messages.ModelNotSpecified, nil)
+	}
+	if motionData == nil {
+		return merr.NewCommonError(motionNotSpecifiedErrorID, merr.ErrorKindValidate, messages.MotionNotSpecified, nil)
+	}
+	if modelData.Bones == nil || motionData.BoneFrames == nil {
+		return nil
+	}
+
+	changed := false
+	for _, direction := range []model.BoneDirection{model.BONE_DIRECTION_LEFT, model.BONE_DIRECTION_RIGHT} {
+		armName := model.ARM.StringFromDirection(direction)
+		armTwistName := model.ARM_TWIST.StringFromDirection(direction)
+		elbowName := model.ELBOW.StringFromDirection(direction)
+		wristTwistName := model.WRIST_TWIST.StringFromDirection(direction)
+		wristName := model.WRIST.StringFromDirection(direction)
+
+		if axis, ok := twistAxis(modelData, armTwistName, armName, elbowName); ok {
+			names := []string{armName, armTwistName}
+			frames := collectBoneKeyFrames(motionData, names)
+			armRotations := readBoneRotations(motionData.BoneFrames, armName, frames)
+			twistRotations := readBoneRotations(motionData.BoneFrames, armTwistName, frames)
+			for i := range frames {
+				// 腕 = 振り * 捩り のため、捩りを子の腕捩の前へ移す。
+				twist, swing := armRotations[i].SeparateTwistByAxis(axis)
+				armRotations[i] = swing
+				twistRotations[i] = twist.Muled(twistRotations[i])
+			}
+			changed = writeBoneRotations(motionData, armName, frames, armRotations) || changed
+			changed = writeBoneRotations(motionData, armTwistName, frames, twistRotations) || changed
+		}
+
+		if axis, ok := twistAxis(modelData, wristTwistName, elbowName, wristName); ok {
+			names := []string{elbowName, wristTwistName, wristName}
+			frames := collectBoneKeyFrames(motionData, names)
+			elbowRotations := readBoneRotations(motionData.BoneFrames, elbowName, frames)
+			twistRotations := readBoneRotations(motionData.BoneFrames, wristTwistName, frames)
+			wristRotations := readBoneRotations(motionData.BoneFrames, wristName, frames)
+			for i := range frames {
+				// ひじ = 振り * 捩り の捩りは子の手捩の前へ、
+				// 手首 = 捩り * 振り の捩りは親の手捩の後ろへ移す。
+				elbowTwist, elbowSwing := elbowRotations[i].SeparateTwistByAxis(axis)
+				wristTwist, wristSwing := separateLeadingTwist(wristRotations[i], axis)
+				elbowRotations[i] = elbowSwing
+				wristRotations[i] = wristSwing
+				twistRotations[i] = elbowTwist.Muled(twistRotations[i]).Muled(wristTwist)
+			}
+			changed = writeBoneRotations(motionData, elbowName, frames, elbowRotations) || changed
+			changed = writeBoneRotations(motionData, wristTwistName, frames, twistRotations) || changed
+			changed = writeBoneRotations(motionData, wristName, frames, wristRotations) || changed
+		}
+	}
+	if changed {
+		motionData.UpdateHash()
+	}
+	return nil
+}
+
+// MergeTwistMotion は腕捩/手捩の回転を腕/ひじへ戻し、捩りボーンのトラックを削除する。
+// 捩りボーンを持たないモデル向けの変換で、見た目の姿勢は変えない。
+func MergeTwistMotion(motionData *motion.VmdMotion) error {
+	if motionData == nil {
+		return merr.NewCommonError(motionNotSpecifiedErrorID, merr.ErrorKindValidate, messages.MotionNotSpecified, nil)
+	}
+	if motionData.BoneFrames == nil {
+		return nil
+	}
+
+	changed := false
+	for _, direction := range []model.BoneDirection{model.BONE_DIRECTION_LEFT, model.BONE_DIRECTION_RIGHT} {
+		for _, pair := range [][2]string{
+			{model.ARM.StringFromDirection(direction), model.ARM_TWIST.StringFromDirection(direction)},
+			{model.ELBOW.StringFromDirection(direction), model.WRIST_TWIST.StringFromDirection(direction)},
+		} {
+			parentName, twistName := pair[0], pair[1]
+			if !motionData.BoneFrames.Has(twistName) {
+				continue
+			}
+			frames := collectBoneKeyFrames(motionData, []string{parentName, twistName})
+			parentRotations := readBoneRotations(motionData.BoneFrames, parentName, frames)
+			twistRotations := readBoneRotations(motionData.BoneFrames, twistName, frames)
+			for i := range frames {
+				parentRotations[i] = parentRotations[i].Muled(twistRotations[i])
+			}
+			writeBoneRotations(motionData, parentName, frames, parentRotations)
+			motionData.BoneFrames.Delete(twistName)
+			changed = true
+		}
+	}
+	if changed {
+		motionData.UpdateHash()
+	}
+	return nil
+}
+
+// twistAxis は捩りボーンの捩り軸を返す。捩りボーンが無い場合は false を返す。
+func twistAxis(modelData *model.PmxModel, twistName, fromName, toName string) (mmath.Vec3, bool) {
+	twistBone, err := modelData.Bones.GetByName(twistName)
+	if err != nil || twistBone == nil {
+		return mmath.Vec3{}, false
+	}
+	if twistBone.BoneFlag&model.BONE_FLAG_HAS_FIXED_AXIS != 0 && twistBone.FixedAxis.Length() > 0 {
+		return twistBone.FixedAxis.Normalized(), true
+	}
+	fromBone, err := modelData.Bones.GetByName(fromName)
+	if err != nil || fromBone == nil {
+		return mmath.Vec3{}, false
+	}
+	toBone, err := modelData.Bones.GetByName(toName)
+	if err != nil || toBone == nil {
+		return mmath.Vec3{}, false
+	}
+	axis := toBone.Position.Subed(fromBone.Position)
+	if axis.Length() == 0 {
+		return mmath.Vec3{}, false
+	}
+	return axis.Normalized(), true
+}
+
+// separateLeadingTwist は回転を 捩り * 振り に分離して返す。
+func separateLeadingTwist(q mmath.Quaternion, axis mmath.Vec3) (mmath.Quaternion, mmath.Quaternion) {
+	// q^-1 = 振り' * 捩り' と分離すると q = 捩り'^-1 * 振り'^-1 となる。
+	twist, swing := q.Inverted().SeparateTwistByAxis(axis)
+	return twist.Inverted(), swing.Inverted()
+}
@@ -0,0 +1,160 @@
+// 指示: miu200521358
+package mmotion
+
+import (
+	"math"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/mmath"
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/domain/motion"
+)
+
+// newTwistTestModel は X 軸方向に伸びる左腕のモデルを生成する。
+func newTwistTestModel() *model.PmxModel {
+	m := model.NewPmxModel()
+	for i, name := range []string{"左腕", "左腕捩", "左ひじ", "左手捩", "左手首"} {
+		bone := &model.Bone{ParentIndex: i - 1, TailIndex: -1, EffectIndex: -1}
+		bone.SetName(name)
+		bone.Position.X = float64(i)
+		if name == "左腕捩" || name == "左手捩" {
+			bone.BoneFlag = model.BONE_FLAG_HAS_FIXED_AXIS
+			bone.FixedAxis = mmath.UNIT_X_VEC3
+		}
+		m.Bones.Append(bone)
+	}
+	return m
+}
+
+// registerRotation は回転のみのキーフレームを登録する。
+func registerRotation(motionData *motion.VmdMotion, name string, frame motion.Frame, rotation mmath.Quaternion) {
+	bf := motion.NewBoneFrame(frame)
+	bf.Rotation = &rotation
+	motionData.BoneFrames.Get(name).Update(bf)
+}
+
+// chainRotation は指定フレームのボーン列の合成回転を返す。
+func chainRotation(motionData *motion.VmdMotion, names []string, frame motion.Frame) mmath.Quaternion {
+	rotation := mmath.NewQuaternion()
+	for _, name := range names {
+		if !motionData.BoneFrames.Has(name) {
+			continue
+		}
+		if bf := motionData.BoneFrames.Get(name).Get(frame); bf != nil && bf.Rotation != nil {
+			rotation = rotation.Muled(*bf.Rotation)
+		}
+	}
+	return rotation
+}
+
+// TestSeparateAndMergeTwistMotion は捩り分離と統合で姿勢が保たれることを確認する。
+func TestSeparateAndMergeTwistMotion(t *testing.T) {
+	m := newTwistTestModel()
+	vmd := motion.NewVmdMotion("")
+	swing := mmath.NewQuaternionFromAxisAngles(mmath.UNIT_Z_VEC3, math.Pi/6)
+	twist := mmath.NewQuaternionFromAxisAngles(mmath.UNIT_X_VEC3, math.Pi/4)
+	registerRotation(vmd, "左腕", 0, swing.Muled(twist))
+	registerRotation(vmd, "左ひじ", 0, mmath.NewQuaternionFromAxisAngles(mmath.UNIT_Y_VEC3, math.Pi/3).Muled(twist))
+	registerRotation(vmd, "左手首", 10, twist.Muled(swing))
+
+	chain := []string{"左腕", "左腕捩", "左ひじ", "左手捩", "左手首"}
+	before := []mmath.Quaternion{chainRotation(vmd, chain, 0), chainRotation(vmd, chain, 10)}
+
+	if err := SeparateTwistMotion(m, vmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !vmd.BoneFrames.Has("左腕捩") || !vmd.BoneFrames.Has("左手捩") {
+		t.Fatalf("twist tracks not created")
+	}
+	arm := vmd.BoneFrames.Get("左腕").Get(0)
+	if !arm.Rotation.NearEquals(swing, 1e-6) {
+		t.Fatalf("arm swing mismatch: %v", arm.Rotation)
+	}
+	armTwist := vmd.BoneFrames.Get("左腕捩").Get(0)
+	if !armTwist.Rotation.NearEquals(twist, 1e-6) {
+		t.Fatalf("arm twist mismatch: %v", armTwist.Rotation)
+	}
+	wrist := vmd.BoneFrames.Get("左手首").Get(10)
+	if !wrist.Rotation.NearEquals(swing, 1e-6) {
+		t.Fatalf("wrist swing mismatch: %v", wrist.Rotation)
+	}
+	for i, frame := range []motion.Frame{0, 10} {
+		if got := chainRotation(vmd, chain, frame); !got.NearEquals(before[i], 1e-6) {
+			t.Fatalf("pose changed at %v: %v != %v", frame, got, before[i])
+		}
+	}
+
+	if err := MergeTwistMotion(vmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vmd.BoneFrames.Has("左腕捩") || vmd.BoneFrames.Has("左手捩") {
+		t.Fatalf("twist tracks should be removed")
+	}
+	for i, frame := range []motion.Frame{0, 10} {
+		if got := chainRotation(vmd, chain, frame); !got.NearEquals(before[i], 1e-6) {
+			t.Fatalf("pose changed after merge at %v: %v != %v", frame, got, before[i])
+		}
+	}
+}
+
+// TestSeparateTwistMotionWithoutTwistBones は捩りボーンの無いモデルで変換しないことを確認する。
+func TestSeparateTwistMotionWithoutTwistBones(t *testing.T) {
+	m := model.NewPmxModel()
+	bone := &model.Bone{ParentIndex: -1, TailIndex: -1, EffectIndex: -1}
+	bone.SetName("左腕")
+	m.Bones.Append(bone)
+	vmd := motion.NewVmdMotion("")
+	registerRotation(vmd, "左腕", 0, mmath.NewQuaternionFromAxisAngles(mmath.UNIT_X_VEC3, 1))
+	if err := SeparateTwistMotion(m, vmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vmd.BoneFrames.Has("左腕捩") {
+		t.Fatalf("twist track should not be created")
+	}
+	if err := SeparateTwistMotion(nil, vmd); err == nil {
+		t.Fatalf("expected error for nil model")
+	}
+}
+
+// TestSeparateTwistMotionKeepsInsertedFrames は補間曲線付きのトラックへ挿入したキーフレームが
+// 変換前の補間値を保つことを確認する。
+func TestSeparateTwistMotionKeepsInsertedFrames(t *testing.T) {
+	m := newTwistTestModel()
+	vmd := motion.NewVmdMotion("")
+	twist := mmath.NewQuaternionFromAxisAngles(mmath.UNIT_X_VEC3, math.Pi/4)
+	swing := mmath.NewQuaternionFromAxisAngles(mmath.UNIT_Z_VEC3, math.Pi/6)
+	for _, frame := range []motion.Frame{0, 30} {
+		// ひじだけ 0 と 30 にキーを持ち、手首のキー(10, 20)がひじへ挿入される。
+		bf := motion.NewBoneFrame(frame)
+		rotation := mmath.NewQuaternionFromAxisAngles(mmath.UNIT_Y_VEC3, float64(frame)/30).Muled(twist)
+		position := mmath.UNIT_X_VEC3.MuledScalar(float64(frame))
+		bf.Rotation = &rotation
+		bf.Position = &position
+		bf.Curves = motion.NewBoneCurves()
+		bf.Curves.TranslateX = &mmath.Curve{Start: mmath.Vec2{X: 110, Y: 5}, End: mmath.Vec2{X: 20, Y: 120}}
+		bf.Curves.Rotate = &mmath.Curve{Start: mmath.Vec2{X: 110, Y: 5}, End: mmath.Vec2{X: 20, Y: 120}}
+		vmd.BoneFrames.Get("左ひじ").Update(bf)
+	}
+	registerRotation(vmd, "左手首", 10, twist.Muled(swing))
+	registerRotation(vmd, "左手首", 20, twist.Muled(swing))
+
+	frames := []motion.Frame{10, 20}
+	chain := []string{"左腕", "左腕捩", "左ひじ", "左手捩", "左手首"}
+	positions := make([]mmath.Vec3, len(frames))
+	poses := make([]mmath.Quaternion, len(frames))
+	for i, frame := range frames {
+		positions[i] = *vmd.BoneFrames.Get("左ひじ").Get(frame).Position
+		poses[i] = chainRotation(vmd, chain, frame)
+	}
+	if err := SeparateTwistMotion(m, vmd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, frame := range frames {
+		if got := *vmd.BoneFrames.Get("左ひじ").Get(frame).Position; !got.NearEquals(positions[i], 1e-6) {
+			t.Fatalf("elbow position changed at %v: %v != %v", frame, got, positions[i])
+		}
+		if got := chainRotation(vmd, chain, frame); !got.NearEquals(poses[i], 1e-6) {
+			t.Fatalf("pose changed at %v: %v != %v", frame, got, poses[i])
+		}
+	}
+}
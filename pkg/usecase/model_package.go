@@ -0,0 +1,151 @@
+// 指示: miu200521358
+package usecase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/usecase/messages"
+	"github.com/miu200521358/mlib_go/pkg/usecase/port/io"
+)
+
+const (
+	packageRepositoryNotConfiguredErrorID = "93506"
+	packageAssetReadFailedErrorID         = "93507"
+	packageWriteFailedErrorID             = "93508"
+	// DEFAULT_PACKAGE_TEXTURE_DIR はパッケージ内のテクスチャ配置先の既定値。
+	DEFAULT_PACKAGE_TEXTURE_DIR = "tex"
+)
+
+// ModelPackageRequest はモデル一式の書き出し要求を表す。
+type ModelPackageRequest struct {
+	ModelData *model.PmxModel
+	// AssetReader はテクスチャ等の参照ファイルを読み込む。
+	AssetReader io.IAssetReader
+	// Validator は参照ファイルの欠落を検出する。
+	Validator io.ITextureValidator
+	// PathResolver はテクスチャ名を実在するファイルパスへ解決する。nil の場合はモデルのディレクトリと結合する。
+	PathResolver io.ITexturePathResolver
+	// Writer は書き出し先(フォルダ/zip)。Close は呼び出し側で行う。
+	Writer io.IPackageWriter
+	// ModelWriter はPMXを書き込む。
+	ModelWriter io.IStreamFileWriter
+	// ModelFileName はパッケージ内のPMXファイル名。空の場合はモデルファイル名を使う。
+	ModelFileName string
+	// TextureDir はパッケージ内のテクスチャ配置先。空の場合は DEFAULT_PACKAGE_TEXTURE_DIR。
+	TextureDir  string
+	SaveOptions io.SaveOptions
+}
+
+// ModelPackageFile はパッケージへ書き出したファイルを表す。
+type ModelPackageFile struct {
+	// Name はパッケージ内の相対パス。
+	Name string
+	// Sources は同一内容として統合した元ファイルのパス。
+	Sources []string
+}
+
+// ModelPackageResult はモデル一式の書き出し結果を表す。
+type ModelPackageResult struct {
+	// ModelFileName はパッケージ内のPMXファイル名。
+	ModelFileName string
+	Files         []ModelPackageFile
+	// Validation は参照ファイルの検証結果。欠落したファイルは書き出さず、元の名前のまま残す。
+	Validation *TextureValidationResult
+}
+
+// PackageModel はモデルが参照するテクスチャ・スフィア・トゥーンを集めて、PMXと共に書き出す。
+// 同一内容のファイルは1つにまとめ、テクスチャ名はパッケージ内の相対パスへ書き換える。元のモデルは変更しない。
+func PackageModel(request ModelPackageRequest) (*ModelPackageResult, error) {
+	if request.ModelData == nil {
+		return nil, newModelNotLoadedError(messages.ModelNotSpecified)
+	}
+	if request.AssetReader == nil || request.Validator == nil || request.Writer == nil || request.ModelWriter == nil {
+		return nil, merr.NewCommonError(
+			packageRepositoryNotConfiguredErrorID, merr.ErrorKindInternal, messages.PackageRepositoryNotConfigured, nil)
+	}
+	textureDir := request.TextureDir
+	if textureDir == "" {
+		textureDir = DEFAULT_PACKAGE_TEXTURE_DIR
+	}
+	modelFileName := request.ModelFileName
+	if modelFileName == "" {
+		base := filepath.Base(request.ModelData.Path())
+		modelFileName = strings.TrimSuffix(base, filepath.Ext(base)) + ".pmx"
+		if base == "." || base == string(filepath.Separator) {
+			modelFileName = "model.pmx"
+		}
+	}
+
+	packaged, err := request.ModelData.Copy()
+	if err != nil {
+		return nil, err
+	}
+	// 検証は有効フラグを書き換えるため、元のモデルではなく複製に対して行う。
+	result := &ModelPackageResult{
+		ModelFileName: modelFileName,
+		Validation:    ValidateModelTexturesWithResolver(&packaged, request.Validator, request.PathResolver),
+	}
+
+	namesByHash := make(map[[sha256.Size]byte]int)
+	usedNames := make(map[string]struct{})
+	for _, texture := range packaged.Textures.Values() {
+		if texture == nil || !texture.IsValid() {
+			continue
+		}
+		sourcePath := resolveTexturePath(request.PathResolver, request.ModelData.Path(), texture.Name())
+		data, err := request.AssetReader.ReadFile(sourcePath)
+		if err != nil {
+			return nil, merr.NewCommonError(
+				packageAssetReadFailedErrorID, merr.ErrorKindExternal, messages.PackageAssetReadFailed, err, sourcePath)
+		}
+		hash := sha256.Sum256(data)
+		fileIndex, ok := namesByHash[hash]
+		if !ok {
+			name := uniquePackageName(textureDir, texture.Name(), usedNames)
+			if err := request.Writer.WriteFile(name, data); err != nil {
+				return nil, merr.NewCommonError(
+					packageWriteFailedErrorID, merr.ErrorKindExternal, messages.PackageWriteFailed, err, name)
+			}
+			fileIndex = len(result.Files)
+			namesByHash[hash] = fileIndex
+			result.Files = append(result.Files, ModelPackageFile{Name: name})
+		}
+		result.Files[fileIndex].Sources = append(result.Files[fileIndex].Sources, sourcePath)
+		texture.SetName(result.Files[fileIndex].Name)
+	}
+
+	var buf bytes.Buffer
+	packaged.SetPath(modelFileName)
+	if err := request.ModelWriter.SaveTo(&buf, &packaged, request.SaveOptions); err != nil {
+		return nil, err
+	}
+	if err := request.Writer.WriteFile(modelFileName, buf.Bytes()); err != nil {
+		return nil, merr.NewCommonError(
+			packageWriteFailedErrorID, merr.ErrorKindExternal, messages.PackageWriteFailed, err, modelFileName)
+	}
+	return result, nil
+}
+
+// uniquePackageName はパッケージ内で重複しないテクスチャの相対パスを返す。
+// 区切りはWindows/他環境のどちらでも解決できる "/" を使う。
+func uniquePackageName(textureDir, textureName string, usedNames map[string]struct{}) string {
+	base := path.Base(strings.ReplaceAll(textureName, "\\", "/"))
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	name := path.Join(textureDir, base)
+	for i := 2; ; i++ {
+		if _, ok := usedNames[strings.ToLower(name)]; !ok {
+			break
+		}
+		name = path.Join(textureDir, fmt.Sprintf("%s_%d%s", stem, i, ext))
+	}
+	usedNames[strings.ToLower(name)] = struct{}{}
+	return name
+}
@@ -0,0 +1,125 @@
+// 指示: miu200521358
+package usecase
+
+import (
+	"errors"
+	stdio "io"
+	"path/filepath"
+	"testing"
+
+	"github.com/miu200521358/mlib_go/pkg/domain/model"
+	"github.com/miu200521358/mlib_go/pkg/shared/base/merr"
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+	"github.com/miu200521358/mlib_go/pkg/usecase/port/io"
+)
+
+// modelPackageTestReader は事前設定した内容を返すスタブ。
+type modelPackageTestReader struct {
+	files map[string]string
+}
+
+// ReadFile は事前設定した内容を返す。
+func (r *modelPackageTestReader) ReadFile(path string) ([]byte, error) {
+	content, ok := r.files[path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(content), nil
+}
+
+// modelPackageTestWriter は書き込まれたファイルを記録するスタブ。
+type modelPackageTestWriter struct {
+	files map[string]string
+}
+
+// WriteFile は書き込まれた内容を記録する。
+func (w *modelPackageTestWriter) WriteFile(name string, data []byte) error {
+	w.files[name] = string(data)
+	return nil
+}
+
+// Close は何もしない。
+func (w *modelPackageTestWriter) Close() error {
+	return nil
+}
+
+// modelPackageTestModelWriter は書き込まれたモデルを記録するスタブ。
+type modelPackageTestModelWriter struct {
+	saved *model.PmxModel
+}
+
+// SaveTo はモデルを記録し、固定の内容を書き込む。
+func (w *modelPackageTestModelWriter) SaveTo(out stdio.Writer, data hashable.IHashable, opts io.SaveOptions) error {
+	w.saved = data.(*model.PmxModel)
+	_, err := out.Write([]byte("PMX"))
+	return err
+}
+
+func TestPackageModel(t *testing.T) {
+	dir := filepath.Join("work", "model")
+	modelData := model.NewPmxModel()
+	modelData.SetPath(filepath.Join(dir, "ミク.pmd"))
+	for _, name := range []string{"body.png", "sub/body.png", "copy.png", "toon.bmp", "missing.png"} {
+		texture := model.NewTexture()
+		texture.SetName(name)
+		modelData.Textures.AppendRaw(texture)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "body.png"):     "body",
+		filepath.Join(dir, "sub/body.png"): "other body",
+		filepath.Join(dir, "copy.png"):     "body",
+		filepath.Join(dir, "toon.bmp"):     "toon",
+	}
+	validator := &textureValidationTestValidator{existsResults: map[string]bool{}}
+	for path := range files {
+		validator.existsResults[path] = true
+	}
+	writer := &modelPackageTestWriter{files: map[string]string{}}
+	modelWriter := &modelPackageTestModelWriter{}
+
+	result, err := PackageModel(ModelPackageRequest{
+		ModelData:   modelData,
+		AssetReader: &modelPackageTestReader{files: files},
+		Validator:   validator,
+		Writer:      writer,
+		ModelWriter: modelWriter,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ModelFileName != "ミク.pmx" || writer.files["ミク.pmx"] != "PMX" {
+		t.Fatalf("model file mismatch: %s %v", result.ModelFileName, writer.files)
+	}
+	// 同名で内容の異なるファイルは別名にし、同じ内容のファイルは1つにまとめる。
+	expectedNames := []string{"tex/body.png", "tex/body_2.png", "tex/body.png", "tex/toon.bmp", "missing.png"}
+	for i, expected := range expectedNames {
+		texture, _ := modelWriter.saved.Textures.Get(i)
+		if texture.Name() != expected {
+			t.Fatalf("texture %d name mismatch: %s", i, texture.Name())
+		}
+	}
+	if len(writer.files) != 4 || writer.files["tex/body_2.png"] != "other body" {
+		t.Fatalf("packaged files mismatch: %v", writer.files)
+	}
+	if len(result.Files) != 3 || len(result.Files[0].Sources) != 2 {
+		t.Fatalf("dedup result mismatch: %+v", result.Files)
+	}
+	if len(result.Validation.Issues) != 1 || result.Validation.Issues[0].Name != "missing.png" {
+		t.Fatalf("missing texture should be reported: %+v", result.Validation.Issues)
+	}
+	original, _ := modelData.Textures.Get(0)
+	if original.Name() != "body.png" {
+		t.Fatalf("source model should not be changed: %s", original.Name())
+	}
+	// 元のモデルの有効フラグは検証で書き換えない。
+	for _, texture := range modelData.Textures.Values() {
+		if texture.IsValid() {
+			t.Fatalf("source texture valid flag should not be changed: %s", texture.Name())
+		}
+	}
+
+	_, err = PackageModel(ModelPackageRequest{ModelData: modelData})
+	if merr.ExtractErrorID(err) != packageRepositoryNotConfiguredErrorID {
+		t.Fatalf("expected not configured error, got %v", err)
+	}
+}
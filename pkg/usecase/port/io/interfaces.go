@@ -1,7 +1,11 @@
 // 指示: miu200521358
 package io
 
-import "github.com/miu200521358/mlib_go/pkg/shared/hashable"
+import (
+	"io/fs"
+
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+)
 
 // IFileReader は入出力共通の読み込み契約を表す。
 type IFileReader interface {
@@ -13,6 +17,12 @@ type IFileReader interface {
 	InferName(path string) string
 }
 
+// IFsFileReader はfs.FS上のファイルからの読み込み契約を表す。
+type IFsFileReader interface {
+	// LoadFS はfs.FS上のファイルから読み込み、IHashable を返す。fsys が nil の場合はOSのファイルを読み込む。
+	LoadFS(fsys fs.FS, path string) (hashable.IHashable, error)
+}
+
 // IFileWriter は入出力共通の書き込み契約を表す。
 type IFileWriter interface {
 	// Save はIHashableを指定パスへ保存する。
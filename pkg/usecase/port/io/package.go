@@ -0,0 +1,28 @@
+// 指示: miu200521358
+package io
+
+import (
+	stdio "io"
+
+	"github.com/miu200521358/mlib_go/pkg/shared/hashable"
+)
+
+// IStreamFileWriter はio.Writerへの書き込み契約を表す。
+type IStreamFileWriter interface {
+	// SaveTo はIHashableをio.Writerへ書き込む。
+	SaveTo(w stdio.Writer, data hashable.IHashable, opts SaveOptions) error
+}
+
+// IAssetReader はモデルが参照するファイルの読み込み契約を表す。
+type IAssetReader interface {
+	// ReadFile はファイルの内容を読み込む。
+	ReadFile(path string) ([]byte, error)
+}
+
+// IPackageWriter はモデル一式の書き出し先(フォルダ/zip)の契約を表す。
+type IPackageWriter interface {
+	// WriteFile はパッケージ内の相対パスへデータを書き込む。
+	WriteFile(name string, data []byte) error
+	// Close は書き込みを確定する。
+	Close() error
+}
@@ -0,0 +1,12 @@
+// 指示: miu200521358
+package io
+
+import "image"
+
+// ITextureImageRepository はテクスチャ画像の読み書き契約を表す。
+type ITextureImageRepository interface {
+	// LoadImage は画像を読み込む。
+	LoadImage(path string) (image.Image, error)
+	// SaveImage は拡張子に応じた形式で画像を保存する。
+	SaveImage(path string, img image.Image) error
+}
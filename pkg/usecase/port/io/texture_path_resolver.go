@@ -0,0 +1,11 @@
+// 指示: miu200521358
+package io
+
+// ITexturePathResolver はモデル内のテクスチャ名を実在するファイルパスへ解決する契約を表す。
+type ITexturePathResolver interface {
+	// ResolveTexturePath はモデルパスを基準にテクスチャ名を解決する。
+	// 見つからない場合は、モデルのディレクトリと結合しただけのパスと false を返す。
+	ResolveTexturePath(modelPath, textureName string) (string, bool)
+	// ClearCache は解決に使うキャッシュを破棄する。
+	ClearCache()
+}
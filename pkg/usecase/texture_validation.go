@@ -28,13 +28,28 @@ type TextureValidationResult struct {
 }
 
 // ValidateModelTextures はモデルのテクスチャ有効性を検証する。
+// validator がテクスチャパスの解決も実装している場合は、その解決結果を検証する。
 func ValidateModelTextures(modelData *model.PmxModel, validator io.ITextureValidator) *TextureValidationResult {
+	resolver, _ := validator.(io.ITexturePathResolver)
+	return ValidateModelTexturesWithResolver(modelData, validator, resolver)
+}
+
+// ValidateModelTexturesWithResolver はテクスチャ名をresolverで実在パスへ解決してから有効性を検証する。
+// resolver が nil の場合はモデルのディレクトリと結合したパスを検証する。
+func ValidateModelTexturesWithResolver(
+	modelData *model.PmxModel,
+	validator io.ITextureValidator,
+	resolver io.ITexturePathResolver,
+) *TextureValidationResult {
 	result := &TextureValidationResult{}
 	if modelData == nil || modelData.Textures == nil || validator == nil {
 		return result
 	}
+	if resolver != nil {
+		// 前回の検証後に追加・改名したファイルも解決できるよう、モデルごとにキャッシュを破棄する。
+		resolver.ClearCache()
+	}
 
-	baseDir := filepath.Dir(modelData.Path())
 	for _, texture := range modelData.Textures.Values() {
 		if texture == nil {
 			continue
@@ -45,10 +60,7 @@ func ValidateModelTextures(modelData *model.PmxModel, validator io.ITextureValid
 			result.Issues = append(result.Issues, TextureValidationIssue{Name: name})
 			continue
 		}
-		texturePath := name
-		if !filepath.IsAbs(texturePath) {
-			texturePath = filepath.Join(baseDir, texturePath)
-		}
+		texturePath := resolveTexturePath(resolver, modelData.Path(), name)
 		exists, err := validator.ExistsFile(texturePath)
 		if err != nil {
 			result.Errors = append(
@@ -89,3 +101,15 @@ func ValidateModelTextures(modelData *model.PmxModel, validator io.ITextureValid
 	}
 	return result
 }
+
+// resolveTexturePath はモデルパスを基準にテクスチャのファイルパスを求める。
+func resolveTexturePath(resolver io.ITexturePathResolver, modelPath, textureName string) string {
+	if resolver != nil {
+		texturePath, _ := resolver.ResolveTexturePath(modelPath, textureName)
+		return texturePath
+	}
+	if filepath.IsAbs(textureName) {
+		return textureName
+	}
+	return filepath.Join(filepath.Dir(modelPath), textureName)
+}
@@ -202,3 +202,59 @@ func TestValidateModelTexturesNilInput(t *testing.T) {
 		t.Fatalf("nil入力では空結果想定です: %+v", result)
 	}
 }
+
+// textureValidationTestResolver は固定の解決結果を返すスタブ。
+type textureValidationTestResolver struct {
+	resolved map[string]string
+	cleared  int
+}
+
+// ResolveTexturePath は事前設定した解決結果を返す。
+func (r *textureValidationTestResolver) ResolveTexturePath(modelPath, textureName string) (string, bool) {
+	path, ok := r.resolved[textureName]
+	return path, ok
+}
+
+// ClearCache は破棄の回数を記録する。
+func (r *textureValidationTestResolver) ClearCache() {
+	r.cleared++
+}
+
+func TestValidateModelTexturesWithResolver(t *testing.T) {
+	modelData := newTextureValidationTestModel(t, "Tex\\Body.png")
+	resolvedPath := filepath.Join(filepath.Dir(modelData.Path()), "tex", "body.png")
+	validator := &textureValidationTestValidator{existsResults: map[string]bool{resolvedPath: true}}
+	resolver := &textureValidationTestResolver{resolved: map[string]string{"Tex\\Body.png": resolvedPath}}
+
+	result := ValidateModelTexturesWithResolver(modelData, validator, resolver)
+	if len(result.Issues) != 0 || len(result.Errors) != 0 {
+		t.Fatalf("解決済みパスで検証される想定です: %+v", result)
+	}
+	if !modelData.Textures.Values()[0].IsValid() {
+		t.Fatalf("テクスチャ有効フラグが不正です")
+	}
+	ValidateModelTexturesWithResolver(modelData, validator, resolver)
+	if resolver.cleared != 2 {
+		t.Fatalf("検証ごとにキャッシュを破棄する想定です: %d", resolver.cleared)
+	}
+}
+
+// textureValidationTestResolvingValidator はパス解決も行う検証スタブ。
+type textureValidationTestResolvingValidator struct {
+	*textureValidationTestValidator
+	*textureValidationTestResolver
+}
+
+func TestValidateModelTexturesUsesValidatorResolver(t *testing.T) {
+	modelData := newTextureValidationTestModel(t, "Tex\\Body.png")
+	resolvedPath := filepath.Join(filepath.Dir(modelData.Path()), "tex", "body.png")
+	validator := &textureValidationTestResolvingValidator{
+		textureValidationTestValidator: &textureValidationTestValidator{existsResults: map[string]bool{resolvedPath: true}},
+		textureValidationTestResolver:  &textureValidationTestResolver{resolved: map[string]string{"Tex\\Body.png": resolvedPath}},
+	}
+
+	result := ValidateModelTextures(modelData, validator)
+	if len(result.Issues) != 0 || len(result.Errors) != 0 {
+		t.Fatalf("検証処理のパス解決が使われる想定です: %+v", result)
+	}
+}